@@ -1,17 +1,87 @@
 package main
 
 import (
+	"backend/internal/config"
+	"backend/internal/db"
+	"backend/internal/logging"
+	"backend/internal/migrations"
 	"backend/internal/server"
+	"backend/internal/telemetry"
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
-	srv, dbConn, store, _ := server.NewServer()
-	if dbConn != nil {
+	migrate := flag.Bool("migrate", false, "同梱のマイグレーションを適用して終了する")
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "設定ファイル（YAML）のパス。環境変数の値が優先される")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		// ロガーの設定も読めていないため、デフォルトの形式で出力する
+		logging.Setup(config.Default().Log)
+		logging.Fatal("invalid configuration", err)
+	}
+	logging.Setup(cfg.Log)
+
+	if *migrate {
+		dbConn, err := db.InitDBConnection(cfg.DB)
+		if err != nil {
+			logging.Fatal("failed to connect database", err)
+		}
 		defer dbConn.Close()
+		if err := migrations.Apply(context.Background(), dbConn); err != nil {
+			logging.Fatal("migration failed", err)
+		}
+		return
+	}
+
+	// SIGINT / SIGTERM で ctx がキャンセルされ、Run が後片付けをして戻る
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTelemetry, err := telemetry.Init(ctx, cfg.Telemetry)
+	if err != nil {
+		logging.Fatal("failed to init telemetry", err)
 	}
-	if store != nil {
-		defer func() { _ = store.Close() }()
+
+	srv, dbConn, store, err := server.NewServer(cfg)
+	if err != nil {
+		logging.Fatal("failed to start server", err)
+	}
+
+	runErr := srv.Run(ctx)
+	// 二度目のシグナルは通常どおりプロセスを終了させる
+	stop()
+
+	// リクエストとバックグラウンド処理が止まってから DB とテレメトリを閉じる
+	// 止まらなかったバックグラウンド処理がある場合、DB は閉じずにプロセスの終了に任せる
+	if errors.Is(runErr, server.ErrBackgroundRunning) {
+		slog.Warn("leaving the database open for background workers that are still running")
+	} else {
+		if err := store.Close(); err != nil {
+			slog.Error("failed to close statements", "err", err)
+		}
+		if dbConn != nil {
+			if err := dbConn.Close(); err != nil {
+				slog.Error("failed to close database", "err", err)
+			}
+		}
+	}
+	telemetryCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTelemetry(telemetryCtx); err != nil {
+		slog.Error("failed to flush telemetry", "err", err)
 	}
 
-	srv.Run()
+	if runErr != nil {
+		logging.Fatal("server error", runErr)
+	}
+	slog.Info("server stopped")
 }
@@ -0,0 +1,210 @@
+// Package apierror は API のエラーレスポンスを共通の JSON 形式で返す
+//
+//	{"error": {"code": "VALIDATION_FAILED", "message": "...", "fields": [{"field": "quantity", "message": "..."}], "request_id": "..."}}
+//
+// /api/v2 のルートでは同じ内容を RFC 9457 の problem details（application/problem+json）で返す（Problem を参照）
+//
+// クライアントは message ではなく code で分岐すること
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"backend/internal/apiversion"
+	"backend/internal/errreport"
+	"backend/internal/logging"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Code はエラーの種類。追加はできるが、既存の値は変更しないこと
+type Code string
+
+const (
+	CodeBadRequest       Code = "BAD_REQUEST"
+	CodeValidationFailed Code = "VALIDATION_FAILED"
+	CodeUnauthorized     Code = "UNAUTHORIZED"
+	CodeForbidden        Code = "FORBIDDEN"
+	CodeNotFound         Code = "NOT_FOUND"
+	CodeConflict         Code = "CONFLICT"
+	CodePayloadTooLarge  Code = "PAYLOAD_TOO_LARGE"
+	CodeRateLimited      Code = "RATE_LIMITED"
+	CodeTimeout          Code = "TIMEOUT"
+	CodeUnavailable      Code = "UNAVAILABLE"
+	CodeInternal         Code = "INTERNAL"
+
+	// Idempotency-Key が別の内容のリクエストで使われた（422）
+	CodeIdempotencyKeyReused Code = "IDEMPOTENCY_KEY_REUSED"
+	// 同じ Idempotency-Key のリクエストを処理中（409）
+	CodeIdempotencyInProgress Code = "IDEMPOTENCY_IN_PROGRESS"
+)
+
+// FieldError は入力のどのフィールドが不正だったか
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error は HTTP ステータスとエラーコードを持つエラー
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Fields  []FieldError
+	// ログにのみ出力する原因（レスポンスには含めない）
+	cause error
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return string(e.Code) + ": " + e.Message + ": " + e.cause.Error()
+	}
+	return string(e.Code) + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// WithCause はログ用に原因のエラーを付ける
+func (e *Error) WithCause(err error) *Error {
+	c := *e
+	c.cause = err
+	return &c
+}
+
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+// Validation は入力値の検証エラー。fields には不正だったフィールドを渡す
+func Validation(message string, fields ...FieldError) *Error {
+	e := New(http.StatusBadRequest, CodeValidationFailed, message)
+	e.Fields = fields
+	return e
+}
+
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, CodeConflict, message)
+}
+
+func PayloadTooLarge(message string) *Error {
+	return New(http.StatusRequestEntityTooLarge, CodePayloadTooLarge, message)
+}
+
+func RateLimited(message string) *Error {
+	return New(http.StatusTooManyRequests, CodeRateLimited, message)
+}
+
+func Timeout(message string) *Error {
+	return New(http.StatusGatewayTimeout, CodeTimeout, message)
+}
+
+func Unavailable(message string) *Error {
+	return New(http.StatusServiceUnavailable, CodeUnavailable, message)
+}
+
+func Internal(message string) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, message)
+}
+
+// Response はエラーレスポンスの本文
+type Response struct {
+	Error ResponseError `json:"error"`
+}
+
+type ResponseError struct {
+	Code      Code         `json:"code"`
+	Message   string       `json:"message"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// Problem は v2 のエラーレスポンスの本文（RFC 9457）
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Code      Code         `json:"code"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// routeOf は chi のルートのパターン（一致しない場合はパス）
+func routeOf(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return r.Method + " " + pattern
+		}
+	}
+	return r.Method + " " + r.URL.Path
+}
+
+// Write は err をエラーレスポンスとして書き出す
+// *Error 以外のエラーは詳細を隠して INTERNAL として返す。5xx はログにも出力する
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = Internal("Internal server error").WithCause(err)
+	}
+	if e.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), e.Message, "code", e.Code, "method", r.Method, "path", r.URL.Path, "err", e.cause)
+	}
+	// 想定外のエラー（500）はエラートラッキングにも送る（503 / 504 は過負荷や障害の影響のため送らない）
+	if e.Status == http.StatusInternalServerError {
+		reported := error(e)
+		if e.cause != nil {
+			reported = e.cause
+		}
+		errreport.Report(r.Context(), errreport.Event{Err: reported, Request: r, Route: routeOf(r)})
+	}
+
+	var body interface{} = Response{Error: ResponseError{
+		Code:      e.Code,
+		Message:   e.Message,
+		Fields:    e.Fields,
+		RequestID: logging.RequestID(r.Context()),
+	}}
+	contentType := "application/json; charset=utf-8"
+	if apiversion.FromContext(r.Context()) >= apiversion.V2 {
+		body = Problem{
+			Type:      "about:blank",
+			Title:     http.StatusText(e.Status),
+			Status:    e.Status,
+			Detail:    e.Message,
+			Code:      e.Code,
+			Errors:    e.Fields,
+			RequestID: logging.RequestID(r.Context()),
+		}
+		contentType = "application/problem+json; charset=utf-8"
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	// エラーレスポンスにキャッシュ用のヘッダーを残さない
+	h.Del("ETag")
+	h.Del("Last-Modified")
+	w.WriteHeader(e.Status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,48 @@
+// Package apiversion はリクエストがどのバージョンの API（/api/v1, /api/v2）として処理されているかを ctx で受け渡す
+//
+// ハンドラは共通で、レスポンスの形が変わる箇所だけ FromContext で分岐する。
+// v1 の形は既存クライアントとの互換のため変更しないこと
+package apiversion
+
+import (
+	"context"
+	"strconv"
+)
+
+type Version int
+
+const (
+	V1 Version = 1
+	// ページングを pagination オブジェクトにまとめ、エラーを RFC 9457（application/problem+json）で返す
+	V2 Version = 2
+)
+
+// Latest は新規クライアントに案内するバージョン
+const Latest = V2
+
+// Supported はルーティングするバージョンの一覧
+var Supported = []Version{V1, V2}
+
+// Prefix はルートのパス（/api/v1 など）
+func (v Version) Prefix() string {
+	return "/api/" + v.String()
+}
+
+func (v Version) String() string {
+	return "v" + strconv.Itoa(int(v))
+}
+
+type contextKey struct{}
+
+// With は v を ctx に保存する
+func With(ctx context.Context, v Version) context.Context {
+	return context.WithValue(ctx, contextKey{}, v)
+}
+
+// FromContext は ctx に保存されたバージョンを返す。バージョン付きのルート以外では V1
+func FromContext(ctx context.Context) Version {
+	if v, ok := ctx.Value(contextKey{}).(Version); ok {
+		return v
+	}
+	return V1
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"context"
+	"sync"
+
+	"backend/internal/coord"
+)
+
+// 変更されたエンティティの種類
+const (
+	// 商品の作成・更新・削除（一覧の並びや件数が変わりうる）
+	EntityProduct = "product"
+	// 商品の在庫数の変更（一覧の並びや件数は変わらない）
+	EntityProductStock = "product_stock"
+	// 注文の作成（件数が変わる）
+	EntityOrder = "order"
+	// 注文の状態の変更（件数は変わらない）
+	EntityOrderStatus = "order_status"
+	// ユーザーの認証情報の変更
+	EntityUser = "user"
+	// お気に入りの追加・削除（ID はユーザーID）
+	EntityFavorite = "favorite"
+)
+
+var entities = []string{EntityProduct, EntityProductStock, EntityOrder, EntityOrderStatus, EntityUser, EntityFavorite}
+
+// Change はエンティティの変更の通知
+type Change struct {
+	Entity string
+	// 変更された行の ID（一括の変更などで特定できない場合は空）
+	ID string
+}
+
+// Bus はエンティティの変更をキャッシュへ通知する
+// リポジトリが書き込みのコミット後に Publish し、キャッシュを持つ側が Subscribe して該当する値を破棄する
+// 変更のたびに破棄されるため、キャッシュの TTL は通知が届かない場合（ほかのサーバーとの通信の障害など）の上限として長めに取れる
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]func(ctx context.Context, c Change)
+	// nil の場合、通知はこのサーバー内に留める
+	remote *coord.Invalidator
+}
+
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[string][]func(ctx context.Context, c Change))}
+}
+
+// Subscribe は entity の変更時に呼ぶ fn を登録する
+// fn は書き込んだリクエストの中で同期的に呼ばれるため、重い処理を行わないこと
+func (b *Bus) Subscribe(entity string, fn func(ctx context.Context, c Change)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[entity] = append(b.handlers[entity], fn)
+}
+
+// Publish は変更を購読者へ通知し、Forward を設定していればほかのサーバーへも通知する
+// nil の *Bus では何もしない
+func (b *Bus) Publish(ctx context.Context, c Change) {
+	if b == nil {
+		return
+	}
+	if !b.deliver(ctx, c) {
+		return
+	}
+	if b.remote != nil {
+		b.remote.Publish(ctx, c.Entity, c.ID)
+	}
+}
+
+// deliver は購読者へ通知し、購読者がいたかを返す（いない種類はほかのサーバーへも送らない）
+func (b *Bus) deliver(ctx context.Context, c Change) bool {
+	b.mu.RLock()
+	handlers := b.handlers[c.Entity]
+	b.mu.RUnlock()
+	for _, fn := range handlers {
+		fn(ctx, c)
+	}
+	return len(handlers) > 0
+}
+
+// Forward はこのサーバーの変更をほかのサーバーへ送り、ほかのサーバーの変更も購読者へ通知する
+// 起動時、inv.Run の前に呼ぶこと
+func (b *Bus) Forward(inv *coord.Invalidator) {
+	b.remote = inv
+	for _, entity := range entities {
+		inv.Subscribe(entity, func(key string) {
+			b.deliver(context.Background(), Change{Entity: entity, ID: key})
+		})
+	}
+}
@@ -0,0 +1,298 @@
+// Package cache はプロセス内のキャッシュ（TTL + LRU）
+//
+// 参照の結果（ヒット / ミス）と追い出しは cache 名ごとにメトリクス（backend.cache.lookups / backend.cache.evictions）に記録する
+// データの変更は Bus で通知し、各キャッシュが該当する値を破棄する
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"backend/internal/telemetry"
+)
+
+// Options はキャッシュの設定
+type Options[V any] struct {
+	// 保持する期間（TTLFunc が無い場合）
+	TTL time.Duration
+	// 保持する件数の上限。超えた場合は最も長く使われていないものから追い出す（0 で上限なし）
+	MaxSize int
+	// 値ごとに保持する期間を決める（存在しないことを短めに覚える場合など）。0 以下を返した値は保持しない
+	TTLFunc func(V) time.Duration
+	// 保持する期間を過ぎた後も GetOrLoadStale が古い値として返す期間（0 で返さない）
+	StaleTTL time.Duration
+}
+
+// Cache は K をキーに V を保持する。nil の *Cache は常にミスとして振る舞う
+//
+// 値は呼び出し側と共有されるため、スライスやポインタを書き換える場合はコピーしてから使うこと
+type Cache[K comparable, V any] struct {
+	name string
+	opts Options[V]
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[K]*list.Element
+	// 読み込み中の呼び出し（GetOrLoad）
+	loading map[K]*call[V]
+	// 削除のたびに進め、削除前に読み込み始めた値を後から保存しないようにする
+	generation uint64
+}
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+	// これを過ぎると古い値としても返さない（StaleTTL が 0 の場合は expiresAt と同じ）
+	staleUntil time.Time
+}
+
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// 追い出しの理由
+const (
+	evictExpired  = "expired"
+	evictCapacity = "capacity"
+)
+
+// New は name（メトリクスの cache 属性）のキャッシュを作る
+func New[K comparable, V any](name string, opts Options[V]) *Cache[K, V] {
+	return &Cache[K, V]{
+		name:    name,
+		opts:    opts,
+		ll:      list.New(),
+		items:   make(map[K]*list.Element),
+		loading: make(map[K]*call[V]),
+	}
+}
+
+// Get は保持していれば (値, true) を返す
+func (c *Cache[K, V]) Get(ctx context.Context, key K) (V, bool) {
+	if c == nil {
+		var zero V
+		return zero, false
+	}
+	c.mu.Lock()
+	v, ok := c.getLocked(key)
+	c.mu.Unlock()
+	telemetry.RecordCacheLookup(ctx, c.name, ok)
+	return v, ok
+}
+
+func (c *Cache[K, V]) getLocked(key K) (V, bool) {
+	e, fresh := c.lookupLocked(key)
+	if e == nil || !fresh {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// lookupLocked は key の値と、保持する期間内かを返す（古い値としても返せない場合は nil）
+func (c *Cache[K, V]) lookupLocked(key K) (*entry[K, V], bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry[K, V])
+	now := time.Now()
+	if now.After(e.staleUntil) {
+		c.removeLocked(el, evictExpired)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return e, !now.After(e.expiresAt)
+}
+
+// Set は value を保持する
+func (c *Cache[K, V]) Set(key K, value V) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(key, value)
+}
+
+func (c *Cache[K, V]) setLocked(key K, value V) {
+	ttl := c.opts.TTL
+	if c.opts.TTLFunc != nil {
+		ttl = c.opts.TTLFunc(value)
+	}
+	if ttl <= 0 {
+		// 保持しない値で古い値が残らないようにする
+		if el, ok := c.items[key]; ok {
+			c.ll.Remove(el)
+			delete(c.items, key)
+		}
+		return
+	}
+	expiresAt := time.Now().Add(ttl)
+	e := &entry[K, V]{key: key, value: value, expiresAt: expiresAt, staleUntil: expiresAt.Add(c.opts.StaleTTL)}
+	if el, ok := c.items[key]; ok {
+		el.Value = e
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(e)
+	for c.opts.MaxSize > 0 && c.ll.Len() > c.opts.MaxSize {
+		c.removeLocked(c.ll.Back(), evictCapacity)
+	}
+}
+
+func (c *Cache[K, V]) removeLocked(el *list.Element, reason string) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*entry[K, V]).key)
+	telemetry.RecordCacheEviction(context.Background(), c.name, reason)
+}
+
+// GetOrLoad は保持していればその値を、無ければ load の結果を保存して返す
+// 同じキーの読み込みが実行中であれば、その結果を待つ（load は1回だけ呼ばれる）
+// load には最初の呼び出し元のキャンセルが伝わらない ctx を渡す。各呼び出し元は自分の ctx がキャンセルされれば待たずに戻る
+// load がエラーを返した場合は保存しない。読み込み中に Delete / DeleteFunc / Expire / Purge があった場合も、古い可能性があるため保存しない
+func (c *Cache[K, V]) GetOrLoad(ctx context.Context, key K, load func(ctx context.Context) (V, error)) (V, error) {
+	if c == nil {
+		return load(ctx)
+	}
+	c.mu.Lock()
+	if v, ok := c.getLocked(key); ok {
+		c.mu.Unlock()
+		telemetry.RecordCacheLookup(ctx, c.name, true)
+		return v, nil
+	}
+	cl := c.startLoadLocked(ctx, key, load)
+	c.mu.Unlock()
+	telemetry.RecordCacheLookup(ctx, c.name, false)
+
+	select {
+	case <-cl.done:
+		return cl.value, cl.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// GetOrLoadStale は GetOrLoad と同じだが、保持する期間を過ぎた値も StaleTTL の間は待たずに返す
+// 古い値を返した場合は stale を true にし、裏で load を1回だけ呼んで値を更新する（同じキーの更新が実行中であれば何もしない）
+func (c *Cache[K, V]) GetOrLoadStale(ctx context.Context, key K, load func(ctx context.Context) (V, error)) (value V, stale bool, err error) {
+	if c == nil {
+		value, err = load(ctx)
+		return value, false, err
+	}
+	c.mu.Lock()
+	if e, fresh := c.lookupLocked(key); e != nil {
+		if !fresh {
+			c.startLoadLocked(ctx, key, load)
+		}
+		c.mu.Unlock()
+		telemetry.RecordCacheLookup(ctx, c.name, true)
+		return e.value, !fresh, nil
+	}
+	c.mu.Unlock()
+	value, err = c.GetOrLoad(ctx, key, load)
+	return value, false, err
+}
+
+// startLoadLocked は key の読み込みを始める。同じキーの読み込みが実行中であればそれを返す
+func (c *Cache[K, V]) startLoadLocked(ctx context.Context, key K, load func(ctx context.Context) (V, error)) *call[V] {
+	if cl, ok := c.loading[key]; ok {
+		return cl
+	}
+	cl := &call[V]{done: make(chan struct{})}
+	c.loading[key] = cl
+	go c.load(context.WithoutCancel(ctx), key, cl, c.generation, load)
+	return cl
+}
+
+func (c *Cache[K, V]) load(ctx context.Context, key K, cl *call[V], generation uint64, load func(ctx context.Context) (V, error)) {
+	defer close(cl.done)
+	cl.value, cl.err = load(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.loading, key)
+	if cl.err == nil && generation == c.generation {
+		c.setLocked(key, cl.value)
+	}
+}
+
+// Delete は key の値を取り除く
+func (c *Cache[K, V]) Delete(key K) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if el, ok := c.items[key]; ok {
+		c.ll.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+// DeleteFunc は fn が true を返した値を取り除く
+func (c *Cache[K, V]) DeleteFunc(fn func(key K, value V) bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for key, el := range c.items {
+		if fn(key, el.Value.(*entry[K, V]).value) {
+			c.ll.Remove(el)
+			delete(c.items, key)
+		}
+	}
+}
+
+// Expire はすべての値を保持する期間切れにする（StaleTTL の間は GetOrLoadStale が古い値として返す）
+// StaleTTL が 0 の場合は Purge と同じ
+func (c *Cache[K, V]) Expire() {
+	if c == nil {
+		return
+	}
+	if c.opts.StaleTTL <= 0 {
+		c.Purge()
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	now := time.Now()
+	for _, el := range c.items {
+		e := el.Value.(*entry[K, V])
+		if e.expiresAt.After(now) {
+			e.expiresAt = now
+		}
+	}
+}
+
+// Purge はすべての値を取り除く
+func (c *Cache[K, V]) Purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.ll.Init()
+	c.items = make(map[K]*list.Element)
+}
+
+// Len は保持している件数（期限切れで未削除のものを含む）
+func (c *Cache[K, V]) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
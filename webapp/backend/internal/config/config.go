@@ -0,0 +1,532 @@
+// Package config はアプリケーションの設定を1つの型付きの構造体にまとめて読み込む
+//
+// 優先順位は 環境変数 > 設定ファイル（YAML、CONFIG_FILE または -config で指定） > デフォルト値。
+// 各フィールドの env タグが環境変数名、yaml タグが設定ファイルのキー。
+// 値の形式や範囲が不正な場合は起動時にエラーにする（以前のように黙ってデフォルト値に戻さない）
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+type Config struct {
+	Server      Server      `yaml:"server"`
+	TLS         TLS         `yaml:"tls"`
+	Timeouts    Timeouts    `yaml:"timeouts"`
+	DB          DB          `yaml:"db"`
+	Planner     Planner     `yaml:"planner"`
+	Cache       Cache       `yaml:"cache"`
+	RateLimit   RateLimit   `yaml:"rate_limit"`
+	Redis       Redis       `yaml:"redis"`
+	Telemetry   Telemetry   `yaml:"telemetry"`
+	Log         Log         `yaml:"log"`
+	Outbox      Outbox      `yaml:"outbox"`
+	Search      Search      `yaml:"search"`
+	Storage     Storage     `yaml:"storage"`
+	Stock       Stock       `yaml:"stock"`
+	Features    Features    `yaml:"features"`
+	Realtime    Realtime    `yaml:"realtime"`
+	Idempotency Idempotency `yaml:"idempotency"`
+	Stats       Stats       `yaml:"stats"`
+	OrderJobs   OrderJobs   `yaml:"order_jobs"`
+	Messaging   Messaging   `yaml:"messaging"`
+	Notify      Notify      `yaml:"notify"`
+	Reports     Reports     `yaml:"reports"`
+	// Sentry 互換のエラートラッキングへの送信（errreport を参照）
+	ErrorReporting ErrorReporting `yaml:"error_reporting"`
+}
+
+type Server struct {
+	Port string `yaml:"port" env:"PORT"`
+	// 終了時に処理中のリクエストを待つ時間
+	ShutdownGracePeriod time.Duration `yaml:"shutdown_grace_period" env:"SHUTDOWN_GRACE_PERIOD"`
+	// リクエストの処理を待った後、バックグラウンド処理の停止を待つ時間（ShutdownGracePeriod とは別に数える）
+	BackgroundDrainTimeout time.Duration `yaml:"background_drain_timeout" env:"BACKGROUND_DRAIN_TIMEOUT"`
+	// 起動時に同梱のマイグレーションを適用する（MySQL のみ）
+	MigrateOnStart bool   `yaml:"migrate_on_start" env:"MIGRATE_ON_START"`
+	RobotAPIKey    string `yaml:"robot_api_key" env:"ROBOT_API_KEY"`
+	// 一覧のカーソルの署名に使う鍵。空の場合は起動ごとに生成する（複数台で動かす場合は同じ値を指定する）
+	CursorKey string `yaml:"cursor_key" env:"CURSOR_KEY"`
+	// セッションCookieに常に Secure 属性を付ける（TLS を前段で終端する場合）
+	CookieSecure bool `yaml:"cookie_secure" env:"COOKIE_SECURE"`
+	// /api/openapi.json と /api/docs を公開する
+	APIDocs bool `yaml:"api_docs" env:"API_DOCS"`
+	// 管理者向けに /debug/ を公開する
+	DebugEndpoints bool `yaml:"debug_endpoints" env:"DEBUG_ENDPOINTS"`
+	// /debug/ を認証なしで公開するループバックのアドレス（127.0.0.1:6060 など）
+	DebugAddr          string        `yaml:"debug_addr" env:"DEBUG_ADDR"`
+	ReadOnly           bool          `yaml:"read_only" env:"READ_ONLY"`
+	ReadOnlyRetryAfter time.Duration `yaml:"read_only_retry_after" env:"READ_ONLY_RETRY_AFTER"`
+	Compress           bool          `yaml:"compress" env:"COMPRESS"`
+	CompressMinBytes   int           `yaml:"compress_min_bytes" env:"COMPRESS_MIN_BYTES"`
+	CompressBrotli     bool          `yaml:"compress_brotli" env:"COMPRESS_BROTLI"`
+}
+
+// HTTPS を直接終端する場合の設定（CertFile / KeyFile と AutocertDomains は排他）
+type TLS struct {
+	CertFile         string   `yaml:"cert_file" env:"TLS_CERT_FILE"`
+	KeyFile          string   `yaml:"key_file" env:"TLS_KEY_FILE"`
+	AutocertDomains  []string `yaml:"autocert_domains" env:"TLS_AUTOCERT_DOMAINS"`
+	AutocertCacheDir string   `yaml:"autocert_cache_dir" env:"TLS_AUTOCERT_CACHE_DIR"`
+	AutocertEmail    string   `yaml:"autocert_email" env:"TLS_AUTOCERT_EMAIL"`
+	// HTTP を HTTPS にリダイレクトするポート（空なら待ち受けない）
+	RedirectPort string `yaml:"redirect_port" env:"TLS_REDIRECT_PORT"`
+}
+
+// ルートごとのリクエスト全体の時間予算（0 で無効）
+type Timeouts struct {
+	List    time.Duration `yaml:"list" env:"ROUTE_TIMEOUT_LIST"`
+	Write   time.Duration `yaml:"write" env:"ROUTE_TIMEOUT_WRITE"`
+	Robot   time.Duration `yaml:"robot" env:"ROUTE_TIMEOUT_ROBOT"`
+	Default time.Duration `yaml:"default" env:"ROUTE_TIMEOUT_DEFAULT"`
+	// 一覧のエクスポート（CSV / NDJSON）。レスポンスをバッファせず、超過すると接続を切る
+	Export time.Duration `yaml:"export" env:"ROUTE_TIMEOUT_EXPORT"`
+}
+
+type DB struct {
+	// memory の場合はデータベースを使わず、デモ用データ入りのプロセス内のストア（repository.NewMemoryStore）で起動する
+	Storage string `yaml:"storage" env:"STORAGE"`
+	// mysql / sqlite / postgres
+	Driver string `yaml:"driver" env:"DB_DRIVER"`
+	// 空の場合はドライバごとのデフォルト
+	URL         string   `yaml:"url" env:"DATABASE_URL"`
+	ReplicaURLs []string `yaml:"replica_urls" env:"DATABASE_REPLICA_URLS"`
+	// 読み取りを振り分けるレプリカの遅延の上限（0 で遅延を見ない）
+	ReplicaMaxLag time.Duration `yaml:"replica_max_lag" env:"DB_REPLICA_MAX_LAG"`
+
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+
+	// 起動時の接続確認のリトライ
+	ConnectDeadline       time.Duration `yaml:"connect_deadline" env:"DB_CONNECT_DEADLINE"`
+	ConnectBackoffInitial time.Duration `yaml:"connect_backoff_initial" env:"DB_CONNECT_BACKOFF_INITIAL"`
+	ConnectBackoffMax     time.Duration `yaml:"connect_backoff_max" env:"DB_CONNECT_BACKOFF_MAX"`
+
+	// MySQL の接続オプション（空・0 の場合は DATABASE_URL の値のまま）
+	Collation string `yaml:"collation" env:"DB_COLLATION"`
+	// true / false
+	InterpolateParams string        `yaml:"interpolate_params" env:"DB_INTERPOLATE_PARAMS"`
+	DialTimeout       time.Duration `yaml:"dial_timeout" env:"DB_DIAL_TIMEOUT"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"DB_READ_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"DB_WRITE_TIMEOUT"`
+	// true / false / skip-verify / preferred
+	TLS     string `yaml:"tls" env:"DB_TLS"`
+	TLSCA   string `yaml:"tls_ca" env:"DB_TLS_CA"`
+	TLSCert string `yaml:"tls_cert" env:"DB_TLS_CERT"`
+	TLSKey  string `yaml:"tls_key" env:"DB_TLS_KEY"`
+
+	// クエリの種類ごとのタイムアウト（0 で無効）
+	PointReadTimeout time.Duration `yaml:"point_read_timeout" env:"DB_TIMEOUT_POINT_READ"`
+	ListReadTimeout  time.Duration `yaml:"list_read_timeout" env:"DB_TIMEOUT_LIST_READ"`
+	PlanReadTimeout  time.Duration `yaml:"plan_read_timeout" env:"DB_TIMEOUT_PLAN_READ"`
+	WriteTimeoutQ    time.Duration `yaml:"write_query_timeout" env:"DB_TIMEOUT_WRITE"`
+
+	// 連続失敗でプライマリへの呼び出しを遮断する回数（0 で無効）と遮断時間
+	BreakerThreshold int           `yaml:"breaker_threshold" env:"DB_BREAKER_THRESHOLD"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env:"DB_BREAKER_COOLDOWN"`
+	// スロークエリとして記録する閾値（0 で無効）
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold" env:"DB_SLOW_QUERY_THRESHOLD"`
+	// 初回のクエリごとに EXPLAIN を記録する（MySQL のみ）
+	Explain      bool `yaml:"explain" env:"DB_EXPLAIN"`
+	QueryMetrics bool `yaml:"query_metrics" env:"DB_QUERY_METRICS"`
+	// readyz で許容するレプリカの遅延
+	ReadyMaxReplicaLag time.Duration `yaml:"ready_max_replica_lag" env:"READY_MAX_REPLICA_LAG"`
+}
+
+// 配送計画の計算の同時実行数と待ち行列
+type Planner struct {
+	Concurrency  int           `yaml:"concurrency" env:"ROBOT_PLAN_CONCURRENCY"`
+	QueueSize    int           `yaml:"queue_size" env:"ROBOT_PLAN_QUEUE_SIZE"`
+	QueueTimeout time.Duration `yaml:"queue_timeout" env:"ROBOT_PLAN_QUEUE_TIMEOUT"`
+	// Redis を使う場合の全サーバー合計の同時実行数（1 で排他）。0 の場合は Concurrency と同じ
+	ClusterConcurrency int `yaml:"cluster_concurrency" env:"ROBOT_PLAN_CLUSTER_CONCURRENCY"`
+	// 配送中のまま DeliveringLease を過ぎた注文を配送待ちへ戻す（ロボットが停止した場合に注文が計画の対象から外れ続けないようにする）
+	// 0 で無効（既定）。orders.updated_at が無いスキーマでも無効
+	// ロボットは配送中に注文を更新しないため、最も長い配送より十分長くすること（短いと配送中の注文を別のロボットに渡してしまう）
+	DeliveringLease time.Duration `yaml:"delivering_lease" env:"ROBOT_DELIVERING_LEASE"`
+	// 配送中のまま残った注文を探す間隔と、1回のトランザクションで戻す件数
+	ReapInterval  time.Duration `yaml:"reap_interval" env:"ROBOT_REAP_INTERVAL"`
+	ReapBatchSize int           `yaml:"reap_batch_size" env:"ROBOT_REAP_BATCH_SIZE"`
+}
+
+// TTL はいずれも 0 で無効
+// 商品一覧・総件数・ユーザーのキャッシュは書き込みのたびに破棄される（cache.Bus）ため、TTL は通知が届かなかった場合に古い値を返し続ける上限となる
+type Cache struct {
+	ProductListTTL    time.Duration `yaml:"product_list_ttl" env:"PRODUCT_CACHE_TTL"`
+	ProductListSize   int           `yaml:"product_list_size" env:"PRODUCT_CACHE_SIZE"`
+	NegativeSearchTTL time.Duration `yaml:"negative_search_ttl" env:"NEGATIVE_SEARCH_CACHE_TTL"`
+	UserTTL           time.Duration `yaml:"user_ttl" env:"USER_CACHE_TTL"`
+	UserNegativeTTL   time.Duration `yaml:"user_negative_ttl" env:"USER_NEGATIVE_CACHE_TTL"`
+	// セッションIDからユーザーIDを引いた結果を保持する期間
+	SessionTTL time.Duration `yaml:"session_ttl" env:"SESSION_CACHE_TTL"`
+	// 商品一覧（検索条件ごと）・注文履歴（ユーザーと検索条件ごと）の総件数を、ページに関係なく共有して保持する期間
+	CountTTL time.Duration `yaml:"count_ttl" env:"COUNT_CACHE_TTL"`
+	// CountTTL を過ぎた後や変更の後も、古い総件数を approximate として返しながら裏で再計算する期間（0 で常に待って数え直す）
+	CountStaleTTL time.Duration `yaml:"count_stale_ttl" env:"COUNT_CACHE_STALE_TTL"`
+	// カテゴリ一覧を保持する期間
+	CategoryTTL time.Duration `yaml:"category_ttl" env:"CATEGORY_CACHE_TTL"`
+	// 配送計画の候補（配送待ちの注文）を保持する期間。注文の作成・状態の変更で破棄される
+	ShippingOrdersTTL time.Duration `yaml:"shipping_orders_ttl" env:"SHIPPING_ORDERS_CACHE_TTL"`
+	// 商品一覧の ETag の元になるバージョン（商品の最終更新日時・件数とユーザーごとのお気に入り）を保持する期間
+	// 商品・お気に入りの変更で破棄される
+	ListingVersionTTL time.Duration `yaml:"listing_version_ttl" env:"LISTING_VERSION_CACHE_TTL"`
+	// 「一緒に注文されている商品」の再計算間隔
+	RelatedRefreshInterval time.Duration `yaml:"related_refresh_interval" env:"RELATED_REFRESH_INTERVAL"`
+	// 同じ条件の一覧取得の同時実行をまとめる
+	RequestCoalescing bool `yaml:"request_coalescing" env:"REQUEST_COALESCING"`
+	// 起動後に商品一覧の1ページ目・カテゴリ一覧・配送計画の候補を読み込んでおく
+	// 読み込み終わるまで（WarmupTimeout まで）/readyz は unavailable を返す
+	Warmup        bool          `yaml:"warmup" env:"CACHE_WARMUP"`
+	WarmupTimeout time.Duration `yaml:"warmup_timeout" env:"CACHE_WARMUP_TIMEOUT"`
+}
+
+type RateLimit struct {
+	Enabled bool `yaml:"enabled" env:"RATE_LIMIT"`
+	// memory / redis（redis の場合は Redis.URL が必要）
+	Backend string `yaml:"backend" env:"RATE_LIMIT_BACKEND"`
+	// 回数/期間（10/1m など）
+	Login string `yaml:"login" env:"RATE_LIMIT_LOGIN"`
+	Write string `yaml:"write" env:"RATE_LIMIT_WRITE"`
+	Read  string `yaml:"read" env:"RATE_LIMIT_READ"`
+	// X-Forwarded-For などからクライアントIPを取る
+	TrustProxy bool `yaml:"trust_proxy" env:"RATE_LIMIT_TRUST_PROXY"`
+}
+
+// 複数台のサーバーで共有する Redis（coord を参照）。URL が空の場合は使わない
+type Redis struct {
+	URL string `yaml:"url" env:"REDIS_URL"`
+	// キーとチャンネル名の接頭辞（同じ Redis を複数の環境で共有する場合に分ける）
+	KeyPrefix string `yaml:"key_prefix" env:"REDIS_KEY_PREFIX"`
+}
+
+type Telemetry struct {
+	// true / false。空の場合はエクスポート先が設定されていれば有効
+	TraceEnabled string `yaml:"trace_enabled" env:"TRACE_ENABLED"`
+	// 0〜1。指定した場合は Sampler より優先し、親スパンの判定に従う比率サンプリングにする
+	SampleRatio string `yaml:"sample_ratio" env:"TRACE_SAMPLE_RATIO"`
+	// always_on / always_off / traceidratio / parentbased_always_on / parentbased_always_off / parentbased_traceidratio
+	// 空の場合は parentbased_traceidratio
+	Sampler string `yaml:"sampler" env:"OTEL_TRACES_SAMPLER"`
+	// traceidratio の比率（0〜1）。空の場合は 0.01
+	SamplerArg string `yaml:"sampler_arg" env:"OTEL_TRACES_SAMPLER_ARG"`
+	// otlp / jaeger / stdout / none。空の場合は設定されているエンドポイントから選ぶ
+	Exporter    string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER"`
+	ServiceName string `yaml:"service_name" env:"SERVICE_NAME"`
+	Environment string `yaml:"environment" env:"ENV"`
+	// Jaeger の collector の URL（jaeger エクスポーター用）
+	JaegerEndpoint string `yaml:"jaeger_endpoint" env:"JAEGER_ENDPOINT"`
+	// host:port または URL。URL の場合は https なら TLS で接続する
+	OTLPEndpoint string `yaml:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	// grpc / http/protobuf
+	OTLPProtocol string `yaml:"otlp_protocol" env:"OTEL_EXPORTER_OTLP_PROTOCOL"`
+	// otlp / prometheus / none。空の場合はメトリクスを送らない
+	// prometheus の場合は /debug/metrics で公開する（DEBUG_ADDR か管理者の /debug）
+	MetricsExporter string `yaml:"metrics_exporter" env:"OTEL_METRICS_EXPORTER"`
+	// otlp で送る間隔
+	MetricsInterval time.Duration `yaml:"metrics_interval" env:"METRICS_EXPORT_INTERVAL"`
+	// エクスポート前に値を隠すスパンの属性（カンマ区切り）。トレースを外部に共有する場合にユーザー名などを出さないため
+	ScrubAttributes []string `yaml:"scrub_attributes" env:"TRACE_SCRUB_ATTRIBUTES"`
+	// hash / redact。hash は同じ値が同じ文字列になるため、値を出さずに同一ユーザーのスパンをまとめられる
+	ScrubMode string `yaml:"scrub_mode" env:"TRACE_SCRUB_MODE"`
+	// hash に使う鍵。空の場合は起動ごとに生成する（プロセスをまたいで同じ値にしたい場合に指定する）
+	ScrubKey string `yaml:"scrub_key" env:"TRACE_SCRUB_KEY"`
+}
+
+type Log struct {
+	// json / text
+	Format string `yaml:"format" env:"LOG_FORMAT"`
+	// debug / info / warn / error
+	Level     string `yaml:"level" env:"LOG_LEVEL"`
+	AccessLog bool   `yaml:"access_log" env:"ACCESS_LOG"`
+}
+
+type Outbox struct {
+	// 空 / log / webhook / kafka / nats。空の場合は配信しない
+	Sink         string        `yaml:"sink" env:"OUTBOX_SINK"`
+	WebhookURL   string        `yaml:"webhook_url" env:"OUTBOX_WEBHOOK_URL"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"OUTBOX_POLL_INTERVAL"`
+	BatchSize    int           `yaml:"batch_size" env:"OUTBOX_BATCH_SIZE"`
+	Retention    time.Duration `yaml:"retention" env:"OUTBOX_RETENTION"`
+}
+
+// メッセージブローカーへの配信（OUTBOX_SINK が kafka / nats の場合）
+type Messaging struct {
+	KafkaBrokers []string `yaml:"kafka_brokers" env:"KAFKA_BROKERS"`
+	NATSURL      string   `yaml:"nats_url" env:"NATS_URL"`
+	// NATS で JetStream に送り、ストリームへの保存を確認する（イベントIDで重複を排除させる）
+	NATSJetStream bool `yaml:"nats_jetstream" env:"NATS_JETSTREAM"`
+	// 送信先のトピック（NATS ではサブジェクト）はこの接頭辞にイベント種別を続けたもの（backend.order.created など）
+	TopicPrefix string `yaml:"topic_prefix" env:"MESSAGING_TOPIC_PREFIX"`
+	// 1件の送信でブローカーの応答を待つ時間
+	PublishTimeout time.Duration `yaml:"publish_timeout" env:"MESSAGING_PUBLISH_TIMEOUT"`
+}
+
+// 配送完了などのユーザーへの通知（送信先はユーザーごとの通知設定で選ぶ）
+type Notify struct {
+	// 有効にするチャネル（log / webhook / email）。空の場合は通知しない
+	Channels []string `yaml:"channels" env:"NOTIFY_CHANNELS"`
+	// webhook チャネルの送信先（ユーザーIDと宛先を含めて送り、プッシュ通知などは送信先が行う）
+	WebhookURL string `yaml:"webhook_url" env:"NOTIFY_WEBHOOK_URL"`
+	// email チャネルの SMTP サーバー（host:port）と差出人。SMTPUsername が空の場合は認証しない
+	SMTPAddr     string `yaml:"smtp_addr" env:"NOTIFY_SMTP_ADDR"`
+	SMTPUsername string `yaml:"smtp_username" env:"NOTIFY_SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" env:"NOTIFY_SMTP_PASSWORD"`
+	SMTPFrom     string `yaml:"smtp_from" env:"NOTIFY_SMTP_FROM"`
+	// 送信待ちの通知の上限（超えた分は送らずにログに残す）
+	QueueSize int `yaml:"queue_size" env:"NOTIFY_QUEUE_SIZE"`
+}
+
+type Search struct {
+	// 空 / mysql / meilisearch。空の場合はリポジトリの LIKE 検索
+	Backend           string `yaml:"backend" env:"SEARCH_BACKEND"`
+	MeilisearchURL    string `yaml:"meilisearch_url" env:"MEILISEARCH_URL"`
+	MeilisearchIndex  string `yaml:"meilisearch_index" env:"MEILISEARCH_INDEX"`
+	MeilisearchAPIKey string `yaml:"meilisearch_api_key" env:"MEILISEARCH_API_KEY"`
+}
+
+type Storage struct {
+	ImageDir      string `yaml:"image_dir" env:"IMAGE_DIR"`
+	ImageCacheDir string `yaml:"image_cache_dir" env:"IMAGE_CACHE_DIR"`
+}
+
+type Stock struct {
+	LowStockThreshold  int    `yaml:"low_stock_threshold" env:"LOW_STOCK_THRESHOLD"`
+	LowStockWebhookURL string `yaml:"low_stock_webhook_url" env:"LOW_STOCK_WEBHOOK_URL"`
+	// Webhook を送るワーカーの数と、送信待ちの上限（超えた通知は破棄する）
+	WebhookWorkers   int `yaml:"webhook_workers" env:"LOW_STOCK_WEBHOOK_WORKERS"`
+	WebhookQueueSize int `yaml:"webhook_queue_size" env:"LOW_STOCK_WEBHOOK_QUEUE_SIZE"`
+}
+
+// 段階的に有効にする機能のフラグ（featureflag パッケージを参照）
+type Features struct {
+	// フラグ名ごとの有効な割合と対象ユーザー
+	Flags map[string]FeatureRule `yaml:"flags"`
+	// name=percent のカンマ区切り（cursor_pagination=100,product_list_cache=25 など）。Flags より優先する
+	Overrides string `yaml:"-" env:"FEATURE_FLAGS"`
+	// 実行中に変更を検知して読み直すフラグのファイル（Flags と同じ形式の YAML）
+	File           string        `yaml:"file" env:"FEATURE_FLAGS_FILE"`
+	ReloadInterval time.Duration `yaml:"reload_interval" env:"FEATURE_FLAGS_RELOAD_INTERVAL"`
+}
+
+// 管理ダッシュボード向けの WebSocket 配信（realtime パッケージを参照）
+type Realtime struct {
+	// 同時に接続できるダッシュボードの上限
+	MaxConnections int `yaml:"max_connections" env:"REALTIME_MAX_CONNECTIONS"`
+	// 接続ごとに送信待ちにできるメッセージ数。溢れた接続は切断する
+	SendBuffer   int           `yaml:"send_buffer" env:"REALTIME_SEND_BUFFER"`
+	PingInterval time.Duration `yaml:"ping_interval" env:"REALTIME_PING_INTERVAL"`
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"REALTIME_WRITE_TIMEOUT"`
+	// 接続中のセッションが失効していないか確認する間隔
+	SessionCheckInterval time.Duration `yaml:"session_check_interval" env:"REALTIME_SESSION_CHECK_INTERVAL"`
+	// 接続を許可する Origin（空の場合は Host と同じ Origin のみ）
+	AllowedOrigins []string `yaml:"allowed_origins" env:"REALTIME_ALLOWED_ORIGINS"`
+}
+
+// 500 とパニックの送信先。環境名は Telemetry.Environment を使う
+type ErrorReporting struct {
+	// https://<key>@<host>/<project>。空の場合は送信しない
+	DSN string `yaml:"dsn" env:"SENTRY_DSN"`
+	// 空の場合はビルド情報の VCS リビジョン
+	Release string `yaml:"release" env:"RELEASE"`
+	// イベントに付けるトレースへのリンク。{trace_id} をトレースIDに置き換える（例: http://localhost:16686/trace/{trace_id}）
+	TraceURL string `yaml:"trace_url" env:"TRACE_URL_TEMPLATE"`
+	// 送信待ちのイベントの上限。溢れたイベントは捨てる
+	QueueSize int `yaml:"queue_size" env:"ERROR_REPORT_QUEUE_SIZE"`
+}
+
+// Idempotency-Key による再送の扱い（middleware.IdempotencyMiddleware を参照）
+type Idempotency struct {
+	// 処理結果を保存しておく期間
+	TTL time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL"`
+	// 処理中のまま（プロセスの停止などで）残ったキーを、別のリクエストが引き継げるまでの時間
+	LockTimeout time.Duration `yaml:"lock_timeout" env:"IDEMPOTENCY_LOCK_TIMEOUT"`
+	// 期限切れのキーを削除する間隔
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env:"IDEMPOTENCY_CLEANUP_INTERVAL"`
+	// 保存するレスポンスの上限。超えたレスポンスは保存せず、再送は再実行になる
+	MaxResponseBytes int `yaml:"max_response_bytes" env:"IDEMPOTENCY_MAX_RESPONSE_BYTES"`
+}
+
+// 注文の時間・日ごとの集計（order_summaries）を更新するジョブ
+type Stats struct {
+	// 集計を更新する間隔。0 で無効（ダッシュボードは orders を直接集計する）
+	SummaryInterval time.Duration `yaml:"summary_interval" env:"ORDER_SUMMARY_INTERVAL"`
+	// 毎回集計し直す直近の期間。これより前の時間は確定したものとして集計し直さない
+	// 配送完了の記録（arrived_at）がこれより遅れて書き込まれる場合は長くすること
+	SummaryLookback time.Duration `yaml:"summary_lookback" env:"ORDER_SUMMARY_LOOKBACK"`
+}
+
+// 非同期の注文作成（?async=true）。ジョブの状態は受け付けたサーバーのメモリに保持する
+type OrderJobs struct {
+	// ジョブを処理するワーカーの数と、処理待ちのジョブの上限（超えた場合は 503）
+	Workers   int `yaml:"workers" env:"ORDER_JOB_WORKERS"`
+	QueueSize int `yaml:"queue_size" env:"ORDER_JOB_QUEUE_SIZE"`
+	// 1回のトランザクションで作成する注文数
+	ChunkSize int `yaml:"chunk_size" env:"ORDER_JOB_CHUNK_SIZE"`
+	// 終了したジョブの状態を保持する期間
+	Retention time.Duration `yaml:"retention" env:"ORDER_JOB_RETENTION"`
+}
+
+// 日次の運用レポート（前日分の注文・配送計画・売れ筋の商品を CSV と JSON で書き出す）
+type Reports struct {
+	// レポートを書き出すディレクトリ。空の場合は作成しない
+	Dir string `yaml:"dir" env:"REPORT_DIR"`
+	// 前日分のレポートを作成する時刻（UTC の時、0〜23）
+	Hour int `yaml:"hour" env:"REPORT_HOUR"`
+	// レポートに含める売れ筋の商品の数
+	TopProducts int `yaml:"top_products" env:"REPORT_TOP_PRODUCTS"`
+}
+
+type FeatureRule struct {
+	// 0〜100
+	Percent int `yaml:"percent"`
+	// 割合に関係なく有効にするユーザーID
+	Users []int `yaml:"users"`
+}
+
+// Default は設定ファイル・環境変数が無い場合の値
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Port:                   "8080",
+			ShutdownGracePeriod:    15 * time.Second,
+			BackgroundDrainTimeout: 10 * time.Second,
+			RobotAPIKey:            "test-robot-key",
+			APIDocs:                true,
+			DebugEndpoints:         true,
+			ReadOnlyRetryAfter:     30 * time.Second,
+			Compress:               true,
+			CompressMinBytes:       1024,
+		},
+		TLS: TLS{AutocertCacheDir: "autocert-cache"},
+		Timeouts: Timeouts{
+			List:    500 * time.Millisecond,
+			Write:   2 * time.Second,
+			Robot:   5 * time.Second,
+			Default: time.Second,
+			Export:  5 * time.Minute,
+		},
+		DB: DB{
+			Driver:                "mysql",
+			MaxOpenConns:          100,
+			MaxIdleConns:          20,
+			ConnMaxLifetime:       5 * time.Minute,
+			ConnMaxIdleTime:       time.Minute,
+			ConnectDeadline:       60 * time.Second,
+			ConnectBackoffInitial: 500 * time.Millisecond,
+			ConnectBackoffMax:     10 * time.Second,
+			PointReadTimeout:      200 * time.Millisecond,
+			ListReadTimeout:       time.Second,
+			PlanReadTimeout:       2 * time.Second,
+			WriteTimeoutQ:         time.Second,
+			BreakerThreshold:      20,
+			BreakerCooldown:       5 * time.Second,
+			SlowQueryThreshold:    100 * time.Millisecond,
+			QueryMetrics:          true,
+			ReadyMaxReplicaLag:    10 * time.Second,
+			ReplicaMaxLag:         2 * time.Second,
+		},
+		Planner: Planner{
+			Concurrency:   runtime.NumCPU(),
+			QueueSize:     64,
+			QueueTimeout:  2 * time.Second,
+			ReapInterval:  time.Minute,
+			ReapBatchSize: 1000,
+		},
+		Cache: Cache{
+			ProductListTTL:         30 * time.Second,
+			ProductListSize:        256,
+			NegativeSearchTTL:      5 * time.Second,
+			UserTTL:                time.Minute,
+			UserNegativeTTL:        5 * time.Second,
+			SessionTTL:             10 * time.Second,
+			CountTTL:               30 * time.Second,
+			CountStaleTTL:          5 * time.Minute,
+			CategoryTTL:            time.Minute,
+			ShippingOrdersTTL:      2 * time.Second,
+			ListingVersionTTL:      time.Minute,
+			RelatedRefreshInterval: 10 * time.Minute,
+			RequestCoalescing:      true,
+			Warmup:                 true,
+			WarmupTimeout:          10 * time.Second,
+		},
+		RateLimit: RateLimit{
+			Backend: "memory",
+			Login:   "10/1m",
+			Write:   "30/1m",
+			Read:    "600/1m",
+		},
+		Redis: Redis{KeyPrefix: "backend:"},
+		Telemetry: Telemetry{
+			ServiceName:     "backend",
+			Environment:     "local",
+			OTLPProtocol:    "http/protobuf",
+			MetricsInterval: time.Minute,
+			ScrubAttributes: []string{"user.name", "user.id", "enduser.id", "session.id",
+				"http.request.header.cookie", "http.request.header.authorization", "http.request.header.x-api-key"},
+			ScrubMode: "hash",
+		},
+		Log: Log{Format: "json", Level: "info", AccessLog: true},
+		Outbox: Outbox{
+			PollInterval: time.Second,
+			BatchSize:    100,
+			Retention:    24 * time.Hour,
+		},
+		Messaging: Messaging{
+			TopicPrefix:    "backend.",
+			PublishTimeout: 5 * time.Second,
+		},
+		Notify: Notify{QueueSize: 1000},
+		Search: Search{
+			MeilisearchURL:   "http://meilisearch:7700",
+			MeilisearchIndex: "products",
+		},
+		Storage: Storage{
+			ImageDir:      "/app/images",
+			ImageCacheDir: filepath.Join(os.TempDir(), "image-variants"),
+		},
+		Stock: Stock{LowStockThreshold: 10, WebhookWorkers: 2, WebhookQueueSize: 100},
+		Realtime: Realtime{
+			MaxConnections:       100,
+			SendBuffer:           64,
+			PingInterval:         30 * time.Second,
+			WriteTimeout:         10 * time.Second,
+			SessionCheckInterval: time.Minute,
+		},
+		ErrorReporting: ErrorReporting{QueueSize: 100},
+		Idempotency: Idempotency{
+			TTL:              24 * time.Hour,
+			LockTimeout:      time.Minute,
+			CleanupInterval:  10 * time.Minute,
+			MaxResponseBytes: 1 << 20,
+		},
+		Stats: Stats{
+			SummaryInterval: time.Minute,
+			SummaryLookback: 2 * time.Hour,
+		},
+		OrderJobs: OrderJobs{
+			Workers:   2,
+			QueueSize: 100,
+			ChunkSize: 500,
+			Retention: time.Hour,
+		},
+		Reports: Reports{Hour: 1, TopProducts: 10},
+	}
+}
+
+// Memory はデータベースを使わず、プロセス内のストアで起動するか
+func (c DB) Memory() bool {
+	return c.Storage == "memory"
+}
+
+// EffectiveDriver は接続先の種類。STORAGE=memory の場合はデータベースを使わないため memory になる
+func (c DB) EffectiveDriver() string {
+	if c.Memory() {
+		return "memory"
+	}
+	return c.Driver
+}
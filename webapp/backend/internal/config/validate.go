@@ -0,0 +1,279 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validate は値の範囲と組み合わせを検証する。問題があればまとめて返す
+// レート制限の「回数/期間」や DB の DSN など、利用するパッケージでしか解釈できない値はそちらで検証する
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]interface{}{ErrInvalid}, args...)...))
+	}
+	nonNegative := func(name string, d time.Duration) {
+		if d < 0 {
+			add("%s must not be negative", name)
+		}
+	}
+	positive := func(name string, n int) {
+		if n <= 0 {
+			add("%s must be positive", name)
+		}
+	}
+	oneOf := func(name, v string, allowed ...string) {
+		for _, a := range allowed {
+			if strings.EqualFold(v, a) {
+				return
+			}
+		}
+		add("%s=%q must be one of %s", name, v, strings.Join(allowed, ", "))
+	}
+
+	if _, err := strconv.ParseUint(c.Server.Port, 10, 16); err != nil {
+		add("PORT=%q is not a valid port", c.Server.Port)
+	}
+	nonNegative("SHUTDOWN_GRACE_PERIOD", c.Server.ShutdownGracePeriod)
+	nonNegative("BACKGROUND_DRAIN_TIMEOUT", c.Server.BackgroundDrainTimeout)
+	if c.Server.RobotAPIKey == "" {
+		add("ROBOT_API_KEY must not be empty")
+	}
+	if c.Server.ReadOnlyRetryAfter <= 0 {
+		add("READ_ONLY_RETRY_AFTER must be positive")
+	}
+	if c.Server.CompressMinBytes < 0 {
+		add("COMPRESS_MIN_BYTES must not be negative")
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		add("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if c.TLS.CertFile != "" && len(c.TLS.AutocertDomains) > 0 {
+		add("TLS_CERT_FILE and TLS_AUTOCERT_DOMAINS are mutually exclusive")
+	}
+	if c.TLS.RedirectPort != "" && c.TLS.CertFile == "" && len(c.TLS.AutocertDomains) == 0 {
+		add("TLS_REDIRECT_PORT requires TLS_CERT_FILE or TLS_AUTOCERT_DOMAINS")
+	}
+	if len(c.TLS.AutocertDomains) > 0 && c.TLS.AutocertCacheDir == "" {
+		add("TLS_AUTOCERT_CACHE_DIR must not be empty")
+	}
+
+	nonNegative("ROUTE_TIMEOUT_LIST", c.Timeouts.List)
+	nonNegative("ROUTE_TIMEOUT_WRITE", c.Timeouts.Write)
+	nonNegative("ROUTE_TIMEOUT_ROBOT", c.Timeouts.Robot)
+	nonNegative("ROUTE_TIMEOUT_DEFAULT", c.Timeouts.Default)
+	nonNegative("ROUTE_TIMEOUT_EXPORT", c.Timeouts.Export)
+
+	if !c.DB.Memory() {
+		oneOf("DB_DRIVER", c.DB.Driver, "mysql", "sqlite", "postgres")
+	}
+	positive("DB_MAX_OPEN_CONNS", c.DB.MaxOpenConns)
+	positive("DB_MAX_IDLE_CONNS", c.DB.MaxIdleConns)
+	for _, d := range []struct {
+		name string
+		v    time.Duration
+	}{
+		{"DB_CONN_MAX_LIFETIME", c.DB.ConnMaxLifetime},
+		{"DB_CONN_MAX_IDLE_TIME", c.DB.ConnMaxIdleTime},
+		{"DB_CONNECT_DEADLINE", c.DB.ConnectDeadline},
+		{"DB_CONNECT_BACKOFF_INITIAL", c.DB.ConnectBackoffInitial},
+		{"DB_CONNECT_BACKOFF_MAX", c.DB.ConnectBackoffMax},
+	} {
+		if d.v <= 0 {
+			add("%s must be positive", d.name)
+		}
+	}
+	if c.DB.InterpolateParams != "" {
+		oneOf("DB_INTERPOLATE_PARAMS", c.DB.InterpolateParams, "true", "false")
+	}
+	if c.DB.TLS != "" {
+		oneOf("DB_TLS", c.DB.TLS, "true", "false", "skip-verify", "preferred")
+	}
+	nonNegative("DB_DIAL_TIMEOUT", c.DB.DialTimeout)
+	nonNegative("DB_READ_TIMEOUT", c.DB.ReadTimeout)
+	nonNegative("DB_WRITE_TIMEOUT", c.DB.WriteTimeout)
+	nonNegative("DB_TIMEOUT_POINT_READ", c.DB.PointReadTimeout)
+	nonNegative("DB_TIMEOUT_LIST_READ", c.DB.ListReadTimeout)
+	nonNegative("DB_TIMEOUT_PLAN_READ", c.DB.PlanReadTimeout)
+	nonNegative("DB_TIMEOUT_WRITE", c.DB.WriteTimeoutQ)
+	if c.DB.BreakerThreshold < 0 {
+		add("DB_BREAKER_THRESHOLD must not be negative")
+	}
+	if c.DB.BreakerThreshold > 0 && c.DB.BreakerCooldown <= 0 {
+		add("DB_BREAKER_COOLDOWN must be positive")
+	}
+	nonNegative("DB_REPLICA_MAX_LAG", c.DB.ReplicaMaxLag)
+	if c.DB.ReadyMaxReplicaLag <= 0 {
+		add("READY_MAX_REPLICA_LAG must be positive")
+	}
+
+	positive("ROBOT_PLAN_CONCURRENCY", c.Planner.Concurrency)
+	positive("ROBOT_PLAN_QUEUE_SIZE", c.Planner.QueueSize)
+	if c.Planner.QueueTimeout <= 0 {
+		add("ROBOT_PLAN_QUEUE_TIMEOUT must be positive")
+	}
+	nonNegative("ROBOT_DELIVERING_LEASE", c.Planner.DeliveringLease)
+	if c.Planner.DeliveringLease > 0 {
+		if c.Planner.ReapInterval <= 0 {
+			add("ROBOT_REAP_INTERVAL must be positive")
+		}
+		positive("ROBOT_REAP_BATCH_SIZE", c.Planner.ReapBatchSize)
+	}
+	if c.Planner.ClusterConcurrency < 0 {
+		add("ROBOT_PLAN_CLUSTER_CONCURRENCY must not be negative")
+	}
+
+	nonNegative("PRODUCT_CACHE_TTL", c.Cache.ProductListTTL)
+	positive("PRODUCT_CACHE_SIZE", c.Cache.ProductListSize)
+	nonNegative("NEGATIVE_SEARCH_CACHE_TTL", c.Cache.NegativeSearchTTL)
+	nonNegative("USER_CACHE_TTL", c.Cache.UserTTL)
+	nonNegative("USER_NEGATIVE_CACHE_TTL", c.Cache.UserNegativeTTL)
+	nonNegative("SESSION_CACHE_TTL", c.Cache.SessionTTL)
+	nonNegative("COUNT_CACHE_TTL", c.Cache.CountTTL)
+	nonNegative("COUNT_CACHE_STALE_TTL", c.Cache.CountStaleTTL)
+	nonNegative("CATEGORY_CACHE_TTL", c.Cache.CategoryTTL)
+	nonNegative("SHIPPING_ORDERS_CACHE_TTL", c.Cache.ShippingOrdersTTL)
+	nonNegative("LISTING_VERSION_CACHE_TTL", c.Cache.ListingVersionTTL)
+	nonNegative("RELATED_REFRESH_INTERVAL", c.Cache.RelatedRefreshInterval)
+	if c.Cache.Warmup && c.Cache.WarmupTimeout <= 0 {
+		add("CACHE_WARMUP_TIMEOUT must be positive")
+	}
+
+	oneOf("RATE_LIMIT_BACKEND", c.RateLimit.Backend, "memory", "redis")
+	if c.RateLimit.Enabled && c.RateLimit.Backend == "redis" && c.Redis.URL == "" {
+		add("RATE_LIMIT_BACKEND=redis requires REDIS_URL")
+	}
+
+	if c.Telemetry.TraceEnabled != "" {
+		oneOf("TRACE_ENABLED", c.Telemetry.TraceEnabled, "true", "false")
+	}
+	if c.Telemetry.SampleRatio != "" {
+		if v, err := strconv.ParseFloat(c.Telemetry.SampleRatio, 64); err != nil || v < 0 || v > 1 {
+			add("TRACE_SAMPLE_RATIO=%q must be a number between 0 and 1", c.Telemetry.SampleRatio)
+		}
+	}
+	if c.Telemetry.Sampler != "" {
+		oneOf("OTEL_TRACES_SAMPLER", c.Telemetry.Sampler, "always_on", "always_off", "traceidratio",
+			"parentbased_always_on", "parentbased_always_off", "parentbased_traceidratio")
+	}
+	if c.Telemetry.SamplerArg != "" {
+		if v, err := strconv.ParseFloat(c.Telemetry.SamplerArg, 64); err != nil || v < 0 || v > 1 {
+			add("OTEL_TRACES_SAMPLER_ARG=%q must be a number between 0 and 1", c.Telemetry.SamplerArg)
+		}
+	}
+	if c.Telemetry.Exporter != "" {
+		oneOf("OTEL_TRACES_EXPORTER", c.Telemetry.Exporter, "otlp", "jaeger", "stdout", "none")
+	}
+	oneOf("OTEL_EXPORTER_OTLP_PROTOCOL", c.Telemetry.OTLPProtocol, "grpc", "http/protobuf")
+	if c.Telemetry.MetricsExporter != "" {
+		oneOf("OTEL_METRICS_EXPORTER", c.Telemetry.MetricsExporter, "otlp", "prometheus", "none")
+	}
+	oneOf("TRACE_SCRUB_MODE", c.Telemetry.ScrubMode, "hash", "redact")
+	if c.Telemetry.MetricsInterval <= 0 {
+		add("METRICS_EXPORT_INTERVAL must be positive")
+	}
+
+	oneOf("LOG_FORMAT", c.Log.Format, "json", "text")
+	oneOf("LOG_LEVEL", c.Log.Level, "debug", "info", "warn", "warning", "error")
+
+	if c.Outbox.Sink != "" {
+		oneOf("OUTBOX_SINK", c.Outbox.Sink, "log", "webhook", "kafka", "nats")
+	}
+	if c.Outbox.Sink == "webhook" && c.Outbox.WebhookURL == "" {
+		add("OUTBOX_WEBHOOK_URL is required for the webhook sink")
+	}
+	if c.Outbox.Sink == "kafka" && len(c.Messaging.KafkaBrokers) == 0 {
+		add("KAFKA_BROKERS is required for the kafka sink")
+	}
+	if c.Outbox.Sink == "nats" && c.Messaging.NATSURL == "" {
+		add("NATS_URL is required for the nats sink")
+	}
+	if c.Messaging.PublishTimeout <= 0 {
+		add("MESSAGING_PUBLISH_TIMEOUT must be positive")
+	}
+
+	for _, ch := range c.Notify.Channels {
+		oneOf("NOTIFY_CHANNELS", ch, "log", "webhook", "email")
+		if ch == "webhook" && c.Notify.WebhookURL == "" {
+			add("NOTIFY_WEBHOOK_URL is required for the webhook channel")
+		}
+		if ch == "email" && (c.Notify.SMTPAddr == "" || c.Notify.SMTPFrom == "") {
+			add("NOTIFY_SMTP_ADDR and NOTIFY_SMTP_FROM are required for the email channel")
+		}
+	}
+	positive("NOTIFY_QUEUE_SIZE", c.Notify.QueueSize)
+	if c.Outbox.PollInterval <= 0 {
+		add("OUTBOX_POLL_INTERVAL must be positive")
+	}
+	positive("OUTBOX_BATCH_SIZE", c.Outbox.BatchSize)
+	if c.Outbox.Retention <= 0 {
+		add("OUTBOX_RETENTION must be positive")
+	}
+
+	if c.Search.Backend != "" {
+		oneOf("SEARCH_BACKEND", c.Search.Backend, "mysql", "meilisearch")
+	}
+
+	if c.Stock.LowStockThreshold < 0 {
+		add("LOW_STOCK_THRESHOLD must not be negative")
+	}
+
+	// フラグ名と Overrides の形式は featureflag で検証する
+	for name, r := range c.Features.Flags {
+		if r.Percent < 0 || r.Percent > 100 {
+			add("features.flags.%s.percent must be between 0 and 100", name)
+		}
+	}
+	nonNegative("FEATURE_FLAGS_RELOAD_INTERVAL", c.Features.ReloadInterval)
+
+	positive("REALTIME_MAX_CONNECTIONS", c.Realtime.MaxConnections)
+	positive("REALTIME_SEND_BUFFER", c.Realtime.SendBuffer)
+	if c.Realtime.PingInterval <= 0 {
+		add("REALTIME_PING_INTERVAL must be positive")
+	}
+	if c.Realtime.WriteTimeout <= 0 {
+		add("REALTIME_WRITE_TIMEOUT must be positive")
+	}
+	if c.Realtime.SessionCheckInterval <= 0 {
+		add("REALTIME_SESSION_CHECK_INTERVAL must be positive")
+	}
+
+	if c.Idempotency.TTL <= 0 {
+		add("IDEMPOTENCY_TTL must be positive")
+	}
+	if c.Idempotency.LockTimeout <= 0 {
+		add("IDEMPOTENCY_LOCK_TIMEOUT must be positive")
+	}
+	if c.Idempotency.CleanupInterval <= 0 {
+		add("IDEMPOTENCY_CLEANUP_INTERVAL must be positive")
+	}
+	positive("IDEMPOTENCY_MAX_RESPONSE_BYTES", c.Idempotency.MaxResponseBytes)
+
+	nonNegative("ORDER_SUMMARY_INTERVAL", c.Stats.SummaryInterval)
+	if c.Stats.SummaryInterval > 0 && c.Stats.SummaryLookback < time.Hour {
+		add("ORDER_SUMMARY_LOOKBACK must be at least 1h")
+	}
+
+	positive("ORDER_JOB_WORKERS", c.OrderJobs.Workers)
+	positive("ORDER_JOB_QUEUE_SIZE", c.OrderJobs.QueueSize)
+	positive("ORDER_JOB_CHUNK_SIZE", c.OrderJobs.ChunkSize)
+	if c.OrderJobs.Retention <= 0 {
+		add("ORDER_JOB_RETENTION must be positive")
+	}
+
+	if c.Reports.Hour < 0 || c.Reports.Hour > 23 {
+		add("REPORT_HOUR must be between 0 and 23")
+	}
+	positive("REPORT_TOP_PRODUCTS", c.Reports.TopProducts)
+
+	positive("LOW_STOCK_WEBHOOK_WORKERS", c.Stock.WebhookWorkers)
+	positive("LOW_STOCK_WEBHOOK_QUEUE_SIZE", c.Stock.WebhookQueueSize)
+
+	positive("ERROR_REPORT_QUEUE_SIZE", c.ErrorReporting.QueueSize)
+
+	return errors.Join(errs...)
+}
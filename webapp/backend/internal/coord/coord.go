@@ -0,0 +1,50 @@
+// Package coord は複数台のサーバーで状態を共有するための Redis の接続と、その上の調整の仕組みを提供する
+//
+// Redis を使うのは REDIS_URL を指定した場合のみ。指定しない場合、各機能はプロセス内で完結する（1台構成の既定）
+//   - セッションの保存（repository.Store.UseRedis）
+//   - レート制限のバケットの共有（RATE_LIMIT_BACKEND=redis）
+//   - キャッシュの無効化の通知（Invalidator）
+//   - 配送計画の計算枠（Semaphore）
+package coord
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"backend/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Client は接頭辞付きのキーで Redis を使う
+type Client struct {
+	redis.UniversalClient
+	prefix string
+}
+
+// NewClient は cfg.URL に接続する。URL が空の場合は nil を返す
+// 起動時に接続できなくても失敗にはしない（各機能は Redis の障害時に縮退して動く）
+func NewClient(cfg config.Redis) (*Client, error) {
+	if cfg.URL == "" {
+		return nil, nil
+	}
+	opts, err := redis.ParseURL(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+	c := &Client{UniversalClient: redis.NewClient(opts), prefix: cfg.KeyPrefix}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := c.Ping(ctx).Err(); err != nil {
+		slog.Warn("coord: redis is not reachable at startup", "addr", opts.Addr, "err", err)
+	}
+	return c, nil
+}
+
+// Key は name に接頭辞を付けたキー
+func (c *Client) Key(name string) string {
+	return c.prefix + name
+}
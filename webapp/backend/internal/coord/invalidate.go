@@ -0,0 +1,100 @@
+package coord
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// 購読が切れた場合に再接続するまでの待ち時間
+const resubscribeDelay = time.Second
+
+// Invalidator はキャッシュの無効化をほかのサーバーへ通知する（Redis の pub/sub）
+// 通知は届かないことがある（購読の再接続中など）ため、キャッシュの TTL を古い値を返し続ける上限として設定しておくこと
+type Invalidator struct {
+	client *Client
+	// 自分が送った通知を受け取っても処理しないよう、サーバーごとに異なる値を付ける
+	origin string
+
+	mu       sync.RWMutex
+	handlers map[string][]func(key string)
+}
+
+type invalidation struct {
+	Origin string `json:"origin"`
+	Topic  string `json:"topic"`
+	Key    string `json:"key"`
+}
+
+func NewInvalidator(client *Client) *Invalidator {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return &Invalidator{
+		client:   client,
+		origin:   hex.EncodeToString(b),
+		handlers: make(map[string][]func(key string)),
+	}
+}
+
+func (i *Invalidator) channel() string {
+	return i.client.Key("invalidate")
+}
+
+// Publish は topic の key が変わったことをほかのサーバーへ通知する（自分のキャッシュは呼び出し側で破棄すること）
+func (i *Invalidator) Publish(ctx context.Context, topic, key string) {
+	msg, err := json.Marshal(invalidation{Origin: i.origin, Topic: topic, Key: key})
+	if err != nil {
+		return
+	}
+	if err := i.client.Publish(context.WithoutCancel(ctx), i.channel(), msg).Err(); err != nil {
+		slog.WarnContext(ctx, "coord: failed to publish invalidation", "topic", topic, "err", err)
+	}
+}
+
+// Subscribe はほかのサーバーから topic の通知を受け取ったときに呼ぶ fn を登録する（Run の前に呼ぶこと）
+func (i *Invalidator) Subscribe(topic string, fn func(key string)) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.handlers[topic] = append(i.handlers[topic], fn)
+}
+
+// Run は ctx がキャンセルされるまで通知を受け取る。購読が切れた場合は再接続する
+func (i *Invalidator) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		i.receive(ctx)
+		select {
+		case <-ctx.Done():
+		case <-time.After(resubscribeDelay):
+		}
+	}
+}
+
+func (i *Invalidator) receive(ctx context.Context) {
+	sub := i.client.Subscribe(ctx, i.channel())
+	defer sub.Close()
+	// 終了時に Channel を閉じて受信のループを抜ける
+	stop := context.AfterFunc(ctx, func() { _ = sub.Close() })
+	defer stop()
+	if _, err := sub.Receive(ctx); err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("coord: failed to subscribe to invalidations", "err", err)
+		}
+		return
+	}
+	for msg := range sub.Channel() {
+		var inv invalidation
+		if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil || inv.Origin == i.origin {
+			continue
+		}
+		i.mu.RLock()
+		handlers := i.handlers[inv.Topic]
+		i.mu.RUnlock()
+		for _, fn := range handlers {
+			fn(inv.Key)
+		}
+	}
+}
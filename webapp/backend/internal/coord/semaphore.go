@@ -0,0 +1,73 @@
+package coord
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 枠が空くのを確認する間隔
+const semaphorePollInterval = 20 * time.Millisecond
+
+// ErrSemaphoreTimeout は待ち時間内に枠が空かなかった場合に返す
+var ErrSemaphoreTimeout = errors.New("coord: semaphore wait timed out")
+
+// 自分が確保した枠のみ解放する（期限切れ後にほかのサーバーが確保した枠を消さない）
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Semaphore は全サーバーで合計 size 個までの枠（size が 1 の場合は排他ロック）
+// 枠は lease の期限付きで確保するため、確保したサーバーが落ちても lease 後には空く
+type Semaphore struct {
+	client *Client
+	name   string
+	size   int
+	lease  time.Duration
+}
+
+func NewSemaphore(client *Client, name string, size int, lease time.Duration) *Semaphore {
+	return &Semaphore{client: client, name: name, size: size, lease: lease}
+}
+
+// Acquire は枠を確保し、解放する関数を返す。wait の間に空かなければ ErrSemaphoreTimeout を返す
+// lease より長く保持すると、ほかのサーバーが同じ枠を確保しうることに注意
+func (s *Semaphore) Acquire(ctx context.Context, wait time.Duration) (func(), error) {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	token := hex.EncodeToString(b)
+
+	deadline := time.Now().Add(wait)
+	for {
+		for i := 0; i < s.size; i++ {
+			key := s.client.Key("semaphore:" + s.name + ":" + strconv.Itoa(i))
+			ok, err := s.client.SetNX(ctx, key, token, s.lease).Result()
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return func() {
+					ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+					defer cancel()
+					_ = releaseScript.Run(ctx, s.client, []string{key}, token).Err()
+				}, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrSemaphoreTimeout
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(semaphorePollInterval):
+		}
+	}
+}
@@ -1,41 +1,117 @@
 package db
 
 import (
+	"backend/internal/config"
 	"backend/internal/telemetry"
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"log/slog"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 )
 
-func InitDBConnection() (*sqlx.DB, error) {
-	dbUrl := os.Getenv("DATABASE_URL")
+// Driver が sqlite の場合はローカル開発用に SQLite、postgres の場合は PostgreSQL を使う（デフォルトは mysql）
+// Storage が memory の場合はデータベースを使わないため、エラーを返す（repository.NewMemoryStore を使う）
+func InitDBConnection(cfg config.DB) (*sqlx.DB, error) {
+	if cfg.Memory() {
+		return nil, errors.New("STORAGE=memory does not use a database")
+	}
+	switch cfg.Driver {
+	case "sqlite":
+		return initSQLite(cfg)
+	case "postgres":
+		return initPostgres(cfg)
+	}
+
+	dbUrl := cfg.URL
 	if dbUrl == "" {
 		dbUrl = "user:password@tcp(db:3306)/hiroshimauniv2511-db"
 	}
-	dsn := fmt.Sprintf("%s?charset=utf8mb4&parseTime=True&loc=UTC", dbUrl)
 
-	driverName := telemetry.WrapSQLDriver("mysql")
-	dbConn, err := sqlx.Open(driverName, dsn)
+	dbConn, err := openDB(dbUrl, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	err = dbConn.PingContext(ctx)
-	if err != nil {
+	if err := pingWithRetry(dbConn, "database", cfg); err != nil {
 		dbConn.Close()
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
+	slog.Info("database connection pool", "pool", newPoolConfig(cfg).String())
+
+	return dbConn, nil
+}
+
+// 読み取り専用レプリカへの接続を開く
+// ReplicaURLs が空ならレプリカなし
+// 起動時に接続できないレプリカもヘルスチェックで復帰できるよう、接続確認の失敗はログに残すだけにする
+func InitReplicaConnections(cfg config.DB) ([]*sqlx.DB, error) {
+	if cfg.EffectiveDriver() != "mysql" {
+		return nil, nil
+	}
+
+	var replicas []*sqlx.DB
+	for _, url := range cfg.ReplicaURLs {
+		conn, err := openDB(url, cfg)
+		if err != nil {
+			for _, r := range replicas {
+				r.Close()
+			}
+			return nil, fmt.Errorf("failed to open replica connection: %w", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := conn.PingContext(ctx); err != nil {
+			slog.Warn("replica is not reachable yet", "err", err)
+		}
+		cancel()
+		replicas = append(replicas, conn)
+	}
+	return replicas, nil
+}
+
+func openDB(dbUrl string, cfg config.DB) (*sqlx.DB, error) {
+	dsn, err := mysqlDSN(dbUrl, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	driverName := telemetry.WrapSQLDriver("mysql")
+	dbConn, err := sqlx.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
 
-	// Tuned connection pool for higher concurrency. Adjust as needed per environment.
-	dbConn.SetMaxOpenConns(100)
-	dbConn.SetMaxIdleConns(20)
-	dbConn.SetConnMaxLifetime(5 * time.Minute)
+	pool := newPoolConfig(cfg)
+	dbConn.SetMaxOpenConns(pool.maxOpenConns)
+	dbConn.SetMaxIdleConns(pool.maxIdleConns)
+	dbConn.SetConnMaxLifetime(pool.connMaxLifetime)
+	dbConn.SetConnMaxIdleTime(pool.connMaxIdleTime)
 
 	return dbConn, nil
 }
+
+type poolConfig struct {
+	maxOpenConns    int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+	connMaxIdleTime time.Duration
+}
+
+// コネクションプールの設定
+func newPoolConfig(cfg config.DB) poolConfig {
+	return poolConfig{
+		maxOpenConns:    cfg.MaxOpenConns,
+		maxIdleConns:    cfg.MaxIdleConns,
+		connMaxLifetime: cfg.ConnMaxLifetime,
+		connMaxIdleTime: cfg.ConnMaxIdleTime,
+	}
+}
+
+func (c poolConfig) String() string {
+	return fmt.Sprintf("max_open_conns=%d max_idle_conns=%d conn_max_lifetime=%s conn_max_idle_time=%s",
+		c.maxOpenConns, c.maxIdleConns, c.connMaxLifetime, c.connMaxIdleTime)
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"backend/internal/config"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// DATABASE_URL の形式が不正、または接続オプションが不正な場合に返す
+var ErrInvalidDSN = errors.New("invalid database configuration")
+
+// 独自の CA / クライアント証明書を使う場合に mysql ドライバへ登録する TLS 設定の名前
+const customTLSConfigName = "backend-custom"
+
+// mysqlDSN は DATABASE_URL（go-sql-driver/mysql の DSN 形式）を解析し、接続オプションを反映した DSN を返す
+// DSN にパラメータが無い場合は charset=utf8mb4 / parseTime / loc=UTC を補う
+// 以下の設定で上書きできる
+//   - TLS: true / false / skip-verify / preferred（TLSCA を指定した場合は CA を検証する）
+//   - TLSCA / TLSCert / TLSKey: CA 証明書、クライアント証明書と鍵のファイル
+//   - InterpolateParams: true でプレースホルダをクライアント側で展開する
+//   - Collation: 接続の照合順序
+//   - DialTimeout / ReadTimeout / WriteTimeout: 接続・読み込み・書き込みのタイムアウト
+func mysqlDSN(dbUrl string, dbCfg config.DB) (string, error) {
+	cfg, err := mysql.ParseDSN(dbUrl)
+	if err != nil {
+		return "", fmt.Errorf("%w: DATABASE_URL: %v", ErrInvalidDSN, err)
+	}
+	if cfg.Addr == "" || cfg.DBName == "" {
+		return "", fmt.Errorf("%w: DATABASE_URL must contain an address and a database name", ErrInvalidDSN)
+	}
+
+	// アプリケーションは DATETIME を time.Time として UTC で扱う前提のため常に有効にする
+	cfg.ParseTime = true
+	if !strings.Contains(dbUrl, "charset=") {
+		if err := cfg.Apply(mysql.Charset("utf8mb4", "")); err != nil {
+			return "", err
+		}
+	}
+
+	if dbCfg.Collation != "" {
+		cfg.Collation = dbCfg.Collation
+	}
+	if v := dbCfg.InterpolateParams; v != "" {
+		cfg.InterpolateParams = v == "true"
+	}
+	for _, t := range []struct {
+		v   time.Duration
+		dst *time.Duration
+	}{
+		{dbCfg.DialTimeout, &cfg.Timeout},
+		{dbCfg.ReadTimeout, &cfg.ReadTimeout},
+		{dbCfg.WriteTimeout, &cfg.WriteTimeout},
+	} {
+		if t.v > 0 {
+			*t.dst = t.v
+		}
+	}
+	if err := applyTLS(cfg, dbCfg); err != nil {
+		return "", err
+	}
+
+	// 組み合わせの検証（interpolateParams と安全でない照合順序など）はドライバに任せる
+	dsn := cfg.FormatDSN()
+	if _, err := mysql.ParseDSN(dsn); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidDSN, err)
+	}
+	return dsn, nil
+}
+
+func applyTLS(cfg *mysql.Config, dbCfg config.DB) error {
+	mode := dbCfg.TLS
+	ca, cert, key := dbCfg.TLSCA, dbCfg.TLSCert, dbCfg.TLSKey
+	if ca == "" && cert == "" && key == "" {
+		switch mode {
+		case "":
+		case "true", "false", "skip-verify", "preferred":
+			cfg.TLSConfig = mode
+		default:
+			return fmt.Errorf("%w: DB_TLS=%q must be one of true, false, skip-verify, preferred", ErrInvalidDSN, mode)
+		}
+		return nil
+	}
+	if mode == "false" {
+		return fmt.Errorf("%w: DB_TLS=false conflicts with DB_TLS_CA / DB_TLS_CERT / DB_TLS_KEY", ErrInvalidDSN)
+	}
+
+	host := cfg.Addr
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	tlsCfg := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	if mode == "skip-verify" {
+		tlsCfg.InsecureSkipVerify = true
+	}
+	if ca != "" {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return fmt.Errorf("%w: DB_TLS_CA: %v", ErrInvalidDSN, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%w: DB_TLS_CA: no certificates found in %s", ErrInvalidDSN, ca)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if cert != "" || key != "" {
+		if cert == "" || key == "" {
+			return fmt.Errorf("%w: DB_TLS_CERT and DB_TLS_KEY must be set together", ErrInvalidDSN)
+		}
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return fmt.Errorf("%w: client certificate: %v", ErrInvalidDSN, err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{pair}
+	}
+	if err := mysql.RegisterTLSConfig(customTLSConfigName, tlsCfg); err != nil {
+		return err
+	}
+	cfg.TLSConfig = customTLSConfigName
+	return nil
+}
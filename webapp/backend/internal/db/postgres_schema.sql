@@ -0,0 +1,174 @@
+-- PostgreSQL 用のスキーマ
+-- mysql/init/init.sql と mysql/migration/*.sql を全て適用した状態に相当する（全文検索用の search_text は除く）
+
+CREATE TABLE IF NOT EXISTS users (
+    user_id SERIAL PRIMARY KEY,
+    password_hash VARCHAR(255) NOT NULL,
+    user_name VARCHAR(255) NOT NULL,
+    role VARCHAR(16) NOT NULL DEFAULT 'user',
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC'
+);
+CREATE INDEX IF NOT EXISTS idx_users_user_name ON users(user_name);
+
+CREATE TABLE IF NOT EXISTS categories (
+    category_id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS products (
+    product_id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    value INTEGER NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'JPY',
+    weight INTEGER NOT NULL,
+    image VARCHAR(255),
+    description TEXT,
+    category_id INTEGER NULL REFERENCES categories(category_id) ON DELETE SET NULL,
+    rating_avg DOUBLE PRECISION NOT NULL DEFAULT 0,
+    rating_count INTEGER NOT NULL DEFAULT 0,
+    stock INTEGER NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_products_name ON products(name);
+CREATE INDEX IF NOT EXISTS idx_products_category_id ON products(category_id);
+CREATE INDEX IF NOT EXISTS idx_products_updated_at ON products(updated_at);
+CREATE INDEX IF NOT EXISTS idx_products_stock ON products(stock);
+CREATE INDEX IF NOT EXISTS idx_products_value ON products(value);
+CREATE INDEX IF NOT EXISTS idx_products_weight ON products(weight);
+
+-- MySQL の ON UPDATE CURRENT_TIMESTAMP の代わり（products / orders で共用）
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS trigger AS $$
+BEGIN
+    IF NEW.updated_at = OLD.updated_at THEN
+        NEW.updated_at := CURRENT_TIMESTAMP;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_products_updated_at ON products;
+CREATE TRIGGER trg_products_updated_at BEFORE UPDATE ON products
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+CREATE TABLE IF NOT EXISTS orders (
+    order_id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    shipped_status VARCHAR(16) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    arrived_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    public_id CHAR(26) NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_public_id ON orders(public_id);
+CREATE INDEX IF NOT EXISTS idx_orders_shipped_status ON orders(shipped_status);
+CREATE INDEX IF NOT EXISTS idx_orders_user_created ON orders(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_user_updated ON orders(user_id, updated_at);
+CREATE INDEX IF NOT EXISTS idx_orders_created ON orders(created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_arrived ON orders(arrived_at);
+
+DROP TRIGGER IF EXISTS trg_orders_updated_at ON orders;
+CREATE TRIGGER trg_orders_updated_at BEFORE UPDATE ON orders
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id SERIAL PRIMARY KEY,
+    session_uuid VARCHAR(36) NOT NULL UNIQUE,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS reviews (
+    review_id BIGSERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    rating SMALLINT NOT NULL,
+    comment TEXT,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_reviews_product_created ON reviews(product_id, created_at, review_id);
+
+CREATE TABLE IF NOT EXISTS favorites (
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, product_id)
+);
+CREATE INDEX IF NOT EXISTS idx_favorites_user_created ON favorites(user_id, created_at);
+
+CREATE TABLE IF NOT EXISTS cart_items (
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS product_cooccurrence (
+    product_id INTEGER NOT NULL,
+    related_product_id INTEGER NOT NULL,
+    score INTEGER NOT NULL,
+    PRIMARY KEY (product_id, related_product_id)
+);
+CREATE INDEX IF NOT EXISTS idx_cooccurrence_product_score ON product_cooccurrence(product_id, score);
+
+CREATE TABLE IF NOT EXISTS product_price_history (
+    history_id BIGSERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    old_value INTEGER NOT NULL,
+    new_value INTEGER NOT NULL,
+    changed_by INTEGER NULL REFERENCES users(user_id) ON DELETE SET NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    changed_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_price_history_product_changed ON product_price_history(product_id, changed_at);
+
+CREATE TABLE IF NOT EXISTS outbox (
+    event_id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(64) NOT NULL,
+    aggregate_id BIGINT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    published_at TIMESTAMP NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error VARCHAR(255) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(published_at, event_id);
+
+CREATE TABLE IF NOT EXISTS outbox_relay_lease (
+    lease_id SMALLINT PRIMARY KEY,
+    owner VARCHAR(128) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP NOT NULL
+);
+INSERT INTO outbox_relay_lease (lease_id, owner, expires_at) VALUES (1, '', '1970-01-01 00:00:00') ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope VARCHAR(64) NOT NULL,
+    idem_key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INTEGER NULL,
+    content_type VARCHAR(255) NOT NULL DEFAULT '',
+    response_body BYTEA NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (scope, idem_key)
+);
+CREATE INDEX IF NOT EXISTS idx_idempotency_expires ON idempotency_keys(expires_at);
+
+CREATE TABLE IF NOT EXISTS order_summaries (
+    period VARCHAR(8) NOT NULL,
+    bucket_start TIMESTAMP NOT NULL,
+    orders_created INTEGER NOT NULL DEFAULT 0,
+    value_created BIGINT NOT NULL DEFAULT 0,
+    orders_delivered INTEGER NOT NULL DEFAULT 0,
+    delivery_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+    PRIMARY KEY (period, bucket_start)
+);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id INTEGER PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+    delivery_completed BOOLEAN NOT NULL DEFAULT TRUE,
+    channels VARCHAR(64) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"backend/internal/config"
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 起動時の接続確認のリトライ設定
+// ConnectDeadline までに接続できなければ起動を諦める
+// 待ち時間は ConnectBackoffInitial から倍々に増やし、ConnectBackoffMax で頭打ちにする
+type retryConfig struct {
+	deadline       time.Duration
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	attemptTimeout time.Duration
+}
+
+func newRetryConfig(cfg config.DB) retryConfig {
+	return retryConfig{
+		deadline:       cfg.ConnectDeadline,
+		initialBackoff: cfg.ConnectBackoffInitial,
+		maxBackoff:     cfg.ConnectBackoffMax,
+		attemptTimeout: 5 * time.Second,
+	}
+}
+
+// pingWithRetry はデータベースが応答するまで ping を繰り返す
+// DBコンテナの起動待ちで backend が先に落ちないようにするためのもの
+func pingWithRetry(dbConn *sqlx.DB, name string, dbCfg config.DB) error {
+	cfg := newRetryConfig(dbCfg)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.deadline)
+	defer cancel()
+
+	backoff := cfg.initialBackoff
+	for attempt := 1; ; attempt++ {
+		pingCtx, pingCancel := context.WithTimeout(ctx, cfg.attemptTimeout)
+		err := dbConn.PingContext(pingCtx)
+		pingCancel()
+		if err == nil {
+			if attempt > 1 {
+				slog.Info("connected to database", "name", name, "attempts", attempt)
+			}
+			return nil
+		}
+
+		// 複数のインスタンスが同時に再接続しないよう、待ち時間を 50〜100% の範囲でばらつかせる
+		wait := backoff/2 + rand.N(backoff/2+1)
+		slog.Warn("database is not reachable; retrying", "name", name, "attempt", attempt, "err", err, "retry_in", wait.Round(time.Millisecond).String())
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up after %d attempts in %s: %w", attempt, cfg.deadline, err)
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, cfg.maxBackoff)
+	}
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"backend/internal/config"
+	"backend/internal/telemetry"
+	_ "embed"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+//go:embed sqlite_schema.sql
+var sqliteSchema string
+
+// SQLite のファイルを開き、スキーマを作成する
+// URL にファイルパスを指定する（デフォルトは backend.db）
+func initSQLite(cfg config.DB) (*sqlx.DB, error) {
+	path := cfg.URL
+	if path == "" {
+		path = "backend.db"
+	}
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+
+	dbConn, err := sqlx.Open(telemetry.WrapSQLDriver("sqlite"), dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database connection: %w", err)
+	}
+	// トランザクション中に別の接続で Prepare することがあるため、接続数は1本に絞らず busy_timeout で待たせる
+	dbConn.SetMaxOpenConns(4)
+
+	if _, err := dbConn.Exec(sqliteSchema); err != nil {
+		dbConn.Close()
+		return nil, fmt.Errorf("failed to create sqlite schema: %w", err)
+	}
+	return dbConn, nil
+}
@@ -0,0 +1,169 @@
+-- ローカル開発用のSQLiteスキーマ
+-- mysql/init/init.sql と mysql/migration/*.sql を全て適用した状態に相当する（全文検索用の search_text は除く）
+
+CREATE TABLE IF NOT EXISTS users (
+    user_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    password_hash TEXT NOT NULL,
+    user_name TEXT NOT NULL,
+    role TEXT NOT NULL DEFAULT 'user',
+    timezone TEXT NOT NULL DEFAULT 'UTC'
+);
+CREATE INDEX IF NOT EXISTS idx_users_user_name ON users(user_name);
+
+CREATE TABLE IF NOT EXISTS categories (
+    category_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS products (
+    product_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    value INTEGER NOT NULL,
+    currency TEXT NOT NULL DEFAULT 'JPY',
+    weight INTEGER NOT NULL,
+    image TEXT,
+    description TEXT,
+    category_id INTEGER NULL REFERENCES categories(category_id) ON DELETE SET NULL,
+    rating_avg REAL NOT NULL DEFAULT 0,
+    rating_count INTEGER NOT NULL DEFAULT 0,
+    stock INTEGER NULL,
+    updated_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+CREATE INDEX IF NOT EXISTS idx_products_name ON products(name);
+CREATE INDEX IF NOT EXISTS idx_products_category_id ON products(category_id);
+CREATE INDEX IF NOT EXISTS idx_products_updated_at ON products(updated_at);
+CREATE INDEX IF NOT EXISTS idx_products_stock ON products(stock);
+CREATE INDEX IF NOT EXISTS idx_products_value ON products(value);
+CREATE INDEX IF NOT EXISTS idx_products_weight ON products(weight);
+
+-- MySQL の ON UPDATE CURRENT_TIMESTAMP の代わり
+CREATE TRIGGER IF NOT EXISTS trg_products_updated_at AFTER UPDATE ON products
+WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE products SET updated_at = CURRENT_TIMESTAMP WHERE product_id = NEW.product_id;
+END;
+
+CREATE TABLE IF NOT EXISTS orders (
+    order_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    shipped_status TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    arrived_at DATETIME,
+    updated_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    public_id TEXT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_public_id ON orders(public_id);
+CREATE INDEX IF NOT EXISTS idx_orders_shipped_status ON orders(shipped_status);
+CREATE INDEX IF NOT EXISTS idx_orders_user_created ON orders(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_user_updated ON orders(user_id, updated_at);
+CREATE INDEX IF NOT EXISTS idx_orders_created ON orders(created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_arrived ON orders(arrived_at);
+
+CREATE TRIGGER IF NOT EXISTS trg_orders_updated_at AFTER UPDATE ON orders
+WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE orders SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE order_id = NEW.order_id;
+END;
+
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_uuid TEXT NOT NULL UNIQUE,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS reviews (
+    review_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id INTEGER NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    rating INTEGER NOT NULL,
+    comment TEXT,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_reviews_product_created ON reviews(product_id, created_at, review_id);
+
+CREATE TABLE IF NOT EXISTS favorites (
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, product_id)
+);
+CREATE INDEX IF NOT EXISTS idx_favorites_user_created ON favorites(user_id, created_at);
+
+CREATE TABLE IF NOT EXISTS cart_items (
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL,
+    updated_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS product_cooccurrence (
+    product_id INTEGER NOT NULL,
+    related_product_id INTEGER NOT NULL,
+    score INTEGER NOT NULL,
+    PRIMARY KEY (product_id, related_product_id)
+);
+CREATE INDEX IF NOT EXISTS idx_cooccurrence_product_score ON product_cooccurrence(product_id, score);
+
+CREATE TABLE IF NOT EXISTS product_price_history (
+    history_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id INTEGER NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    old_value INTEGER NOT NULL,
+    new_value INTEGER NOT NULL,
+    changed_by INTEGER NULL REFERENCES users(user_id) ON DELETE SET NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    changed_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_price_history_product_changed ON product_price_history(product_id, changed_at);
+
+CREATE TABLE IF NOT EXISTS outbox (
+    event_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_type TEXT NOT NULL,
+    aggregate_id INTEGER NOT NULL,
+    payload TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    published_at DATETIME NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(published_at, event_id);
+
+CREATE TABLE IF NOT EXISTS outbox_relay_lease (
+    lease_id INTEGER PRIMARY KEY,
+    owner TEXT NOT NULL DEFAULT '',
+    expires_at DATETIME NOT NULL
+);
+INSERT INTO outbox_relay_lease (lease_id, owner, expires_at) VALUES (1, '', '1970-01-01 00:00:00') ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope TEXT NOT NULL,
+    idem_key TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    status_code INTEGER NULL,
+    content_type TEXT NOT NULL DEFAULT '',
+    response_body BLOB NULL,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    PRIMARY KEY (scope, idem_key)
+);
+CREATE INDEX IF NOT EXISTS idx_idempotency_expires ON idempotency_keys(expires_at);
+
+CREATE TABLE IF NOT EXISTS order_summaries (
+    period TEXT NOT NULL,
+    bucket_start DATETIME NOT NULL,
+    orders_created INTEGER NOT NULL DEFAULT 0,
+    value_created INTEGER NOT NULL DEFAULT 0,
+    orders_delivered INTEGER NOT NULL DEFAULT 0,
+    delivery_seconds REAL NOT NULL DEFAULT 0,
+    PRIMARY KEY (period, bucket_start)
+);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id INTEGER PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+    delivery_completed BOOLEAN NOT NULL DEFAULT TRUE,
+    channels TEXT NOT NULL DEFAULT '',
+    email TEXT NOT NULL DEFAULT '',
+    updated_at DATETIME NOT NULL
+);
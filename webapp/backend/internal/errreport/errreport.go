@@ -0,0 +1,59 @@
+// Package errreport は 500 やパニックをエラートラッキング（Sentry 互換）へ送る
+//
+// 送信先は SetDefault で差し替える。未設定の場合は何もしない（ログには呼び出し元が出力する）
+package errreport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// Event は1件のエラー
+type Event struct {
+	Err error
+	// 発生したリクエスト（バックグラウンド処理などでは nil）
+	Request *http.Request
+	// ルート（chi のパターンや GraphQL のパス）。同じ場所のエラーをまとめるのに使う
+	Route string
+	// 追加のタグ
+	Tags map[string]string
+}
+
+// Reporter はエラーを送る。Report はリクエストを待たせないよう、送信を待たずに戻ること
+type Reporter interface {
+	Report(ctx context.Context, ev Event)
+}
+
+type holder struct{ r Reporter }
+
+var current atomic.Pointer[holder]
+
+// SetDefault は Report の送信先を設定する（nil で無効）
+func SetDefault(r Reporter) {
+	current.Store(&holder{r: r})
+}
+
+// Report は設定された送信先へ ev を送る
+func Report(ctx context.Context, ev Event) {
+	if h := current.Load(); h != nil && h.r != nil {
+		h.r.Report(ctx, ev)
+	}
+}
+
+// PanicError は復旧したパニック。ログとエラートラッキングでスタックトレースを区別して扱うためのもの
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n%s", e.Value, e.Stack)
+}
+
+// Unwrap はパニックの値が error の場合にそれを返す
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
@@ -0,0 +1,252 @@
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/logging"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 終了時に送り残したイベントを送る時間
+const drainTimeout = 3 * time.Second
+
+// SentryReporter は Sentry の store API（DSN 形式: https://<key>@<host>/<project>）へイベントを送る
+// 送信は Run のゴルーチンで行い、キューが溢れたイベントは捨てる
+type SentryReporter struct {
+	endpoint    string
+	auth        string
+	release     string
+	environment string
+	traceURL    string
+	serverName  string
+	client      *http.Client
+	queue       chan []byte
+}
+
+// NewSentryReporter は cfg.DSN が空の場合は nil を返す
+func NewSentryReporter(cfg config.ErrorReporting, environment string) (*SentryReporter, error) {
+	if cfg.DSN == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(cfg.DSN)
+	if err != nil || u.User == nil || u.Host == "" {
+		return nil, fmt.Errorf("errreport: invalid SENTRY_DSN")
+	}
+	project := strings.Trim(u.Path, "/")
+	if project == "" {
+		return nil, fmt.Errorf("errreport: SENTRY_DSN has no project id")
+	}
+	// パスに接頭辞がある DSN（https://key@host/prefix/123）にも対応する
+	prefix, projectID := "", project
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		prefix, projectID = "/"+project[:i], project[i+1:]
+	}
+	release := cfg.Release
+	if release == "" {
+		release = buildRevision()
+	}
+	host, _ := os.Hostname()
+	return &SentryReporter{
+		endpoint:    u.Scheme + "://" + u.Host + prefix + "/api/" + projectID + "/store/",
+		auth:        "Sentry sentry_version=7, sentry_client=backend/1.0, sentry_key=" + u.User.Username(),
+		release:     release,
+		environment: environment,
+		traceURL:    cfg.TraceURL,
+		serverName:  host,
+		client:      &http.Client{Timeout: 5 * time.Second},
+		queue:       make(chan []byte, cfg.QueueSize),
+	}, nil
+}
+
+// buildRevision はビルド情報の VCS リビジョン（無い場合は空文字）
+func buildRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+func (s *SentryReporter) Report(ctx context.Context, ev Event) {
+	body, err := json.Marshal(s.event(ctx, ev))
+	if err != nil {
+		slog.WarnContext(ctx, "errreport: failed to encode event", "err", err)
+		return
+	}
+	select {
+	case s.queue <- body:
+	default:
+		slog.WarnContext(ctx, "errreport: queue is full, dropping event")
+	}
+}
+
+// Run は ctx がキャンセルされるまでキューのイベントを送る。キャンセル後は drainTimeout の間だけ送り残しを送る
+func (s *SentryReporter) Run(ctx context.Context) {
+	for {
+		select {
+		case body := <-s.queue:
+			s.send(ctx, body)
+		case <-ctx.Done():
+			drainCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), drainTimeout)
+			defer cancel()
+			for {
+				select {
+				case body := <-s.queue:
+					s.send(drainCtx, body)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *SentryReporter) send(ctx context.Context, body []byte) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", s.auth)
+	res, err := s.client.Do(req)
+	if err != nil {
+		slog.WarnContext(ctx, "errreport: failed to send event", "err", err)
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode >= 300 {
+		slog.WarnContext(ctx, "errreport: event rejected", "status", res.StatusCode)
+	}
+}
+
+// sentryEvent は Sentry のイベント（必要な項目のみ）
+type sentryEvent struct {
+	EventID     string                       `json:"event_id"`
+	Timestamp   string                       `json:"timestamp"`
+	Platform    string                       `json:"platform"`
+	Level       string                       `json:"level"`
+	Logger      string                       `json:"logger"`
+	ServerName  string                       `json:"server_name,omitempty"`
+	Release     string                       `json:"release,omitempty"`
+	Environment string                       `json:"environment,omitempty"`
+	Transaction string                       `json:"transaction,omitempty"`
+	Exception   sentryExceptions             `json:"exception"`
+	Request     *sentryRequest               `json:"request,omitempty"`
+	Tags        map[string]string            `json:"tags,omitempty"`
+	Contexts    map[string]map[string]string `json:"contexts,omitempty"`
+	Extra       map[string]string            `json:"extra,omitempty"`
+	Fingerprint []string                     `json:"fingerprint"`
+}
+
+type sentryExceptions struct {
+	Values []sentryException `json:"values"`
+}
+
+type sentryException struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sentryRequest struct {
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	QueryString string            `json:"query_string,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+// Cookie や API キーを送らないよう、送るヘッダーは限定する
+var reportedHeaders = []string{"User-Agent", "Content-Type", "Accept", "Api-Version"}
+
+func (s *SentryReporter) event(ctx context.Context, ev Event) sentryEvent {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	e := sentryEvent{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339Nano),
+		Platform:    "go",
+		Level:       "error",
+		Logger:      "backend",
+		ServerName:  s.serverName,
+		Release:     s.release,
+		Environment: s.environment,
+		Transaction: ev.Route,
+		Tags:        map[string]string{},
+		Extra:       map[string]string{},
+	}
+
+	errType, message := describe(ev.Err)
+	var p *PanicError
+	if errors.As(ev.Err, &p) {
+		e.Level = "fatal"
+		errType, message = "panic", fmt.Sprint(p.Value)
+		e.Extra["stack"] = string(p.Stack)
+	}
+	e.Exception.Values = []sentryException{{Type: errType, Value: message}}
+	// メッセージ中の ID などの数値は同じ種類のエラーを別々にしないよう伏せてまとめる
+	e.Fingerprint = []string{ev.Route, errType, digits.ReplaceAllString(message, "N")}
+
+	if r := ev.Request; r != nil {
+		e.Request = &sentryRequest{Method: r.Method, URL: r.URL.Path, QueryString: r.URL.RawQuery, Headers: map[string]string{}}
+		for _, h := range reportedHeaders {
+			if v := r.Header.Get(h); v != "" {
+				e.Request.Headers[h] = v
+			}
+		}
+	}
+	if id := logging.RequestID(ctx); id != "" {
+		e.Tags["request_id"] = id
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		e.Tags["trace_id"] = sc.TraceID().String()
+		e.Contexts = map[string]map[string]string{"trace": {
+			"trace_id": sc.TraceID().String(),
+			"span_id":  sc.SpanID().String(),
+		}}
+		if s.traceURL != "" {
+			e.Extra["trace_url"] = strings.ReplaceAll(s.traceURL, "{trace_id}", sc.TraceID().String())
+		}
+	}
+	for k, v := range ev.Tags {
+		e.Tags[k] = v
+	}
+	return e
+}
+
+var digits = regexp.MustCompile(`[0-9]+`)
+
+// describe はエラーの根本原因の型とメッセージ全体を返す
+func describe(err error) (string, string) {
+	if err == nil {
+		return "error", ""
+	}
+	root := err
+	for {
+		next := errors.Unwrap(root)
+		if next == nil {
+			break
+		}
+		root = next
+	}
+	return fmt.Sprintf("%T", root), err.Error()
+}
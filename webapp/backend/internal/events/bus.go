@@ -0,0 +1,149 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"backend/internal/model"
+	"backend/internal/telemetry"
+	"backend/internal/workerpool"
+)
+
+// イベントの種類
+const (
+	TypeLowStock = "product.low_stock"
+
+	// 注文のライフサイクル（アウトボックス経由で外部へ配信する）
+	TypeOrderCreated       = "order.created"
+	TypeOrderClaimed       = "order.claimed"
+	TypeOrderDelivered     = "order.delivered"
+	TypeOrderStatusChanged = "order.status_changed"
+
+	// ロボットの現在位置（アウトボックスには記録せず、ダッシュボードへの配信のみ）
+	TypeRobotPosition = "robot.position"
+)
+
+// OrderCreated は1商品分の注文作成イベントのペイロード
+type OrderCreated struct {
+	UserID    int      `json:"user_id"`
+	ProductID int      `json:"product_id"`
+	OrderIDs  []string `json:"order_ids"`
+}
+
+// OrderStatusChanged は注文の状態変更イベントのペイロード
+// 外部（アウトボックス・WebSocket）へは公開IDだけを出し、OrderIDs はサーバー内の購読者だけが使う
+type OrderStatusChanged struct {
+	OrderIDs       []int64             `json:"-"`
+	PublicOrderIDs []string            `json:"order_ids"`
+	From           model.ShippedStatus `json:"from,omitempty"`
+	To             model.ShippedStatus `json:"to"`
+}
+
+// NewOrderStatusChanged は orderIDs の状態変更イベントを返す（publicIDs は OrderRepository.PublicIDs の結果）
+// 公開IDの無い注文は公開IDの一覧に含めない
+func NewOrderStatusChanged(orderIDs []int64, publicIDs map[int64]string, from, to model.ShippedStatus) OrderStatusChanged {
+	changed := OrderStatusChanged{OrderIDs: orderIDs, PublicOrderIDs: make([]string, 0, len(orderIDs)), From: from, To: to}
+	for _, id := range orderIDs {
+		if publicID := publicIDs[id]; publicID != "" {
+			changed.PublicOrderIDs = append(changed.PublicOrderIDs, publicID)
+		}
+	}
+	return changed
+}
+
+// RobotPosition はロボットの位置報告イベントのペイロード
+type RobotPosition struct {
+	RobotID    string    `json:"robot_id"`
+	X          float64   `json:"x"`
+	Y          float64   `json:"y"`
+	ReportedAt time.Time `json:"reported_at"`
+}
+
+// OrderStatusEventType は変更後の状態に対応するイベント種別を返す
+func OrderStatusEventType(status model.ShippedStatus) string {
+	switch status {
+	case model.StatusDelivering:
+		return TypeOrderClaimed
+	case model.StatusCompleted:
+		return TypeOrderDelivered
+	default:
+		return TypeOrderStatusChanged
+	}
+}
+
+type Event struct {
+	Type       string      `json:"type"`
+	Payload    interface{} `json:"payload"`
+	OccurredAt time.Time   `json:"occurred_at"`
+}
+
+type Handler func(ctx context.Context, ev Event)
+
+// Bus はプロセス内のイベントバス
+// Publish は購読者を同期的に呼び出すため、重い処理は購読者側でgoroutineに逃がすこと
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[string][]Handler)}
+}
+
+// Subscribe はイベント種別ごとに購読者を登録する
+func (b *Bus) Subscribe(eventType string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], h)
+}
+
+func (b *Bus) Publish(ctx context.Context, eventType string, payload interface{}) {
+	ev := Event{Type: eventType, Payload: payload, OccurredAt: time.Now().UTC()}
+
+	b.mu.RLock()
+	handlers := b.handlers[eventType]
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(ctx, ev)
+	}
+}
+
+// WebhookHandler はイベントをJSONで指定URLにPOSTする購読者を返す
+// リクエスト処理を遅らせないよう、送信は pool のワーカーで行う。送信待ちが pool の上限を超えたイベントは破棄する
+func WebhookHandler(url string, pool *workerpool.Pool) Handler {
+	client := &http.Client{Timeout: 5 * time.Second, Transport: telemetry.Transport(nil)}
+	return func(ctx context.Context, ev Event) {
+		body, err := json.Marshal(ev)
+		if err != nil {
+			slog.ErrorContext(ctx, "events: failed to encode event", "type", ev.Type, "err", err)
+			return
+		}
+		// リクエストの終了後に送るため、キャンセルは引き継がずにトレースだけ引き継ぐ（Submit が行う）
+		err = pool.Submit(ctx, func(ctx context.Context) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+			if err != nil {
+				slog.WarnContext(ctx, "events: invalid webhook request", "type", ev.Type, "err", err)
+				return
+			}
+			req.Header.Set("Content-Type", "application/json")
+			res, err := client.Do(req)
+			if err != nil {
+				slog.WarnContext(ctx, "events: webhook failed", "type", ev.Type, "err", err)
+				return
+			}
+			res.Body.Close()
+			if res.StatusCode >= 300 {
+				slog.WarnContext(ctx, "events: webhook returned error status", "type", ev.Type, "status", res.StatusCode)
+			}
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "events: dropping webhook", "type", ev.Type, "err", err)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Package featureflag はチューニングなどのリスクのある変更を一部のユーザーから段階的に有効にするためのフラグを提供する
+//
+// フラグごとに有効にする割合（0〜100%）と、割合に関係なく常に有効にするユーザーを指定する。
+// 同じユーザーには常に同じ結果を返すため、ユーザーから見た挙動がリクエストごとに揺れない。
+// ユーザーが分からない呼び出し（ロボットなど）はリクエストごとに割合で判定する
+//
+// 設定は次の順に重ねる（後のものが優先）
+//   - Flag ごとのデフォルト
+//   - 設定ファイルの features.flags
+//   - FEATURE_FLAGS（cursor_pagination=100,product_list_cache=25 のようなカンマ区切り）
+//   - FEATURE_FLAGS_FILE（実行中も変更を検知して読み直す）
+package featureflag
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"backend/internal/config"
+)
+
+// ErrUnknownFlag は定義されていないフラグ名が設定された場合に返す（タイプミスで黙って無視しないため）
+var ErrUnknownFlag = errors.New("unknown feature flag")
+
+// ErrInvalidRule はフラグの設定値が不正な場合に返す
+var ErrInvalidRule = errors.New("invalid feature flag rule")
+
+type Flag string
+
+const (
+	// 商品一覧のレスポンスに次ページのカーソル（next_cursor）を含める
+	CursorPagination Flag = "cursor_pagination"
+	// 商品一覧のページ単位のキャッシュを使う
+	ProductListCache Flag = "product_list_cache"
+)
+
+// defaults は定義済みのフラグと、設定が無い場合の有効な割合
+var defaults = map[Flag]int{
+	CursorPagination: 100,
+	ProductListCache: 100,
+}
+
+// Rule は1つのフラグの設定
+type Rule struct {
+	Percent int   `json:"percent"`
+	Users   []int `json:"users,omitempty"`
+}
+
+// Set はフラグの設定の集合。nil の場合は各フラグのデフォルトで判定する
+type Set struct {
+	// base は起動時の設定（FEATURE_FLAGS_FILE を読み直す際の土台）
+	base  map[Flag]Rule
+	rules atomic.Pointer[map[Flag]Rule]
+	// FEATURE_FLAGS_FILE のパス（空なら読まない）
+	file string
+}
+
+var published atomic.Pointer[Set]
+
+func init() {
+	expvar.Publish("feature_flags", expvar.Func(func() interface{} {
+		if s := published.Load(); s != nil {
+			return s.Snapshot()
+		}
+		return nil
+	}))
+}
+
+// New は cfg からフラグの設定を組み立てる。FEATURE_FLAGS_FILE が指定されていれば読み込む
+func New(cfg config.Features) (*Set, error) {
+	base := make(map[Flag]Rule, len(defaults))
+	for f, p := range defaults {
+		base[f] = Rule{Percent: p}
+	}
+	for name, r := range cfg.Flags {
+		if err := merge(base, name, Rule{Percent: r.Percent, Users: r.Users}); err != nil {
+			return nil, err
+		}
+	}
+	if err := mergeSpec(base, cfg.Overrides); err != nil {
+		return nil, err
+	}
+
+	s := &Set{base: base, file: cfg.File}
+	s.rules.Store(&base)
+	if s.file != "" {
+		if _, err := s.reload(); err != nil {
+			return nil, err
+		}
+	}
+	published.Store(s)
+	return s, nil
+}
+
+// Enabled は userID のユーザーに対して flag が有効かどうかを返す（userID が 0 の場合はリクエストごとに判定する）
+func (s *Set) Enabled(flag Flag, userID int) bool {
+	rule := Rule{Percent: defaults[flag]}
+	if s != nil {
+		rule = (*s.rules.Load())[flag]
+	}
+	if userID != 0 && slices.Contains(rule.Users, userID) {
+		return true
+	}
+	switch {
+	case rule.Percent >= 100:
+		return true
+	case rule.Percent <= 0:
+		return false
+	case userID == 0:
+		return rand.IntN(100) < rule.Percent
+	}
+	return bucket(flag, userID) < rule.Percent
+}
+
+// Snapshot は現在の設定を返す（/debug/vars 向け）
+func (s *Set) Snapshot() map[Flag]Rule {
+	if s == nil {
+		return nil
+	}
+	return *s.rules.Load()
+}
+
+// bucket はユーザーをフラグごとに 0〜99 に割り振る
+// フラグ名を混ぜることで、割合が同じフラグでも同じユーザーばかりが対象にならないようにする
+func bucket(flag Flag, userID int) int {
+	h := fnv.New32a()
+	h.Write([]byte(flag))
+	h.Write([]byte{':'})
+	h.Write([]byte(strconv.Itoa(userID)))
+	return int(h.Sum32() % 100)
+}
+
+func merge(rules map[Flag]Rule, name string, r Rule) error {
+	f := Flag(strings.TrimSpace(name))
+	if _, ok := defaults[f]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownFlag, name)
+	}
+	if r.Percent < 0 || r.Percent > 100 {
+		return fmt.Errorf("%w: %s: percent must be between 0 and 100", ErrInvalidRule, name)
+	}
+	rules[f] = r
+	return nil
+}
+
+// mergeSpec は "name=percent" のカンマ区切りを読む。on / off は 100 / 0 として扱う
+func mergeSpec(rules map[Flag]Rule, spec string) error {
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("%w: %q: want <name>=<percent>", ErrInvalidRule, item)
+		}
+		var percent int
+		switch value = strings.TrimSuffix(strings.TrimSpace(value), "%"); value {
+		case "on", "true":
+			percent = 100
+		case "off", "false":
+			percent = 0
+		default:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%w: %q: want <name>=<percent>", ErrInvalidRule, item)
+			}
+			percent = n
+		}
+		// 対象ユーザーの指定は上書きしない
+		r := rules[Flag(strings.TrimSpace(name))]
+		r.Percent = percent
+		if err := merge(rules, name, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package featureflag
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileRule は FEATURE_FLAGS_FILE の1フラグ分（features.flags と同じ形式）
+type fileRule struct {
+	Percent int   `yaml:"percent"`
+	Users   []int `yaml:"users"`
+}
+
+// reload は FEATURE_FLAGS_FILE を読み直し、起動時の設定に重ねたものに差し替える
+// 読み込みや検証に失敗した場合は現在の設定を維持する。戻り値はファイルの更新日時
+func (s *Set) reload() (time.Time, error) {
+	info, err := os.Stat(s.file)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("feature flags file: %w", err)
+	}
+	b, err := os.ReadFile(s.file)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("feature flags file: %w", err)
+	}
+	var file map[string]fileRule
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return time.Time{}, fmt.Errorf("%w: %s: %v", ErrInvalidRule, s.file, err)
+	}
+
+	rules := maps.Clone(s.base)
+	for name, r := range file {
+		if err := merge(rules, name, Rule{Percent: r.Percent, Users: r.Users}); err != nil {
+			return time.Time{}, err
+		}
+	}
+	s.rules.Store(&rules)
+	return info.ModTime(), nil
+}
+
+// Watch は interval ごとに FEATURE_FLAGS_FILE の更新日時を確認し、変わっていれば読み直す
+// ファイルを指定していない場合はすぐに戻る
+func (s *Set) Watch(ctx context.Context, interval time.Duration) {
+	if s == nil || s.file == "" || interval <= 0 {
+		return
+	}
+	var modTime time.Time
+	if info, err := os.Stat(s.file); err == nil {
+		modTime = info.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(s.file)
+		if err != nil || info.ModTime().Equal(modTime) {
+			continue
+		}
+		loaded, err := s.reload()
+		if err != nil {
+			slog.Warn("failed to reload feature flags; keeping the current rules", "file", s.file, "err", err)
+			// 同じ内容で警告を繰り返さないよう、壊れた版の更新日時も記録しておく
+			modTime = info.ModTime()
+			continue
+		}
+		modTime = loaded
+		slog.Info("reloaded feature flags", "file", s.file, "flags", s.Snapshot())
+	}
+}
@@ -0,0 +1,53 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"backend/internal/apierror"
+	"backend/internal/errreport"
+	"backend/internal/service"
+	"backend/internal/validation"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// presentError はリゾルバのエラーを REST と同じエラーコード（extensions.code）付きの GraphQL エラーにする
+// 想定外のエラーは詳細をログにのみ出し、レスポンスには INTERNAL とだけ返す
+func presentError(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+	var verrs validation.Errors
+	var apiErr *apierror.Error
+	switch {
+	case errors.As(err, &verrs):
+		fields := make([]map[string]string, len(verrs))
+		for i, fe := range verrs {
+			fields[i] = map[string]string{"field": fe.Field, "message": fe.Message}
+		}
+		gqlErr.Message = "Validation failed"
+		gqlErr.Extensions = map[string]interface{}{"code": apierror.CodeValidationFailed, "errors": fields}
+	case errors.As(err, &apiErr):
+		gqlErr.Message = apiErr.Message
+		gqlErr.Extensions = map[string]interface{}{"code": apiErr.Code}
+	case errors.Is(err, service.ErrTimeout):
+		gqlErr.Message = "Request timed out"
+		gqlErr.Extensions = map[string]interface{}{"code": apierror.CodeTimeout}
+	case errors.Is(err, service.ErrUnavailable):
+		gqlErr.Message = "Database temporarily unavailable"
+		gqlErr.Extensions = map[string]interface{}{"code": apierror.CodeUnavailable}
+	default:
+		// クエリのパースや検証など gqlgen 自身のエラーは原因を持たないため、そのまま返す
+		var gqlOwn *gqlerror.Error
+		if errors.As(err, &gqlOwn) && gqlOwn.Err == nil {
+			return gqlErr
+		}
+		slog.ErrorContext(ctx, "graphql resolver failed", "path", gqlErr.Path.String(), "err", err)
+		errreport.Report(ctx, errreport.Event{Err: err, Route: "graphql " + gqlErr.Path.String()})
+		gqlErr.Message = "Internal server error"
+		gqlErr.Extensions = map[string]interface{}{"code": apierror.CodeInternal}
+	}
+	return gqlErr
+}
@@ -0,0 +1,41 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"backend/internal/apierror"
+	"backend/internal/errreport"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+// 1回のクエリで解決できるフィールド数の目安の上限（一覧の入れ子で DB を使い切らないため）
+const maxComplexity = 500
+
+// NewHandler は /graphql のハンドラを返す。LoaderMiddleware の後段で使用すること
+// 受け付けるのは POST（application/json）のみ。GET はキャッシュされやすく、ミューテーションの CSRF にもなりうるため使わない
+func NewHandler(r *Resolver) http.Handler {
+	srv := handler.New(NewExecutableSchema(Config{Resolvers: r}))
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.FixedComplexityLimit(maxComplexity))
+	srv.SetErrorPresenter(presentError)
+	srv.SetRecoverFunc(func(ctx context.Context, v interface{}) error {
+		stack := debug.Stack()
+		slog.ErrorContext(ctx, "graphql resolver panicked", "panic", fmt.Sprint(v), "stack", string(stack))
+		route := "graphql"
+		if fc := graphql.GetFieldContext(ctx); fc != nil {
+			route += " " + fc.Path().String()
+		}
+		errreport.Report(ctx, errreport.Event{Err: &errreport.PanicError{Value: v, Stack: stack}, Route: route})
+		return apierror.Internal("Internal server error")
+	})
+	return srv
+}
@@ -0,0 +1,69 @@
+package graph
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+
+	"github.com/graph-gophers/dataloader/v7"
+)
+
+// 同じリクエスト内の解決をまとめるまでの待ち時間
+// 注文一覧の各注文の商品は同じタイミングで要求されるため、ごく短い時間で十分にまとまる
+const loaderWait = 2 * time.Millisecond
+
+type loadersKey struct{}
+
+// Loaders はリクエストごとの dataloader（結果のキャッシュもリクエスト内に限る）
+type Loaders struct {
+	Product *dataloader.Loader[int, *model.Product]
+}
+
+func newLoaders(userID int, productSvc *service.ProductService) *Loaders {
+	return &Loaders{
+		Product: dataloader.NewBatchedLoader(productBatch(userID, productSvc),
+			dataloader.WithWait[int, *model.Product](loaderWait)),
+	}
+}
+
+// productBatch は商品IDをまとめて1回のクエリで取得する。存在しない商品は nil を返す
+func productBatch(userID int, productSvc *service.ProductService) dataloader.BatchFunc[int, *model.Product] {
+	return func(ctx context.Context, ids []int) []*dataloader.Result[*model.Product] {
+		results := make([]*dataloader.Result[*model.Product], len(ids))
+		products, err := productSvc.FetchProductsByIDs(ctx, userID, ids)
+		if err != nil {
+			for i := range results {
+				results[i] = &dataloader.Result[*model.Product]{Error: err}
+			}
+			return results
+		}
+		byID := make(map[int]*model.Product, len(products))
+		for i := range products {
+			byID[products[i].ProductID] = &products[i]
+		}
+		for i, id := range ids {
+			results[i] = &dataloader.Result[*model.Product]{Data: byID[id]}
+		}
+		return results
+	}
+}
+
+// LoaderMiddleware はリクエストごとの Loaders を ctx に入れる（UserAuthMiddleware の後段で使用すること）
+func LoaderMiddleware(productSvc *service.ProductService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, _ := middleware.GetUserFromContext(r.Context())
+			ctx := context.WithValue(r.Context(), loadersKey{}, newLoaders(userID, productSvc))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func loadersFor(ctx context.Context) *Loaders {
+	l, _ := ctx.Value(loadersKey{}).(*Loaders)
+	return l
+}
@@ -0,0 +1,46 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package graph
+
+import (
+	"backend/internal/model"
+	"time"
+)
+
+type ListInput struct {
+	Search *string `json:"search,omitempty"`
+	// partial / prefix
+	Type      *string `json:"type,omitempty"`
+	Page      *int    `json:"page,omitempty"`
+	PageSize  *int    `json:"pageSize,omitempty"`
+	SortField *string `json:"sortField,omitempty"`
+	SortOrder *string `json:"sortOrder,omitempty"`
+	Category  *int    `json:"category,omitempty"`
+	Cursor    *string `json:"cursor,omitempty"`
+}
+
+type Mutation struct {
+}
+
+type OrderPage struct {
+	Data  []model.Order `json:"data"`
+	Total int           `json:"total"`
+}
+
+type ProductPage struct {
+	Data       []model.Product `json:"data"`
+	Total      int             `json:"total"`
+	NextCursor *string         `json:"nextCursor,omitempty"`
+}
+
+type Query struct {
+}
+
+// 配送状況
+type Tracking struct {
+	// shipping / delivering / completed
+	Status    string     `json:"status"`
+	Delivered bool       `json:"delivered"`
+	ArrivedAt *time.Time `json:"arrivedAt,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
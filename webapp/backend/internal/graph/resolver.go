@@ -0,0 +1,62 @@
+// Package graph は /graphql のスキーマとリゾルバ
+//
+// schema.graphqls を変更した場合は go generate ./internal/graph で generated.go と models_gen.go を再生成する
+package graph
+
+//go:generate go run github.com/99designs/gqlgen generate
+
+import (
+	"backend/internal/apierror"
+	"backend/internal/listparams"
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service"
+	"context"
+)
+
+type Resolver struct {
+	ProductSvc *service.ProductService
+	OrderSvc   *service.OrderService
+	RobotSvc   *service.RobotService
+	UserRepo   repository.Users
+	ReadOnly   *middleware.ReadOnlyMode
+}
+
+// requireAdmin は管理者ロールのユーザーでなければエラーを返す（AdminAuthMiddleware と同じ判定）
+func (r *Resolver) requireAdmin(ctx context.Context) error {
+	userID, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		return apierror.Unauthorized("No session")
+	}
+	role, err := r.UserRepo.FindRoleByID(ctx, userID)
+	if err != nil || role != model.RoleAdmin {
+		return apierror.Forbidden("Admin role required")
+	}
+	return nil
+}
+
+// listRequest は REST の一覧 API と同じデフォルト値と検証で model.ListRequest を組み立てる
+func (in *ListInput) listRequest(spec listparams.Spec) (model.ListRequest, error) {
+	var req model.ListRequest
+	if in != nil {
+		setIf(&req.Search, in.Search)
+		setIf(&req.Type, in.Type)
+		setIf(&req.SortField, in.SortField)
+		setIf(&req.SortOrder, in.SortOrder)
+		setIf(&req.Cursor, in.Cursor)
+		setIf(&req.Category, in.Category)
+		setIf(&req.Page, in.Page)
+		setIf(&req.PageSize, in.PageSize)
+	}
+	if err := listparams.Normalize(&req, spec); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+func setIf[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
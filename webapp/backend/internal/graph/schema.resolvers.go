@@ -0,0 +1,115 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.76
+
+import (
+	"backend/internal/apierror"
+	"backend/internal/listparams"
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+	"context"
+	"errors"
+)
+
+// CreateDeliveryPlan is the resolver for the createDeliveryPlan field.
+func (r *mutationResolver) CreateDeliveryPlan(ctx context.Context, robotID string, capacity int) (*model.DeliveryPlan, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if r.ReadOnly.Enabled() {
+		return nil, apierror.Unavailable("Service is in read-only maintenance mode")
+	}
+	if capacity <= 0 {
+		return nil, apierror.Validation("capacity must be positive", apierror.FieldError{Field: "capacity", Message: "must be positive"})
+	}
+	plan, err := r.RobotSvc.GenerateDeliveryPlan(ctx, robotID, capacity)
+	if errors.Is(err, service.ErrPlannerSaturated) {
+		return nil, apierror.Unavailable("Delivery planner is busy")
+	}
+	return plan, err
+}
+
+// ShippedStatus is the resolver for the shippedStatus field.
+func (r *orderResolver) ShippedStatus(ctx context.Context, obj *model.Order) (string, error) {
+	return string(obj.ShippedStatus), nil
+}
+
+// Product is the resolver for the product field.
+func (r *orderResolver) Product(ctx context.Context, obj *model.Order) (*model.Product, error) {
+	return loadersFor(ctx).Product.Load(ctx, obj.ProductID)()
+}
+
+// Tracking is the resolver for the tracking field.
+func (r *orderResolver) Tracking(ctx context.Context, obj *model.Order) (*Tracking, error) {
+	t := &Tracking{
+		Status:    string(obj.ShippedStatus),
+		Delivered: obj.ShippedStatus == model.StatusCompleted,
+		UpdatedAt: obj.UpdatedAt,
+	}
+	if obj.ArrivedAt.Valid {
+		t.ArrivedAt = &obj.ArrivedAt.Time
+	}
+	return t, nil
+}
+
+// Products is the resolver for the products field.
+func (r *queryResolver) Products(ctx context.Context, input *ListInput) (*ProductPage, error) {
+	userID, _ := middleware.GetUserFromContext(ctx)
+	req, err := input.listRequest(listparams.Products)
+	if err != nil {
+		return nil, err
+	}
+	products, total, err := r.ProductSvc.FetchProducts(ctx, userID, req)
+	if errors.Is(err, service.ErrInvalidSort) || errors.Is(err, service.ErrInvalidCursor) {
+		return nil, apierror.Validation(err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	page := &ProductPage{Data: products, Total: total.Count}
+	if next := r.ProductSvc.NextProductCursor(userID, req, products); next != "" {
+		page.NextCursor = &next
+	}
+	return page, nil
+}
+
+// Product is the resolver for the product field.
+func (r *queryResolver) Product(ctx context.Context, id int) (*model.Product, error) {
+	return loadersFor(ctx).Product.Load(ctx, id)()
+}
+
+// Orders is the resolver for the orders field.
+func (r *queryResolver) Orders(ctx context.Context, input *ListInput) (*OrderPage, error) {
+	userID, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		return nil, apierror.Unauthorized("No session")
+	}
+	req, err := input.listRequest(listparams.Orders)
+	if err != nil {
+		return nil, err
+	}
+	orders, total, err := r.OrderSvc.FetchOrders(ctx, userID, req)
+	if errors.Is(err, service.ErrInvalidSort) {
+		return nil, apierror.Validation(err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &OrderPage{Data: orders, Total: total.Count}, nil
+}
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+// Order returns OrderResolver implementation.
+func (r *Resolver) Order() OrderResolver { return &orderResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+type mutationResolver struct{ *Resolver }
+type orderResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
@@ -0,0 +1,6 @@
+//go:build tools
+
+package graph
+
+// go generate で使う gqlgen を go.mod に固定する
+import _ "github.com/99designs/gqlgen"
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"backend/internal/apierror"
+	"backend/internal/model"
+	"backend/internal/service"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// 注文の集計の since を省略した場合に返す期間
+var defaultSummaryRange = map[string]time.Duration{
+	model.SummaryPeriodHour: 24 * time.Hour,
+	model.SummaryPeriodDay:  30 * 24 * time.Hour,
+}
+
+type AdminHandler struct {
+	AdminSvc *service.AdminService
+	// 日次レポート（nil の場合は REPORT_DIR が未設定のため 503）
+	Reports *service.ReportGenerator
+}
+
+func NewAdminHandler(adminSvc *service.AdminService) *AdminHandler {
+	return &AdminHandler{AdminSvc: adminSvc}
+}
+
+// 運用ダッシュボードの概要（注文の滞留・配送の状況・スロークエリ）
+func (h *AdminHandler) Overview(w http.ResponseWriter, r *http.Request) {
+	overview, err := h.AdminSvc.Overview(r.Context())
+	if err != nil {
+		writeServerError(w, r, err, "Failed to build overview")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(overview)
+}
+
+// 時間・日ごとの注文の集計（period: hour / day、since: RFC 3339 の日時）
+func (h *AdminHandler) OrderStats(w http.ResponseWriter, r *http.Request) {
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = model.SummaryPeriodHour
+	}
+	since := time.Now().Add(-defaultSummaryRange[period])
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			apierror.Write(w, r, apierror.Validation("Query parameter 'since' must be an RFC 3339 timestamp", apierror.FieldError{Field: "since", Message: "must be an RFC 3339 timestamp"}))
+			return
+		}
+		since = t
+	}
+
+	summaries, err := h.AdminSvc.OrderSummaries(r.Context(), period, since)
+	switch {
+	case errors.Is(err, service.ErrInvalidSummaryPeriod):
+		apierror.Write(w, r, apierror.Validation("Query parameter 'period' must be hour or day", apierror.FieldError{Field: "period", Message: "must be hour or day"}))
+		return
+	case errors.Is(err, service.ErrSummariesUnavailable):
+		apierror.Write(w, r, apierror.Unavailable("Order summaries are not available yet"))
+		return
+	case err != nil:
+		writeServerError(w, r, err, "Failed to fetch order summaries")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DataResponse[model.OrderSummary]{Data: summaries})
+}
+
+// 書き出し済みの日次レポートの一覧（新しい順）
+func (h *AdminHandler) ListReports(w http.ResponseWriter, r *http.Request) {
+	files, err := h.Reports.List(r.Context())
+	switch {
+	case errors.Is(err, service.ErrReportsUnavailable):
+		apierror.Write(w, r, apierror.Unavailable("Reports are not enabled"))
+		return
+	case err != nil:
+		writeServerError(w, r, err, "Failed to list reports")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DataResponse[model.ReportFile]{Data: files})
+}
+
+// 日次レポートを取得（name は一覧の name）
+func (h *AdminHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	blob, err := h.Reports.Open(r.Context(), name)
+	switch {
+	case errors.Is(err, service.ErrReportsUnavailable):
+		apierror.Write(w, r, apierror.Unavailable("Reports are not enabled"))
+		return
+	case errors.Is(err, service.ErrReportNotFound):
+		apierror.Write(w, r, apierror.NotFound("Report not found"))
+		return
+	case err != nil:
+		writeServerError(w, r, err, "Failed to read report")
+		return
+	}
+	defer blob.Content.Close()
+
+	contentType := "application/json"
+	if strings.HasSuffix(name, ".csv") {
+		contentType = "text/csv; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeContent(w, r, "", blob.ModTime, blob.Content)
+}
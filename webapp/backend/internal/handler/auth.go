@@ -5,12 +5,15 @@ import (
 	"errors"
 	"net/http"
 
+	"backend/internal/apierror"
 	"backend/internal/model"
 	"backend/internal/service"
 )
 
 type AuthHandler struct {
 	AuthSvc *service.AuthService
+	// true の場合はセッションCookieに Secure 属性を付ける（HTTPS で受けたリクエストには常に付ける）
+	SecureCookie bool
 }
 
 func NewAuthHandler(authSvc *service.AuthService) *AuthHandler {
@@ -20,8 +23,8 @@ func NewAuthHandler(authSvc *service.AuthService) *AuthHandler {
 // ログイン時にセッションを発行し、Cookieにセットする
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req model.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(w, r, &req, maxLoginBodyBytes); err != nil {
+		writeBodyError(w, r, err)
 		return
 	}
 
@@ -29,11 +32,11 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		if errors.Is(err, service.ErrUserNotFound) {
-			http.Error(w, "Unauthorized: Invalid credentials", http.StatusUnauthorized)
+			apierror.Write(w, r, apierror.Unauthorized("Invalid credentials"))
 		} else if errors.Is(err, service.ErrInvalidPassword) {
-			http.Error(w, "Unauthorized: Invalid credentials", http.StatusUnauthorized)
+			apierror.Write(w, r, apierror.Unauthorized("Invalid credentials"))
 		} else {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			writeServerError(w, r, err, "Internal server error")
 		}
 		return
 	}
@@ -43,10 +46,11 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		Value:    sessionID,
 		Expires:  expiresAt,
 		HttpOnly: true,
+		Secure:   h.SecureCookie || r.TLS != nil,
 		Path:     "/",
 	})
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Login successful"})
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Login successful"})
 }
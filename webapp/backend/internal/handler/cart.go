@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"backend/internal/apierror"
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type CartHandler struct {
+	CartSvc *service.CartService
+}
+
+func NewCartHandler(svc *service.CartService) *CartHandler {
+	return &CartHandler{CartSvc: svc}
+}
+
+// カートサービスのエラーをHTTPレスポンスに変換
+func writeCartError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidQuantity):
+		apierror.Write(w, r, apierror.Validation("Quantity must be positive", apierror.FieldError{Field: "quantity", Message: "must be positive"}))
+	case errors.Is(err, service.ErrProductNotFound):
+		apierror.Write(w, r, apierror.NotFound("Product not found"))
+	case errors.Is(err, service.ErrCartItemMissing):
+		apierror.Write(w, r, apierror.NotFound("Item not in cart"))
+	case errors.Is(err, service.ErrCartEmpty):
+		apierror.Write(w, r, apierror.Conflict("Cart is empty"))
+	case errors.Is(err, service.ErrCurrencyMismatch):
+		apierror.Write(w, r, apierror.Conflict("Cart contains products in different currencies"))
+	case errors.Is(err, service.ErrInsufficientStock):
+		apierror.Write(w, r, apierror.Conflict("Insufficient stock"))
+	default:
+		writeServerError(w, r, err, "Failed to process cart request")
+	}
+}
+
+// カートの中身を取得
+func (h *CartHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Internal("User not found in context"))
+		return
+	}
+
+	cart, err := h.CartSvc.GetCart(r.Context(), userID)
+	if err != nil {
+		writeCartError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cart)
+}
+
+// カートに商品を追加
+func (h *CartHandler) AddItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Internal("User not found in context"))
+		return
+	}
+
+	var req model.RequestItem
+	if err := decodeJSON(w, r, &req, maxDefaultBodyBytes); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	if err := h.CartSvc.AddItem(r.Context(), userID, req); err != nil {
+		writeCartError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// カート内の商品の数量を更新
+func (h *CartHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Internal("User not found in context"))
+		return
+	}
+	productID, ok := productIDFromURL(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid product id"))
+		return
+	}
+
+	var req model.UpdateCartItemRequest
+	if err := decodeJSON(w, r, &req, maxDefaultBodyBytes); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	if err := h.CartSvc.UpdateQuantity(r.Context(), userID, productID, req.Quantity); err != nil {
+		writeCartError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// カートから商品を削除
+func (h *CartHandler) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Internal("User not found in context"))
+		return
+	}
+	productID, ok := productIDFromURL(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid product id"))
+		return
+	}
+
+	if err := h.CartSvc.RemoveItem(r.Context(), userID, productID); err != nil {
+		writeCartError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// カートの中身で注文を確定する
+func (h *CartHandler) Checkout(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Internal("User not found in context"))
+		return
+	}
+
+	orders, err := h.CartSvc.Checkout(r.Context(), userID)
+	if err != nil {
+		writeCartError(w, r, err)
+		return
+	}
+
+	response := OrdersCreatedResponse{
+		Message:  "Orders created successfully",
+		OrderIDs: createdOrderIDs(orders, true),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"backend/internal/apierror"
+	"backend/internal/listparams"
+	"backend/internal/model"
+	"backend/internal/validation"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// エンドポイントごとのリクエストボディの上限
+const (
+	maxLoginBodyBytes   = 4 << 10
+	maxDefaultBodyBytes = 64 << 10
+	// 注文は商品を大量に指定できるため大きめにする
+	maxOrderBodyBytes  = 1 << 20
+	maxImportBodyBytes = 32 << 20
+)
+
+// requestBodyError はリクエストボディを読めなかった理由。Field は原因となったフィールド（分かる場合）
+type requestBodyError struct {
+	Status  int
+	Message string
+	Field   string
+}
+
+func (e *requestBodyError) Error() string {
+	if e.Field != "" {
+		return e.Field + ": " + e.Message
+	}
+	return e.Message
+}
+
+// decodeJSON はボディを maxBytes までに制限して dst に読み込み、validate タグに従って検証する
+// 未知のフィールド、型の誤り、2つ目のJSON値などは *requestBodyError を、検証エラーは validation.Errors を返す
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}, maxBytes int64) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return bodyDecodeError(err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return &requestBodyError{Status: http.StatusBadRequest, Message: "request body must contain a single JSON value"}
+	}
+	return validation.Struct(dst)
+}
+
+func bodyDecodeError(err error) *requestBodyError {
+	var (
+		syntaxErr   *json.SyntaxError
+		typeErr     *json.UnmarshalTypeError
+		maxBytesErr *http.MaxBytesError
+	)
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return &requestBodyError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("request body must not exceed %d bytes", maxBytesErr.Limit)}
+	case errors.As(err, &syntaxErr):
+		return &requestBodyError{Status: http.StatusBadRequest, Message: fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset)}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &requestBodyError{Status: http.StatusBadRequest, Message: "malformed JSON"}
+	case errors.Is(err, io.EOF):
+		return &requestBodyError{Status: http.StatusBadRequest, Message: "request body must not be empty"}
+	case errors.As(err, &typeErr):
+		return &requestBodyError{Status: http.StatusBadRequest, Message: fmt.Sprintf("must be of type %s", typeErr.Type), Field: typeErr.Field}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json はこのエラーの型を公開していないため、メッセージからフィールド名を取り出す
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &requestBodyError{Status: http.StatusBadRequest, Message: "unknown field", Field: field}
+	}
+	return &requestBodyError{Status: http.StatusBadRequest, Message: "invalid request body"}
+}
+
+// decodeListRequest は一覧の条件をボディ（JSON）とクエリパラメータから組み立てる
+// ボディの無い GET ではクエリパラメータだけを使い、両方にある項目はクエリパラメータを優先する
+// 不正な値は decodeJSON と同じく writeBodyError で返せるエラーにする
+func decodeListRequest(w http.ResponseWriter, r *http.Request, spec listparams.Spec) (model.ListRequest, error) {
+	var req model.ListRequest
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		if err := decodeJSON(w, r, &req, maxDefaultBodyBytes); err != nil {
+			return req, err
+		}
+	}
+	return listparams.Parse(r.URL.Query(), req, spec)
+}
+
+// writeBodyError は decodeJSON のエラーをエラーレスポンスとして返す
+func writeBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	var verrs validation.Errors
+	if errors.As(err, &verrs) {
+		writeValidationError(w, r, verrs)
+		return
+	}
+	var bodyErr *requestBodyError
+	if !errors.As(err, &bodyErr) {
+		bodyErr = bodyDecodeError(err)
+	}
+	switch {
+	case bodyErr.Status == http.StatusRequestEntityTooLarge:
+		apierror.Write(w, r, apierror.PayloadTooLarge(bodyErr.Message))
+	case bodyErr.Field != "":
+		apierror.Write(w, r, apierror.Validation("Invalid request body", apierror.FieldError{Field: bodyErr.Field, Message: bodyErr.Message}))
+	default:
+		apierror.Write(w, r, apierror.BadRequest(bodyErr.Message))
+	}
+}
+
+// writeValidationError は検証エラーを VALIDATION_FAILED として返す
+func writeValidationError(w http.ResponseWriter, r *http.Request, verrs validation.Errors) {
+	fields := make([]apierror.FieldError, len(verrs))
+	for i, fe := range verrs {
+		fields[i] = apierror.FieldError{Field: fe.Field, Message: fe.Message}
+	}
+	apierror.Write(w, r, apierror.Validation("Validation failed", fields...))
+}
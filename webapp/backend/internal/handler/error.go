@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"backend/internal/apierror"
+	"backend/internal/service"
+	"errors"
+	"net/http"
+)
+
+// 想定外のエラーを返す。DBクエリのタイムアウトは 504、DBの遮断中は 503 にする
+func writeServerError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrTimeout):
+		apierror.Write(w, r, apierror.Timeout("Request timed out").WithCause(err))
+		return
+	case errors.Is(err, service.ErrUnavailable):
+		w.Header().Set("Retry-After", "5")
+		apierror.Write(w, r, apierror.Unavailable("Database temporarily unavailable").WithCause(err))
+		return
+	}
+	apierror.Write(w, r, apierror.Internal(message).WithCause(err))
+}
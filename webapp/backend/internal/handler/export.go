@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// この行数ごとにクライアントへ送り出す
+const exportFlushRows = 500
+
+// exportColumns は CSV の列と NDJSON の各行（日時は loc で表す）
+type exportColumns[T any] struct {
+	header []string
+	record func(row *T, loc *time.Location) []string
+	// NDJSON で書き出す値（nil の場合は行をそのまま JSON にする）
+	object func(row *T, loc *time.Location) interface{}
+}
+
+// writeExport は stream から受け取った行を format（middleware.ExportCSV / ExportNDJSON）で書き出す
+// 行はバッファに溜めずに送り出すため、件数に関係なくメモリの使用量は一定になる
+// 最初の行を送る前のエラーは通常のエラーレスポンスにし、送り始めた後のエラーは接続を切って途中で終わったことを伝える
+func writeExport[T any](w http.ResponseWriter, r *http.Request, format, name string, cols exportColumns[T], stream func(fn func(*T) error) error, writeErr func(http.ResponseWriter, *http.Request, error)) {
+	out := &exportWriter{w: w, contentType: format + "; charset=utf-8"}
+	if format == middleware.ExportCSV {
+		out.disposition = `attachment; filename="` + name + `.csv"`
+	}
+	rc := http.NewResponseController(w)
+	loc := middleware.LocationFromContext(r.Context())
+
+	// write は1行をバッファに書き、flush はバッファを送り出す
+	var write func(*T) error
+	var flush func() error
+	switch format {
+	case middleware.ExportCSV:
+		cw := csv.NewWriter(out)
+		headerWritten := false
+		write = func(row *T) error {
+			if !headerWritten {
+				headerWritten = true
+				if err := cw.Write(cols.header); err != nil {
+					return err
+				}
+			}
+			return cw.Write(cols.record(row, loc))
+		}
+		flush = func() error {
+			// 0件でもヘッダー行は返す
+			if !headerWritten {
+				headerWritten = true
+				_ = cw.Write(cols.header)
+			}
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		bw := bufio.NewWriter(out)
+		enc := json.NewEncoder(bw)
+		write = func(row *T) error {
+			if cols.object != nil {
+				return enc.Encode(cols.object(row, loc))
+			}
+			return enc.Encode(row)
+		}
+		flush = bw.Flush
+	}
+
+	rows := 0
+	err := stream(func(row *T) error {
+		if err := write(row); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushRows == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			_ = rc.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		if !out.started {
+			writeErr(w, r, err)
+			return
+		}
+		slog.ErrorContext(r.Context(), "export aborted", "rows", rows, "err", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// exportWriter は最初の書き込みまでヘッダーを送らない（それまではエラーレスポンスに切り替えられる）
+type exportWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	disposition string
+	started     bool
+}
+
+func (e *exportWriter) Write(p []byte) (int, error) {
+	if !e.started {
+		e.started = true
+		e.w.Header().Set("Content-Type", e.contentType)
+		if e.disposition != "" {
+			e.w.Header().Set("Content-Disposition", e.disposition)
+		}
+		e.w.WriteHeader(http.StatusOK)
+	}
+	return e.w.Write(p)
+}
+
+var orderExportColumns = exportColumns[model.Order]{
+	header: []string{"order_id", "product_id", "product_name", "shipped_status", "created_at", "arrived_at", "updated_at"},
+	record: func(o *model.Order, loc *time.Location) []string {
+		var arrivedAt *time.Time
+		if o.ArrivedAt.Valid {
+			arrivedAt = &o.ArrivedAt.Time
+		}
+		return []string{
+			strconv.FormatInt(o.OrderID, 10),
+			strconv.Itoa(o.ProductID),
+			o.ProductName,
+			string(o.ShippedStatus),
+			o.CreatedAt.In(loc).Format(time.RFC3339),
+			formatOptionalTime(arrivedAt, loc),
+			formatOptionalTime(o.UpdatedAt, loc),
+		}
+	},
+	object: func(o *model.Order, loc *time.Location) interface{} { return newOrderResponse(o, loc) },
+}
+
+// publicOrderExportColumns は order_id を公開IDにした注文の列（v2）
+var publicOrderExportColumns = exportColumns[model.Order]{
+	header: orderExportColumns.header,
+	record: func(o *model.Order, loc *time.Location) []string {
+		record := orderExportColumns.record(o, loc)
+		record[0] = o.ExternalID()
+		return record
+	},
+	object: func(o *model.Order, loc *time.Location) interface{} { return newPublicOrderResponse(o, loc) },
+}
+
+var productExportColumns = exportColumns[model.Product]{
+	header: []string{"product_id", "name", "value", "currency", "weight", "image", "description", "category_id", "stock", "rating_avg", "rating_count", "updated_at"},
+	record: func(p *model.Product, loc *time.Location) []string {
+		return []string{
+			strconv.Itoa(p.ProductID),
+			p.Name,
+			strconv.Itoa(p.Value),
+			p.Currency,
+			strconv.Itoa(p.Weight),
+			p.Image,
+			p.Description,
+			formatOptionalInt(p.CategoryID),
+			formatOptionalInt(p.Stock),
+			strconv.FormatFloat(p.RatingAvg, 'f', -1, 64),
+			strconv.Itoa(p.RatingCount),
+			formatOptionalTime(p.UpdatedAt, loc),
+		}
+	},
+	object: func(p *model.Product, loc *time.Location) interface{} { return newProductResponse(p) },
+}
+
+func formatOptionalTime(t *time.Time, loc *time.Location) string {
+	if t == nil {
+		return ""
+	}
+	return t.In(loc).Format(time.RFC3339)
+}
+
+func formatOptionalInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
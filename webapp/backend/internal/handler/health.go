@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"backend/internal/service"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type HealthHandler struct {
+	HealthSvc *service.HealthService
+}
+
+func NewHealthHandler(healthSvc *service.HealthService) *HealthHandler {
+	return &HealthHandler{HealthSvc: healthSvc}
+}
+
+// プロセスが起動していれば常に 200 を返す
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+// トラフィックを受けられる状態かを返す（unavailable の場合は 503）
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	report := h.HealthSvc.Readiness(ctx)
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status == service.HealthUnavailable {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
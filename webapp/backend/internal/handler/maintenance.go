@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"backend/internal/middleware"
+	"backend/internal/model"
+)
+
+type MaintenanceHandler struct {
+	ReadOnly *middleware.ReadOnlyMode
+}
+
+func NewMaintenanceHandler(readOnly *middleware.ReadOnlyMode) *MaintenanceHandler {
+	return &MaintenanceHandler{ReadOnly: readOnly}
+}
+
+// 現在のメンテナンス状態を取得
+func (h *MaintenanceHandler) Get(w http.ResponseWriter, r *http.Request) {
+	h.write(w)
+}
+
+// 読み取り専用モードを切り替える
+func (h *MaintenanceHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req model.MaintenanceState
+	if err := decodeJSON(w, r, &req, maxDefaultBodyBytes); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	h.ReadOnly.Set(r.Context(), req.ReadOnly)
+	h.write(w)
+}
+
+func (h *MaintenanceHandler) write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.MaintenanceState{ReadOnly: h.ReadOnly.Enabled()})
+}
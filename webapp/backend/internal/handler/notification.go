@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"backend/internal/apierror"
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type NotificationHandler struct {
+	NotificationSvc *service.NotificationService
+}
+
+func NewNotificationHandler(svc *service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{NotificationSvc: svc}
+}
+
+// 自分の通知設定を取得
+func (h *NotificationHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Internal("User not found in context"))
+		return
+	}
+
+	prefs, err := h.NotificationSvc.GetPreferences(r.Context(), userID)
+	if err != nil {
+		writeServerError(w, r, err, "Failed to fetch notification preferences")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
+
+// 自分の通知設定を更新
+func (h *NotificationHandler) Put(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Internal("User not found in context"))
+		return
+	}
+
+	var req model.NotificationPreferences
+	if err := decodeJSON(w, r, &req, maxDefaultBodyBytes); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	prefs, err := h.NotificationSvc.UpdatePreferences(r.Context(), userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidNotificationChannel):
+			apierror.Write(w, r, apierror.Validation("Channels must be log, webhook or email", apierror.FieldError{Field: "channels", Message: "must be log, webhook or email"}))
+		case errors.Is(err, service.ErrInvalidNotificationEmail):
+			apierror.Write(w, r, apierror.Validation("A valid email address is required for the email channel", apierror.FieldError{Field: "email", Message: "must be a valid email address"}))
+		default:
+			writeServerError(w, r, err, "Failed to update notification preferences")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
@@ -1,68 +1,172 @@
 package handler
 
 import (
+	"backend/internal/apierror"
+	"backend/internal/apiversion"
+	"backend/internal/listparams"
 	"backend/internal/middleware"
 	"backend/internal/model"
 	"backend/internal/service"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+
+	"github.com/go-chi/chi/v5"
 )
 
 type OrderHandler struct {
 	OrderSvc *service.OrderService
+	Jobs     *service.OrderJobQueue
 }
 
-func NewOrderHandler(svc *service.OrderService) *OrderHandler {
-	return &OrderHandler{OrderSvc: svc}
+func NewOrderHandler(svc *service.OrderService, jobs *service.OrderJobQueue) *OrderHandler {
+	return &OrderHandler{OrderSvc: svc, Jobs: jobs}
 }
 
 // 注文履歴一覧を取得
 func (h *OrderHandler) List(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "User not found", http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Internal("User not found"))
 		return
 	}
 
-	var req model.ListRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	spec := listparams.Orders
+	if apiversion.FromContext(r.Context()) == apiversion.V1 {
+		spec = listparams.OrdersV1
+	}
+	req, err := decodeListRequest(w, r, spec)
+	if err != nil {
+		writeBodyError(w, r, err)
 		return
 	}
 
-	// デフォルト値の設定
-	if req.Page <= 0 {
-		req.Page = 1
+	// Accept が CSV / NDJSON の場合はページングせずに全件を返す
+	if format := middleware.ExportFormat(r); format != "" {
+		stream := func(fn func(*model.Order) error) error {
+			return h.OrderSvc.StreamOrders(r.Context(), userID, req, fn)
+		}
+		cols := orderExportColumns
+		if publicOrderIDs(r.Context()) {
+			cols = publicOrderExportColumns
+		}
+		writeExport(w, r, format, "orders", cols, stream, func(w http.ResponseWriter, r *http.Request, err error) {
+			if errors.Is(err, service.ErrInvalidSort) {
+				apierror.Write(w, r, apierror.Validation(err.Error()))
+				return
+			}
+			writeServerError(w, r, err, "Failed to export orders")
+		})
+		return
 	}
-	if req.PageSize <= 0 {
-		req.PageSize = 20
+
+	orders, total, err := h.OrderSvc.FetchOrders(r.Context(), userID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSort) {
+			apierror.Write(w, r, apierror.Validation(err.Error()))
+			return
+		}
+		writeServerError(w, r, err, "Failed to fetch orders")
+		return
 	}
-	if req.SortField == "" {
-		req.SortField = "order_id"
+
+	loc := middleware.LocationFromContext(r.Context())
+	var resp interface{} = ListResponse[OrderResponse]{
+		Data:        newOrderResponses(orders, loc),
+		Total:       total.Count,
+		Approximate: total.Approximate,
 	}
-	if req.SortOrder == "" {
-		req.SortOrder = "desc"
+	if apiversion.FromContext(r.Context()) >= apiversion.V2 {
+		resp = PageResponse[PublicOrderResponse]{
+			Data:       newPublicOrderResponses(orders, loc),
+			Pagination: Pagination{Page: req.Page, PageSize: req.PageSize, Total: total.Count, Approximate: total.Approximate},
+		}
 	}
-	if req.Type != "" && req.Type != "partial" && req.Type != "prefix" {
-		req.Type = "partial"
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// 注文を1件取得
+// 所有者の確認は OrderOwnerMiddleware で行う
+func (h *OrderHandler) Get(w http.ResponseWriter, r *http.Request) {
+	orderID, ok := middleware.OrderIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Internal("Order not resolved"))
+		return
 	}
-	// ページネーション用のオフセットを計算
-	req.Offset = (req.Page - 1) * req.PageSize
 
-	orders, total, err := h.OrderSvc.FetchOrders(r.Context(), userID, req)
+	order, err := h.OrderSvc.GetOrder(r.Context(), orderID)
 	if err != nil {
-		http.Error(w, "Failed to fetch orders", http.StatusInternalServerError)
+		if errors.Is(err, service.ErrOrderNotFound) {
+			apierror.Write(w, r, apierror.NotFound("Order not found"))
+			return
+		}
+		writeServerError(w, r, err, "Failed to fetch order")
 		return
 	}
 
-	resp := struct {
-		Data  []model.Order `json:"data"`
-		Total int           `json:"total"`
-	}{
-		Data:  orders,
-		Total: total,
+	loc := middleware.LocationFromContext(r.Context())
+	var resp interface{} = newOrderResponse(order, loc)
+	if publicOrderIDs(r.Context()) {
+		resp = newPublicOrderResponse(order, loc)
 	}
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
+
+// 配送前の注文を取り消す
+// 所有者の確認は OrderOwnerMiddleware で行う
+func (h *OrderHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	orderID, ok := middleware.OrderIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Internal("Order not resolved"))
+		return
+	}
+
+	if err := h.OrderSvc.CancelOrder(r.Context(), orderID); err != nil {
+		if errors.Is(err, service.ErrOrderNotFound) {
+			apierror.Write(w, r, apierror.NotFound("Order not found"))
+			return
+		}
+		if errors.Is(err, service.ErrInvalidStatusTransition) {
+			apierror.Write(w, r, apierror.Conflict("Only orders waiting for shipment can be cancelled").WithCause(err))
+			return
+		}
+		writeServerError(w, r, err, "Failed to cancel order")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 非同期の注文作成ジョブの状態を取得
+func (h *OrderHandler) Job(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Internal("User not found"))
+		return
+	}
+
+	job, err := h.Jobs.Get(userID, chi.URLParam(r, "jobID"))
+	if err != nil {
+		if errors.Is(err, service.ErrOrderJobNotFound) {
+			apierror.Write(w, r, apierror.NotFound("Order job not found"))
+			return
+		}
+		writeServerError(w, r, err, "Failed to fetch order job")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(orderJobResponse(r.Context(), job))
+}
+
+// orderJobResponse は v1 以外では作成済みの注文IDを公開IDにする
+func orderJobResponse(ctx context.Context, job model.OrderJob) model.OrderJob {
+	if publicOrderIDs(ctx) {
+		job.OrderIDs = job.PublicOrderIDs
+	}
+	return job
+}
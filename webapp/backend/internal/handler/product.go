@@ -1,63 +1,224 @@
 package handler
 
 import (
+	"backend/internal/apierror"
+	"backend/internal/apiversion"
+	"backend/internal/imageproc"
+	"backend/internal/listparams"
 	"backend/internal/middleware"
 	"backend/internal/model"
 	"backend/internal/service"
+	"backend/internal/storage"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
-	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+
+	"github.com/go-chi/chi/v5"
 )
 
 type ProductHandler struct {
-	ProductSvc *service.ProductService
+	ProductSvc   *service.ProductService
+	RecommendSvc *service.RecommendationService
+	Images       storage.BlobStore
+	Variants     *imageproc.Processor
+	// ?async=true の注文作成を受け付けるキュー
+	OrderJobs *service.OrderJobQueue
 }
 
-func NewProductHandler(svc *service.ProductService) *ProductHandler {
-	return &ProductHandler{ProductSvc: svc}
+func NewProductHandler(svc *service.ProductService, recommendSvc *service.RecommendationService, images storage.BlobStore, variants *imageproc.Processor) *ProductHandler {
+	return &ProductHandler{ProductSvc: svc, RecommendSvc: recommendSvc, Images: images, Variants: variants}
 }
 
 // 商品一覧を取得
 func (h *ProductHandler) List(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Internal("User not found in context"))
+		return
+	}
+
+	req, err := decodeListRequest(w, r, listparams.Products)
+	if err != nil {
+		writeBodyError(w, r, err)
 		return
 	}
 
-	var req model.ListRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	// Accept が CSV / NDJSON の場合はページングせずに全件を返す（カーソル・ファセットは無視する）
+	if format := middleware.ExportFormat(r); format != "" {
+		stream := func(fn func(*model.Product) error) error {
+			return h.ProductSvc.StreamProducts(r.Context(), req, fn)
+		}
+		writeExport(w, r, format, "products", productExportColumns, stream, func(w http.ResponseWriter, r *http.Request, err error) {
+			if errors.Is(err, service.ErrInvalidSort) {
+				apierror.Write(w, r, apierror.Validation(err.Error()))
+				return
+			}
+			writeServerError(w, r, err, "Failed to export products")
+		})
+		return
+	}
+
+	products, total, err := h.ProductSvc.FetchProducts(r.Context(), userID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSort) || errors.Is(err, service.ErrInvalidCursor) {
+			apierror.Write(w, r, apierror.Validation(err.Error()))
+			return
+		}
+		writeServerError(w, r, err, "Failed to fetch products")
 		return
 	}
 
-	if req.Page <= 0 {
-		req.Page = 1
+	var facets *model.Facets
+	if req.Facets {
+		facets, err = h.ProductSvc.FetchFacets(r.Context(), req)
+		if err != nil {
+			writeServerError(w, r, err, "Failed to fetch facets")
+			return
+		}
 	}
-	if req.PageSize <= 0 {
-		req.PageSize = 20
+
+	nextCursor := h.ProductSvc.NextProductCursor(userID, req, products)
+	var resp interface{} = ProductListResponse{
+		Data:        products,
+		Total:       total.Count,
+		NextCursor:  nextCursor,
+		Facets:      facets,
+		Approximate: total.Approximate,
 	}
-	if req.SortField == "" {
-		req.SortField = "product_id"
+	if apiversion.FromContext(r.Context()) >= apiversion.V2 {
+		resp = ProductPageResponse{
+			Data:       newProductResponses(products),
+			Pagination: Pagination{Page: req.Page, PageSize: req.PageSize, Total: total.Count, NextCursor: nextCursor, Approximate: total.Approximate},
+			Facets:     facets,
+		}
 	}
-	if req.SortOrder == "" {
-		req.SortOrder = "asc"
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// 商品名の入力補完候補を取得
+func (h *ProductHandler) Suggest(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	limit := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		v, err := strconv.Atoi(l)
+		if err != nil {
+			apierror.Write(w, r, apierror.Validation("Query parameter 'limit' must be an integer", apierror.FieldError{Field: "limit", Message: "must be an integer"}))
+			return
+		}
+		limit = v
 	}
-	req.Offset = (req.Page - 1) * req.PageSize
 
-	products, total, err := h.ProductSvc.FetchProducts(r.Context(), userID, req)
+	suggestions, err := h.ProductSvc.SuggestProducts(r.Context(), q, limit)
+	if err != nil {
+		writeServerError(w, r, err, "Failed to fetch suggestions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SuggestResponse{Suggestions: suggestions})
+}
+
+// 一緒に注文されることの多い商品を取得
+func (h *ProductHandler) Related(w http.ResponseWriter, r *http.Request) {
+	productID, ok := productIDFromURL(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid product id"))
+		return
+	}
+
+	products, err := h.RecommendSvc.FetchRelated(r.Context(), productID)
+	if err != nil {
+		writeServerError(w, r, err, "Failed to fetch related products")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DataResponse[ProductResponse]{Data: newProductResponses(products)})
+}
+
+// 商品の価値の変更履歴を取得
+func (h *ProductHandler) PriceHistory(w http.ResponseWriter, r *http.Request) {
+	productID, ok := productIDFromURL(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid product id"))
+		return
+	}
+
+	history, err := h.ProductSvc.FetchPriceHistory(r.Context(), productID)
 	if err != nil {
-		http.Error(w, "Failed to fetch products", http.StatusInternalServerError)
+		writeServerError(w, r, err, "Failed to fetch price history")
 		return
 	}
 
-	resp := struct {
-		Data  []model.Product `json:"data"`
-		Total int             `json:"total"`
-	}{
-		Data:  products,
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DataResponse[model.PriceHistory]{Data: history})
+}
+
+// お気に入りに追加
+func (h *ProductHandler) AddFavorite(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Internal("User not found in context"))
+		return
+	}
+	productID, ok := productIDFromURL(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid product id"))
+		return
+	}
+
+	if err := h.ProductSvc.AddFavorite(r.Context(), userID, productID); err != nil {
+		writeProductError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// お気に入りから削除
+func (h *ProductHandler) RemoveFavorite(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Internal("User not found in context"))
+		return
+	}
+	productID, ok := productIDFromURL(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid product id"))
+		return
+	}
+
+	if err := h.ProductSvc.RemoveFavorite(r.Context(), userID, productID); err != nil {
+		writeProductError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// お気に入り一覧を取得
+func (h *ProductHandler) ListFavorites(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Internal("User not found in context"))
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+
+	products, total, err := h.ProductSvc.FetchFavorites(r.Context(), userID, page, pageSize)
+	if err != nil {
+		writeServerError(w, r, err, "Failed to fetch favorites")
+		return
+	}
+
+	resp := ListResponse[ProductResponse]{
+		Data:  newProductResponses(products),
 		Total: total,
 	}
 
@@ -65,77 +226,289 @@ func (h *ProductHandler) List(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+// カテゴリ一覧を取得
+func (h *ProductHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.ProductSvc.FetchCategories(r.Context())
+	if err != nil {
+		writeServerError(w, r, err, "Failed to fetch categories")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(categories)
+}
+
 // 注文を作成
 func (h *ProductHandler) CreateOrders(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Internal("User not found in context"))
 		return
 	}
 
 	var req model.CreateOrderRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(w, r, &req, maxOrderBodyBytes); err != nil {
+		writeBodyError(w, r, err)
 		return
 	}
 
-	insertedOrderIDs, err := h.ProductSvc.CreateOrders(r.Context(), userID, req.Items)
+	// 大量の注文はジョブとして受け付け、作成の状況は GET /orders/jobs/{jobID} で確認させる
+	if r.URL.Query().Get("async") == "true" {
+		job, err := h.OrderJobs.Enqueue(r.Context(), userID, req.Items)
+		if err != nil {
+			if errors.Is(err, service.ErrOrderJobQueueFull) {
+				w.Header().Set("Retry-After", "1")
+				apierror.Write(w, r, apierror.Unavailable("Too many pending order jobs"))
+				return
+			}
+			writeServerError(w, r, err, "Failed to enqueue order request")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", apiversion.FromContext(r.Context()).Prefix()+"/orders/jobs/"+job.JobID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(orderJobResponse(r.Context(), job))
+		return
+	}
+
+	inserted, err := h.ProductSvc.CreateOrders(r.Context(), userID, req.Items)
 	if err != nil {
-		http.Error(w, "Failed to process order request", http.StatusInternalServerError)
+		if errors.Is(err, service.ErrInsufficientStock) {
+			apierror.Write(w, r, apierror.Conflict("Insufficient stock"))
+			return
+		}
+		writeServerError(w, r, err, "Failed to process order request")
 		return
 	}
 
-	response := map[string]interface{}{
-		"message":   "Orders created successfully",
-		"order_ids": insertedOrderIDs,
+	response := OrdersCreatedResponse{
+		Message:  "Orders created successfully",
+		OrderIDs: createdOrderIDs(inserted, publicOrderIDs(r.Context())),
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(response)
 }
 
+// 画像パスを指定して画像を取得
 func (h *ProductHandler) GetImage(w http.ResponseWriter, r *http.Request) {
 	imagePath := r.URL.Query().Get("path")
 	if imagePath == "" {
-		http.Error(w, "画像パスが指定されていません", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.BadRequest("画像パスが指定されていません"))
 		return
 	}
+	h.serveImage(w, r, imagePath)
+}
 
-	imagePath = filepath.Clean(imagePath)
-	if filepath.IsAbs(imagePath) || strings.Contains(imagePath, "..") {
-		http.Error(w, "無効なパスです", http.StatusBadRequest)
+// 商品IDを指定して画像を取得
+// ETag / Last-Modified による条件付きリクエストに対応する（Cache-Control はルートの middleware.CacheMiddleware で付ける）
+// ?size=thumb|detail で縮小版を返す
+func (h *ProductHandler) GetProductImage(w http.ResponseWriter, r *http.Request) {
+	productID, ok := productIDFromURL(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid product id"))
 		return
 	}
 
-	baseImageDir := "/app/images"
-	fullPath := filepath.Join(baseImageDir, imagePath)
+	imagePath, err := h.ProductSvc.GetProductImage(r.Context(), productID)
+	if err != nil {
+		if errors.Is(err, service.ErrProductNotFound) {
+			apierror.Write(w, r, apierror.NotFound("画像が見つかりません"))
+			return
+		}
+		writeServerError(w, r, err, "画像の読み込みに失敗しました")
+		return
+	}
+	h.serveImage(w, r, imagePath)
+}
 
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		http.Error(w, "画像が見つかりません", http.StatusNotFound)
+func (h *ProductHandler) serveImage(w http.ResponseWriter, r *http.Request, imagePath string) {
+	size, err := imageproc.ParseSize(r.URL.Query().Get("size"))
+	if err != nil {
+		apierror.Write(w, r, apierror.Validation("Invalid size", apierror.FieldError{Field: "size", Message: "is not a supported size"}))
 		return
 	}
 
-	ext := filepath.Ext(fullPath)
-	var contentType string
-	switch strings.ToLower(ext) {
+	var blob *storage.Blob
+	contentType := imageContentType(imagePath)
+	if size != imageproc.SizeOriginal && h.Variants != nil {
+		blob, contentType, err = h.Variants.Variant(r.Context(), imagePath, size)
+		// 縮小できない画像は元画像をそのまま返す
+		if errors.Is(err, imageproc.ErrNotResizable) {
+			size = imageproc.SizeOriginal
+			contentType = imageContentType(imagePath)
+			err = nil
+		}
+	}
+	if blob == nil && err == nil {
+		blob, err = h.Images.Get(r.Context(), imagePath)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrInvalidKey):
+			apierror.Write(w, r, apierror.BadRequest("無効なパスです"))
+		case errors.Is(err, storage.ErrBlobNotFound):
+			apierror.Write(w, r, apierror.NotFound("画像が見つかりません"))
+		default:
+			writeServerError(w, r, err, "画像の読み込みに失敗しました")
+		}
+		return
+	}
+	defer blob.Content.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", fmt.Sprintf(`W/"%x-%x%s"`, blob.Size, blob.ModTime.UnixNano(), size))
+	http.ServeContent(w, r, "", blob.ModTime, blob.Content)
+}
+
+func imageContentType(imagePath string) string {
+	switch strings.ToLower(filepath.Ext(imagePath)) {
 	case ".jpg", ".jpeg":
-		contentType = "image/jpeg"
+		return "image/jpeg"
 	case ".png":
-		contentType = "image/png"
+		return "image/png"
 	case ".gif":
-		contentType = "image/gif"
+		return "image/gif"
 	case ".webp":
-		contentType = "image/webp"
+		return "image/webp"
 	default:
-		contentType = "application/octet-stream"
+		return "application/octet-stream"
 	}
-	w.Header().Set("Content-Type", contentType)
+}
 
-	data, err := os.ReadFile(fullPath)
+// URLパラメータから商品IDを取得
+func productIDFromURL(r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "productID"))
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+// 商品サービスのエラーをHTTPレスポンスに変換
+func writeProductError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidProduct):
+		apierror.Write(w, r, apierror.Validation(err.Error()))
+	case errors.Is(err, service.ErrProductNotFound):
+		apierror.Write(w, r, apierror.NotFound("Product not found"))
+	default:
+		writeServerError(w, r, err, "Failed to process product request")
+	}
+}
+
+// 商品を作成（管理者用）
+func (h *ProductHandler) AdminCreate(w http.ResponseWriter, r *http.Request) {
+	var req model.ProductInput
+	if err := decodeJSON(w, r, &req, maxDefaultBodyBytes); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	product, err := h.ProductSvc.CreateProduct(r.Context(), req)
 	if err != nil {
-		http.Error(w, "画像の読み込みに失敗しました", http.StatusInternalServerError)
+		writeProductError(w, r, err)
 		return
 	}
 
-	w.Write(data)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newProductResponse(product))
+}
+
+// 商品を更新（管理者用）
+func (h *ProductHandler) AdminUpdate(w http.ResponseWriter, r *http.Request) {
+	productID, ok := productIDFromURL(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid product id"))
+		return
+	}
+
+	var req model.ProductInput
+	if err := decodeJSON(w, r, &req, maxDefaultBodyBytes); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	userID, _ := middleware.GetUserFromContext(r.Context())
+	product, err := h.ProductSvc.UpdateProduct(r.Context(), userID, productID, req)
+	if err != nil {
+		writeProductError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newProductResponse(product))
+}
+
+// 商品を削除（管理者用）
+func (h *ProductHandler) AdminDelete(w http.ResponseWriter, r *http.Request) {
+	productID, ok := productIDFromURL(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid product id"))
+		return
+	}
+
+	if err := h.ProductSvc.DeleteProduct(r.Context(), productID); err != nil {
+		writeProductError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CSVから商品を一括登録（管理者用）
+// multipart/form-data の file フィールド、またはリクエストボディそのものをCSVとして受け付ける
+func (h *ProductHandler) AdminImport(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBodyBytes)
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest("Invalid multipart body"))
+			return
+		}
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				apierror.Write(w, r, apierror.BadRequest("Form field 'file' is required"))
+				return
+			}
+			if part.FormName() == "file" {
+				body = part
+				break
+			}
+		}
+	}
+
+	userID, _ := middleware.GetUserFromContext(r.Context())
+	report, err := h.ProductSvc.ImportProducts(r.Context(), userID, body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeBodyError(w, r, err)
+			return
+		}
+		if errors.Is(err, service.ErrInvalidCSVHeader) {
+			apierror.Write(w, r, apierror.Validation(err.Error()))
+			return
+		}
+		writeServerError(w, r, err, "Failed to import products")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// 在庫が閾値未満の商品一覧を取得（管理者用）
+func (h *ProductHandler) AdminLowStock(w http.ResponseWriter, r *http.Request) {
+	levels, err := h.ProductSvc.FetchLowStock(r.Context())
+	if err != nil {
+		writeServerError(w, r, err, "Failed to fetch low-stock products")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DataResponse[model.StockLevel]{Data: levels})
 }
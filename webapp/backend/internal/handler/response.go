@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"backend/internal/apiversion"
+	"backend/internal/model"
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// 一覧APIのレスポンス（ページングの総件数付き）。v2 では PageResponse を使う
+type ListResponse[T any] struct {
+	Data  []T `json:"data"`
+	Total int `json:"total"`
+	// Total がキャッシュの古い値（再計算中）の場合 true
+	Approximate bool `json:"approximate,omitempty"`
+}
+
+// 商品一覧のレスポンス
+type ProductListResponse struct {
+	Data       []model.Product `json:"data"`
+	Total      int             `json:"total"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	Facets     *model.Facets   `json:"facets,omitempty"`
+	// Total がキャッシュの古い値（再計算中）の場合 true
+	Approximate bool `json:"approximate,omitempty"`
+}
+
+// v2 の一覧APIのページング情報
+type Pagination struct {
+	Page       int    `json:"page"`
+	PageSize   int    `json:"page_size"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Total がキャッシュの古い値（再計算中）の場合 true
+	Approximate bool `json:"approximate,omitempty"`
+}
+
+// v2 の一覧APIのレスポンス
+type PageResponse[T any] struct {
+	Data       []T        `json:"data"`
+	Pagination Pagination `json:"pagination"`
+}
+
+// v2 の商品一覧のレスポンス
+type ProductPageResponse struct {
+	Data       []ProductResponse `json:"data"`
+	Pagination Pagination        `json:"pagination"`
+	Facets     *model.Facets     `json:"facets,omitempty"`
+}
+
+// v2・管理者APIの商品のレスポンス。v1 は互換のため model.Product をそのまま返す（currency やカテゴリ・評価・在庫などを含まない）
+type ProductResponse struct {
+	model.Product
+	Currency    string     `json:"currency"`
+	CategoryID  *int       `json:"category_id,omitempty"`
+	RatingAvg   float64    `json:"rating_avg,omitempty"`
+	RatingCount int        `json:"rating_count,omitempty"`
+	Stock       *int       `json:"stock,omitempty"`
+	Favorited   bool       `json:"favorited,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+func newProductResponse(p *model.Product) ProductResponse {
+	return ProductResponse{
+		Product:     *p,
+		Currency:    p.Currency,
+		CategoryID:  p.CategoryID,
+		RatingAvg:   p.RatingAvg,
+		RatingCount: p.RatingCount,
+		Stock:       p.Stock,
+		Favorited:   p.Favorited,
+		UpdatedAt:   p.UpdatedAt,
+	}
+}
+
+func newProductResponses(products []model.Product) []ProductResponse {
+	resp := make([]ProductResponse, len(products))
+	for i := range products {
+		resp[i] = newProductResponse(&products[i])
+	}
+	return resp
+}
+
+// 件数を伴わない一覧のレスポンス
+type DataResponse[T any] struct {
+	Data []T `json:"data"`
+}
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// 注文作成のレスポンス
+type OrdersCreatedResponse struct {
+	Message  string   `json:"message"`
+	OrderIDs []string `json:"order_ids"`
+}
+
+// publicOrderIDs は v1 以外のバージョン付きのルートで公開IDを返すか（v1 は互換のため order_id を返す）
+func publicOrderIDs(ctx context.Context) bool {
+	return apiversion.FromContext(ctx) >= apiversion.V2
+}
+
+// createdOrderIDs は作成した注文のIDを返す。public が false の場合は order_id を返す
+func createdOrderIDs(orders []model.Order, public bool) []string {
+	ids := make([]string, len(orders))
+	for i := range orders {
+		if public {
+			ids[i] = orders[i].ExternalID()
+		} else {
+			ids[i] = strconv.FormatInt(orders[i].OrderID, 10)
+		}
+	}
+	return ids
+}
+
+type SuggestResponse struct {
+	Suggestions []string `json:"suggestions"`
+}
+
+// 注文のレスポンス（v1）
+// v1 は互換のため、arrived_at を sql.NullTime の形（{"Time", "Valid"}）のまま返す。v2 は PublicOrderResponse を使う
+type OrderResponse struct {
+	OrderID       int64               `json:"order_id"`
+	UserID        int                 `json:"user_id"`
+	ProductID     int                 `json:"product_id"`
+	ProductName   string              `json:"product_name"`
+	ShippedStatus model.ShippedStatus `json:"shipped_status"`
+	Weight        int                 `json:"weight"`
+	Value         int                 `json:"value"`
+	CreatedAt     time.Time           `json:"created_at"`
+	ArrivedAt     sql.NullTime        `json:"arrived_at"`
+}
+
+// newOrderResponse は日時を loc で表した注文のレスポンスを返す（DB の値は UTC）
+func newOrderResponse(o *model.Order, loc *time.Location) OrderResponse {
+	resp := OrderResponse{
+		OrderID:       o.OrderID,
+		UserID:        o.UserID,
+		ProductID:     o.ProductID,
+		ProductName:   o.ProductName,
+		ShippedStatus: o.ShippedStatus,
+		Weight:        o.Weight,
+		Value:         o.Value,
+		CreatedAt:     o.CreatedAt.In(loc),
+		ArrivedAt:     o.ArrivedAt,
+	}
+	if o.ArrivedAt.Valid {
+		resp.ArrivedAt.Time = o.ArrivedAt.Time.In(loc)
+	}
+	return resp
+}
+
+func newOrderResponses(orders []model.Order, loc *time.Location) []OrderResponse {
+	resp := make([]OrderResponse, len(orders))
+	for i := range orders {
+		resp[i] = newOrderResponse(&orders[i], loc)
+	}
+	return resp
+}
+
+// 配送計画の注文のレスポンス。v1 と同じ形で、order_id だけを公開ID（ULID）にする
+// encoding/json は浅い階層のフィールドを優先するため、埋め込んだ OrderResponse の order_id は出力されない
+type PlanOrderResponse struct {
+	OrderResponse
+	OrderID string `json:"order_id"`
+}
+
+func newPlanOrderResponses(orders []model.Order) []PlanOrderResponse {
+	resp := make([]PlanOrderResponse, len(orders))
+	for i := range orders {
+		resp[i] = PlanOrderResponse{OrderResponse: newOrderResponse(&orders[i], time.UTC), OrderID: orders[i].ExternalID()}
+	}
+	return resp
+}
+
+// v2 の注文のレスポンス
+// order_id は公開ID、arrived_at は null または ISO 8601 の文字列で、最終更新日時を含める
+type PublicOrderResponse struct {
+	OrderID       string              `json:"order_id"`
+	UserID        int                 `json:"user_id"`
+	ProductID     int                 `json:"product_id"`
+	ProductName   string              `json:"product_name"`
+	ShippedStatus model.ShippedStatus `json:"shipped_status"`
+	Weight        int                 `json:"weight"`
+	Value         int                 `json:"value"`
+	Currency      string              `json:"currency,omitempty"`
+	CreatedAt     time.Time           `json:"created_at"`
+	// 配送完了日時（未完了の場合は null）
+	ArrivedAt *time.Time `json:"arrived_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+func newPublicOrderResponse(o *model.Order, loc *time.Location) PublicOrderResponse {
+	resp := PublicOrderResponse{
+		OrderID:       o.ExternalID(),
+		UserID:        o.UserID,
+		ProductID:     o.ProductID,
+		ProductName:   o.ProductName,
+		ShippedStatus: o.ShippedStatus,
+		Weight:        o.Weight,
+		Value:         o.Value,
+		Currency:      o.Currency,
+		CreatedAt:     o.CreatedAt.In(loc),
+	}
+	if o.ArrivedAt.Valid {
+		arrivedAt := o.ArrivedAt.Time.In(loc)
+		resp.ArrivedAt = &arrivedAt
+	}
+	if o.UpdatedAt != nil {
+		updatedAt := o.UpdatedAt.In(loc)
+		resp.UpdatedAt = &updatedAt
+	}
+	return resp
+}
+
+func newPublicOrderResponses(orders []model.Order, loc *time.Location) []PublicOrderResponse {
+	resp := make([]PublicOrderResponse, len(orders))
+	for i := range orders {
+		resp[i] = newPublicOrderResponse(&orders[i], loc)
+	}
+	return resp
+}
+
+// 配送計画のレスポンス
+// ロボットのAPIはバージョンを持たず v1 の形を保つため、通貨は含めない（1つの計画の注文は同じ通貨にそろう。service.selectPlanAcrossCurrencies を参照）
+type DeliveryPlanResponse struct {
+	RobotID     string              `json:"robot_id"`
+	TotalWeight int                 `json:"total_weight"`
+	TotalValue  int                 `json:"total_value"`
+	Orders      []PlanOrderResponse `json:"orders"`
+}
+
+func newDeliveryPlanResponse(plan *model.DeliveryPlan) DeliveryPlanResponse {
+	return DeliveryPlanResponse{
+		RobotID:     plan.RobotID,
+		TotalWeight: plan.TotalWeight,
+		TotalValue:  plan.TotalValue,
+		Orders:      newPlanOrderResponses(plan.Orders),
+	}
+}
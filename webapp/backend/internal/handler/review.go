@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"backend/internal/apierror"
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+type ReviewHandler struct {
+	ReviewSvc *service.ReviewService
+}
+
+func NewReviewHandler(svc *service.ReviewService) *ReviewHandler {
+	return &ReviewHandler{ReviewSvc: svc}
+}
+
+// レビューを投稿
+func (h *ReviewHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Internal("User not found in context"))
+		return
+	}
+	productID, ok := productIDFromURL(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid product id"))
+		return
+	}
+
+	var req model.CreateReviewRequest
+	if err := decodeJSON(w, r, &req, maxDefaultBodyBytes); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	review, err := h.ReviewSvc.CreateReview(r.Context(), userID, productID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidReview):
+			apierror.Write(w, r, apierror.Validation("Rating must be between 1 and 5", apierror.FieldError{Field: "rating", Message: "must be between 1 and 5"}))
+		case errors.Is(err, service.ErrProductNotFound):
+			apierror.Write(w, r, apierror.NotFound("Product not found"))
+		default:
+			writeServerError(w, r, err, "Failed to create review")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(review)
+}
+
+// レビュー一覧を取得
+func (h *ReviewHandler) List(w http.ResponseWriter, r *http.Request) {
+	productID, ok := productIDFromURL(r)
+	if !ok {
+		apierror.Write(w, r, apierror.BadRequest("Invalid product id"))
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+
+	reviews, total, err := h.ReviewSvc.FetchReviews(r.Context(), productID, page, pageSize)
+	if err != nil {
+		writeServerError(w, r, err, "Failed to fetch reviews")
+		return
+	}
+
+	resp := ListResponse[model.Review]{
+		Data:  reviews,
+		Total: total,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
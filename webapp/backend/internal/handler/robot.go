@@ -1,13 +1,19 @@
 package handler
 
 import (
+	"backend/internal/apierror"
 	"backend/internal/model"
 	"backend/internal/service"
+	"backend/internal/validation"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 )
 
+// API キーが1つのため、ロボットは1台として扱う
+const robotID = "robot-001"
+
 type RobotHandler struct {
 	RobotSvc *service.RobotService
 }
@@ -18,43 +24,79 @@ func NewRobotHandler(robotSvc *service.RobotService) *RobotHandler {
 
 // 配送計画を取得
 func (h *RobotHandler) GetDeliveryPlan(w http.ResponseWriter, r *http.Request) {
-	robotID := "robot-001"
-
 	capacityStr := r.URL.Query().Get("capacity")
 	if capacityStr == "" {
-		http.Error(w, "Query parameter 'capacity' is required", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Validation("Query parameter 'capacity' is required", apierror.FieldError{Field: "capacity", Message: "is required"}))
 		return
 	}
 	capacity, err := strconv.Atoi(capacityStr)
 	if err != nil {
-		http.Error(w, "Query parameter 'capacity' must be an integer", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Validation("Query parameter 'capacity' must be an integer", apierror.FieldError{Field: "capacity", Message: "must be an integer"}))
+		return
+	}
+	query := model.DeliveryPlanQuery{Capacity: capacity}
+	if err := validation.Struct(query); err != nil {
+		writeBodyError(w, r, err)
 		return
 	}
 
-	plan, err := h.RobotSvc.GenerateDeliveryPlan(r.Context(), robotID, capacity)
+	plan, err := h.RobotSvc.GenerateDeliveryPlan(r.Context(), robotID, query.Capacity)
 	if err != nil {
-		http.Error(w, "Failed to create delivery plan", http.StatusInternalServerError)
+		if errors.Is(err, service.ErrPlannerSaturated) {
+			retryAfter := int(h.RobotSvc.RetryAfter().Seconds())
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			apierror.Write(w, r, apierror.Unavailable("Delivery planner is busy"))
+			return
+		}
+		writeServerError(w, r, err, "Failed to create delivery plan")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(plan)
+	json.NewEncoder(w).Encode(newDeliveryPlanResponse(plan))
 }
 
 // 配送完了時に注文ステータスを更新
 func (h *RobotHandler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 	var req model.UpdateOrderStatusRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(w, r, &req, maxDefaultBodyBytes); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if req.OrderID.IsZero() {
+		apierror.Write(w, r, apierror.Validation("order_id is required", apierror.FieldError{Field: "order_id", Message: "is required"}))
 		return
 	}
 
 	err := h.RobotSvc.UpdateOrderStatus(r.Context(), req.OrderID, req.NewStatus)
 	if err != nil {
-		http.Error(w, "Failed to update order status", http.StatusInternalServerError)
+		if errors.Is(err, service.ErrOrderNotFound) {
+			apierror.Write(w, r, apierror.NotFound("Order not found"))
+			return
+		}
+		if errors.Is(err, service.ErrInvalidStatusTransition) {
+			apierror.Write(w, r, apierror.Conflict("Order status cannot be changed to "+req.NewStatus.String()).WithCause(err))
+			return
+		}
+		writeServerError(w, r, err, "Failed to update order status")
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Order status updated"))
 }
+
+// ロボットの現在位置を受け取り、管理ダッシュボードへ通知する
+func (h *RobotHandler) ReportPosition(w http.ResponseWriter, r *http.Request) {
+	var req model.RobotPositionRequest
+	if err := decodeJSON(w, r, &req, maxDefaultBodyBytes); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	h.RobotSvc.ReportPosition(r.Context(), robotID, req)
+	w.WriteHeader(http.StatusNoContent)
+}
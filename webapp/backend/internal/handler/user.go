@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"backend/internal/apierror"
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type UserHandler struct {
+	UserSvc *service.UserService
+}
+
+func NewUserHandler(svc *service.UserService) *UserHandler {
+	return &UserHandler{UserSvc: svc}
+}
+
+// 自分の表示設定を取得
+func (h *UserHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Internal("User not found in context"))
+		return
+	}
+
+	prefs, err := h.UserSvc.GetPreferences(r.Context(), userID)
+	if err != nil {
+		writeServerError(w, r, err, "Failed to fetch preferences")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
+
+// 自分の表示設定を更新
+func (h *UserHandler) PutPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Internal("User not found in context"))
+		return
+	}
+
+	var req model.UserPreferences
+	if err := decodeJSON(w, r, &req, maxDefaultBodyBytes); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	prefs, err := h.UserSvc.UpdatePreferences(r.Context(), userID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTimezone) {
+			apierror.Write(w, r, apierror.Validation("Timezone must be an IANA time zone name", apierror.FieldError{Field: "timezone", Message: "must be an IANA time zone name"}))
+			return
+		}
+		writeServerError(w, r, err, "Failed to update preferences")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
@@ -0,0 +1,266 @@
+package imageproc
+
+import (
+	"backend/internal/storage"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	_ "image/gif"
+)
+
+var (
+	ErrInvalidSize = errors.New("invalid image size")
+	// 縮小の必要がない、デコードできない形式、または画素数が多すぎる場合に返す（呼び出し側は元画像を返す）
+	ErrNotResizable = errors.New("image is not resizable")
+)
+
+// Size は画像のサイズバリアント
+type Size string
+
+const (
+	SizeOriginal  Size = ""
+	SizeThumbnail Size = "thumb"
+	SizeDetail    Size = "detail"
+)
+
+// 各バリアントの長辺の最大ピクセル数
+var maxEdges = map[Size]int{
+	SizeThumbnail: 200,
+	SizeDetail:    800,
+}
+
+const jpegQuality = 80
+
+// デコードする元画像の画素数の上限（約 4000 万画素。RGBA で約 160MB）
+// 小さなファイルに巨大な寸法を書いた画像（展開爆弾）でメモリを使い切らないよう、ヘッダーの寸法で先に判定する
+const maxSourcePixels = 40_000_000
+
+// クエリパラメータの size を解釈する（空文字と "original" は元画像）
+func ParseSize(s string) (Size, error) {
+	switch s {
+	case "", "original":
+		return SizeOriginal, nil
+	case string(SizeThumbnail), string(SizeDetail):
+		return Size(s), nil
+	default:
+		return SizeOriginal, ErrInvalidSize
+	}
+}
+
+// Processor はリサイズ済み画像を初回リクエスト時に生成し、ディスクにキャッシュする
+type Processor struct {
+	source   storage.BlobStore
+	cacheDir string
+
+	mu       sync.Mutex
+	inflight map[string]*sync.Mutex
+	// 縮小できなかったバリアントのパスと、その時点の元画像の更新日時
+	// 小さい画像やデコードできない画像を、リクエストのたびにデコードし直さないようにする（元画像が更新されれば再判定する）
+	notResizable map[string]time.Time
+}
+
+func NewProcessor(source storage.BlobStore, cacheDir string) *Processor {
+	return &Processor{source: source, cacheDir: cacheDir, inflight: make(map[string]*sync.Mutex), notResizable: make(map[string]time.Time)}
+}
+
+// Variant は key の画像を size に縮小したものを返す
+// 戻り値の文字列はバリアントの Content-Type
+func (p *Processor) Variant(ctx context.Context, key string, size Size) (*storage.Blob, string, error) {
+	maxEdge, ok := maxEdges[size]
+	if !ok {
+		return nil, "", ErrInvalidSize
+	}
+
+	src, err := p.source.Get(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	defer src.Content.Close()
+
+	format := outputFormat(key)
+	if format == "" {
+		return nil, "", ErrNotResizable
+	}
+	cachePath := filepath.Join(p.cacheDir, string(size), filepath.Clean(key)+"."+format)
+	if p.knownNotResizable(cachePath, src.ModTime) {
+		return nil, "", ErrNotResizable
+	}
+
+	// 同じバリアントの同時生成を防ぐ
+	lock := p.lockFor(cachePath)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// 元画像より新しいキャッシュがあればそれを返す
+	if blob, err := openCached(cachePath, src); err == nil {
+		return blob, contentType(format), nil
+	}
+
+	// ヘッダーだけを読んで寸法を確かめてから、読んだ分と残りを続けて全体をデコードする
+	var header bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(src.Content, &header))
+	if err != nil || (cfg.Width <= maxEdge && cfg.Height <= maxEdge) || int64(cfg.Width)*int64(cfg.Height) > maxSourcePixels {
+		p.markNotResizable(cachePath, src.ModTime)
+		return nil, "", ErrNotResizable
+	}
+	img, _, err := image.Decode(io.MultiReader(&header, src.Content))
+	if err != nil {
+		p.markNotResizable(cachePath, src.ModTime)
+		return nil, "", ErrNotResizable
+	}
+
+	var buf bytes.Buffer
+	resized := resize(img, maxEdge)
+	if format == "jpg" {
+		err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&buf, resized)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("encode variant: %w", err)
+	}
+	if err := writeAtomic(cachePath, buf.Bytes()); err != nil {
+		return nil, "", err
+	}
+
+	blob, err := openCached(cachePath, src)
+	if err != nil {
+		return nil, "", err
+	}
+	return blob, contentType(format), nil
+}
+
+func (p *Processor) lockFor(path string) *sync.Mutex {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	lock, ok := p.inflight[path]
+	if !ok {
+		lock = &sync.Mutex{}
+		p.inflight[path] = lock
+	}
+	return lock
+}
+
+// 元画像が modTime のまま縮小できないと分かっているか
+func (p *Processor) knownNotResizable(path string, modTime time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	at, ok := p.notResizable[path]
+	return ok && at.Equal(modTime)
+}
+
+func (p *Processor) markNotResizable(path string, modTime time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.notResizable[path] = modTime
+}
+
+// JPEGはJPEGのまま、それ以外のデコード可能な形式はPNGで出力する
+func outputFormat(key string) string {
+	switch strings.ToLower(filepath.Ext(key)) {
+	case ".jpg", ".jpeg":
+		return "jpg"
+	case ".png", ".gif":
+		return "png"
+	default:
+		return ""
+	}
+}
+
+func contentType(format string) string {
+	if format == "jpg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+func openCached(path string, src *storage.Blob) (*storage.Blob, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.ModTime().Before(src.ModTime) {
+		f.Close()
+		return nil, os.ErrNotExist
+	}
+	return &storage.Blob{Content: f, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// 一時ファイルに書き出してからリネームし、書きかけのファイルを配信しないようにする
+func writeAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".variant-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, bytes.NewReader(data)); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// 長辺が maxEdge になるよう、面積平均法で縮小する
+func resize(img image.Image, maxEdge int) image.Image {
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+	dstW, dstH := maxEdge, maxEdge
+	if srcW >= srcH {
+		dstH = max(1, srcH*maxEdge/srcW)
+	} else {
+		dstW = max(1, srcW*maxEdge/srcH)
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, srcW, srcH))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0, y1 := y*srcH/dstH, max((y+1)*srcH/dstH, y*srcH/dstH+1)
+		for x := 0; x < dstW; x++ {
+			x0, x1 := x*srcW/dstW, max((x+1)*srcW/dstW, x*srcW/dstW+1)
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					i := sx * 4
+					r += uint32(row[i])
+					g += uint32(row[i+1])
+					bl += uint32(row[i+2])
+					a += uint32(row[i+3])
+					n++
+				}
+			}
+			j := y*dst.Stride + x*4
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(bl / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
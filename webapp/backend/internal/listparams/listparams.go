@@ -0,0 +1,147 @@
+// Package listparams は一覧 API の条件（model.ListRequest）を組み立てて検証する
+//
+// REST（ボディとクエリパラメータ）と GraphQL で同じデフォルト値と上限を使うため、
+// ハンドラやリポジトリで個別に補わず、ここで未指定の値を埋めて不正な値は validation.Errors として返す
+package listparams
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"backend/internal/model"
+	"backend/internal/validation"
+)
+
+// DefaultPageSize は page_size 未指定時の件数（上限は model.ListRequest の validate タグで 100 件）
+const DefaultPageSize = 20
+
+// Spec は一覧ごとのソートのデフォルト値と許可するソート列
+// SortFields は各リポジトリのソート定義（querybuilder.Sort）と揃えること
+type Spec struct {
+	SortFields   []string
+	DefaultSort  string
+	DefaultOrder string
+	// Aliases は互換のため受け付ける SortFields 以外の値と、その代わりに使うソート列
+	Aliases map[string]string
+}
+
+var (
+	// relevance は全文検索時のみ関連度順になり、それ以外は商品ID順で代替される
+	Products = Spec{
+		SortFields:   []string{"product_id", "name", "value", "weight", "relevance"},
+		DefaultSort:  "product_id",
+		DefaultOrder: "asc",
+	}
+	Orders = Spec{
+		SortFields:   []string{"order_id", "product_name", "created_at", "shipped_status", "arrived_at"},
+		DefaultSort:  "order_id",
+		DefaultOrder: "desc",
+	}
+	// v1 の注文一覧は互換のため name を受け付ける
+	// 以前は許可されていないソート列を order_id 順として扱っており、既存のクライアント（ベンチマーカーなど）が name を送るため、同じ並びにする
+	OrdersV1 = Spec{
+		SortFields:   Orders.SortFields,
+		DefaultSort:  Orders.DefaultSort,
+		DefaultOrder: Orders.DefaultOrder,
+		Aliases:      map[string]string{"name": "order_id"},
+	}
+)
+
+// Parse は base（ボディで受け取った条件など）にクエリパラメータの値を上書きし、Normalize する
+// 数値・真偽値として読めない値は validation.Errors を返す
+func Parse(q url.Values, base model.ListRequest, spec Spec) (model.ListRequest, error) {
+	req := base
+	var errs validation.Errors
+	parseInt := func(name string, dst *int) {
+		v := q.Get(name)
+		if v == "" {
+			return
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, validation.FieldError{Field: name, Message: "must be an integer"})
+			return
+		}
+		*dst = n
+	}
+	parseString := func(name string, dst *string) {
+		if q.Has(name) {
+			*dst = q.Get(name)
+		}
+	}
+
+	parseString("search", &req.Search)
+	parseString("type", &req.Type)
+	parseString("sort_field", &req.SortField)
+	parseString("sort_order", &req.SortOrder)
+	parseString("cursor", &req.Cursor)
+	parseInt("page", &req.Page)
+	parseInt("page_size", &req.PageSize)
+	parseInt("category", &req.Category)
+	if v := q.Get("facets"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, validation.FieldError{Field: "facets", Message: "must be a boolean"})
+		} else {
+			req.Facets = b
+		}
+	}
+	if len(errs) > 0 {
+		return req, errs
+	}
+
+	if err := Normalize(&req, spec); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// Normalize は未指定（0・空文字）の値をデフォルト値にし、validate タグとソートのホワイトリストで検証して Offset を計算する
+// 検索語は NormalizeSearch で正規化する
+func Normalize(req *model.ListRequest, spec Spec) error {
+	req.Search = NormalizeSearch(req.Search)
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = DefaultPageSize
+	}
+	if req.SortField == "" {
+		req.SortField = spec.DefaultSort
+	}
+	if req.SortOrder == "" {
+		req.SortOrder = spec.DefaultOrder
+	}
+	if alias, ok := spec.Aliases[req.SortField]; ok {
+		req.SortField = alias
+	}
+
+	var errs validation.Errors
+	if err := validation.Struct(req); err != nil {
+		verrs, ok := err.(validation.Errors)
+		if !ok {
+			return err
+		}
+		errs = append(errs, verrs...)
+	}
+	if !slices.Contains(spec.SortFields, req.SortField) {
+		errs = append(errs, validation.FieldError{Field: "sort_field", Message: "must be one of " + strings.Join(spec.SortFields, ", ")})
+	}
+	if !strings.EqualFold(req.SortOrder, "asc") && !strings.EqualFold(req.SortOrder, "desc") {
+		errs = append(errs, validation.FieldError{Field: "sort_order", Message: "must be one of asc, desc"})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	req.Offset = (req.Page - 1) * req.PageSize
+	return nil
+}
+
+// NormalizeSearch は検索語の前後の空白を除き、連続する空白を1つにまとめて小文字化する
+// 検索はどの DB・検索エンジンでも大文字小文字を区別しないため、結果は変わらずキャッシュのキーがそろう
+func NormalizeSearch(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
@@ -0,0 +1,113 @@
+// Package logging は log/slog の設定と、リクエストIDなどのコンテキスト情報をログに付与するハンドラを提供する
+package logging
+
+import (
+	"backend/internal/config"
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type contextKey struct{}
+
+// WithRequestID はリクエストIDを ctx に保存する
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, requestID)
+}
+
+// RequestID は ctx に保存されたリクエストIDを返す（無い場合は空文字）
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Setup は cfg.Format（json / text）と cfg.Level（debug / info / warn / error）に従って
+// slog のデフォルトロガーを設定する。標準の log パッケージの出力も同じハンドラに流れる
+func Setup(cfg config.Log) {
+	slog.SetDefault(slog.New(NewHandler(os.Stderr, cfg.Format, parseLevel(cfg.Level))))
+}
+
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// NewHandler は ctx のリクエストID・トレースIDを各行に付与するハンドラを返す
+func NewHandler(w io.Writer, format string, level slog.Level) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	if strings.EqualFold(format, "text") {
+		h = slog.NewTextHandler(w, opts)
+	} else {
+		h = slog.NewJSONHandler(w, opts)
+	}
+	return contextHandler{Handler: h}
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+// TraceAttrs は ctx のスパンのトレースID・スパンIDを返す（スパンが無い場合は nil）
+// Jaeger で見つけた遅いトレースと同じIDでログを検索できるよう、ログの各行に付ける
+func TraceAttrs(ctx context.Context) []slog.Attr {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return nil
+	}
+	attrs := []slog.Attr{slog.String("trace_id", sc.TraceID().String())}
+	if sc.HasSpanID() {
+		attrs = append(attrs, slog.String("span_id", sc.SpanID().String()))
+	}
+	return attrs
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if id := RequestID(ctx); id != "" {
+			r.AddAttrs(slog.String("request_id", id))
+		}
+		r.AddAttrs(TraceAttrs(ctx)...)
+		// 警告以上はスパンにもイベントとして残し、トレースからもログの内容を確認できるようにする
+		if r.Level >= slog.LevelWarn {
+			if span := trace.SpanFromContext(ctx); span.IsRecording() {
+				span.AddEvent(r.Message, trace.WithAttributes(
+					attribute.String("log.severity", r.Level.String()),
+				))
+			}
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// Fatal はエラーを出力してプロセスを終了する（log.Fatalf の置き換え）
+func Fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
+
+// StdLogger は slog に出力する *log.Logger を返す（http.Server.ErrorLog など向け）
+func StdLogger(level slog.Level) *log.Logger {
+	return slog.NewLogLogger(slog.Default().Handler(), level)
+}
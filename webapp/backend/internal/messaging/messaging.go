@@ -0,0 +1,50 @@
+// Package messaging はメッセージブローカー（Kafka / NATS）へメッセージを送る
+//
+// アウトボックスのリレー（outbox.BrokerSink）から注文のライフサイクルのイベントを送り、
+// 分析や通知などの外部のシステムが API をポーリングせずに購読できるようにする
+package messaging
+
+import (
+	"context"
+	"fmt"
+
+	"backend/internal/config"
+)
+
+// Message は1件のメッセージ
+type Message struct {
+	// 送信先のトピック（NATS ではサブジェクト）
+	Topic string
+	// 同じキーのメッセージは Kafka で同じパーティションに入り、順序が保たれる
+	Key   string
+	Value []byte
+	// 受信側で重複の排除や振り分けに使うヘッダー
+	Headers map[string]string
+	// 重複排除用のID（NATS の JetStream では Nats-Msg-Id として送る）
+	ID string
+}
+
+// Publisher はメッセージを送る
+// Publish はブローカーが受け取ったことを確認してから戻り、失敗した場合は呼び出し側が再送する
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// New は kind（kafka / nats）の Publisher を返す
+func New(kind string, cfg config.Messaging) (Publisher, error) {
+	switch kind {
+	case "kafka":
+		if len(cfg.KafkaBrokers) == 0 {
+			return nil, fmt.Errorf("messaging: KAFKA_BROKERS is required for kafka")
+		}
+		return NewKafkaPublisher(cfg.KafkaBrokers, cfg.PublishTimeout), nil
+	case "nats":
+		if cfg.NATSURL == "" {
+			return nil, fmt.Errorf("messaging: NATS_URL is required for nats")
+		}
+		return NewNATSPublisher(cfg.NATSURL, cfg.NATSJetStream, cfg.PublishTimeout)
+	default:
+		return nil, fmt.Errorf("messaging: unknown broker %q", kind)
+	}
+}
@@ -0,0 +1,60 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSPublisher は NATS へメッセージを送る
+// JetStream を使う場合はストリームへの保存の応答を待ち、使わない場合はサーバーへの到達（Flush）までを確認する
+// JetStream のストリームは、送信先のサブジェクトを含むよう別途作成しておくこと
+type NATSPublisher struct {
+	conn    *nats.Conn
+	js      nats.JetStreamContext
+	timeout time.Duration
+}
+
+func NewNATSPublisher(url string, jetStream bool, timeout time.Duration) (*NATSPublisher, error) {
+	// 起動時にブローカーが無くても起動させ、接続できるまで再接続を続ける
+	conn, err := nats.Connect(url, nats.Name("backend"), nats.Timeout(timeout), nats.RetryOnFailedConnect(true), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("messaging: failed to connect to nats: %w", err)
+	}
+	p := &NATSPublisher{conn: conn, timeout: timeout}
+	if jetStream {
+		if p.js, err = conn.JetStream(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("messaging: failed to create jetstream context: %w", err)
+		}
+	}
+	return p, nil
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, msg Message) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	m := nats.NewMsg(msg.Topic)
+	m.Data = msg.Value
+	for k, v := range msg.Headers {
+		m.Header.Set(k, v)
+	}
+	if p.js != nil {
+		opts := []nats.PubOpt{nats.Context(ctx)}
+		if msg.ID != "" {
+			opts = append(opts, nats.MsgId(msg.ID))
+		}
+		_, err := p.js.PublishMsg(m, opts...)
+		return err
+	}
+	if err := p.conn.PublishMsg(m); err != nil {
+		return err
+	}
+	return p.conn.FlushWithContext(ctx)
+}
+
+func (p *NATSPublisher) Close() error {
+	return p.conn.Drain()
+}
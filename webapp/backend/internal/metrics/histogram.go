@@ -0,0 +1,103 @@
+// Package metrics は /debug/vars（expvar）で公開するレイテンシのヒストグラムを提供する
+package metrics
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets はヒストグラムのバケット境界（ミリ秒）
+var LatencyBuckets = []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500}
+
+// Histogram は実行時間の分布を集計する。ゼロ値では使えないため NewHistogram で作成すること
+type Histogram struct {
+	buckets []float64
+	counts  []atomic.Uint64 // len(buckets) + 1（+Inf）
+	sumUs   atomic.Uint64
+}
+
+func NewHistogram(buckets []float64) *Histogram {
+	return &Histogram{buckets: buckets, counts: make([]atomic.Uint64, len(buckets)+1)}
+}
+
+func (h *Histogram) Observe(d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	i := 0
+	for i < len(h.buckets) && ms > h.buckets[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.sumUs.Add(uint64(d / time.Microsecond))
+}
+
+// Snapshot は Prometheus と同じく le ごとの累積件数で返す
+func (h *Histogram) Snapshot() map[string]interface{} {
+	buckets := make(map[string]uint64, len(h.counts))
+	var total uint64
+	for i := range h.counts {
+		total += h.counts[i].Load()
+		le := "+Inf"
+		if i < len(h.buckets) {
+			le = strconv.FormatFloat(h.buckets[i], 'f', -1, 64)
+		}
+		buckets[le] = total
+	}
+	return map[string]interface{}{
+		"count":   total,
+		"sum_ms":  float64(h.sumUs.Load()) / 1000,
+		"buckets": buckets,
+	}
+}
+
+// HistogramVec は2段のラベル（例: クエリとその結果）ごとに Histogram を持つ
+// 系列数が maxSeries に達した後の新しい1段目のラベルは Overflow にまとめる
+type HistogramVec struct {
+	buckets   []float64
+	maxSeries int
+	mu        sync.RWMutex
+	series    map[[2]string]*Histogram
+}
+
+// 上限を超えた系列をまとめるラベル
+const Overflow = "other"
+
+func NewHistogramVec(buckets []float64, maxSeries int) *HistogramVec {
+	return &HistogramVec{buckets: buckets, maxSeries: maxSeries, series: make(map[[2]string]*Histogram)}
+}
+
+func (v *HistogramVec) Observe(name, label string, d time.Duration) {
+	key := [2]string{name, label}
+	v.mu.RLock()
+	h, ok := v.series[key]
+	v.mu.RUnlock()
+	if !ok {
+		v.mu.Lock()
+		if h, ok = v.series[key]; !ok {
+			if len(v.series) >= v.maxSeries {
+				key[0] = Overflow
+			}
+			if h, ok = v.series[key]; !ok {
+				h = NewHistogram(v.buckets)
+				v.series[key] = h
+			}
+		}
+		v.mu.Unlock()
+	}
+	h.Observe(d)
+}
+
+// Snapshot は {name: {label: ヒストグラム}} の形で返す（expvar.Func に渡す）
+func (v *HistogramVec) Snapshot() interface{} {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	out := make(map[string]map[string]interface{})
+	for key, h := range v.series {
+		if out[key[0]] == nil {
+			out[key[0]] = make(map[string]interface{})
+		}
+		out[key[0]][key[1]] = h.Snapshot()
+	}
+	return out
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"backend/internal/metrics"
+
+	"github.com/go-chi/chi/v5"
+	chimw "github.com/go-chi/chi/v5/middleware"
+)
+
+// 区別するルートの上限（ルート定義の数で決まるため通常は届かない）
+const maxRouteMetricSeries = 512
+
+// ルートとステータスごとのレイテンシ。/debug/vars の http_request_latency で参照できる
+var httpLatency = metrics.NewHistogramVec(metrics.LatencyBuckets, maxRouteMetricSeries)
+
+func init() {
+	expvar.Publish("http_request_latency", expvar.Func(httpLatency.Snapshot))
+}
+
+type accessLogKey struct{}
+
+// 認証ミドルウェアが後から書き込めるよう、ポインタで ctx に載せる
+type accessLogEntry struct {
+	userID atomic.Int64
+}
+
+// 認証済みのユーザーIDをアクセスログに記録する（AccessLogMiddleware の内側でのみ有効）
+func setAccessLogUser(ctx context.Context, userID int) {
+	if e, ok := ctx.Value(accessLogKey{}).(*accessLogEntry); ok {
+		e.userID.Store(int64(userID))
+	}
+}
+
+// AccessLogMiddleware はリクエストごとにメソッド・ルート・ステータス・バイト数・ユーザーID・所要時間を記録する
+// logEnabled が false の場合はログを出さない（レイテンシの集計は常に行う）
+// ルートは chi のパターン（/api/products/{productID}/reviews など）で集計し、一致しないものは "unmatched" にまとめる
+func AccessLogMiddleware(logEnabled bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			entry := &accessLogEntry{}
+			ctx := context.WithValue(r.Context(), accessLogKey{}, entry)
+			ww := chimw.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			defer func() {
+				elapsed := time.Since(start)
+				status := ww.Status()
+				if status == 0 {
+					// 何も書かずに戻った場合は net/http が 200 を返す
+					status = http.StatusOK
+				}
+				route := "unmatched"
+				if rc := chi.RouteContext(ctx); rc != nil {
+					if p := rc.RoutePattern(); p != "" {
+						route = p
+					}
+				}
+				httpLatency.Observe(r.Method+" "+route, strconv.Itoa(status), elapsed)
+
+				if !logEnabled {
+					return
+				}
+				level := slog.LevelInfo
+				if status >= http.StatusInternalServerError {
+					level = slog.LevelWarn
+				}
+				attrs := []slog.Attr{
+					slog.String("method", r.Method),
+					slog.String("route", route),
+					slog.String("path", r.URL.Path),
+					slog.Int("status", status),
+					slog.Int("bytes", ww.BytesWritten()),
+					slog.Float64("duration_ms", float64(elapsed)/float64(time.Millisecond)),
+				}
+				if userID := entry.userID.Load(); userID != 0 {
+					attrs = append(attrs, slog.Int64("user_id", userID))
+				}
+				slog.LogAttrs(ctx, level, "access", attrs...)
+			}()
+
+			next.ServeHTTP(ww, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"net/http"
+
+	"backend/internal/apiversion"
+)
+
+const APIVersionHeader = "API-Version"
+
+// APIVersionMiddleware はルートグループのバージョンを ctx に保存し、レスポンスヘッダーでも返す
+// 認証などのエラーもそのバージョンの形式で返るよう、グループの最初に使用すること
+func APIVersionMiddleware(v apiversion.Version) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(APIVersionHeader, v.String())
+			next.ServeHTTP(w, r.WithContext(apiversion.With(r.Context(), v)))
+		})
+	}
+}
@@ -2,8 +2,11 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
+	"backend/internal/apierror"
+	"backend/internal/model"
 	"backend/internal/repository"
 )
 
@@ -11,22 +14,31 @@ type contextKey string
 
 const userContextKey contextKey = "user"
 
-func UserAuthMiddleware(sessionRepo *repository.SessionRepository) func(http.Handler) http.Handler {
+func UserAuthMiddleware(sessionRepo repository.Sessions) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			cookie, err := r.Cookie("session_id")
 			if err != nil {
-				http.Error(w, "Unauthorized: No session cookie", http.StatusUnauthorized)
+				apierror.Write(w, r, apierror.Unauthorized("No session cookie"))
 				return
 			}
 			sessionID := cookie.Value
 
 			userID, err := sessionRepo.FindUserBySessionID(r.Context(), sessionID)
 			if err != nil {
-				http.Error(w, "Unauthorized: Invalid session", http.StatusUnauthorized)
+				if errors.Is(err, repository.ErrQueryTimeout) {
+					apierror.Write(w, r, apierror.Timeout("Request timed out"))
+					return
+				}
+				if errors.Is(err, repository.ErrCircuitOpen) {
+					apierror.Write(w, r, apierror.Unavailable("Database temporarily unavailable"))
+					return
+				}
+				apierror.Write(w, r, apierror.Unauthorized("Invalid session"))
 				return
 			}
 
+			setAccessLogUser(r.Context(), userID)
 			ctx := context.WithValue(r.Context(), userContextKey, userID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
@@ -39,7 +51,27 @@ func RobotAuthMiddleware(validAPIKey string) func(http.Handler) http.Handler {
 			apiKey := r.Header.Get("X-API-KEY")
 
 			if apiKey == "" || apiKey != validAPIKey {
-				http.Error(w, "Forbidden: Invalid or missing API key", http.StatusForbidden)
+				apierror.Write(w, r, apierror.Forbidden("Invalid or missing API key"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// 管理者ロールのユーザーのみ通過させる
+// UserAuthMiddleware の後段で使用すること
+func AdminAuthMiddleware(userRepo repository.Users) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := GetUserFromContext(r.Context())
+			if !ok {
+				apierror.Write(w, r, apierror.Unauthorized("No session"))
+				return
+			}
+			role, err := userRepo.FindRoleByID(r.Context(), userID)
+			if err != nil || role != model.RoleAdmin {
+				apierror.Write(w, r, apierror.Forbidden("Admin role required"))
 				return
 			}
 			next.ServeHTTP(w, r)
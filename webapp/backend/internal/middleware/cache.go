@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ETag を計算するためにバッファするレスポンスの上限。超えた場合は ETag を付けずにそのまま返す
+const maxCacheBodyBytes = 1 << 20
+
+// CachePolicy は GET / HEAD のレスポンスをブラウザや CDN にキャッシュさせる方針
+type CachePolicy struct {
+	// キャッシュしてよい期間（Cache-Control: max-age）
+	MaxAge time.Duration
+	// 共有キャッシュ（CDN）にも保存させるか。ユーザーごとに内容が変わるレスポンスは false にすること
+	Public bool
+	// ETag はハンドラを呼ばずに ETag を計算する（一致すればハンドラを呼ばずに 304 を返す）
+	// nil の場合はレスポンスの本文から計算する。ハンドラが自分で ETag を付けた場合はそれを使う
+	ETag func(r *http.Request) (string, error)
+}
+
+func (p CachePolicy) cacheControl() string {
+	scope := "private"
+	if p.Public {
+		scope = "public"
+	}
+	return scope + ", max-age=" + strconv.Itoa(int(p.MaxAge/time.Second))
+}
+
+// CacheMiddleware は p に従って Cache-Control と ETag を付け、If-None-Match が一致すれば 304 を返す
+// 2xx / 304 以外のレスポンスには Cache-Control を付けない。GET / HEAD 以外のリクエストはそのまま処理する
+func CacheMiddleware(p CachePolicy) func(http.Handler) http.Handler {
+	cacheControl := p.cacheControl()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if p.ETag != nil {
+				etag, err := p.ETag(r)
+				// 計算できない場合は本文から計算する
+				if err == nil && etag != "" {
+					w.Header().Set("Cache-Control", cacheControl)
+					w.Header().Set("ETag", etag)
+					if etagMatches(r.Header.Get("If-None-Match"), etag) {
+						w.WriteHeader(http.StatusNotModified)
+						return
+					}
+					next.ServeHTTP(&cacheStatusWriter{ResponseWriter: w}, r)
+					return
+				}
+			}
+
+			cw := &cacheWriter{cacheStatusWriter: cacheStatusWriter{ResponseWriter: w}, r: r}
+			w.Header().Set("Cache-Control", cacheControl)
+			next.ServeHTTP(cw, r)
+			cw.finish()
+		})
+	}
+}
+
+// cacheStatusWriter はキャッシュさせないレスポンス（エラーなど）から Cache-Control を外す
+type cacheStatusWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *cacheStatusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if status >= http.StatusMultipleChoices && status != http.StatusNotModified {
+			w.Header().Del("Cache-Control")
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheStatusWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *cacheStatusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// cacheWriter は 200 のレスポンスをバッファし、本文から ETag を計算する
+// ハンドラが ETag を付けた場合（画像など）や 200 以外、上限を超えた場合はバッファせずにそのまま返す
+type cacheWriter struct {
+	cacheStatusWriter
+	r         *http.Request
+	status    int
+	buf       bytes.Buffer
+	buffering bool
+}
+
+func (w *cacheWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	if status == http.StatusOK && w.Header().Get("ETag") == "" {
+		w.buffering = true
+		return
+	}
+	w.cacheStatusWriter.WriteHeader(status)
+}
+
+func (w *cacheWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.buffering {
+		return w.cacheStatusWriter.Write(p)
+	}
+	if w.buf.Len()+len(p) > maxCacheBodyBytes {
+		w.buffering = false
+		w.cacheStatusWriter.WriteHeader(http.StatusOK)
+		if _, err := w.cacheStatusWriter.Write(w.buf.Bytes()); err != nil {
+			return 0, err
+		}
+		w.buf.Reset()
+		return w.cacheStatusWriter.Write(p)
+	}
+	return w.buf.Write(p)
+}
+
+// Flush は呼ばれた時点でバッファをやめて送り出す（ETag は付けない）
+func (w *cacheWriter) Flush() {
+	if w.buffering {
+		w.buffering = false
+		w.cacheStatusWriter.WriteHeader(http.StatusOK)
+		_, _ = w.cacheStatusWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// finish はバッファしたレスポンスに ETag を付けて送る（If-None-Match が一致すれば 304）
+func (w *cacheWriter) finish() {
+	if !w.buffering {
+		return
+	}
+	sum := sha256.Sum256(w.buf.Bytes())
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	if etagMatches(w.r.Header.Get("If-None-Match"), etag) {
+		w.Header().Del("Content-Length")
+		w.cacheStatusWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.cacheStatusWriter.WriteHeader(http.StatusOK)
+	_, _ = w.cacheStatusWriter.Write(w.buf.Bytes())
+}
@@ -0,0 +1,237 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"backend/internal/config"
+
+	"github.com/andybalholm/brotli"
+)
+
+// 圧縮するコンテンツタイプ（画像などは既に圧縮済みのため対象外）
+var compressibleTypes = map[string]bool{
+	"application/json":         true,
+	"application/x-ndjson":     true,
+	"application/javascript":   true,
+	"application/xml":          true,
+	"application/problem+json": true,
+	"image/svg+xml":            true,
+	"text/plain":               true,
+	"text/html":                true,
+	"text/css":                 true,
+	"text/csv":                 true,
+}
+
+type compressConfig struct {
+	// これより小さいレスポンスは圧縮しない（ヘッダーの分だけ大きくなるため）
+	minBytes  int
+	gzipPool  sync.Pool
+	brotli    bool
+	brotliLvl int
+}
+
+// CompressMiddleware は Accept-Encoding に応じてレスポンスを gzip（CompressBrotli の場合は brotli も）で圧縮する
+// CompressMinBytes 未満のレスポンスと、JSON・テキスト以外のコンテンツタイプはそのまま返す
+// WebSocket などのアップグレード要求は接続を引き継ぐため対象外
+// Compress が false の場合は何もしない
+func CompressMiddleware(server config.Server) func(http.Handler) http.Handler {
+	if !server.Compress {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	cfg := &compressConfig{minBytes: server.CompressMinBytes, brotliLvl: brotli.DefaultCompression}
+	cfg.brotli = server.CompressBrotli
+	// CPU を使いすぎないよう速度優先のレベルにする
+	cfg.gzipPool.New = func() interface{} {
+		zw, _ := gzip.NewWriterLevel(io.Discard, gzip.BestSpeed)
+		return zw
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), cfg.brotli)
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, cfg: cfg, encoding: encoding}
+			defer cw.finish()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// Accept-Encoding から使用する圧縮方式を選ぶ（q=0 は拒否として扱う）。使えるものが無ければ空文字
+func negotiateEncoding(header string, allowBrotli bool) string {
+	var gzipOK, brOK bool
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v <= 0 {
+				continue
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "gzip":
+			gzipOK = true
+		case "br":
+			brOK = true
+		}
+	}
+	switch {
+	case brOK && allowBrotli:
+		return "br"
+	case gzipOK:
+		return "gzip"
+	}
+	return ""
+}
+
+// compressWriter は先頭 minBytes をバッファし、圧縮するかどうかを決めてから書き出す
+type compressWriter struct {
+	http.ResponseWriter
+	cfg      *compressConfig
+	encoding string
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided || cw.status != 0 {
+		return
+	}
+	// 1xx はそのまま送る
+	if code >= 100 && code < 200 {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	cw.status = code
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.cfg.minBytes {
+			return len(p), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// decide は圧縮の有無を決めてヘッダーとバッファ済みの内容を書き出す
+func (cw *compressWriter) decide(large bool) error {
+	cw.decided = true
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	h := cw.Header()
+	if large && cw.compressible() {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoding)
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			// 圧縮後は内容が変わるため強いETagは弱いETagにする
+			h.Set("ETag", "W/"+etag)
+		}
+		switch cw.encoding {
+		case "br":
+			cw.enc = brotli.NewWriterLevel(cw.ResponseWriter, cw.cfg.brotliLvl)
+		default:
+			zw := cw.cfg.gzipPool.Get().(*gzip.Writer)
+			zw.Reset(cw.ResponseWriter)
+			cw.enc = zw
+		}
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (cw *compressWriter) compressible() bool {
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	switch cw.status {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+	ct := h.Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(cw.buf)
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return compressibleTypes[mediaType]
+}
+
+// finish は未決定のまま終わった場合（閾値未満）はそのまま書き出し、圧縮中であれば閉じる
+func (cw *compressWriter) finish() {
+	if !cw.decided {
+		if cw.status == 0 && len(cw.buf) == 0 {
+			// ハンドラが何も書かなかった場合は net/http に任せる
+			return
+		}
+		_ = cw.decide(false)
+	}
+	if cw.enc == nil {
+		return
+	}
+	_ = cw.enc.Close()
+	if zw, ok := cw.enc.(*gzip.Writer); ok {
+		zw.Reset(io.Discard)
+		cw.cfg.gzipPool.Put(zw)
+	}
+	cw.enc = nil
+}
+
+// Flush はストリーミング応答向け。バッファ中の内容は閾値に関係なく圧縮して送る
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		_ = cw.decide(true)
+	}
+	if f, ok := cw.enc.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// http.ResponseController から元の ResponseWriter を辿れるようにする
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"backend/internal/apierror"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 条件付きリクエストのためにリクエストボディを読み込む上限
+const maxETagBodyBytes = 64 << 10
+
+// ListingETagMiddleware は一覧APIに弱いETagを付与し、If-None-Match が一致すれば 304 を返す
+// ETag は version（データのバージョン）とリクエスト内容（パス・クエリ・ボディ）から計算するため、
+// 一覧がPOSTで検索条件を受け取る場合でも条件ごとに異なる値になる
+// version が返す最終更新日時（updated_at の最大値）は Last-Modified として返し、
+// If-None-Match の無い GET / HEAD では If-Modified-Since による 304 にも使う
+func ListingETagMiddleware(version func(r *http.Request) (string, time.Time, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			v, lastModified, err := version(r)
+			if err != nil {
+				// バージョンが取れない場合は条件付きリクエストを諦めて通常どおり処理する
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxETagBodyBytes+1))
+			if err != nil {
+				apierror.Write(w, r, apierror.BadRequest("Invalid request body"))
+				return
+			}
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+			if len(body) > maxETagBodyBytes {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := sha256.New()
+			io.WriteString(h, v)
+			io.WriteString(h, "|"+r.URL.Path+"?"+r.URL.RawQuery+"|")
+			// JSON とエクスポート（CSV / NDJSON）で内容が異なるため区別する（JSON の値は従来どおり）
+			if format := ExportFormat(r); format != "" {
+				io.WriteString(h, format+"|")
+			}
+			// ユーザーのタイムゾーンの設定で日時の表記が変わるため区別する（UTC の値は従来どおり）
+			if loc := LocationFromContext(r.Context()); loc != time.UTC {
+				io.WriteString(h, "tz="+loc.String()+"|")
+			}
+			h.Write(body)
+			etag := `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+
+			w.Header().Add("Vary", "Accept")
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Cache-Control", "private, no-cache")
+			if !lastModified.IsZero() {
+				w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+			}
+			if inm := r.Header.Get("If-None-Match"); inm != "" {
+				if etagMatches(inm, etag) {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			} else if notModifiedSince(r, lastModified) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// If-Modified-Since 以降に更新が無いか（秒未満は HTTP の日付で表せないため切り捨てて比較する）
+// POST の一覧は条件ごとに内容が異なるため対象外とする
+func notModifiedSince(r *http.Request, lastModified time.Time) bool {
+	if lastModified.IsZero() || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// If-None-Match の値（カンマ区切り、弱い比較）に etag が含まれるか
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
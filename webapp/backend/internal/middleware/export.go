@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 一覧 API が Accept に応じて返すストリーミング形式
+const (
+	ExportCSV    = "text/csv"
+	ExportNDJSON = "application/x-ndjson"
+)
+
+// ExportFormat は Accept から一覧のエクスポート形式を選ぶ。JSON（通常の一覧）の場合は空文字
+// 先に書かれたものを優先し、q=0 は拒否として扱う
+func ExportFormat(r *http.Request) string {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v <= 0 {
+				continue
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case ExportCSV:
+			return ExportCSV
+		case ExportNDJSON:
+			return ExportNDJSON
+		case "application/json", "*/*", "application/*":
+			return ""
+		}
+	}
+	return ""
+}
+
+// ExportTimeoutMiddleware はエクスポートのリクエストにのみ budget の期限を設ける
+// TimeoutMiddleware と違ってレスポンスをバッファしないため、期限を過ぎるとその時点で接続が切れる。budget が 0 の場合は何もしない
+func ExportTimeoutMiddleware(budget time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if budget <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ExportFormat(r) == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), budget)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
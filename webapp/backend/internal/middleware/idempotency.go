@@ -0,0 +1,254 @@
+package middleware
+
+import (
+	"backend/internal/apierror"
+	"backend/internal/config"
+	"backend/internal/repository"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// Idempotency-Key の最大長（idempotency_keys.idem_key の長さ）
+	maxIdempotencyKeyLen = 255
+	// リクエストの同一性を確認するためにボディを読み込む上限
+	maxIdempotencyBodyBytes = 1 << 20
+)
+
+// IdempotencyMiddleware は Idempotency-Key ヘッダーの付いたリクエストの処理結果を保存し、
+// 同じキーで再送されたリクエストには処理をやり直さずに保存したレスポンスを返す（Idempotent-Replayed: true を付ける）
+//   - キーはユーザー（ロボットは API キー）ごとに区別する。認証ミドルウェアの内側で使用すること
+//   - 同じキーで内容（メソッド・パス・ボディ）の異なるリクエストは 422 IDEMPOTENCY_KEY_REUSED
+//   - 同じキーのリクエストを処理中なら 409 IDEMPOTENCY_IN_PROGRESS（cfg.LockTimeout を過ぎたものは引き継ぐ）
+//   - 5xx やパニックで終わったリクエストは保存せず、再送で再実行させる
+//   - ただしタイムアウト（504）の場合、ハンドラはまだ動いていて書き込みがコミットされうるため、キーを処理中のまま残す
+//     （cfg.LockTimeout を過ぎるまで再送は 409、その後は引き継いで再実行する）
+//
+// ヘッダーが無いリクエストや、マイグレーション適用前（テーブルが無い）の場合はそのまま処理する
+func IdempotencyMiddleware(repo repository.IdempotencyKeys, cfg config.Idempotency) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" || !repo.Enabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !validIdempotencyKey(key) {
+				apierror.Write(w, r, apierror.BadRequest("Invalid Idempotency-Key header"))
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotencyBodyBytes+1))
+			if err != nil {
+				apierror.Write(w, r, apierror.BadRequest("Invalid request body"))
+				return
+			}
+			if len(body) > maxIdempotencyBodyBytes {
+				apierror.Write(w, r, apierror.PayloadTooLarge("Request body too large"))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			h := sha256.New()
+			io.WriteString(h, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery+"\n")
+			h.Write(body)
+			hash := hex.EncodeToString(h.Sum(nil))
+			scope := idempotencyScope(r)
+
+			ctx := r.Context()
+			reserved, err := reserveIdempotencyKey(ctx, repo, cfg, scope, key, hash)
+			if err != nil {
+				apierror.Write(w, r, apierror.Unavailable("Failed to check Idempotency-Key").WithCause(err))
+				return
+			}
+			if !reserved {
+				rec, err := repo.Get(ctx, scope, key)
+				if err != nil {
+					apierror.Write(w, r, apierror.Unavailable("Failed to check Idempotency-Key").WithCause(err))
+					return
+				}
+				switch {
+				case rec == nil:
+					// 確認の間に削除された（処理に失敗した）。再送してもらう
+					w.Header().Set("Retry-After", "1")
+					apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeIdempotencyInProgress, "A request with the same Idempotency-Key is in progress"))
+					return
+				case rec.RequestHash != hash:
+					apierror.Write(w, r, apierror.New(http.StatusUnprocessableEntity, apierror.CodeIdempotencyKeyReused, "Idempotency-Key was already used for a different request"))
+					return
+				case rec.StatusCode != nil:
+					replayIdempotentResponse(w, *rec.StatusCode, rec.ContentType, rec.Body)
+					return
+				}
+				now := time.Now().UTC()
+				took, err := repo.TakeOver(ctx, scope, key, now.Add(-cfg.LockTimeout), now, now.Add(cfg.TTL))
+				if err != nil {
+					apierror.Write(w, r, apierror.Unavailable("Failed to check Idempotency-Key").WithCause(err))
+					return
+				}
+				if !took {
+					w.Header().Set("Retry-After", strconv.Itoa(max(1, int(cfg.LockTimeout/time.Second))))
+					apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeIdempotencyInProgress, "A request with the same Idempotency-Key is in progress"))
+					return
+				}
+				slog.WarnContext(ctx, "idempotency: took over stale key", "scope", scope, "key", key)
+			}
+
+			rw := &idempotencyRecorder{ResponseWriter: w, limit: cfg.MaxResponseBytes}
+			// 結果の保存・解放はクライアントの切断に関係なく行う
+			saveCtx := context.WithoutCancel(ctx)
+			// completed: 結果を保存した、locked: 処理中のまま残す
+			completed, locked := false, false
+			defer func() {
+				if completed || locked {
+					return
+				}
+				if err := repo.Release(saveCtx, scope, key); err != nil {
+					slog.ErrorContext(ctx, "idempotency: failed to release key", "scope", scope, "key", key, "err", err)
+				}
+			}()
+
+			next.ServeHTTP(rw, r)
+
+			status := rw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status == http.StatusGatewayTimeout {
+				locked = true
+				slog.WarnContext(ctx, "idempotency: request timed out; keeping key locked", "scope", scope, "key", key)
+				return
+			}
+			if status >= http.StatusInternalServerError || rw.overflow {
+				return
+			}
+			if err := repo.Complete(saveCtx, scope, key, status, rw.Header().Get("Content-Type"), rw.body.Bytes()); err != nil {
+				slog.ErrorContext(ctx, "idempotency: failed to save response", "scope", scope, "key", key, "err", err)
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+// reserveIdempotencyKey はキーを処理中として登録する。期限切れのキーが残っていれば削除してから登録し直す
+func reserveIdempotencyKey(ctx context.Context, repo repository.IdempotencyKeys, cfg config.Idempotency, scope, key, hash string) (bool, error) {
+	now := time.Now().UTC()
+	ok, err := repo.Reserve(ctx, scope, key, hash, now, now.Add(cfg.TTL))
+	if err != nil || ok {
+		return ok, err
+	}
+	rec, err := repo.Get(ctx, scope, key)
+	if err != nil || rec == nil || !rec.ExpiresAt.Before(now) {
+		return false, err
+	}
+	if err := repo.Release(ctx, scope, key); err != nil {
+		return false, err
+	}
+	return repo.Reserve(ctx, scope, key, hash, now, now.Add(cfg.TTL))
+}
+
+// idempotencyScope はキーの名前空間。ユーザーごと、ロボットは API キーごとに区別する
+func idempotencyScope(r *http.Request) string {
+	if userID, ok := GetUserFromContext(r.Context()); ok {
+		return "user:" + strconv.Itoa(userID)
+	}
+	if apiKey := r.Header.Get("X-API-KEY"); apiKey != "" {
+		sum := sha256.Sum256([]byte(apiKey))
+		return "robot:" + hex.EncodeToString(sum[:8])
+	}
+	return "anonymous"
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x21 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func replayIdempotentResponse(w http.ResponseWriter, status int, contentType string, body []byte) {
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// idempotencyRecorder はレスポンスをクライアントへ送りつつ、保存のために limit バイトまで記録する
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (rw *idempotencyRecorder) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *idempotencyRecorder) Write(p []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	if !rw.overflow {
+		if rw.body.Len()+len(p) > rw.limit {
+			rw.overflow = true
+			rw.body.Reset()
+		} else {
+			rw.body.Write(p)
+		}
+	}
+	return rw.ResponseWriter.Write(p)
+}
+
+func (rw *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// 1回の掃除で削除するキーの上限
+const idempotencyPurgeBatchSize = 1000
+
+// RunIdempotencyCleanup は ctx がキャンセルされるまで、interval ごとに期限切れのキーを削除する
+func RunIdempotencyCleanup(ctx context.Context, repo repository.IdempotencyKeys, interval time.Duration) {
+	if !repo.Enabled() {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// 溜まっている間は続けて削除する
+		for ctx.Err() == nil {
+			n, err := repo.PurgeExpired(ctx, time.Now().UTC(), idempotencyPurgeBatchSize)
+			if err != nil {
+				slog.ErrorContext(ctx, "idempotency: failed to purge expired keys", "err", err)
+				break
+			}
+			if n < idempotencyPurgeBatchSize {
+				break
+			}
+		}
+	}
+}
@@ -2,7 +2,6 @@ package middleware
 
 import (
 	"net/http"
-	"os"
 
 	"context"
 
@@ -13,8 +12,7 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 )
 
-func InitJaegerTracer() error {
-	otlpEndpoint := os.Getenv("OTLP_ENDPOINT")
+func InitJaegerTracer(otlpEndpoint string) error {
 	if otlpEndpoint == "" {
 		otlpEndpoint = "jaeger:4318"
 	}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"backend/internal/apierror"
+	"backend/internal/apiversion"
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const orderContextKey contextKey = "order"
+
+// OrderOwnerMiddleware はルートの {orderID} の注文が、ログイン中のユーザーのものか確かめる
+// v2 以降は公開ID（ULID）のみを受け付け、v1 は互換のため order_id も受け付ける
+// 他のユーザーの注文は管理者のみ通過させ、それ以外は存在を知らせないよう 404 にする
+// 確認した order_id は ctx に保存する。後段のハンドラは OrderIDFromContext の ID だけを使い、user_id の条件を付け直さなくてよい
+// UserAuthMiddleware の後段で使用すること
+func OrderOwnerMiddleware(orderRepo repository.Orders, userRepo repository.Users) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := GetUserFromContext(r.Context())
+			if !ok {
+				apierror.Write(w, r, apierror.Unauthorized("No session"))
+				return
+			}
+			ref, err := model.ParseOrderRef(chi.URLParam(r, "orderID"))
+			if err != nil {
+				apierror.Write(w, r, apierror.Validation("Invalid order ID", apierror.FieldError{Field: "orderID", Message: "must be an order ID"}))
+				return
+			}
+			// 連番の order_id を順に試して注文を探れないよう、v2 以降は公開IDに限る
+			if ref.PublicID == "" && apiversion.FromContext(r.Context()) >= apiversion.V2 {
+				apierror.Write(w, r, apierror.Validation("Invalid order ID", apierror.FieldError{Field: "orderID", Message: "must be a public order ID (ULID)"}))
+				return
+			}
+			orderID, ownerID, err := orderRepo.FindOwner(r.Context(), ref)
+			if err != nil {
+				writeOwnerLookupError(w, r, err)
+				return
+			}
+			if ownerID != userID {
+				role, err := userRepo.FindRoleByID(r.Context(), userID)
+				if err != nil && !errors.Is(err, sql.ErrNoRows) {
+					writeOwnerLookupError(w, r, err)
+					return
+				}
+				if role != model.RoleAdmin {
+					apierror.Write(w, r, apierror.NotFound("Order not found"))
+					return
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), orderContextKey, orderID)))
+		})
+	}
+}
+
+func writeOwnerLookupError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		apierror.Write(w, r, apierror.NotFound("Order not found"))
+	case errors.Is(err, repository.ErrQueryTimeout):
+		apierror.Write(w, r, apierror.Timeout("Request timed out"))
+	case errors.Is(err, repository.ErrCircuitOpen):
+		apierror.Write(w, r, apierror.Unavailable("Database temporarily unavailable"))
+	default:
+		apierror.Write(w, r, err)
+	}
+}
+
+// OrderIDFromContext は OrderOwnerMiddleware で所有者を確認した注文の order_id を返す
+func OrderIDFromContext(ctx context.Context) (int64, bool) {
+	orderID, ok := ctx.Value(orderContextKey).(int64)
+	return orderID, ok
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"backend/internal/apierror"
+	"backend/internal/ratelimit"
+)
+
+// RateLimitMiddleware はユーザー（未認証の場合はクライアントIP）ごとに policy の回数までリクエストを許可する
+// 超過した場合は 429 と Retry-After を返す。limiter が nil の場合は何もしない
+// 判定結果は RateLimit-Limit / RateLimit-Remaining / RateLimit-Reset / RateLimit-Policy ヘッダーで返す
+// Redis の障害などで判定できない場合は、サービスを止めないよう許可する
+// trustProxy が true の場合は X-Forwarded-For などからクライアントIPを取る
+func RateLimitMiddleware(limiter ratelimit.Limiter, policy ratelimit.Policy, trustProxy bool) func(http.Handler) http.Handler {
+	if limiter == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := "ip:" + clientIP(r, trustProxy)
+			if userID, ok := GetUserFromContext(r.Context()); ok {
+				key = "user:" + strconv.Itoa(userID)
+			}
+
+			res, err := limiter.Allow(r.Context(), policy, key)
+			if err != nil {
+				slog.WarnContext(r.Context(), "rate limit check failed; allowing request", "policy", policy.Name, "err", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter.Seconds())))
+			h.Set("RateLimit-Policy", policy.String())
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter.Seconds())))
+				apierror.Write(w, r, apierror.RateLimited("Too many requests"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(s float64) int {
+	return int(math.Ceil(s))
+}
+
+// リバースプロキシ配下（RATE_LIMIT_TRUST_PROXY=true）では X-Forwarded-For の先頭をクライアントIPとみなす
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			first, _, _ := strings.Cut(xff, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+		if ip := r.Header.Get("X-Real-IP"); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"backend/internal/apierror"
+	"backend/internal/config"
+)
+
+// ReadOnlyMode はメンテナンス（スキーマ移行やフェイルオーバー）中に書き込み系の API を止めるためのフラグ
+// 起動時の値は設定の ReadOnly、実行中は管理者 API から切り替える。状態はプロセスごとに持つ
+type ReadOnlyMode struct {
+	enabled    atomic.Bool
+	retryAfter time.Duration
+}
+
+// ReadOnlyRetryAfter は 503 で返す再試行の目安
+func NewReadOnlyMode(cfg config.Server) *ReadOnlyMode {
+	m := &ReadOnlyMode{retryAfter: cfg.ReadOnlyRetryAfter}
+	m.enabled.Store(cfg.ReadOnly)
+	return m
+}
+
+func (m *ReadOnlyMode) Enabled() bool {
+	return m.enabled.Load()
+}
+
+func (m *ReadOnlyMode) Set(ctx context.Context, enabled bool) {
+	if m.enabled.Swap(enabled) != enabled {
+		slog.WarnContext(ctx, "read-only mode changed", "read_only", enabled)
+	}
+}
+
+// Guard は読み取り専用モードの間、503 と Retry-After を返してリクエストを拒否する
+// 一覧の検索など POST でも参照のみのルートがあるため、メソッドではなくルートごとに書き込み系へ付けること
+func (m *ReadOnlyMode) Guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.enabled.Load() {
+			seconds := int(m.retryAfter / time.Second)
+			if seconds < 1 {
+				seconds = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			apierror.Write(w, r, apierror.Unavailable("Service is in read-only mode for maintenance"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"net/http"
+
+	"backend/internal/repository"
+)
+
+// ReadYourWritesMiddleware はリクエスト内の書き込みを記録し、書き込んだ後の読み取りをプライマリで行わせる
+// レプリカが無い場合は何も変わらない
+func ReadYourWritesMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(repository.TrackWrites(r.Context())))
+	})
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"expvar"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"backend/internal/apierror"
+	"backend/internal/errreport"
+
+	"github.com/go-chi/chi/v5"
+	chimw "github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 復旧したパニックの回数。/debug/vars の http_panics で参照できる
+var panicCount = expvar.NewInt("http_panics")
+
+// RecoverMiddleware はハンドラのパニックを復旧し、スタックトレースをログとスパン、エラートラッキングに記録して 500 のエラーレスポンスを返す
+// アクセスログに 500 として残るよう AccessLogMiddleware の内側で使用すること
+// レスポンスを書き始めた後のパニックは正しい本文を返せないため、http.ErrAbortHandler で接続を切る
+func RecoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := chimw.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// net/http の規約どおり、意図的な中断はそのまま伝える
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			err := &errreport.PanicError{Value: rec, Stack: debug.Stack()}
+			panicCount.Add(1)
+			span := trace.SpanFromContext(r.Context())
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "panic")
+
+			if ww.Status() != 0 {
+				slog.ErrorContext(r.Context(), "panic after response started, aborting connection",
+					"method", r.Method, "path", r.URL.Path, "err", err)
+				route := r.Method + " " + r.URL.Path
+				if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+					route = r.Method + " " + rctx.RoutePattern()
+				}
+				errreport.Report(r.Context(), errreport.Event{Err: err, Request: r, Route: route})
+				panic(http.ErrAbortHandler)
+			}
+			// ログ（スタックトレース付き）は apierror.Write が出力する
+			apierror.Write(ww, r, apierror.Internal("Internal server error").WithCause(err))
+		}()
+		next.ServeHTTP(ww, r)
+	})
+}
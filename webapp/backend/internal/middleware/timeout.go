@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"expvar"
+	"net/http"
+	"sync"
+	"time"
+
+	"backend/internal/apierror"
+)
+
+// 予算を超えたリクエストの数。/debug/vars の http_timeouts で参照できる
+var timeoutCount = expvar.NewInt("http_timeouts")
+
+// TimeoutMiddleware はリクエスト全体（認証・DB アクセス・JSON のエンコードを含む）に budget の時間制限を設ける
+// 超過した場合はリクエストの ctx をキャンセルし、504 のエラーレスポンスを返す（その後にハンドラが書いた内容は捨てる）
+//
+// 判定のためにレスポンスをバッファするので、画像やストリーミングのルートには使わないこと。budget が 0 の場合は何もしない
+// 一覧のエクスポート（ExportFormat）は行をそのまま流すため対象外とし、ExportTimeoutMiddleware で期限を設ける
+func TimeoutMiddleware(budget time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if budget <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ExportFormat(r) != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), budget)
+			defer cancel()
+
+			tw := &timeoutWriter{header: make(http.Header)}
+			done := make(chan struct{})
+			panicked := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case p := <-panicked:
+				// RecoverMiddleware で処理できるよう、呼び出し元の goroutine で投げ直す
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				dst := w.Header()
+				for k, v := range tw.header {
+					dst[k] = v
+				}
+				if tw.status == 0 {
+					tw.status = http.StatusOK
+				}
+				w.WriteHeader(tw.status)
+				_, _ = w.Write(tw.buf.Bytes())
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.timedOut = true
+				timeoutCount.Add(1)
+				apierror.Write(w, r, apierror.Timeout("Request timed out").WithCause(ctx.Err()))
+			}
+		})
+	}
+}
+
+// timeoutWriter はハンドラの出力をバッファし、タイムアウト後の書き込みを捨てる
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.status != 0 {
+		return
+	}
+	tw.status = status
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
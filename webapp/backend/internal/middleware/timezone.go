@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"backend/internal/apierror"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+const locationContextKey contextKey = "location"
+
+// TimezoneMiddleware はレスポンスの日時を表すタイムゾーンを ctx に保存する
+// クエリパラメータ tz（IANA のタイムゾーン名）があればそれを、無ければユーザーの設定を使う
+// 設定を読めない場合は UTC のまま返す（DB の日時は UTC のため、変換しなくても正しい値になる）
+// UserAuthMiddleware の後段、ETag を計算するミドルウェアより前で使用すること
+func TimezoneMiddleware(userRepo repository.Users) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			loc := time.UTC
+			if name := r.URL.Query().Get("tz"); name != "" {
+				parsed, err := model.LoadTimezone(name)
+				if err != nil {
+					apierror.Write(w, r, apierror.Validation("tz must be an IANA time zone name", apierror.FieldError{Field: "tz", Message: "must be an IANA time zone name"}))
+					return
+				}
+				loc = parsed
+			} else if userID, ok := GetUserFromContext(r.Context()); ok {
+				if name, err := userRepo.FindTimezoneByID(r.Context(), userID); err == nil {
+					if parsed, err := model.LoadTimezone(name); err == nil {
+						loc = parsed
+					}
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), locationContextKey, loc)))
+		})
+	}
+}
+
+// LocationFromContext はレスポンスの日時に使うタイムゾーンを返す（TimezoneMiddleware を通っていない場合は UTC）
+func LocationFromContext(ctx context.Context) *time.Location {
+	if loc, ok := ctx.Value(locationContextKey).(*time.Location); ok {
+		return loc
+	}
+	return time.UTC
+}
@@ -2,7 +2,8 @@ package middleware
 
 import (
 	"context"
-	"log"
+
+	"backend/internal/logging"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -15,7 +16,7 @@ import (
 func InitTracing(collectorURL string) func(context.Context) error {
 	exp, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(collectorURL)))
 	if err != nil {
-		log.Fatalf("failed to create jaeger exporter: %v", err)
+		logging.Fatal("failed to create jaeger exporter", err)
 	}
 
 	res, err := resource.New(context.Background(),
@@ -29,7 +30,7 @@ func InitTracing(collectorURL string) func(context.Context) error {
 		),
 	)
 	if err != nil {
-		log.Fatalf("failed to create resource: %v", err)
+		logging.Fatal("failed to create resource", err)
 	}
 
 	tp := sdktrace.NewTracerProvider(
@@ -0,0 +1,144 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 採点前のマイグレーションは webapp/mysql/migration が正となるため、その内容をバイナリに同梱する
+// SQLファイルを追加・変更したら go generate ./internal/migrations で同期すること
+//go:generate sh -c "rm -f sql/*.sql && cp ../../../mysql/migration/*.sql sql/"
+
+//go:embed sql/*.sql
+var files embed.FS
+
+const lockName = "schema_migrations"
+
+// Migration は {数字}_{名前}.sql の1ファイル
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Load は同梱されたマイグレーションをバージョン順に返す
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	for _, e := range entries {
+		prefix, _, ok := strings.Cut(e.Name(), "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name: %s", e.Name())
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", e.Name())
+		}
+		body, err := fs.ReadFile(files, path.Join("sql", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: e.Name(), SQL: string(body)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Apply は未適用のマイグレーションを順に適用し、schema_migrations テーブルに記録する
+// 複数のインスタンスが同時に起動しても二重に適用されないよう、GET_LOCK で排他する
+// restore_and_migration.sh で適用済みのDBには記録が無いため、同じDBに対して両方を使わないこと
+func Apply(ctx context.Context, db *sqlx.DB) error {
+	migrations, err := Load()
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked int
+	if err := conn.GetContext(ctx, &locked, "SELECT GET_LOCK(?, 30)", lockName); err != nil {
+		return err
+	}
+	if locked != 1 {
+		return fmt.Errorf("failed to acquire migration lock")
+	}
+	defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return err
+	}
+
+	var applied []int
+	if err := conn.SelectContext(ctx, &applied, "SELECT version FROM schema_migrations"); err != nil {
+		return err
+	}
+	done := make(map[int]bool, len(applied))
+	for _, v := range applied {
+		done[v] = true
+	}
+
+	for _, m := range migrations {
+		if done[m.Version] {
+			continue
+		}
+		slog.InfoContext(ctx, "applying migration", "name", m.Name)
+		// MySQLのDDLは暗黙的にコミットされるため、トランザクションは使わず1文ずつ実行する
+		for _, stmt := range splitStatements(m.SQL) {
+			if _, err := conn.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("migration %s failed: %w", m.Name, err)
+			}
+		}
+		if _, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// コメント行を取り除き、行末の ; で文を区切る
+// マイグレーションでは DELIMITER を使うストアドプロシージャ等は書かない前提
+func splitStatements(sql string) []string {
+	var stmts []string
+	var b strings.Builder
+	for _, line := range strings.Split(sql, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			if stmt := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(b.String()), ";")); stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+			b.Reset()
+		}
+	}
+	if stmt := strings.TrimSpace(b.String()); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
@@ -0,0 +1,14 @@
+-- トランザクショナルアウトボックス
+-- 注文の作成・状態変更と同じトランザクションでイベントを記録し、リレーが外部へ配信する
+-- 未配信のイベントは published_at が NULL の行を event_id 順に読む
+CREATE TABLE outbox (
+    event_id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    event_type VARCHAR(64) NOT NULL,
+    aggregate_id BIGINT UNSIGNED NOT NULL,
+    payload JSON NOT NULL,
+    created_at DATETIME(6) NOT NULL,
+    published_at DATETIME(6) NULL,
+    attempts INT UNSIGNED NOT NULL DEFAULT 0,
+    last_error VARCHAR(255) NOT NULL DEFAULT '',
+    INDEX idx_outbox_pending (published_at, event_id)
+);
@@ -0,0 +1,7 @@
+-- 注文の最終更新日時
+-- 配送状況の変更で自動的に更新され、注文一覧の ETag / Last-Modified の計算に使用する
+-- （ユーザーごとの MAX(updated_at) をインデックスのみで求められるようにする）
+-- 同じ秒の中での状態変更でも ETag が変わるよう、マイクロ秒まで保持する
+ALTER TABLE orders
+    ADD COLUMN updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    ADD INDEX idx_orders_user_updated (user_id, updated_at);
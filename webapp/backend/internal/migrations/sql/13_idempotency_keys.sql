@@ -0,0 +1,15 @@
+-- Idempotency-Key ヘッダー付きのリクエストの処理結果
+-- 同じキーの再送には保存したレスポンスを返す。status_code が NULL の行は処理中
+-- 期限（expires_at）を過ぎた行は定期的に削除する
+CREATE TABLE idempotency_keys (
+    scope VARCHAR(64) NOT NULL,
+    idem_key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INT NULL,
+    content_type VARCHAR(255) NOT NULL DEFAULT '',
+    response_body MEDIUMBLOB NULL,
+    created_at DATETIME(6) NOT NULL,
+    expires_at DATETIME(6) NOT NULL,
+    PRIMARY KEY (scope, idem_key),
+    INDEX idx_idempotency_expires (expires_at)
+);
@@ -0,0 +1,19 @@
+-- 注文の時間・日ごとの集計
+-- 定期的なジョブ（service.OrderSummarizer）が orders から集計して書き込み、運用ダッシュボードは orders を走査せずにこれを読む
+-- period は 'hour' / 'day'、bucket_start はその期間の開始日時（UTC）
+-- 作成された注文は created_at、配送完了した注文は arrived_at の期間に数える
+CREATE TABLE order_summaries (
+    period VARCHAR(8) NOT NULL,
+    bucket_start DATETIME NOT NULL,
+    orders_created INT UNSIGNED NOT NULL DEFAULT 0,
+    value_created BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    orders_delivered INT UNSIGNED NOT NULL DEFAULT 0,
+    -- 配送完了までの時間の合計（平均は delivery_seconds / orders_delivered）
+    delivery_seconds DOUBLE NOT NULL DEFAULT 0,
+    PRIMARY KEY (period, bucket_start)
+);
+
+-- 集計し直す直近の期間だけを読めるようにする
+ALTER TABLE orders
+    ADD INDEX idx_orders_created (created_at),
+    ADD INDEX idx_orders_arrived (arrived_at);
@@ -0,0 +1,5 @@
+-- 商品価値（value）の通貨
+-- value は通貨の最小単位（JPY は円、USD はセント）の金額。既存の商品はすべて JPY として扱う
+-- 配送計画・集計では異なる通貨の金額を合算しない
+ALTER TABLE products
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'JPY' AFTER value;
@@ -0,0 +1,7 @@
+-- 商品の最終更新日時・お気に入りの登録日時をマイクロ秒まで保持する
+-- 商品一覧の ETag は MAX(updated_at) と件数から計算するため、秒単位では同じ秒の中での更新で ETag が変わらず、
+-- 古い一覧に 304 を返すことがある（orders.updated_at と同じ精度にそろえる）
+ALTER TABLE products
+    MODIFY COLUMN updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6);
+ALTER TABLE favorites
+    MODIFY COLUMN created_at DATETIME(6) NOT NULL;
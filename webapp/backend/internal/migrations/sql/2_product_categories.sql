@@ -0,0 +1,16 @@
+-- 商品カテゴリ
+
+CREATE TABLE categories (
+    category_id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    UNIQUE KEY uq_categories_name (name)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8mb4
+COLLATE=utf8mb4_0900_ai_ci;
+
+-- 商品にカテゴリを紐付ける（未分類の商品は NULL）
+-- カテゴリでの絞り込み一覧・件数取得を高速化するためインデックスを作成
+ALTER TABLE products
+    ADD COLUMN category_id INT UNSIGNED NULL,
+    ADD INDEX idx_products_category_id (category_id),
+    ADD CONSTRAINT fk_products_category FOREIGN KEY (category_id) REFERENCES categories(category_id) ON DELETE SET NULL;
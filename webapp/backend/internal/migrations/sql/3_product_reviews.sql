@@ -0,0 +1,22 @@
+-- 商品レビュー・評価
+
+CREATE TABLE reviews (
+    review_id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    product_id INT UNSIGNED NOT NULL,
+    user_id INT UNSIGNED NOT NULL,
+    rating TINYINT UNSIGNED NOT NULL,
+    comment TEXT,
+    created_at DATETIME NOT NULL,
+    -- 商品ごとのレビュー一覧（新しい順）のページングに使用
+    INDEX idx_reviews_product_created (product_id, created_at, review_id),
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8mb4
+COLLATE=utf8mb4_0900_ai_ci;
+
+-- 平均評価とレビュー件数を商品に非正規化して保持する
+-- レビュー投稿と同じトランザクション内で更新するため、一覧取得時に集計は不要
+ALTER TABLE products
+    ADD COLUMN rating_avg DECIMAL(3,2) NOT NULL DEFAULT 0,
+    ADD COLUMN rating_count INT UNSIGNED NOT NULL DEFAULT 0;
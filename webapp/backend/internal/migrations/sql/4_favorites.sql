@@ -0,0 +1,13 @@
+-- お気に入り（ウィッシュリスト）
+
+-- (user_id, product_id) の複合主キーで重複登録を防ぐ
+-- ユーザーごとのお気に入り一覧は主キーの前方一致で取得できる
+CREATE TABLE favorites (
+    user_id INT UNSIGNED NOT NULL,
+    product_id INT UNSIGNED NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, product_id),
+    INDEX idx_favorites_user_created (user_id, created_at),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
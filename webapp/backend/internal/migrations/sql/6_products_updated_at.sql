@@ -0,0 +1,5 @@
+-- 商品の最終更新日時
+-- 商品一覧の ETag 計算に使用する（MAX(updated_at) をインデックスのみで求められるようにする）
+ALTER TABLE products
+    ADD COLUMN updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    ADD INDEX idx_products_updated_at (updated_at);
@@ -9,61 +9,403 @@ type User struct {
 	UserID       int    `db:"user_id"`
 	PasswordHash string `db:"password_hash"`
 	UserName     string `db:"user_name"`
+	Role         string `db:"role"`
 }
 
+const RoleAdmin = "admin"
+
+// Currency（ISO 4217）やカテゴリ・評価・在庫などの追加した項目は v1 の互換のため JSON には含めず、v2 は handler.ProductResponse で返す
 type Product struct {
-	ProductID   int    `db:"product_id"   json:"product_id"`
-	Name        string `db:"name"         json:"name"`
-	Value       int    `db:"value"        json:"value"`
-	Weight      int    `db:"weight"       json:"weight"`
-	Image       string `db:"image"        json:"image"`
-	Description string `db:"description"  json:"description"`
+	ProductID   int     `db:"product_id"   json:"product_id"`
+	Name        string  `db:"name"         json:"name"`
+	Value       int     `db:"value"        json:"value"`
+	Currency    string  `db:"currency"     json:"-"`
+	Weight      int     `db:"weight"       json:"weight"`
+	Image       string  `db:"image"        json:"image"`
+	Description string  `db:"description"  json:"description"`
+	CategoryID  *int    `db:"category_id"  json:"-"`
+	RatingAvg   float64 `db:"rating_avg"   json:"-"`
+	RatingCount int     `db:"rating_count" json:"-"`
+	// 在庫数（NULL の場合は在庫管理の対象外）
+	Stock *int `db:"stock" json:"-"`
+	// 認証ユーザーがお気に入り登録しているか
+	Favorited bool `db:"-" json:"-"`
+	// 全文検索時の関連度スコア（検索エンジン経由の場合のみ設定）
+	Relevance *float64 `db:"-" json:"relevance,omitempty"`
+	// 最終更新日時。v1 の互換のため JSON には含めず、v2 は handler.ProductResponse で返す
+	UpdatedAt *time.Time `db:"updated_at" json:"-"`
+}
+
+type Review struct {
+	ReviewID  int64     `db:"review_id"  json:"review_id"`
+	ProductID int       `db:"product_id" json:"product_id"`
+	UserID    int       `db:"user_id"    json:"user_id"`
+	Rating    int       `db:"rating"     json:"rating"`
+	Comment   string    `db:"comment"    json:"comment"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+type CreateReviewRequest struct {
+	Rating  int    `json:"rating" validate:"min=1,max=5"`
+	Comment string `json:"comment"`
+}
+
+// 検索結果のファセットごとの件数
+// Category の Key はカテゴリID（未分類は 0）、Weight/Value の Key は帯の下限、Max は上限
+type Facets struct {
+	Category []FacetCount `json:"category"`
+	Weight   []FacetCount `json:"weight"`
+	Value    []FacetCount `json:"value"`
+}
+
+type FacetCount struct {
+	Key   int `json:"key"`
+	Max   int `json:"max,omitempty"`
+	Count int `json:"count"`
+}
+
+type Category struct {
+	CategoryID int    `db:"category_id" json:"category_id"`
+	Name       string `db:"name"        json:"name"`
+}
+
+// 管理者による商品の作成・更新リクエスト
+type ProductInput struct {
+	Name        string `json:"name"`
+	Value       int    `json:"value"`
+	Currency    string `json:"currency"`
+	Weight      int    `json:"weight"`
+	Image       string `json:"image"`
+	Description string `json:"description"`
+	CategoryID  *int   `json:"category_id"`
+	// 在庫数（null で在庫を管理しない）。更新時に省略した場合は変更しない
+	Stock OptionalInt `json:"stock"`
+	// 価値を変更する場合の理由（価格履歴に記録される）
+	ChangeReason string `json:"change_reason" validate:"max=255"`
+}
+
+type PriceHistory struct {
+	HistoryID int64     `db:"history_id" json:"history_id"`
+	ProductID int       `db:"product_id" json:"product_id"`
+	OldValue  int       `db:"old_value"  json:"old_value"`
+	NewValue  int       `db:"new_value"  json:"new_value"`
+	ChangedBy *int      `db:"changed_by" json:"changed_by"`
+	Reason    string    `db:"reason"     json:"reason"`
+	ChangedAt time.Time `db:"changed_at" json:"changed_at"`
+}
+
+// アウトボックスに記録された未配信のイベント
+// Payload はJSONのまま保持し、配信先へそのまま渡す
+// 外部へは order_id を出さないため、集約IDには AggregateKey（注文の公開ID）を使う
+type OutboxEvent struct {
+	EventID      int64     `db:"event_id"      json:"event_id"`
+	EventType    string    `db:"event_type"    json:"type"`
+	AggregateID  int64     `db:"aggregate_id"  json:"-"`
+	AggregateKey string    `db:"aggregate_key" json:"aggregate_id"`
+	Payload      []byte    `db:"payload"       json:"-"`
+	CreatedAt    time.Time `db:"created_at"    json:"occurred_at"`
+	Attempts     int       `db:"attempts"      json:"-"`
 }
 
+// Idempotency-Key ごとに保存したリクエストの処理結果
+// StatusCode が nil の間は処理中
+type IdempotencyRecord struct {
+	RequestHash string    `db:"request_hash"`
+	StatusCode  *int      `db:"status_code"`
+	ContentType string    `db:"content_type"`
+	Body        []byte    `db:"response_body"`
+	CreatedAt   time.Time `db:"created_at"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
+
+// 在庫が閾値を下回った商品
+type StockLevel struct {
+	ProductID int    `db:"product_id" json:"product_id"`
+	Name      string `db:"name"       json:"name,omitempty"`
+	Stock     int    `db:"stock"      json:"stock"`
+}
+
+// 商品CSVインポートの結果
+type ImportReport struct {
+	Inserted int           `json:"inserted"`
+	Updated  int           `json:"updated"`
+	Rejected int           `json:"rejected"`
+	Errors   []ImportError `json:"errors"`
+}
+
+type ImportError struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// Currency（ISO 4217）は v1 の互換のため JSON には含めず、v2 は handler.PublicOrderResponse で返す
 type Order struct {
-	OrderID       int64        `db:"order_id"        json:"order_id"`
-	UserID        int          `db:"user_id"         json:"user_id"`
-	ProductID     int          `db:"product_id"      json:"product_id"`
-	ProductName   string       `db:"product_name"    json:"product_name"`
-	ShippedStatus string       `db:"shipped_status"  json:"shipped_status"`
-	Weight        int          `db:"weight"          json:"weight"`
-	Value         int          `db:"value"           json:"value"`
-	CreatedAt     time.Time    `db:"created_at"      json:"created_at"`
-	ArrivedAt     sql.NullTime `db:"arrived_at"      json:"arrived_at"`
+	OrderID       int64         `db:"order_id"        json:"order_id"`
+	UserID        int           `db:"user_id"         json:"user_id"`
+	ProductID     int           `db:"product_id"      json:"product_id"`
+	ProductName   string        `db:"product_name"    json:"product_name"`
+	ShippedStatus ShippedStatus `db:"shipped_status"  json:"shipped_status"`
+	Weight        int           `db:"weight"          json:"weight"`
+	Value         int           `db:"value"           json:"value"`
+	Currency      string        `db:"currency"        json:"-"`
+	CreatedAt     time.Time     `db:"created_at"      json:"created_at"`
+	ArrivedAt     sql.NullTime  `db:"arrived_at"      json:"arrived_at"`
+	// 最終更新日時（orders.updated_at が無いスキーマでは nil）。v1 の互換のため JSON には含めない
+	UpdatedAt *time.Time `db:"updated_at" json:"-"`
+	// 外部に公開する注文ID（ULID）。orders.public_id が無いスキーマや、割り当て前の注文では空
+	// v1 の互換のため JSON には含めず、v2 は handler.PublicOrderResponse で返す
+	PublicID string `db:"public_id" json:"-"`
 }
 
 type DeliveryPlan struct {
 	RobotID     string  `json:"robot_id"`
 	TotalWeight int     `json:"total_weight"`
 	TotalValue  int     `json:"total_value"`
+	Currency    string  `json:"currency"`
 	Orders      []Order `json:"orders"`
 }
 
 type LoginRequest struct {
-	UserName string `json:"user_name"`
-	Password string `json:"password"`
+	UserName string `json:"user_name" validate:"required,max=64"`
+	Password string `json:"password"  validate:"required,max=128"`
 }
 
 type CreateOrderRequest struct {
-	Items []RequestItem `json:"items"`
+	Items []RequestItem `json:"items" validate:"required,max=1000"`
 }
 
 type RequestItem struct {
-	ProductID int `json:"product_id"`
-	Quantity  int `json:"quantity"`
+	ProductID int `db:"product_id" json:"product_id" validate:"min=1"`
+	Quantity  int `db:"quantity"   json:"quantity"   validate:"min=1,max=1000"`
+}
+
+// 非同期の注文作成ジョブの状態
+const (
+	OrderJobQueued    = "queued"
+	OrderJobRunning   = "running"
+	OrderJobSucceeded = "succeeded"
+	OrderJobFailed    = "failed"
+)
+
+// 非同期の注文作成ジョブ
+// 注文は一定数ごとに別のトランザクションで作成するため、失敗した場合も作成済みの注文は残る
+type OrderJob struct {
+	JobID  string `json:"job_id"`
+	Status string `json:"status"`
+	// 作成する注文の総数と、作成済みの数
+	Total    int      `json:"total"`
+	Created  int      `json:"created"`
+	OrderIDs []string `json:"order_ids"`
+	// 作成済みの注文の公開ID（v2 では order_ids をこちらに置き換えて返す）
+	PublicOrderIDs []string `json:"-"`
+	// 失敗した場合の理由
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+type CartItem struct {
+	ProductID   int    `db:"product_id"   json:"product_id"`
+	ProductName string `db:"product_name" json:"product_name"`
+	Value       int    `db:"value"        json:"value"`
+	Currency    string `db:"currency"     json:"currency"`
+	Weight      int    `db:"weight"       json:"weight"`
+	Quantity    int    `db:"quantity"     json:"quantity"`
+}
+
+type Cart struct {
+	Items         []CartItem `json:"items"`
+	TotalQuantity int        `json:"total_quantity"`
+	TotalValue    int        `json:"total_value"`
+	Currency      string     `json:"currency"`
+}
+
+type UpdateCartItemRequest struct {
+	Quantity int `json:"quantity"`
 }
 
 type UpdateOrderStatusRequest struct {
-	OrderID   int64  `json:"order_id"`
-	NewStatus string `json:"new_status"`
+	OrderID   OrderRef      `json:"order_id"`
+	NewStatus ShippedStatus `json:"new_status" validate:"required,oneof=shipping delivering completed failed cancelled"`
+}
+
+// ロボットの位置報告（倉庫内の座標、単位はメートル）
+type RobotPositionRequest struct {
+	X float64 `json:"x" validate:"min=-100000,max=100000"`
+	Y float64 `json:"y" validate:"min=-100000,max=100000"`
+}
+
+// 配送計画の取得条件（クエリパラメータ）
+type DeliveryPlanQuery struct {
+	Capacity int `json:"capacity" validate:"min=1"`
 }
 
+// Page・PageSize の 0 と空の SortField・SortOrder は未指定として listparams.Normalize でデフォルト値にする
+// SortField・SortOrder は一覧ごとに許可する値が異なるため、listparams.Spec で検証する
 type ListRequest struct {
-	Search    string `json:"search"`
-	Type      string `json:"type"`
-	Page      int    `json:"page"`
-	PageSize  int    `json:"page_size"`
-	SortField string `json:"sort_field"`
-	SortOrder string `json:"sort_order"`
-	Offset    int    `json:"-"`
+	Search    string `json:"search"     validate:"max=200"`
+	Type      string `json:"type"       validate:"oneof=partial prefix"`
+	Page      int    `json:"page"       validate:"min=0"`
+	PageSize  int    `json:"page_size"  validate:"min=0,max=100"`
+	SortField string `json:"sort_field" validate:"max=64"`
+	SortOrder string `json:"sort_order" validate:"max=8"`
+	Category  int    `json:"category"   validate:"min=0"`
+	// true の場合、検索結果と合わせてファセットの件数を返す（商品一覧のみ）
+	Facets bool `json:"facets"`
+	// キーセットページング用のカーソル（前のレスポンスの next_cursor）
+	Cursor string `json:"cursor" validate:"maxbytes=1024"`
+	Offset int    `json:"-"`
+}
+
+// Total は一覧の総件数
+type Total struct {
+	Count int
+	// 件数のキャッシュの古い値を返した場合 true（裏で再計算している）
+	Approximate bool
+}
+
+// 通知のチャネル
+const (
+	NotifyChannelLog     = "log"
+	NotifyChannelWebhook = "webhook"
+	NotifyChannelEmail   = "email"
+)
+
+// ユーザーごとの通知設定
+type NotificationPreferences struct {
+	// 配送完了を通知するか
+	DeliveryCompleted bool `json:"delivery_completed"`
+	// 通知に使うチャネル（log / webhook / email）。サーバーで有効になっていないチャネルには送らない
+	Channels []string `json:"channels" validate:"max=3"`
+	// email チャネルの宛先
+	Email string `json:"email" validate:"max=255"`
+}
+
+// ユーザーごとの表示設定
+type UserPreferences struct {
+	// IANA のタイムゾーン名（例: Asia/Tokyo）。注文一覧の日時はこのタイムゾーンに変換して返す
+	Timezone string `json:"timezone" validate:"required,max=64"`
+}
+
+// 配送完了を通知する注文と宛先
+// 通知には PublicID（orders.public_id が無いスキーマでは空）を使い、OrderID は出さない
+type DeliveryRecipient struct {
+	OrderID     int64  `db:"order_id"`
+	PublicID    string `db:"public_id"`
+	UserID      int    `db:"user_id"`
+	ProductName string `db:"product_name"`
+	// カンマ区切り
+	Channels string `db:"channels"`
+	Email    string `db:"email"`
+}
+
+// メンテナンス状態（管理者API）
+type MaintenanceState struct {
+	ReadOnly bool `json:"read_only"`
+}
+
+// 運用ダッシュボードの概要（管理者API）
+type AdminOverview struct {
+	// 状態ごとの注文数
+	Backlog []StatusCount `json:"backlog"`
+	// orders.updated_at が無いスキーマでは省略
+	ClaimThroughput *ClaimThroughput `json:"claim_throughput,omitempty"`
+	// 配送完了までの平均時間（arrived_at のある完了済みの注文が無い場合は省略）
+	AvgTimeToDeliverySeconds *float64 `json:"avg_time_to_delivery_seconds,omitempty"`
+	DeliveredCount           int      `json:"delivered_count"`
+	// 直近に配送計画の取得か位置の報告があったロボット
+	ActiveRobots []ActiveRobot `json:"active_robots"`
+	// スロークエリの集計（件数の多い順）
+	SlowQueries []SlowQueryStat `json:"slow_queries"`
+	GeneratedAt time.Time       `json:"generated_at"`
+}
+
+type StatusCount struct {
+	Status ShippedStatus `db:"shipped_status" json:"status"`
+	Count  int           `db:"cnt"            json:"count"`
+}
+
+// 直近 WindowSeconds 秒に配送待ちから先へ進んだ注文数
+// 状態の最終更新日時で数えるため、期間内に配送完了になった注文も含む
+type ClaimThroughput struct {
+	WindowSeconds int     `json:"window_seconds"`
+	Claimed       int     `json:"claimed"`
+	PerMinute     float64 `json:"per_minute"`
+}
+
+type ActiveRobot struct {
+	RobotID    string    `json:"robot_id"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+type SlowQueryStat struct {
+	Query   string  `json:"query"`
+	Count   int64   `json:"count"`
+	TotalMs float64 `json:"total_ms"`
+	MaxMs   float64 `json:"max_ms"`
+}
+
+// 日次の運用レポート（UTC の1日分）
+// ValueCreated は ValueCurrency（DefaultCurrency）の商品の注文のみを合計する
+type DailyReport struct {
+	// 対象日（YYYY-MM-DD）
+	Date            string `json:"date"`
+	OrdersCreated   int    `json:"orders_created"`
+	ValueCreated    int64  `json:"value_created"`
+	ValueCurrency   string `json:"value_currency"`
+	OrdersDelivered int    `json:"orders_delivered"`
+	// 配送完了までの平均時間（対象日に配送完了した注文が無い場合は省略）
+	AvgTimeToDeliverySeconds *float64 `json:"avg_time_to_delivery_seconds,omitempty"`
+	// 配送計画の積載率（レポートを作成したサーバーが計算した計画のみ）
+	Planner     PlannerUtilization `json:"planner"`
+	TopProducts []ProductSales     `json:"top_products"`
+	GeneratedAt time.Time          `json:"generated_at"`
+}
+
+// 配送計画の積載率。Utilization は計画の重量の合計を積載可能な重量の合計で割った値（計画が無い場合は省略）
+type PlannerUtilization struct {
+	Plans         int      `json:"plans"`
+	OrdersPlanned int      `json:"orders_planned"`
+	TotalWeight   int64    `json:"total_weight"`
+	TotalCapacity int64    `json:"total_capacity"`
+	Utilization   *float64 `json:"utilization,omitempty"`
+}
+
+// 期間内に注文の多かった商品
+type ProductSales struct {
+	ProductID int    `db:"product_id" json:"product_id"`
+	Name      string `db:"name"       json:"name"`
+	Orders    int    `db:"orders"     json:"orders"`
+	Value     int64  `db:"total_value" json:"value"`
+	Currency  string `db:"currency"    json:"currency"`
+}
+
+// 書き出し済みのレポート（管理者API）
+type ReportFile struct {
+	Name       string    `json:"name"`
+	Date       string    `json:"date"`
+	Format     string    `json:"format"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// 注文の集計の期間
+const (
+	SummaryPeriodHour = "hour"
+	SummaryPeriodDay  = "day"
+)
+
+// 時間・日ごとの注文の集計（管理者API）
+// 作成された注文は created_at、配送完了した注文は arrived_at の期間に数える
+// ValueCreated は DefaultCurrency の商品の注文のみを合計する
+type OrderSummary struct {
+	Period          string    `db:"period"           json:"period"`
+	BucketStart     time.Time `db:"bucket_start"     json:"bucket_start"`
+	OrdersCreated   int       `db:"orders_created"   json:"orders_created"`
+	ValueCreated    int64     `db:"value_created"    json:"value_created"`
+	OrdersDelivered int       `db:"orders_delivered" json:"orders_delivered"`
+	// 配送完了までの時間の合計
+	DeliverySeconds float64 `db:"delivery_seconds" json:"-"`
+	// 配送完了までの平均時間（期間内に配送完了した注文が無い場合は省略）
+	AvgTimeToDeliverySeconds *float64 `db:"-" json:"avg_time_to_delivery_seconds,omitempty"`
 }
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// DefaultCurrency は通貨を指定しない商品の通貨（products.currency の既定値と揃える）
+const DefaultCurrency = "JPY"
+
+var (
+	// 異なる通貨の金額を計算しようとした場合に返す
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	// 計算結果が int64 に収まらない場合に返す
+	ErrMoneyOverflow = errors.New("money overflow")
+)
+
+// Money は通貨の最小単位（JPY は円、USD はセント）の金額と ISO 4217 の通貨コード
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// NewMoney は amount と通貨コードの金額を返す。通貨が空の場合は DefaultCurrency にする
+func NewMoney(amount int64, currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ValidCurrency は code が ISO 4217 の形式（英大文字3文字）かを返す
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		if code[i] < 'A' || code[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Add は m と o の合計を返す。通貨が異なる場合は ErrCurrencyMismatch、桁あふれする場合は ErrMoneyOverflow
+// 通貨が空の金額（Money{}）は 0 として扱い、もう一方の通貨に合わせる
+func (m Money) Add(o Money) (Money, error) {
+	switch {
+	case m.Currency == "" && m.Amount == 0:
+		return o, nil
+	case o.Currency == "" && o.Amount == 0:
+		return m, nil
+	case m.Currency != o.Currency:
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	if (o.Amount > 0 && m.Amount > math.MaxInt64-o.Amount) || (o.Amount < 0 && m.Amount < math.MinInt64-o.Amount) {
+		return Money{}, fmt.Errorf("%w: %d + %d %s", ErrMoneyOverflow, m.Amount, o.Amount, m.Currency)
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Mul は m の n 倍を返す。桁あふれする場合は ErrMoneyOverflow
+func (m Money) Mul(n int64) (Money, error) {
+	if m.Amount == 0 || n == 0 {
+		return Money{Amount: 0, Currency: m.Currency}, nil
+	}
+	product := m.Amount * n
+	if product/n != m.Amount || (m.Amount == -1 && n == math.MinInt64) || (n == -1 && m.Amount == math.MinInt64) {
+		return Money{}, fmt.Errorf("%w: %d * %d %s", ErrMoneyOverflow, m.Amount, n, m.Currency)
+	}
+	return Money{Amount: product, Currency: m.Currency}, nil
+}
+
+// Int は金額を int で返す。int に収まらない場合は ErrMoneyOverflow
+func (m Money) Int() (int, error) {
+	if int64(int(m.Amount)) != m.Amount {
+		return 0, fmt.Errorf("%w: %d %s does not fit in int", ErrMoneyOverflow, m.Amount, m.Currency)
+	}
+	return int(m.Amount), nil
+}
+
+func (m Money) String() string {
+	return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+}
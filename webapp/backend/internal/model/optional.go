@@ -0,0 +1,29 @@
+package model
+
+import "encoding/json"
+
+// OptionalInt は JSON で省略された（Set が false）か、null を指定された（Value が nil）かを区別する整数
+// 部分的な更新で「変更しない」と「値を消す」を分けるために使う
+type OptionalInt struct {
+	Set   bool
+	Value *int
+}
+
+// OpenAPIType は API の仕様での型（null も受け付ける）
+func (o OptionalInt) OpenAPIType() (string, string) {
+	return "integer", ""
+}
+
+func (o OptionalInt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.Value)
+}
+
+// UnmarshalJSON はフィールドが存在する場合のみ呼ばれるため、呼ばれた時点で Set にする
+func (o *OptionalInt) UnmarshalJSON(data []byte) error {
+	var v *int
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*o = OptionalInt{Set: true, Value: v}
+	return nil
+}
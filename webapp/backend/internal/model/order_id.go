@@ -0,0 +1,82 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"backend/internal/ulid"
+)
+
+// ExternalID は API で返す注文ID（公開ID）
+// 連番の order_id は件数や作成のペースが推測できるため、公開IDの代わりには返さない
+// リポジトリは公開IDの無い注文を読んだ時点で割り当てるため、空になるのは orders.public_id が無いスキーマのみ
+func (o *Order) ExternalID() string {
+	return o.PublicID
+}
+
+// OrderRef は API で受け取る注文ID
+// 公開ID（ULID の文字列）を受け付け、既存のクライアントとの互換のため order_id（数値、または数字の文字列）も受け付ける
+type OrderRef struct {
+	ID       int64
+	PublicID string
+}
+
+// IsZero は注文IDが指定されていないかを返す
+func (r OrderRef) IsZero() bool {
+	return r.ID <= 0 && r.PublicID == ""
+}
+
+func (r OrderRef) String() string {
+	if r.PublicID != "" {
+		return r.PublicID
+	}
+	return strconv.FormatInt(r.ID, 10)
+}
+
+// OpenAPIType は API の仕様での型（数値も受け付けるが、公開IDの文字列として載せる）
+func (r OrderRef) OpenAPIType() (string, string) {
+	return "string", "ulid"
+}
+
+func (r OrderRef) MarshalJSON() ([]byte, error) {
+	if r.PublicID != "" {
+		return json.Marshal(r.PublicID)
+	}
+	return json.Marshal(r.ID)
+}
+
+func (r *OrderRef) UnmarshalJSON(data []byte) error {
+	if !bytes.HasPrefix(data, []byte(`"`)) {
+		var id int64
+		if err := json.Unmarshal(data, &id); err != nil {
+			return fmt.Errorf("order_id must be a string or an integer")
+		}
+		*r = OrderRef{ID: id}
+		return nil
+	}
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	ref, err := ParseOrderRef(v)
+	if err != nil {
+		return err
+	}
+	*r = ref
+	return nil
+}
+
+// ParseOrderRef は公開ID（ULID）または数字の order_id の文字列を OrderRef にする
+func ParseOrderRef(v string) (OrderRef, error) {
+	if ulid.Valid(v) {
+		return OrderRef{PublicID: strings.ToUpper(v)}, nil
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id <= 0 {
+		return OrderRef{}, fmt.Errorf("order_id %q is not a valid order ID", v)
+	}
+	return OrderRef{ID: id}, nil
+}
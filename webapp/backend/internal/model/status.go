@@ -0,0 +1,111 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// ShippedStatus は注文の配送状況（orders.shipped_status）
+type ShippedStatus string
+
+const (
+	// 配送待ち（注文作成時の状態）
+	StatusShipping ShippedStatus = "shipping"
+	// ロボットが引き受けて配送中
+	StatusDelivering ShippedStatus = "delivering"
+	// 配送完了
+	StatusCompleted ShippedStatus = "completed"
+	// 配送前に取り消された
+	StatusCancelled ShippedStatus = "cancelled"
+	// 配送に失敗した（配送待ちへ戻して再配送できる）
+	StatusFailed ShippedStatus = "failed"
+)
+
+// statusTransitions は状態ごとに遷移できる先の状態
+// 配送中から配送待ちへの遷移は、ロボットが応答しなくなった注文を戻すためのもの
+var statusTransitions = map[ShippedStatus][]ShippedStatus{
+	StatusShipping:   {StatusDelivering, StatusCancelled},
+	StatusDelivering: {StatusCompleted, StatusShipping, StatusFailed},
+	StatusFailed:     {StatusShipping, StatusCancelled},
+	StatusCompleted:  nil,
+	StatusCancelled:  nil,
+}
+
+// Valid は s が定義済みの状態かを返す
+func (s ShippedStatus) Valid() bool {
+	_, ok := statusTransitions[s]
+	return ok
+}
+
+func (s ShippedStatus) String() string {
+	return string(s)
+}
+
+// CanTransition は from から to へ状態を変更できるかを返す（同じ状態への変更は false）
+func CanTransition(from, to ShippedStatus) bool {
+	for _, next := range statusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseShippedStatus は文字列を状態に変換する。定義されていない値はエラーにする
+func ParseShippedStatus(v string) (ShippedStatus, error) {
+	s := ShippedStatus(v)
+	if !s.Valid() {
+		return "", fmt.Errorf("unknown shipped status %q", v)
+	}
+	return s, nil
+}
+
+func (s ShippedStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(s))
+}
+
+func (s *ShippedStatus) UnmarshalJSON(data []byte) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	// 空文字は未指定として残し、必須かどうかは validate タグに任せる
+	if v == "" {
+		*s = ""
+		return nil
+	}
+	parsed, err := ParseShippedStatus(v)
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// Value は DB へ書き込む値を返す
+func (s ShippedStatus) Value() (driver.Value, error) {
+	if !s.Valid() {
+		return nil, fmt.Errorf("unknown shipped status %q", string(s))
+	}
+	return string(s), nil
+}
+
+// Scan は DB から読み込んだ値を状態に変換する
+func (s *ShippedStatus) Scan(src interface{}) error {
+	var v string
+	switch src := src.(type) {
+	case string:
+		v = src
+	case []byte:
+		v = string(src)
+	default:
+		return fmt.Errorf("cannot scan %T into ShippedStatus", src)
+	}
+	parsed, err := ParseShippedStatus(v)
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"time"
+	// 実行環境に zoneinfo が無くてもタイムゾーンを読み込めるよう、バイナリに埋め込む
+	_ "time/tzdata"
+)
+
+// DefaultTimezone はタイムゾーンを設定していないユーザーのタイムゾーン（users.timezone の既定値と揃える）
+const DefaultTimezone = "UTC"
+
+// IANA のタイムゾーン名として解釈できない場合に返す
+var ErrInvalidTimezone = errors.New("invalid timezone")
+
+// LoadTimezone は IANA のタイムゾーン名を読み込む。解釈できない場合は ErrInvalidTimezone を返す
+// サーバーのローカル時刻（Local）はサーバーごとに異なるため受け付けない
+func LoadTimezone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "Local" {
+		return nil, ErrInvalidTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	return loc, nil
+}
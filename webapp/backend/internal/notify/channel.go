@@ -0,0 +1,145 @@
+// Package notify はユーザーへの通知（配送完了など）をチャネル（ログ・Webhook・メール）で送る
+//
+// 使うチャネルはサーバーの設定（NOTIFY_CHANNELS）で有効にし、各ユーザーが通知設定でその中から選ぶ
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/model"
+	"backend/internal/telemetry"
+)
+
+// Notification は1件の通知（OrderID は注文の公開ID）
+type Notification struct {
+	// 通知の種類（配送完了は order.delivered）
+	Type    string `json:"type"`
+	UserID  int    `json:"user_id"`
+	OrderID string `json:"order_id"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	// email チャネルの宛先
+	Email string `json:"-"`
+}
+
+// Channel は通知を送る
+// Send は送信の完了を待って戻る。失敗した通知は再送しない
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, n Notification) error
+}
+
+// NewChannels は cfg.Channels で有効にしたチャネルをチャネル名ごとに返す
+func NewChannels(cfg config.Notify) (map[string]Channel, error) {
+	channels := make(map[string]Channel)
+	for _, name := range cfg.Channels {
+		switch name = strings.ToLower(name); name {
+		case model.NotifyChannelLog:
+			channels[name] = LogChannel{}
+		case model.NotifyChannelWebhook:
+			channels[name] = NewWebhookChannel(cfg.WebhookURL)
+		case model.NotifyChannelEmail:
+			channels[name] = NewSMTPChannel(cfg.SMTPAddr, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+		default:
+			return nil, fmt.Errorf("notify: unknown channel %q", name)
+		}
+	}
+	return channels, nil
+}
+
+// LogChannel は通知をログに出力する（動作確認用）
+type LogChannel struct{}
+
+func (LogChannel) Name() string { return model.NotifyChannelLog }
+
+func (LogChannel) Send(ctx context.Context, n Notification) error {
+	slog.InfoContext(ctx, "notify: notification", "type", n.Type, "user_id", n.UserID, "order_id", n.OrderID, "subject", n.Subject)
+	return nil
+}
+
+// WebhookChannel は通知をJSONで指定URLにPOSTする
+// 送信先は全ユーザーで共通のため、ユーザーへの配送（プッシュ通知など）は送信先が user_id をもとに行う
+type WebhookChannel struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookChannel(url string) *WebhookChannel {
+	return &WebhookChannel{url: url, client: &http.Client{Timeout: 5 * time.Second, Transport: telemetry.Transport(nil)}}
+}
+
+func (c *WebhookChannel) Name() string { return model.NotifyChannelWebhook }
+
+func (c *WebhookChannel) Send(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %d", res.StatusCode)
+	}
+	return nil
+}
+
+// SMTPChannel は通知をメールで送る
+// 宛先（通知設定の email）が無いユーザーには送らない
+type SMTPChannel struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTPChannel(addr, username, password, from string) *SMTPChannel {
+	c := &SMTPChannel{addr: addr, from: from}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		c.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return c
+}
+
+func (c *SMTPChannel) Name() string { return model.NotifyChannelEmail }
+
+func (c *SMTPChannel) Send(ctx context.Context, n Notification) error {
+	if n.Email == "" {
+		return nil
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", c.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", n.Email)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", n.Subject))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(n.Body)
+	msg.WriteString("\r\n")
+
+	// net/smtp は ctx に対応していないため、キャンセルされた場合は送信の完了を待たずに戻る
+	done := make(chan error, 1)
+	go func() { done <- smtp.SendMail(c.addr, c.auth, c.from, []string{n.Email}, msg.Bytes()) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
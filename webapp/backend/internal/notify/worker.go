@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/events"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/telemetry"
+)
+
+// 1件の通知をチャネルへ送る時間の上限
+const sendTimeout = 10 * time.Second
+
+// Worker は配送完了のイベントを受け取り、通知設定で配送完了の通知を有効にしているユーザーへ通知する
+// イベントはこのサーバー内のバス（events.Bus）から受け取るため、1件の配送完了は状態を更新したサーバーだけが通知する
+// 送信待ちの通知はメモリに保持するため、上限を超えた場合やサーバーの停止時には送られない
+type Worker struct {
+	repo     repository.Notifications
+	channels map[string]Channel
+	queue    chan []int64
+}
+
+// cfg.Channels が空の場合は nil を返す
+func NewWorker(repo repository.Notifications, cfg config.Notify) (*Worker, error) {
+	if len(cfg.Channels) == 0 {
+		return nil, nil
+	}
+	channels, err := NewChannels(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Worker{repo: repo, channels: channels, queue: make(chan []int64, cfg.QueueSize)}, nil
+}
+
+// Subscribe は配送完了のイベントを購読する。状態を更新したリクエストは待たせない
+func (w *Worker) Subscribe(bus *events.Bus) {
+	bus.Subscribe(events.TypeOrderDelivered, func(ctx context.Context, ev events.Event) {
+		changed, ok := ev.Payload.(events.OrderStatusChanged)
+		if !ok || len(changed.OrderIDs) == 0 {
+			return
+		}
+		select {
+		case w.queue <- changed.OrderIDs:
+		default:
+			slog.WarnContext(ctx, "notify: queue is full; dropping delivery notifications", "orders", len(changed.OrderIDs))
+			telemetry.RecordNotification(ctx, "", telemetry.NotificationDropped)
+		}
+	})
+}
+
+// Run は ctx がキャンセルされるまで通知を送る
+func (w *Worker) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case orderIDs := <-w.queue:
+			if err := w.notifyDelivered(ctx, orderIDs); err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "notify: failed to look up delivery recipients", "err", err)
+			}
+		}
+	}
+}
+
+func (w *Worker) notifyDelivered(ctx context.Context, orderIDs []int64) error {
+	recipients, err := w.repo.DeliveryRecipients(ctx, orderIDs)
+	if err != nil {
+		return err
+	}
+	for _, r := range recipients {
+		n := Notification{
+			Type:    events.TypeOrderDelivered,
+			UserID:  r.UserID,
+			OrderID: r.PublicID,
+			Subject: "ご注文の商品が届きました",
+			Body:    deliveredBody(r),
+			Email:   r.Email,
+		}
+		for _, name := range repository.SplitChannels(r.Channels) {
+			ch, ok := w.channels[name]
+			if !ok {
+				continue
+			}
+			w.send(ctx, ch, n)
+		}
+	}
+	return nil
+}
+
+func (w *Worker) send(ctx context.Context, ch Channel, n Notification) {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	if err := ch.Send(ctx, n); err != nil {
+		slog.WarnContext(ctx, "notify: failed to send notification", "channel", ch.Name(), "user_id", n.UserID, "order_id", n.OrderID, "err", err)
+		telemetry.RecordNotification(ctx, ch.Name(), telemetry.NotificationFailed)
+		return
+	}
+	telemetry.RecordNotification(ctx, ch.Name(), telemetry.NotificationSent)
+}
+
+// deliveredBody は配送完了の通知の本文を返す
+// 注文番号には公開IDを使い、公開IDが無い場合は注文番号を載せない（order_id は出さない）
+func deliveredBody(r model.DeliveryRecipient) string {
+	if r.PublicID == "" {
+		return fmt.Sprintf("ご注文の商品（%s）の配送が完了しました。", r.ProductName)
+	}
+	return fmt.Sprintf("注文番号 %s（%s）の配送が完了しました。", r.PublicID, r.ProductName)
+}
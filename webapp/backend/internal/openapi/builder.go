@@ -0,0 +1,179 @@
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// セキュリティスキーム名
+const (
+	SecuritySession  = "session"
+	SecurityRobotKey = "robotApiKey"
+)
+
+// Param はクエリパラメータ
+type Param struct {
+	Name        string
+	Description string
+	Required    bool
+	// スキーマの元にする値（0, "" など）。nil の場合は string
+	Type interface{}
+}
+
+// Operation はルート1つ分の説明。Request / Responses の値は型のみを使う（ゼロ値でよい）
+type Operation struct {
+	Method      string
+	Path        string // chi のパターン（/api/products/{productID} など）
+	Summary     string
+	Description string
+	Tag         string
+	Security    string // SecuritySession / SecurityRobotKey、空の場合は認証不要
+	Query       []Param
+	Request     interface{}
+	// ステータスコードごとのレスポンスの型。nil の値は本文なし
+	Responses map[int]interface{}
+	// JSON 以外のレスポンス（画像など）の Content-Type
+	ResponseContentType string
+	// 共通のエラーレスポンスの型と Content-Type。nil の場合は NewBuilder の errorBody（application/json）
+	ErrorBody        interface{}
+	ErrorContentType string
+}
+
+// Builder は Operation を集めて Document を組み立てる
+type Builder struct {
+	doc      *Document
+	registry *schemaRegistry
+	errorRef *Schema
+}
+
+// NewBuilder は errorBody をエラーレスポンス（4xx / 5xx）の型として使う
+func NewBuilder(info Info, errorBody interface{}) *Builder {
+	b := &Builder{
+		doc: &Document{
+			OpenAPI: "3.0.3",
+			Info:    info,
+			Paths:   make(map[string]*PathItem),
+			Components: Components{
+				SecuritySchemes: map[string]*SecurityScheme{
+					SecuritySession:  {Type: "apiKey", In: "cookie", Name: "session_id", Description: "POST /api/login で発行されるセッション"},
+					SecurityRobotKey: {Type: "apiKey", In: "header", Name: "X-API-KEY", Description: "配送ロボット用のAPIキー"},
+				},
+			},
+		},
+		registry: newSchemaRegistry(),
+	}
+	b.errorRef = b.registry.NamedSchemaOf(errorBody, "Error")
+	return b
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// Add は操作を追加する
+func (b *Builder) Add(op Operation) {
+	path := pathParamPattern.ReplaceAllString(op.Path, "{$1}")
+	item := b.doc.Paths[path]
+	if item == nil {
+		item = &PathItem{}
+		b.doc.Paths[path] = item
+	}
+
+	o := &OperationObject{
+		Summary:     op.Summary,
+		Description: op.Description,
+		Responses:   make(map[string]*Response),
+	}
+	if op.Tag != "" {
+		o.Tags = []string{op.Tag}
+	}
+	if op.Security != "" {
+		o.Security = []map[string][]string{{op.Security: {}}}
+	}
+	for _, m := range pathParamPattern.FindAllStringSubmatch(op.Path, -1) {
+		schema := &Schema{Type: "string"}
+		if strings.HasSuffix(m[1], "ID") {
+			schema = &Schema{Type: "integer", Format: "int64"}
+		}
+		o.Parameters = append(o.Parameters, ParameterObject{Name: m[1], In: "path", Required: true, Schema: schema})
+	}
+	for _, p := range op.Query {
+		var schema *Schema
+		if p.Type == nil {
+			schema = &Schema{Type: "string"}
+		} else {
+			schema = b.registry.SchemaOf(p.Type)
+		}
+		o.Parameters = append(o.Parameters, ParameterObject{Name: p.Name, In: "query", Description: p.Description, Required: p.Required, Schema: schema})
+	}
+	if op.Request != nil {
+		o.RequestBody = &RequestBodyObject{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: b.registry.SchemaOf(op.Request)}},
+		}
+	}
+
+	for status, body := range op.Responses {
+		resp := &Response{Description: http.StatusText(status)}
+		if body != nil {
+			contentType := "application/json"
+			schema := b.registry.SchemaOf(body)
+			if op.ResponseContentType != "" && status < 300 {
+				contentType = op.ResponseContentType
+				schema = &Schema{Type: "string"}
+				if !strings.HasPrefix(contentType, "text/") {
+					schema.Format = "binary"
+				}
+			}
+			resp.Content = map[string]MediaType{contentType: {Schema: schema}}
+		}
+		o.Responses[strconv.Itoa(status)] = resp
+	}
+	// 共通のエラーレスポンス
+	errorStatuses := []int{http.StatusBadRequest, http.StatusTooManyRequests, http.StatusInternalServerError}
+	if op.Security != "" {
+		errorStatuses = append(errorStatuses, http.StatusUnauthorized, http.StatusForbidden)
+	}
+	errorContent := map[string]MediaType{"application/json": {Schema: b.errorRef}}
+	if op.ErrorBody != nil {
+		contentType := op.ErrorContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		errorContent = map[string]MediaType{contentType: {Schema: b.registry.SchemaOf(op.ErrorBody)}}
+	}
+	for _, status := range errorStatuses {
+		key := strconv.Itoa(status)
+		if _, ok := o.Responses[key]; !ok {
+			o.Responses[key] = &Response{
+				Description: http.StatusText(status),
+				Content:     errorContent,
+			}
+		}
+	}
+
+	(*item)[strings.ToLower(op.Method)] = o
+}
+
+// AddUndocumented はルーターにはあるが Operation が無いルートを、説明なしで追加する
+// ドキュメントの書き漏れがあっても、ルートの存在だけは分かるようにする
+func (b *Builder) AddUndocumented(method, path string) {
+	p := pathParamPattern.ReplaceAllString(path, "{$1}")
+	if item := b.doc.Paths[p]; item != nil {
+		if _, ok := (*item)[strings.ToLower(method)]; ok {
+			return
+		}
+	}
+	b.Add(Operation{
+		Method:    method,
+		Path:      path,
+		Summary:   "(undocumented)",
+		Responses: map[int]interface{}{http.StatusOK: nil},
+	})
+}
+
+// Document は組み立てたドキュメントを返す
+func (b *Builder) Document() *Document {
+	b.doc.Components.Schemas = b.registry.schemas
+	return b.doc
+}
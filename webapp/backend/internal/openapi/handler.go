@@ -0,0 +1,34 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+//go:embed swagger.html
+var swaggerHTML string
+
+// Handler はドキュメントを JSON で返す
+func Handler(doc *Document) http.HandlerFunc {
+	body, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic("openapi: failed to encode document: " + err.Error())
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write(body)
+	}
+}
+
+// UIHandler は specURL のドキュメントを表示する Swagger UI のページを返す
+// Swagger UI 本体（JS / CSS）は CDN から読み込む
+func UIHandler(specURL string) http.HandlerFunc {
+	page := strings.ReplaceAll(swaggerHTML, "{{SPEC_URL}}", specURL)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(page))
+	}
+}
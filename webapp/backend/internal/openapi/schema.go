@@ -0,0 +1,221 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaRegistry は名前付きの struct を components.schemas に登録し、$ref で参照する
+type schemaRegistry struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{schemas: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+// SchemaOf は v の型のスキーマを返す（v はゼロ値でよい）
+func (r *schemaRegistry) SchemaOf(v interface{}) *Schema {
+	return r.schema(reflect.TypeOf(v))
+}
+
+// Typer は JSON での形を独自に決める型（json.Marshaler など）が実装し、スキーマの type / format を返す
+type Typer interface {
+	OpenAPIType() (typ, format string)
+}
+
+var typerType = reflect.TypeOf((*Typer)(nil)).Elem()
+
+func (r *schemaRegistry) schema(t reflect.Type) *Schema {
+	if t.Kind() != reflect.Pointer && t.Implements(typerType) {
+		typ, format := reflect.Zero(t).Interface().(Typer).OpenAPIType()
+		return &Schema{Type: typ, Format: format}
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := r.schema(t.Elem())
+		if s.Ref != "" {
+			return s
+		}
+		c := *s
+		c.Nullable = true
+		return &c
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: r.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		return r.ref(t)
+	}
+	// interface{} など型を決められないもの
+	return &Schema{}
+}
+
+func (r *schemaRegistry) ref(t reflect.Type) *Schema {
+	name, ok := r.names[t]
+	if !ok {
+		name = componentName(t)
+		// 別パッケージの同名の型は番号を付けて区別する
+		for i := 2; r.schemas[name] != nil; i++ {
+			name = componentName(t) + strconv.Itoa(i)
+		}
+		r.define(t, name)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// NamedSchemaOf は v の型を name という名前のコンポーネントとして登録する
+func (r *schemaRegistry) NamedSchemaOf(v interface{}, name string) *Schema {
+	t := reflect.TypeOf(v)
+	if _, ok := r.names[t]; !ok {
+		r.define(t, name)
+	}
+	return r.ref(t)
+}
+
+func (r *schemaRegistry) define(t reflect.Type, name string) {
+	r.names[t] = name
+	r.schemas[name] = &Schema{} // 再帰的な型に備えて先に登録する
+	*r.schemas[name] = *r.structSchema(t)
+}
+
+// ジェネリクスの型名 ListResponse[backend/internal/model.Order] は ListResponseOrder にする
+func componentName(t reflect.Type) string {
+	name := t.Name()
+	base, args, ok := strings.Cut(name, "[")
+	if !ok {
+		return name
+	}
+	var b strings.Builder
+	b.WriteString(base)
+	for _, arg := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+		arg = strings.TrimPrefix(strings.TrimSpace(arg), "*")
+		if i := strings.LastIndexAny(arg, "./"); i >= 0 {
+			arg = arg[i+1:]
+		}
+		b.WriteString(strings.ToUpper(arg[:1]) + arg[1:])
+	}
+	return b.String()
+}
+
+func (r *schemaRegistry) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	r.addFields(s, t)
+	return s
+}
+
+func (r *schemaRegistry) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		// 埋め込みの struct は encoding/json と同じく展開する
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				r.addFields(s, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fs := r.schema(f.Type)
+		if applyValidateTag(&fs, f.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = fs
+	}
+}
+
+// validate タグ（internal/validation のルール）をスキーマの制約に変換し、required かどうかを返す
+func applyValidateTag(sp **Schema, tag string) bool {
+	if tag == "" {
+		return false
+	}
+	s := *sp
+	if s.Ref != "" {
+		// $ref には制約を並べられないため、required のみ反映する
+		return strings.Contains(","+tag+",", ",required,")
+	}
+	c := *s
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		n, _ := strconv.ParseFloat(param, 64)
+		switch name {
+		case "required":
+			required = true
+		case "min":
+			switch c.Type {
+			case "string":
+				c.MinLength = intPtr(int(n))
+			case "array":
+				c.MinItems = intPtr(int(n))
+			default:
+				c.Minimum = &n
+			}
+		case "max":
+			switch c.Type {
+			case "string":
+				c.MaxLength = intPtr(int(n))
+			case "array":
+				c.MaxItems = intPtr(int(n))
+			default:
+				c.Maximum = &n
+			}
+		case "maxbytes":
+			c.MaxLength = intPtr(int(n))
+		case "oneof":
+			c.Enum = strings.Fields(param)
+		}
+	}
+	*sp = &c
+	return required
+}
+
+func intPtr(n int) *int {
+	return &n
+}
@@ -0,0 +1,181 @@
+// Package outbox はアウトボックスに記録されたイベントを外部へ配信するリレーを提供する
+//
+// イベントは注文を変更するトランザクション内で outbox テーブルに記録され、
+// リレーがポーリングして記録順に配信する。配信に成功したものだけを配信済みにするため at-least-once となる
+// 複数台のサーバーでリレーを起動した場合は、DB 上のリース（outbox_relay_lease）を持つ1台だけが配信する
+package outbox
+
+import (
+	"backend/internal/config"
+	"backend/internal/messaging"
+	"backend/internal/repository"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 100
+	defaultRetention    = 24 * time.Hour
+	purgeInterval       = time.Minute
+	purgeBatchSize      = 1000
+	// リースの期限。持ち主はポーリングごとに延長し、止まった場合はこの時間の後に他のサーバーが引き継ぐ
+	// 1バッチの配信がこれより長くかかると引き継ぎと重なり、同じイベントが二重に配信されうる
+	relayLease = 30 * time.Second
+)
+
+type Relay struct {
+	repo repository.Outbox
+	sink Sink
+	// リースの持ち主として記録する、このリレーを識別する値
+	owner        string
+	pollInterval time.Duration
+	batchSize    int
+	retention    time.Duration
+}
+
+func NewRelay(repo repository.Outbox, sink Sink) *Relay {
+	return &Relay{
+		repo:         repo,
+		sink:         sink,
+		owner:        relayOwner(),
+		pollInterval: defaultPollInterval,
+		batchSize:    defaultBatchSize,
+		retention:    defaultRetention,
+	}
+}
+
+// NewRelayFromConfig は cfg.Sink で指定された配信先のリレーを返す（未指定なら nil）
+//   - Sink: log / webhook / kafka / nats
+//   - WebhookURL: webhook の送信先
+//   - PollInterval / BatchSize / Retention: ポーリング間隔、1回の配信件数、配信済みイベントの保持期間
+//
+// kafka / nats の接続先と送信先のトピックは broker で指定する
+func NewRelayFromConfig(repo repository.Outbox, cfg config.Outbox, broker config.Messaging) (*Relay, error) {
+	var sink Sink
+	switch name := cfg.Sink; name {
+	case "":
+		return nil, nil
+	case "log":
+		sink = LogSink{}
+	case "webhook":
+		if cfg.WebhookURL == "" {
+			return nil, fmt.Errorf("outbox: OUTBOX_WEBHOOK_URL is required for the webhook sink")
+		}
+		sink = NewWebhookSink(cfg.WebhookURL)
+	case "kafka", "nats":
+		publisher, err := messaging.New(name, broker)
+		if err != nil {
+			return nil, fmt.Errorf("outbox: %w", err)
+		}
+		sink = NewBrokerSink(publisher, broker.TopicPrefix)
+	default:
+		return nil, fmt.Errorf("outbox: unknown OUTBOX_SINK %q", name)
+	}
+
+	r := NewRelay(repo, sink)
+	r.pollInterval = cfg.PollInterval
+	r.batchSize = cfg.BatchSize
+	r.retention = cfg.Retention
+	return r, nil
+}
+
+// Run は ctx がキャンセルされるまでポーリングを続ける
+// 配信先が io.Closer を実装している場合は、終了時に閉じる
+func (r *Relay) Run(ctx context.Context) {
+	if closer, ok := r.sink.(io.Closer); ok {
+		defer func() {
+			if err := closer.Close(); err != nil {
+				slog.Warn("outbox: failed to close sink", "err", err)
+			}
+		}()
+	}
+	defer r.release()
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+	lastPurge := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// 溜まっている間は待たずに続けて配信する（リースはバッチごとに延長する）
+		for ctx.Err() == nil {
+			held, err := r.repo.AcquireRelayLease(ctx, r.owner, relayLease)
+			if err != nil {
+				slog.ErrorContext(ctx, "outbox: failed to acquire relay lease", "err", err)
+				break
+			}
+			if !held {
+				break
+			}
+			n, err := r.relayOnce(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "outbox: relay failed", "err", err)
+				break
+			}
+			if n < r.batchSize {
+				break
+			}
+		}
+		if time.Since(lastPurge) >= purgeInterval {
+			lastPurge = time.Now()
+			if _, err := r.repo.PurgePublished(ctx, time.Now().UTC().Add(-r.retention), purgeBatchSize); err != nil {
+				slog.ErrorContext(ctx, "outbox: failed to purge published events", "err", err)
+			}
+		}
+	}
+}
+
+// 終了時にリースを手放す（ctx はキャンセル済みのため、短いタイムアウトで別に実行する）
+func (r *Relay) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := r.repo.ReleaseRelayLease(ctx, r.owner); err != nil {
+		slog.Warn("outbox: failed to release relay lease", "err", err)
+	}
+}
+
+// ホスト名・プロセスID・乱数から、サーバーごとに異なる持ち主の名前を作る
+func relayOwner() string {
+	host, _ := os.Hostname()
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(b[:]))
+}
+
+// relayOnce は未配信のイベントを1バッチ分配信し、配信できた件数を返す
+// 失敗したイベントがあればそこで打ち切り、以降のイベントは次回に回して記録順を保つ
+func (r *Relay) relayOnce(ctx context.Context) (int, error) {
+	pending, err := r.repo.ListPending(ctx, r.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pending events: %w", err)
+	}
+
+	published := make([]int64, 0, len(pending))
+	var publishErr error
+	for _, ev := range pending {
+		if err := r.sink.Publish(ctx, ev); err != nil {
+			if recErr := r.repo.RecordFailure(ctx, ev.EventID, err); recErr != nil {
+				slog.ErrorContext(ctx, "outbox: failed to record failure", "event_id", ev.EventID, "err", recErr)
+			}
+			publishErr = fmt.Errorf("failed to publish event %d (attempt %d): %w", ev.EventID, ev.Attempts+1, err)
+			break
+		}
+		published = append(published, ev.EventID)
+	}
+	if err := r.repo.MarkPublished(ctx, published); err != nil {
+		return 0, fmt.Errorf("failed to mark events as published: %w", err)
+	}
+	if publishErr != nil {
+		return len(published), publishErr
+	}
+	return len(published), nil
+}
@@ -0,0 +1,113 @@
+package outbox
+
+import (
+	"backend/internal/messaging"
+	"backend/internal/model"
+	"backend/internal/telemetry"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Sink はアウトボックスのイベントの配信先
+// Publish がエラーを返したイベントは再送されるため、配信先は event_id で重複を排除すること
+type Sink interface {
+	Publish(ctx context.Context, ev model.OutboxEvent) error
+}
+
+// Message は配信先へ送るイベントの形式（AggregateID は注文の公開ID）
+type Message struct {
+	EventID     int64           `json:"event_id"`
+	Type        string          `json:"type"`
+	AggregateID string          `json:"aggregate_id"`
+	Payload     json.RawMessage `json:"payload"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+}
+
+func NewMessage(ev model.OutboxEvent) Message {
+	return Message{
+		EventID:     ev.EventID,
+		Type:        ev.EventType,
+		AggregateID: ev.AggregateKey,
+		Payload:     json.RawMessage(ev.Payload),
+		OccurredAt:  ev.CreatedAt,
+	}
+}
+
+// LogSink はイベントをログに出力する（動作確認用）
+type LogSink struct{}
+
+func (LogSink) Publish(ctx context.Context, ev model.OutboxEvent) error {
+	slog.InfoContext(ctx, "outbox: event", "event_id", ev.EventID, "type", ev.EventType, "aggregate_id", ev.AggregateKey, "payload", string(ev.Payload))
+	return nil
+}
+
+// WebhookSink はイベントをJSONで指定URLにPOSTする
+// 2xx 以外の応答は失敗として扱い、X-Event-ID ヘッダで重複排除用のIDを渡す
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{url: url, client: &http.Client{Timeout: 5 * time.Second, Transport: telemetry.Transport(nil)}}
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, ev model.OutboxEvent) error {
+	body, err := json.Marshal(NewMessage(ev))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", strconv.FormatInt(ev.EventID, 10))
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %d", res.StatusCode)
+	}
+	return nil
+}
+
+// BrokerSink はイベントをメッセージブローカー（Kafka / NATS）へ送る
+// 送信先はトピックの接頭辞にイベント種別を続けたもの（backend.order.created など）
+// キーを集約ID（先頭の注文の公開ID）にするため、Kafka では同じ注文のイベントが記録順に届く
+type BrokerSink struct {
+	publisher messaging.Publisher
+	prefix    string
+}
+
+func NewBrokerSink(publisher messaging.Publisher, topicPrefix string) *BrokerSink {
+	return &BrokerSink{publisher: publisher, prefix: topicPrefix}
+}
+
+func (s *BrokerSink) Publish(ctx context.Context, ev model.OutboxEvent) error {
+	body, err := json.Marshal(NewMessage(ev))
+	if err != nil {
+		return err
+	}
+	eventID := strconv.FormatInt(ev.EventID, 10)
+	return s.publisher.Publish(ctx, messaging.Message{
+		Topic:   s.prefix + ev.EventType,
+		Key:     ev.AggregateKey,
+		Value:   body,
+		Headers: map[string]string{"event-id": eventID, "event-type": ev.EventType, "content-type": "application/json"},
+		ID:      eventID,
+	})
+}
+
+// Close はブローカーへの接続を閉じる（リレーの終了時に呼ばれる）
+func (s *BrokerSink) Close() error {
+	return s.publisher.Close()
+}
@@ -0,0 +1,101 @@
+// Package querybuilder は一覧取得クエリのソート・ページング部分を組み立てる
+// ORDER BY に埋め込む列はエンティティごとのフィールド定義（Fields）を通したものに限定する
+package querybuilder
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ソートパラメータが許可されていない場合に返す
+var ErrInvalidSort = errors.New("invalid sort parameter")
+
+const (
+	Asc  = "ASC"
+	Desc = "DESC"
+)
+
+// Field はソートに使える1つのフィールド
+type Field struct {
+	// ORDER BY に埋め込む列（式）
+	Column string
+	// NULL をソート順によらず末尾に並べる
+	NullsLast bool
+}
+
+// Fields はリクエストの sort_field と列の対応（ホワイトリスト）
+type Fields map[string]Field
+
+// Dialect は NULL の並び順の書き方をデータベースごとに切り替える
+// repository.Dialect がこれを満たす
+type Dialect interface {
+	OrderNullsLast(column, order string) string
+}
+
+// Sort はエンティティごとのソートの定義
+type Sort struct {
+	Fields Fields
+	// 同値の行の順序を安定させるために最後に付ける列（常に昇順）
+	Tiebreak string
+}
+
+// Order は検証済みのソート条件
+type Order struct {
+	Field string
+	Dir   string
+	col   Field
+}
+
+// Validate は sort_field と sort_order を検証する。許可されていない値は ErrInvalidSort を返す
+func (s Sort) Validate(field, dir string) (Order, error) {
+	col, ok := s.Fields[field]
+	if !ok {
+		return Order{}, fmt.Errorf("%w: sort_field %q", ErrInvalidSort, field)
+	}
+	d, ok := normalizeDir(dir)
+	if !ok {
+		return Order{}, fmt.Errorf("%w: sort_order %q", ErrInvalidSort, dir)
+	}
+	return Order{Field: field, Dir: d, col: col}, nil
+}
+
+// OrderBy は先頭に空白を付けた ORDER BY 句を返す
+// ソート列がタイブレーク列と同じ場合はタイブレークを付けない
+func (s Sort) OrderBy(o Order, d Dialect) string {
+	var key string
+	if o.col.NullsLast {
+		key = d.OrderNullsLast(o.col.Column, o.Dir)
+	} else {
+		key = o.col.Column + " " + o.Dir
+	}
+	if s.Tiebreak == "" || o.col.Column == s.Tiebreak {
+		return " ORDER BY " + key
+	}
+	return " ORDER BY " + key + ", " + s.Tiebreak + " ASC"
+}
+
+// Column は検証済みのソート列を返す
+func (o Order) Column() string {
+	return o.col.Column
+}
+
+// LimitOffset は ? プレースホルダの LIMIT / OFFSET 句と引数を返す
+func LimitOffset(limit, offset int) (string, []interface{}) {
+	return " LIMIT ? OFFSET ?", []interface{}{limit, offset}
+}
+
+// NamedLimitOffset は名前付きパラメータの LIMIT / OFFSET 句を返し、params に値を設定する
+func NamedLimitOffset(params map[string]interface{}, limit, offset int) string {
+	params["limit"] = limit
+	params["offset"] = offset
+	return " LIMIT :limit OFFSET :offset"
+}
+
+func normalizeDir(dir string) (string, bool) {
+	dir = strings.ToUpper(dir)
+	if dir != Asc && dir != Desc {
+		return "", false
+	}
+	return dir, true
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 満杯に戻ったバケットを掃除する間隔
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // この時刻以降は満杯（掃除してよい）
+}
+
+// MemoryLimiter はプロセス内でバケットを管理する。複数台構成ではサーバーごとに独立して数える
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: make(map[string]*bucket), lastSweep: time.Now(), now: time.Now}
+}
+
+func (l *MemoryLimiter) Allow(_ context.Context, p Policy, key string) (Result, error) {
+	now := l.now()
+	rate := p.ratePerMs()
+	k := p.Name + ":" + key
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.lastSweep = now
+		for k, b := range l.buckets {
+			if now.After(b.full) {
+				delete(l.buckets, k)
+			}
+		}
+	}
+
+	b, ok := l.buckets[k]
+	if !ok {
+		b = &bucket{tokens: float64(p.Burst), last: now}
+		l.buckets[k] = b
+	}
+	b.tokens += float64(now.Sub(b.last).Milliseconds()) * rate
+	if b.tokens > float64(p.Burst) {
+		b.tokens = float64(p.Burst)
+	}
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	res := newResult(p, allowed, b.tokens)
+	b.full = now.Add(res.ResetAfter)
+	return res, nil
+}
@@ -0,0 +1,92 @@
+// Package ratelimit はトークンバケット方式のレート制限を提供する
+// 既定はプロセス内のメモリで管理し、バックエンドに redis を指定した場合は Redis で複数台のサーバー間で共有する
+package ratelimit
+
+import (
+	"backend/internal/config"
+	"backend/internal/coord"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy はバケットの設定。Burst 個まで連続で許可し、Period ごとに Burst 個まで回復する
+type Policy struct {
+	Name   string
+	Burst  int
+	Period time.Duration
+}
+
+// 1ミリ秒あたりに回復するトークン数
+func (p Policy) ratePerMs() float64 {
+	return float64(p.Burst) / float64(p.Period.Milliseconds())
+}
+
+// String は RateLimit-Policy ヘッダーの形式（例: 10;w=60）で返す
+func (p Policy) String() string {
+	return fmt.Sprintf("%d;w=%d", p.Burst, int(p.Period.Seconds()))
+}
+
+// ParsePolicy は "10/1m" のような「回数/期間」の指定を読む
+func ParsePolicy(name, spec string) (Policy, error) {
+	n, period, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("invalid rate limit %q for %s: want <count>/<period>", spec, name)
+	}
+	burst, err := strconv.Atoi(strings.TrimSpace(n))
+	if err != nil || burst <= 0 {
+		return Policy{}, fmt.Errorf("invalid rate limit %q for %s: count must be positive", spec, name)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(period))
+	if err != nil || d < time.Millisecond {
+		return Policy{}, fmt.Errorf("invalid rate limit %q for %s: bad period", spec, name)
+	}
+	return Policy{Name: name, Burst: burst, Period: d}, nil
+}
+
+// Result は1回の判定結果
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// 次のリクエストが許可されるまでの時間（Allowed の場合は 0）
+	RetryAfter time.Duration
+	// バケットが満杯に戻るまでの時間
+	ResetAfter time.Duration
+}
+
+func newResult(p Policy, allowed bool, tokens float64) Result {
+	rate := p.ratePerMs()
+	res := Result{Allowed: allowed, Limit: p.Burst, Remaining: int(tokens)}
+	if !allowed {
+		res.RetryAfter = time.Duration((1-tokens)/rate) * time.Millisecond
+	}
+	res.ResetAfter = time.Duration((float64(p.Burst)-tokens)/rate) * time.Millisecond
+	return res
+}
+
+// Limiter は key ごとにトークンを1つ消費できるか判定する
+type Limiter interface {
+	Allow(ctx context.Context, p Policy, key string) (Result, error)
+}
+
+// New は cfg.Enabled の場合のみ Limiter を返す（無効の場合は nil）
+// バックエンドが redis の場合は共有の Redis（REDIS_URL）を使う
+func New(cfg config.RateLimit, shared *coord.Client) (Limiter, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	switch strings.ToLower(cfg.Backend) {
+	case "", "memory":
+		return NewMemoryLimiter(), nil
+	case "redis":
+		if shared == nil {
+			return nil, fmt.Errorf("RATE_LIMIT_BACKEND=redis requires REDIS_URL")
+		}
+		return NewRedisLimiter(shared), nil
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_BACKEND %q", cfg.Backend)
+	}
+}
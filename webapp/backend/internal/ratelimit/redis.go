@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"backend/internal/coord"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// トークンの補充と消費を Redis 上で原子的に行う
+// 時刻は Redis の TIME を使い、サーバー間の時計のずれの影響を受けないようにする
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local v = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(v[1]) or burst
+local ts = tonumber(v[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisLimiter は Redis でバケットを共有し、複数台のサーバーで合計して制限する
+type RedisLimiter struct {
+	client *coord.Client
+}
+
+func NewRedisLimiter(client *coord.Client) *RedisLimiter {
+	return &RedisLimiter{client: client}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, p Policy, key string) (Result, error) {
+	vals, err := tokenBucketScript.Run(ctx, l.client, []string{l.client.Key("ratelimit:" + p.Name + ":" + key)}, p.ratePerMs(), p.Burst).Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit script failed: %w", err)
+	}
+	if len(vals) != 2 {
+		return Result{}, fmt.Errorf("rate limit script returned %d values", len(vals))
+	}
+	allowed, _ := vals[0].(int64)
+	s, _ := vals[1].(string)
+	tokens, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit script returned invalid tokens %q", s)
+	}
+	return newResult(p, allowed == 1, tokens), nil
+}
@@ -0,0 +1,175 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/internal/apierror"
+	"backend/internal/middleware"
+
+	"github.com/gorilla/websocket"
+)
+
+// ダッシュボードからのメッセージは読み捨てるため、大きなものは受け付けない
+const maxReadBytes = 512
+
+// client は1つの WebSocket 接続
+// conn への書き込みは writePump、読み込みは readPump だけが行う
+type client struct {
+	hub       *Hub
+	conn      *websocket.Conn
+	userID    int
+	sessionID string
+	topics    map[string]bool
+	send      chan []byte
+
+	closeOnce   sync.Once
+	done        chan struct{}
+	closeCode   int
+	closeReason string
+}
+
+// ServeHTTP は WebSocket にアップグレードし、切断されるまでイベントを送る
+// UserAuthMiddleware・AdminAuthMiddleware の後段で使用すること（接続時の認証はそちらで行う）
+// 購読するトピックは ?topics=orders,robots で指定する（省略時はすべて）
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserFromContext(r.Context())
+	cookie, err := r.Cookie("session_id")
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthorized("No session cookie"))
+		return
+	}
+	topics, err := parseTopics(r.URL.Query().Get("topics"))
+	if err != nil {
+		apierror.Write(w, r, apierror.Validation(err.Error(), apierror.FieldError{Field: "topics", Message: err.Error()}))
+		return
+	}
+
+	c := &client{
+		hub:       h,
+		userID:    userID,
+		sessionID: cookie.Value,
+		topics:    topics,
+		send:      make(chan []byte, h.cfg.SendBuffer),
+		done:      make(chan struct{}),
+	}
+	if !h.register(c) {
+		apierror.Write(w, r, apierror.Unavailable("Too many dashboard connections"))
+		return
+	}
+	upgrader := websocket.Upgrader{CheckOrigin: h.checkOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade がエラーレスポンスを書き込み済み
+		h.unregister(c)
+		return
+	}
+	c.conn = conn
+	activeConns.Add(1)
+
+	// 切断後のセッション確認やログに使うため、リクエストの終了でキャンセルされないようにする
+	ctx := context.WithoutCancel(r.Context())
+	go c.writePump(ctx)
+	c.readPump()
+}
+
+// checkOrigin は AllowedOrigins が空の場合、Host と同じ Origin だけを許可する
+func (h *Hub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if slices.Contains(h.cfg.AllowedOrigins, origin) {
+		return true
+	}
+	_, host, _ := strings.Cut(origin, "://")
+	return strings.EqualFold(host, r.Host)
+}
+
+func parseTopics(s string) (map[string]bool, error) {
+	if s == "" {
+		return map[string]bool{TopicOrders: true, TopicRobots: true}, nil
+	}
+	topics := make(map[string]bool)
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if t != TopicOrders && t != TopicRobots {
+			return nil, fmt.Errorf("unknown topic %q", t)
+		}
+		topics[t] = true
+	}
+	return topics, nil
+}
+
+// close は接続を閉じるよう writePump に伝える。何度呼んでもよい
+func (c *client) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.closeReason = reason
+		close(c.done)
+	})
+}
+
+// readPump は pong で生存を確認する。ダッシュボードからのメッセージは読み捨てる
+func (c *client) readPump() {
+	defer c.close(websocket.CloseNormalClosure, "")
+
+	wait := 2 * c.hub.cfg.PingInterval
+	c.conn.SetReadLimit(maxReadBytes)
+	_ = c.conn.SetReadDeadline(time.Now().Add(wait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wait))
+	})
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writePump は送信待ちのメッセージと ping を書き込み、定期的にセッションを確認する
+func (c *client) writePump(ctx context.Context) {
+	cfg := c.hub.cfg
+	ping := time.NewTicker(cfg.PingInterval)
+	check := time.NewTicker(cfg.SessionCheckInterval)
+	defer func() {
+		ping.Stop()
+		check.Stop()
+		c.hub.unregister(c)
+		c.conn.Close()
+		activeConns.Add(-1)
+	}()
+
+	deadline := func() time.Time { return time.Now().Add(cfg.WriteTimeout) }
+	for {
+		select {
+		case data := <-c.send:
+			_ = c.conn.SetWriteDeadline(deadline())
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, deadline()); err != nil {
+				return
+			}
+		case <-check.C:
+			checkCtx, cancel := context.WithTimeout(ctx, cfg.WriteTimeout)
+			err := c.hub.authorize(checkCtx, c.sessionID)
+			cancel()
+			if err != nil {
+				slog.InfoContext(ctx, "realtime: closing connection", "user_id", c.userID, "reason", err)
+				c.close(websocket.ClosePolicyViolation, "session expired")
+			}
+		case <-c.done:
+			msg := websocket.FormatCloseMessage(c.closeCode, c.closeReason)
+			_ = c.conn.WriteControl(websocket.CloseMessage, msg, deadline())
+			return
+		}
+	}
+}
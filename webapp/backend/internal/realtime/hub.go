@@ -0,0 +1,130 @@
+// Package realtime は注文の状態変更とロボットの位置を、WebSocket で管理ダッシュボードへ配信する
+//
+// イベントはプロセス内のバス（events.Bus）から受け取る。複数インスタンスで動かす場合、
+// 各インスタンスは自分が処理したイベントだけを配信する。
+// 送信が追いつかない接続は送信待ちが SendBuffer を超えた時点で切断し、他の接続や Publish 元を待たせない
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"log/slog"
+	"sync"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/events"
+
+	"github.com/gorilla/websocket"
+)
+
+// 購読できるトピック
+const (
+	TopicOrders = "orders"
+	TopicRobots = "robots"
+)
+
+// イベント種別ごとの配信先トピック
+var topicOf = map[string]string{
+	events.TypeOrderClaimed:       TopicOrders,
+	events.TypeOrderDelivered:     TopicOrders,
+	events.TypeOrderStatusChanged: TopicOrders,
+	events.TypeRobotPosition:      TopicRobots,
+}
+
+var (
+	activeConns = expvar.NewInt("realtime_connections")
+	// 送信が追いつかずに切断した接続数
+	droppedConns = expvar.NewInt("realtime_dropped_connections")
+)
+
+// Message はダッシュボードへ送るメッセージ
+type Message struct {
+	Topic      string      `json:"topic"`
+	Type       string      `json:"type"`
+	Payload    interface{} `json:"payload"`
+	OccurredAt time.Time   `json:"occurred_at"`
+}
+
+// Authorizer は接続中のセッションがまだ有効か（管理者のままか）を確認する
+type Authorizer func(ctx context.Context, sessionID string) error
+
+type Hub struct {
+	cfg       config.Realtime
+	authorize Authorizer
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+	closed  bool
+}
+
+func NewHub(cfg config.Realtime, authorize Authorizer) *Hub {
+	return &Hub{cfg: cfg, authorize: authorize, clients: make(map[*client]struct{})}
+}
+
+// Subscribe は配信対象のイベントをバスから受け取るよう登録する
+func (h *Hub) Subscribe(bus *events.Bus) {
+	for eventType := range topicOf {
+		bus.Subscribe(eventType, h.publish)
+	}
+}
+
+// Run は ctx がキャンセルされるまで待ち、その後すべての接続を閉じる
+func (h *Hub) Run(ctx context.Context) {
+	<-ctx.Done()
+
+	h.mu.Lock()
+	h.closed = true
+	clients := h.clients
+	h.clients = make(map[*client]struct{})
+	h.mu.Unlock()
+
+	for c := range clients {
+		c.close(websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+// publish はバスの購読者。送信は接続ごとの writePump が行うため、ここでは待たない
+func (h *Hub) publish(ctx context.Context, ev events.Event) {
+	topic := topicOf[ev.Type]
+	data, err := json.Marshal(Message{Topic: topic, Type: ev.Type, Payload: ev.Payload, OccurredAt: ev.OccurredAt})
+	if err != nil {
+		slog.ErrorContext(ctx, "realtime: failed to encode event", "type", ev.Type, "err", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		if !c.topics[topic] {
+			continue
+		}
+		select {
+		case c.send <- data:
+		default:
+			// 送信待ちが溢れた接続は切断し、ダッシュボード側で再接続してもらう
+			delete(h.clients, c)
+			droppedConns.Add(1)
+			slog.WarnContext(ctx, "realtime: dropping slow connection", "user_id", c.userID)
+			c.close(websocket.CloseTryAgainLater, "too slow")
+		}
+	}
+}
+
+// register は上限に達していなければ接続を登録する（上限の確認のため、アップグレード前に呼ぶ）
+func (h *Hub) register(c *client) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed || len(h.clients) >= h.cfg.MaxConnections {
+		return false
+	}
+	h.clients[c] = struct{}{}
+	return true
+}
+
+func (h *Hub) unregister(c *client) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	h.mu.Unlock()
+}
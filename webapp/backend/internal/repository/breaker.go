@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"backend/internal/config"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrCircuitOpen はDBへの呼び出しが連続して失敗したため、一定時間呼び出しを止めていることを表す
+var ErrCircuitOpen = errors.New("database circuit breaker is open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker は連続した失敗回数が閾値を超えると open になり、cooldown の間は即座に失敗させる
+// cooldown 後は1件だけ試行（half-open）し、成功すれば closed に戻す
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// BreakerThreshold（連続失敗回数、0 で無効）と BreakerCooldown で設定する
+func newCircuitBreaker(cfg config.DB) *circuitBreaker {
+	if cfg.BreakerThreshold == 0 {
+		return nil
+	}
+	return &circuitBreaker{threshold: cfg.BreakerThreshold, cooldown: cfg.BreakerCooldown}
+}
+
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return nil
+	case breakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record は呼び出しの結果を記録する
+// クエリごとのタイムアウトを超えた場合は失敗として数え、呼び出し元の ctx が終了した場合の失敗は数えない
+func (b *circuitBreaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.probing = false
+	}
+	timedOut := err != nil && queryTimedOut(ctx)
+	if err != nil && ctx.Err() != nil && !timedOut {
+		// 呼び出し元のキャンセルやデッドライン（リクエストのタイムアウトなど）は、DB が遅いとは限らない
+		// 成功とも失敗とも数えず、half-open の場合は次の呼び出しで改めて試す
+		return
+	}
+	if !timedOut && !isDBFailure(err) {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// DB に接続できなかった・接続が切れた（接続・ドライバーのエラー）場合のみ失敗として数える（クエリごとのタイムアウトは record で判定する）
+// 行が無い、タイムアウト・キャンセル（context のエラー）、MySQL がエラーを返した（＝応答はしている）、
+// Scan の失敗などのアプリケーション側のエラーは数えない
+func isDBFailure(err error) bool {
+	if err == nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	// 接続の拒否・名前解決の失敗・読み書きのタイムアウト（DSN の readTimeout など）
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// breakerDB は DBTX の呼び出しをサーキットブレーカー越しに行う
+type breakerDB struct {
+	DBTX
+	breaker *circuitBreaker
+}
+
+func (d *breakerDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	if err := d.breaker.allow(); err != nil {
+		return err
+	}
+	err := d.DBTX.GetContext(ctx, dest, query, args...)
+	d.breaker.record(ctx, err)
+	return err
+}
+
+func (d *breakerDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	if err := d.breaker.allow(); err != nil {
+		return err
+	}
+	err := d.DBTX.SelectContext(ctx, dest, query, args...)
+	d.breaker.record(ctx, err)
+	return err
+}
+
+func (d *breakerDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if err := d.breaker.allow(); err != nil {
+		return nil, err
+	}
+	res, err := d.DBTX.ExecContext(ctx, query, args...)
+	d.breaker.record(ctx, err)
+	return res, err
+}
+
+func (d *breakerDB) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	if err := d.breaker.allow(); err != nil {
+		return nil, err
+	}
+	rows, err := d.DBTX.QueryxContext(ctx, query, args...)
+	d.breaker.record(ctx, err)
+	return rows, err
+}
+
+func (d *breakerDB) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	if err := d.breaker.allow(); err != nil {
+		return nil, err
+	}
+	res, err := d.DBTX.NamedExecContext(ctx, query, arg)
+	d.breaker.record(ctx, err)
+	return res, err
+}
+
+func (d *breakerDB) NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error) {
+	if err := d.breaker.allow(); err != nil {
+		return nil, err
+	}
+	rows, err := d.DBTX.NamedQueryContext(ctx, query, arg)
+	d.breaker.record(ctx, err)
+	return rows, err
+}
+
+func (d *breakerDB) PreparexContext(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	if err := d.breaker.allow(); err != nil {
+		return nil, err
+	}
+	stmt, err := d.DBTX.PreparexContext(ctx, query)
+	d.breaker.record(ctx, err)
+	return stmt, err
+}
+
+// ブレーカーが無効（nil）の場合はそのまま返す
+func withBreaker(db DBTX, b *circuitBreaker) DBTX {
+	if b == nil {
+		return db
+	}
+	return &breakerDB{DBTX: db, breaker: b}
+}
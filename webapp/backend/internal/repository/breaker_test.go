@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// クエリごとのタイムアウトは失敗として数え、呼び出し元のキャンセルは数えない
+func TestCircuitBreakerCountsQueryTimeouts(t *testing.T) {
+	b := &circuitBreaker{threshold: 1, cooldown: time.Minute}
+
+	parent, cancelParent := context.WithCancel(context.Background())
+	qctx, cancel := withQueryTimeout(parent, "Test", time.Hour)
+	cancelParent()
+	<-qctx.Done()
+	b.record(qctx, qctx.Err())
+	cancel()
+	if b.state != breakerClosed {
+		t.Fatalf("state after caller cancellation = %v, want closed", b.state)
+	}
+
+	qctx, cancel = withQueryTimeout(context.Background(), "Test", time.Nanosecond)
+	defer cancel()
+	<-qctx.Done()
+	b.record(qctx, qctx.Err())
+	if b.state != breakerOpen {
+		t.Fatalf("state after query timeout = %v, want open", b.state)
+	}
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"time"
+)
+
+type CartRepository struct {
+	db       DBTX
+	timeouts *QueryTimeouts
+	dialect  Dialect
+}
+
+func NewCartRepository(db DBTX, timeouts *QueryTimeouts, dialect Dialect) *CartRepository {
+	return &CartRepository{db: db, timeouts: timeouts, dialect: dialect}
+}
+
+// カートに商品を追加（既にある場合は数量を加算）
+// PostgreSQL では DO UPDATE SET の右辺で列名だけだと曖昧になるため、既存の値はテーブル名で修飾する
+func (r *CartRepository) AddItem(ctx context.Context, userID, productID, quantity int) error {
+	d := r.dialect
+	query := `
+		INSERT INTO cart_items (user_id, product_id, quantity, updated_at) VALUES (?, ?, ?, ?)
+		` + d.OnConflictUpdate("user_id", "product_id") + ` quantity = cart_items.quantity + ` + d.Excluded("quantity") + `, updated_at = ` + d.Excluded("updated_at")
+	qctx, cancel := r.timeouts.write(ctx, "AddCartItem")
+	defer cancel()
+	_, err := r.db.ExecContext(qctx, query, userID, productID, quantity, time.Now().UTC())
+	return qctx.wrap(err)
+}
+
+// カート内の商品の数量を更新する。カートに無い場合は sql.ErrNoRows を返す
+func (r *CartRepository) SetQuantity(ctx context.Context, userID, productID, quantity int) error {
+	var exists int
+	rctx, cancel := r.timeouts.pointRead(ctx, "FindCartItem")
+	defer cancel()
+	if err := r.db.GetContext(rctx, &exists, "SELECT 1 FROM cart_items WHERE user_id = ? AND product_id = ?", userID, productID); err != nil {
+		return rctx.wrap(err)
+	}
+	query := "UPDATE cart_items SET quantity = ?, updated_at = ? WHERE user_id = ? AND product_id = ?"
+	qctx, cancel := r.timeouts.write(ctx, "SetCartQuantity")
+	defer cancel()
+	_, err := r.db.ExecContext(qctx, query, quantity, time.Now().UTC(), userID, productID)
+	return qctx.wrap(err)
+}
+
+// カートから商品を削除
+func (r *CartRepository) RemoveItem(ctx context.Context, userID, productID int) error {
+	qctx, cancel := r.timeouts.write(ctx, "RemoveCartItem")
+	defer cancel()
+	_, err := r.db.ExecContext(qctx, "DELETE FROM cart_items WHERE user_id = ? AND product_id = ?", userID, productID)
+	return qctx.wrap(err)
+}
+
+// カートの中身を取得
+func (r *CartRepository) ListItems(ctx context.Context, userID int) ([]model.CartItem, error) {
+	items := []model.CartItem{}
+	query := `
+		SELECT c.product_id, p.name AS product_name, p.value, p.currency, p.weight, c.quantity
+		FROM cart_items c
+		JOIN products p ON c.product_id = p.product_id
+		WHERE c.user_id = ?
+		ORDER BY c.updated_at ASC, c.product_id ASC`
+	qctx, cancel := r.timeouts.listRead(ctx, "ListCartItems")
+	defer cancel()
+	if err := r.db.SelectContext(qctx, &items, query, userID); err != nil {
+		return nil, qctx.wrap(err)
+	}
+	return items, nil
+}
+
+// 注文確定のためにカートの中身を排他ロックして取得（トランザクション内で使用）
+func (r *CartRepository) ListItemsForUpdate(ctx context.Context, userID int) ([]model.RequestItem, error) {
+	items := []model.RequestItem{}
+	query := "SELECT product_id, quantity FROM cart_items WHERE user_id = ? ORDER BY product_id" + r.dialect.ForUpdate()
+	qctx, cancel := r.timeouts.write(ctx, "ListCartItemsForUpdate")
+	defer cancel()
+	if err := r.db.SelectContext(qctx, &items, query, userID); err != nil {
+		return nil, qctx.wrap(err)
+	}
+	return items, nil
+}
+
+// カートを空にする
+func (r *CartRepository) Clear(ctx context.Context, userID int) error {
+	qctx, cancel := r.timeouts.write(ctx, "ClearCart")
+	defer cancel()
+	_, err := r.db.ExecContext(qctx, "DELETE FROM cart_items WHERE user_id = ?", userID)
+	return qctx.wrap(err)
+}
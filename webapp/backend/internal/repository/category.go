@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+)
+
+type CategoryRepository struct {
+	db       DBTX
+	timeouts *QueryTimeouts
+}
+
+func NewCategoryRepository(db DBTX, timeouts *QueryTimeouts) *CategoryRepository {
+	return &CategoryRepository{db: db, timeouts: timeouts}
+}
+
+// カテゴリ一覧を取得
+func (r *CategoryRepository) ListCategories(ctx context.Context) ([]model.Category, error) {
+	categories := []model.Category{}
+	query := "SELECT category_id, name FROM categories ORDER BY name ASC, category_id ASC"
+	qctx, cancel := r.timeouts.listRead(ctx, "ListCategories")
+	defer cancel()
+	if err := r.db.SelectContext(qctx, &categories, query); err != nil {
+		return nil, qctx.wrap(err)
+	}
+	return categories, nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"backend/internal/cache"
+)
+
+// changeLog はリポジトリの書き込みで変わったエンティティを cache.Bus へ通知する
+// トランザクション内の Store ではコミットまで溜めておき、ロールバックした変更は通知しない
+type changeLog struct {
+	bus *cache.Bus
+	// トランザクション内の Store のみ true
+	deferred bool
+
+	mu      sync.Mutex
+	pending []cache.Change
+}
+
+func (l *changeLog) record(ctx context.Context, entity string, id int64) {
+	c := cache.Change{Entity: entity}
+	if id != 0 {
+		c.ID = strconv.FormatInt(id, 10)
+	}
+	if !l.deferred {
+		l.bus.Publish(ctx, c)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, p := range l.pending {
+		if p == c {
+			return
+		}
+	}
+	l.pending = append(l.pending, c)
+}
+
+// flush はコミット後に溜めておいた変更を通知する
+func (l *changeLog) flush(ctx context.Context) {
+	l.mu.Lock()
+	pending := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+	for _, c := range pending {
+		l.bus.Publish(ctx, c)
+	}
+}
+
+// Changes はエンティティの変更を通知する Bus（キャッシュの無効化の購読に使う）
+func (s *Store) Changes() *cache.Bus {
+	return s.shared.changes
+}
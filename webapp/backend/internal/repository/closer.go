@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// closerRegistry は Store が保持する閉じるべき資源（Prepared Statement のキャッシュや接続など）を集める
+// 資源は生成時に register し、Close で登録と逆の順に閉じる
+type closerRegistry struct {
+	mu      sync.Mutex
+	closers []io.Closer
+}
+
+func (r *closerRegistry) register(c io.Closer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closers = append(r.closers, c)
+}
+
+// 全て閉じてからエラーをまとめて返す。2回目以降の呼び出しは何もしない
+func (r *closerRegistry) Close() error {
+	r.mu.Lock()
+	closers := r.closers
+	r.closers = nil
+	r.mu.Unlock()
+
+	var errs []error
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
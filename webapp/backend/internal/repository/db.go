@@ -13,4 +13,8 @@ type DBTX interface {
 	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
 	Rebind(query string) string
+	// 名前付きパラメータ（:name）を使うクエリ。引数は構造体または map[string]interface{}
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+	NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error)
+	PreparexContext(ctx context.Context, query string) (*sqlx.Stmt, error)
 }
@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Dialect はデータベースごとに異なるSQLの書き方を吸収する
+// MySQL 以外では、全文検索や一時テーブルを使った一括更新などは使わずに代替の実装で動作する
+// リポジトリのクエリは ? プレースホルダで書き、PostgreSQL では Store が $1 形式に置き換える
+type Dialect interface {
+	Name() string
+	// 現在時刻を表す式
+	Now() string
+	// NULL を最後に並べる ORDER BY の項
+	OrderNullsLast(column, order string) string
+	// SELECT で行ロックを取る場合に付ける句（未対応の場合は空）
+	ForUpdate() string
+	// 一意制約に違反する行を無視する INSERT 文（rest は "(列) VALUES (...)" 部分）
+	InsertIgnore(table, rest string) string
+	// 挿入した行の自動採番IDを返させる句（LastInsertId を使える場合は空）
+	Returning(idColumn string) string
+	// 大文字小文字を区別しない LIKE 演算子
+	Like() string
+	// プレースホルダの形式（sqlx.QUESTION / sqlx.DOLLAR）
+	BindType() int
+	// 一意制約に違反した場合に更新する句（この後に "col = 値, ..." を続ける）
+	OnConflictUpdate(conflictColumns ...string) string
+	// OnConflictUpdate の中で、挿入しようとした値を参照する式
+	Excluded(column string) string
+	// LIKE のエスケープ文字をバックスラッシュにする句
+	LikeEscape() string
+	// 整数の切り捨て除算
+	IntDiv(dividend, divisor string) string
+	// from から to までの秒数（小数を含みうる数値）
+	SecondsBetween(from, to string) string
+	// 日時を時間単位に切り捨てた文字列（"2006-01-02 15:00:00" 形式）
+	HourBucket(column string) string
+	// テーブル・カラムの存在を確認するクエリ（COUNT を返す）
+	TableExistsQuery(table string) (string, []interface{})
+	ColumnExistsQuery(table, column string) (string, []interface{})
+	// 明示したIDで挿入した後に、自動採番の次の値を列の最大値より後ろへ進める文（自動で追従する場合は空）
+	SyncSequence(table, column string) string
+	// MySQL 固有の機能（FULLTEXT・UPDATE ... JOIN・一時テーブル等）が使えるか
+	IsMySQL() bool
+}
+
+// DialectFor はドライバ名から Dialect を返す（不明な場合は MySQL）
+func DialectFor(driverName string) Dialect {
+	switch {
+	case strings.Contains(driverName, "sqlite"):
+		return sqliteDialect{}
+	case strings.Contains(driverName, "pgx"), strings.Contains(driverName, "postgres"):
+		return postgresDialect{}
+	default:
+		return mysqlDialect{}
+	}
+}
+
+// insertReturningID は INSERT を実行し、自動採番されたIDを返す
+// PostgreSQL のドライバは LastInsertId に対応していないため RETURNING で取得する
+func insertReturningID(ctx context.Context, db DBTX, d Dialect, query, idColumn string, args ...interface{}) (int64, error) {
+	if returning := d.Returning(idColumn); returning != "" {
+		var id int64
+		if err := db.GetContext(ctx, &id, query+returning, args...); err != nil {
+			return 0, err
+		}
+		return id, nil
+	}
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string { return "mysql" }
+func (mysqlDialect) Now() string  { return "NOW()" }
+
+// MySQL は NULLS LAST に対応していないため ISNULL() で並べる
+func (mysqlDialect) OrderNullsLast(column, order string) string {
+	return "ISNULL(" + column + ") ASC, " + column + " " + order
+}
+
+func (mysqlDialect) ForUpdate() string { return " FOR UPDATE" }
+
+func (mysqlDialect) InsertIgnore(table, rest string) string {
+	return "INSERT IGNORE INTO " + table + " " + rest
+}
+
+func (mysqlDialect) Returning(idColumn string) string { return "" }
+
+// utf8mb4_0900_ai_ci の照合順序で大文字小文字を区別しない
+func (mysqlDialect) Like() string  { return "LIKE" }
+func (mysqlDialect) BindType() int { return sqlx.QUESTION }
+
+func (mysqlDialect) OnConflictUpdate(conflictColumns ...string) string {
+	return "ON DUPLICATE KEY UPDATE"
+}
+
+func (mysqlDialect) Excluded(column string) string { return "VALUES(" + column + ")" }
+
+// MySQL はバックスラッシュがデフォルトのエスケープ文字
+func (mysqlDialect) LikeEscape() string { return "" }
+
+func (mysqlDialect) IntDiv(dividend, divisor string) string {
+	return "(" + dividend + " DIV " + divisor + ")"
+}
+
+func (mysqlDialect) SecondsBetween(from, to string) string {
+	return "TIMESTAMPDIFF(SECOND, " + from + ", " + to + ")"
+}
+
+func (mysqlDialect) HourBucket(column string) string {
+	return "DATE_FORMAT(" + column + ", '%Y-%m-%d %H:00:00')"
+}
+
+func (mysqlDialect) TableExistsQuery(table string) (string, []interface{}) {
+	return `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?`, []interface{}{table}
+}
+
+func (mysqlDialect) ColumnExistsQuery(table, column string) (string, []interface{}) {
+	return `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?`, []interface{}{table, column}
+}
+
+// AUTO_INCREMENT は明示したIDに追従する
+func (mysqlDialect) SyncSequence(table, column string) string { return "" }
+
+func (mysqlDialect) IsMySQL() bool { return true }
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return "sqlite" }
+func (sqliteDialect) Now() string  { return "CURRENT_TIMESTAMP" }
+
+func (sqliteDialect) OrderNullsLast(column, order string) string {
+	return column + " " + order + " NULLS LAST"
+}
+
+// SQLite はデータベース単位でロックするため行ロックは不要
+func (sqliteDialect) ForUpdate() string { return "" }
+
+func (sqliteDialect) InsertIgnore(table, rest string) string {
+	return "INSERT INTO " + table + " " + rest + " ON CONFLICT DO NOTHING"
+}
+
+func (sqliteDialect) Returning(idColumn string) string { return "" }
+
+// ASCII の範囲では大文字小文字を区別しない
+func (sqliteDialect) Like() string  { return "LIKE" }
+func (sqliteDialect) BindType() int { return sqlx.QUESTION }
+
+func (sqliteDialect) OnConflictUpdate(conflictColumns ...string) string {
+	return "ON CONFLICT(" + strings.Join(conflictColumns, ", ") + ") DO UPDATE SET"
+}
+
+func (sqliteDialect) Excluded(column string) string { return "excluded." + column }
+func (sqliteDialect) LikeEscape() string            { return ` ESCAPE '\'` }
+
+// 整数同士の除算は切り捨てになる
+func (sqliteDialect) IntDiv(dividend, divisor string) string {
+	return "(" + dividend + " / " + divisor + ")"
+}
+
+// 日時は文字列で保存されているため、ユリウス日の差を秒に換算する
+func (sqliteDialect) SecondsBetween(from, to string) string {
+	return "((julianday(" + to + ") - julianday(" + from + ")) * 86400.0)"
+}
+
+func (sqliteDialect) HourBucket(column string) string {
+	return "strftime('%Y-%m-%d %H:00:00', " + column + ")"
+}
+
+func (sqliteDialect) TableExistsQuery(table string) (string, []interface{}) {
+	return `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, []interface{}{table}
+}
+
+func (sqliteDialect) ColumnExistsQuery(table, column string) (string, []interface{}) {
+	return `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, []interface{}{table, column}
+}
+
+// INTEGER PRIMARY KEY は最大値の次から採番される
+func (sqliteDialect) SyncSequence(table, column string) string { return "" }
+
+func (sqliteDialect) IsMySQL() bool { return false }
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string { return "postgres" }
+func (postgresDialect) Now() string  { return "NOW()" }
+
+func (postgresDialect) OrderNullsLast(column, order string) string {
+	return column + " " + order + " NULLS LAST"
+}
+
+func (postgresDialect) ForUpdate() string { return " FOR UPDATE" }
+
+func (postgresDialect) InsertIgnore(table, rest string) string {
+	return "INSERT INTO " + table + " " + rest + " ON CONFLICT DO NOTHING"
+}
+
+func (postgresDialect) Returning(idColumn string) string { return " RETURNING " + idColumn }
+
+func (postgresDialect) OnConflictUpdate(conflictColumns ...string) string {
+	return "ON CONFLICT(" + strings.Join(conflictColumns, ", ") + ") DO UPDATE SET"
+}
+
+func (postgresDialect) Excluded(column string) string { return "excluded." + column }
+
+// PostgreSQL もバックスラッシュがデフォルトのエスケープ文字
+func (postgresDialect) LikeEscape() string { return "" }
+func (postgresDialect) Like() string       { return "ILIKE" }
+func (postgresDialect) BindType() int      { return sqlx.DOLLAR }
+
+func (postgresDialect) IntDiv(dividend, divisor string) string {
+	return "(" + dividend + " / " + divisor + ")"
+}
+
+// EXTRACT は numeric を返すため、float64 で読めるよう変換する
+func (postgresDialect) SecondsBetween(from, to string) string {
+	return "CAST(EXTRACT(EPOCH FROM (" + to + " - " + from + ")) AS DOUBLE PRECISION)"
+}
+
+func (postgresDialect) HourBucket(column string) string {
+	return "to_char(date_trunc('hour', " + column + "), 'YYYY-MM-DD HH24:00:00')"
+}
+
+func (postgresDialect) TableExistsQuery(table string) (string, []interface{}) {
+	return `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?`, []interface{}{table}
+}
+
+func (postgresDialect) ColumnExistsQuery(table, column string) (string, []interface{}) {
+	return `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?`, []interface{}{table, column}
+}
+
+// SERIAL のシーケンスは明示したIDでは進まないため、setval で最大値に合わせる
+func (postgresDialect) SyncSequence(table, column string) string {
+	return "SELECT setval(pg_get_serial_sequence('" + table + "', '" + column + "'), COALESCE(MAX(" + column + "), 0) + 1, false) FROM " + table
+}
+
+func (postgresDialect) IsMySQL() bool { return false }
+
+// rebindDB は ? プレースホルダのクエリをドライバの形式に置き換えて実行する DBTX
+// sqlx.In の結果などで既に置き換え済みのクエリは ? を含まないため、そのまま実行される
+type rebindDB struct {
+	DBTX
+	bindType int
+}
+
+// ? 形式のドライバではそのまま返す
+func withRebind(db DBTX, d Dialect) DBTX {
+	if d.BindType() == sqlx.QUESTION {
+		return db
+	}
+	return &rebindDB{DBTX: db, bindType: d.BindType()}
+}
+
+func (d *rebindDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return d.DBTX.GetContext(ctx, dest, sqlx.Rebind(d.bindType, query), args...)
+}
+
+func (d *rebindDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return d.DBTX.SelectContext(ctx, dest, sqlx.Rebind(d.bindType, query), args...)
+}
+
+func (d *rebindDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return d.DBTX.ExecContext(ctx, sqlx.Rebind(d.bindType, query), args...)
+}
+
+func (d *rebindDB) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	return d.DBTX.QueryxContext(ctx, sqlx.Rebind(d.bindType, query), args...)
+}
+
+func (d *rebindDB) PreparexContext(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	return d.DBTX.PreparexContext(ctx, sqlx.Rebind(d.bindType, query))
+}
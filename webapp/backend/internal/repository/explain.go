@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"backend/internal/config"
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// フィンガープリントごとの実行計画（/debug/vars で参照できる）
+var explainPlans = expvar.NewMap("db_explain_plans")
+
+// explainer は Explain が有効な場合に、SELECT の実行計画をフィンガープリントごとに一度だけ取得してログに出す
+// 本番では無効にしておくこと（初回のクエリごとに EXPLAIN が追加で実行される）
+type explainer struct {
+	conn *sqlx.DB
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func newExplainer(conn *sqlx.DB, cfg config.DB) *explainer {
+	if !cfg.Explain {
+		return nil
+	}
+	return &explainer{conn: conn, seen: make(map[string]bool)}
+}
+
+func (e *explainer) explain(ctx context.Context, query string, args []interface{}) {
+	if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(query)), "SELECT") {
+		return
+	}
+	fp := fingerprint(query)
+	e.mu.Lock()
+	if e.seen[fp] {
+		e.mu.Unlock()
+		return
+	}
+	e.seen[fp] = true
+	e.mu.Unlock()
+
+	// 呼び出し元のトランザクションやデッドラインに影響しないよう、別のコネクションで非同期に実行する
+	// ログには呼び出し元のリクエストIDを引き継ぐ
+	logCtx := context.WithoutCancel(ctx)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var plan string
+		if err := e.conn.GetContext(ctx, &plan, "EXPLAIN FORMAT=JSON "+query, args...); err != nil {
+			slog.WarnContext(logCtx, "explain failed", "sql", fp, "err", err)
+			return
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, []byte(plan)); err == nil {
+			plan = compact.String()
+		}
+		explainPlans.Set(fp, expvarString(plan))
+
+		// フルスキャンを含む計画は目立つように出力する
+		if strings.Contains(plan, `"access_type":"ALL"`) {
+			slog.WarnContext(logCtx, "explain: full scan", "sql", fp, "plan", plan)
+			return
+		}
+		slog.InfoContext(logCtx, "explain", "sql", fp, "plan", plan)
+	}()
+}
+
+// 実行計画のJSONをそのまま /debug/vars に埋め込む
+type expvarString string
+
+func (s expvarString) String() string { return string(s) }
+
+// explainDB は読み取りクエリの実行計画を記録する DBTX
+type explainDB struct {
+	DBTX
+	explainer *explainer
+}
+
+// explainer が無効（nil）の場合はそのまま返す
+func withExplain(db DBTX, e *explainer) DBTX {
+	if e == nil {
+		return db
+	}
+	return &explainDB{DBTX: db, explainer: e}
+}
+
+func (d *explainDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	d.explainer.explain(ctx, query, args)
+	return d.DBTX.GetContext(ctx, dest, query, args...)
+}
+
+func (d *explainDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	d.explainer.explain(ctx, query, args)
+	return d.DBTX.SelectContext(ctx, dest, query, args...)
+}
+
+func (d *explainDB) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	d.explainer.explain(ctx, query, args)
+	return d.DBTX.QueryxContext(ctx, query, args...)
+}
+
+func (d *explainDB) NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error) {
+	if q, args, err := sqlx.Named(query, arg); err == nil {
+		d.explainer.explain(ctx, q, args)
+	}
+	return d.DBTX.NamedQueryContext(ctx, query, arg)
+}
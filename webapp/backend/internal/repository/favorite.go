@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"backend/internal/cache"
+	"backend/internal/model"
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type FavoriteRepository struct {
+	db       DBTX
+	timeouts *QueryTimeouts
+	dialect  Dialect
+	changes  *changeLog
+}
+
+func NewFavoriteRepository(db DBTX, timeouts *QueryTimeouts, dialect Dialect) *FavoriteRepository {
+	return &FavoriteRepository{db: db, timeouts: timeouts, dialect: dialect}
+}
+
+// お気に入りに追加（登録済みの場合は何もしない）
+func (r *FavoriteRepository) Add(ctx context.Context, userID, productID int) error {
+	query := r.dialect.InsertIgnore("favorites", "(user_id, product_id, created_at) VALUES (?, ?, ?)")
+	qctx, cancel := r.timeouts.write(ctx, "AddFavorite")
+	defer cancel()
+	if _, err := r.db.ExecContext(qctx, query, userID, productID, time.Now().UTC()); err != nil {
+		return qctx.wrap(err)
+	}
+	r.changes.record(ctx, cache.EntityFavorite, int64(userID))
+	return nil
+}
+
+// お気に入りから削除
+func (r *FavoriteRepository) Remove(ctx context.Context, userID, productID int) error {
+	qctx, cancel := r.timeouts.write(ctx, "RemoveFavorite")
+	defer cancel()
+	if _, err := r.db.ExecContext(qctx, "DELETE FROM favorites WHERE user_id = ? AND product_id = ?", userID, productID); err != nil {
+		return qctx.wrap(err)
+	}
+	r.changes.record(ctx, cache.EntityFavorite, int64(userID))
+	return nil
+}
+
+// 指定した商品のうち、ユーザーがお気に入り登録している商品IDを返す
+func (r *FavoriteRepository) FavoritedProductIDs(ctx context.Context, userID int, productIDs []int) (map[int]bool, error) {
+	favorited := make(map[int]bool)
+	if len(productIDs) == 0 {
+		return favorited, nil
+	}
+	query, args, err := sqlx.In("SELECT product_id FROM favorites WHERE user_id = ? AND product_id IN (?)", userID, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	qctx, cancel := r.timeouts.listRead(ctx, "FavoritedProductIDs")
+	defer cancel()
+	if err := r.db.SelectContext(qctx, &ids, r.db.Rebind(query), args...); err != nil {
+		return nil, qctx.wrap(err)
+	}
+	for _, id := range ids {
+		favorited[id] = true
+	}
+	return favorited, nil
+}
+
+// ユーザーのお気に入りの件数と最終登録日時を取得（一覧の ETag 計算用）
+func (r *FavoriteRepository) Version(ctx context.Context, userID int) (time.Time, int, error) {
+	var row struct {
+		CreatedAt aggregateTime `db:"created_at"`
+		Count     int           `db:"cnt"`
+	}
+	query := "SELECT MAX(created_at) AS created_at, COUNT(*) AS cnt FROM favorites WHERE user_id = ?"
+	qctx, cancel := r.timeouts.listRead(ctx, "FavoritesVersion")
+	defer cancel()
+	if err := r.db.GetContext(qctx, &row, query, userID); err != nil {
+		return time.Time{}, 0, qctx.wrap(err)
+	}
+	return row.CreatedAt.Time, row.Count, nil
+}
+
+// ユーザーのお気に入り件数を取得
+func (r *FavoriteRepository) CountByUser(ctx context.Context, userID int) (int, error) {
+	var count int
+	qctx, cancel := r.timeouts.listRead(ctx, "CountFavorites")
+	defer cancel()
+	if err := r.db.GetContext(qctx, &count, "SELECT COUNT(*) FROM favorites WHERE user_id = ?", userID); err != nil {
+		return 0, qctx.wrap(err)
+	}
+	return count, nil
+}
+
+// ユーザーのお気に入り商品を登録が新しい順に取得
+func (r *FavoriteRepository) ListByUser(ctx context.Context, userID int, limit, offset int) ([]model.Product, error) {
+	products := []model.Product{}
+	query := `
+		SELECT p.product_id, p.name, p.value, p.currency, p.weight, p.image, p.description, p.category_id, p.rating_avg, p.rating_count
+		FROM favorites f
+		JOIN products p ON f.product_id = p.product_id
+		WHERE f.user_id = ?
+		ORDER BY f.created_at DESC, f.product_id DESC
+		LIMIT ? OFFSET ?`
+	qctx, cancel := r.timeouts.listRead(ctx, "ListFavorites")
+	defer cancel()
+	if err := r.db.SelectContext(qctx, &products, query, userID, limit, offset); err != nil {
+		return nil, qctx.wrap(err)
+	}
+	for i := range products {
+		products[i].Favorited = true
+	}
+	return products, nil
+}
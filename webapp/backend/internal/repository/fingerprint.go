@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	fingerprintString = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'`)
+	fingerprintNumber = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	fingerprintList   = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)+\s*\)`)
+	fingerprintValues = regexp.MustCompile(`(\(\?\+\))(?:\s*,\s*\(\?\+?\))+`)
+	fingerprintSpaces = regexp.MustCompile(`\s+`)
+	fingerprintNamed  = regexp.MustCompile(`:[A-Za-z_][A-Za-z0-9_]*`)
+)
+
+// fingerprint はSQLからリテラルやプレースホルダの個数の違いを取り除き、同じ形のクエリを同一視するためのキーを返す
+// 例: "SELECT * FROM t WHERE id IN (?, ?, ?)" → "select * from t where id in (?+)"
+func fingerprint(query string) string {
+	fp := fingerprintSpaces.ReplaceAllString(strings.TrimSpace(query), " ")
+	fp = fingerprintString.ReplaceAllString(fp, "?")
+	fp = fingerprintNamed.ReplaceAllString(fp, "?")
+	fp = fingerprintNumber.ReplaceAllString(fp, "?")
+	fp = fingerprintList.ReplaceAllString(fp, "(?+)")
+	fp = strings.ReplaceAll(fp, "(?)", "(?+)")
+	fp = fingerprintValues.ReplaceAllString(fp, "$1")
+	return strings.ToLower(fp)
+}
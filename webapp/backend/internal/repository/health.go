@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// ReplicaStatus はレプリカごとの死活と遅延
+type ReplicaStatus struct {
+	// 読み取りの振り分け対象か（応答しない場合と、遅延が DB_REPLICA_MAX_LAG を超えた場合は false）
+	Healthy bool
+	// SHOW REPLICA STATUS の Seconds_Behind_Source（取得できない場合は nil）
+	Lag *time.Duration
+}
+
+// Ping はプライマリへの接続を確認する
+func (s *Store) Ping(ctx context.Context) error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.PingContext(ctx)
+}
+
+// AppliedMigrationVersion は schema_migrations に記録された最新のバージョンを返す
+// テーブルが無い場合（マイグレーションを restore_and_migration.sh で管理している場合）と STORAGE=memory の場合は ok=false
+func (s *Store) AppliedMigrationVersion(ctx context.Context) (version int, ok bool, err error) {
+	if s.memory != nil {
+		return 0, false, nil
+	}
+	var n int
+	query, args := s.dialect.TableExistsQuery("schema_migrations")
+	if err := s.db.GetContext(ctx, &n, query, args...); err != nil {
+		return 0, false, err
+	}
+	if n == 0 {
+		return 0, false, nil
+	}
+	if err := s.db.GetContext(ctx, &version, "SELECT COALESCE(MAX(version), -1) FROM schema_migrations"); err != nil {
+		return 0, false, err
+	}
+	return version, true, nil
+}
+
+// ReplicaStatuses はレプリカごとの死活と遅延を返す（レプリカが無い場合は空）
+func (s *Store) ReplicaStatuses(ctx context.Context) []ReplicaStatus {
+	if s.router == nil {
+		return nil
+	}
+	statuses := make([]ReplicaStatus, len(s.router.replicas))
+	for i, rep := range s.router.replicas {
+		statuses[i].Healthy = rep.healthy.Load()
+		if !statuses[i].Healthy {
+			continue
+		}
+		statuses[i].Lag = replicaLag(ctx, rep)
+	}
+	return statuses
+}
+
+// 権限不足などで取得できない場合は nil を返す
+func replicaLag(ctx context.Context, rep *replica) *time.Duration {
+	rows, err := rep.conn.QueryxContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil
+	}
+	row := map[string]interface{}{}
+	if err := rows.MapScan(row); err != nil {
+		return nil
+	}
+	var raw string
+	switch v := row["Seconds_Behind_Source"].(type) {
+	case []byte:
+		raw = string(v)
+	case string:
+		raw = v
+	case int64:
+		raw = strconv.FormatInt(v, 10)
+	}
+	sec, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil
+	}
+	lag := time.Duration(sec) * time.Second
+	return &lag
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"time"
+)
+
+type IdempotencyRepository struct {
+	db       DBTX
+	schema   *SchemaCapabilities
+	timeouts *QueryTimeouts
+	dialect  Dialect
+}
+
+func NewIdempotencyRepository(db DBTX, schema *SchemaCapabilities, timeouts *QueryTimeouts, dialect Dialect) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db, schema: schema, timeouts: timeouts, dialect: dialect}
+}
+
+// Enabled は idempotency_keys テーブルがあるか（マイグレーション適用前は false）
+func (r *IdempotencyRepository) Enabled() bool {
+	return r.schema.IdempotencyKeys
+}
+
+// Reserve はキーを処理中として登録する。既に登録されている場合は false を返す
+func (r *IdempotencyRepository) Reserve(ctx context.Context, scope, key, requestHash string, now, expiresAt time.Time) (bool, error) {
+	qctx, cancel := r.timeouts.write(ctx, "ReserveIdempotencyKey")
+	defer cancel()
+	query := r.dialect.InsertIgnore("idempotency_keys", "(scope, idem_key, request_hash, created_at, expires_at) VALUES (?, ?, ?, ?, ?)")
+	res, err := r.db.ExecContext(qctx, query, scope, key, requestHash, now, expiresAt)
+	if err != nil {
+		return false, qctx.wrap(err)
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// Get は登録済みのキーを返す（無い場合は nil）
+// 登録直後の行を読むため、レプリカに振り分けられない QueryxContext でプライマリから読む
+func (r *IdempotencyRepository) Get(ctx context.Context, scope, key string) (*model.IdempotencyRecord, error) {
+	qctx, cancel := r.timeouts.pointRead(ctx, "GetIdempotencyKey")
+	defer cancel()
+	rows, err := r.db.QueryxContext(qctx, `
+		SELECT request_hash, status_code, content_type, response_body, created_at, expires_at
+		FROM idempotency_keys WHERE scope = ? AND idem_key = ?`, scope, key)
+	if err != nil {
+		return nil, qctx.wrap(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, qctx.wrap(rows.Err())
+	}
+	var rec model.IdempotencyRecord
+	if err := rows.StructScan(&rec); err != nil {
+		return nil, qctx.wrap(err)
+	}
+	return &rec, nil
+}
+
+// TakeOver は staleBefore より前から処理中のまま残っているキーを、新しいリクエストの処理中として登録し直す
+// 他のリクエストが先に引き継いだ場合は false を返す
+func (r *IdempotencyRepository) TakeOver(ctx context.Context, scope, key string, staleBefore, now, expiresAt time.Time) (bool, error) {
+	qctx, cancel := r.timeouts.write(ctx, "TakeOverIdempotencyKey")
+	defer cancel()
+	res, err := r.db.ExecContext(qctx, `
+		UPDATE idempotency_keys SET created_at = ?, expires_at = ?
+		WHERE scope = ? AND idem_key = ? AND status_code IS NULL AND created_at < ?`,
+		now, expiresAt, scope, key, staleBefore)
+	if err != nil {
+		return false, qctx.wrap(err)
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// Complete は処理結果を保存する
+func (r *IdempotencyRepository) Complete(ctx context.Context, scope, key string, statusCode int, contentType string, body []byte) error {
+	qctx, cancel := r.timeouts.write(ctx, "CompleteIdempotencyKey")
+	defer cancel()
+	_, err := r.db.ExecContext(qctx, `
+		UPDATE idempotency_keys SET status_code = ?, content_type = ?, response_body = ?
+		WHERE scope = ? AND idem_key = ?`,
+		statusCode, contentType, body, scope, key)
+	return qctx.wrap(err)
+}
+
+// Release はキーを削除する（処理に失敗し、再送で再実行させる場合）
+func (r *IdempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	qctx, cancel := r.timeouts.write(ctx, "ReleaseIdempotencyKey")
+	defer cancel()
+	_, err := r.db.ExecContext(qctx, "DELETE FROM idempotency_keys WHERE scope = ? AND idem_key = ?", scope, key)
+	return qctx.wrap(err)
+}
+
+// PurgeExpired は期限切れのキーを最大 limit 件削除する
+func (r *IdempotencyRepository) PurgeExpired(ctx context.Context, now time.Time, limit int) (int64, error) {
+	// MySQL は DELETE の対象テーブルをサブクエリで直接参照できないため、派生テーブルを挟む
+	query := `
+		DELETE FROM idempotency_keys WHERE (scope, idem_key) IN (
+			SELECT scope, idem_key FROM (
+				SELECT scope, idem_key FROM idempotency_keys WHERE expires_at < ? ORDER BY expires_at LIMIT ?
+			) t
+		)`
+	res, err := r.db.ExecContext(ctx, query, now, limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,353 @@
+package repository
+
+import (
+	"backend/internal/config"
+	"backend/internal/model"
+	"backend/internal/ulid"
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// STORAGE=memory の Store
+// データベースを使わず、全てのテーブルをプロセス内の map に保持する。フロントエンドの開発やハンドラのテスト向け
+// プロセスを終了するとデータは全て失われる
+
+// STORAGE=memory では全文検索（products.search_text）以外の全ての機能が使える
+var memorySchema = SchemaCapabilities{
+	OrderUpdatedAt:  true,
+	OrderPublicID:   true,
+	IdempotencyKeys: true,
+	OrderSummaries:  true,
+}
+
+// NewMemoryStore は STORAGE=memory 用の Store を返す。デモ用のデータ（seedMemory）を投入した状態で始まる
+func NewMemoryStore(cfg *config.Config) *Store {
+	db := &memoryDB{tables: newMemoryTables()}
+	seedMemory(db.tables, time.Now().UTC())
+	schema := memorySchema
+	return newMemoryStore(&memoryConn{db: db}, &schema, newStoreShared(cfg.Cache, cfg.Server.CursorKey))
+}
+
+func newMemoryStore(conn *memoryConn, schema *SchemaCapabilities, shared *storeShared) *Store {
+	changes := &changeLog{bus: shared.changes}
+	return &Store{
+		schema:        schema,
+		timeouts:      &QueryTimeouts{},
+		shared:        shared,
+		changes:       changes,
+		memory:        conn,
+		UserRepo:      &memoryUsers{conn: conn, changes: changes},
+		SessionRepo:   &memorySessions{conn: conn},
+		ProductRepo:   &memoryProducts{conn: conn, changes: changes, cursors: shared.cursors},
+		OrderRepo:     &memoryOrders{conn: conn, changes: changes},
+		CategoryRepo:  &memoryCategories{conn: conn},
+		ReviewRepo:    &memoryReviews{conn: conn, changes: changes},
+		FavoriteRepo:  &memoryFavorites{conn: conn, changes: changes},
+		CartRepo:      &memoryCarts{conn: conn},
+		RecommendRepo: &memoryRecommendations{conn: conn},
+		PriceHistRepo: &memoryPriceHistory{conn: conn},
+		OutboxRepo:    &memoryOutbox{conn: conn, enabled: shared.outbox},
+		IdemRepo:      &memoryIdempotency{conn: conn},
+		SummaryRepo:   &memorySummaries{conn: conn},
+		NotifyRepo:    &memoryNotifications{conn: conn},
+	}
+}
+
+// memoryDB は全てのテーブルを1つのロックで守る
+// トランザクションはロックを持ったままテーブルの複製に書き込み、コミットで複製と入れ替える（ロールバックは複製を捨てる）
+// 複製は全件のコピーのため、デモ・テスト程度のデータ量を前提とする
+type memoryDB struct {
+	mu     sync.Mutex
+	tables *memoryTables
+}
+
+// memoryConn はリポジトリがテーブルを読み書きするための入口
+type memoryConn struct {
+	db *memoryDB
+	// トランザクション内の Store のみ設定する（コミットまで db.tables の代わりに読み書きする複製）
+	tx   *memoryTables
+	txMu *sync.Mutex
+}
+
+// do はロックを取って fn にテーブルを渡す
+// fn の中で別のリポジトリを呼ぶとデッドロックするため、fn はテーブルの読み書きだけを行うこと
+func (c *memoryConn) do(ctx context.Context, fn func(t *memoryTables) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.tx != nil {
+		c.txMu.Lock()
+		defer c.txMu.Unlock()
+		return fn(c.tx)
+	}
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	return fn(c.db.tables)
+}
+
+// execTx はトランザクションを直列に実行する（実行中はトランザクション外の読み書きも待たせる）
+// トランザクション内で外側の Store を使うとデッドロックするため、fn には txStore だけを使うこと
+func (c *memoryConn) execTx(ctx context.Context, s *Store, fn func(txStore *Store) error) error {
+	if c.tx != nil {
+		return fn(s)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var txStore *Store
+	err := func() error {
+		c.db.mu.Lock()
+		defer c.db.mu.Unlock()
+		tx := &memoryConn{db: c.db, tx: c.db.tables.clone(), txMu: &sync.Mutex{}}
+		txStore = newMemoryStore(tx, s.schema, s.shared)
+		// 変更の通知はコミットの後に行う
+		txStore.changes.deferred = true
+		if err := fn(txStore); err != nil {
+			return err
+		}
+		c.db.tables = tx.tx
+		return nil
+	}()
+	if err != nil {
+		return err
+	}
+	txStore.changes.flush(ctx)
+	return nil
+}
+
+type memoryUserProduct struct {
+	UserID    int
+	ProductID int
+}
+
+type memoryUser struct {
+	model.User
+	Timezone string
+}
+
+type memoryOrder struct {
+	OrderID   int64
+	UserID    int
+	ProductID int
+	Status    model.ShippedStatus
+	CreatedAt time.Time
+	ArrivedAt sql.NullTime
+	UpdatedAt time.Time
+	PublicID  string
+}
+
+type memoryCartItem struct {
+	Quantity  int
+	UpdatedAt time.Time
+}
+
+type memoryOutboxEvent struct {
+	model.OutboxEvent
+	PublishedAt *time.Time
+	LastError   string
+}
+
+type memoryIdempotencyKey struct {
+	Scope string
+	Key   string
+}
+
+type memorySummaryKey struct {
+	Period      string
+	BucketStart time.Time
+}
+
+// memoryTables は STORAGE=memory の全てのテーブル
+// 行は値で保持し、ポインタのフィールド（在庫数など）は書き込み時にコピーする（呼び出し側と共有しない）
+type memoryTables struct {
+	users            map[int]*memoryUser
+	sessions         map[string]sessionEntry
+	categories       map[int]model.Category
+	products         map[int]model.Product
+	orders           map[int64]*memoryOrder
+	orderByPublicID  map[string]int64
+	reviews          map[int64]model.Review
+	favorites        map[memoryUserProduct]time.Time
+	cartItems        map[memoryUserProduct]memoryCartItem
+	cooccurrence     map[int]map[int]int
+	priceHistory     map[int64]model.PriceHistory
+	outbox           map[int64]*memoryOutboxEvent
+	idempotency      map[memoryIdempotencyKey]model.IdempotencyRecord
+	summaries        map[memorySummaryKey]model.OrderSummary
+	notificationPref map[int]model.NotificationPreferences
+
+	// 自動採番した最後の値
+	lastUserID, lastCategoryID, lastProductID             int
+	lastOrderID, lastReviewID, lastHistoryID, lastEventID int64
+}
+
+func newMemoryTables() *memoryTables {
+	return &memoryTables{
+		users:            map[int]*memoryUser{},
+		sessions:         map[string]sessionEntry{},
+		categories:       map[int]model.Category{},
+		products:         map[int]model.Product{},
+		orders:           map[int64]*memoryOrder{},
+		orderByPublicID:  map[string]int64{},
+		reviews:          map[int64]model.Review{},
+		favorites:        map[memoryUserProduct]time.Time{},
+		cartItems:        map[memoryUserProduct]memoryCartItem{},
+		cooccurrence:     map[int]map[int]int{},
+		priceHistory:     map[int64]model.PriceHistory{},
+		outbox:           map[int64]*memoryOutboxEvent{},
+		idempotency:      map[memoryIdempotencyKey]model.IdempotencyRecord{},
+		summaries:        map[memorySummaryKey]model.OrderSummary{},
+		notificationPref: map[int]model.NotificationPreferences{},
+	}
+}
+
+// clone はトランザクション用にテーブルを複製する。トランザクション内で書き換える行はポインタを共有しない
+func (t *memoryTables) clone() *memoryTables {
+	c := *t
+	c.users = make(map[int]*memoryUser, len(t.users))
+	for id, u := range t.users {
+		u := *u
+		c.users[id] = &u
+	}
+	c.sessions = cloneMap(t.sessions)
+	c.categories = cloneMap(t.categories)
+	c.products = cloneMap(t.products)
+	c.orders = make(map[int64]*memoryOrder, len(t.orders))
+	for id, o := range t.orders {
+		o := *o
+		c.orders[id] = &o
+	}
+	c.orderByPublicID = cloneMap(t.orderByPublicID)
+	c.reviews = cloneMap(t.reviews)
+	c.favorites = cloneMap(t.favorites)
+	c.cartItems = cloneMap(t.cartItems)
+	c.cooccurrence = make(map[int]map[int]int, len(t.cooccurrence))
+	for id, related := range t.cooccurrence {
+		c.cooccurrence[id] = cloneMap(related)
+	}
+	c.priceHistory = cloneMap(t.priceHistory)
+	c.outbox = make(map[int64]*memoryOutboxEvent, len(t.outbox))
+	for id, e := range t.outbox {
+		e := *e
+		c.outbox[id] = &e
+	}
+	c.idempotency = cloneMap(t.idempotency)
+	c.summaries = cloneMap(t.summaries)
+	c.notificationPref = cloneMap(t.notificationPref)
+	return &c
+}
+
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// copyProduct は在庫数などのポインタのフィールドを共有しないコピーを返す
+func copyProduct(p model.Product) model.Product {
+	if p.CategoryID != nil {
+		v := *p.CategoryID
+		p.CategoryID = &v
+	}
+	if p.Stock != nil {
+		v := *p.Stock
+		p.Stock = &v
+	}
+	if p.UpdatedAt != nil {
+		v := *p.UpdatedAt
+		p.UpdatedAt = &v
+	}
+	p.Relevance = nil
+	p.Favorited = false
+	return p
+}
+
+// listedProduct はお気に入り・関連商品の一覧で返す商品（SQL の実装と同じく在庫数と更新日時は含めない）
+func listedProduct(p model.Product) model.Product {
+	p = copyProduct(p)
+	p.Stock = nil
+	p.UpdatedAt = nil
+	return p
+}
+
+// memoryNow は orders.created_at などと同じく秒単位の現在時刻を返す
+// 同じリクエストで作成した注文の作成日時をそろえ、共起の集計で「一緒に注文された」とみなせるようにする
+func memoryNow() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// デモ用のデータ。パスワードはどちらも "password"（service.verifyPasswordHash と同じ SHA-256 + ソルト）
+// 空のテーブルに投入するため、IDは常に1から順に採番される
+const memoryDemoPasswordHash = "be1b1014e2c74c494db5162eeda0f4bf2f253f00e9441b1151ff67ec940beb65"
+
+func seedMemory(t *memoryTables, now time.Time) {
+	for _, u := range []struct{ name, role string }{{"demo", "user"}, {"admin", model.RoleAdmin}} {
+		t.lastUserID++
+		t.users[t.lastUserID] = &memoryUser{
+			User:     model.User{UserID: t.lastUserID, PasswordHash: memoryDemoPasswordHash, UserName: u.name, Role: u.role},
+			Timezone: "UTC",
+		}
+	}
+	for _, name := range []string{"食品", "日用品", "家電"} {
+		t.lastCategoryID++
+		t.categories[t.lastCategoryID] = model.Category{CategoryID: t.lastCategoryID, Name: name}
+	}
+	stock := func(n int) *int { return &n }
+	for _, p := range []struct {
+		name, image, description string
+		value, weight, category  int
+		stock                    *int
+	}{
+		{"りんご", "chello_01.png", "青森県産のりんご", 300, 5, 1, stock(100)},
+		{"みかん", "chello_02.png", "愛媛県産のみかん", 200, 3, 1, stock(50)},
+		{"ティッシュペーパー", "chello_03.png", "5箱セット", 400, 10, 2, stock(30)},
+		{"歯ブラシ", "chello_01.png", "やわらかめ", 150, 1, 2, stock(5)},
+		{"電気ケトル", "chello_02.png", "1.0L", 3000, 15, 3, stock(8)},
+		{"ドライヤー", "chello_03.png", "大風量タイプ", 5000, 12, 3, nil},
+	} {
+		t.lastProductID++
+		category := p.category
+		updatedAt := now
+		t.products[t.lastProductID] = model.Product{
+			ProductID:   t.lastProductID,
+			Name:        p.name,
+			Value:       p.value,
+			Currency:    model.DefaultCurrency,
+			Weight:      p.weight,
+			Image:       p.image,
+			Description: p.description,
+			CategoryID:  &category,
+			Stock:       p.stock,
+			UpdatedAt:   &updatedAt,
+		}
+	}
+	day := func(d int) time.Time { return time.Date(2025, 11, d, 10, 0, 0, 0, time.UTC) }
+	for _, o := range []struct {
+		productID int
+		status    model.ShippedStatus
+		createdAt time.Time
+		arrivedAt sql.NullTime
+	}{
+		{1, model.StatusCompleted, day(1), sql.NullTime{Time: day(2), Valid: true}},
+		{3, model.StatusDelivering, day(3), sql.NullTime{}},
+		{5, model.StatusShipping, day(4), sql.NullTime{}},
+	} {
+		t.lastOrderID++
+		order := &memoryOrder{
+			OrderID:   t.lastOrderID,
+			UserID:    1,
+			ProductID: o.productID,
+			Status:    o.status,
+			CreatedAt: o.createdAt,
+			ArrivedAt: o.arrivedAt,
+			UpdatedAt: now,
+			PublicID:  ulid.Make(o.createdAt),
+		}
+		t.orders[order.OrderID] = order
+		t.orderByPublicID[order.PublicID] = order.OrderID
+	}
+}
@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"time"
+)
+
+// STORAGE=memory のアウトボックスと注文の集計
+
+type memoryOutbox struct {
+	conn *memoryConn
+	// リレーが動いていない場合は記録しない（OutboxRepository と同じ）
+	enabled bool
+}
+
+func (r *memoryOutbox) Add(ctx context.Context, eventType string, aggregateID int64, payload interface{}) error {
+	if !r.enabled {
+		return nil
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return r.conn.do(ctx, func(t *memoryTables) error {
+		t.lastEventID++
+		t.outbox[t.lastEventID] = &memoryOutboxEvent{OutboxEvent: model.OutboxEvent{
+			EventID:     t.lastEventID,
+			EventType:   eventType,
+			AggregateID: aggregateID,
+			Payload:     body,
+			CreatedAt:   time.Now().UTC(),
+		}}
+		return nil
+	})
+}
+
+// プロセス内にしかデータが無く、ほかのサーバーと配信を分け合うことは無いため、リースは常に取得できる
+func (r *memoryOutbox) AcquireRelayLease(ctx context.Context, owner string, lease time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (r *memoryOutbox) ReleaseRelayLease(ctx context.Context, owner string) error {
+	return nil
+}
+
+func (r *memoryOutbox) ListPending(ctx context.Context, limit int) ([]model.OutboxEvent, error) {
+	events := []model.OutboxEvent{}
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, e := range t.outbox {
+			if e.PublishedAt != nil {
+				continue
+			}
+			event := e.OutboxEvent
+			event.Payload = slices.Clone(e.Payload)
+			if o, ok := t.orders[e.AggregateID]; ok {
+				event.AggregateKey = o.PublicID
+			}
+			events = append(events, event)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].EventID < events[j].EventID })
+	return page(events, limit, 0), nil
+}
+
+func (r *memoryOutbox) MarkPublished(ctx context.Context, eventIDs []int64) error {
+	now := time.Now().UTC()
+	return r.conn.do(ctx, func(t *memoryTables) error {
+		for _, id := range eventIDs {
+			if e, ok := t.outbox[id]; ok {
+				e.PublishedAt = &now
+			}
+		}
+		return nil
+	})
+}
+
+func (r *memoryOutbox) RecordFailure(ctx context.Context, eventID int64, cause error) error {
+	msg := cause.Error()
+	if len(msg) > maxOutboxErrorLength {
+		msg = msg[:maxOutboxErrorLength]
+	}
+	return r.conn.do(ctx, func(t *memoryTables) error {
+		if e, ok := t.outbox[eventID]; ok {
+			e.Attempts++
+			e.LastError = msg
+		}
+		return nil
+	})
+}
+
+func (r *memoryOutbox) PurgePublished(ctx context.Context, before time.Time, limit int) (int64, error) {
+	var n int64
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		var ids []int64
+		for id, e := range t.outbox {
+			if e.PublishedAt != nil && e.PublishedAt.Before(before) {
+				ids = append(ids, id)
+			}
+		}
+		slices.Sort(ids)
+		for _, id := range page(ids, limit, 0) {
+			delete(t.outbox, id)
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+type memorySummaries struct {
+	conn *memoryConn
+}
+
+func (r *memorySummaries) Enabled() bool {
+	return true
+}
+
+func (r *memorySummaries) LatestBucket(ctx context.Context, period string) (t time.Time, ok bool, err error) {
+	err = r.conn.do(ctx, func(tables *memoryTables) error {
+		for k := range tables.summaries {
+			if k.Period == period && (!ok || k.BucketStart.After(t)) {
+				t, ok = k.BucketStart, true
+			}
+		}
+		return nil
+	})
+	return t, ok, err
+}
+
+func (r *memorySummaries) EarliestOrder(ctx context.Context) (t time.Time, ok bool, err error) {
+	err = r.conn.do(ctx, func(tables *memoryTables) error {
+		for _, o := range tables.orders {
+			if !ok || o.CreatedAt.Before(t) {
+				t, ok = o.CreatedAt, true
+			}
+		}
+		return nil
+	})
+	return t, ok, err
+}
+
+// AggregateHours は SummaryRepository.AggregateHours と同じく、作成は created_at、配送完了は arrived_at の時間に数える
+func (r *memorySummaries) AggregateHours(ctx context.Context, from, to time.Time) ([]model.OrderSummary, error) {
+	byBucket := make(map[time.Time]*model.OrderSummary)
+	get := func(at time.Time) *model.OrderSummary {
+		bucket := at.UTC().Truncate(time.Hour)
+		s, ok := byBucket[bucket]
+		if !ok {
+			s = &model.OrderSummary{Period: model.SummaryPeriodHour, BucketStart: bucket}
+			byBucket[bucket] = s
+		}
+		return s
+	}
+	in := func(at time.Time) bool { return !at.Before(from) && at.Before(to) }
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, o := range t.orders {
+			p, ok := t.products[o.ProductID]
+			if ok && in(o.CreatedAt) {
+				s := get(o.CreatedAt)
+				s.OrdersCreated++
+				if p.Currency == model.DefaultCurrency {
+					s.ValueCreated += int64(p.Value)
+				}
+			}
+			if o.Status == model.StatusCompleted && o.ArrivedAt.Valid && in(o.ArrivedAt.Time) {
+				s := get(o.ArrivedAt.Time)
+				s.OrdersDelivered++
+				s.DeliverySeconds += o.ArrivedAt.Time.Sub(o.CreatedAt).Seconds()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]model.OrderSummary, 0, len(byBucket))
+	for _, s := range byBucket {
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].BucketStart.Before(summaries[j].BucketStart) })
+	return summaries, nil
+}
+
+func (r *memorySummaries) Replace(ctx context.Context, period string, from, to time.Time, summaries []model.OrderSummary) error {
+	return r.conn.do(ctx, func(t *memoryTables) error {
+		for k := range t.summaries {
+			if k.Period == period && !k.BucketStart.Before(from) && k.BucketStart.Before(to) {
+				delete(t.summaries, k)
+			}
+		}
+		for _, s := range summaries {
+			s.Period = period
+			s.BucketStart = s.BucketStart.UTC()
+			s.AvgTimeToDeliverySeconds = nil
+			t.summaries[memorySummaryKey{Period: period, BucketStart: s.BucketStart}] = s
+		}
+		return nil
+	})
+}
+
+func (r *memorySummaries) List(ctx context.Context, period string, from, to time.Time) ([]model.OrderSummary, error) {
+	summaries := []model.OrderSummary{}
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for k, s := range t.summaries {
+			if k.Period == period && !k.BucketStart.Before(from) && k.BucketStart.Before(to) {
+				summaries = append(summaries, s)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].BucketStart.Before(summaries[j].BucketStart) })
+	return summaries, nil
+}
+
+func (r *memorySummaries) DeliveryTotals(ctx context.Context) (delivered int, seconds float64, err error) {
+	err = r.conn.do(ctx, func(t *memoryTables) error {
+		for k, s := range t.summaries {
+			if k.Period == model.SummaryPeriodDay {
+				delivered += s.OrdersDelivered
+				seconds += s.DeliverySeconds
+			}
+		}
+		return nil
+	})
+	return delivered, seconds, err
+}
@@ -0,0 +1,464 @@
+package repository
+
+import (
+	"backend/internal/cache"
+	"backend/internal/model"
+	"backend/internal/ulid"
+	"cmp"
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+)
+
+// STORAGE=memory の注文
+
+type memoryOrders struct {
+	conn    *memoryConn
+	changes *changeLog
+}
+
+func (r *memoryOrders) Create(ctx context.Context, order *model.Order) error {
+	o := &memoryOrder{
+		UserID:    order.UserID,
+		ProductID: order.ProductID,
+		Status:    model.StatusShipping,
+		CreatedAt: memoryNow(),
+		PublicID:  ulid.New(),
+	}
+	o.UpdatedAt = time.Now().UTC()
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		// 外部キー制約と同じく、存在しない商品・ユーザーの注文は作らない
+		if _, ok := t.products[o.ProductID]; !ok {
+			return sql.ErrNoRows
+		}
+		if _, ok := t.users[o.UserID]; !ok {
+			return sql.ErrNoRows
+		}
+		t.lastOrderID++
+		o.OrderID = t.lastOrderID
+		t.orders[o.OrderID] = o
+		t.orderByPublicID[o.PublicID] = o.OrderID
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.changes.record(ctx, cache.EntityOrder, o.OrderID)
+	order.OrderID, order.PublicID = o.OrderID, o.PublicID
+	return nil
+}
+
+func (r *memoryOrders) FindIDByPublicID(ctx context.Context, publicID string) (int64, error) {
+	var id int64
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		found, ok := t.orderByPublicID[publicID]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		id = found
+		return nil
+	})
+	return id, err
+}
+
+func (r *memoryOrders) FindOwner(ctx context.Context, ref model.OrderRef) (int64, int, error) {
+	var orderID int64
+	var userID int
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		id := ref.ID
+		if ref.PublicID != "" {
+			id = t.orderByPublicID[ref.PublicID]
+		}
+		o, ok := t.orders[id]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		orderID, userID = o.OrderID, o.UserID
+		return nil
+	})
+	return orderID, userID, err
+}
+
+func (r *memoryOrders) FindByID(ctx context.Context, orderID int64) (*model.Order, error) {
+	var order model.Order
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		o, ok := t.orders[orderID]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		p, ok := t.products[o.ProductID]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		order = o.toModel(p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// 注文は作成時に公開IDを割り当てるため、割り当てが必要な注文は無い
+func (r *memoryOrders) AssignPublicIDs(ctx context.Context, limit int) (int, error) {
+	return 0, nil
+}
+
+func (r *memoryOrders) PublicIDs(ctx context.Context, orderIDs []int64) (map[int64]string, error) {
+	ids := make(map[int64]string, len(orderIDs))
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, id := range orderIDs {
+			if o, ok := t.orders[id]; ok {
+				ids[id] = o.PublicID
+			}
+		}
+		return nil
+	})
+	return ids, err
+}
+
+func (r *memoryOrders) UpdateStatuses(ctx context.Context, orderIDs []int64, newStatus model.ShippedStatus) error {
+	if len(orderIDs) == 0 {
+		return nil
+	}
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		now := time.Now().UTC()
+		for _, id := range orderIDs {
+			if o, ok := t.orders[id]; ok {
+				o.setStatus(newStatus, now)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.changes.record(ctx, cache.EntityOrderStatus, 0)
+	return nil
+}
+
+func (r *memoryOrders) GetStatus(ctx context.Context, orderID int64) (model.ShippedStatus, error) {
+	var status model.ShippedStatus
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		o, ok := t.orders[orderID]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		status = o.Status
+		return nil
+	})
+	return status, err
+}
+
+func (r *memoryOrders) UpdateStatusesConditional(ctx context.Context, orderIDs []int64, newStatus, expectedCurrent model.ShippedStatus) (int64, error) {
+	var affected int64
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		now := time.Now().UTC()
+		for _, id := range orderIDs {
+			if o, ok := t.orders[id]; ok && o.Status == expectedCurrent {
+				o.setStatus(newStatus, now)
+				affected++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if affected > 0 {
+		r.changes.record(ctx, cache.EntityOrderStatus, 0)
+	}
+	return affected, nil
+}
+
+// 価値/重さの大きい順（重さが 0 の商品は末尾）に最大2000件
+func (r *memoryOrders) GetShippingOrders(ctx context.Context) ([]model.Order, error) {
+	const defaultCandidateLimit = 2000
+	var orders []model.Order
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, o := range t.orders {
+			if o.Status != model.StatusShipping {
+				continue
+			}
+			p, ok := t.products[o.ProductID]
+			if !ok {
+				continue
+			}
+			orders = append(orders, model.Order{OrderID: o.OrderID, Weight: p.Weight, Value: p.Value, Currency: p.Currency, PublicID: o.PublicID})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		a, b := orders[i], orders[j]
+		if (a.Weight == 0) != (b.Weight == 0) {
+			return b.Weight == 0
+		}
+		if a.Weight != 0 {
+			if d := cmp.Compare(float64(a.Value)/float64(a.Weight), float64(b.Value)/float64(b.Weight)); d != 0 {
+				return d > 0
+			}
+		}
+		return a.OrderID < b.OrderID
+	})
+	return orders[:min(len(orders), defaultCandidateLimit)], nil
+}
+
+func (r *memoryOrders) CountOrders(ctx context.Context, userID int, req model.ListRequest) (int, error) {
+	var count int
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		count = len(t.matchOrders(userID, req))
+		return nil
+	})
+	return count, err
+}
+
+func (r *memoryOrders) ListOrders(ctx context.Context, userID int, req model.ListRequest) ([]model.Order, error) {
+	orders, err := r.sortedOrders(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+	return page(orders, req.PageSize, req.Offset), nil
+}
+
+func (r *memoryOrders) StreamOrders(ctx context.Context, userID int, req model.ListRequest, fn func(*model.Order) error) error {
+	orders, err := r.sortedOrders(ctx, userID, req)
+	if err != nil {
+		return err
+	}
+	for i := range orders {
+		if err := fn(&orders[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedOrders は検索条件に一致する注文を一覧と同じ順序（orderSort）で返す
+func (r *memoryOrders) sortedOrders(ctx context.Context, userID int, req model.ListRequest) ([]model.Order, error) {
+	order, err := orderSort.Validate(req.SortField, req.SortOrder)
+	if err != nil {
+		return nil, err
+	}
+	var orders []model.Order
+	err = r.conn.do(ctx, func(t *memoryTables) error {
+		orders = t.matchOrders(userID, req)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		a, b := orders[i], orders[j]
+		var d int
+		switch order.Field {
+		case "product_name":
+			d = strings.Compare(a.ProductName, b.ProductName)
+		case "created_at":
+			d = a.CreatedAt.Compare(b.CreatedAt)
+		case "shipped_status":
+			d = strings.Compare(string(a.ShippedStatus), string(b.ShippedStatus))
+		case "arrived_at":
+			// 未配達の注文はソート順によらず末尾
+			if a.ArrivedAt.Valid != b.ArrivedAt.Valid {
+				return a.ArrivedAt.Valid
+			}
+			d = a.ArrivedAt.Time.Compare(b.ArrivedAt.Time)
+		}
+		if d == 0 {
+			if order.Field == "order_id" && order.Dir == "DESC" {
+				return a.OrderID > b.OrderID
+			}
+			return a.OrderID < b.OrderID
+		}
+		if order.Dir == "DESC" {
+			return d > 0
+		}
+		return d < 0
+	})
+	return orders, nil
+}
+
+// matchOrders はユーザーの注文のうち、商品名が検索語に一致するものを返す（LIKE と同じく大文字・小文字を区別しない）
+func (t *memoryTables) matchOrders(userID int, req model.ListRequest) []model.Order {
+	search := strings.ToLower(req.Search)
+	var orders []model.Order
+	for _, o := range t.orders {
+		if o.UserID != userID {
+			continue
+		}
+		p, ok := t.products[o.ProductID]
+		if !ok {
+			continue
+		}
+		if search != "" {
+			name := strings.ToLower(p.Name)
+			if req.Type == "prefix" && !strings.HasPrefix(name, search) {
+				continue
+			}
+			if req.Type != "prefix" && !strings.Contains(name, search) {
+				continue
+			}
+		}
+		orders = append(orders, o.toModel(p))
+	}
+	return orders
+}
+
+func (r *memoryOrders) LastModified(ctx context.Context, userID int) (time.Time, int, error) {
+	var latest time.Time
+	var count int
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, o := range t.orders {
+			if o.UserID != userID {
+				continue
+			}
+			count++
+			if o.UpdatedAt.After(latest) {
+				latest = o.UpdatedAt
+			}
+		}
+		return nil
+	})
+	return latest, count, err
+}
+
+func (r *memoryOrders) CountByStatus(ctx context.Context) ([]model.StatusCount, error) {
+	byStatus := map[model.ShippedStatus]int{}
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, o := range t.orders {
+			byStatus[o.Status]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	counts := []model.StatusCount{}
+	for status, n := range byStatus {
+		counts = append(counts, model.StatusCount{Status: status, Count: n})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Status < counts[j].Status })
+	return counts, nil
+}
+
+func (r *memoryOrders) CountClaimedSince(ctx context.Context, since time.Time) (n int, ok bool, err error) {
+	err = r.conn.do(ctx, func(t *memoryTables) error {
+		for _, o := range t.orders {
+			if (o.Status == model.StatusDelivering || o.Status == model.StatusCompleted) && !o.UpdatedAt.Before(since) {
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err == nil, err
+}
+
+func (r *memoryOrders) FindStuckDelivering(ctx context.Context, cutoff time.Time, limit int) (ids []int64, ok bool, err error) {
+	var stuck []*memoryOrder
+	err = r.conn.do(ctx, func(t *memoryTables) error {
+		for _, o := range t.orders {
+			if o.Status == model.StatusDelivering && o.UpdatedAt.Before(cutoff) {
+				stuck = append(stuck, o)
+			}
+		}
+		sort.Slice(stuck, func(i, j int) bool {
+			if !stuck[i].UpdatedAt.Equal(stuck[j].UpdatedAt) {
+				return stuck[i].UpdatedAt.Before(stuck[j].UpdatedAt)
+			}
+			return stuck[i].OrderID < stuck[j].OrderID
+		})
+		ids = []int64{}
+		for _, o := range stuck[:min(limit, len(stuck))] {
+			ids = append(ids, o.OrderID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return ids, true, nil
+}
+
+func (r *memoryOrders) AverageTimeToDelivery(ctx context.Context) (time.Duration, int, error) {
+	var total time.Duration
+	var count int
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, o := range t.orders {
+			if o.Status == model.StatusCompleted && o.ArrivedAt.Valid {
+				total += o.ArrivedAt.Time.Sub(o.CreatedAt)
+				count++
+			}
+		}
+		return nil
+	})
+	if err != nil || count == 0 {
+		return 0, 0, err
+	}
+	return total / time.Duration(count), count, nil
+}
+
+func (r *memoryOrders) TopProducts(ctx context.Context, from, to time.Time, limit int) ([]model.ProductSales, error) {
+	byProduct := map[int]*model.ProductSales{}
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, o := range t.orders {
+			if o.CreatedAt.Before(from) || !o.CreatedAt.Before(to) {
+				continue
+			}
+			p, ok := t.products[o.ProductID]
+			if !ok {
+				continue
+			}
+			s, ok := byProduct[p.ProductID]
+			if !ok {
+				s = &model.ProductSales{ProductID: p.ProductID, Name: p.Name, Currency: p.Currency}
+				byProduct[p.ProductID] = s
+			}
+			s.Orders++
+			s.Value += int64(p.Value)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	products := []model.ProductSales{}
+	for _, s := range byProduct {
+		products = append(products, *s)
+	}
+	sort.Slice(products, func(i, j int) bool {
+		if products[i].Orders != products[j].Orders {
+			return products[i].Orders > products[j].Orders
+		}
+		return products[i].ProductID < products[j].ProductID
+	})
+	return products[:min(limit, len(products))], nil
+}
+
+// setStatus は状態を変更する（ON UPDATE CURRENT_TIMESTAMP と同じく、値が変わる場合のみ更新日時を進める）
+func (o *memoryOrder) setStatus(status model.ShippedStatus, now time.Time) {
+	if o.Status == status {
+		return
+	}
+	o.Status = status
+	o.UpdatedAt = now
+}
+
+// toModel は一覧・詳細で返す注文（orderRow.toModel と同じ項目）
+func (o *memoryOrder) toModel(p model.Product) model.Order {
+	updatedAt := o.UpdatedAt
+	return model.Order{
+		OrderID:       o.OrderID,
+		ProductID:     o.ProductID,
+		ProductName:   p.Name,
+		ShippedStatus: o.Status,
+		CreatedAt:     o.CreatedAt,
+		ArrivedAt:     o.ArrivedAt,
+		UpdatedAt:     &updatedAt,
+		PublicID:      o.PublicID,
+	}
+}
@@ -0,0 +1,858 @@
+package repository
+
+import (
+	"backend/internal/cache"
+	"backend/internal/cursor"
+	"backend/internal/model"
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+)
+
+// STORAGE=memory の商品と、商品にひも付くレビュー・お気に入り・カート・関連商品・価格履歴
+
+type memoryProducts struct {
+	conn    *memoryConn
+	changes *changeLog
+	cursors *cursor.Codec
+}
+
+// matchProducts は一覧・件数・ファセット・エクスポートで共通の絞り込み（productWhere と同じ条件）
+// 検索語は商品名か説明文への部分一致で、LIKE と同じく大文字・小文字を区別しない
+func (t *memoryTables) matchProducts(req model.ListRequest) []model.Product {
+	search := strings.ToLower(req.Search)
+	var products []model.Product
+	for _, p := range t.products {
+		if search != "" && !strings.Contains(strings.ToLower(p.Name), search) && !strings.Contains(strings.ToLower(p.Description), search) {
+			continue
+		}
+		if req.Category > 0 && (p.CategoryID == nil || *p.CategoryID != req.Category) {
+			continue
+		}
+		products = append(products, copyProduct(p))
+	}
+	return products
+}
+
+// sortedProducts は検索条件に一致する商品を一覧と同じ順序（productSort）で返す
+func (r *memoryProducts) sortedProducts(ctx context.Context, req model.ListRequest) ([]model.Product, error) {
+	// 関連度スコアは全文検索時にしか存在しないため、商品ID順で代替する
+	if req.SortField == "relevance" {
+		req.SortField = "product_id"
+	}
+	order, err := productSort.Validate(req.SortField, req.SortOrder)
+	if err != nil {
+		return nil, err
+	}
+	var products []model.Product
+	err = r.conn.do(ctx, func(t *memoryTables) error {
+		products = t.matchProducts(req)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(products, func(i, j int) bool {
+		a, b := products[i], products[j]
+		var d int
+		switch order.Field {
+		case "name":
+			d = strings.Compare(a.Name, b.Name)
+		case "value":
+			d = a.Value - b.Value
+		case "weight":
+			d = a.Weight - b.Weight
+		}
+		if d == 0 {
+			if order.Field == "product_id" && order.Dir == "DESC" {
+				return a.ProductID > b.ProductID
+			}
+			return a.ProductID < b.ProductID
+		}
+		if order.Dir == "DESC" {
+			return d > 0
+		}
+		return d < 0
+	})
+	return products, nil
+}
+
+func (r *memoryProducts) ListProducts(ctx context.Context, userID int, req model.ListRequest) ([]model.Product, error) {
+	products, err := r.sortedProducts(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Cursor == "" {
+		return page(products, req.PageSize, req.Offset), nil
+	}
+	// キーセットページングではカーソル位置より後ろの先頭から返す（OFFSET は使わない）
+	if req.SortField == "relevance" {
+		req.SortField = "product_id"
+	}
+	order, err := productSort.Validate(req.SortField, req.SortOrder)
+	if err != nil {
+		return nil, err
+	}
+	c, err := decodeProductCursor(r.cursors, req, order.Field, order.Dir)
+	if err != nil {
+		return nil, err
+	}
+	start := sort.Search(len(products), func(i int) bool { return c.after(products[i]) })
+	return page(products, req.PageSize, start), nil
+}
+
+func (r *memoryProducts) EncodeCursor(req model.ListRequest, last model.Product) (string, error) {
+	return encodeProductCursor(r.cursors, req, last)
+}
+
+func (r *memoryProducts) StreamProducts(ctx context.Context, req model.ListRequest, fn func(*model.Product) error) error {
+	products, err := r.sortedProducts(ctx, req)
+	if err != nil {
+		return err
+	}
+	for i := range products {
+		if err := fn(&products[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *memoryProducts) CountFacets(ctx context.Context, req model.ListRequest) (*model.Facets, error) {
+	var products []model.Product
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		products = t.matchProducts(req)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	category, weight, value := map[int]int{}, map[int]int{}, map[int]int{}
+	for _, p := range products {
+		key := 0
+		if p.CategoryID != nil {
+			key = *p.CategoryID
+		}
+		category[key]++
+		weight[p.Weight/weightFacetBucket*weightFacetBucket]++
+		value[p.Value/valueFacetBucket*valueFacetBucket]++
+	}
+	return &model.Facets{
+		Category: facetCounts(category, 0),
+		Weight:   facetCounts(weight, weightFacetBucket),
+		Value:    facetCounts(value, valueFacetBucket),
+	}, nil
+}
+
+// facetCounts はバケットごとの件数をバケットの昇順に並べる（bucket が 0 の場合は上限を付けない）
+func facetCounts(counts map[int]int, bucket int) []model.FacetCount {
+	facets := []model.FacetCount{}
+	for key, n := range counts {
+		f := model.FacetCount{Key: key, Count: n}
+		if bucket > 0 {
+			f.Max = key + bucket - 1
+		}
+		facets = append(facets, f)
+	}
+	sort.Slice(facets, func(i, j int) bool { return facets[i].Key < facets[j].Key })
+	return facets
+}
+
+func (r *memoryProducts) CountProducts(ctx context.Context, userID int, req model.ListRequest) (int, error) {
+	var count int
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		count = len(t.matchProducts(req))
+		return nil
+	})
+	return count, err
+}
+
+func (r *memoryProducts) GetProduct(ctx context.Context, productID int) (*model.Product, error) {
+	var product model.Product
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		p, ok := t.products[productID]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		product = copyProduct(p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// 全文検索のインデックスは無いため、常に ErrSearchTextUnavailable を返す（検索は LIKE での絞り込みになる）
+func (r *memoryProducts) SearchProducts(ctx context.Context, req model.ListRequest) ([]SearchHit, int, error) {
+	return nil, 0, ErrSearchTextUnavailable
+}
+
+func (r *memoryProducts) GetProductsByIDs(ctx context.Context, ids []int) ([]model.Product, error) {
+	products := make([]model.Product, 0, len(ids))
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, id := range ids {
+			if p, ok := t.products[id]; ok {
+				products = append(products, copyProduct(p))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (r *memoryProducts) SuggestProductNames(ctx context.Context, prefix string, limit int) ([]string, error) {
+	prefix = strings.ToLower(prefix)
+	seen := map[string]bool{}
+	names := []string{}
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, p := range t.products {
+			if !seen[p.Name] && strings.HasPrefix(strings.ToLower(p.Name), prefix) {
+				seen[p.Name] = true
+				names = append(names, p.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names[:min(limit, len(names))], nil
+}
+
+func (r *memoryProducts) GetProductImage(ctx context.Context, productID int) (string, error) {
+	var image string
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		p, ok := t.products[productID]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		image = p.Image
+		return nil
+	})
+	return image, err
+}
+
+func (r *memoryProducts) LastModified(ctx context.Context) (time.Time, int, error) {
+	var latest time.Time
+	var count int
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, p := range t.products {
+			count++
+			if p.UpdatedAt != nil && p.UpdatedAt.After(latest) {
+				latest = *p.UpdatedAt
+			}
+		}
+		return nil
+	})
+	return latest, count, err
+}
+
+func (r *memoryProducts) DecrementStock(ctx context.Context, productID, quantity int) (*int, error) {
+	var stock *int
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		p, ok := t.products[productID]
+		if !ok || p.Stock == nil {
+			return nil
+		}
+		if *p.Stock < quantity {
+			return ErrInsufficientStock
+		}
+		remaining := *p.Stock - quantity
+		p.Stock = &remaining
+		t.putProduct(p)
+		stock = &remaining
+		return nil
+	})
+	if err != nil || stock == nil {
+		return nil, err
+	}
+	r.changes.record(ctx, cache.EntityProductStock, int64(productID))
+	left := *stock
+	return &left, nil
+}
+
+func (r *memoryProducts) ListLowStock(ctx context.Context, threshold int) ([]model.StockLevel, error) {
+	levels := []model.StockLevel{}
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, p := range t.products {
+			if p.Stock != nil && *p.Stock < threshold {
+				levels = append(levels, model.StockLevel{ProductID: p.ProductID, Name: p.Name, Stock: *p.Stock})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if levels[i].Stock != levels[j].Stock {
+			return levels[i].Stock < levels[j].Stock
+		}
+		return levels[i].ProductID < levels[j].ProductID
+	})
+	return levels, nil
+}
+
+// トランザクションはロックを持ったまま実行するため、行ロックは存在確認のみ
+func (r *memoryProducts) LockProduct(ctx context.Context, productID int) error {
+	return r.conn.do(ctx, func(t *memoryTables) error {
+		if _, ok := t.products[productID]; !ok {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
+
+func (r *memoryProducts) LockValues(ctx context.Context, productIDs []int) (map[int]int, error) {
+	values := make(map[int]int, len(productIDs))
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, id := range productIDs {
+			if p, ok := t.products[id]; ok {
+				values[id] = p.Value
+			}
+		}
+		return nil
+	})
+	return values, err
+}
+
+func (r *memoryProducts) CreateProduct(ctx context.Context, p *model.Product) (int, error) {
+	var id int
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		t.lastProductID++
+		id = t.lastProductID
+		row := copyProduct(*p)
+		row.ProductID = id
+		row.RatingAvg, row.RatingCount = 0, 0
+		t.putProduct(row)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	r.changes.record(ctx, cache.EntityProduct, int64(id))
+	return id, nil
+}
+
+func (r *memoryProducts) UpdateProduct(ctx context.Context, p *model.Product) error {
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		current, ok := t.products[p.ProductID]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		row := copyProduct(*p)
+		// 評価は UpdateProduct では変えない（ReviewRepository.RefreshProductRating が集計する）
+		row.RatingAvg, row.RatingCount = current.RatingAvg, current.RatingCount
+		t.putProduct(row)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.changes.record(ctx, cache.EntityProduct, int64(p.ProductID))
+	return nil
+}
+
+// 外部キーの ON DELETE CASCADE と同じく、商品にひも付く行も削除する
+func (r *memoryProducts) DeleteProduct(ctx context.Context, productID int) error {
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		if _, ok := t.products[productID]; !ok {
+			return sql.ErrNoRows
+		}
+		delete(t.products, productID)
+		for id, o := range t.orders {
+			if o.ProductID == productID {
+				delete(t.orderByPublicID, o.PublicID)
+				delete(t.orders, id)
+			}
+		}
+		for id, rv := range t.reviews {
+			if rv.ProductID == productID {
+				delete(t.reviews, id)
+			}
+		}
+		for k := range t.favorites {
+			if k.ProductID == productID {
+				delete(t.favorites, k)
+			}
+		}
+		for k := range t.cartItems {
+			if k.ProductID == productID {
+				delete(t.cartItems, k)
+			}
+		}
+		for id, h := range t.priceHistory {
+			if h.ProductID == productID {
+				delete(t.priceHistory, id)
+			}
+		}
+		delete(t.cooccurrence, productID)
+		for _, related := range t.cooccurrence {
+			delete(related, productID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.changes.record(ctx, cache.EntityProduct, int64(productID))
+	return nil
+}
+
+// 一括登録は商品の基本項目のみを書き込む（在庫数と評価は変えない。新しい商品は在庫管理の対象外）
+func (r *memoryProducts) BulkUpsertProducts(ctx context.Context, products []model.Product) (inserted, updated int, err error) {
+	err = r.conn.do(ctx, func(t *memoryTables) error {
+		for _, p := range products {
+			row := model.Product{
+				Name:        p.Name,
+				Value:       p.Value,
+				Currency:    p.Currency,
+				Weight:      p.Weight,
+				Image:       p.Image,
+				Description: p.Description,
+				CategoryID:  p.CategoryID,
+			}
+			if current, ok := t.products[p.ProductID]; ok && p.ProductID > 0 {
+				row.ProductID = p.ProductID
+				row.Stock = current.Stock
+				row.RatingAvg, row.RatingCount = current.RatingAvg, current.RatingCount
+				updated++
+			} else {
+				if p.ProductID > 0 {
+					row.ProductID = p.ProductID
+				} else {
+					row.ProductID = t.lastProductID + 1
+				}
+				// 明示したIDで挿入した場合も、後の自動採番が衝突しないようにする
+				t.lastProductID = max(t.lastProductID, row.ProductID)
+				inserted++
+			}
+			t.putProduct(copyProduct(row))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	if inserted > 0 || updated > 0 {
+		r.changes.record(ctx, cache.EntityProduct, 0)
+	}
+	return inserted, updated, nil
+}
+
+// putProduct は商品を書き込み、更新日時を進める（products.updated_at の ON UPDATE CURRENT_TIMESTAMP と同じ）
+func (t *memoryTables) putProduct(p model.Product) {
+	now := time.Now().UTC()
+	p.UpdatedAt = &now
+	p.Relevance = nil
+	p.Favorited = false
+	t.products[p.ProductID] = p
+}
+
+type memoryReviews struct {
+	conn    *memoryConn
+	changes *changeLog
+}
+
+func (r *memoryReviews) Create(ctx context.Context, review *model.Review) (int64, error) {
+	var id int64
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		t.lastReviewID++
+		id = t.lastReviewID
+		row := *review
+		row.ReviewID = id
+		t.reviews[id] = row
+		return nil
+	})
+	return id, err
+}
+
+func (r *memoryReviews) RefreshProductRating(ctx context.Context, productID int) error {
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		p, ok := t.products[productID]
+		if !ok {
+			return nil
+		}
+		var count, sum int
+		for _, rv := range t.reviews {
+			if rv.ProductID == productID {
+				count++
+				sum += rv.Rating
+			}
+		}
+		p.RatingCount, p.RatingAvg = count, 0
+		if count > 0 {
+			p.RatingAvg = float64(sum) / float64(count)
+		}
+		t.putProduct(p)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.changes.record(ctx, cache.EntityProduct, int64(productID))
+	return nil
+}
+
+func (r *memoryReviews) CountByProduct(ctx context.Context, productID int) (int, error) {
+	var count int
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, rv := range t.reviews {
+			if rv.ProductID == productID {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (r *memoryReviews) ListByProduct(ctx context.Context, productID int, limit, offset int) ([]model.Review, error) {
+	reviews := []model.Review{}
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, rv := range t.reviews {
+			if rv.ProductID == productID {
+				reviews = append(reviews, rv)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(reviews, func(i, j int) bool {
+		if !reviews[i].CreatedAt.Equal(reviews[j].CreatedAt) {
+			return reviews[i].CreatedAt.After(reviews[j].CreatedAt)
+		}
+		return reviews[i].ReviewID > reviews[j].ReviewID
+	})
+	return page(reviews, limit, offset), nil
+}
+
+// page は LIMIT / OFFSET と同じ範囲を返す
+func page[T any](rows []T, limit, offset int) []T {
+	start := min(max(offset, 0), len(rows))
+	end := min(start+max(limit, 0), len(rows))
+	return rows[start:end]
+}
+
+type memoryFavorites struct {
+	conn    *memoryConn
+	changes *changeLog
+}
+
+func (r *memoryFavorites) Add(ctx context.Context, userID, productID int) error {
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		k := memoryUserProduct{UserID: userID, ProductID: productID}
+		if _, ok := t.favorites[k]; !ok {
+			t.favorites[k] = time.Now().UTC()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.changes.record(ctx, cache.EntityFavorite, int64(userID))
+	return nil
+}
+
+func (r *memoryFavorites) Remove(ctx context.Context, userID, productID int) error {
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		delete(t.favorites, memoryUserProduct{UserID: userID, ProductID: productID})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.changes.record(ctx, cache.EntityFavorite, int64(userID))
+	return nil
+}
+
+func (r *memoryFavorites) FavoritedProductIDs(ctx context.Context, userID int, productIDs []int) (map[int]bool, error) {
+	favorited := make(map[int]bool)
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, id := range productIDs {
+			if _, ok := t.favorites[memoryUserProduct{UserID: userID, ProductID: id}]; ok {
+				favorited[id] = true
+			}
+		}
+		return nil
+	})
+	return favorited, err
+}
+
+func (r *memoryFavorites) Version(ctx context.Context, userID int) (time.Time, int, error) {
+	var latest time.Time
+	var count int
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for k, createdAt := range t.favorites {
+			if k.UserID != userID {
+				continue
+			}
+			count++
+			if createdAt.After(latest) {
+				latest = createdAt
+			}
+		}
+		return nil
+	})
+	return latest, count, err
+}
+
+func (r *memoryFavorites) CountByUser(ctx context.Context, userID int) (int, error) {
+	_, count, err := r.Version(ctx, userID)
+	return count, err
+}
+
+func (r *memoryFavorites) ListByUser(ctx context.Context, userID int, limit, offset int) ([]model.Product, error) {
+	type favorite struct {
+		product   model.Product
+		createdAt time.Time
+	}
+	var favorites []favorite
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for k, createdAt := range t.favorites {
+			if p, ok := t.products[k.ProductID]; ok && k.UserID == userID {
+				favorites = append(favorites, favorite{product: listedProduct(p), createdAt: createdAt})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(favorites, func(i, j int) bool {
+		if !favorites[i].createdAt.Equal(favorites[j].createdAt) {
+			return favorites[i].createdAt.After(favorites[j].createdAt)
+		}
+		return favorites[i].product.ProductID > favorites[j].product.ProductID
+	})
+	products := []model.Product{}
+	for _, f := range page(favorites, limit, offset) {
+		f.product.Favorited = true
+		products = append(products, f.product)
+	}
+	return products, nil
+}
+
+type memoryCarts struct {
+	conn *memoryConn
+}
+
+func (r *memoryCarts) AddItem(ctx context.Context, userID, productID, quantity int) error {
+	return r.conn.do(ctx, func(t *memoryTables) error {
+		k := memoryUserProduct{UserID: userID, ProductID: productID}
+		item := t.cartItems[k]
+		t.cartItems[k] = memoryCartItem{Quantity: item.Quantity + quantity, UpdatedAt: time.Now().UTC()}
+		return nil
+	})
+}
+
+func (r *memoryCarts) SetQuantity(ctx context.Context, userID, productID, quantity int) error {
+	return r.conn.do(ctx, func(t *memoryTables) error {
+		k := memoryUserProduct{UserID: userID, ProductID: productID}
+		if _, ok := t.cartItems[k]; !ok {
+			return sql.ErrNoRows
+		}
+		t.cartItems[k] = memoryCartItem{Quantity: quantity, UpdatedAt: time.Now().UTC()}
+		return nil
+	})
+}
+
+func (r *memoryCarts) RemoveItem(ctx context.Context, userID, productID int) error {
+	return r.conn.do(ctx, func(t *memoryTables) error {
+		delete(t.cartItems, memoryUserProduct{UserID: userID, ProductID: productID})
+		return nil
+	})
+}
+
+func (r *memoryCarts) ListItems(ctx context.Context, userID int) ([]model.CartItem, error) {
+	type cartRow struct {
+		item      model.CartItem
+		updatedAt time.Time
+	}
+	var rows []cartRow
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for k, c := range t.cartItems {
+			p, ok := t.products[k.ProductID]
+			if !ok || k.UserID != userID {
+				continue
+			}
+			rows = append(rows, cartRow{
+				item:      model.CartItem{ProductID: p.ProductID, ProductName: p.Name, Value: p.Value, Currency: p.Currency, Weight: p.Weight, Quantity: c.Quantity},
+				updatedAt: c.UpdatedAt,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if !rows[i].updatedAt.Equal(rows[j].updatedAt) {
+			return rows[i].updatedAt.Before(rows[j].updatedAt)
+		}
+		return rows[i].item.ProductID < rows[j].item.ProductID
+	})
+	items := make([]model.CartItem, len(rows))
+	for i, row := range rows {
+		items[i] = row.item
+	}
+	return items, nil
+}
+
+func (r *memoryCarts) ListItemsForUpdate(ctx context.Context, userID int) ([]model.RequestItem, error) {
+	items := []model.RequestItem{}
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for k, c := range t.cartItems {
+			if k.UserID == userID {
+				items = append(items, model.RequestItem{ProductID: k.ProductID, Quantity: c.Quantity})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ProductID < items[j].ProductID })
+	return items, nil
+}
+
+func (r *memoryCarts) Clear(ctx context.Context, userID int) error {
+	return r.conn.do(ctx, func(t *memoryTables) error {
+		for k := range t.cartItems {
+			if k.UserID == userID {
+				delete(t.cartItems, k)
+			}
+		}
+		return nil
+	})
+}
+
+type memoryRecommendations struct {
+	conn *memoryConn
+}
+
+// 同じユーザーが同時刻に作成した注文（1回の注文リクエスト）を「一緒に注文された」とみなす（cooccurrenceSelect と同じ集計）
+func (r *memoryRecommendations) RefreshCooccurrence(ctx context.Context) error {
+	return r.conn.do(ctx, func(t *memoryTables) error {
+		type group struct {
+			userID    int
+			createdAt time.Time
+		}
+		groups := map[group]map[int]int{}
+		for _, o := range t.orders {
+			g := group{userID: o.UserID, createdAt: o.CreatedAt}
+			if groups[g] == nil {
+				groups[g] = map[int]int{}
+			}
+			groups[g][o.ProductID]++
+		}
+		t.cooccurrence = map[int]map[int]int{}
+		for _, counts := range groups {
+			for a, na := range counts {
+				for b, nb := range counts {
+					if a == b {
+						continue
+					}
+					if t.cooccurrence[a] == nil {
+						t.cooccurrence[a] = map[int]int{}
+					}
+					t.cooccurrence[a][b] += na * nb
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// 作り直しは map の入れ替えのため、読み取りを止める時間は RefreshCooccurrence と変わらない
+func (r *memoryRecommendations) RebuildsBySwap() bool {
+	return false
+}
+
+func (r *memoryRecommendations) RebuildCooccurrence(ctx context.Context) error {
+	return r.RefreshCooccurrence(ctx)
+}
+
+func (r *memoryRecommendations) ListRelatedProducts(ctx context.Context, productID, limit int) ([]model.Product, error) {
+	type related struct {
+		product model.Product
+		score   int
+	}
+	var rows []related
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for id, score := range t.cooccurrence[productID] {
+			if p, ok := t.products[id]; ok {
+				rows = append(rows, related{product: listedProduct(p), score: score})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].score != rows[j].score {
+			return rows[i].score > rows[j].score
+		}
+		return rows[i].product.ProductID < rows[j].product.ProductID
+	})
+	products := []model.Product{}
+	for _, row := range page(rows, limit, 0) {
+		products = append(products, row.product)
+	}
+	return products, nil
+}
+
+type memoryPriceHistory struct {
+	conn *memoryConn
+}
+
+func (r *memoryPriceHistory) Create(ctx context.Context, h *model.PriceHistory) error {
+	return r.CreateBatch(ctx, []model.PriceHistory{*h})
+}
+
+func (r *memoryPriceHistory) CreateBatch(ctx context.Context, history []model.PriceHistory) error {
+	return r.conn.do(ctx, func(t *memoryTables) error {
+		for _, h := range history {
+			t.lastHistoryID++
+			h.HistoryID = t.lastHistoryID
+			if h.ChangedBy != nil {
+				by := *h.ChangedBy
+				h.ChangedBy = &by
+			}
+			t.priceHistory[h.HistoryID] = h
+		}
+		return nil
+	})
+}
+
+func (r *memoryPriceHistory) ListByProduct(ctx context.Context, productID, limit int) ([]model.PriceHistory, error) {
+	history := []model.PriceHistory{}
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, h := range t.priceHistory {
+			if h.ProductID == productID {
+				history = append(history, h)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(history, func(i, j int) bool {
+		if !history[i].ChangedAt.Equal(history[j].ChangedAt) {
+			return history[i].ChangedAt.After(history[j].ChangedAt)
+		}
+		return history[i].HistoryID > history[j].HistoryID
+	})
+	return page(history, limit, 0), nil
+}
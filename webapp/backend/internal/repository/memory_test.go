@@ -0,0 +1,167 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"backend/internal/config"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+// デモ用のデータを投入した STORAGE=memory の Store を開く
+func openMemoryStore(t *testing.T) *repository.Store {
+	t.Helper()
+	store := repository.NewMemoryStore(config.Default())
+	if err := store.DetectSchema(context.Background()); err != nil {
+		t.Fatalf("detect schema: %v", err)
+	}
+	return store
+}
+
+// デモ用のユーザー・商品・注文が入った状態で始まる
+func TestMemoryStoreSeed(t *testing.T) {
+	store := openMemoryStore(t)
+	ctx := context.Background()
+
+	user, err := store.UserRepo.FindByUserName(ctx, "demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.UserRepo.FindByUserName(ctx, "nobody"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("FindByUserName(nobody) err = %v, want sql.ErrNoRows", err)
+	}
+	if n, err := store.ProductRepo.CountProducts(ctx, 0, model.ListRequest{}); err != nil || n != 6 {
+		t.Fatalf("CountProducts = %d, %v, want 6", n, err)
+	}
+	req := model.ListRequest{PageSize: 10, SortField: "order_id", SortOrder: "asc"}
+	orders, err := store.OrderRepo.ListOrders(ctx, user.UserID, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 3 || orders[0].ProductName != "りんご" || !orders[0].ArrivedAt.Valid || orders[0].PublicID == "" {
+		t.Fatalf("ListOrders = %+v, want the three seeded orders", orders)
+	}
+}
+
+// ExecTx の関数がエラーを返した場合は書き込みが残らず、成功した場合は全て残る
+func TestMemoryExecTx(t *testing.T) {
+	store := openMemoryStore(t)
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	var productID int
+	err := store.ExecTx(ctx, func(txStore *repository.Store) error {
+		var err error
+		productID, err = txStore.ProductRepo.CreateProduct(ctx, &model.Product{Name: "p", Value: 100, Currency: "JPY", Weight: 1})
+		if err != nil {
+			return err
+		}
+		if _, err := txStore.ProductRepo.DecrementStock(ctx, 1, 10); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("ExecTx err = %v, want errAbort", err)
+	}
+	if _, err := store.ProductRepo.GetProduct(ctx, productID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetProduct after rollback err = %v, want sql.ErrNoRows", err)
+	}
+	if p, err := store.ProductRepo.GetProduct(ctx, 1); err != nil || *p.Stock != 100 {
+		t.Fatalf("stock after rollback = %v, %v, want 100", p, err)
+	}
+
+	err = store.ExecTx(ctx, func(txStore *repository.Store) error {
+		_, err := txStore.ProductRepo.DecrementStock(ctx, 1, 10)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err := store.ProductRepo.GetProduct(ctx, 1); err != nil || *p.Stock != 90 {
+		t.Fatalf("stock after commit = %v, %v, want 90", p, err)
+	}
+}
+
+// カーソルで辿った結果が OFFSET でのページングと一致する
+func TestMemoryListProductsCursor(t *testing.T) {
+	store := openMemoryStore(t)
+	ctx := context.Background()
+
+	for _, sort := range []struct{ field, order string }{{"value", "desc"}, {"name", "asc"}, {"product_id", "desc"}} {
+		req := model.ListRequest{PageSize: 100, SortField: sort.field, SortOrder: sort.order}
+		all, err := store.ProductRepo.ListProducts(ctx, 0, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.PageSize = 4
+		var paged []model.Product
+		for {
+			products, err := store.ProductRepo.ListProducts(ctx, 0, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			paged = append(paged, products...)
+			if len(products) < req.PageSize {
+				break
+			}
+			if req.Cursor, err = store.ProductRepo.EncodeCursor(req, products[len(products)-1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(paged) != len(all) {
+			t.Fatalf("%s %s: paged %d products, want %d", sort.field, sort.order, len(paged), len(all))
+		}
+		for i := range all {
+			if paged[i].ProductID != all[i].ProductID {
+				t.Fatalf("%s %s: product %d = %d, want %d", sort.field, sort.order, i, paged[i].ProductID, all[i].ProductID)
+			}
+		}
+	}
+}
+
+// 注文の状態の更新で更新日時が進み、商品を削除すると注文も消える
+func TestMemoryOrderLifecycle(t *testing.T) {
+	store := openMemoryStore(t)
+	ctx := context.Background()
+
+	order := &model.Order{UserID: 1, ProductID: 2}
+	if err := store.OrderRepo.Create(ctx, order); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := store.OrderRepo.FindIDByPublicID(ctx, order.PublicID); err != nil || id != order.OrderID {
+		t.Fatalf("FindIDByPublicID = %d, %v, want %d", id, err, order.OrderID)
+	}
+	shipping, err := store.OrderRepo.GetShippingOrders(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shipping) != 2 {
+		t.Fatalf("GetShippingOrders = %+v, want 2 orders", shipping)
+	}
+
+	ids := []int64{order.OrderID}
+	if n, err := store.OrderRepo.UpdateStatusesConditional(ctx, ids, model.StatusDelivering, model.StatusShipping); err != nil || n != 1 {
+		t.Fatalf("UpdateStatusesConditional = %d, %v, want 1", n, err)
+	}
+	if n, err := store.OrderRepo.UpdateStatusesConditional(ctx, ids, model.StatusDelivering, model.StatusShipping); err != nil || n != 0 {
+		t.Fatalf("second UpdateStatusesConditional = %d, %v, want 0", n, err)
+	}
+	got, err := store.OrderRepo.FindByID(ctx, order.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ShippedStatus != model.StatusDelivering || got.UpdatedAt == nil || got.UpdatedAt.Before(got.CreatedAt) {
+		t.Fatalf("FindByID = %+v, want a delivering order updated after creation", got)
+	}
+
+	if err := store.ProductRepo.DeleteProduct(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.OrderRepo.FindByID(ctx, order.OrderID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("FindByID after DeleteProduct err = %v, want sql.ErrNoRows", err)
+	}
+}
@@ -0,0 +1,303 @@
+package repository
+
+import (
+	"backend/internal/cache"
+	"backend/internal/model"
+	"context"
+	"database/sql"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// STORAGE=memory のユーザー・セッション・カテゴリ・通知設定・冪等キー
+
+type memoryUsers struct {
+	conn    *memoryConn
+	changes *changeLog
+}
+
+func (r *memoryUsers) FindByUserName(ctx context.Context, userName string) (*model.User, error) {
+	var user *model.User
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, u := range t.users {
+			if u.UserName == userName {
+				found := u.User
+				user = &found
+				return nil
+			}
+		}
+		return sql.ErrNoRows
+	})
+	return user, err
+}
+
+func (r *memoryUsers) UpdatePasswordHash(ctx context.Context, userID int, passwordHash string) error {
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		if u, ok := t.users[userID]; ok {
+			u.PasswordHash = passwordHash
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.changes.record(ctx, cache.EntityUser, int64(userID))
+	return nil
+}
+
+func (r *memoryUsers) FindRoleByID(ctx context.Context, userID int) (string, error) {
+	var role string
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		u, ok := t.users[userID]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		role = u.Role
+		return nil
+	})
+	return role, err
+}
+
+func (r *memoryUsers) FindTimezoneByID(ctx context.Context, userID int) (string, error) {
+	var tz string
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		u, ok := t.users[userID]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		tz = u.Timezone
+		return nil
+	})
+	return tz, err
+}
+
+func (r *memoryUsers) UpdateTimezone(ctx context.Context, userID int, tz string) error {
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		if u, ok := t.users[userID]; ok {
+			u.Timezone = tz
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.changes.record(ctx, cache.EntityUser, int64(userID))
+	return nil
+}
+
+type memorySessions struct {
+	conn *memoryConn
+}
+
+func (r *memorySessions) Create(ctx context.Context, userBusinessID int, duration time.Duration) (string, time.Time, error) {
+	sessionUUID, err := uuid.NewRandom()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(duration)
+	err = r.conn.do(ctx, func(t *memoryTables) error {
+		t.sessions[sessionUUID.String()] = sessionEntry{UserID: userBusinessID, ExpiresAt: expiresAt}
+		return nil
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return sessionUUID.String(), expiresAt, nil
+}
+
+func (r *memorySessions) FindUserBySessionID(ctx context.Context, sessionID string) (int, error) {
+	var userID int
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		entry, ok := t.sessions[sessionID]
+		if !ok || !time.Now().Before(entry.ExpiresAt) {
+			return sql.ErrNoRows
+		}
+		if _, ok := t.users[entry.UserID]; !ok {
+			return sql.ErrNoRows
+		}
+		userID = entry.UserID
+		return nil
+	})
+	return userID, err
+}
+
+type memoryCategories struct {
+	conn *memoryConn
+}
+
+func (r *memoryCategories) ListCategories(ctx context.Context) ([]model.Category, error) {
+	categories := []model.Category{}
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, c := range t.categories {
+			categories = append(categories, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].Name != categories[j].Name {
+			return categories[i].Name < categories[j].Name
+		}
+		return categories[i].CategoryID < categories[j].CategoryID
+	})
+	return categories, nil
+}
+
+type memoryNotifications struct {
+	conn *memoryConn
+}
+
+func (r *memoryNotifications) Get(ctx context.Context, userID int) (prefs model.NotificationPreferences, ok bool, err error) {
+	err = r.conn.do(ctx, func(t *memoryTables) error {
+		prefs, ok = t.notificationPref[userID]
+		prefs.Channels = slices.Clone(prefs.Channels)
+		return nil
+	})
+	if ok && prefs.Channels == nil {
+		prefs.Channels = []string{}
+	}
+	return prefs, ok, err
+}
+
+func (r *memoryNotifications) Put(ctx context.Context, userID int, prefs model.NotificationPreferences) error {
+	prefs.Channels = slices.Clone(prefs.Channels)
+	return r.conn.do(ctx, func(t *memoryTables) error {
+		t.notificationPref[userID] = prefs
+		return nil
+	})
+}
+
+func (r *memoryNotifications) DeliveryRecipients(ctx context.Context, orderIDs []int64) ([]model.DeliveryRecipient, error) {
+	if len(orderIDs) == 0 {
+		return nil, nil
+	}
+	recipients := []model.DeliveryRecipient{}
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		for _, id := range orderIDs {
+			o, ok := t.orders[id]
+			if !ok {
+				continue
+			}
+			prefs, ok := t.notificationPref[o.UserID]
+			if !ok || !prefs.DeliveryCompleted || len(prefs.Channels) == 0 {
+				continue
+			}
+			recipients = append(recipients, model.DeliveryRecipient{
+				OrderID:     o.OrderID,
+				PublicID:    o.PublicID,
+				UserID:      o.UserID,
+				ProductName: t.products[o.ProductID].Name,
+				Channels:    strings.Join(prefs.Channels, ","),
+				Email:       prefs.Email,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(recipients, func(i, j int) bool { return recipients[i].OrderID < recipients[j].OrderID })
+	// 同じ注文IDが重複して渡された場合も1件にする（IN と同じ）
+	recipients = slices.CompactFunc(recipients, func(a, b model.DeliveryRecipient) bool { return a.OrderID == b.OrderID })
+	return recipients, nil
+}
+
+type memoryIdempotency struct {
+	conn *memoryConn
+}
+
+func (r *memoryIdempotency) Enabled() bool {
+	return true
+}
+
+func (r *memoryIdempotency) Reserve(ctx context.Context, scope, key, requestHash string, now, expiresAt time.Time) (bool, error) {
+	var reserved bool
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		k := memoryIdempotencyKey{Scope: scope, Key: key}
+		if _, ok := t.idempotency[k]; ok {
+			return nil
+		}
+		t.idempotency[k] = model.IdempotencyRecord{RequestHash: requestHash, CreatedAt: now, ExpiresAt: expiresAt}
+		reserved = true
+		return nil
+	})
+	return reserved, err
+}
+
+func (r *memoryIdempotency) Get(ctx context.Context, scope, key string) (*model.IdempotencyRecord, error) {
+	var rec *model.IdempotencyRecord
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		if found, ok := t.idempotency[memoryIdempotencyKey{Scope: scope, Key: key}]; ok {
+			found.Body = slices.Clone(found.Body)
+			rec = &found
+		}
+		return nil
+	})
+	return rec, err
+}
+
+func (r *memoryIdempotency) TakeOver(ctx context.Context, scope, key string, staleBefore, now, expiresAt time.Time) (bool, error) {
+	var taken bool
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		k := memoryIdempotencyKey{Scope: scope, Key: key}
+		rec, ok := t.idempotency[k]
+		if !ok || rec.StatusCode != nil || !rec.CreatedAt.Before(staleBefore) {
+			return nil
+		}
+		rec.CreatedAt = now
+		rec.ExpiresAt = expiresAt
+		t.idempotency[k] = rec
+		taken = true
+		return nil
+	})
+	return taken, err
+}
+
+func (r *memoryIdempotency) Complete(ctx context.Context, scope, key string, statusCode int, contentType string, body []byte) error {
+	return r.conn.do(ctx, func(t *memoryTables) error {
+		k := memoryIdempotencyKey{Scope: scope, Key: key}
+		rec, ok := t.idempotency[k]
+		if !ok {
+			return nil
+		}
+		rec.StatusCode = &statusCode
+		rec.ContentType = contentType
+		rec.Body = slices.Clone(body)
+		t.idempotency[k] = rec
+		return nil
+	})
+}
+
+func (r *memoryIdempotency) Release(ctx context.Context, scope, key string) error {
+	return r.conn.do(ctx, func(t *memoryTables) error {
+		delete(t.idempotency, memoryIdempotencyKey{Scope: scope, Key: key})
+		return nil
+	})
+}
+
+func (r *memoryIdempotency) PurgeExpired(ctx context.Context, now time.Time, limit int) (int64, error) {
+	var n int64
+	err := r.conn.do(ctx, func(t *memoryTables) error {
+		var expired []memoryIdempotencyKey
+		for k, rec := range t.idempotency {
+			if rec.ExpiresAt.Before(now) {
+				expired = append(expired, k)
+			}
+		}
+		sort.Slice(expired, func(i, j int) bool {
+			return t.idempotency[expired[i]].ExpiresAt.Before(t.idempotency[expired[j]].ExpiresAt)
+		})
+		for _, k := range expired[:min(limit, len(expired))] {
+			delete(t.idempotency, k)
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
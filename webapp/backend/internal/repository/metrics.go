@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"backend/internal/config"
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"backend/internal/metrics"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 区別するフィンガープリントの上限（IN句の展開漏れなどで無制限に増えないようにする）
+const maxQueryMetricSeries = 1024
+
+// クエリの結果の分類
+const (
+	outcomeOK       = "ok"
+	outcomeNoRows   = "no_rows"
+	outcomeTimeout  = "timeout"
+	outcomeCanceled = "canceled"
+	outcomeError    = "error"
+)
+
+func queryOutcome(err error) string {
+	switch {
+	case err == nil:
+		return outcomeOK
+	case errors.Is(err, sql.ErrNoRows):
+		return outcomeNoRows
+	case errors.Is(err, context.DeadlineExceeded):
+		return outcomeTimeout
+	case errors.Is(err, context.Canceled):
+		return outcomeCanceled
+	default:
+		return outcomeError
+	}
+}
+
+// queryMetrics はフィンガープリントと結果の組ごとにクエリの実行時間のヒストグラムを集計する
+// /debug/vars の db_query_latency で参照できる
+type queryMetrics struct {
+	latency *metrics.HistogramVec
+	// クエリ文字列からフィンガープリントへの変換結果（正規表現を毎回実行しないため）
+	fps sync.Map
+	nfp atomic.Int64
+}
+
+var defaultQueryMetrics = &queryMetrics{latency: metrics.NewHistogramVec(metrics.LatencyBuckets, maxQueryMetricSeries)}
+
+func init() {
+	expvar.Publish("db_query_latency", expvar.Func(defaultQueryMetrics.latency.Snapshot))
+}
+
+// QueryMetrics を false にすると無効
+func newQueryMetrics(cfg config.DB) *queryMetrics {
+	if !cfg.QueryMetrics {
+		return nil
+	}
+	return defaultQueryMetrics
+}
+
+func (m *queryMetrics) fingerprint(query string) string {
+	if fp, ok := m.fps.Load(query); ok {
+		return fp.(string)
+	}
+	fp := fingerprint(query)
+	if m.nfp.Load() < maxQueryMetricSeries*4 {
+		if _, loaded := m.fps.LoadOrStore(query, fp); !loaded {
+			m.nfp.Add(1)
+		}
+	}
+	return fp
+}
+
+func (m *queryMetrics) observe(query string, start time.Time, err error) {
+	m.latency.Observe(m.fingerprint(query), queryOutcome(err), time.Since(start))
+}
+
+// metricsDB は全てのクエリの実行時間をフィンガープリント単位で集計する DBTX
+type metricsDB struct {
+	DBTX
+	metrics *queryMetrics
+}
+
+// 集計が無効（nil）の場合はそのまま返す
+func withQueryMetrics(db DBTX, m *queryMetrics) DBTX {
+	if m == nil {
+		return db
+	}
+	return &metricsDB{DBTX: db, metrics: m}
+}
+
+func (d *metricsDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) (err error) {
+	defer func(start time.Time) { d.metrics.observe(query, start, err) }(time.Now())
+	return d.DBTX.GetContext(ctx, dest, query, args...)
+}
+
+func (d *metricsDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) (err error) {
+	defer func(start time.Time) { d.metrics.observe(query, start, err) }(time.Now())
+	return d.DBTX.SelectContext(ctx, dest, query, args...)
+}
+
+func (d *metricsDB) ExecContext(ctx context.Context, query string, args ...interface{}) (res sql.Result, err error) {
+	defer func(start time.Time) { d.metrics.observe(query, start, err) }(time.Now())
+	return d.DBTX.ExecContext(ctx, query, args...)
+}
+
+// 行の読み出し時間は含まない（クエリの実行開始までを計測する）
+func (d *metricsDB) QueryxContext(ctx context.Context, query string, args ...interface{}) (rows *sqlx.Rows, err error) {
+	defer func(start time.Time) { d.metrics.observe(query, start, err) }(time.Now())
+	return d.DBTX.QueryxContext(ctx, query, args...)
+}
+
+func (d *metricsDB) NamedExecContext(ctx context.Context, query string, arg interface{}) (res sql.Result, err error) {
+	defer func(start time.Time) { d.metrics.observe(query, start, err) }(time.Now())
+	return d.DBTX.NamedExecContext(ctx, query, arg)
+}
+
+func (d *metricsDB) NamedQueryContext(ctx context.Context, query string, arg interface{}) (rows *sqlx.Rows, err error) {
+	defer func(start time.Time) { d.metrics.observe(query, start, err) }(time.Now())
+	return d.DBTX.NamedQueryContext(ctx, query, arg)
+}
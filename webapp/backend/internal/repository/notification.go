@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// NotificationRepository はユーザーごとの通知設定を読み書きする
+type NotificationRepository struct {
+	db       DBTX
+	schema   *SchemaCapabilities
+	timeouts *QueryTimeouts
+	dialect  Dialect
+}
+
+func NewNotificationRepository(db DBTX, schema *SchemaCapabilities, timeouts *QueryTimeouts, dialect Dialect) *NotificationRepository {
+	return &NotificationRepository{db: db, schema: schema, timeouts: timeouts, dialect: dialect}
+}
+
+// Get はユーザーの通知設定を返す（未設定の場合は ok=false）
+func (r *NotificationRepository) Get(ctx context.Context, userID int) (prefs model.NotificationPreferences, ok bool, err error) {
+	qctx, cancel := r.timeouts.pointRead(ctx, "GetNotificationPreferences")
+	defer cancel()
+	var row struct {
+		DeliveryCompleted bool   `db:"delivery_completed"`
+		Channels          string `db:"channels"`
+		Email             string `db:"email"`
+	}
+	err = r.db.GetContext(qctx, &row, "SELECT delivery_completed, channels, email FROM notification_preferences WHERE user_id = ?", userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.NotificationPreferences{}, false, nil
+	}
+	if err != nil {
+		return model.NotificationPreferences{}, false, qctx.wrap(err)
+	}
+	return model.NotificationPreferences{
+		DeliveryCompleted: row.DeliveryCompleted,
+		Channels:          SplitChannels(row.Channels),
+		Email:             row.Email,
+	}, true, nil
+}
+
+// Put はユーザーの通知設定を保存する
+func (r *NotificationRepository) Put(ctx context.Context, userID int, prefs model.NotificationPreferences) error {
+	qctx, cancel := r.timeouts.write(ctx, "PutNotificationPreferences")
+	defer cancel()
+	query := "INSERT INTO notification_preferences (user_id, delivery_completed, channels, email, updated_at) VALUES (?, ?, ?, ?, ?) " +
+		r.dialect.OnConflictUpdate("user_id") +
+		" delivery_completed = " + r.dialect.Excluded("delivery_completed") +
+		", channels = " + r.dialect.Excluded("channels") +
+		", email = " + r.dialect.Excluded("email") +
+		", updated_at = " + r.dialect.Excluded("updated_at")
+	_, err := r.db.ExecContext(qctx, query, userID, prefs.DeliveryCompleted, strings.Join(prefs.Channels, ","), prefs.Email, time.Now().UTC())
+	return qctx.wrap(err)
+}
+
+// DeliveryRecipients は orderIDs のうち、配送完了の通知を有効にしているユーザーの注文と宛先を返す
+func (r *NotificationRepository) DeliveryRecipients(ctx context.Context, orderIDs []int64) ([]model.DeliveryRecipient, error) {
+	if len(orderIDs) == 0 {
+		return nil, nil
+	}
+	publicID := "''"
+	if r.schema.OrderPublicID {
+		publicID = "COALESCE(o.public_id, '')"
+	}
+	query, args, err := sqlx.In(`
+		SELECT o.order_id, `+publicID+` AS public_id, o.user_id, p.name AS product_name, np.channels, np.email
+		FROM orders o
+		JOIN notification_preferences np ON np.user_id = o.user_id
+		JOIN products p ON p.product_id = o.product_id
+		WHERE o.order_id IN (?) AND np.delivery_completed = TRUE AND np.channels <> ''
+		ORDER BY o.order_id`, orderIDs)
+	if err != nil {
+		return nil, err
+	}
+	qctx, cancel := r.timeouts.listRead(ctx, "DeliveryRecipients")
+	defer cancel()
+	recipients := []model.DeliveryRecipient{}
+	if err := r.db.SelectContext(qctx, &recipients, r.db.Rebind(query), args...); err != nil {
+		return nil, qctx.wrap(err)
+	}
+	return recipients, nil
+}
+
+// SplitChannels はカンマ区切りのチャネルを分割する（空の場合は空のスライス）
+func SplitChannels(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
@@ -1,46 +1,223 @@
 package repository
 
 import (
+	"backend/internal/cache"
 	"backend/internal/model"
+	"backend/internal/querybuilder"
+	"backend/internal/telemetry"
+	"backend/internal/ulid"
 	"context"
 	"database/sql"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 
-	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type OrderRepository struct {
-	db DBTX
+	db       DBTX
+	schema   *SchemaCapabilities
+	timeouts *QueryTimeouts
+	dialect  Dialect
+	// 書き込みをキャッシュへ通知する（Store が設定する）
+	changes *changeLog
 }
 
-func NewOrderRepository(db DBTX) *OrderRepository {
-	return &OrderRepository{db: db}
+func NewOrderRepository(db DBTX, schema *SchemaCapabilities, timeouts *QueryTimeouts, dialect Dialect) *OrderRepository {
+	return &OrderRepository{db: db, schema: schema, timeouts: timeouts, dialect: dialect}
 }
 
-// 注文を作成し、生成された注文IDを返す
-func (r *OrderRepository) Create(ctx context.Context, order *model.Order) (string, error) {
-	query := `INSERT INTO orders (user_id, product_id, shipped_status, created_at) VALUES (?, ?, 'shipping', NOW())`
-	result, err := r.db.ExecContext(ctx, query, order.UserID, order.ProductID)
+// 注文を作成し、生成された注文IDと公開ID（ULID）を order に設定する
+func (r *OrderRepository) Create(ctx context.Context, order *model.Order) error {
+	query := `INSERT INTO orders (user_id, product_id, shipped_status, created_at) VALUES (?, ?, 'shipping', ` + r.dialect.Now() + `)`
+	args := []interface{}{order.UserID, order.ProductID}
+	var publicID string
+	if r.schema.OrderPublicID {
+		publicID = ulid.New()
+		query = `INSERT INTO orders (user_id, product_id, shipped_status, created_at, public_id) VALUES (?, ?, 'shipping', ` + r.dialect.Now() + `, ?)`
+		args = append(args, publicID)
+	}
+	qctx, cancel := r.timeouts.write(ctx, "CreateOrder")
+	defer cancel()
+	id, err := insertReturningID(qctx, r.db, r.dialect, query, "order_id", args...)
 	if err != nil {
-		return "", err
+		return qctx.wrap(err)
+	}
+	r.changes.record(ctx, cache.EntityOrder, id)
+	order.OrderID, order.PublicID = id, publicID
+	return nil
+}
+
+// 公開IDから注文IDを取得。存在しない場合は sql.ErrNoRows を返す
+func (r *OrderRepository) FindIDByPublicID(ctx context.Context, publicID string) (int64, error) {
+	if !r.schema.OrderPublicID {
+		return 0, sql.ErrNoRows
+	}
+	qctx, cancel := r.timeouts.pointRead(ctx, "FindOrderIDByPublicID")
+	defer cancel()
+	var id int64
+	if err := r.db.GetContext(qctx, &id, "SELECT order_id FROM orders WHERE public_id = ?", publicID); err != nil {
+		return 0, qctx.wrap(err)
+	}
+	return id, nil
+}
+
+// FindOwner は注文の order_id と注文したユーザーのIDを返す（存在しない場合は sql.ErrNoRows）
+// ref は公開ID・order_id のどちらでもよい
+func (r *OrderRepository) FindOwner(ctx context.Context, ref model.OrderRef) (int64, int, error) {
+	query, arg := "SELECT order_id, user_id FROM orders WHERE order_id = ?", interface{}(ref.ID)
+	if ref.PublicID != "" {
+		if !r.schema.OrderPublicID {
+			return 0, 0, sql.ErrNoRows
+		}
+		query, arg = "SELECT order_id, user_id FROM orders WHERE public_id = ?", ref.PublicID
 	}
-	id, err := result.LastInsertId()
+	qctx, cancel := r.timeouts.pointRead(ctx, "FindOrderOwner")
+	defer cancel()
+	var row struct {
+		OrderID int64 `db:"order_id"`
+		UserID  int   `db:"user_id"`
+	}
+	if err := r.db.GetContext(qctx, &row, query, arg); err != nil {
+		return 0, 0, qctx.wrap(err)
+	}
+	return row.OrderID, row.UserID, nil
+}
+
+// FindByID は注文を1件取得する（存在しない場合は sql.ErrNoRows）
+// 所有者は確認しないため、呼び出し側で確認済みの order_id を渡すこと
+func (r *OrderRepository) FindByID(ctx context.Context, orderID int64) (*model.Order, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			o.order_id,
+			o.product_id,
+			p.name AS product_name,
+			o.shipped_status,
+			o.created_at,
+			o.arrived_at%s
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.order_id = ?
+	`, r.optionalColumns())
+	qctx, cancel := r.timeouts.pointRead(ctx, "FindOrderByID")
+	defer cancel()
+	var row orderRow
+	if err := r.db.GetContext(qctx, &row, query, orderID); err != nil {
+		return nil, qctx.wrap(err)
+	}
+	orders := []model.Order{row.toModel()}
+	if err := r.ensurePublicIDs(ctx, orders); err != nil {
+		return nil, err
+	}
+	return &orders[0], nil
+}
+
+// 公開IDの無い注文（マイグレーション適用前に作成された注文）に最大 limit 件の公開IDを割り当て、対象にした件数を返す
+// 他のサーバーが先に割り当てた行は上書きしない（件数には含める）
+// ULID の時刻部分は注文の作成日時、乱数部分は新規の注文と同じく乱数とする（order_id から逆算できないようにする）
+// 最終更新日時（ETag の計算に使う）は変えない
+func (r *OrderRepository) AssignPublicIDs(ctx context.Context, limit int) (int, error) {
+	if !r.schema.OrderPublicID {
+		return 0, nil
+	}
+	qctx, cancel := r.timeouts.listRead(ctx, "AssignOrderPublicIDs")
+	defer cancel()
+	var rows []struct {
+		OrderID   int64     `db:"order_id"`
+		CreatedAt time.Time `db:"created_at"`
+	}
+	if err := r.db.SelectContext(qctx, &rows, "SELECT order_id, created_at FROM orders WHERE public_id IS NULL ORDER BY order_id LIMIT ?", limit); err != nil {
+		return 0, qctx.wrap(err)
+	}
+
+	for i, row := range rows {
+		if _, err := r.db.ExecContext(ctx, r.assignPublicIDQuery(), ulid.Make(row.CreatedAt), row.OrderID); err != nil {
+			return i, err
+		}
+	}
+	return len(rows), nil
+}
+
+// 公開IDが未設定の場合だけ割り当てる UPDATE 文
+func (r *OrderRepository) assignPublicIDQuery() string {
+	if r.schema.OrderUpdatedAt {
+		// MySQL の ON UPDATE CURRENT_TIMESTAMP は、同じ値を明示的に設定すると更新されない
+		return "UPDATE orders SET public_id = ?, updated_at = updated_at WHERE order_id = ? AND public_id IS NULL"
+	}
+	return "UPDATE orders SET public_id = ? WHERE order_id = ? AND public_id IS NULL"
+}
+
+// ensurePublicIDs は orders のうち公開IDの無い注文（バックフィルがまだ済んでいない注文）に公開IDを割り当てる
+// 他のサーバーが同時に割り当てた場合はその値を読み直して使うため、同じ注文に2つの公開IDが返ることはない
+// 作成日時を読んでいない注文は現在時刻の ULID にする
+func (r *OrderRepository) ensurePublicIDs(ctx context.Context, orders []model.Order) error {
+	if !r.schema.OrderPublicID {
+		return nil
+	}
+	for i := range orders {
+		o := &orders[i]
+		if o.PublicID != "" {
+			continue
+		}
+		t := o.CreatedAt
+		if t.IsZero() {
+			t = time.Now()
+		}
+		qctx, cancel := r.timeouts.write(ctx, "AssignOrderPublicID")
+		_, err := r.db.ExecContext(qctx, r.assignPublicIDQuery(), ulid.Make(t), o.OrderID)
+		if err == nil {
+			err = r.db.GetContext(qctx, &o.PublicID, "SELECT public_id FROM orders WHERE order_id = ?", o.OrderID)
+		}
+		cancel()
+		if err != nil {
+			return qctx.wrap(err)
+		}
+	}
+	return nil
+}
+
+// PublicIDs は orderIDs の公開IDを返す（公開IDが無い注文には割り当てる。存在しない注文は含まない）
+// orders.public_id が無いスキーマでは空の map を返す
+func (r *OrderRepository) PublicIDs(ctx context.Context, orderIDs []int64) (map[int64]string, error) {
+	ids := make(map[int64]string, len(orderIDs))
+	if !r.schema.OrderPublicID || len(orderIDs) == 0 {
+		return ids, nil
+	}
+	query, args, err := sqlx.In("SELECT order_id, COALESCE(public_id, '') AS public_id FROM orders WHERE order_id IN (?)", orderIDs)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	qctx, cancel := r.timeouts.listRead(ctx, "OrderPublicIDs")
+	defer cancel()
+	var rows []struct {
+		OrderID  int64  `db:"order_id"`
+		PublicID string `db:"public_id"`
+	}
+	if err := r.db.SelectContext(qctx, &rows, r.db.Rebind(query), args...); err != nil {
+		return nil, qctx.wrap(err)
 	}
-	return fmt.Sprintf("%d", id), nil
+	orders := make([]model.Order, len(rows))
+	for i, row := range rows {
+		orders[i] = model.Order{OrderID: row.OrderID, PublicID: row.PublicID}
+	}
+	if err := r.ensurePublicIDs(ctx, orders); err != nil {
+		return nil, err
+	}
+	for _, o := range orders {
+		ids[o.OrderID] = o.PublicID
+	}
+	return ids, nil
 }
 
 // 複数の注文IDのステータスを一括で更新
 // 主に配送ロボットが注文を引き受けた際に一括更新をするために使用
 // 最適化: 大量のorderIDsをバッチ処理に分割して、DBアクセス回数を削減
-func (r *OrderRepository) UpdateStatuses(ctx context.Context, orderIDs []int64, newStatus string) error {
+func (r *OrderRepository) UpdateStatuses(ctx context.Context, orderIDs []int64, newStatus model.ShippedStatus) error {
 	if len(orderIDs) == 0 {
 		return nil
 	}
@@ -61,18 +238,33 @@ func (r *OrderRepository) UpdateStatuses(ctx context.Context, orderIDs []int64,
 			return err
 		}
 		query = r.db.Rebind(query)
-		_, err = r.db.ExecContext(ctx, query, args...)
+		qctx, cancel := r.timeouts.write(ctx, "UpdateStatuses")
+		_, err = r.db.ExecContext(qctx, query, args...)
+		cancel()
 		if err != nil {
-			return err
+			return qctx.wrap(err)
 		}
 	}
 
+	r.changes.record(ctx, cache.EntityOrderStatus, 0)
 	return nil
 }
 
+// 注文の現在の状態を取得（存在しない場合は sql.ErrNoRows）
+func (r *OrderRepository) GetStatus(ctx context.Context, orderID int64) (model.ShippedStatus, error) {
+	qctx, cancel := r.timeouts.pointRead(ctx, "GetOrderStatus")
+	defer cancel()
+	var status model.ShippedStatus
+	if err := r.db.GetContext(qctx, &status, "SELECT shipped_status FROM orders WHERE order_id = ?", orderID); err != nil {
+		return "", qctx.wrap(err)
+	}
+	return status, nil
+}
+
 // UpdateStatusesConditional updates statuses only when current status equals expectedCurrent.
 // Returns number of rows affected.
-func (r *OrderRepository) UpdateStatusesConditional(ctx context.Context, orderIDs []int64, newStatus string, expectedCurrent string) (int64, error) {
+// 配送計画の確保も GetShippingOrders の候補数（defaultCandidateLimit）までのため、IN 句の1文で更新する
+func (r *OrderRepository) UpdateStatusesConditional(ctx context.Context, orderIDs []int64, newStatus, expectedCurrent model.ShippedStatus) (int64, error) {
 	if len(orderIDs) == 0 {
 		return 0, nil
 	}
@@ -82,154 +274,146 @@ func (r *OrderRepository) UpdateStatusesConditional(ctx context.Context, orderID
 		return 0, err
 	}
 	query = r.db.Rebind(query)
-	res, err := r.db.ExecContext(ctx, query, args...)
+	qctx, cancel := r.timeouts.write(ctx, "UpdateStatusesConditional")
+	defer cancel()
+	res, err := r.db.ExecContext(qctx, query, args...)
 	if err != nil {
-		return 0, err
+		return 0, qctx.wrap(err)
 	}
 	affected, err := res.RowsAffected()
 	if err != nil {
 		return 0, err
 	}
+	if affected > 0 {
+		r.changes.record(ctx, cache.EntityOrderStatus, 0)
+	}
 	return affected, nil
 }
 
 // 配送中(shipped_status:shipping)の注文一覧を取得
 func (r *OrderRepository) GetShippingOrders(ctx context.Context) ([]model.Order, error) {
-	tracer := otel.Tracer("backend/repository.OrderRepository")
-	ctx, span := tracer.Start(ctx, "GetShippingOrders")
-	defer span.End()
+	return telemetry.WithSpanResult(ctx, "GetShippingOrders", func(ctx context.Context) ([]model.Order, error) {
+		// 全件を読み切るまでを1つのデッドラインに収める
+		qctx, cancel := r.timeouts.planRead(ctx, "GetShippingOrders")
+		defer cancel()
 
-	var orders []model.Order
-
-	// build-query span (child)
-	_, buildSpan := tracer.Start(ctx, "build-query")
-	const defaultCandidateLimit = 2000
-	query := `
+		const defaultCandidateLimit = 2000
+		query := `
 		SELECT
 			o.order_id,
 			p.weight,
-			p.value
+			p.value,
+			p.currency` + r.publicIDColumn() + `
 		FROM orders o
 		JOIN products p ON o.product_id = p.product_id
 		WHERE o.shipped_status = 'shipping'
-		ORDER BY (p.value / NULLIF(p.weight, 0)) DESC
+		ORDER BY ` + r.dialect.OrderNullsLast("(p.value * 1.0 / NULLIF(p.weight, 0))", "DESC") + `
 		LIMIT ?
 	`
-	buildSpan.SetAttributes(attribute.String("db.statement_snippet", "SELECT o.order_id, p.weight, p.value FROM orders JOIN products WHERE shipped_status = 'shipping' ORDER BY (p.value/p.weight) DESC LIMIT ?"))
-	buildSpan.End()
-
-	// db select span (child) - the otelsql instrumentation will produce its own `sql.rows` span,
-	// but create an explicit parent child to make the hierarchy clear
-	selCtx, selSpan := tracer.Start(ctx, "db.select")
-
-	// Use QueryContext + manual rows.Scan loop so we can trace per-row scanning.
-	rows, err := r.db.QueryxContext(selCtx, query, defaultCandidateLimit)
-	if err != nil {
-		selSpan.RecordError(err)
-		selSpan.SetStatus(codes.Error, err.Error())
-		selSpan.End()
-		span.RecordError(err)
-		span.SetStatus(codes.Error, err.Error())
-		return nil, err
-	}
-	defer rows.Close()
 
-	// scan-loop span (child of db.select)
-	_, scanLoopSpan := tracer.Start(selCtx, "scan-loop")
-	count := 0
-	// collect a small sample of order IDs to record as attribute (avoid per-row spans)
-	var sampleIDs []int64
-	for rows.Next() {
-		var o model.Order
-		if err := rows.Scan(&o.OrderID, &o.Weight, &o.Value); err != nil {
-			scanLoopSpan.RecordError(err)
-			scanLoopSpan.SetStatus(codes.Error, err.Error())
-			scanLoopSpan.End()
-			selSpan.RecordError(err)
-			selSpan.SetStatus(codes.Error, err.Error())
-			selSpan.End()
+		var orders []model.Order
+		err := telemetry.WithSpan(qctx, "db.select", func(ctx context.Context) error {
+			span := trace.SpanFromContext(ctx)
+			if span.IsRecording() {
+				span.SetAttributes(attribute.String("db.statement_snippet", "SELECT o.order_id, p.weight, p.value, p.currency FROM orders JOIN products WHERE shipped_status = 'shipping' ORDER BY (p.value/p.weight) DESC LIMIT ?"))
+			}
+			rows, err := r.db.QueryxContext(ctx, query, defaultCandidateLimit)
+			if err != nil {
+				return qctx.wrap(err)
+			}
+			defer rows.Close()
+
+			// 行ごとのスパンは作らず、件数と先頭数件の注文IDだけを記録する
+			err = telemetry.WithSpan(ctx, "scan-loop", func(ctx context.Context) error {
+				span := trace.SpanFromContext(ctx)
+				var sampleIDs []string
+				for rows.Next() {
+					var o model.Order
+					dest := []interface{}{&o.OrderID, &o.Weight, &o.Value, &o.Currency}
+					var publicID sql.NullString
+					if r.schema.OrderPublicID {
+						dest = append(dest, &publicID)
+					}
+					if err := rows.Scan(dest...); err != nil {
+						return qctx.wrap(err)
+					}
+					o.PublicID = publicID.String
+					orders = append(orders, o)
+					if len(sampleIDs) < 5 && span.IsRecording() {
+						sampleIDs = append(sampleIDs, strconv.FormatInt(o.OrderID, 10))
+					}
+				}
+				if err := rows.Err(); err != nil {
+					return qctx.wrap(err)
+				}
+				if !span.IsRecording() {
+					return nil
+				}
+				span.SetAttributes(attribute.Int("orders.fetched", len(orders)))
+				if len(sampleIDs) > 0 {
+					span.SetAttributes(attribute.String("orders.sample_ids", strings.Join(sampleIDs, ",")))
+				}
+				return nil
+			})
+			if span.IsRecording() {
+				span.SetAttributes(attribute.Int("orders.fetched", len(orders)))
+			}
+			return err
+		})
+		if err != nil {
 			return nil, err
 		}
-		orders = append(orders, o)
-		if len(sampleIDs) < 5 {
-			sampleIDs = append(sampleIDs, o.OrderID)
-		}
-		count++
-	}
-	if err := rows.Err(); err != nil {
-		scanLoopSpan.RecordError(err)
-		scanLoopSpan.SetStatus(codes.Error, err.Error())
-		scanLoopSpan.End()
-		selSpan.RecordError(err)
-		selSpan.SetStatus(codes.Error, err.Error())
-		selSpan.End()
-		return nil, err
-	}
-	// record count and a small sample of order IDs for debugging without creating many spans
-	scanLoopSpan.SetAttributes(attribute.Int("orders.fetched", count))
-	if len(sampleIDs) > 0 {
-		// build comma separated string
-		var sids []string
-		for _, id := range sampleIDs {
-			sids = append(sids, strconv.FormatInt(id, 10))
+		if err := r.ensurePublicIDs(ctx, orders); err != nil {
+			return nil, err
 		}
-		scanLoopSpan.SetAttributes(attribute.String("orders.sample_ids", strings.Join(sids, ",")))
-	}
-	scanLoopSpan.End()
-
-	selSpan.SetAttributes(attribute.Int("orders.fetched", len(orders)))
-	selSpan.End()
-
-	// process-rows span (grandchild)
-	_, procSpan := tracer.Start(ctx, "process-rows")
-	procSpan.SetAttributes(attribute.Int("orders.count", len(orders)))
-	// minimal processing here; heavy processing should be traced where it happens
-	procSpan.End()
-
-	return orders, nil
+		return orders, nil
+	})
 }
 
-// 許可されたソートフィールドのホワイトリスト
-var allowedOrderSortFields = map[string]bool{
-	"order_id":       true,
-	"product_name":   true,
-	"created_at":     true,
-	"shipped_status": true,
-	"arrived_at":     true,
+// 注文一覧で許可するソート
+// 未配達の注文は arrived_at が NULL のため、ソート順によらず末尾に並べる
+var orderSort = querybuilder.Sort{
+	Fields: querybuilder.Fields{
+		"order_id":       {Column: "o.order_id"},
+		"product_name":   {Column: "p.name"},
+		"created_at":     {Column: "o.created_at"},
+		"shipped_status": {Column: "o.shipped_status"},
+		"arrived_at":     {Column: "o.arrived_at", NullsLast: true},
+	},
+	Tiebreak: "o.order_id",
 }
 
-// 許可されたソート順のホワイトリスト
-var allowedOrderSortOrders = map[string]bool{
-	"ASC":  true,
-	"DESC": true,
-	"asc":  true,
-	"desc": true,
-}
-
-// 注文の総件数を取得
-func (r *OrderRepository) CountOrders(ctx context.Context, userID int, req model.ListRequest) (int, error) {
-	// WHERE句の構築
-	whereClause := "WHERE o.user_id = ?"
-	whereArgs := []interface{}{userID}
+// 注文一覧・件数取得の共通の WHERE 句と名前付きパラメータを構築
+func buildOrderWhere(d Dialect, userID int, req model.ListRequest) (string, map[string]interface{}) {
+	whereClause := "WHERE o.user_id = :user_id"
+	params := map[string]interface{}{"user_id": userID}
 
 	// 検索条件の追加
 	if req.Search != "" {
+		whereClause += " AND p.name " + d.Like() + " :search"
 		if req.Type == "prefix" {
-			whereClause += " AND p.name LIKE ?"
-			whereArgs = append(whereArgs, req.Search+"%")
+			params["search"] = req.Search + "%"
 		} else {
 			// partial (デフォルト)
-			whereClause += " AND p.name LIKE ?"
-			whereArgs = append(whereArgs, "%"+req.Search+"%")
+			params["search"] = "%" + req.Search + "%"
 		}
 	}
+	return whereClause, params
+}
+
+// 注文の総件数を取得
+func (r *OrderRepository) CountOrders(ctx context.Context, userID int, req model.ListRequest) (int, error) {
+	whereClause, params := buildOrderWhere(r.dialect, userID, req)
+
+	qctx, cancel := r.timeouts.listRead(ctx, "CountOrders")
+	defer cancel()
 
 	var count int
 	var err error
 	if req.Search == "" {
 		// 検索条件が無ければ JOIN は不要なので orders のみでカウントして高速化
 		countQuery := "SELECT COUNT(*) FROM orders WHERE user_id = ?"
-		err = r.db.GetContext(ctx, &count, countQuery, userID)
+		err = r.db.GetContext(qctx, &count, countQuery, userID)
 	} else {
 		// 検索がある場合は product に対する条件があるため JOIN が必要
 		countQuery := fmt.Sprintf(`
@@ -238,10 +422,20 @@ func (r *OrderRepository) CountOrders(ctx context.Context, userID int, req model
 			JOIN products p ON o.product_id = p.product_id
 			%s
 		`, whereClause)
-		err = r.db.GetContext(ctx, &count, countQuery, whereArgs...)
+		var rows *sqlx.Rows
+		rows, err = r.db.NamedQueryContext(qctx, countQuery, params)
+		if err == nil {
+			defer rows.Close()
+			if rows.Next() {
+				err = rows.Scan(&count)
+			}
+			if err == nil {
+				err = rows.Err()
+			}
+		}
 	}
 	if err != nil {
-		return 0, fmt.Errorf("failed to get count: %w", err)
+		return 0, fmt.Errorf("failed to get count: %w", qctx.wrap(err))
 	}
 
 	return count, nil
@@ -250,106 +444,170 @@ func (r *OrderRepository) CountOrders(ctx context.Context, userID int, req model
 // 注文履歴一覧を取得
 // データベース側でJOIN、フィルタリング、ソート、ページングを実行
 func (r *OrderRepository) ListOrders(ctx context.Context, userID int, req model.ListRequest) ([]model.Order, error) {
-	// ソートフィールドとソート順の検証
-	sortField := req.SortField
-	if !allowedOrderSortFields[sortField] {
-		sortField = "order_id"
-	}
-	sortOrder := strings.ToUpper(req.SortOrder)
-	if !allowedOrderSortOrders[sortOrder] {
-		sortOrder = "DESC"
+	whereClause, params := buildOrderWhere(r.dialect, userID, req)
+	limitClause := querybuilder.NamedLimitOffset(params, req.PageSize, req.Offset)
+	selectQuery, err := r.orderSelectQuery(req, whereClause, limitClause)
+	if err != nil {
+		return nil, err
 	}
 
-	// WHERE句の構築
-	whereClause := "WHERE o.user_id = ?"
-	whereArgs := []interface{}{userID}
+	qctx, cancel := r.timeouts.listRead(ctx, "ListOrders")
+	defer cancel()
+	rows, err := r.db.NamedQueryContext(qctx, selectQuery, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select orders: %w", qctx.wrap(err))
+	}
+	defer rows.Close()
 
-	// 検索条件の追加
-	if req.Search != "" {
-		if req.Type == "prefix" {
-			whereClause += " AND p.name LIKE ?"
-			whereArgs = append(whereArgs, req.Search+"%")
-		} else {
-			// partial (デフォルト)
-			whereClause += " AND p.name LIKE ?"
-			whereArgs = append(whereArgs, "%"+req.Search+"%")
+	var ordersRaw []orderRow
+	for rows.Next() {
+		var o orderRow
+		if err := rows.StructScan(&o); err != nil {
+			return nil, fmt.Errorf("failed to scan orders: %w", qctx.wrap(err))
 		}
+		ordersRaw = append(ordersRaw, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to select orders: %w", qctx.wrap(err))
 	}
 
-	// ORDER BY句の構築
-	orderByClause := "ORDER BY "
-	switch sortField {
-	case "product_name":
-		orderByClause += fmt.Sprintf("p.name %s, o.order_id ASC", sortOrder)
-	case "created_at":
-		orderByClause += fmt.Sprintf("o.created_at %s, o.order_id ASC", sortOrder)
-	case "shipped_status":
-		orderByClause += fmt.Sprintf("o.shipped_status %s, o.order_id ASC", sortOrder)
-	case "arrived_at":
-		// NULL値の処理: NULLは最後に配置（MySQL互換性のためISNULL()を使用）
-		if sortOrder == "DESC" {
-			orderByClause += "ISNULL(o.arrived_at) ASC, o.arrived_at DESC, o.order_id ASC"
-		} else {
-			orderByClause += "ISNULL(o.arrived_at) ASC, o.arrived_at ASC, o.order_id ASC"
+	// orderRowからOrderに変換
+	orders := make([]model.Order, len(ordersRaw))
+	for i, o := range ordersRaw {
+		orders[i] = o.toModel()
+	}
+	if err := r.ensurePublicIDs(ctx, orders); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// StreamOrders は検索条件に一致する注文をページングせずに1行ずつ fn に渡す（エクスポート用）
+// 結果をメモリに溜めないよう、カーソルから読んだ行をそのまま渡す。fn がエラーを返すとその時点で中断する
+// クエリ単位のタイムアウトは設けないため、期限は ctx で指定すること
+func (r *OrderRepository) StreamOrders(ctx context.Context, userID int, req model.ListRequest, fn func(*model.Order) error) error {
+	whereClause, params := buildOrderWhere(r.dialect, userID, req)
+	selectQuery, err := r.orderSelectQuery(req, whereClause, "")
+	if err != nil {
+		return err
+	}
+
+	qctx, cancel := withQueryTimeout(ctx, "StreamOrders", 0)
+	defer cancel()
+	rows, err := r.db.NamedQueryContext(qctx, selectQuery, params)
+	if err != nil {
+		return fmt.Errorf("failed to select orders: %w", qctx.wrap(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var o orderRow
+		if err := rows.StructScan(&o); err != nil {
+			return fmt.Errorf("failed to scan orders: %w", qctx.wrap(err))
+		}
+		orders := []model.Order{o.toModel()}
+		if err := r.ensurePublicIDs(ctx, orders); err != nil {
+			return err
+		}
+		if err := fn(&orders[0]); err != nil {
+			return err
 		}
-	case "order_id":
-		fallthrough
-	default:
-		orderByClause += fmt.Sprintf("o.order_id %s", sortOrder)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to select orders: %w", qctx.wrap(err))
+	}
+	return nil
+}
+
+// 注文一覧の SELECT 文（limitClause が空の場合は全件）
+// JOINを使って商品名を一度に取得（N+1クエリ問題を解決）
+// ソートが許可されていない値の場合は ErrInvalidSort を返す
+func (r *OrderRepository) orderSelectQuery(req model.ListRequest, whereClause, limitClause string) (string, error) {
+	order, err := orderSort.Validate(req.SortField, req.SortOrder)
+	if err != nil {
+		return "", err
 	}
 
-	// ページングされた注文を取得するクエリ
-	// JOINを使って商品名を一度に取得（N+1クエリ問題を解決）
-	selectQuery := fmt.Sprintf(`
+	return fmt.Sprintf(`
 		SELECT 
 			o.order_id,
 			o.product_id,
 			p.name AS product_name,
 			o.shipped_status,
 			o.created_at,
-			o.arrived_at
+			o.arrived_at%s
 		FROM orders o
 		JOIN products p ON o.product_id = p.product_id
 		%s
 		%s
-		LIMIT ? OFFSET ?
-	`, whereClause, orderByClause)
+		%s
+	`, r.optionalColumns(), whereClause, orderSort.OrderBy(order, r.dialect), limitClause), nil
+}
 
-	// SELECTクエリ用の引数（WHERE句の引数 + LIMIT + OFFSET）
-	selectArgs := make([]interface{}, len(whereArgs))
-	copy(selectArgs, whereArgs)
-	selectArgs = append(selectArgs, req.PageSize, req.Offset)
+type orderRow struct {
+	OrderID       int64               `db:"order_id"`
+	ProductID     int                 `db:"product_id"`
+	ProductName   string              `db:"product_name"`
+	ShippedStatus model.ShippedStatus `db:"shipped_status"`
+	CreatedAt     sql.NullTime        `db:"created_at"`
+	ArrivedAt     sql.NullTime        `db:"arrived_at"`
+	UpdatedAt     sql.NullTime        `db:"updated_at"`
+	PublicID      sql.NullString      `db:"public_id"`
+}
 
-	type orderRow struct {
-		OrderID       int64        `db:"order_id"`
-		ProductID     int          `db:"product_id"`
-		ProductName   string       `db:"product_name"`
-		ShippedStatus string       `db:"shipped_status"`
-		CreatedAt     sql.NullTime `db:"created_at"`
-		ArrivedAt     sql.NullTime `db:"arrived_at"`
+func (o orderRow) toModel() model.Order {
+	order := model.Order{
+		OrderID:       o.OrderID,
+		ProductID:     o.ProductID,
+		ProductName:   o.ProductName,
+		ShippedStatus: o.ShippedStatus,
+		CreatedAt:     o.CreatedAt.Time,
+		ArrivedAt:     o.ArrivedAt,
+		PublicID:      o.PublicID.String,
+	}
+	if o.UpdatedAt.Valid {
+		order.UpdatedAt = &o.UpdatedAt.Time
 	}
+	return order
+}
 
-	var ordersRaw []orderRow
-	err := r.db.SelectContext(ctx, &ordersRaw, selectQuery, selectArgs...)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return []model.Order{}, nil
-		}
-		return nil, fmt.Errorf("failed to select orders: %w", err)
+// 配送計画の候補で取得する公開IDの列（マイグレーション適用前のスキーマでは取得しない）
+func (r *OrderRepository) publicIDColumn() string {
+	if r.schema.OrderPublicID {
+		return ", o.public_id"
 	}
+	return ""
+}
 
-	// orderRowからOrderに変換
-	orders := make([]model.Order, len(ordersRaw))
-	for i, o := range ordersRaw {
-		orders[i] = model.Order{
-			OrderID:       o.OrderID,
-			ProductID:     o.ProductID,
-			ProductName:   o.ProductName,
-			ShippedStatus: o.ShippedStatus,
-			CreatedAt:     o.CreatedAt.Time,
-			ArrivedAt:     o.ArrivedAt,
-		}
+// マイグレーション適用前のスキーマでは updated_at・public_id を取得しない
+func (r *OrderRepository) optionalColumns() string {
+	var columns string
+	if r.schema.OrderUpdatedAt {
+		columns += ", o.updated_at"
+	}
+	if r.schema.OrderPublicID {
+		columns += ", o.public_id"
 	}
+	return columns
+}
 
-	return orders, nil
+// ユーザーの注文の最終更新日時と件数を取得（注文一覧の ETag 計算用）
+// updated_at が無いスキーマでは作成日時で代用する（状態の変更は検知できない）
+func (r *OrderRepository) LastModified(ctx context.Context, userID int) (time.Time, int, error) {
+	column := "created_at"
+	if r.schema.OrderUpdatedAt {
+		column = "updated_at"
+	}
+	var row struct {
+		UpdatedAt aggregateTime `db:"updated_at"`
+		Count     int           `db:"cnt"`
+	}
+	qctx, cancel := r.timeouts.pointRead(ctx, "OrdersLastModified")
+	defer cancel()
+	query := "SELECT MAX(" + column + ") AS updated_at, COUNT(*) AS cnt FROM orders WHERE user_id = ?"
+	if err := r.db.GetContext(qctx, &row, query, userID); err != nil {
+		return time.Time{}, 0, qctx.wrap(err)
+	}
+	return row.UpdatedAt.Time, row.Count, nil
 }
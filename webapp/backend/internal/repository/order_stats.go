@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// 運用ダッシュボード向けの注文の集計
+
+// 状態ごとの注文数
+func (r *OrderRepository) CountByStatus(ctx context.Context) ([]model.StatusCount, error) {
+	qctx, cancel := r.timeouts.listRead(ctx, "CountOrdersByStatus")
+	defer cancel()
+	counts := []model.StatusCount{}
+	query := "SELECT shipped_status, COUNT(*) AS cnt FROM orders GROUP BY shipped_status ORDER BY shipped_status"
+	if err := r.db.SelectContext(qctx, &counts, query); err != nil {
+		return nil, qctx.wrap(err)
+	}
+	return counts, nil
+}
+
+// since 以降に配送中・配送完了へ更新された注文数
+// updated_at が無いスキーマでは数えられないため ok=false を返す
+func (r *OrderRepository) CountClaimedSince(ctx context.Context, since time.Time) (n int, ok bool, err error) {
+	if !r.schema.OrderUpdatedAt {
+		return 0, false, nil
+	}
+	qctx, cancel := r.timeouts.listRead(ctx, "CountClaimedSince")
+	defer cancel()
+	query := "SELECT COUNT(*) FROM orders WHERE shipped_status IN ('delivering', 'completed') AND updated_at >= ?"
+	if err := r.db.GetContext(qctx, &n, query, since.UTC()); err != nil {
+		return 0, false, qctx.wrap(err)
+	}
+	return n, true, nil
+}
+
+// cutoff より前から配送中のまま更新されていない注文のIDを、古い順に最大 limit 件取得して行をロックする（トランザクション内で使用）
+// updated_at が無いスキーマでは配送中になった時刻がわからないため ok=false を返す
+func (r *OrderRepository) FindStuckDelivering(ctx context.Context, cutoff time.Time, limit int) (ids []int64, ok bool, err error) {
+	if !r.schema.OrderUpdatedAt {
+		return nil, false, nil
+	}
+	qctx, cancel := r.timeouts.listRead(ctx, "FindStuckDelivering")
+	defer cancel()
+	ids = []int64{}
+	query := "SELECT order_id FROM orders WHERE shipped_status = 'delivering' AND updated_at < ? ORDER BY updated_at LIMIT ?" + r.dialect.ForUpdate()
+	if err := r.db.SelectContext(qctx, &ids, query, cutoff.UTC(), limit); err != nil {
+		return nil, false, qctx.wrap(err)
+	}
+	return ids, true, nil
+}
+
+// 配送完了までの平均時間と対象の注文数（arrived_at のある完了済みの注文のみ）
+// 対象が無い場合は平均を 0 で返す
+func (r *OrderRepository) AverageTimeToDelivery(ctx context.Context) (time.Duration, int, error) {
+	qctx, cancel := r.timeouts.listRead(ctx, "AverageTimeToDelivery")
+	defer cancel()
+	var row struct {
+		AvgSeconds sql.NullFloat64 `db:"avg_seconds"`
+		Count      int             `db:"cnt"`
+	}
+	query := "SELECT AVG(" + r.dialect.SecondsBetween("created_at", "arrived_at") + ") AS avg_seconds, COUNT(*) AS cnt" +
+		" FROM orders WHERE shipped_status = 'completed' AND arrived_at IS NOT NULL"
+	if err := r.db.GetContext(qctx, &row, query); err != nil {
+		return 0, 0, qctx.wrap(err)
+	}
+	return time.Duration(row.AvgSeconds.Float64 * float64(time.Second)), row.Count, nil
+}
+
+// [from, to) に作成された注文の多い商品を最大 limit 件（同数の場合は商品ID順）
+func (r *OrderRepository) TopProducts(ctx context.Context, from, to time.Time, limit int) ([]model.ProductSales, error) {
+	qctx, cancel := r.timeouts.listRead(ctx, "TopProducts")
+	defer cancel()
+	products := []model.ProductSales{}
+	query := "SELECT p.product_id, p.name, p.currency, COUNT(*) AS orders, SUM(p.value) AS total_value" +
+		" FROM orders o JOIN products p ON p.product_id = o.product_id" +
+		" WHERE o.created_at >= ? AND o.created_at < ?" +
+		" GROUP BY p.product_id, p.name, p.currency ORDER BY orders DESC, p.product_id LIMIT ?"
+	// 集計（AggregateHours）と同じく、SQLite でも境界の注文を取りこぼさないよう文字列で渡す
+	if err := r.db.SelectContext(qctx, &products, query, from.UTC().Format(bucketLayout), to.UTC().Format(bucketLayout), limit); err != nil {
+		return nil, qctx.wrap(err)
+	}
+	return products, nil
+}
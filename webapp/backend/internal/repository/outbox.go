@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// last_error に保存するエラーメッセージの最大長
+const maxOutboxErrorLength = 255
+
+type OutboxRepository struct {
+	db       DBTX
+	schema   *SchemaCapabilities
+	timeouts *QueryTimeouts
+	// リレーが動いていない場合は記録しない（配信されない行が溜まり続けないようにする）
+	enabled bool
+}
+
+func NewOutboxRepository(db DBTX, schema *SchemaCapabilities, timeouts *QueryTimeouts) *OutboxRepository {
+	return &OutboxRepository{db: db, schema: schema, timeouts: timeouts}
+}
+
+// Add はイベントを記録する。注文を変更するトランザクション内の Store から呼ぶこと
+// payload はJSONに変換して保存する
+func (r *OutboxRepository) Add(ctx context.Context, eventType string, aggregateID int64, payload interface{}) error {
+	if !r.enabled {
+		return nil
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO outbox (event_type, aggregate_id, payload, created_at) VALUES (?, ?, ?, ?)`
+	qctx, cancel := r.timeouts.write(ctx, "AddOutboxEvent")
+	defer cancel()
+	_, err = r.db.ExecContext(qctx, query, eventType, aggregateID, string(body), time.Now().UTC())
+	return qctx.wrap(err)
+}
+
+// AcquireRelayLease はリレーの実行権（リース）を owner として取得または延長し、owner が持っているかを返す
+// 他のサーバーが期限内のリースを持っている場合は false を返す
+// outbox_relay_lease テーブルが無い場合は常に true を返す（1台構成を前提とする）
+// 期限はサーバーの時計で判定するため、lease はサーバー間の時計のずれより十分長くすること
+func (r *OutboxRepository) AcquireRelayLease(ctx context.Context, owner string, lease time.Duration) (bool, error) {
+	if !r.schema.OutboxRelayLease {
+		return true, nil
+	}
+	now := time.Now().UTC()
+	query := "UPDATE outbox_relay_lease SET owner = ?, expires_at = ? WHERE lease_id = 1 AND (owner = ? OR expires_at < ?)"
+	qctx, cancel := r.timeouts.write(ctx, "AcquireRelayLease")
+	defer cancel()
+	if _, err := r.db.ExecContext(qctx, query, owner, now.Add(lease), owner, now); err != nil {
+		return false, qctx.wrap(err)
+	}
+	// affected rows は MySQL では値が変わらない場合に0となるため、持ち主を読み直して判定する
+	var current string
+	rctx, cancel := r.timeouts.pointRead(ctx, "RelayLeaseOwner")
+	defer cancel()
+	if err := r.db.GetContext(rctx, &current, "SELECT owner FROM outbox_relay_lease WHERE lease_id = 1"); err != nil {
+		return false, rctx.wrap(err)
+	}
+	return current == owner, nil
+}
+
+// ReleaseRelayLease は owner が持っているリースを手放し、他のサーバーがすぐに引き継げるようにする
+func (r *OutboxRepository) ReleaseRelayLease(ctx context.Context, owner string) error {
+	if !r.schema.OutboxRelayLease {
+		return nil
+	}
+	qctx, cancel := r.timeouts.write(ctx, "ReleaseRelayLease")
+	defer cancel()
+	_, err := r.db.ExecContext(qctx, "UPDATE outbox_relay_lease SET owner = '', expires_at = ? WHERE lease_id = 1 AND owner = ?", time.Unix(0, 0).UTC(), owner)
+	return qctx.wrap(err)
+}
+
+// 未配信のイベントを記録順に取得
+// 複数台で配信しないよう、AcquireRelayLease でリースを持っている間だけ呼ぶこと
+// 集約はすべて注文のため、AggregateKey には注文の公開IDを読む（公開IDが無い場合は空）
+func (r *OutboxRepository) ListPending(ctx context.Context, limit int) ([]model.OutboxEvent, error) {
+	events := []model.OutboxEvent{}
+	aggregateKey := "''"
+	join := ""
+	if r.schema.OrderPublicID {
+		aggregateKey = "COALESCE(o.public_id, '')"
+		join = "LEFT JOIN orders o ON o.order_id = e.aggregate_id"
+	}
+	query := `
+		SELECT e.event_id, e.event_type, e.aggregate_id, ` + aggregateKey + ` AS aggregate_key, e.payload, e.created_at, e.attempts
+		FROM outbox e ` + join + `
+		WHERE e.published_at IS NULL
+		ORDER BY e.event_id ASC
+		LIMIT ?`
+	qctx, cancel := r.timeouts.listRead(ctx, "ListPendingOutbox")
+	defer cancel()
+	if err := r.db.SelectContext(qctx, &events, query, limit); err != nil {
+		return nil, qctx.wrap(err)
+	}
+	return events, nil
+}
+
+// 配信済みにする
+func (r *OutboxRepository) MarkPublished(ctx context.Context, eventIDs []int64) error {
+	if len(eventIDs) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In("UPDATE outbox SET published_at = ? WHERE event_id IN (?)", time.Now().UTC(), eventIDs)
+	if err != nil {
+		return err
+	}
+	qctx, cancel := r.timeouts.write(ctx, "MarkOutboxPublished")
+	defer cancel()
+	_, err = r.db.ExecContext(qctx, r.db.Rebind(query), args...)
+	return qctx.wrap(err)
+}
+
+// 配信の失敗を記録する（イベントは未配信のまま残り、次回のポーリングで再送される）
+func (r *OutboxRepository) RecordFailure(ctx context.Context, eventID int64, cause error) error {
+	msg := cause.Error()
+	if len(msg) > maxOutboxErrorLength {
+		msg = msg[:maxOutboxErrorLength]
+	}
+	qctx, cancel := r.timeouts.write(ctx, "RecordOutboxFailure")
+	defer cancel()
+	_, err := r.db.ExecContext(qctx, "UPDATE outbox SET attempts = attempts + 1, last_error = ? WHERE event_id = ?", msg, eventID)
+	return qctx.wrap(err)
+}
+
+// before より前に配信済みになったイベントを最大 limit 件削除する
+func (r *OutboxRepository) PurgePublished(ctx context.Context, before time.Time, limit int) (int64, error) {
+	// MySQL は DELETE の対象テーブルをサブクエリで直接参照できないため、派生テーブルを挟む
+	query := `
+		DELETE FROM outbox WHERE event_id IN (
+			SELECT event_id FROM (
+				SELECT event_id FROM outbox WHERE published_at < ? ORDER BY event_id LIMIT ?
+			) t
+		)`
+	qctx, cancel := r.timeouts.write(ctx, "PurgeOutbox")
+	defer cancel()
+	res, err := r.db.ExecContext(qctx, query, before, limit)
+	if err != nil {
+		return 0, qctx.wrap(err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"strings"
+)
+
+type PriceHistoryRepository struct {
+	db       DBTX
+	timeouts *QueryTimeouts
+}
+
+func NewPriceHistoryRepository(db DBTX, timeouts *QueryTimeouts) *PriceHistoryRepository {
+	return &PriceHistoryRepository{db: db, timeouts: timeouts}
+}
+
+// 価値の変更を記録
+func (r *PriceHistoryRepository) Create(ctx context.Context, h *model.PriceHistory) error {
+	query := `INSERT INTO product_price_history (product_id, old_value, new_value, changed_by, reason, changed_at) VALUES (?, ?, ?, ?, ?, ?)`
+	qctx, cancel := r.timeouts.write(ctx, "CreatePriceHistory")
+	defer cancel()
+	_, err := r.db.ExecContext(qctx, query, h.ProductID, h.OldValue, h.NewValue, h.ChangedBy, h.Reason, h.ChangedAt)
+	return qctx.wrap(err)
+}
+
+// 複数の価値の変更をまとめて記録（CSVの一括登録用）
+func (r *PriceHistoryRepository) CreateBatch(ctx context.Context, history []model.PriceHistory) error {
+	if len(history) == 0 {
+		return nil
+	}
+	values := make([]string, len(history))
+	args := make([]interface{}, 0, len(history)*6)
+	for i, h := range history {
+		values[i] = "(?, ?, ?, ?, ?, ?)"
+		args = append(args, h.ProductID, h.OldValue, h.NewValue, h.ChangedBy, h.Reason, h.ChangedAt)
+	}
+	query := "INSERT INTO product_price_history (product_id, old_value, new_value, changed_by, reason, changed_at) VALUES " + strings.Join(values, ", ")
+	qctx, cancel := r.timeouts.write(ctx, "CreatePriceHistoryBatch")
+	defer cancel()
+	_, err := r.db.ExecContext(qctx, query, args...)
+	return qctx.wrap(err)
+}
+
+// 商品の価値の変更履歴を新しい順に取得
+func (r *PriceHistoryRepository) ListByProduct(ctx context.Context, productID, limit int) ([]model.PriceHistory, error) {
+	history := []model.PriceHistory{}
+	query := `
+		SELECT history_id, product_id, old_value, new_value, changed_by, reason, changed_at
+		FROM product_price_history
+		WHERE product_id = ?
+		ORDER BY changed_at DESC, history_id DESC
+		LIMIT ?`
+	qctx, cancel := r.timeouts.listRead(ctx, "ListPriceHistory")
+	defer cancel()
+	if err := r.db.SelectContext(qctx, &history, query, productID, limit); err != nil {
+		return nil, qctx.wrap(err)
+	}
+	return history, nil
+}
@@ -1,77 +1,652 @@
 package repository
 
 import (
+	"backend/internal/cache"
+	"backend/internal/cursor"
 	"backend/internal/model"
+	"backend/internal/querybuilder"
 	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
 )
 
 type ProductRepository struct {
-	db DBTX
+	db       DBTX
+	schema   *SchemaCapabilities
+	timeouts *QueryTimeouts
+	dialect  Dialect
+	// 書き込みをキャッシュへ通知する（Store が設定する）
+	changes *changeLog
+	// 一覧のカーソルの署名（Store が設定する）
+	cursors *cursor.Codec
+	// 検索語を FULLTEXT インデックスで絞り込む（Store.UseFullTextSearch で設定する）
+	fullText bool
+}
+
+func NewProductRepository(db DBTX, schema *SchemaCapabilities, timeouts *QueryTimeouts, dialect Dialect) *ProductRepository {
+	return &ProductRepository{db: db, schema: schema, timeouts: timeouts, dialect: dialect}
+}
+
+// ソートパラメータがホワイトリストに含まれない場合に返す
+var ErrInvalidSort = querybuilder.ErrInvalidSort
+
+// 商品一覧で許可するソート
+var productSort = querybuilder.Sort{
+	Fields: querybuilder.Fields{
+		"product_id": {Column: "product_id"},
+		"name":       {Column: "name"},
+		"value":      {Column: "value"},
+		"weight":     {Column: "weight"},
+	},
+	Tiebreak: "product_id",
 }
 
-func NewProductRepository(db DBTX) *ProductRepository {
-	return &ProductRepository{db: db}
+// 商品取得時に SELECT するカラム
+const productColumns = "product_id, name, value, currency, weight, image, description, category_id, rating_avg, rating_count, stock, updated_at"
+
+// 全文検索時のみ関連度順のソートを許可する
+var productSearchSort = querybuilder.Sort{
+	Fields: querybuilder.Fields{
+		"product_id": {Column: "product_id"},
+		"name":       {Column: "name"},
+		"value":      {Column: "value"},
+		"weight":     {Column: "weight"},
+		"relevance":  {Column: "score"},
+	},
+	Tiebreak: "product_id",
+}
+
+// 商品一覧・件数・ファセット・エクスポートで共通のWHERE句を構築
+func (r *ProductRepository) productWhere(req model.ListRequest) (string, []interface{}) {
+	var conds []string
+	args := []interface{}{}
+
+	if req.Search != "" {
+		cond, searchArgs := r.searchCondition(req.Search)
+		conds = append(conds, cond)
+		args = append(args, searchArgs...)
+	}
+	if req.Category > 0 {
+		conds = append(conds, "category_id = ?")
+		args = append(args, req.Category)
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// searchCondition は検索語の絞り込み条件を返す
+// FULLTEXT 検索を使う場合は一覧（SearchProducts）と同じ条件にし、ファセットや件数が一覧の結果と食い違わないようにする
+func (r *ProductRepository) searchCondition(search string) (string, []interface{}) {
+	if r.fullText && r.schema.ProductSearchText {
+		return fullTextCondition(search)
+	}
+	// OR条件を使用（LIKE '%...%'ではインデックスが使えないため、UNIONよりシンプルなORの方が速い）
+	pattern := "%" + search + "%"
+	return "(name " + r.dialect.Like() + " ? OR description " + r.dialect.Like() + " ?)", []interface{}{pattern, pattern}
+}
+
+// fullTextCondition は FULLTEXT(ngram) インデックスで検索語をフレーズとして探す条件を返す
+func fullTextCondition(search string) (string, []interface{}) {
+	return "MATCH(search_text) AGAINST(? IN BOOLEAN MODE)", []interface{}{`"` + strings.ReplaceAll(search, `"`, "") + `"`}
 }
 
 // 商品一覧を取得（SQLレベルでページング処理を行う）
 // 商品データは常にMySQLから取得（順序が重要なため）
+// req.Cursor が指定された場合は OFFSET ではなくキーセットページングで取得する
+//
+// 説明文などを含む幅の広い行をソートしないよう、2段階で取得する
+//  1. 検索条件・ソートキーのみを使って対象ページの product_id を取得（インデックスで完結しやすい）
+//  2. 取得した product_id で主キー検索して行全体を取得
 func (r *ProductRepository) ListProducts(ctx context.Context, userID int, req model.ListRequest) ([]model.Product, error) {
-	var products []model.Product
-	var baseQuery string
-	args := []interface{}{}
+	ids, err := r.listProductIDs(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetProductsByIDs(ctx, ids)
+}
 
-	if req.Search != "" {
-		// OR条件を使用（LIKE '%...%'ではインデックスが使えないため、UNIONよりシンプルなORの方が速い）
-		searchPattern := "%" + req.Search + "%"
-		baseQuery = `
-			SELECT product_id, name, value, weight, image, description
-			FROM products
-			WHERE name LIKE ? OR description LIKE ?
-		`
-		args = append(args, searchPattern, searchPattern)
-	} else {
-		baseQuery = `
-			SELECT product_id, name, value, weight, image, description
-			FROM products
-		`
-	}
-
-	baseQuery += " ORDER BY " + req.SortField + " " + req.SortOrder + " , product_id ASC"
-	baseQuery += " LIMIT ? OFFSET ?"
-	args = append(args, req.PageSize, req.Offset)
-
-	err := r.db.SelectContext(ctx, &products, baseQuery, args...)
+// 商品一覧の対象ページの product_id をソート順に取得
+func (r *ProductRepository) listProductIDs(ctx context.Context, req model.ListRequest) ([]int, error) {
+	ids := []int{}
+
+	// 関連度スコアは全文検索時にしか存在しないため、商品ID順で代替する
+	if req.SortField == "relevance" {
+		req.SortField = "product_id"
+	}
+	order, err := productSort.Validate(req.SortField, req.SortOrder)
 	if err != nil {
 		return nil, err
 	}
 
-	return products, nil
+	whereClause, args := r.productWhere(req)
+	offset := req.Offset
+	if req.Cursor != "" {
+		cursor, err := r.decodeCursor(req, order.Field, order.Dir)
+		if err != nil {
+			return nil, err
+		}
+		cond, cursorArgs := cursor.whereClause()
+		if whereClause == "" {
+			whereClause = " WHERE " + cond
+		} else {
+			whereClause += " AND " + cond
+		}
+		args = append(args, cursorArgs...)
+		offset = 0
+	}
+
+	limitClause, limitArgs := querybuilder.LimitOffset(req.PageSize, offset)
+	baseQuery := "SELECT product_id FROM products" + whereClause + productSort.OrderBy(order, r.dialect) + limitClause
+	args = append(args, limitArgs...)
+
+	qctx, cancel := r.timeouts.listRead(ctx, "ListProductIDs")
+	defer cancel()
+	err = r.db.SelectContext(qctx, &ids, baseQuery, args...)
+	if err != nil {
+		return nil, qctx.wrap(err)
+	}
+
+	return ids, nil
+}
+
+// StreamProducts は検索条件に一致する商品をページングせずにソート順で1行ずつ fn に渡す（エクスポート用）
+// キーセットのカーソルと検索エンジンは使わず、LIKE 検索で絞り込む。fn がエラーを返すとその時点で中断する
+// クエリ単位のタイムアウトは設けないため、期限は ctx で指定すること
+func (r *ProductRepository) StreamProducts(ctx context.Context, req model.ListRequest, fn func(*model.Product) error) error {
+	if req.SortField == "relevance" {
+		req.SortField = "product_id"
+	}
+	order, err := productSort.Validate(req.SortField, req.SortOrder)
+	if err != nil {
+		return err
+	}
+	whereClause, args := r.productWhere(req)
+	query := "SELECT " + productColumns + " FROM products" + whereClause + productSort.OrderBy(order, r.dialect)
+
+	qctx, cancel := withQueryTimeout(ctx, "StreamProducts", 0)
+	defer cancel()
+	rows, err := r.db.QueryxContext(qctx, query, args...)
+	if err != nil {
+		return qctx.wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p model.Product
+		if err := rows.StructScan(&p); err != nil {
+			return qctx.wrap(err)
+		}
+		if err := fn(&p); err != nil {
+			return err
+		}
+	}
+	return qctx.wrap(rows.Err())
+}
+
+// ファセットのバケット幅
+const (
+	weightFacetBucket = 10
+	valueFacetBucket  = 10
+)
+
+// 検索条件に一致する商品のカテゴリ別・重さ帯別・価値帯別の件数を1回のクエリで取得する
+// 未分類の商品はカテゴリ 0 として数える
+func (r *ProductRepository) CountFacets(ctx context.Context, req model.ListRequest) (*model.Facets, error) {
+	whereClause, whereArgs := r.productWhere(req)
+
+	query := `
+		SELECT 'category' AS facet, COALESCE(category_id, 0) AS bucket, COUNT(*) AS cnt
+		FROM products` + whereClause + `
+		GROUP BY bucket
+		UNION ALL
+		SELECT 'weight' AS facet, ` + r.dialect.IntDiv("weight", "?") + ` * ? AS bucket, COUNT(*) AS cnt
+		FROM products` + whereClause + `
+		GROUP BY bucket
+		UNION ALL
+		SELECT 'value' AS facet, ` + r.dialect.IntDiv("value", "?") + ` * ? AS bucket, COUNT(*) AS cnt
+		FROM products` + whereClause + `
+		GROUP BY bucket
+		ORDER BY facet, bucket`
+	args := append([]interface{}{}, whereArgs...)
+	args = append(args, weightFacetBucket, weightFacetBucket)
+	args = append(args, whereArgs...)
+	args = append(args, valueFacetBucket, valueFacetBucket)
+	args = append(args, whereArgs...)
+
+	var rows []struct {
+		Facet  string `db:"facet"`
+		Bucket int    `db:"bucket"`
+		Count  int    `db:"cnt"`
+	}
+	qctx, cancel := r.timeouts.listRead(ctx, "CountFacets")
+	defer cancel()
+	if err := r.db.SelectContext(qctx, &rows, query, args...); err != nil {
+		return nil, qctx.wrap(err)
+	}
+
+	facets := &model.Facets{
+		Category: []model.FacetCount{},
+		Weight:   []model.FacetCount{},
+		Value:    []model.FacetCount{},
+	}
+	for _, row := range rows {
+		switch row.Facet {
+		case "category":
+			facets.Category = append(facets.Category, model.FacetCount{Key: row.Bucket, Count: row.Count})
+		case "weight":
+			facets.Weight = append(facets.Weight, model.FacetCount{Key: row.Bucket, Max: row.Bucket + weightFacetBucket - 1, Count: row.Count})
+		case "value":
+			facets.Value = append(facets.Value, model.FacetCount{Key: row.Bucket, Max: row.Bucket + valueFacetBucket - 1, Count: row.Count})
+		}
+	}
+	return facets, nil
 }
 
 // 商品の総件数を取得
 func (r *ProductRepository) CountProducts(ctx context.Context, userID int, req model.ListRequest) (int, error) {
 	var count int
-	var baseQuery string
-	args := []interface{}{}
 
-	if req.Search != "" {
-		// OR条件を使用（LIKE '%...%'ではインデックスが使えないため、UNIONよりシンプルなORの方が速い）
-		// DISTINCTで重複を排除して正確な件数を取得
-		searchPattern := "%" + req.Search + "%"
-		baseQuery = `
-			SELECT COUNT(DISTINCT product_id) 
-			FROM products
-			WHERE name LIKE ? OR description LIKE ?
-		`
-		args = append(args, searchPattern, searchPattern)
-	} else {
-		baseQuery = "SELECT COUNT(*) FROM products"
+	whereClause, args := r.productWhere(req)
+	baseQuery := "SELECT COUNT(*) FROM products" + whereClause
+
+	qctx, cancel := r.timeouts.listRead(ctx, "CountProducts")
+	defer cancel()
+	err := r.db.GetContext(qctx, &count, baseQuery, args...)
+	if err != nil {
+		return 0, qctx.wrap(err)
+	}
+
+	return count, nil
+}
+
+// 検索用テキストを組み立てる（products.search_text に保存）
+func buildSearchText(name, description string) string {
+	return name + " " + description
+}
+
+// 商品を1件取得
+func (r *ProductRepository) GetProduct(ctx context.Context, productID int) (*model.Product, error) {
+	var product model.Product
+	query := "SELECT " + productColumns + " FROM products WHERE product_id = ?"
+	qctx, cancel := r.timeouts.pointRead(ctx, "GetProduct")
+	defer cancel()
+	if err := r.db.GetContext(qctx, &product, query, productID); err != nil {
+		return nil, qctx.wrap(err)
+	}
+	return &product, nil
+}
+
+// search_text のマイグレーションが未適用の状態で全文検索を行った場合に返す
+var ErrSearchTextUnavailable = errors.New("products.search_text is not available")
+
+// 全文検索の結果（商品IDと関連度スコア）
+type SearchHit struct {
+	ProductID int     `db:"product_id"`
+	Score     float64 `db:"score"`
+}
+
+// FULLTEXT(ngram)インデックスを使って商品を検索し、総件数と合わせて返す
+// 検索語はフレーズとして扱い、部分一致検索に近い結果を得る
+// sort_field に relevance を指定した場合は関連度スコア順に並べる
+func (r *ProductRepository) SearchProducts(ctx context.Context, req model.ListRequest) ([]SearchHit, int, error) {
+	if !r.schema.ProductSearchText {
+		return nil, 0, ErrSearchTextUnavailable
+	}
+	order, err := productSearchSort.Validate(req.SortField, req.SortOrder)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cond, condArgs := fullTextCondition(req.Search)
+	whereClause := " WHERE " + cond
+	whereArgs := append([]interface{}{}, condArgs...)
+	if req.Category > 0 {
+		whereClause += " AND category_id = ?"
+		whereArgs = append(whereArgs, req.Category)
 	}
 
-	err := r.db.GetContext(ctx, &count, baseQuery, args...)
+	qctx, cancel := r.timeouts.listRead(ctx, "SearchProducts")
+	defer cancel()
+
+	var total int
+	if err := r.db.GetContext(qctx, &total, "SELECT COUNT(*) FROM products"+whereClause, whereArgs...); err != nil {
+		return nil, 0, qctx.wrap(err)
+	}
+
+	hits := []SearchHit{}
+	limitClause, limitArgs := querybuilder.LimitOffset(req.PageSize, req.Offset)
+	query := "SELECT product_id, " + cond + " AS score FROM products" + whereClause +
+		productSearchSort.OrderBy(order, r.dialect) + limitClause
+	// スコアの式にも同じ検索語を渡す
+	args := append(append([]interface{}{}, condArgs...), whereArgs...)
+	args = append(args, limitArgs...)
+	if err := r.db.SelectContext(qctx, &hits, query, args...); err != nil {
+		return nil, 0, qctx.wrap(err)
+	}
+	return hits, total, nil
+}
+
+// 商品IDの順序を保ったまま商品を取得
+func (r *ProductRepository) GetProductsByIDs(ctx context.Context, ids []int) ([]model.Product, error) {
+	if len(ids) == 0 {
+		return []model.Product{}, nil
+	}
+	query, args, err := sqlx.In("SELECT "+productColumns+" FROM products WHERE product_id IN (?)", ids)
+	if err != nil {
+		return nil, err
+	}
+	qctx, cancel := r.timeouts.listRead(ctx, "GetProductsByIDs")
+	defer cancel()
+	var rows []model.Product
+	if err := r.db.SelectContext(qctx, &rows, r.db.Rebind(query), args...); err != nil {
+		return nil, qctx.wrap(err)
+	}
+
+	byID := make(map[int]model.Product, len(rows))
+	for _, p := range rows {
+		byID[p.ProductID] = p
+	}
+	products := make([]model.Product, 0, len(ids))
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			products = append(products, p)
+		}
+	}
+	return products, nil
+}
+
+// LIKE パターン中のワイルドカードをエスケープ
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// 前方一致で商品名の候補を取得（入力補完用）
+// idx_products_name を使った範囲検索になるよう、前方一致のみを許可する
+func (r *ProductRepository) SuggestProductNames(ctx context.Context, prefix string, limit int) ([]string, error) {
+	names := []string{}
+	query := "SELECT DISTINCT name FROM products WHERE name LIKE ?" + r.dialect.LikeEscape() + " ORDER BY name ASC LIMIT ?"
+	if err := r.db.SelectContext(ctx, &names, query, likeEscaper.Replace(prefix)+"%", limit); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// 商品の画像パスのみを取得（画像配信用）
+func (r *ProductRepository) GetProductImage(ctx context.Context, productID int) (string, error) {
+	var image sql.NullString
+	query := "SELECT image FROM products WHERE product_id = ?"
+	qctx, cancel := r.timeouts.pointRead(ctx, "GetProductImage")
+	defer cancel()
+	if err := r.db.GetContext(qctx, &image, query, productID); err != nil {
+		return "", qctx.wrap(err)
+	}
+	return image.String, nil
+}
+
+// 商品テーブルの最終更新日時と件数を取得（一覧の ETag 計算用）
+func (r *ProductRepository) LastModified(ctx context.Context) (time.Time, int, error) {
+	var row struct {
+		UpdatedAt aggregateTime `db:"updated_at"`
+		Count     int           `db:"cnt"`
+	}
+	if err := r.db.GetContext(ctx, &row, "SELECT MAX(updated_at) AS updated_at, COUNT(*) AS cnt FROM products"); err != nil {
+		return time.Time{}, 0, err
+	}
+	return row.UpdatedAt.Time, row.Count, nil
+}
+
+// 注文数が在庫数を上回る場合に DecrementStock が返す
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// 在庫を管理している商品の在庫数を減らし、減算後の在庫数を返す
+// 在庫管理の対象外（stock が NULL）の商品は nil を返す（トランザクション内で使用）
+// 在庫数が quantity に満たない場合は減らさずに ErrInsufficientStock を返す（条件付きの UPDATE のため、同時の注文でも負にならない）
+func (r *ProductRepository) DecrementStock(ctx context.Context, productID, quantity int) (*int, error) {
+	result, err := r.db.ExecContext(ctx, "UPDATE products SET stock = stock - ? WHERE product_id = ? AND (stock IS NULL OR stock >= ?)", quantity, productID, quantity)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	// MySQL は値が変わらない行（stock が NULL）を0件と数えるため、0件の場合は在庫不足か管理対象外かを確かめる
+	var stock *int
+	if err := r.db.GetContext(ctx, &stock, "SELECT stock FROM products WHERE product_id = ?", productID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if stock == nil {
+		return nil, nil
+	}
+	if affected == 0 {
+		return nil, ErrInsufficientStock
+	}
+	r.changes.record(ctx, cache.EntityProductStock, int64(productID))
+	return stock, nil
+}
+
+// 在庫数が閾値未満の商品を在庫の少ない順に取得
+func (r *ProductRepository) ListLowStock(ctx context.Context, threshold int) ([]model.StockLevel, error) {
+	levels := []model.StockLevel{}
+	query := "SELECT product_id, name, stock FROM products WHERE stock < ? ORDER BY stock ASC, product_id ASC"
+	if err := r.db.SelectContext(ctx, &levels, query, threshold); err != nil {
+		return nil, err
+	}
+	return levels, nil
+}
+
+// 商品行を排他ロックする（トランザクション内で使用）
+// 存在しない場合は sql.ErrNoRows を返す
+func (r *ProductRepository) LockProduct(ctx context.Context, productID int) error {
+	var id int
+	return r.db.GetContext(ctx, &id, "SELECT product_id FROM products WHERE product_id = ?"+r.dialect.ForUpdate(), productID)
+}
+
+// 商品行を排他ロックし、商品IDごとの現在の価値を返す（トランザクション内で使用）
+// デッドロックを避けるため、商品IDの昇順にロックする。存在しない商品は結果に含まれない
+func (r *ProductRepository) LockValues(ctx context.Context, productIDs []int) (map[int]int, error) {
+	values := make(map[int]int, len(productIDs))
+	if len(productIDs) == 0 {
+		return values, nil
+	}
+	query, args, err := sqlx.In("SELECT product_id, value FROM products WHERE product_id IN (?) ORDER BY product_id"+r.dialect.ForUpdate(), productIDs)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		ProductID int `db:"product_id"`
+		Value     int `db:"value"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		values[row.ProductID] = row.Value
+	}
+	return values, nil
+}
+
+// 商品を作成し、生成された商品IDを返す
+func (r *ProductRepository) CreateProduct(ctx context.Context, p *model.Product) (int, error) {
+	query := `INSERT INTO products (name, value, currency, weight, image, description, category_id, stock) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	args := []interface{}{p.Name, p.Value, p.Currency, p.Weight, p.Image, p.Description, p.CategoryID, p.Stock}
+	if r.schema.ProductSearchText {
+		query = `INSERT INTO products (name, value, currency, weight, image, description, category_id, stock, search_text) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		args = append(args, buildSearchText(p.Name, p.Description))
+	}
+	id, err := insertReturningID(ctx, r.db, r.dialect, query, "product_id", args...)
 	if err != nil {
 		return 0, err
 	}
+	r.changes.record(ctx, cache.EntityProduct, id)
+	return int(id), nil
+}
 
-	return count, nil
+// 商品を更新する。存在しない場合は sql.ErrNoRows を返す
+func (r *ProductRepository) UpdateProduct(ctx context.Context, p *model.Product) error {
+	query := `UPDATE products SET name = ?, value = ?, currency = ?, weight = ?, image = ?, description = ?, category_id = ?, stock = ? WHERE product_id = ?`
+	args := []interface{}{p.Name, p.Value, p.Currency, p.Weight, p.Image, p.Description, p.CategoryID, p.Stock, p.ProductID}
+	if r.schema.ProductSearchText {
+		query = `UPDATE products SET name = ?, value = ?, currency = ?, weight = ?, image = ?, description = ?, category_id = ?, stock = ?, search_text = ? WHERE product_id = ?`
+		args = []interface{}{p.Name, p.Value, p.Currency, p.Weight, p.Image, p.Description, p.CategoryID, p.Stock, buildSearchText(p.Name, p.Description), p.ProductID}
+	}
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		// 値が変わらない場合も0件になるため、存在確認を行う
+		var exists int
+		if err := r.db.GetContext(ctx, &exists, "SELECT 1 FROM products WHERE product_id = ?", p.ProductID); err != nil {
+			return err
+		}
+	}
+	r.changes.record(ctx, cache.EntityProduct, int64(p.ProductID))
+	return nil
+}
+
+// 商品を削除する。存在しない場合は sql.ErrNoRows を返す
+func (r *ProductRepository) DeleteProduct(ctx context.Context, productID int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM products WHERE product_id = ?", productID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	r.changes.record(ctx, cache.EntityProduct, int64(productID))
+	return nil
+}
+
+// 商品を複数行INSERTでまとめて登録する
+// ProductID が指定された行は既存商品を更新する（INSERT ... ON DUPLICATE KEY UPDATE / ON CONFLICT DO UPDATE）
+// ProductID が無い行は product_id を列に含めずに INSERT し、自動採番に任せる
+// 戻り値は chunk の行ごとに数えた挿入件数と更新件数（値が変わらない更新も更新として数える）
+func (r *ProductRepository) BulkUpsertProducts(ctx context.Context, products []model.Product) (inserted, updated int, err error) {
+	var newRows, idRows []model.Product
+	for _, p := range products {
+		if p.ProductID > 0 {
+			idRows = append(idRows, p)
+		} else {
+			newRows = append(newRows, p)
+		}
+	}
+
+	if len(newRows) > 0 {
+		query, args := r.bulkInsertQuery(newRows, false)
+		if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+			return 0, 0, err
+		}
+		inserted = len(newRows)
+	}
+	if len(idRows) > 0 {
+		ins, upd, err := r.bulkUpsertByID(ctx, idRows)
+		if err != nil {
+			return 0, 0, err
+		}
+		inserted += ins
+		updated = upd
+	}
+	if inserted > 0 || updated > 0 {
+		r.changes.record(ctx, cache.EntityProduct, 0)
+	}
+	return inserted, updated, nil
+}
+
+// ProductID が指定された行を一括で挿入・更新する
+// 挿入か更新かは書き込む前に既存の商品IDを（ロックを取って）調べて数える
+// MySQL の affected rows は更新で2、値が変わらない行で0と数えられ、件数の算出には使えないため
+// PostgreSQL の ON CONFLICT DO UPDATE は1つの文で同じ行を2回更新できないため、同じ商品IDの行は最後の行だけを書き込む
+// （同じ商品IDの2行目以降は更新として数える）
+func (r *ProductRepository) bulkUpsertByID(ctx context.Context, products []model.Product) (inserted, updated int, err error) {
+	last := make(map[int]int, len(products))
+	ids := make([]int, 0, len(products))
+	for i, p := range products {
+		if _, ok := last[p.ProductID]; !ok {
+			ids = append(ids, p.ProductID)
+		}
+		last[p.ProductID] = i
+	}
+	existing, err := r.LockValues(ctx, ids)
+	if err != nil {
+		return 0, 0, err
+	}
+	rows := make([]model.Product, 0, len(last))
+	seen := make(map[int]bool, len(last))
+	for i, p := range products {
+		if _, ok := existing[p.ProductID]; ok || seen[p.ProductID] {
+			updated++
+		} else {
+			inserted++
+		}
+		seen[p.ProductID] = true
+		if last[p.ProductID] == i {
+			rows = append(rows, p)
+		}
+	}
+
+	query, args := r.bulkInsertQuery(rows, true)
+	updates := make([]string, 0, 8)
+	for _, c := range r.bulkUpdateColumns() {
+		updates = append(updates, c+" = "+r.dialect.Excluded(c))
+	}
+	query += " " + r.dialect.OnConflictUpdate("product_id") + " " + strings.Join(updates, ", ")
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		return 0, 0, err
+	}
+	// 明示したIDで挿入しても PostgreSQL のシーケンスは進まないため、後の自動採番が衝突しないよう合わせる
+	if stmt := r.dialect.SyncSequence("products", "product_id"); stmt != "" {
+		if _, err := r.db.ExecContext(ctx, stmt); err != nil {
+			return 0, 0, err
+		}
+	}
+	return inserted, updated, nil
+}
+
+// 一括登録で書き込む（更新する）カラム
+func (r *ProductRepository) bulkUpdateColumns() []string {
+	columns := []string{"name", "value", "currency", "weight", "image", "description", "category_id"}
+	if r.schema.ProductSearchText {
+		columns = append(columns, "search_text")
+	}
+	return columns
+}
+
+// 複数行INSERTの文と引数を組み立てる（withID が false の場合は product_id を含めない）
+func (r *ProductRepository) bulkInsertQuery(products []model.Product, withID bool) (string, []interface{}) {
+	columns := r.bulkUpdateColumns()
+	if withID {
+		columns = append([]string{"product_id"}, columns...)
+	}
+	placeholder := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+
+	values := make([]string, len(products))
+	args := make([]interface{}, 0, len(products)*len(columns))
+	for i, p := range products {
+		values[i] = placeholder
+		if withID {
+			args = append(args, p.ProductID)
+		}
+		args = append(args, p.Name, p.Value, p.Currency, p.Weight, p.Image, p.Description, p.CategoryID)
+		if r.schema.ProductSearchText {
+			args = append(args, buildSearchText(p.Name, p.Description))
+		}
+	}
+	return "INSERT INTO products (" + strings.Join(columns, ", ") + ") VALUES " + strings.Join(values, ", "), args
 }
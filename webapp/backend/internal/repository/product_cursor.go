@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"backend/internal/cursor"
+	"backend/internal/model"
+	"cmp"
+	"fmt"
+	"strings"
+)
+
+// カーソルが不正、または現在の検索条件と一致しない場合に返す
+var ErrInvalidCursor = cursor.ErrInvalid
+
+// 商品一覧のキーセットページング用カーソル
+// 最後に返した行のソートキーと product_id（同値時のタイブレーク）を保持する
+type productCursor struct {
+	SortField string
+	SortOrder string
+	Value     interface{}
+	ProductID int
+}
+
+// productCursorFilter はカーソルを発行した検索条件を表す。条件が変わるとカーソルは使えない
+func productCursorFilter(req model.ListRequest, sortField, sortOrder string) string {
+	return fmt.Sprintf("product|%s|%s|%q|%s|%d", sortField, sortOrder, req.Search, req.Type, req.Category)
+}
+
+// EncodeCursor は一覧の最後の商品から次ページ用の不透明なトークンを生成する
+func (r *ProductRepository) EncodeCursor(req model.ListRequest, last model.Product) (string, error) {
+	return encodeProductCursor(r.cursors, req, last)
+}
+
+func (r *ProductRepository) decodeCursor(req model.ListRequest, sortField, sortOrder string) (*productCursor, error) {
+	return decodeProductCursor(r.cursors, req, sortField, sortOrder)
+}
+
+func encodeProductCursor(codec *cursor.Codec, req model.ListRequest, last model.Product) (string, error) {
+	order, err := productSort.Validate(req.SortField, req.SortOrder)
+	if err != nil {
+		return "", err
+	}
+	pos := cursor.Position{ID: int64(last.ProductID)}
+	switch order.Field {
+	case "name":
+		pos.Values = []interface{}{last.Name}
+	case "value":
+		pos.Values = []interface{}{last.Value}
+	case "weight":
+		pos.Values = []interface{}{last.Weight}
+	}
+	return codec.Encode(pos, productCursorFilter(req, order.Field, order.Dir))
+}
+
+func decodeProductCursor(codec *cursor.Codec, req model.ListRequest, sortField, sortOrder string) (*productCursor, error) {
+	pos, err := codec.Decode(req.Cursor, productCursorFilter(req, sortField, sortOrder))
+	if err != nil {
+		return nil, err
+	}
+	c := &productCursor{SortField: sortField, SortOrder: sortOrder, ProductID: int(pos.ID)}
+	if sortField != "product_id" {
+		if len(pos.Values) != 1 || pos.Values[0] == nil {
+			return nil, ErrInvalidCursor
+		}
+		c.Value = pos.Values[0]
+	}
+	return c, nil
+}
+
+// カーソル位置より後ろの行を取得するための条件
+// ORDER BY <sortField> <sortOrder>, product_id ASC と同じ順序で「次」を表す
+func (c *productCursor) whereClause() (string, []interface{}) {
+	if c.SortField == "product_id" {
+		if c.SortOrder == "DESC" {
+			return "product_id < ?", []interface{}{c.ProductID}
+		}
+		return "product_id > ?", []interface{}{c.ProductID}
+	}
+	op := ">"
+	if c.SortOrder == "DESC" {
+		op = "<"
+	}
+	cond := fmt.Sprintf("(%s %s ? OR (%s = ? AND product_id > ?))", c.SortField, op, c.SortField)
+	return cond, []interface{}{c.Value, c.Value, c.ProductID}
+}
+
+// after は p がカーソル位置より後ろの行か（whereClause と同じ条件を STORAGE=memory の一覧で使う）
+func (c *productCursor) after(p model.Product) bool {
+	if c.SortField == "product_id" {
+		if c.SortOrder == "DESC" {
+			return p.ProductID < c.ProductID
+		}
+		return p.ProductID > c.ProductID
+	}
+	var d int
+	switch c.SortField {
+	case "name":
+		s, ok := c.Value.(string)
+		if !ok {
+			return false
+		}
+		d = strings.Compare(p.Name, s)
+	case "value", "weight":
+		// カーソルの値は JSON を経由するため float64 で読まれる
+		n, ok := c.Value.(float64)
+		if !ok {
+			return false
+		}
+		v := p.Value
+		if c.SortField == "weight" {
+			v = p.Weight
+		}
+		d = cmp.Compare(float64(v), n)
+	}
+	if d == 0 {
+		return p.ProductID > c.ProductID
+	}
+	if c.SortOrder == "DESC" {
+		return d < 0
+	}
+	return d > 0
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// 共起テーブルの作り直しで、作業用テーブルへ1回の INSERT で投入する行数
+const cooccurrenceInsertBatchSize = 1000
+
+// 同じユーザーが同時刻に作成した注文（1回の注文リクエスト）を「一緒に注文された」とみなす
+const cooccurrenceSelect = `
+		SELECT a.product_id, b.product_id AS related_product_id, COUNT(*) AS score
+		FROM orders a
+		JOIN orders b
+			ON a.user_id = b.user_id
+			AND a.created_at = b.created_at
+			AND a.product_id <> b.product_id
+		GROUP BY a.product_id, b.product_id`
+
+type RecommendationRepository struct {
+	db       DBTX
+	timeouts *QueryTimeouts
+	dialect  Dialect
+}
+
+func NewRecommendationRepository(db DBTX, timeouts *QueryTimeouts, dialect Dialect) *RecommendationRepository {
+	return &RecommendationRepository{db: db, timeouts: timeouts, dialect: dialect}
+}
+
+// 共起テーブルを orders から再計算する（トランザクション内で使用）
+// MySQL では INSERT ... SELECT が orders の行を共有ロックするため、RebuildCooccurrence を使う
+func (r *RecommendationRepository) RefreshCooccurrence(ctx context.Context) error {
+	dctx, cancel := r.timeouts.write(ctx, "ClearCooccurrence")
+	defer cancel()
+	if _, err := r.db.ExecContext(dctx, "DELETE FROM product_cooccurrence"); err != nil {
+		return dctx.wrap(err)
+	}
+	// orders を全件集計するため、配送計画と同じ全件読み取りのタイムアウトを使う
+	qctx, cancel := r.timeouts.planRead(ctx, "RefreshCooccurrence")
+	defer cancel()
+	_, err := r.db.ExecContext(qctx, "INSERT INTO product_cooccurrence (product_id, related_product_id, score)"+cooccurrenceSelect)
+	return qctx.wrap(err)
+}
+
+// RebuildsBySwap は RebuildCooccurrence を使えるか（MySQL のみ）を返す
+func (r *RecommendationRepository) RebuildsBySwap() bool {
+	return r.dialect.IsMySQL()
+}
+
+// RebuildCooccurrence は共起テーブルを作業用テーブルに作り直し、RENAME TABLE で入れ替える（MySQL のみ。トランザクション外で使用）
+// 集計はロックしない読み取り（一貫性のあるスナップショット）で行い、orders への書き込みを止めない
+// 入れ替えは1文で行うため、読み取り側から空や作りかけのテーブルは見えない
+// 作業用テーブルの名前は実行ごとに変え、複数台で同時に実行しても互いのテーブルを壊さない
+func (r *RecommendationRepository) RebuildCooccurrence(ctx context.Context) error {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	staging := "product_cooccurrence_new_" + hex.EncodeToString(suffix)
+	old := "product_cooccurrence_old_" + hex.EncodeToString(suffix)
+	cctx, cancel := r.timeouts.write(ctx, "CreateCooccurrenceStaging")
+	defer cancel()
+	if _, err := r.db.ExecContext(cctx, "CREATE TABLE "+staging+" LIKE product_cooccurrence"); err != nil {
+		return cctx.wrap(err)
+	}
+	// 途中で失敗した場合も作業用テーブルを残さない（入れ替え後は古いテーブルを消す）
+	cleanup := context.WithoutCancel(ctx)
+	defer r.db.ExecContext(cleanup, "DROP TABLE IF EXISTS "+staging+", "+old)
+
+	// 集計結果を読み終えるまでを全件読み取りのタイムアウトで区切る
+	qctx, cancel := r.timeouts.planRead(ctx, "RebuildCooccurrence")
+	defer cancel()
+	rows, err := r.db.QueryxContext(qctx, cooccurrenceSelect)
+	if err != nil {
+		return qctx.wrap(err)
+	}
+	defer rows.Close()
+	args := make([]interface{}, 0, cooccurrenceInsertBatchSize*3)
+	flush := func() error {
+		if len(args) == 0 {
+			return nil
+		}
+		n := len(args) / 3
+		query := "INSERT INTO " + staging + " (product_id, related_product_id, score) VALUES " + strings.TrimSuffix(strings.Repeat("(?, ?, ?),", n), ",")
+		ictx, cancel := r.timeouts.write(ctx, "InsertCooccurrenceBatch")
+		defer cancel()
+		_, err := r.db.ExecContext(ictx, query, args...)
+		args = args[:0]
+		return ictx.wrap(err)
+	}
+	for rows.Next() {
+		var productID, relatedID, score int64
+		if err := rows.Scan(&productID, &relatedID, &score); err != nil {
+			return qctx.wrap(err)
+		}
+		args = append(args, productID, relatedID, score)
+		if len(args) == cap(args) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return qctx.wrap(err)
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	sctx, cancel := r.timeouts.write(ctx, "SwapCooccurrence")
+	defer cancel()
+	_, err = r.db.ExecContext(sctx, "RENAME TABLE product_cooccurrence TO "+old+", "+staging+" TO product_cooccurrence")
+	return sctx.wrap(err)
+}
+
+// 一緒に注文されることの多い商品を取得
+func (r *RecommendationRepository) ListRelatedProducts(ctx context.Context, productID, limit int) ([]model.Product, error) {
+	products := []model.Product{}
+	query := `
+		SELECT p.product_id, p.name, p.value, p.currency, p.weight, p.image, p.description, p.category_id, p.rating_avg, p.rating_count
+		FROM product_cooccurrence c
+		JOIN products p ON c.related_product_id = p.product_id
+		WHERE c.product_id = ?
+		ORDER BY c.score DESC, c.related_product_id ASC
+		LIMIT ?`
+	qctx, cancel := r.timeouts.listRead(ctx, "ListRelatedProducts")
+	defer cancel()
+	if err := r.db.SelectContext(qctx, &products, query, productID, limit); err != nil {
+		return nil, qctx.wrap(err)
+	}
+	return products, nil
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const replicaHealthCheckInterval = 5 * time.Second
+
+type replica struct {
+	db      DBTX
+	conn    *sqlx.DB
+	healthy atomic.Bool
+}
+
+type replicaCtxKey int
+
+const (
+	allowReplicaKey replicaCtxKey = iota
+	writeTrackerKey
+)
+
+// AllowReplica は ctx での読み取りをレプリカに振り分けてよいことを示す
+// レプリカは遅れて追従するため、直前の書き込みを読み返す必要の無い読み取り（商品一覧・検索など）にだけ指定する
+func AllowReplica(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allowReplicaKey, true)
+}
+
+// TrackWrites は ctx（主にリクエスト単位）の中でプライマリへ書き込んだことを記録できるようにする
+// 書き込みの後は、AllowReplica を指定した読み取りもプライマリで行う（read-your-writes）
+func TrackWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writeTrackerKey, new(atomic.Bool))
+}
+
+// ctx で書き込みがあったことを記録する（TrackWrites されていない ctx では何もしない）
+func markWritten(ctx context.Context) {
+	if wrote, ok := ctx.Value(writeTrackerKey).(*atomic.Bool); ok {
+		wrote.Store(true)
+	}
+}
+
+// ctx の読み取りをレプリカで行ってよいか
+func replicaAllowed(ctx context.Context) bool {
+	if allowed, _ := ctx.Value(allowReplicaKey).(bool); !allowed {
+		return false
+	}
+	wrote, ok := ctx.Value(writeTrackerKey).(*atomic.Bool)
+	return !ok || !wrote.Load()
+}
+
+// replicaRouter はトランザクション外の読み取りのうち、AllowReplica を指定したものをレプリカに振り分ける DBTX
+// それ以外の読み取りと書き込みは埋め込んだプライマリで実行し、正常なレプリカが無い場合もプライマリで行う
+// 遅延が maxLag を超えたレプリカは、追いつくまで振り分け対象から外す（0 の場合は遅延を見ない）
+type replicaRouter struct {
+	DBTX
+	replicas []*replica
+	next     atomic.Uint64
+	maxLag   time.Duration
+}
+
+// ラウンドロビンで正常なレプリカを選ぶ（ctx がレプリカを許可していない場合はプライマリ）
+func (r *replicaRouter) reader(ctx context.Context) DBTX {
+	if !replicaAllowed(ctx) {
+		return r.DBTX
+	}
+	n := uint64(len(r.replicas))
+	start := r.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		rep := r.replicas[(start+i)%n]
+		if rep.healthy.Load() {
+			return rep.db
+		}
+	}
+	return r.DBTX
+}
+
+func (r *replicaRouter) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return r.reader(ctx).GetContext(ctx, dest, query, args...)
+}
+
+func (r *replicaRouter) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return r.reader(ctx).SelectContext(ctx, dest, query, args...)
+}
+
+func (r *replicaRouter) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	return r.reader(ctx).QueryxContext(ctx, query, args...)
+}
+
+func (r *replicaRouter) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	markWritten(ctx)
+	return r.DBTX.ExecContext(ctx, query, args...)
+}
+
+func (r *replicaRouter) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	markWritten(ctx)
+	return r.DBTX.NamedExecContext(ctx, query, arg)
+}
+
+// 遅延を取得できない場合（権限不足など）は ping の結果だけで判断する
+func (r *replicaRouter) checkHealth(ctx context.Context) {
+	for _, rep := range r.replicas {
+		checkCtx, cancel := context.WithTimeout(ctx, time.Second)
+		healthy := rep.conn.PingContext(checkCtx) == nil
+		if healthy && r.maxLag > 0 {
+			if lag := replicaLag(checkCtx, rep); lag != nil && *lag > r.maxLag {
+				healthy = false
+			}
+		}
+		rep.healthy.Store(healthy)
+		cancel()
+	}
+}
+
+// 定期的にレプリカへ ping し、応答しないもの・遅延が大きいものを振り分け対象から外す
+func (r *replicaRouter) runHealthCheck(ctx context.Context) {
+	r.checkHealth(ctx)
+	ticker := time.NewTicker(replicaHealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.checkHealth(ctx)
+		}
+	}
+}
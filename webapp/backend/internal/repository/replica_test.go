@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+// 呼び出された DBTX を記録するための偽の接続
+type namedDB struct {
+	DBTX
+	name  string
+	calls *[]string
+}
+
+func (d *namedDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	*d.calls = append(*d.calls, d.name)
+	return nil
+}
+
+func (d *namedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	*d.calls = append(*d.calls, d.name)
+	return nil, nil
+}
+
+func TestReplicaRouterReadYourWrites(t *testing.T) {
+	var calls []string
+	router := &replicaRouter{DBTX: &namedDB{name: "primary", calls: &calls}}
+	rep := &replica{db: &namedDB{name: "replica", calls: &calls}}
+	rep.healthy.Store(true)
+	router.replicas = []*replica{rep}
+
+	ctx := TrackWrites(context.Background())
+	var dest int
+	_ = router.GetContext(ctx, &dest, "SELECT 1")
+	_ = router.GetContext(AllowReplica(ctx), &dest, "SELECT 1")
+	_, _ = router.ExecContext(ctx, "UPDATE t SET a = 1")
+	_ = router.GetContext(AllowReplica(ctx), &dest, "SELECT 1")
+
+	rep.healthy.Store(false)
+	_ = router.GetContext(AllowReplica(context.Background()), &dest, "SELECT 1")
+
+	want := []string{"primary", "replica", "primary", "primary", "primary"}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("calls = %v, want %v", calls, want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"time"
+)
+
+// Store が持つリポジトリの型
+// SQL で読み書きする実装（*UserRepository など）と、STORAGE=memory で使うプロセス内の map の実装（memory*.go）がある
+
+type Users interface {
+	FindByUserName(ctx context.Context, userName string) (*model.User, error)
+	UpdatePasswordHash(ctx context.Context, userID int, passwordHash string) error
+	FindRoleByID(ctx context.Context, userID int) (string, error)
+	FindTimezoneByID(ctx context.Context, userID int) (string, error)
+	UpdateTimezone(ctx context.Context, userID int, tz string) error
+}
+
+type Sessions interface {
+	Create(ctx context.Context, userBusinessID int, duration time.Duration) (string, time.Time, error)
+	FindUserBySessionID(ctx context.Context, sessionID string) (int, error)
+}
+
+type Products interface {
+	ListProducts(ctx context.Context, userID int, req model.ListRequest) ([]model.Product, error)
+	StreamProducts(ctx context.Context, req model.ListRequest, fn func(*model.Product) error) error
+	CountFacets(ctx context.Context, req model.ListRequest) (*model.Facets, error)
+	CountProducts(ctx context.Context, userID int, req model.ListRequest) (int, error)
+	GetProduct(ctx context.Context, productID int) (*model.Product, error)
+	SearchProducts(ctx context.Context, req model.ListRequest) ([]SearchHit, int, error)
+	GetProductsByIDs(ctx context.Context, ids []int) ([]model.Product, error)
+	SuggestProductNames(ctx context.Context, prefix string, limit int) ([]string, error)
+	GetProductImage(ctx context.Context, productID int) (string, error)
+	LastModified(ctx context.Context) (time.Time, int, error)
+	DecrementStock(ctx context.Context, productID, quantity int) (*int, error)
+	ListLowStock(ctx context.Context, threshold int) ([]model.StockLevel, error)
+	LockProduct(ctx context.Context, productID int) error
+	LockValues(ctx context.Context, productIDs []int) (map[int]int, error)
+	CreateProduct(ctx context.Context, p *model.Product) (int, error)
+	UpdateProduct(ctx context.Context, p *model.Product) error
+	DeleteProduct(ctx context.Context, productID int) error
+	BulkUpsertProducts(ctx context.Context, products []model.Product) (inserted, updated int, err error)
+	EncodeCursor(req model.ListRequest, last model.Product) (string, error)
+}
+
+type Orders interface {
+	Create(ctx context.Context, order *model.Order) error
+	FindIDByPublicID(ctx context.Context, publicID string) (int64, error)
+	FindOwner(ctx context.Context, ref model.OrderRef) (int64, int, error)
+	FindByID(ctx context.Context, orderID int64) (*model.Order, error)
+	AssignPublicIDs(ctx context.Context, limit int) (int, error)
+	PublicIDs(ctx context.Context, orderIDs []int64) (map[int64]string, error)
+	UpdateStatuses(ctx context.Context, orderIDs []int64, newStatus model.ShippedStatus) error
+	GetStatus(ctx context.Context, orderID int64) (model.ShippedStatus, error)
+	UpdateStatusesConditional(ctx context.Context, orderIDs []int64, newStatus, expectedCurrent model.ShippedStatus) (int64, error)
+	GetShippingOrders(ctx context.Context) ([]model.Order, error)
+	CountOrders(ctx context.Context, userID int, req model.ListRequest) (int, error)
+	ListOrders(ctx context.Context, userID int, req model.ListRequest) ([]model.Order, error)
+	StreamOrders(ctx context.Context, userID int, req model.ListRequest, fn func(*model.Order) error) error
+	LastModified(ctx context.Context, userID int) (time.Time, int, error)
+
+	// 運用ダッシュボード向けの集計
+	CountByStatus(ctx context.Context) ([]model.StatusCount, error)
+	CountClaimedSince(ctx context.Context, since time.Time) (n int, ok bool, err error)
+	FindStuckDelivering(ctx context.Context, cutoff time.Time, limit int) (ids []int64, ok bool, err error)
+	AverageTimeToDelivery(ctx context.Context) (time.Duration, int, error)
+	TopProducts(ctx context.Context, from, to time.Time, limit int) ([]model.ProductSales, error)
+}
+
+type Categories interface {
+	ListCategories(ctx context.Context) ([]model.Category, error)
+}
+
+type Reviews interface {
+	Create(ctx context.Context, review *model.Review) (int64, error)
+	RefreshProductRating(ctx context.Context, productID int) error
+	CountByProduct(ctx context.Context, productID int) (int, error)
+	ListByProduct(ctx context.Context, productID int, limit, offset int) ([]model.Review, error)
+}
+
+type Favorites interface {
+	Add(ctx context.Context, userID, productID int) error
+	Remove(ctx context.Context, userID, productID int) error
+	FavoritedProductIDs(ctx context.Context, userID int, productIDs []int) (map[int]bool, error)
+	Version(ctx context.Context, userID int) (time.Time, int, error)
+	CountByUser(ctx context.Context, userID int) (int, error)
+	ListByUser(ctx context.Context, userID int, limit, offset int) ([]model.Product, error)
+}
+
+type Carts interface {
+	AddItem(ctx context.Context, userID, productID, quantity int) error
+	SetQuantity(ctx context.Context, userID, productID, quantity int) error
+	RemoveItem(ctx context.Context, userID, productID int) error
+	ListItems(ctx context.Context, userID int) ([]model.CartItem, error)
+	ListItemsForUpdate(ctx context.Context, userID int) ([]model.RequestItem, error)
+	Clear(ctx context.Context, userID int) error
+}
+
+type Recommendations interface {
+	RefreshCooccurrence(ctx context.Context) error
+	RebuildsBySwap() bool
+	RebuildCooccurrence(ctx context.Context) error
+	ListRelatedProducts(ctx context.Context, productID, limit int) ([]model.Product, error)
+}
+
+type PriceHistory interface {
+	Create(ctx context.Context, h *model.PriceHistory) error
+	CreateBatch(ctx context.Context, history []model.PriceHistory) error
+	ListByProduct(ctx context.Context, productID, limit int) ([]model.PriceHistory, error)
+}
+
+type Outbox interface {
+	Add(ctx context.Context, eventType string, aggregateID int64, payload interface{}) error
+	AcquireRelayLease(ctx context.Context, owner string, lease time.Duration) (bool, error)
+	ReleaseRelayLease(ctx context.Context, owner string) error
+	ListPending(ctx context.Context, limit int) ([]model.OutboxEvent, error)
+	MarkPublished(ctx context.Context, eventIDs []int64) error
+	RecordFailure(ctx context.Context, eventID int64, cause error) error
+	PurgePublished(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+type IdempotencyKeys interface {
+	Enabled() bool
+	Reserve(ctx context.Context, scope, key, requestHash string, now, expiresAt time.Time) (bool, error)
+	Get(ctx context.Context, scope, key string) (*model.IdempotencyRecord, error)
+	TakeOver(ctx context.Context, scope, key string, staleBefore, now, expiresAt time.Time) (bool, error)
+	Complete(ctx context.Context, scope, key string, statusCode int, contentType string, body []byte) error
+	Release(ctx context.Context, scope, key string) error
+	PurgeExpired(ctx context.Context, now time.Time, limit int) (int64, error)
+}
+
+type Summaries interface {
+	Enabled() bool
+	LatestBucket(ctx context.Context, period string) (t time.Time, ok bool, err error)
+	EarliestOrder(ctx context.Context) (t time.Time, ok bool, err error)
+	AggregateHours(ctx context.Context, from, to time.Time) ([]model.OrderSummary, error)
+	Replace(ctx context.Context, period string, from, to time.Time, summaries []model.OrderSummary) error
+	List(ctx context.Context, period string, from, to time.Time) ([]model.OrderSummary, error)
+	DeliveryTotals(ctx context.Context) (delivered int, seconds float64, err error)
+}
+
+type Notifications interface {
+	Get(ctx context.Context, userID int) (prefs model.NotificationPreferences, ok bool, err error)
+	Put(ctx context.Context, userID int, prefs model.NotificationPreferences) error
+	DeliveryRecipients(ctx context.Context, orderIDs []int64) ([]model.DeliveryRecipient, error)
+}
+
+var (
+	_ Users           = (*UserRepository)(nil)
+	_ Sessions        = (*SessionRepository)(nil)
+	_ Products        = (*ProductRepository)(nil)
+	_ Orders          = (*OrderRepository)(nil)
+	_ Categories      = (*CategoryRepository)(nil)
+	_ Reviews         = (*ReviewRepository)(nil)
+	_ Favorites       = (*FavoriteRepository)(nil)
+	_ Carts           = (*CartRepository)(nil)
+	_ Recommendations = (*RecommendationRepository)(nil)
+	_ PriceHistory    = (*PriceHistoryRepository)(nil)
+	_ Outbox          = (*OutboxRepository)(nil)
+	_ IdempotencyKeys = (*IdempotencyRepository)(nil)
+	_ Summaries       = (*SummaryRepository)(nil)
+	_ Notifications   = (*NotificationRepository)(nil)
+)
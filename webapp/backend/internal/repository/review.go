@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"backend/internal/cache"
+	"backend/internal/model"
+	"context"
+)
+
+type ReviewRepository struct {
+	db       DBTX
+	timeouts *QueryTimeouts
+	dialect  Dialect
+	changes  *changeLog
+}
+
+func NewReviewRepository(db DBTX, timeouts *QueryTimeouts, dialect Dialect) *ReviewRepository {
+	return &ReviewRepository{db: db, timeouts: timeouts, dialect: dialect}
+}
+
+// レビューを作成し、生成されたレビューIDを返す
+func (r *ReviewRepository) Create(ctx context.Context, review *model.Review) (int64, error) {
+	query := `INSERT INTO reviews (product_id, user_id, rating, comment, created_at) VALUES (?, ?, ?, ?, ?)`
+	qctx, cancel := r.timeouts.write(ctx, "CreateReview")
+	defer cancel()
+	id, err := insertReturningID(qctx, r.db, r.dialect, query, "review_id", review.ProductID, review.UserID, review.Rating, review.Comment, review.CreatedAt)
+	return id, qctx.wrap(err)
+}
+
+// 商品の平均評価とレビュー件数を再集計して products に反映する
+// 同時投稿で集計が食い違わないよう、ProductRepository.LockProduct で商品行をロックしてから呼ぶこと
+func (r *ReviewRepository) RefreshProductRating(ctx context.Context, productID int) error {
+	query := `
+		UPDATE products
+		SET rating_count = (SELECT COUNT(*) FROM reviews WHERE product_id = ?),
+			rating_avg = (SELECT COALESCE(AVG(rating), 0) FROM reviews WHERE product_id = ?)
+		WHERE product_id = ?`
+	qctx, cancel := r.timeouts.write(ctx, "RefreshProductRating")
+	defer cancel()
+	if _, err := r.db.ExecContext(qctx, query, productID, productID, productID); err != nil {
+		return qctx.wrap(err)
+	}
+	// 商品の一覧・詳細のキャッシュに古い評価が残らないようにする
+	r.changes.record(ctx, cache.EntityProduct, int64(productID))
+	return nil
+}
+
+// 商品のレビュー件数を取得
+func (r *ReviewRepository) CountByProduct(ctx context.Context, productID int) (int, error) {
+	var count int
+	qctx, cancel := r.timeouts.listRead(ctx, "CountReviews")
+	defer cancel()
+	if err := r.db.GetContext(qctx, &count, "SELECT COUNT(*) FROM reviews WHERE product_id = ?", productID); err != nil {
+		return 0, qctx.wrap(err)
+	}
+	return count, nil
+}
+
+// 商品のレビュー一覧を新しい順に取得
+func (r *ReviewRepository) ListByProduct(ctx context.Context, productID int, limit, offset int) ([]model.Review, error) {
+	reviews := []model.Review{}
+	query := `
+		SELECT review_id, product_id, user_id, rating, COALESCE(comment, '') AS comment, created_at
+		FROM reviews
+		WHERE product_id = ?
+		ORDER BY created_at DESC, review_id DESC
+		LIMIT ? OFFSET ?`
+	qctx, cancel := r.timeouts.listRead(ctx, "ListReviews")
+	defer cancel()
+	if err := r.db.SelectContext(qctx, &reviews, query, productID, limit, offset); err != nil {
+		return nil, qctx.wrap(err)
+	}
+	return reviews, nil
+}
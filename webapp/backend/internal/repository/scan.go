@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SQLite が日時を文字列で返す場合の書式（ドライバの書式と CURRENT_TIMESTAMP / strftime の書式）
+var aggregateTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+}
+
+// aggregateTime は MAX(updated_at) などの集約結果の日時を読み取る
+// SQLite では集約関数の結果が列の型を失い文字列になるため、sql.NullTime では読み取れない
+type aggregateTime struct {
+	sql.NullTime
+}
+
+func (t *aggregateTime) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return t.NullTime.Scan(src)
+	}
+	for _, layout := range aggregateTimeLayouts {
+		if parsed, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			t.Time, t.Valid = parsed, true
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse %q as time", s)
+}
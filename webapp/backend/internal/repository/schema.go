@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+)
+
+// SchemaCapabilities は起動時に検出したスキーマの状態
+// マイグレーションの適用状況によってクエリの形を切り替えるために使用する
+type SchemaCapabilities struct {
+	// products.search_text（と FULLTEXT インデックス）が存在するか
+	ProductSearchText bool
+	// orders.updated_at が存在するか
+	OrderUpdatedAt bool
+	// orders.public_id が存在するか（無い場合は注文IDとして order_id を返す）
+	OrderPublicID bool
+	// idempotency_keys テーブルが存在するか（無い場合は Idempotency-Key を無視する）
+	IdempotencyKeys bool
+	// order_summaries テーブルが存在するか（無い場合はダッシュボードが orders を直接集計する）
+	OrderSummaries bool
+	// outbox_relay_lease テーブルが存在するか（無い場合はリレーが実行権を取らずに配信する）
+	OutboxRelayLease bool
+}
+
+// information_schema を参照してスキーマの状態を検出する
+// 全文検索（FULLTEXT ... WITH PARSER ngram）は MySQL でのみ使用する
+func detectSchema(ctx context.Context, db DBTX, dialect Dialect) (SchemaCapabilities, error) {
+	var caps SchemaCapabilities
+
+	var n int
+	query, args := dialect.ColumnExistsQuery("products", "search_text")
+	if err := db.GetContext(ctx, &n, query, args...); err != nil {
+		return caps, err
+	}
+	caps.ProductSearchText = n > 0 && dialect.IsMySQL()
+
+	query, args = dialect.ColumnExistsQuery("orders", "updated_at")
+	if err := db.GetContext(ctx, &n, query, args...); err != nil {
+		return caps, err
+	}
+	caps.OrderUpdatedAt = n > 0
+
+	query, args = dialect.ColumnExistsQuery("orders", "public_id")
+	if err := db.GetContext(ctx, &n, query, args...); err != nil {
+		return caps, err
+	}
+	caps.OrderPublicID = n > 0
+
+	query, args = dialect.TableExistsQuery("idempotency_keys")
+	if err := db.GetContext(ctx, &n, query, args...); err != nil {
+		return caps, err
+	}
+	caps.IdempotencyKeys = n > 0
+
+	query, args = dialect.TableExistsQuery("order_summaries")
+	if err := db.GetContext(ctx, &n, query, args...); err != nil {
+		return caps, err
+	}
+	caps.OrderSummaries = n > 0
+
+	query, args = dialect.TableExistsQuery("outbox_relay_lease")
+	if err := db.GetContext(ctx, &n, query, args...); err != nil {
+		return caps, err
+	}
+	caps.OutboxRelayLease = n > 0
+
+	return caps, nil
+}
@@ -2,17 +2,32 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
 	"time"
 
+	"backend/internal/cache"
+	"backend/internal/coord"
+
 	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 )
 
 type SessionRepository struct {
-	db DBTX
+	db       DBTX
+	timeouts *QueryTimeouts
+	// nil の場合はキャッシュしない（Store 内の全リポジトリで共有する）
+	cache *cache.Cache[string, sessionEntry]
+	// 設定されている場合はセッションを Redis に保存する（Store.UseRedis を参照）
+	redis *coord.Client
 }
 
-func NewSessionRepository(db DBTX) *SessionRepository {
-	return &SessionRepository{db: db}
+func NewSessionRepository(db DBTX, timeouts *QueryTimeouts, sessions *cache.Cache[string, sessionEntry]) *SessionRepository {
+	return &SessionRepository{db: db, timeouts: timeouts, cache: sessions}
 }
 
 // セッションを作成し、セッションIDと有効期限を返す
@@ -24,26 +39,85 @@ func (r *SessionRepository) Create(ctx context.Context, userBusinessID int, dura
 	expiresAt := time.Now().Add(duration)
 	sessionIDStr := sessionUUID.String()
 
+	if r.redis != nil {
+		val := strconv.Itoa(userBusinessID) + ":" + strconv.FormatInt(expiresAt.UnixMilli(), 10)
+		if err := r.redis.Set(ctx, r.redis.Key("session:"+sessionIDStr), val, duration).Err(); err != nil {
+			return "", time.Time{}, fmt.Errorf("failed to store session: %w", err)
+		}
+		return sessionIDStr, expiresAt, nil
+	}
+
 	query := "INSERT INTO user_sessions (session_uuid, user_id, expires_at) VALUES (?, ?, ?)"
-	_, err = r.db.ExecContext(ctx, query, sessionIDStr, userBusinessID, expiresAt)
+	qctx, cancel := r.timeouts.write(ctx, "CreateSession")
+	defer cancel()
+	_, err = r.db.ExecContext(qctx, query, sessionIDStr, userBusinessID, expiresAt)
 	if err != nil {
-		return "", time.Time{}, err
+		return "", time.Time{}, qctx.wrap(err)
 	}
 	return sessionIDStr, expiresAt, nil
 }
 
 // セッションIDからユーザーIDを取得
+// 結果はキャッシュに短時間保持する（セッションの有効期限を過ぎては保持しない）
 func (r *SessionRepository) FindUserBySessionID(ctx context.Context, sessionID string) (int, error) {
-	var userID int
+	entry, err := r.cache.GetOrLoad(ctx, sessionID, func(ctx context.Context) (sessionEntry, error) {
+		return r.findUserBySessionID(ctx, sessionID)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if !time.Now().Before(entry.ExpiresAt) {
+		return 0, sql.ErrNoRows
+	}
+	return entry.UserID, nil
+}
+
+// Redis を使う場合も、Redis を使う前に作られたセッションや Redis の障害に備えて、Redis に無ければ DB から探す
+func (r *SessionRepository) findUserBySessionID(ctx context.Context, sessionID string) (sessionEntry, error) {
+	if r.redis != nil {
+		entry, err := r.findInRedis(ctx, sessionID)
+		if err == nil {
+			return entry, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			slog.WarnContext(ctx, "session lookup in redis failed; falling back to database", "err", err)
+		}
+	}
+
+	var entry sessionEntry
 	query := `
 		SELECT 
-			u.user_id
+			u.user_id,
+			s.expires_at
 		FROM users u
 		JOIN user_sessions s ON u.user_id = s.user_id
 		WHERE s.session_uuid = ? AND s.expires_at > ?`
-	err := r.db.GetContext(ctx, &userID, query, sessionID, time.Now())
+	qctx, cancel := r.timeouts.pointRead(ctx, "FindUserBySessionID")
+	defer cancel()
+	err := r.db.GetContext(qctx, &entry, query, sessionID, time.Now())
 	if err != nil {
-		return 0, err
+		return sessionEntry{}, qctx.wrap(err)
+	}
+	return entry, nil
+}
+
+// 値は "<ユーザーID>:<有効期限（UNIX ミリ秒）>"
+func (r *SessionRepository) findInRedis(ctx context.Context, sessionID string) (sessionEntry, error) {
+	val, err := r.redis.Get(ctx, r.redis.Key("session:"+sessionID)).Result()
+	if err != nil {
+		return sessionEntry{}, err
+	}
+	userID, expiresAt, ok := strings.Cut(val, ":")
+	if !ok {
+		return sessionEntry{}, fmt.Errorf("invalid session value %q", val)
+	}
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return sessionEntry{}, fmt.Errorf("invalid session value %q", val)
+	}
+	ms, err := strconv.ParseInt(expiresAt, 10, 64)
+	if err != nil {
+		return sessionEntry{}, fmt.Errorf("invalid session value %q", val)
 	}
-	return userID, nil
+	return sessionEntry{UserID: id, ExpiresAt: time.UnixMilli(ms)}, nil
 }
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"backend/internal/cache"
+	"backend/internal/config"
+	"backend/internal/coord"
+	"backend/internal/cursor"
+	"backend/internal/model"
+)
+
+const (
+	defaultUserCacheSize    = 10000
+	defaultSessionCacheSize = 10000
+)
+
+// storeShared は Store とトランザクション内の Store で共有する状態
+type storeShared struct {
+	// 以下のキャッシュは設定で無効な場合は nil
+
+	// FindByUserName の結果（nil は存在しないユーザー名）
+	// ログインは同じユーザー名で繰り返し行われるため、users への問い合わせを減らせる
+	// 存在しないユーザー名も「無い」ことを覚えておき、総当たりのログイン試行でDBが叩かれ続けないようにする
+	users *cache.Cache[string, *model.User]
+	// FindUserBySessionID の結果。認証が必要なリクエストごとに引かれるため短時間保持する
+	sessions *cache.Cache[string, sessionEntry]
+
+	// 書き込みによるエンティティの変更の通知
+	changes *cache.Bus
+	// UseRedis で設定する（nil の場合、セッションは DB に保存する）
+	redis *coord.Client
+	// 一覧のカーソルの署名
+	cursors *cursor.Codec
+	// UseFullTextSearch で設定する
+	fullTextSearch bool
+	// EnableOutbox で設定する（トランザクション内の Store のアウトボックスにも引き継ぐ）
+	outbox bool
+}
+
+type sessionEntry struct {
+	UserID    int       `db:"user_id"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// UserTTL（0 で無効）と UserNegativeTTL（0 で存在しないユーザー名は保持しない）、SessionTTL（0 で無効）で設定する
+func newStoreShared(cfg config.Cache, cursorKey string) *storeShared {
+	c := &storeShared{changes: cache.NewBus(), cursors: cursor.NewCodec([]byte(cursorKey))}
+	if cfg.UserTTL > 0 {
+		c.users = cache.New[string, *model.User]("user", cache.Options[*model.User]{
+			MaxSize: defaultUserCacheSize,
+			TTLFunc: func(u *model.User) time.Duration {
+				if u == nil {
+					return cfg.UserNegativeTTL
+				}
+				return cfg.UserTTL
+			},
+		})
+	}
+	if cfg.SessionTTL > 0 {
+		c.sessions = cache.New[string, sessionEntry]("session", cache.Options[sessionEntry]{
+			MaxSize: defaultSessionCacheSize,
+			// セッションの有効期限を過ぎて保持しない
+			TTLFunc: func(e sessionEntry) time.Duration {
+				return min(cfg.SessionTTL, time.Until(e.ExpiresAt))
+			},
+		})
+	}
+	if c.users != nil {
+		// パスワードの変更などで、変更前のユーザー情報を使わないようにする
+		c.changes.Subscribe(cache.EntityUser, func(_ context.Context, ch cache.Change) {
+			if userID, err := strconv.Atoi(ch.ID); err == nil {
+				c.forgetUser(userID)
+			}
+		})
+	}
+	return c
+}
+
+// forgetUser はユーザーのキャッシュを破棄する
+func (c *storeShared) forgetUser(userID int) {
+	c.users.DeleteFunc(func(_ string, u *model.User) bool {
+		return u != nil && u.UserID == userID
+	})
+}
+
+// UseFullTextSearch は商品の検索語を FULLTEXT インデックス（products.search_text）で絞り込む
+// 検索エンジンに MySQL を使う場合に、起動時、リクエストの受け付け前に呼ぶこと（件数・ファセット・エクスポートを一覧の検索とそろえる）
+func (s *Store) UseFullTextSearch() {
+	s.shared.fullTextSearch = true
+	if r, ok := s.ProductRepo.(*ProductRepository); ok {
+		r.fullText = true
+	}
+}
+
+// UseRedis は複数台のサーバーで状態を共有する。起動時、リクエストの受け付け前に呼ぶこと
+//   - セッションを Redis に保存する（Redis に無いセッションは DB から探す）
+//   - エンティティの変更（Changes）をほかのサーバーにも通知し、ほかのサーバーでの変更でもキャッシュを破棄する
+func (s *Store) UseRedis(client *coord.Client, invalidator *coord.Invalidator) {
+	s.shared.redis = client
+	if r, ok := s.SessionRepo.(*SessionRepository); ok {
+		r.redis = client
+	}
+	s.shared.changes.Forward(invalidator)
+}
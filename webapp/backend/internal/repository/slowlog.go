@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"backend/internal/config"
+	"backend/internal/model"
+	"context"
+	"database/sql"
+	"expvar"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// フィンガープリントごとのスロークエリ件数（/debug/vars で参照できる）
+var slowQueryCounts = expvar.NewMap("db_slow_queries")
+
+// 管理ダッシュボード向けに、件数に加えて所要時間も集計する
+var slowQueries = &slowQueryStats{stats: make(map[string]*model.SlowQueryStat)}
+
+// 集計するフィンガープリントの上限（超えた分は件数のみ expvar に数える）
+const maxSlowQueryFingerprints = 1000
+
+type slowQueryStats struct {
+	mu    sync.Mutex
+	stats map[string]*model.SlowQueryStat
+}
+
+func (s *slowQueryStats) add(fp string, elapsed time.Duration) {
+	ms := float64(elapsed) / float64(time.Millisecond)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.stats[fp]
+	if !ok {
+		if len(s.stats) >= maxSlowQueryFingerprints {
+			return
+		}
+		st = &model.SlowQueryStat{Query: fp}
+		s.stats[fp] = st
+	}
+	st.Count++
+	st.TotalMs += ms
+	st.MaxMs = max(st.MaxMs, ms)
+}
+
+// TopSlowQueries は起動してからのスロークエリを件数の多い順に最大 n 件返す
+func TopSlowQueries(n int) []model.SlowQueryStat {
+	slowQueries.mu.Lock()
+	list := make([]model.SlowQueryStat, 0, len(slowQueries.stats))
+	for _, st := range slowQueries.stats {
+		list = append(list, *st)
+	}
+	slowQueries.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].TotalMs > list[j].TotalMs
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// slowQueryLogger は閾値を超えたクエリをログに出す
+type slowQueryLogger struct {
+	threshold time.Duration
+}
+
+// SlowQueryThreshold が 0 の場合は無効
+func newSlowQueryLogger(cfg config.DB) *slowQueryLogger {
+	if cfg.SlowQueryThreshold <= 0 {
+		return nil
+	}
+	return &slowQueryLogger{threshold: cfg.SlowQueryThreshold}
+}
+
+func (l *slowQueryLogger) observe(ctx context.Context, query string, nargs int, start time.Time) {
+	elapsed := time.Since(start)
+	if elapsed < l.threshold {
+		return
+	}
+	fp := fingerprint(query)
+	slowQueryCounts.Add(fp, 1)
+	slowQueries.add(fp, elapsed)
+
+	slog.WarnContext(ctx, "slow query", "duration", elapsed.String(), "args", nargs, "sql", fp)
+}
+
+// slowQueryDB は全てのクエリの実行時間を計測する DBTX
+type slowQueryDB struct {
+	DBTX
+	logger *slowQueryLogger
+}
+
+// ロガーが無効（nil）の場合はそのまま返す
+func withSlowQueryLog(db DBTX, l *slowQueryLogger) DBTX {
+	if l == nil {
+		return db
+	}
+	return &slowQueryDB{DBTX: db, logger: l}
+}
+
+func (d *slowQueryDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	defer d.logger.observe(ctx, query, len(args), time.Now())
+	return d.DBTX.GetContext(ctx, dest, query, args...)
+}
+
+func (d *slowQueryDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	defer d.logger.observe(ctx, query, len(args), time.Now())
+	return d.DBTX.SelectContext(ctx, dest, query, args...)
+}
+
+func (d *slowQueryDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer d.logger.observe(ctx, query, len(args), time.Now())
+	return d.DBTX.ExecContext(ctx, query, args...)
+}
+
+// 行の読み出し時間は含まない（クエリの実行開始までを計測する）
+func (d *slowQueryDB) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	defer d.logger.observe(ctx, query, len(args), time.Now())
+	return d.DBTX.QueryxContext(ctx, query, args...)
+}
+
+func (d *slowQueryDB) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	defer d.logger.observe(ctx, query, 1, time.Now())
+	return d.DBTX.NamedExecContext(ctx, query, arg)
+}
+
+func (d *slowQueryDB) NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error) {
+	defer d.logger.observe(ctx, query, 1, time.Now())
+	return d.DBTX.NamedQueryContext(ctx, query, arg)
+}
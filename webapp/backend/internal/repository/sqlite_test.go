@@ -0,0 +1,272 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"backend/internal/cache"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+func TestSQLiteCartAddItemAccumulates(t *testing.T) {
+	store, conn := openSQLiteStore(t)
+	testCartAddItemAccumulates(t, store, conn)
+}
+
+func TestSQLiteBulkUpsertProducts(t *testing.T) {
+	store, _ := openSQLiteStore(t)
+	testBulkUpsertProducts(t, store)
+}
+
+// 在庫数を超える減算は ErrInsufficientStock になり、在庫管理の対象外の商品は nil を返す
+func TestDecrementStock(t *testing.T) {
+	store, _ := openSQLiteStore(t)
+	ctx := context.Background()
+	stock := 3
+	tracked := createProduct(t, store, &model.Product{Name: "tracked", Value: 100, Currency: "JPY", Weight: 1, Stock: &stock})
+	untracked := createProduct(t, store, &model.Product{Name: "untracked", Value: 100, Currency: "JPY", Weight: 1})
+
+	left, err := store.ProductRepo.DecrementStock(ctx, tracked, 2)
+	if err != nil || left == nil || *left != 1 {
+		t.Fatalf("DecrementStock = %v, %v, want 1", left, err)
+	}
+	if _, err := store.ProductRepo.DecrementStock(ctx, tracked, 2); !errors.Is(err, repository.ErrInsufficientStock) {
+		t.Fatalf("DecrementStock over stock err = %v, want ErrInsufficientStock", err)
+	}
+	p, err := store.ProductRepo.GetProduct(ctx, tracked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Stock == nil || *p.Stock != 1 {
+		t.Fatalf("stock after rejected decrement = %v, want 1", p.Stock)
+	}
+	if left, err := store.ProductRepo.DecrementStock(ctx, untracked, 5); err != nil || left != nil {
+		t.Fatalf("DecrementStock untracked = %v, %v, want nil, nil", left, err)
+	}
+}
+
+// 注文には公開IDが割り当てられ、条件付きのステータス更新は現在のステータスが一致する注文だけを更新する
+func TestOrderCreateAndConditionalUpdate(t *testing.T) {
+	store, conn := openSQLiteStore(t)
+	ctx := context.Background()
+	userID := createUser(t, conn, "order-user")
+	productID := createProduct(t, store, &model.Product{Name: "p", Value: 100, Currency: "JPY", Weight: 1})
+
+	order := &model.Order{UserID: userID, ProductID: productID}
+	if err := store.OrderRepo.Create(ctx, order); err != nil {
+		t.Fatal(err)
+	}
+	if order.OrderID == 0 || len(order.PublicID) != 26 {
+		t.Fatalf("created order = %d/%q, want an ID and a ULID", order.OrderID, order.PublicID)
+	}
+	id, owner, err := store.OrderRepo.FindOwner(ctx, model.OrderRef{PublicID: order.PublicID})
+	if err != nil || id != order.OrderID || owner != userID {
+		t.Fatalf("FindOwner = %d, %d, %v, want %d, %d", id, owner, err, order.OrderID, userID)
+	}
+
+	ids := []int64{order.OrderID}
+	n, err := store.OrderRepo.UpdateStatusesConditional(ctx, ids, model.StatusDelivering, model.StatusShipping)
+	if err != nil || n != 1 {
+		t.Fatalf("UpdateStatusesConditional = %d, %v, want 1", n, err)
+	}
+	n, err = store.OrderRepo.UpdateStatusesConditional(ctx, ids, model.StatusDelivering, model.StatusShipping)
+	if err != nil || n != 0 {
+		t.Fatalf("second UpdateStatusesConditional = %d, %v, want 0", n, err)
+	}
+	status, err := store.OrderRepo.GetStatus(ctx, order.OrderID)
+	if err != nil || status != model.StatusDelivering {
+		t.Fatalf("GetStatus = %q, %v, want delivering", status, err)
+	}
+}
+
+// 公開IDの無い注文は読んだ時点で乱数の ULID が割り当てられ、以降は同じ値を返す
+func TestOrderPublicIDAssignedOnRead(t *testing.T) {
+	store, conn := openSQLiteStore(t)
+	ctx := context.Background()
+	userID := createUser(t, conn, "public-id-user")
+	productID := createProduct(t, store, &model.Product{Name: "p", Value: 100, Currency: "JPY", Weight: 1})
+
+	var orderIDs []int64
+	for i := 0; i < 2; i++ {
+		order := &model.Order{UserID: userID, ProductID: productID}
+		if err := store.OrderRepo.Create(ctx, order); err != nil {
+			t.Fatal(err)
+		}
+		orderIDs = append(orderIDs, order.OrderID)
+	}
+	conn.MustExec("UPDATE orders SET public_id = NULL")
+
+	order, err := store.OrderRepo.FindByID(ctx, orderIDs[0])
+	if err != nil || len(order.PublicID) != 26 {
+		t.Fatalf("FindByID = %+v, %v, want a ULID", order, err)
+	}
+	ids, err := store.OrderRepo.PublicIDs(ctx, orderIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids[orderIDs[0]] != order.PublicID {
+		t.Fatalf("PublicIDs = %q, want %q", ids[orderIDs[0]], order.PublicID)
+	}
+	if ids[orderIDs[1]] == "" || ids[orderIDs[1]] == order.PublicID {
+		t.Fatalf("PublicIDs = %v, want distinct ULIDs", ids)
+	}
+
+	// 割り当て済みの注文はバックフィルの対象にならない
+	n, err := store.OrderRepo.AssignPublicIDs(ctx, 10)
+	if err != nil || n != 0 {
+		t.Fatalf("AssignPublicIDs = %d, %v, want 0", n, err)
+	}
+}
+
+// レビューの再集計は商品の変更として通知される（商品のキャッシュを破棄させる）
+func TestRefreshProductRatingRecordsChange(t *testing.T) {
+	store, _ := openSQLiteStore(t)
+	ctx := context.Background()
+	productID := createProduct(t, store, &model.Product{Name: "p", Value: 100, Currency: "JPY", Weight: 1})
+
+	var changed []string
+	store.Changes().Subscribe(cache.EntityProduct, func(_ context.Context, c cache.Change) {
+		changed = append(changed, c.ID)
+	})
+	if err := store.ReviewRepo.RefreshProductRating(ctx, productID); err != nil {
+		t.Fatal(err)
+	}
+	if want := strconv.Itoa(productID); len(changed) != 1 || changed[0] != want {
+		t.Fatalf("changes = %v, want [%s]", changed, want)
+	}
+}
+
+// ExecTx の関数がエラーを返した場合は書き込みが残らない
+func TestExecTxRollsBack(t *testing.T) {
+	store, _ := openSQLiteStore(t)
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	var productID int
+	err := store.ExecTx(ctx, func(txStore *repository.Store) error {
+		var err error
+		productID, err = txStore.ProductRepo.CreateProduct(ctx, &model.Product{Name: "p", Value: 100, Currency: "JPY", Weight: 1})
+		if err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("ExecTx err = %v, want errAbort", err)
+	}
+	if _, err := store.ProductRepo.GetProduct(ctx, productID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetProduct after rollback err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+// お気に入りの追加は冪等で、削除すると一覧から消える
+func TestFavoritesAddRemove(t *testing.T) {
+	store, conn := openSQLiteStore(t)
+	ctx := context.Background()
+	userID := createUser(t, conn, "fav-user")
+	productID := createProduct(t, store, &model.Product{Name: "p", Value: 100, Currency: "JPY", Weight: 1})
+
+	for i := 0; i < 2; i++ {
+		if err := store.FavoriteRepo.Add(ctx, userID, productID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := store.FavoriteRepo.CountByUser(ctx, userID); err != nil || n != 1 {
+		t.Fatalf("CountByUser = %d, %v, want 1", n, err)
+	}
+	if err := store.FavoriteRepo.Remove(ctx, userID, productID); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := store.FavoriteRepo.CountByUser(ctx, userID); err != nil || n != 0 {
+		t.Fatalf("CountByUser after Remove = %d, %v, want 0", n, err)
+	}
+}
+
+// 作成したセッションからユーザーを引け、期限切れのセッションは見つからない
+func TestSessionLookup(t *testing.T) {
+	store, conn := openSQLiteStore(t)
+	ctx := context.Background()
+	userID := createUser(t, conn, "session-user")
+
+	sessionID, _, err := store.SessionRepo.Create(ctx, userID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := store.SessionRepo.FindUserBySessionID(ctx, sessionID); err != nil || got != userID {
+		t.Fatalf("FindUserBySessionID = %d, %v, want %d", got, err, userID)
+	}
+
+	expired, _, err := store.SessionRepo.Create(ctx, userID, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.SessionRepo.FindUserBySessionID(ctx, expired); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("FindUserBySessionID(expired) err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+// 商品一覧の部分一致検索と件数
+func TestListProductsSearch(t *testing.T) {
+	store, _ := openSQLiteStore(t)
+	ctx := context.Background()
+	for _, name := range []string{"Red Apple", "Green Apple", "Banana"} {
+		createProduct(t, store, &model.Product{Name: name, Value: 100, Currency: "JPY", Weight: 1})
+	}
+
+	req := model.ListRequest{Search: "apple", Type: "partial", PageSize: 10, SortField: "product_id", SortOrder: "asc"}
+	products, err := store.ProductRepo.ListProducts(ctx, 0, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 2 || products[0].Name != "Red Apple" || products[1].Name != "Green Apple" {
+		t.Fatalf("ListProducts = %+v, want the two apples", products)
+	}
+	if n, err := store.ProductRepo.CountProducts(ctx, 0, req); err != nil || n != 2 {
+		t.Fatalf("CountProducts = %d, %v, want 2", n, err)
+	}
+}
+
+// 商品を作成し、商品IDを返す
+func createProduct(t *testing.T, store *repository.Store, p *model.Product) int {
+	t.Helper()
+	id, err := store.ProductRepo.CreateProduct(context.Background(), p)
+	if err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	return id
+}
+
+// リレーのリースは期限内なら1つの持ち主だけが持て、手放すと他の持ち主が取得できる
+func TestOutboxRelayLease(t *testing.T) {
+	store, _ := openSQLiteStore(t)
+	ctx := context.Background()
+	repo := store.OutboxRepo
+
+	for _, tc := range []struct {
+		owner string
+		want  bool
+	}{{"a", true}, {"b", false}, {"a", true}} {
+		held, err := repo.AcquireRelayLease(ctx, tc.owner, time.Minute)
+		if err != nil || held != tc.want {
+			t.Fatalf("AcquireRelayLease(%s) = %v, %v, want %v", tc.owner, held, err, tc.want)
+		}
+	}
+	if err := repo.ReleaseRelayLease(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := repo.AcquireRelayLease(ctx, "b", time.Minute); err != nil || !held {
+		t.Fatalf("AcquireRelayLease(b) after release = %v, %v, want true", held, err)
+	}
+	// 期限切れのリースは他の持ち主が引き継ぐ
+	if _, err := repo.AcquireRelayLease(ctx, "b", -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := repo.AcquireRelayLease(ctx, "a", time.Minute); err != nil || !held {
+		t.Fatalf("AcquireRelayLease(a) after expiry = %v, %v, want true", held, err)
+	}
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// キャッシュするステートメント数の上限
+// MySQL はコネクションごとにステートメントを準備するため、max_prepared_stmt_count を超えないよう抑える
+const maxCachedStmts = 128
+
+// プレースホルダーがこれより多いクエリは準備しない
+// IN 句の展開や複数行の INSERT は件数ごとに文字列が変わり、ほぼ使い回されないため
+const maxCachedPlaceholders = 64
+
+// stmtCache はSQL文字列をキーに Prepared Statement を遅延生成して使い回す
+// 上限に達した場合は最も長く使われていないものを閉じて入れ替える（LRU）
+type stmtCache struct {
+	db *sqlx.DB
+
+	mu    sync.Mutex
+	stmts map[string]*list.Element
+	// 先頭ほど最近使われたもの
+	lru *list.List
+}
+
+type cachedStmt struct {
+	query string
+	stmt  *sqlx.Stmt
+	// 実行中の呼び出しの数。追い出されても 0 になるまでは閉じない
+	refs    int
+	evicted bool
+}
+
+// 生成したキャッシュは Store.Close で閉じられるよう registry に登録する
+func newStmtCache(db *sqlx.DB, registry *closerRegistry) *stmtCache {
+	c := &stmtCache{db: db, stmts: make(map[string]*list.Element), lru: list.New()}
+	registry.register(c)
+	return c
+}
+
+// get はステートメントと、使い終わった際に呼ぶ release を返す
+// 準備しないクエリ（プレースホルダーが多いもの）の場合は nil を返す
+func (c *stmtCache) get(ctx context.Context, query string) (*sqlx.Stmt, func(), error) {
+	// PostgreSQL では Rebind 済みの $1, $2, ... の形で渡される
+	if strings.Count(query, "?")+strings.Count(query, "$") > maxCachedPlaceholders {
+		return nil, nil, nil
+	}
+
+	c.mu.Lock()
+	if elem, ok := c.stmts[query]; ok {
+		c.lru.MoveToFront(elem)
+		entry := elem.Value.(*cachedStmt)
+		entry.refs++
+		c.mu.Unlock()
+		return entry.stmt, func() { c.release(entry) }, nil
+	}
+	c.mu.Unlock()
+
+	// 準備はロックの外で行い、同じクエリを同時に準備した場合は先に登録されたものを使う
+	stmt, err := c.db.PreparexContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.stmts[query]; ok {
+		_ = stmt.Close()
+		c.lru.MoveToFront(elem)
+		entry := elem.Value.(*cachedStmt)
+		entry.refs++
+		return entry.stmt, func() { c.release(entry) }, nil
+	}
+	entry := &cachedStmt{query: query, stmt: stmt, refs: 1}
+	c.stmts[query] = c.lru.PushFront(entry)
+	for c.lru.Len() > maxCachedStmts {
+		c.evictLocked(c.lru.Back())
+	}
+	return stmt, func() { c.release(entry) }, nil
+}
+
+func (c *stmtCache) release(entry *cachedStmt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.refs--
+	if entry.evicted && entry.refs == 0 {
+		_ = entry.stmt.Close()
+	}
+}
+
+// evictLocked は elem をキャッシュから外し、使用中でなければ閉じる
+func (c *stmtCache) evictLocked(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cachedStmt)
+	delete(c.stmts, entry.query)
+	entry.evicted = true
+	if entry.refs == 0 {
+		_ = entry.stmt.Close()
+	}
+}
+
+// Close はキャッシュしている全てのステートメントを閉じる
+func (c *stmtCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	for elem := c.lru.Front(); elem != nil; elem = c.lru.Front() {
+		entry := c.lru.Remove(elem).(*cachedStmt)
+		delete(c.stmts, entry.query)
+		entry.evicted = true
+		if err := entry.stmt.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stmtCacheDB はトランザクション外のクエリをキャッシュ済みステートメントで実行する DBTX
+// トランザクション内のクエリはキャッシュを使わない（Tx.Stmt はステートメントをトランザクションの接続で準備し直すため、毎回準備するのと変わらない）
+type stmtCacheDB struct {
+	*sqlx.DB
+	cache *stmtCache
+}
+
+func (d *stmtCacheDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	stmt, release, err := d.cache.get(ctx, query)
+	if err != nil || stmt == nil {
+		return d.DB.GetContext(ctx, dest, query, args...)
+	}
+	defer release()
+	return stmt.GetContext(ctx, dest, args...)
+}
+
+func (d *stmtCacheDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	stmt, release, err := d.cache.get(ctx, query)
+	if err != nil || stmt == nil {
+		return d.DB.SelectContext(ctx, dest, query, args...)
+	}
+	defer release()
+	return stmt.SelectContext(ctx, dest, args...)
+}
+
+func (d *stmtCacheDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	stmt, release, err := d.cache.get(ctx, query)
+	if err != nil || stmt == nil {
+		return d.DB.ExecContext(ctx, query, args...)
+	}
+	defer release()
+	return stmt.ExecContext(ctx, args...)
+}
+
+func (d *stmtCacheDB) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	stmt, release, err := d.cache.get(ctx, query)
+	if err != nil || stmt == nil {
+		return d.DB.QueryxContext(ctx, query, args...)
+	}
+	defer release()
+	return stmt.QueryxContext(ctx, args...)
+}
+
+// txDB はトランザクションの DBTX
+type txDB struct {
+	*sqlx.Tx
+}
+
+// *sqlx.Tx には NamedQueryContext が無いため、sqlx のパッケージ関数で補う
+func (t *txDB) NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error) {
+	return sqlx.NamedQueryContext(ctx, t.Tx, query, arg)
+}
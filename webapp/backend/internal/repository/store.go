@@ -1,61 +1,233 @@
 package repository
 
 import (
+	"backend/internal/config"
 	"context"
 
 	"github.com/jmoiron/sqlx"
 )
 
 type Store struct {
-	db          DBTX
-	UserRepo    *UserRepository
-	SessionRepo *SessionRepository
-	ProductRepo *ProductRepository
-	OrderRepo   *OrderRepository
+	db DBTX
+	// トランザクションを開始するための接続（トランザクション内の Store では nil）
+	conn      *sqlx.DB
+	stmts     *stmtCache
+	closers   *closerRegistry
+	router    *replicaRouter
+	breaker   *circuitBreaker
+	slowLog   *slowQueryLogger
+	metrics   *queryMetrics
+	explainer *explainer
+	dialect   Dialect
+	schema    *SchemaCapabilities
+	timeouts  *QueryTimeouts
+	shared    *storeShared
+	changes   *changeLog
+	// STORAGE=memory の場合のみ設定する（NewMemoryStore を参照）
+	memory        *memoryConn
+	UserRepo      Users
+	SessionRepo   Sessions
+	ProductRepo   Products
+	OrderRepo     Orders
+	CategoryRepo  Categories
+	ReviewRepo    Reviews
+	FavoriteRepo  Favorites
+	CartRepo      Carts
+	RecommendRepo Recommendations
+	PriceHistRepo PriceHistory
+	OutboxRepo    Outbox
+	IdemRepo      IdempotencyKeys
+	SummaryRepo   Summaries
+	NotifyRepo    Notifications
 }
 
-func NewStore(db DBTX) *Store {
+// クエリごとのタイムアウトやキャッシュは cfg から設定する
+// *sqlx.DB を渡した場合は、全リポジトリのクエリで Prepared Statement を共有する
+// replicas を渡すと、トランザクション外で AllowReplica を指定した読み取りはレプリカに振り分けられる
+// （それ以外の読み取り、書き込みとトランザクションは常にプライマリ）
+func NewStore(db DBTX, cfg *config.Config, replicas ...*sqlx.DB) *Store {
+	conn, ok := db.(*sqlx.DB)
+	if !ok {
+		return newStore(db, &SchemaCapabilities{}, NewQueryTimeouts(cfg.DB), mysqlDialect{}, newStoreShared(cfg.Cache, cfg.Server.CursorKey))
+	}
+	dialect := DialectFor(conn.DriverName())
+	closers := &closerRegistry{}
+	stmts := newStmtCache(conn, closers)
+	// プライマリへの呼び出しは連続して失敗するとサーキットブレーカーで遮断する
+	breaker := newCircuitBreaker(cfg.DB)
+	var primary DBTX = withBreaker(withRebind(&stmtCacheDB{DB: conn, cache: stmts}, dialect), breaker)
+
+	var router *replicaRouter
+	if len(replicas) > 0 {
+		router = &replicaRouter{DBTX: primary, maxLag: cfg.DB.ReplicaMaxLag}
+		for _, rc := range replicas {
+			// レプリカへの接続は Store が開いたものではないが、所有権を受け取って Close で閉じる
+			closers.register(rc)
+			router.replicas = append(router.replicas, &replica{
+				db:   &stmtCacheDB{DB: rc, cache: newStmtCache(rc, closers)},
+				conn: rc,
+			})
+		}
+		primary = router
+	}
+
+	slowLog := newSlowQueryLogger(cfg.DB)
+	metrics := newQueryMetrics(cfg.DB)
+	var explainer *explainer
+	if dialect.IsMySQL() {
+		explainer = newExplainer(conn, cfg.DB)
+	}
+	s := newStore(withExplain(withQueryMetrics(withSlowQueryLog(primary, slowLog), metrics), explainer), &SchemaCapabilities{}, NewQueryTimeouts(cfg.DB), dialect, newStoreShared(cfg.Cache, cfg.Server.CursorKey))
+	s.conn = conn
+	s.stmts = stmts
+	s.closers = closers
+	s.router = router
+	s.breaker = breaker
+	s.slowLog = slowLog
+	s.metrics = metrics
+	s.explainer = explainer
+	return s
+}
+
+// RunReplicaHealthCheck はレプリカの死活監視を ctx がキャンセルされるまで続ける（レプリカが無い場合はすぐに戻る）
+func (s *Store) RunReplicaHealthCheck(ctx context.Context) {
+	if s.router != nil {
+		s.router.runHealthCheck(ctx)
+	}
+}
+
+func newStore(db DBTX, schema *SchemaCapabilities, timeouts *QueryTimeouts, dialect Dialect, shared *storeShared) *Store {
+	changes := &changeLog{bus: shared.changes}
+	users := NewUserRepository(db, timeouts, shared.users)
+	users.changes = changes
+	sessions := NewSessionRepository(db, timeouts, shared.sessions)
+	sessions.redis = shared.redis
+	products := NewProductRepository(db, schema, timeouts, dialect)
+	products.changes = changes
+	products.cursors = shared.cursors
+	products.fullText = shared.fullTextSearch
+	orders := NewOrderRepository(db, schema, timeouts, dialect)
+	orders.changes = changes
+	reviews := NewReviewRepository(db, timeouts, dialect)
+	reviews.changes = changes
+	favorites := NewFavoriteRepository(db, timeouts, dialect)
+	favorites.changes = changes
+	outbox := NewOutboxRepository(db, schema, timeouts)
+	outbox.enabled = shared.outbox
 	return &Store{
-		db:          db,
-		UserRepo:    NewUserRepository(db),
-		SessionRepo: NewSessionRepository(db),
-		ProductRepo: NewProductRepository(db),
-		OrderRepo:   NewOrderRepository(db),
+		db:            db,
+		schema:        schema,
+		timeouts:      timeouts,
+		dialect:       dialect,
+		shared:        shared,
+		changes:       changes,
+		UserRepo:      users,
+		SessionRepo:   sessions,
+		ProductRepo:   products,
+		OrderRepo:     orders,
+		CategoryRepo:  NewCategoryRepository(db, timeouts),
+		ReviewRepo:    reviews,
+		FavoriteRepo:  favorites,
+		CartRepo:      NewCartRepository(db, timeouts, dialect),
+		RecommendRepo: NewRecommendationRepository(db, timeouts, dialect),
+		PriceHistRepo: NewPriceHistoryRepository(db, timeouts),
+		OutboxRepo:    outbox,
+		IdemRepo:      NewIdempotencyRepository(db, schema, timeouts, dialect),
+		SummaryRepo:   NewSummaryRepository(db, schema, timeouts, dialect),
+		NotifyRepo:    NewNotificationRepository(db, schema, timeouts, dialect),
 	}
 }
 
+// トランザクションはプライマリで実行するため、同じ ctx での以後の読み取りもプライマリで行う
 func (s *Store) ExecTx(ctx context.Context, fn func(txStore *Store) error) error {
-	db, ok := s.db.(*sqlx.DB)
-	if !ok {
+	if s.memory != nil {
+		return s.memory.execTx(ctx, s, fn)
+	}
+	if s.conn == nil {
 		return fn(s)
 	}
+	markWritten(ctx)
 
-	tx, err := db.BeginTxx(ctx, nil)
+	if s.breaker != nil {
+		if err := s.breaker.allow(); err != nil {
+			return err
+		}
+	}
+	tx, err := s.conn.BeginTxx(ctx, nil)
+	if s.breaker != nil {
+		s.breaker.record(ctx, err)
+	}
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	txStore := NewStore(tx)
+	txConn := withBreaker(withRebind(&txDB{Tx: tx}, s.dialect), s.breaker)
+	txStore := newStore(withExplain(withQueryMetrics(withSlowQueryLog(txConn, s.slowLog), s.metrics), s.explainer), s.schema, s.timeouts, s.dialect, s.shared)
+	txStore.breaker = s.breaker
+	txStore.slowLog = s.slowLog
+	txStore.metrics = s.metrics
+	txStore.explainer = s.explainer
+	// 変更の通知はコミットの後に行う
+	txStore.changes.deferred = true
 	if err := fn(txStore); err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	txStore.changes.flush(ctx)
+	return nil
+}
+
+// EnableOutbox はアウトボックスへの記録を有効にする
+// リレーを起動する場合に、リクエストの処理を始める前に呼ぶこと
+func (s *Store) EnableOutbox() {
+	s.shared.outbox = true
+	switch r := s.OutboxRepo.(type) {
+	case *OutboxRepository:
+		r.enabled = true
+	case *memoryOutbox:
+		r.enabled = true
+	}
 }
 
-// Close closes all prepared statements in repositories
-func (s *Store) Close() error {
-	var errs []error
-	if err := s.UserRepo.Close(); err != nil {
-		errs = append(errs, err)
+// OutboxEnabled はアウトボックスへの記録が有効か
+func (s *Store) OutboxEnabled() bool {
+	return s.shared.outbox
+}
+
+// DetectSchema は起動時に一度だけスキーマの状態を検出し、リポジトリが使うクエリの形を確定させる
+// STORAGE=memory の場合は全ての機能が使える状態（全文検索を除く）とする
+func (s *Store) DetectSchema(ctx context.Context) error {
+	if s.memory != nil {
+		*s.schema = memorySchema
+		return nil
 	}
-	// 他のRepositoryにもPrepared Statementを追加した場合はここに追加
-	// if err := s.SessionRepo.Close(); err != nil {
-	//     errs = append(errs, err)
-	// }
-	if len(errs) > 0 {
-		return errs[0] // 最初のエラーを返す（簡易実装）
+	caps, err := detectSchema(ctx, s.db, s.dialect)
+	if err != nil {
+		return err
 	}
+	*s.schema = caps
 	return nil
 }
+
+// Dialect は接続先データベースの Dialect を返す
+func (s *Store) Dialect() Dialect {
+	return s.dialect
+}
+
+// Schema は検出済みのスキーマの状態を返す
+func (s *Store) Schema() SchemaCapabilities {
+	return *s.schema
+}
+
+// Close は登録された資源（Prepared Statement のキャッシュ、レプリカへの接続）を全て閉じる
+// トランザクション内の Store では何もしない
+func (s *Store) Close() error {
+	if s.closers == nil {
+		return nil
+	}
+	return s.closers.Close()
+}
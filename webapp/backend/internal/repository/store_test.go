@@ -0,0 +1,129 @@
+package repository_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"backend/internal/config"
+	"backend/internal/db"
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// テスト用の Store を開く（テストの終了時に閉じる）
+// sqlite の場合は一時ディレクトリに空のデータベースを作る。postgres の場合は url のデータベースにスキーマを作る
+func openStore(t *testing.T, driver, url string) (*repository.Store, *sqlx.DB) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.DB.Driver = driver
+	cfg.DB.URL = url
+	if driver == "sqlite" {
+		cfg.DB.URL = filepath.Join(t.TempDir(), "test.db")
+	}
+
+	conn, err := db.InitDBConnection(cfg.DB)
+	if err != nil {
+		t.Fatalf("open %s: %v", driver, err)
+	}
+	store := repository.NewStore(conn, cfg)
+	t.Cleanup(func() {
+		store.Close()
+		conn.Close()
+	})
+	if err := store.DetectSchema(context.Background()); err != nil {
+		t.Fatalf("detect schema: %v", err)
+	}
+	return store, conn
+}
+
+// 空の SQLite データベースの Store を開く
+func openSQLiteStore(t *testing.T) (*repository.Store, *sqlx.DB) {
+	t.Helper()
+	return openStore(t, "sqlite", "")
+}
+
+// ユーザーを作成し、ユーザーIDを返す
+func createUser(t *testing.T, conn *sqlx.DB, name string) int {
+	t.Helper()
+	var id int
+	query := conn.Rebind("INSERT INTO users (password_hash, user_name) VALUES (?, ?) RETURNING user_id")
+	if err := conn.Get(&id, query, "hash", name); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return id
+}
+
+// 以下は SQLite と PostgreSQL で共通のテストケース
+
+// 同じ商品を2回追加すると数量が加算される
+func testCartAddItemAccumulates(t *testing.T, store *repository.Store, conn *sqlx.DB) {
+	ctx := context.Background()
+	userID := createUser(t, conn, "cart-user")
+	productID, err := store.ProductRepo.CreateProduct(ctx, &model.Product{Name: "apple", Value: 100, Currency: "JPY", Weight: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, q := range []int{2, 3} {
+		if err := store.CartRepo.AddItem(ctx, userID, productID, q); err != nil {
+			t.Fatalf("AddItem(%d): %v", q, err)
+		}
+	}
+	items, err := store.CartRepo.ListItems(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Quantity != 5 {
+		t.Fatalf("cart = %+v, want one item with quantity 5", items)
+	}
+}
+
+// 新規の行は自動採番され、商品IDを指定した行は既存商品を更新する
+// 明示したIDで挿入した後も、自動採番のIDが衝突しない
+func testBulkUpsertProducts(t *testing.T, store *repository.Store) {
+	ctx := context.Background()
+	inserted, updated, err := store.ProductRepo.BulkUpsertProducts(ctx, []model.Product{
+		{Name: "a", Value: 100, Currency: "JPY", Weight: 1},
+		{Name: "b", Value: 200, Currency: "JPY", Weight: 2},
+		{ProductID: 10, Name: "c", Value: 300, Currency: "JPY", Weight: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted != 3 || updated != 0 {
+		t.Fatalf("inserted, updated = %d, %d, want 3, 0", inserted, updated)
+	}
+
+	// 値が変わらない行も更新として数える
+	inserted, updated, err = store.ProductRepo.BulkUpsertProducts(ctx, []model.Product{
+		{ProductID: 2, Name: "b", Value: 200, Currency: "JPY", Weight: 2},
+		{ProductID: 1, Name: "a2", Value: 150, Currency: "JPY", Weight: 1},
+		{ProductID: 10, Name: "c2", Value: 350, Currency: "JPY", Weight: 3},
+		{ProductID: 10, Name: "c3", Value: 400, Currency: "JPY", Weight: 3},
+		{Name: "d", Value: 500, Currency: "JPY", Weight: 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted != 1 || updated != 4 {
+		t.Fatalf("inserted, updated = %d, %d, want 1, 4", inserted, updated)
+	}
+	p, err := store.ProductRepo.GetProduct(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "c3" || p.Value != 400 {
+		t.Fatalf("product 10 = %s/%d, want c3/400", p.Name, p.Value)
+	}
+
+	id, err := store.ProductRepo.CreateProduct(ctx, &model.Product{Name: "e", Value: 600, Currency: "JPY", Weight: 5})
+	if err != nil {
+		t.Fatalf("CreateProduct after explicit IDs: %v", err)
+	}
+	if id <= 10 {
+		t.Fatalf("CreateProduct id = %d, want > 10", id)
+	}
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 集計のバケットを表す文字列の形式（Dialect.HourBucket）
+const bucketLayout = "2006-01-02 15:04:05"
+
+// SummaryRepository は注文の時間・日ごとの集計（order_summaries）を読み書きする
+// order_summaries が無いスキーマでは使えないため、呼び出し側で Enabled を確認すること
+type SummaryRepository struct {
+	db       DBTX
+	schema   *SchemaCapabilities
+	timeouts *QueryTimeouts
+	dialect  Dialect
+}
+
+func NewSummaryRepository(db DBTX, schema *SchemaCapabilities, timeouts *QueryTimeouts, dialect Dialect) *SummaryRepository {
+	return &SummaryRepository{db: db, schema: schema, timeouts: timeouts, dialect: dialect}
+}
+
+// Enabled は order_summaries が存在するか
+func (r *SummaryRepository) Enabled() bool {
+	return r.schema.OrderSummaries
+}
+
+// LatestBucket は period の集計のうち最も新しいバケットの開始日時（集計が無い場合は ok=false）
+func (r *SummaryRepository) LatestBucket(ctx context.Context, period string) (t time.Time, ok bool, err error) {
+	qctx, cancel := r.timeouts.pointRead(ctx, "LatestSummaryBucket")
+	defer cancel()
+	// MAX() では SQLite のドライバが日時として読めないため、インデックス順に1件取得する
+	err = r.db.GetContext(qctx, &t, "SELECT bucket_start FROM order_summaries WHERE period = ? ORDER BY bucket_start DESC LIMIT 1", period)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, qctx.wrap(err)
+	}
+	return t.UTC(), true, nil
+}
+
+// EarliestOrder は最も古い注文の作成日時（注文が無い場合は ok=false）
+func (r *SummaryRepository) EarliestOrder(ctx context.Context) (t time.Time, ok bool, err error) {
+	qctx, cancel := r.timeouts.pointRead(ctx, "EarliestOrder")
+	defer cancel()
+	err = r.db.GetContext(qctx, &t, "SELECT created_at FROM orders ORDER BY created_at LIMIT 1")
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, qctx.wrap(err)
+	}
+	return t.UTC(), true, nil
+}
+
+// AggregateHours は orders から [from, to) の時間ごとの集計を求める（注文の無い時間は含まない）
+func (r *SummaryRepository) AggregateHours(ctx context.Context, from, to time.Time) ([]model.OrderSummary, error) {
+	qctx, cancel := r.timeouts.listRead(ctx, "AggregateOrderHours")
+	defer cancel()
+	// SQLite は日時を文字列で比較するため、タイムゾーンを付けずに orders と同じ形式で渡す（ちょうど正時の注文を取りこぼさない）
+	lower, upper := from.UTC().Format(bucketLayout), to.UTC().Format(bucketLayout)
+
+	var created []struct {
+		Bucket string `db:"bucket"`
+		Count  int    `db:"cnt"`
+		Value  int64  `db:"total_value"`
+	}
+	// 通貨の異なる金額は合算できないため、value_created は DefaultCurrency の商品のみを集計する
+	query := "SELECT " + r.dialect.HourBucket("o.created_at") + " AS bucket, COUNT(*) AS cnt, COALESCE(SUM(CASE WHEN p.currency = ? THEN p.value ELSE 0 END), 0) AS total_value" +
+		" FROM orders o JOIN products p ON p.product_id = o.product_id" +
+		" WHERE o.created_at >= ? AND o.created_at < ? GROUP BY bucket"
+	if err := r.db.SelectContext(qctx, &created, query, model.DefaultCurrency, lower, upper); err != nil {
+		return nil, qctx.wrap(err)
+	}
+
+	var delivered []struct {
+		Bucket  string          `db:"bucket"`
+		Count   int             `db:"cnt"`
+		Seconds sql.NullFloat64 `db:"total_seconds"`
+	}
+	query = "SELECT " + r.dialect.HourBucket("arrived_at") + " AS bucket, COUNT(*) AS cnt, SUM(" + r.dialect.SecondsBetween("created_at", "arrived_at") + ") AS total_seconds" +
+		" FROM orders WHERE shipped_status = 'completed' AND arrived_at >= ? AND arrived_at < ? GROUP BY bucket"
+	if err := r.db.SelectContext(qctx, &delivered, query, lower, upper); err != nil {
+		return nil, qctx.wrap(err)
+	}
+
+	byBucket := make(map[time.Time]*model.OrderSummary)
+	get := func(bucket string) (*model.OrderSummary, error) {
+		t, err := time.ParseInLocation(bucketLayout, bucket, time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected summary bucket %q: %w", bucket, err)
+		}
+		s, ok := byBucket[t]
+		if !ok {
+			s = &model.OrderSummary{Period: model.SummaryPeriodHour, BucketStart: t}
+			byBucket[t] = s
+		}
+		return s, nil
+	}
+	for _, row := range created {
+		s, err := get(row.Bucket)
+		if err != nil {
+			return nil, err
+		}
+		s.OrdersCreated = row.Count
+		s.ValueCreated = row.Value
+	}
+	for _, row := range delivered {
+		s, err := get(row.Bucket)
+		if err != nil {
+			return nil, err
+		}
+		s.OrdersDelivered = row.Count
+		s.DeliverySeconds = row.Seconds.Float64
+	}
+
+	summaries := make([]model.OrderSummary, 0, len(byBucket))
+	for _, s := range byBucket {
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].BucketStart.Before(summaries[j].BucketStart) })
+	return summaries, nil
+}
+
+// Replace は period の [from, to) の集計を summaries で置き換える（トランザクション内の Store から呼ぶこと）
+// 注文が無くなった期間の行も残らないよう、範囲内の行を削除してから書き込む
+func (r *SummaryRepository) Replace(ctx context.Context, period string, from, to time.Time, summaries []model.OrderSummary) error {
+	qctx, cancel := r.timeouts.write(ctx, "ReplaceOrderSummaries")
+	defer cancel()
+	if _, err := r.db.ExecContext(qctx, "DELETE FROM order_summaries WHERE period = ? AND bucket_start >= ? AND bucket_start < ?", period, from.UTC(), to.UTC()); err != nil {
+		return qctx.wrap(err)
+	}
+	if len(summaries) == 0 {
+		return nil
+	}
+	query := "INSERT INTO order_summaries (period, bucket_start, orders_created, value_created, orders_delivered, delivery_seconds)" +
+		" VALUES (:period, :bucket_start, :orders_created, :value_created, :orders_delivered, :delivery_seconds)"
+	rows := make([]map[string]interface{}, len(summaries))
+	for i, s := range summaries {
+		rows[i] = map[string]interface{}{
+			"period":           period,
+			"bucket_start":     s.BucketStart.UTC(),
+			"orders_created":   s.OrdersCreated,
+			"value_created":    s.ValueCreated,
+			"orders_delivered": s.OrdersDelivered,
+			"delivery_seconds": s.DeliverySeconds,
+		}
+	}
+	if _, err := r.db.NamedExecContext(qctx, query, rows); err != nil {
+		return qctx.wrap(err)
+	}
+	return nil
+}
+
+// List は period の [from, to) の集計を古い順に返す
+func (r *SummaryRepository) List(ctx context.Context, period string, from, to time.Time) ([]model.OrderSummary, error) {
+	qctx, cancel := r.timeouts.listRead(ctx, "ListOrderSummaries")
+	defer cancel()
+	summaries := []model.OrderSummary{}
+	query := `
+		SELECT period, bucket_start, orders_created, value_created, orders_delivered, delivery_seconds
+		FROM order_summaries
+		WHERE period = ? AND bucket_start >= ? AND bucket_start < ?
+		ORDER BY bucket_start`
+	if err := r.db.SelectContext(qctx, &summaries, query, period, from.UTC(), to.UTC()); err != nil {
+		return nil, qctx.wrap(err)
+	}
+	for i := range summaries {
+		summaries[i].BucketStart = summaries[i].BucketStart.UTC()
+	}
+	return summaries, nil
+}
+
+// DeliveryTotals は日ごとの集計から、配送完了した注文の総数と配送完了までの時間の合計を求める
+func (r *SummaryRepository) DeliveryTotals(ctx context.Context) (delivered int, seconds float64, err error) {
+	qctx, cancel := r.timeouts.listRead(ctx, "SummaryDeliveryTotals")
+	defer cancel()
+	var row struct {
+		Delivered sql.NullInt64   `db:"delivered"`
+		Seconds   sql.NullFloat64 `db:"total_seconds"`
+	}
+	query := "SELECT SUM(orders_delivered) AS delivered, SUM(delivery_seconds) AS total_seconds FROM order_summaries WHERE period = ?"
+	if err := r.db.GetContext(qctx, &row, query, model.SummaryPeriodDay); err != nil {
+		return 0, 0, qctx.wrap(err)
+	}
+	return int(row.Delivered.Int64), row.Seconds.Float64, nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"backend/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrQueryTimeout はクエリごとのタイムアウトを超えたことを表す
+var ErrQueryTimeout = errors.New("query timed out")
+
+// QueryTimeoutError はタイムアウトしたクエリの種類と設定値を保持する
+// errors.Is(err, ErrQueryTimeout) で判定できる
+type QueryTimeoutError struct {
+	Op      string
+	Timeout time.Duration
+}
+
+func (e *QueryTimeoutError) Error() string {
+	return fmt.Sprintf("%s: query timed out after %s", e.Op, e.Timeout)
+}
+
+func (e *QueryTimeoutError) Is(target error) bool {
+	return target == ErrQueryTimeout
+}
+
+// QueryTimeouts はクエリの種類ごとのタイムアウト（0 の場合はタイムアウトなし）
+type QueryTimeouts struct {
+	// 主キーなどによる1件取得
+	PointRead time.Duration
+	// 一覧・件数の取得
+	ListRead time.Duration
+	// 配送計画や共起の再計算のための全件読み取り
+	PlanRead time.Duration
+	// 更新系
+	Write time.Duration
+}
+
+func NewQueryTimeouts(cfg config.DB) *QueryTimeouts {
+	return &QueryTimeouts{
+		PointRead: cfg.PointReadTimeout,
+		ListRead:  cfg.ListReadTimeout,
+		PlanRead:  cfg.PlanReadTimeout,
+		Write:     cfg.WriteTimeoutQ,
+	}
+}
+
+// queryContext は1回のクエリ用のデッドラインを設定した context を返す
+type queryContext struct {
+	context.Context
+	// 呼び出し元の context（デッドラインを設定する前）
+	parent  context.Context
+	op      string
+	timeout time.Duration
+}
+
+func withQueryTimeout(ctx context.Context, op string, timeout time.Duration) (*queryContext, context.CancelFunc) {
+	if timeout <= 0 {
+		return &queryContext{Context: ctx, parent: ctx, op: op}, func() {}
+	}
+	c, cancel := context.WithTimeout(ctx, timeout)
+	return &queryContext{Context: c, parent: ctx, op: op, timeout: timeout}, cancel
+}
+
+// queryTimedOut は ctx がクエリごとのタイムアウトで終了したか（呼び出し元はまだ待っている）を返す
+// 呼び出し元のキャンセル・デッドラインで終了した場合や、クエリごとのタイムアウトが無い場合は false
+func queryTimedOut(ctx context.Context) bool {
+	c, ok := ctx.(*queryContext)
+	return ok && c.timeout > 0 && errors.Is(c.Err(), context.DeadlineExceeded) && c.parent.Err() == nil
+}
+
+// err がデッドライン超過によるものであれば QueryTimeoutError に変換する
+// ドライバはタイムアウト時に context のエラー以外を返すことがあるため、context 側の状態で判定する
+func (c *queryContext) wrap(err error) error {
+	if err == nil || !errors.Is(c.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return &QueryTimeoutError{Op: c.op, Timeout: c.timeout}
+}
+
+func (t *QueryTimeouts) pointRead(ctx context.Context, op string) (*queryContext, context.CancelFunc) {
+	return withQueryTimeout(ctx, op, t.PointRead)
+}
+
+func (t *QueryTimeouts) listRead(ctx context.Context, op string) (*queryContext, context.CancelFunc) {
+	return withQueryTimeout(ctx, op, t.ListRead)
+}
+
+func (t *QueryTimeouts) planRead(ctx context.Context, op string) (*queryContext, context.CancelFunc) {
+	return withQueryTimeout(ctx, op, t.PlanRead)
+}
+
+func (t *QueryTimeouts) write(ctx context.Context, op string) (*queryContext, context.CancelFunc) {
+	return withQueryTimeout(ctx, op, t.Write)
+}
@@ -5,66 +5,98 @@ import (
 	"database/sql"
 	"errors"
 
+	"backend/internal/cache"
 	"backend/internal/model"
-	"github.com/jmoiron/sqlx"
 )
 
 type UserRepository struct {
-	db                DBTX
-	findByUserNameStmt *sqlx.Stmt
+	db       DBTX
+	timeouts *QueryTimeouts
+	// nil の場合はキャッシュしない（Store 内の全リポジトリで共有する）
+	cache *cache.Cache[string, *model.User]
+	// 書き込みをキャッシュへ通知する（Store が設定する）
+	changes *changeLog
 }
 
-func NewUserRepository(db DBTX) *UserRepository {
-	ur := &UserRepository{db: db}
-	// Try to prepare statement if we have a *sqlx.DB
-	if d, ok := db.(*sqlx.DB); ok {
-		if stmt, err := d.Preparex("SELECT user_id, password_hash, user_name FROM users WHERE user_name = ?"); err == nil {
-			ur.findByUserNameStmt = stmt
-		}
-	}
-	return ur
-}
-
-// Close closes prepared statements
-func (r *UserRepository) Close() error {
-	if r.findByUserNameStmt != nil {
-		return r.findByUserNameStmt.Close()
-	}
-	return nil
+func NewUserRepository(db DBTX, timeouts *QueryTimeouts, users *cache.Cache[string, *model.User]) *UserRepository {
+	return &UserRepository{db: db, timeouts: timeouts, cache: users}
 }
 
 // ユーザー名からユーザー情報を取得
 // ログイン時に使用
+// 結果はキャッシュに短時間保持し、存在しない場合も sql.ErrNoRows をキャッシュから返す
 func (r *UserRepository) FindByUserName(ctx context.Context, userName string) (*model.User, error) {
-	var user model.User
-
-	// Prepared statement が利用可能な場合は使用
-	if r.findByUserNameStmt != nil {
-		err := r.findByUserNameStmt.GetContext(ctx, &user, userName)
-		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				return nil, err
-			}
-			return nil, err
-		}
-		return &user, nil
+	user, err := r.cache.GetOrLoad(ctx, userName, func(ctx context.Context) (*model.User, error) {
+		return r.findByUserName(ctx, userName)
+	})
+	if err != nil {
+		return nil, err
 	}
+	if user == nil {
+		return nil, sql.ErrNoRows
+	}
+	// 呼び出し側が書き換えてもキャッシュが汚れないよう、コピーを返す
+	u := *user
+	return &u, nil
+}
 
-	// フォールバック: 通常のクエリ実行
+// 存在しない場合は (nil, nil) を返す（キャッシュに「無い」ことを保持するため）
+func (r *UserRepository) findByUserName(ctx context.Context, userName string) (*model.User, error) {
+	var user model.User
 	query := "SELECT user_id, password_hash, user_name FROM users WHERE user_name = ?"
-	err := r.db.GetContext(ctx, &user, query, userName)
-	if err != nil {
+	qctx, cancel := r.timeouts.pointRead(ctx, "FindByUserName")
+	defer cancel()
+	if err := r.db.GetContext(qctx, &user, query, userName); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, err
+			return nil, nil
 		}
-		return nil, err
+		return nil, qctx.wrap(err)
 	}
 	return &user, nil
 }
 
 // UpdatePasswordHash パスワードハッシュを更新
+// 変更前のハッシュでログインできないよう、キャッシュからも取り除く（ほかのサーバーのキャッシュも含む）
 func (r *UserRepository) UpdatePasswordHash(ctx context.Context, userID int, passwordHash string) error {
 	query := "UPDATE users SET password_hash = ? WHERE user_id = ?"
-	_, err := r.db.ExecContext(ctx, query, passwordHash, userID)
-	return err
+	if _, err := r.db.ExecContext(ctx, query, passwordHash, userID); err != nil {
+		return err
+	}
+	r.changes.record(ctx, cache.EntityUser, int64(userID))
+	return nil
+}
+
+// ユーザーのロールを取得
+func (r *UserRepository) FindRoleByID(ctx context.Context, userID int) (string, error) {
+	var role string
+	query := "SELECT role FROM users WHERE user_id = ?"
+	qctx, cancel := r.timeouts.pointRead(ctx, "FindRoleByID")
+	defer cancel()
+	if err := r.db.GetContext(qctx, &role, query, userID); err != nil {
+		return "", qctx.wrap(err)
+	}
+	return role, nil
+}
+
+// ユーザーのタイムゾーン（IANA のタイムゾーン名）を取得
+func (r *UserRepository) FindTimezoneByID(ctx context.Context, userID int) (string, error) {
+	var tz string
+	query := "SELECT timezone FROM users WHERE user_id = ?"
+	qctx, cancel := r.timeouts.pointRead(ctx, "FindTimezoneByID")
+	defer cancel()
+	if err := r.db.GetContext(qctx, &tz, query, userID); err != nil {
+		return "", qctx.wrap(err)
+	}
+	return tz, nil
+}
+
+// ユーザーのタイムゾーンを更新
+func (r *UserRepository) UpdateTimezone(ctx context.Context, userID int, tz string) error {
+	qctx, cancel := r.timeouts.write(ctx, "UpdateTimezone")
+	defer cancel()
+	if _, err := r.db.ExecContext(qctx, "UPDATE users SET timezone = ? WHERE user_id = ?", tz, userID); err != nil {
+		return qctx.wrap(err)
+	}
+	r.changes.record(ctx, cache.EntityUser, int64(userID))
+	return nil
 }
@@ -0,0 +1,168 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/model"
+	"backend/internal/telemetry"
+)
+
+// MeilisearchIndex は Meilisearch の HTTP API を使う実装
+// タイポ許容・関連度順の検索が可能
+type MeilisearchIndex struct {
+	host   string
+	apiKey string
+	index  string
+	client *http.Client
+}
+
+func NewMeilisearchIndex(host, apiKey, index string) *MeilisearchIndex {
+	return &MeilisearchIndex{
+		host:   strings.TrimRight(host, "/"),
+		apiKey: apiKey,
+		index:  index,
+		client: &http.Client{Timeout: 5 * time.Second, Transport: telemetry.Transport(nil)},
+	}
+}
+
+// 検索エンジン側のソート可能属性（products のソートホワイトリストと合わせる）
+var meiliSortFields = map[string]bool{
+	"product_id": true,
+	"name":       true,
+	"value":      true,
+	"weight":     true,
+}
+
+type meiliDocument struct {
+	ProductID   int    `json:"product_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Value       int    `json:"value"`
+	Weight      int    `json:"weight"`
+	CategoryID  *int   `json:"category_id"`
+}
+
+func (m *MeilisearchIndex) Search(ctx context.Context, req model.ListRequest) ([]Hit, int, error) {
+	body := map[string]interface{}{
+		"q":                    req.Search,
+		"offset":               req.Offset,
+		"limit":                req.PageSize,
+		"attributesToRetrieve": []string{"product_id"},
+		"showRankingScore":     true,
+	}
+	if req.Category > 0 {
+		body["filter"] = fmt.Sprintf("category_id = %d", req.Category)
+	}
+	// relevance またはソート指定がない場合は Meilisearch の関連度順
+	if meiliSortFields[req.SortField] {
+		order := "asc"
+		if strings.EqualFold(req.SortOrder, "desc") {
+			order = "desc"
+		}
+		body["sort"] = []string{req.SortField + ":" + order}
+	}
+
+	var resp struct {
+		Hits []struct {
+			ProductID    int     `json:"product_id"`
+			RankingScore float64 `json:"_rankingScore"`
+		} `json:"hits"`
+		EstimatedTotalHits int `json:"estimatedTotalHits"`
+	}
+	if err := m.do(ctx, http.MethodPost, "/indexes/"+m.index+"/search", body, &resp); err != nil {
+		return nil, 0, err
+	}
+
+	hits := make([]Hit, len(resp.Hits))
+	for i, h := range resp.Hits {
+		hits[i] = Hit{ProductID: h.ProductID, Score: h.RankingScore}
+	}
+	return hits, resp.EstimatedTotalHits, nil
+}
+
+func (m *MeilisearchIndex) Index(ctx context.Context, p model.Product) error {
+	return m.IndexBatch(ctx, []model.Product{p})
+}
+
+func newMeiliDocument(p model.Product) meiliDocument {
+	return meiliDocument{
+		ProductID:   p.ProductID,
+		Name:        p.Name,
+		Description: p.Description,
+		Value:       p.Value,
+		Weight:      p.Weight,
+		CategoryID:  p.CategoryID,
+	}
+}
+
+// IndexBatch は商品をまとめて登録する（同じ product_id の文書は置き換える）
+func (m *MeilisearchIndex) IndexBatch(ctx context.Context, products []model.Product) error {
+	docs := make([]meiliDocument, len(products))
+	for i, p := range products {
+		docs[i] = newMeiliDocument(p)
+	}
+	return m.do(ctx, http.MethodPost, "/indexes/"+m.index+"/documents?primaryKey=product_id", docs, nil)
+}
+
+// Configure は検索で使う絞り込み・ソートの属性を索引に設定する
+// 設定されていない属性で filter / sort を指定すると Meilisearch はエラーを返すため、起動時に呼び出す
+func (m *MeilisearchIndex) Configure(ctx context.Context) error {
+	sortable := make([]string, 0, len(meiliSortFields))
+	for field := range meiliSortFields {
+		sortable = append(sortable, field)
+	}
+	sort.Strings(sortable)
+	settings := map[string]interface{}{
+		"filterableAttributes": []string{"category_id"},
+		"sortableAttributes":   sortable,
+	}
+	return m.do(ctx, http.MethodPatch, "/indexes/"+m.index+"/settings", settings, nil)
+}
+
+func (m *MeilisearchIndex) Delete(ctx context.Context, productID int) error {
+	return m.do(ctx, http.MethodDelete, "/indexes/"+m.index+"/documents/"+strconv.Itoa(productID), nil, nil)
+}
+
+func (m *MeilisearchIndex) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, m.host+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	}
+
+	res, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("meilisearch: %s %s: %d %s", method, path, res.StatusCode, msg)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
@@ -0,0 +1,188 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/workerpool"
+)
+
+// Hit は検索結果の1件（商品IDと関連度スコア）
+type Hit struct {
+	ProductID int
+	Score     float64
+}
+
+// SearchIndex は商品の全文検索を行う検索エンジンの抽象
+// Search はページング済みの検索結果と、条件に一致する総件数を返す
+// sort_field が relevance の場合は関連度順に並べる
+type SearchIndex interface {
+	Search(ctx context.Context, req model.ListRequest) ([]Hit, int, error)
+	Index(ctx context.Context, product model.Product) error
+	Delete(ctx context.Context, productID int) error
+}
+
+// cfg.Backend から検索エンジンを選択する
+//   - 未設定: nil（リポジトリの LIKE 検索を使用）
+//   - mysql: MySQL の FULLTEXT(ngram) インデックス
+//   - meilisearch: Meilisearch（MeilisearchURL, MeilisearchAPIKey, MeilisearchIndex）
+func New(cfg config.Search, productRepo repository.Products, schema repository.SchemaCapabilities) SearchIndex {
+	switch strings.ToLower(cfg.Backend) {
+	case "mysql":
+		if !schema.ProductSearchText {
+			slog.Warn("search: products.search_text is missing; falling back to LIKE search")
+			return nil
+		}
+		return NewMySQLIndex(productRepo)
+	case "meilisearch":
+		return NewMeilisearchIndex(cfg.MeilisearchURL, cfg.MeilisearchAPIKey, cfg.MeilisearchIndex)
+	}
+	return nil
+}
+
+// MySQLIndex は products.search_text の FULLTEXT インデックスを使う実装
+// search_text はリポジトリが書き込み時に更新するため、Index/Delete は何もしない
+type MySQLIndex struct {
+	repo repository.Products
+}
+
+func NewMySQLIndex(repo repository.Products) *MySQLIndex {
+	return &MySQLIndex{repo: repo}
+}
+
+func (m *MySQLIndex) Search(ctx context.Context, req model.ListRequest) ([]Hit, int, error) {
+	rows, total, err := m.repo.SearchProducts(ctx, req)
+	if err != nil {
+		return nil, 0, err
+	}
+	hits := make([]Hit, len(rows))
+	for i, row := range rows {
+		hits[i] = Hit{ProductID: row.ProductID, Score: row.Score}
+	}
+	return hits, total, nil
+}
+
+func (m *MySQLIndex) Index(ctx context.Context, product model.Product) error { return nil }
+
+func (m *MySQLIndex) Delete(ctx context.Context, productID int) error { return nil }
+
+// ExternalIndex は DB の外に索引を持つ検索エンジン（Meilisearch）
+// 商品の書き込みは Indexer で反映し、起動時に Configure で索引を設定してから既存の商品を IndexBatch で登録する
+type ExternalIndex interface {
+	SearchIndex
+	Configure(ctx context.Context) error
+	IndexBatch(ctx context.Context, products []model.Product) error
+}
+
+// ProductSource は索引付けのために商品を取得する
+type ProductSource interface {
+	GetProduct(ctx context.Context, productID int) (*model.Product, error)
+	StreamProducts(ctx context.Context, req model.ListRequest, fn func(*model.Product) error) error
+}
+
+// 全件の再索引で1回に登録する商品数
+const reindexBatchSize = 500
+
+// Indexer は商品の書き込みを検索エンジンに反映する
+// 反映はリクエストの外でワーカーが行い、検索エンジンの遅延や障害が書き込みの応答に影響しないようにする
+type Indexer struct {
+	index    ExternalIndex
+	products ProductSource
+	pool     *workerpool.Pool
+}
+
+// NewIndexer は反映用のワーカーを起動する。Run を呼び出すこと
+func NewIndexer(index ExternalIndex, products ProductSource, drainTimeout time.Duration) *Indexer {
+	return &Indexer{
+		index:    index,
+		products: products,
+		// 反映の順序が入れ替わらないよう1件ずつ処理する
+		pool: workerpool.New("search_indexer", workerpool.Options{Workers: 1, QueueSize: 1024, DrainTimeout: drainTimeout}),
+	}
+}
+
+// Run は索引を設定して全件を登録してから、ctx がキャンセルされるまで反映を続ける
+// 設定・登録に失敗しても反映は続ける（次の起動か一括の変更で再索引される）
+func (i *Indexer) Run(ctx context.Context) {
+	if err := i.index.Configure(ctx); err != nil {
+		slog.ErrorContext(ctx, "search: failed to configure the index; filtered and sorted searches may fail", "err", err)
+	}
+	if err := i.submit(ctx, i.Reindex); err != nil {
+		slog.ErrorContext(ctx, "search: failed to schedule the initial reindex", "err", err)
+	}
+	i.pool.Run(ctx)
+}
+
+// Sync は商品IDの最新状態の反映を予約する（削除済みの場合は索引から削除）
+// productID が 0（一括インポートなど対象を特定できない変更）の場合は全件を再索引する
+// ProductService.OnProductChanged に登録して使用する
+func (i *Indexer) Sync(ctx context.Context, productID int) {
+	fn := func(ctx context.Context) error { return i.sync(ctx, productID) }
+	if productID == 0 {
+		fn = i.Reindex
+	}
+	if err := i.submit(ctx, fn); err != nil {
+		slog.WarnContext(ctx, "search: dropped product sync", "product_id", productID, "err", err)
+	}
+}
+
+func (i *Indexer) submit(ctx context.Context, fn func(ctx context.Context) error) error {
+	return i.pool.Submit(ctx, func(ctx context.Context) {
+		if err := fn(ctx); err != nil {
+			slog.ErrorContext(ctx, "search: failed to sync the index", "err", err)
+		}
+	})
+}
+
+func (i *Indexer) sync(ctx context.Context, productID int) error {
+	product, err := i.products.GetProduct(ctx, productID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return i.index.Delete(ctx, productID)
+	}
+	if err != nil {
+		return fmt.Errorf("product %d: %w", productID, err)
+	}
+	return i.index.Index(ctx, *product)
+}
+
+// Reindex は全ての商品を reindexBatchSize 件ずつ登録し直す
+// 削除済みの商品の文書は残るが、検索結果は DB から読み直すため一覧には出ない
+func (i *Indexer) Reindex(ctx context.Context) error {
+	batch := make([]model.Product, 0, reindexBatchSize)
+	total := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := i.index.IndexBatch(ctx, batch); err != nil {
+			return err
+		}
+		total += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	req := model.ListRequest{SortField: "product_id", SortOrder: "asc"}
+	err := i.products.StreamProducts(ctx, req, func(p *model.Product) error {
+		batch = append(batch, *p)
+		if len(batch) < reindexBatchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return fmt.Errorf("reindex: %w", err)
+	}
+	slog.InfoContext(ctx, "search: reindexed products", "count", total)
+	return nil
+}
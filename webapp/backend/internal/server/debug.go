@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"backend/internal/logging"
+	"backend/internal/telemetry"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var errDebugAddr = errors.New("DEBUG_ADDR must be a loopback address")
+
+// debugRoutes は pprof と expvar（/debug/pprof/*, /debug/vars）のルーター
+// pprof.Index はパスの /debug/pprof/ 以降でプロファイルを選ぶため、/debug にマウントすること
+func debugRoutes() http.Handler {
+	r := chi.NewRouter()
+	r.HandleFunc("/pprof/", pprof.Index)
+	r.HandleFunc("/pprof/cmdline", pprof.Cmdline)
+	r.HandleFunc("/pprof/profile", pprof.Profile)
+	r.HandleFunc("/pprof/symbol", pprof.Symbol)
+	r.HandleFunc("/pprof/trace", pprof.Trace)
+	r.Handle("/pprof/{profile}", http.HandlerFunc(pprof.Index))
+	r.Handle("/vars", expvar.Handler())
+	// OTEL_METRICS_EXPORTER=prometheus の場合のみ
+	if h := telemetry.PrometheusHandler(); h != nil {
+		r.Handle("/metrics", h)
+	}
+	return r
+}
+
+// checkDebugAddr は DEBUG_ADDR（127.0.0.1:6060 など）を検証して返す
+// 認証なしで公開されるため、ループバック以外のアドレスはエラーにする
+func checkDebugAddr(addr string) (string, error) {
+	if addr == "" {
+		return "", nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errDebugAddr, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return "", fmt.Errorf("%w: %q", errDebugAddr, addr)
+	}
+	return addr, nil
+}
+
+// runDebugListener は ctx がキャンセルされるまで addr で /debug/ を認証なしで公開する
+func runDebugListener(ctx context.Context, addr string) {
+	mux := chi.NewRouter()
+	mux.Mount("/debug", debugRoutes())
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          logging.StdLogger(slog.LevelWarn),
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	slog.Info("serving debug endpoints", "addr", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("debug listener failed", "err", err)
+	}
+}
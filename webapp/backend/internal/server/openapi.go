@@ -0,0 +1,220 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"backend/internal/apierror"
+	"backend/internal/apiversion"
+	"backend/internal/handler"
+	"backend/internal/model"
+	"backend/internal/openapi"
+	"backend/internal/realtime"
+	"backend/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// API の各ルートの説明。setupRoutes にルートを追加したらここにも追加すること
+// （追加し忘れたルートも "(undocumented)" としてドキュメントには載る）
+func apiOperations() []openapi.Operation {
+	const (
+		session = openapi.SecuritySession
+		robot   = openapi.SecurityRobotKey
+	)
+	pageParams := []openapi.Param{
+		{Name: "page", Type: 0, Description: "1始まりのページ番号"},
+		{Name: "page_size", Type: 0, Description: "1ページの件数"},
+	}
+	noContent := map[int]interface{}{http.StatusNoContent: nil}
+
+	ops := []openapi.Operation{
+		{Method: "POST", Path: "/api/login", Tag: "auth", Summary: "ログインしてセッションCookieを発行する",
+			Request: model.LoginRequest{}, Responses: map[int]interface{}{http.StatusOK: handler.MessageResponse{}, http.StatusUnauthorized: apierror.Response{}}},
+
+		{Method: "POST", Path: "/graphql", Tag: "graphql", Security: session, Summary: "GraphQL（商品・注文・配送計画。スキーマは internal/graph/schema.graphqls）",
+			Responses: map[int]interface{}{http.StatusOK: ""}},
+
+		{Method: "GET", Path: "/api/categories", Tag: "products", Security: session, Summary: "カテゴリ一覧",
+			Responses: map[int]interface{}{http.StatusOK: []model.Category{}}},
+		{Method: "GET", Path: "/api/favorites", Tag: "favorites", Security: session, Summary: "お気に入りの商品一覧",
+			Query: pageParams, Responses: map[int]interface{}{http.StatusOK: handler.ListResponse[handler.ProductResponse]{}}},
+
+		{Method: "GET", Path: "/api/products/suggest", Tag: "products", Security: session, Summary: "商品名の入力補完",
+			Query:     []openapi.Param{{Name: "q", Description: "入力中の文字列"}, {Name: "limit", Type: 0, Description: "候補の最大件数"}},
+			Responses: map[int]interface{}{http.StatusOK: handler.SuggestResponse{}}},
+		{Method: "GET", Path: "/api/products/{productID}/image", Tag: "products", Security: session, Summary: "商品画像を取得する",
+			Query:               []openapi.Param{{Name: "size", Description: "thumb / detail / original"}},
+			ResponseContentType: "image/*", Responses: map[int]interface{}{http.StatusOK: "", http.StatusNotFound: apierror.Response{}}},
+		{Method: "GET", Path: "/api/products/{productID}/related", Tag: "products", Security: session, Summary: "一緒に注文されている商品",
+			Responses: map[int]interface{}{http.StatusOK: handler.DataResponse[handler.ProductResponse]{}}},
+		{Method: "GET", Path: "/api/products/{productID}/price-history", Tag: "products", Security: session, Summary: "価格の変更履歴",
+			Responses: map[int]interface{}{http.StatusOK: handler.DataResponse[model.PriceHistory]{}}},
+		{Method: "GET", Path: "/api/products/{productID}/reviews", Tag: "reviews", Security: session, Summary: "レビュー一覧",
+			Query: pageParams, Responses: map[int]interface{}{http.StatusOK: handler.ListResponse[model.Review]{}}},
+		{Method: "POST", Path: "/api/products/{productID}/reviews", Tag: "reviews", Security: session, Summary: "レビューを投稿する",
+			Request: model.CreateReviewRequest{}, Responses: map[int]interface{}{http.StatusCreated: model.Review{}, http.StatusNotFound: apierror.Response{}}},
+		{Method: "POST", Path: "/api/products/{productID}/favorite", Tag: "favorites", Security: session, Summary: "お気に入りに追加する",
+			Responses: noContent},
+		{Method: "DELETE", Path: "/api/products/{productID}/favorite", Tag: "favorites", Security: session, Summary: "お気に入りから外す",
+			Responses: noContent},
+
+		{Method: "GET", Path: "/api/cart/", Tag: "cart", Security: session, Summary: "カートの内容",
+			Description: "通貨の異なる商品が含まれる場合は合計を求められないため 409 を返す",
+			Responses:   map[int]interface{}{http.StatusOK: model.Cart{}, http.StatusConflict: apierror.Response{}}},
+		{Method: "POST", Path: "/api/cart/items", Tag: "cart", Security: session, Summary: "カートに商品を追加する",
+			Request: model.RequestItem{}, Responses: noContent},
+		{Method: "PUT", Path: "/api/cart/items/{productID}", Tag: "cart", Security: session, Summary: "カート内の数量を変更する",
+			Request: model.UpdateCartItemRequest{}, Responses: noContent},
+		{Method: "DELETE", Path: "/api/cart/items/{productID}", Tag: "cart", Security: session, Summary: "カートから商品を削除する",
+			Responses: noContent},
+		{Method: "POST", Path: "/api/cart/checkout", Tag: "cart", Security: session, Summary: "カートの商品を注文する",
+			Description: "Idempotency-Key ヘッダーを付けると、同じキーの再送には処理をやり直さずに最初のレスポンスを返す",
+			Responses:   map[int]interface{}{http.StatusCreated: handler.OrdersCreatedResponse{}, http.StatusConflict: apierror.Response{}}},
+
+		{Method: "GET", Path: "/api/users/me/notifications", Tag: "users", Security: session, Summary: "自分の通知設定",
+			Description: "未設定の場合は何も通知しない設定を返す",
+			Responses:   map[int]interface{}{http.StatusOK: model.NotificationPreferences{}}},
+		{Method: "PUT", Path: "/api/users/me/notifications", Tag: "users", Security: session, Summary: "自分の通知設定を更新する",
+			Description: "channels は log / webhook / email。email を選ぶ場合は email が必要。サーバーで有効になっていない（NOTIFY_CHANNELS に無い）チャネルには送らない",
+			Request:     model.NotificationPreferences{}, Responses: map[int]interface{}{http.StatusOK: model.NotificationPreferences{}, http.StatusBadRequest: apierror.Response{}}},
+		{Method: "GET", Path: "/api/users/me/preferences", Tag: "users", Security: session, Summary: "自分の表示設定",
+			Responses: map[int]interface{}{http.StatusOK: model.UserPreferences{}}},
+		{Method: "PUT", Path: "/api/users/me/preferences", Tag: "users", Security: session, Summary: "自分の表示設定を更新する",
+			Description: "timezone は IANA のタイムゾーン名（例: Asia/Tokyo）。DB の日時は UTC のまま保持し、注文一覧のレスポンスで変換する",
+			Request:     model.UserPreferences{}, Responses: map[int]interface{}{http.StatusOK: model.UserPreferences{}, http.StatusBadRequest: apierror.Response{}}},
+
+		{Method: "POST", Path: "/api/admin/products", Tag: "admin", Security: session, Summary: "商品を作成する（管理者）",
+			Description: "Idempotency-Key ヘッダーを付けると、同じキーの再送には処理をやり直さずに最初のレスポンスを返す",
+			Request:     model.ProductInput{}, Responses: map[int]interface{}{http.StatusCreated: handler.ProductResponse{}}},
+		{Method: "POST", Path: "/api/admin/products/import", Tag: "admin", Security: session, Summary: "CSVから商品を一括登録する（管理者）",
+			Description: "multipart/form-data の file フィールド、またはボディそのものをCSVとして受け付ける",
+			Responses:   map[int]interface{}{http.StatusOK: model.ImportReport{}}},
+		{Method: "GET", Path: "/api/admin/products/low-stock", Tag: "admin", Security: session, Summary: "在庫が少ない商品（管理者）",
+			Responses: map[int]interface{}{http.StatusOK: handler.DataResponse[model.StockLevel]{}}},
+		{Method: "PUT", Path: "/api/admin/products/{productID}", Tag: "admin", Security: session, Summary: "商品を更新する（管理者）",
+			Request: model.ProductInput{}, Responses: map[int]interface{}{http.StatusOK: handler.ProductResponse{}, http.StatusNotFound: apierror.Response{}}},
+		{Method: "DELETE", Path: "/api/admin/products/{productID}", Tag: "admin", Security: session, Summary: "商品を削除する（管理者）",
+			Responses: map[int]interface{}{http.StatusNoContent: nil, http.StatusNotFound: apierror.Response{}}},
+
+		{Method: "GET", Path: "/api/admin/overview", Tag: "admin", Security: session, Summary: "運用ダッシュボードの概要（管理者）",
+			Description: "稼働中のロボットとスロークエリは、応答したインスタンスが起動してから観測したもののみ",
+			Responses:   map[int]interface{}{http.StatusOK: model.AdminOverview{}}},
+		{Method: "GET", Path: "/api/admin/stats/orders", Tag: "admin", Security: session, Summary: "時間・日ごとの注文の集計（管理者）",
+			Description: "定期的に更新する集計を返すため、直近の注文は ORDER_SUMMARY_INTERVAL だけ遅れて反映される。集計が無効な場合や起動後の最初の集計が終わるまでは 503",
+			Query: []openapi.Param{{Name: "period", Description: "hour / day（省略時は hour）"},
+				{Name: "since", Description: "RFC 3339 の日時（省略時は hour で24時間前、day で30日前。hour は7日、day は366日より前は切り詰める）"}},
+			Responses: map[int]interface{}{http.StatusOK: handler.DataResponse[model.OrderSummary]{}, http.StatusServiceUnavailable: apierror.Response{}}},
+		{Method: "GET", Path: "/api/admin/reports", Tag: "admin", Security: session, Summary: "書き出し済みの日次レポートの一覧（管理者）",
+			Description: "毎日 REPORT_HOUR 時（UTC）に前日分を JSON と CSV で書き出す。REPORT_DIR が未設定の場合は 503",
+			Responses:   map[int]interface{}{http.StatusOK: handler.DataResponse[model.ReportFile]{}, http.StatusServiceUnavailable: apierror.Response{}}},
+		{Method: "GET", Path: "/api/admin/reports/{name}", Tag: "admin", Security: session, Summary: "日次レポートを取得する（管理者）",
+			Description: "name は一覧の name（YYYY-MM-DD.json / YYYY-MM-DD.csv）。JSON の形は model.DailyReport",
+			Responses:   map[int]interface{}{http.StatusOK: model.DailyReport{}, http.StatusNotFound: apierror.Response{}}},
+		{Method: "GET", Path: "/api/admin/maintenance", Tag: "admin", Security: session, Summary: "メンテナンス状態を取得する（管理者）",
+			Responses: map[int]interface{}{http.StatusOK: model.MaintenanceState{}}},
+		{Method: "PUT", Path: "/api/admin/maintenance", Tag: "admin", Security: session, Summary: "読み取り専用モードを切り替える（管理者）",
+			Description: "読み取り専用モードの間、書き込み系の API は 503 と Retry-After を返す",
+			Request:     model.MaintenanceState{}, Responses: map[int]interface{}{http.StatusOK: model.MaintenanceState{}}},
+		{Method: "GET", Path: "/api/admin/ws", Tag: "admin", Security: session, Summary: "注文の状態変更とロボットの位置を WebSocket で受け取る（管理者）",
+			Description: "メッセージは realtime.Message の JSON。送信が追いつかない接続は 1013 で切断されるため、再接続すること",
+			Query:       []openapi.Param{{Name: "topics", Description: "orders / robots のカンマ区切り（省略時はすべて）"}},
+			Responses:   map[int]interface{}{http.StatusSwitchingProtocols: realtime.Message{}, http.StatusServiceUnavailable: apierror.Response{}}},
+
+		{Method: "GET", Path: "/api/robot/delivery-plan", Tag: "robot", Security: robot, Summary: "配送計画を作成し、対象の注文を配送中にする",
+			Query:     []openapi.Param{{Name: "capacity", Type: 0, Required: true, Description: "積載可能な重量（1以上）"}},
+			Responses: map[int]interface{}{http.StatusOK: handler.DeliveryPlanResponse{}, http.StatusServiceUnavailable: apierror.Response{}}},
+		{Method: "PATCH", Path: "/api/robot/orders/status", Tag: "robot", Security: robot, Summary: "注文のステータスを更新する",
+			Description: "Idempotency-Key ヘッダーを付けると、同じキーの再送には処理をやり直さずに最初のレスポンスを返す。現在の状態から遷移できない状態を指定すると 409 を返す。" +
+				"order_id は配送計画の公開ID（ULID）を指定する。互換のため数値の注文IDも受け付ける",
+			Request: model.UpdateOrderStatusRequest{}, ResponseContentType: "text/plain",
+			Responses: map[int]interface{}{http.StatusOK: "", http.StatusNotFound: apierror.Response{}, http.StatusConflict: apierror.Response{}}},
+		{Method: "PUT", Path: "/api/robot/position", Tag: "robot", Security: robot, Summary: "ロボットの現在位置を報告する（管理ダッシュボードへ通知される）",
+			Request: model.RobotPositionRequest{}, Responses: noContent},
+
+		{Method: "GET", Path: "/api/health", Tag: "health", Summary: "ロードバランサー向けの死活確認",
+			ResponseContentType: "text/plain", Responses: map[int]interface{}{http.StatusOK: ""}},
+		{Method: "GET", Path: "/healthz", Tag: "health", Summary: "プロセスの死活確認",
+			ResponseContentType: "text/plain", Responses: map[int]interface{}{http.StatusOK: ""}},
+		{Method: "GET", Path: "/readyz", Tag: "health", Summary: "トラフィックを受けられる状態か",
+			Responses: map[int]interface{}{http.StatusOK: service.ReadinessReport{}, http.StatusServiceUnavailable: service.ReadinessReport{}}},
+	}
+	for _, v := range apiversion.Supported {
+		ops = append(ops, versionedOperations(v)...)
+	}
+	return ops
+}
+
+// /api/v1, /api/v2 のルートの説明。バージョンで形が変わるレスポンスだけ型を切り替える
+func versionedOperations(v apiversion.Version) []openapi.Operation {
+	const session = openapi.SecuritySession
+	var (
+		productList, orderList interface{} = handler.ProductListResponse{}, handler.ListResponse[handler.OrderResponse]{}
+		order                  interface{} = handler.OrderResponse{}
+		errorBody              interface{} = apierror.Response{}
+		errorContentType                   = "application/json"
+	)
+	if v >= apiversion.V2 {
+		productList, orderList = handler.ProductPageResponse{}, handler.PageResponse[handler.PublicOrderResponse]{}
+		order = handler.PublicOrderResponse{}
+		errorBody, errorContentType = apierror.Problem{}, "application/problem+json"
+	}
+
+	ops := []openapi.Operation{
+		{Method: "POST", Path: "/product", Tag: "products", Security: session, Summary: "商品一覧を検索する",
+			Description: "ETag / If-None-Match に対応する。cursor を指定した場合はキーセットページングになる。" +
+				"Accept: text/csv / application/x-ndjson の場合はページングせずに全件を返す（cursor・facets は無視）。" +
+				"条件はクエリパラメータでも指定でき、ボディより優先する。page_size の上限は 100、sort_field は product_id / name / value / weight / relevance",
+			Request: model.ListRequest{}, Responses: map[int]interface{}{http.StatusOK: productList, http.StatusNotModified: nil}},
+		{Method: "POST", Path: "/product/post", Tag: "orders", Security: session, Summary: "商品を注文する",
+			Description: "Idempotency-Key ヘッダーを付けると、同じキーの再送には処理をやり直さずに最初のレスポンスを返す。" +
+				"async=true の場合はジョブとして受け付けて 202 と Location（/orders/jobs/{jobID}）を返し、注文は ORDER_JOB_CHUNK_SIZE 件ずつ別のトランザクションで作成する。" +
+				"在庫を管理している商品の在庫数が足りない場合は 409 を返し、どの注文も作成しない",
+			Query:   []openapi.Param{{Name: "async", Description: "true で非同期に作成する"}},
+			Request: model.CreateOrderRequest{}, Responses: map[int]interface{}{http.StatusCreated: handler.OrdersCreatedResponse{}, http.StatusAccepted: model.OrderJob{}, http.StatusConflict: apierror.Response{}, http.StatusServiceUnavailable: apierror.Response{}}},
+		{Method: "POST", Path: "/orders", Tag: "orders", Security: session, Summary: "注文履歴を検索する",
+			Description: "ETag / If-None-Match に対応する。Accept: text/csv / application/x-ndjson の場合はページングせずに全件を返す。" +
+				"条件はクエリパラメータでも指定でき、ボディより優先する。page_size の上限は 100、sort_field は order_id / product_name / created_at / shipped_status / arrived_at。" +
+				"日時はユーザーのタイムゾーン（/api/users/me/preferences）のオフセット付きで返す。v2 の order_id は公開ID（ULID の文字列）",
+			Query:   []openapi.Param{{Name: "tz", Description: "日時を表すタイムゾーン（IANA のタイムゾーン名。ユーザーの設定より優先する）"}},
+			Request: model.ListRequest{}, Responses: map[int]interface{}{http.StatusOK: orderList, http.StatusNotModified: nil}},
+		{Method: "GET", Path: "/orders/{orderID}", Tag: "orders", Security: session, Summary: "注文を1件取得する",
+			Description: "orderID は公開ID（ULID）。v1 は互換のため order_id も受け付ける。他のユーザーの注文は管理者以外には 404 を返す",
+			Query:       []openapi.Param{{Name: "tz", Description: "日時を表すタイムゾーン（IANA のタイムゾーン名。ユーザーの設定より優先する）"}},
+			Responses:   map[int]interface{}{http.StatusOK: order, http.StatusNotFound: apierror.Response{}}},
+		{Method: "DELETE", Path: "/orders/{orderID}", Tag: "orders", Security: session, Summary: "配送前の注文を取り消す",
+			Description: "orderID は公開ID（ULID）。v1 は互換のため order_id も受け付ける。他のユーザーの注文は管理者以外には 404 を返す。配送待ち以外の注文は 409 を返す",
+			Responses:   map[int]interface{}{http.StatusNoContent: nil, http.StatusNotFound: apierror.Response{}, http.StatusConflict: apierror.Response{}}},
+		{Method: "GET", Path: "/orders/jobs/{jobID}", Tag: "orders", Security: session, Summary: "非同期の注文作成の状況を取得する",
+			Description: "ジョブの状態は受け付けたサーバーのメモリに保持し、終了から ORDER_JOB_RETENTION の間だけ参照できる。失敗した場合も作成済みの注文は残る",
+			Responses:   map[int]interface{}{http.StatusOK: model.OrderJob{}, http.StatusNotFound: apierror.Response{}}},
+		{Method: "GET", Path: "/image", Tag: "products", Security: session, Summary: "画像を取得する", Description: "画像が無い場合は 404",
+			Query:               []openapi.Param{{Name: "path", Required: true, Description: "画像のパス"}, {Name: "size", Description: "thumb / detail / original"}},
+			ResponseContentType: "image/*", Responses: map[int]interface{}{http.StatusOK: ""}},
+	}
+	for i := range ops {
+		ops[i].Path = v.Prefix() + ops[i].Path
+		ops[i].Tag += " (" + v.String() + ")"
+		ops[i].ErrorBody, ops[i].ErrorContentType = errorBody, errorContentType
+	}
+	return ops
+}
+
+// buildOpenAPI は apiOperations とルーターに登録されたルートからドキュメントを作る
+func buildOpenAPI(router chi.Routes) *openapi.Document {
+	b := openapi.NewBuilder(openapi.Info{
+		Title:       "Backend API",
+		Version:     "1.0.0",
+		Description: "エラーは全て {\"error\": {\"code\", \"message\", \"fields\", \"request_id\"}} の形式で返す",
+	}, apierror.Response{})
+	for _, op := range apiOperations() {
+		b.Add(op)
+	}
+	_ = chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, "/api/") || route == "/healthz" || route == "/readyz" {
+			b.AddUndocumented(method, route)
+		}
+		return nil
+	})
+	return b.Document()
+}
@@ -1,94 +1,633 @@
 package server
 
 import (
+	"backend/internal/apiversion"
+	"backend/internal/config"
+	"backend/internal/coord"
 	"backend/internal/db"
+	"backend/internal/errreport"
+	"backend/internal/events"
+	"backend/internal/featureflag"
+	"backend/internal/graph"
 	"backend/internal/handler"
+	"backend/internal/imageproc"
+	"backend/internal/logging"
 	"backend/internal/middleware"
+	"backend/internal/migrations"
+	"backend/internal/model"
+	"backend/internal/notify"
+	"backend/internal/openapi"
+	"backend/internal/outbox"
+	"backend/internal/ratelimit"
+	"backend/internal/realtime"
 	"backend/internal/repository"
+	"backend/internal/search"
 	"backend/internal/service"
+	"backend/internal/storage"
+	"backend/internal/telemetry"
+	"backend/internal/workerpool"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jmoiron/sqlx"
 )
 
+// 全サーバーで共有する配送計画の枠の期限（ROUTE_TIMEOUT_ROBOT が無効な場合の値と、タイムアウトに足す余裕）
+const (
+	defaultPlannerLease = time.Minute
+	plannerLeaseMargin  = 5 * time.Second
+)
+
+// ルートグループごとのレート制限（RateLimit.Enabled の場合のみ有効）
+type routeRateLimits struct {
+	login func(http.Handler) http.Handler // ログイン（IPごと）
+	write func(http.Handler) http.Handler // 注文作成など書き込みの重い操作（ユーザーごと）
+	read  func(http.Handler) http.Handler // 一覧などの参照（ユーザーごと）
+}
+
+func newRouteRateLimits(cfg config.RateLimit, shared *coord.Client) (routeRateLimits, error) {
+	limiter, err := ratelimit.New(cfg, shared)
+	if err != nil {
+		return routeRateLimits{}, err
+	}
+	login, err := ratelimit.ParsePolicy("login", cfg.Login)
+	if err != nil {
+		return routeRateLimits{}, err
+	}
+	write, err := ratelimit.ParsePolicy("write", cfg.Write)
+	if err != nil {
+		return routeRateLimits{}, err
+	}
+	read, err := ratelimit.ParsePolicy("read", cfg.Read)
+	if err != nil {
+		return routeRateLimits{}, err
+	}
+	return routeRateLimits{
+		login: middleware.RateLimitMiddleware(limiter, login, cfg.TrustProxy),
+		write: middleware.RateLimitMiddleware(limiter, write, cfg.TrustProxy),
+		read:  middleware.RateLimitMiddleware(limiter, read, cfg.TrustProxy),
+	}, nil
+}
+
+// ルートごとのリクエスト全体の時間予算（0 で無効）
+type routeTimeouts struct {
+	list     func(http.Handler) http.Handler // 一覧・検索（エクスポートは cfg.Export の期限のみ）
+	write    func(http.Handler) http.Handler // 注文作成などの書き込み
+	robot    func(http.Handler) http.Handler // 配送計画
+	standard func(http.Handler) http.Handler // その他の JSON API
+}
+
+func newRouteTimeouts(cfg config.Timeouts) routeTimeouts {
+	return routeTimeouts{
+		list: func(next http.Handler) http.Handler {
+			return middleware.ExportTimeoutMiddleware(cfg.Export)(middleware.TimeoutMiddleware(cfg.List)(next))
+		},
+		write:    middleware.TimeoutMiddleware(cfg.Write),
+		robot:    middleware.TimeoutMiddleware(cfg.Robot),
+		standard: middleware.TimeoutMiddleware(cfg.Default),
+	}
+}
+
 type Server struct {
 	Router *chi.Mux
+
+	cfg *config.Config
+
+	// 起動後に読み込んでおくキャッシュ（nil の場合は読み込まない）
+	warmer *service.CacheWarmer
+
+	// nil の場合は HTTP で待ち受ける
+	tls *tlsSettings
+
+	// バックグラウンド処理（レプリカ監視、アウトボックス配信など）は workerCtx で動かし、終了時にキャンセルする
+	workerCtx     context.Context
+	cancelWorkers context.CancelFunc
+	workers       sync.WaitGroup
+}
+
+// goBackground は fn をバックグラウンドで動かす。Run の終了時に ctx がキャンセルされ、戻るまで待たれる
+func (s *Server) goBackground(fn func(ctx context.Context)) {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		fn(s.workerCtx)
+	}()
 }
 
-func NewServer() (*Server, *sqlx.DB, *repository.Store, error) {
-	dbConn, err := db.InitDBConnection()
+// cfg は config.Load で検証済みのものを渡す
+// STORAGE=memory の場合、返す *sqlx.DB は nil
+func NewServer(cfg *config.Config) (*Server, *sqlx.DB, *repository.Store, error) {
+	tlsSettings, err := newTLSSettings(cfg.TLS)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	debugAddr, err := checkDebugAddr(cfg.Server.DebugAddr)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
-	store := repository.NewStore(dbConn)
+	dbConn, store, err := openStore(cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	workerCtx, cancelWorkers := context.WithCancel(context.Background())
+	s := &Server{
+		cfg:           cfg,
+		tls:           tlsSettings,
+		workerCtx:     workerCtx,
+		cancelWorkers: cancelWorkers,
+	}
+	s.goBackground(store.RunReplicaHealthCheck)
+	if debugAddr != "" {
+		s.goBackground(func(ctx context.Context) { runDebugListener(ctx, debugAddr) })
+	}
+	// マイグレーションの適用状況を起動時に一度だけ確認する
+	schemaCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	err = store.DetectSchema(schemaCtx)
+	cancel()
+	if err != nil {
+		s.stopBackground(context.Background())
+		return nil, nil, nil, err
+	}
+
+	// Outbox.Sink が指定された場合のみ、注文イベントをアウトボックスに記録して配信する（kafka / nats はメッセージブローカーへ）
+	relay, err := outbox.NewRelayFromConfig(store.OutboxRepo, cfg.Outbox, cfg.Messaging)
+	if err != nil {
+		s.stopBackground(context.Background())
+		return nil, nil, nil, err
+	}
+	if relay != nil {
+		store.EnableOutbox()
+		s.goBackground(relay.Run)
+	}
+
+	// SENTRY_DSN が指定された場合のみ、500 とパニックをエラートラッキングへ送る
+	reporter, err := errreport.NewSentryReporter(cfg.ErrorReporting, cfg.Telemetry.Environment)
+	if err != nil {
+		s.stopBackground(context.Background())
+		return nil, nil, nil, err
+	}
+	if reporter != nil {
+		errreport.SetDefault(reporter)
+		s.goBackground(reporter.Run)
+	}
+
+	// REDIS_URL が指定された場合のみ、セッション・レート制限・キャッシュの無効化・配送計画の枠を全サーバーで共有する
+	shared, err := coord.NewClient(cfg.Redis)
+	if err != nil {
+		s.stopBackground(context.Background())
+		return nil, nil, nil, err
+	}
+	var invalidator *coord.Invalidator
+	if shared != nil {
+		invalidator = coord.NewInvalidator(shared)
+		store.UseRedis(shared, invalidator)
+		s.goBackground(func(ctx context.Context) {
+			<-ctx.Done()
+			_ = shared.Close()
+		})
+	}
 
 	authService := service.NewAuthService(store)
-	orderService := service.NewOrderService(store)
-	productService := service.NewProductService(store)
-	robotService := service.NewRobotService(store)
+	bus := events.NewBus()
+	orderService := service.NewOrderService(store, bus, cfg)
+	if url := cfg.Stock.LowStockWebhookURL; url != "" {
+		webhooks := workerpool.New("webhook", workerpool.Options{
+			Workers:      cfg.Stock.WebhookWorkers,
+			QueueSize:    cfg.Stock.WebhookQueueSize,
+			DrainTimeout: cfg.Server.ShutdownGracePeriod,
+		})
+		s.goBackground(webhooks.Run)
+		bus.Subscribe(events.TypeLowStock, events.WebhookHandler(url, webhooks))
+	}
+
+	flags, err := featureflag.New(cfg.Features)
+	if err != nil {
+		s.stopBackground(context.Background())
+		return nil, nil, nil, err
+	}
+	s.goBackground(func(ctx context.Context) { flags.Watch(ctx, cfg.Features.ReloadInterval) })
 
+	searchIndex := search.New(cfg.Search, store.ProductRepo, store.Schema())
+	if _, ok := searchIndex.(*search.MySQLIndex); ok {
+		store.UseFullTextSearch()
+	}
+	productService := service.NewProductService(store, searchIndex, bus, cfg, flags)
+	// 外部の検索エンジンには、商品の書き込みをワーカーで反映する（起動時に索引の設定と全件の登録も行う）
+	if external, ok := searchIndex.(search.ExternalIndex); ok {
+		indexer := search.NewIndexer(external, store.ProductRepo, cfg.Server.ShutdownGracePeriod)
+		productService.OnProductChanged(indexer.Sync)
+		s.goBackground(indexer.Run)
+	}
+	robotService := service.NewRobotService(store, bus, cfg)
+	s.goBackground(robotService.RunPlanner)
+	s.goBackground(service.BackfillOrderPublicIDs(store))
+	if reaper := service.NewOrderReaper(store, bus, cfg.Planner); reaper != nil {
+		s.goBackground(reaper.Run)
+	}
+	if shared != nil {
+		lease := cfg.Timeouts.Robot
+		if lease <= 0 {
+			lease = defaultPlannerLease
+		}
+		// 計算がタイムアウトまで続いても枠が先に切れないよう余裕を持たせる
+		robotService.UseClusterSlots(shared, cfg.Planner, lease+plannerLeaseMargin)
+		s.goBackground(invalidator.Run)
+	}
+
+	// NOTIFY_CHANNELS が指定された場合のみ、配送完了をユーザーの通知設定に従って通知する
+	notifier, err := notify.NewWorker(store.NotifyRepo, cfg.Notify)
+	if err != nil {
+		s.stopBackground(context.Background())
+		return nil, nil, nil, err
+	}
+	if notifier != nil {
+		notifier.Subscribe(bus)
+		s.goBackground(notifier.Run)
+	}
+
+	s.warmer = service.NewCacheWarmer(productService, robotService, cfg.Cache)
+
+	// 管理ダッシュボードへの配信。接続中も管理者のセッションが有効かを定期的に確かめる
+	dashboardHub := realtime.NewHub(cfg.Realtime, adminSessionAuthorizer(store))
+	dashboardHub.Subscribe(bus)
+	s.goBackground(dashboardHub.Run)
+	reviewService := service.NewReviewService(store)
+	cartService := service.NewCartService(store, bus, cfg)
+	recommendService := service.NewRecommendationService(store, cfg)
+	s.goBackground(recommendService.RunRefresher)
+
+	images := storage.NewFSBlobStore(cfg.Storage.ImageDir)
 	authHandler := handler.NewAuthHandler(authService)
-	productHandler := handler.NewProductHandler(productService)
-	orderHandler := handler.NewOrderHandler(orderService)
+	// TLS を前段のロードバランサーで終端している場合は CookieSecure を有効にする
+	authHandler.SecureCookie = tlsSettings != nil || cfg.Server.CookieSecure
+	productHandler := handler.NewProductHandler(productService, recommendService, images, imageproc.NewProcessor(images, cfg.Storage.ImageCacheDir))
+	// 大量の注文の作成（?async=true）はリクエストの外でワーカーが処理する
+	orderJobs := service.NewOrderJobQueue(productService, cfg)
+	s.goBackground(orderJobs.Run)
+	productHandler.OrderJobs = orderJobs
+	orderHandler := handler.NewOrderHandler(orderService, orderJobs)
 	robotHandler := handler.NewRobotHandler(robotService)
+	reviewHandler := handler.NewReviewHandler(reviewService)
+	cartHandler := handler.NewCartHandler(cartService)
+	notificationHandler := handler.NewNotificationHandler(service.NewNotificationService(store))
+	userHandler := handler.NewUserHandler(service.NewUserService(store))
+	// 運用ダッシュボードの集計は orders を走査せず、定期的に更新する order_summaries から読む
+	summarizer := service.NewOrderSummarizer(store, cfg.Stats)
+	if summarizer != nil {
+		s.goBackground(summarizer.Run)
+	}
+	adminHandler := handler.NewAdminHandler(service.NewAdminService(store, robotService, summarizer))
+	// REPORT_DIR が指定された場合のみ、前日分の運用レポートを毎日書き出す
+	if cfg.Reports.Dir != "" {
+		adminHandler.Reports = service.NewReportGenerator(store, robotService, storage.NewFSBlobStore(cfg.Reports.Dir), cfg.Reports)
+		s.goBackground(adminHandler.Reports.Run)
+	}
 
 	userAuthMW := middleware.UserAuthMiddleware(store.SessionRepo)
+	adminAuthMW := middleware.AdminAuthMiddleware(store.UserRepo)
+	timezoneMW := middleware.TimezoneMiddleware(store.UserRepo)
+	// /orders/{orderID} のルートは、注文の所有者（または管理者）かをここでまとめて確かめる
+	orderOwnerMW := middleware.OrderOwnerMiddleware(store.OrderRepo, store.UserRepo)
+
+	robotAuthMW := middleware.RobotAuthMiddleware(cfg.Server.RobotAPIKey)
+	productETagMW := middleware.ListingETagMiddleware(func(r *http.Request) (string, time.Time, error) {
+		userID, _ := middleware.GetUserFromContext(r.Context())
+		return productService.ListingVersion(r.Context(), userID)
+	})
+	orderETagMW := middleware.ListingETagMiddleware(func(r *http.Request) (string, time.Time, error) {
+		userID, _ := middleware.GetUserFromContext(r.Context())
+		return orderService.ListingVersion(r.Context(), userID)
+	})
 
-	robotAPIKey := os.Getenv("ROBOT_API_KEY")
-	if robotAPIKey == "" {
-		robotAPIKey = "test-robot-key"
+	readOnly := middleware.NewReadOnlyMode(cfg.Server)
+	// Idempotency-Key による再送の保護は、重複して実行されると困る書き込みにだけ付ける
+	idempotent := middleware.IdempotencyMiddleware(store.IdemRepo, cfg.Idempotency)
+	s.goBackground(func(ctx context.Context) {
+		middleware.RunIdempotencyCleanup(ctx, store.IdemRepo, cfg.Idempotency.CleanupInterval)
+	})
+	rateLimits, err := newRouteRateLimits(cfg.RateLimit, shared)
+	if err != nil {
+		s.stopBackground(context.Background())
+		return nil, nil, nil, err
 	}
-	robotAuthMW := middleware.RobotAuthMiddleware(robotAPIKey)
 
 	r := chi.NewRouter()
+	r.Use(middleware.RequestIDMiddleware)
+	r.Use(middleware.TraceContextMiddleware)
+	r.Use(middleware.AccessLogMiddleware(cfg.Log.AccessLog))
+	r.Use(middleware.RecoverMiddleware)
+	r.Use(middleware.CompressMiddleware(cfg.Server))
+	r.Use(middleware.ReadYourWritesMiddleware)
 
 	r.Get("/api/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
 
-	s := &Server{
-		Router: r,
-	}
+	// 認証なしで参照できるヘルスチェック
+	healthHandler := handler.NewHealthHandler(service.NewHealthService(store, cfg, s.warmer))
+	r.Get("/healthz", healthHandler.Liveness)
+	r.Get("/readyz", healthHandler.Readiness)
+
+	graphHandler := graph.LoaderMiddleware(productService)(graph.NewHandler(&graph.Resolver{
+		ProductSvc: productService,
+		OrderSvc:   orderService,
+		RobotSvc:   robotService,
+		UserRepo:   store.UserRepo,
+		ReadOnly:   readOnly,
+	}))
+
+	s.Router = r
+	s.setupRoutes(authHandler, productHandler, orderHandler, robotHandler, reviewHandler, cartHandler, notificationHandler, userHandler, adminHandler, graphHandler, dashboardHub, userAuthMW, adminAuthMW, timezoneMW, orderOwnerMW, robotAuthMW, productETagMW, orderETagMW, idempotent, rateLimits, newRouteTimeouts(cfg.Timeouts), readOnly, handler.NewMaintenanceHandler(readOnly))
 
-	s.setupRoutes(authHandler, productHandler, orderHandler, robotHandler, userAuthMW, robotAuthMW)
+	// APIDocs が false の場合は公開しない
+	if cfg.Server.APIDocs {
+		r.Get("/api/openapi.json", openapi.Handler(buildOpenAPI(r)))
+		r.Get("/api/docs", openapi.UIHandler("/api/openapi.json"))
+	}
 
 	return s, dbConn, store, nil
 }
 
+// openStore はデータベースに接続し、マイグレーションを適用して Store を作る
+// STORAGE=memory の場合はデータベースを使わない Store を返す（dbConn は nil）
+func openStore(cfg *config.Config) (*sqlx.DB, *repository.Store, error) {
+	if cfg.DB.Memory() {
+		return nil, repository.NewMemoryStore(cfg), nil
+	}
+
+	dbConn, err := db.InitDBConnection(cfg.DB)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// MigrateOnStart の場合は起動時に同梱のマイグレーションを適用する
+	// （同梱のマイグレーションは MySQL 用のため、SQLite / PostgreSQL ではスキーマ作成時に適用済み）
+	if cfg.Server.MigrateOnStart && cfg.DB.EffectiveDriver() == "mysql" {
+		if err := migrations.Apply(context.Background(), dbConn); err != nil {
+			dbConn.Close()
+			return nil, nil, err
+		}
+	}
+
+	replicas, err := db.InitReplicaConnections(cfg.DB)
+	if err != nil {
+		dbConn.Close()
+		return nil, nil, err
+	}
+
+	pools := map[string]*sql.DB{"primary": dbConn.DB}
+	for i, replica := range replicas {
+		pools["replica-"+strconv.Itoa(i)] = replica.DB
+	}
+	if err := telemetry.RegisterDBPoolMetrics(pools); err != nil {
+		slog.Warn("failed to register db pool metrics", "err", err)
+	}
+
+	return dbConn, repository.NewStore(dbConn, cfg, replicas...), nil
+}
+
 func (s *Server) setupRoutes(
 	authHandler *handler.AuthHandler,
 	productHandler *handler.ProductHandler,
 	orderHandler *handler.OrderHandler,
 	robotHandler *handler.RobotHandler,
+	reviewHandler *handler.ReviewHandler,
+	cartHandler *handler.CartHandler,
+	notificationHandler *handler.NotificationHandler,
+	userHandler *handler.UserHandler,
+	adminHandler *handler.AdminHandler,
+	graphHandler http.Handler,
+	dashboardHub http.Handler,
 	userAuthMW func(http.Handler) http.Handler,
+	adminAuthMW func(http.Handler) http.Handler,
+	timezoneMW func(http.Handler) http.Handler,
+	orderOwnerMW func(http.Handler) http.Handler,
 	robotAuthMW func(http.Handler) http.Handler,
+	productETagMW func(http.Handler) http.Handler,
+	orderETagMW func(http.Handler) http.Handler,
+	idempotent func(http.Handler) http.Handler,
+	rateLimits routeRateLimits,
+	timeouts routeTimeouts,
+	readOnly *middleware.ReadOnlyMode,
+	maintenanceHandler *handler.MaintenanceHandler,
 ) {
-	s.Router.Post("/api/login", authHandler.Login)
+	// 画像は商品ごとに内容が決まるため CDN にもキャッシュさせる（ETag はハンドラが付ける）
+	imageCache := middleware.CacheMiddleware(middleware.CachePolicy{MaxAge: 24 * time.Hour, Public: true})
+	// カテゴリはほとんど変わらないため、短い期間だけキャッシュさせる（ETag は本文から計算する）
+	categoryCache := middleware.CacheMiddleware(middleware.CachePolicy{MaxAge: 5 * time.Minute, Public: true})
 
-	s.Router.Route("/api/v1", func(r chi.Router) {
+	// 時間予算（timeouts）は JSON を返すルートにのみ設定する。画像はバッファせずにそのまま返すため対象外
+	// 認証のセッション参照は予算の外側だが、リポジトリのクエリごとのタイムアウトで抑えている
+	// readOnly.Guard は書き込み系のルートに付ける。ログインは参照を続けるために必要なため対象外
+	s.Router.With(rateLimits.login, timeouts.standard).Post("/api/login", authHandler.Login)
+	s.Router.With(userAuthMW, rateLimits.read, categoryCache, timeouts.standard).Get("/api/categories", productHandler.ListCategories)
+	s.Router.With(userAuthMW, rateLimits.read, timeouts.list).Get("/api/favorites", productHandler.ListFavorites)
+
+	// バージョン付きのルートはハンドラを共有し、レスポンスの形の違いはハンドラ内で ctx のバージョンを見て切り替える
+	for _, v := range apiversion.Supported {
+		s.Router.Route(v.Prefix(), func(r chi.Router) {
+			r.Use(middleware.APIVersionMiddleware(v))
+			r.Use(userAuthMW)
+			r.Use(rateLimits.read)
+			r.With(timeouts.list, productETagMW).Post("/product", productHandler.List)
+			r.With(readOnly.Guard, rateLimits.write, idempotent, timeouts.write).Post("/product/post", productHandler.CreateOrders)
+			// 日時はユーザーのタイムゾーン（?tz= で上書き）で返すため、ETag の計算より前に解決する
+			r.With(timeouts.list, timezoneMW, orderETagMW).Post("/orders", orderHandler.List)
+			r.With(timeouts.standard).Get("/orders/jobs/{jobID}", orderHandler.Job)
+			// 注文を1件扱うルートには必ず orderOwnerMW を付ける（ハンドラは user_id の条件を付けない）
+			r.With(timeouts.standard, orderOwnerMW, timezoneMW).Get("/orders/{orderID}", orderHandler.Get)
+			r.With(readOnly.Guard, rateLimits.write, timeouts.write, orderOwnerMW).Delete("/orders/{orderID}", orderHandler.Cancel)
+			r.With(imageCache).Get("/image", productHandler.GetImage)
+		})
+	}
+
+	// GraphQL は商品・注文・配送計画をまとめて取得するためのエンドポイント
+	// ミューテーション（配送計画の作成）の管理者判定と readOnly の確認はリゾルバ側で行う
+	s.Router.With(userAuthMW, rateLimits.read, timeouts.list).Post("/graphql", graphHandler.ServeHTTP)
+
+	s.Router.Route("/api/products", func(r chi.Router) {
+		r.Use(userAuthMW)
+		r.Use(rateLimits.read)
+		r.With(timeouts.list).Get("/suggest", productHandler.Suggest)
+		r.With(imageCache).Get("/{productID}/image", productHandler.GetProductImage)
+		r.With(timeouts.list).Get("/{productID}/related", productHandler.Related)
+		r.With(timeouts.list).Get("/{productID}/price-history", productHandler.PriceHistory)
+		r.With(timeouts.list).Get("/{productID}/reviews", reviewHandler.List)
+		r.With(readOnly.Guard, rateLimits.write, timeouts.write).Post("/{productID}/reviews", reviewHandler.Create)
+		r.With(readOnly.Guard, timeouts.standard).Post("/{productID}/favorite", productHandler.AddFavorite)
+		r.With(readOnly.Guard, timeouts.standard).Delete("/{productID}/favorite", productHandler.RemoveFavorite)
+	})
+
+	s.Router.Route("/api/cart", func(r chi.Router) {
+		r.Use(userAuthMW)
+		r.Use(rateLimits.read)
+		r.With(timeouts.standard).Get("/", cartHandler.Get)
+		r.With(readOnly.Guard, timeouts.standard).Post("/items", cartHandler.AddItem)
+		r.With(readOnly.Guard, timeouts.standard).Put("/items/{productID}", cartHandler.UpdateItem)
+		r.With(readOnly.Guard, timeouts.standard).Delete("/items/{productID}", cartHandler.RemoveItem)
+		r.With(readOnly.Guard, rateLimits.write, idempotent, timeouts.write).Post("/checkout", cartHandler.Checkout)
+	})
+
+	s.Router.Route("/api/users/me", func(r chi.Router) {
+		r.Use(userAuthMW)
+		r.Use(rateLimits.read)
+		r.With(timeouts.standard).Get("/notifications", notificationHandler.Get)
+		r.With(readOnly.Guard, timeouts.standard).Put("/notifications", notificationHandler.Put)
+		r.With(timeouts.standard).Get("/preferences", userHandler.GetPreferences)
+		r.With(readOnly.Guard, timeouts.standard).Put("/preferences", userHandler.PutPreferences)
+	})
+
+	s.Router.Route("/api/admin", func(r chi.Router) {
 		r.Use(userAuthMW)
-		r.Post("/product", productHandler.List)
-		r.Post("/product/post", productHandler.CreateOrders)
-		r.Post("/orders", orderHandler.List)
-		r.Get("/image", productHandler.GetImage)
+		r.Use(adminAuthMW)
+		r.With(readOnly.Guard, idempotent, timeouts.write).Post("/products", productHandler.AdminCreate)
+		// 大きな CSV を受けるため時間予算は設けない
+		r.With(readOnly.Guard).Post("/products/import", productHandler.AdminImport)
+		r.With(timeouts.list).Get("/products/low-stock", productHandler.AdminLowStock)
+		r.With(readOnly.Guard, timeouts.write).Put("/products/{productID}", productHandler.AdminUpdate)
+		r.With(readOnly.Guard, timeouts.write).Delete("/products/{productID}", productHandler.AdminDelete)
+		r.With(timeouts.list).Get("/overview", adminHandler.Overview)
+		r.With(timeouts.list).Get("/stats/orders", adminHandler.OrderStats)
+		r.With(timeouts.standard).Get("/reports", adminHandler.ListReports)
+		r.With(timeouts.standard).Get("/reports/{name}", adminHandler.GetReport)
+		r.With(timeouts.standard).Get("/maintenance", maintenanceHandler.Get)
+		r.With(timeouts.standard).Put("/maintenance", maintenanceHandler.Update)
+		// WebSocket は接続が続くため時間予算は設けない
+		r.Get("/ws", dashboardHub.ServeHTTP)
 	})
 
+	// pprof / expvar。本番でもプロファイルを取れるよう管理者に公開する（DebugEndpoints が false の場合は無効）
+	if s.cfg.Server.DebugEndpoints {
+		s.Router.With(userAuthMW, adminAuthMW).Mount("/debug", debugRoutes())
+	}
+
 	s.Router.Route("/api/robot", func(r chi.Router) {
 		r.Use(robotAuthMW)
-		r.Get("/delivery-plan", robotHandler.GetDeliveryPlan)
-		r.Patch("/orders/status", robotHandler.UpdateOrderStatus)
+		// 配送計画は対象の注文を配送中に更新するため書き込みとして扱う
+		r.With(readOnly.Guard, timeouts.robot).Get("/delivery-plan", robotHandler.GetDeliveryPlan)
+		r.With(readOnly.Guard, idempotent, timeouts.write).Patch("/orders/status", robotHandler.UpdateOrderStatus)
+		// 位置は保存せず通知するだけのため、読み取り専用モードでも受け付ける
+		r.With(timeouts.standard).Put("/position", robotHandler.ReportPosition)
 	})
 }
 
-func (s *Server) Run() {
-	appPort := os.Getenv("PORT")
-	if appPort == "" {
-		appPort = "8080"
+// adminSessionAuthorizer は WebSocket 接続中のセッションが失効したか、管理者でなくなった場合にエラーを返す
+// DB の一時的な障害では切断せず、次の確認まで接続を続ける
+func adminSessionAuthorizer(store *repository.Store) realtime.Authorizer {
+	transient := func(err error) bool {
+		return errors.Is(err, repository.ErrQueryTimeout) || errors.Is(err, repository.ErrCircuitOpen)
+	}
+	return func(ctx context.Context, sessionID string) error {
+		userID, err := store.SessionRepo.FindUserBySessionID(ctx, sessionID)
+		if err != nil {
+			if transient(err) {
+				slog.WarnContext(ctx, "realtime: session check skipped", "err", err)
+				return nil
+			}
+			return fmt.Errorf("session is no longer valid: %w", err)
+		}
+		role, err := store.UserRepo.FindRoleByID(ctx, userID)
+		if err != nil {
+			if transient(err) {
+				slog.WarnContext(ctx, "realtime: session check skipped", "err", err)
+				return nil
+			}
+			return fmt.Errorf("failed to look up role: %w", err)
+		}
+		if role != model.RoleAdmin {
+			return errors.New("admin role revoked")
+		}
+		return nil
+	}
+}
+
+// Run は ctx がキャンセルされる（SIGINT / SIGTERM を受ける）までリクエストを受け付ける
+// TLS の証明書か autocert のドメインが設定されていれば HTTPS で待ち受ける（tlsSettings を参照）
+// 終了時は新規接続の受付を止め、処理中のリクエストを ShutdownGracePeriod まで待ってから
+// バックグラウンド処理を停止し、BackgroundDrainTimeout まで終了を待つ。DB の切断は呼び出し側で Run が戻った後に行うこと
+func (s *Server) Run(ctx context.Context) error {
+	appPort := s.cfg.Server.Port
+	grace := s.cfg.Server.ShutdownGracePeriod
+
+	httpServer := &http.Server{
+		Addr:     ":" + appPort,
+		Handler:  s.Router,
+		ErrorLog: logging.StdLogger(slog.LevelWarn),
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		if s.tls == nil {
+			serveErr <- httpServer.ListenAndServe()
+			return
+		}
+		serveErr <- s.tls.serve(httpServer)
+	}()
+	if s.tls != nil && s.tls.redirectPort != "" {
+		redirect := s.tls.redirectServer(appPort)
+		s.goBackground(func(ctx context.Context) { runRedirect(ctx, redirect) })
+	}
+	// 待ち受けを始めてから読み込む（読み込み中は /readyz が unavailable を返す）
+	if s.warmer != nil {
+		s.goBackground(s.warmer.Run)
+	}
+
+	select {
+	case err := <-serveErr:
+		s.stopBackground(context.Background())
+		return err
+	case <-ctx.Done():
 	}
 
-	_ = http.ListenAndServe(":"+appPort, s.Router)
+	slog.Info("shutting down: draining in-flight requests", "grace_period", grace.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	var err error
+	if shutdownErr := httpServer.Shutdown(shutdownCtx); shutdownErr != nil {
+		// 猶予を過ぎても終わらないリクエストは接続を切り、コンテキストのキャンセルでトランザクションをロールバックさせる
+		slog.Warn("grace period exceeded, closing remaining connections", "err", shutdownErr)
+		err = httpServer.Close()
+	}
+	if serr := <-serveErr; serr != nil && !errors.Is(serr, http.ErrServerClosed) {
+		err = errors.Join(err, serr)
+	}
+
+	// リクエストの待機で使い切った猶予とは別に、バックグラウンド処理の停止を待つ
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), s.cfg.Server.BackgroundDrainTimeout)
+	defer cancelDrain()
+	if derr := s.stopBackground(drainCtx); derr != nil {
+		err = errors.Join(err, derr)
+	}
+	return err
+}
+
+// ErrBackgroundRunning は期限までにバックグラウンド処理が止まらなかったことを表す
+// Run がこのエラーを返した場合、バックグラウンド処理がまだ DB を使っているため DB を閉じないこと
+var ErrBackgroundRunning = errors.New("background workers did not stop in time")
+
+// stopBackground はバックグラウンド処理をキャンセルし、ctx の期限まで終了を待つ
+// 期限までに止まらなければ ErrBackgroundRunning を返す
+func (s *Server) stopBackground(ctx context.Context) error {
+	s.cancelWorkers()
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		slog.Warn("background workers did not stop in time", "err", ctx.Err())
+		return ErrBackgroundRunning
+	}
 }
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/logging"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+var errTLSConfig = errors.New("invalid TLS configuration")
+
+// ロードバランサーを挟まずに HTTPS を終端する場合の設定（config.TLS から作る）
+// 証明書ファイルを指定するか、Let's Encrypt で自動取得するドメインを指定する
+// リダイレクト用のポートは autocert の http-01 チャレンジにも応答する
+type tlsSettings struct {
+	certFile, keyFile string
+	autocert          *autocert.Manager
+	redirectPort      string
+}
+
+// newTLSSettings は TLS を使わない場合 nil を返す
+// 組み合わせの検証は config.Validate で済んでいる
+func newTLSSettings(cfg config.TLS) (*tlsSettings, error) {
+	certFile, keyFile, domains := cfg.CertFile, cfg.KeyFile, cfg.AutocertDomains
+
+	s := &tlsSettings{certFile: certFile, keyFile: keyFile, redirectPort: cfg.RedirectPort}
+	switch {
+	case certFile != "":
+		// 起動時に読めることを確認しておく（ListenAndServeTLS まで待つと設定ミスに気付くのが遅れる）
+		if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+			return nil, fmt.Errorf("%w: %w", errTLSConfig, err)
+		}
+	case len(domains) > 0:
+		s.autocert = &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(domains...),
+			Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+			Email:      cfg.AutocertEmail,
+		}
+	default:
+		return nil, nil
+	}
+	return s, nil
+}
+
+func (s *tlsSettings) config() *tls.Config {
+	if s.autocert != nil {
+		c := s.autocert.TLSConfig()
+		c.MinVersion = tls.VersionTLS12
+		return c
+	}
+	return &tls.Config{MinVersion: tls.VersionTLS12}
+}
+
+// serve は TLS で待ち受ける。証明書は config() の GetCertificate か TLS_CERT_FILE から読む
+func (s *tlsSettings) serve(srv *http.Server) error {
+	srv.TLSConfig = s.config()
+	return srv.ListenAndServeTLS(s.certFile, s.keyFile)
+}
+
+// redirectServer は HTTP のリクエストを同じホストの HTTPS にリダイレクトするサーバー
+func (s *tlsSettings) redirectServer(httpsPort string) *http.Server {
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		status := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			// POST などはメソッドと本文を保ったままリダイレクトさせる
+			status = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	})
+	if s.autocert != nil {
+		h = s.autocert.HTTPHandler(h)
+	}
+	return &http.Server{
+		Addr:              ":" + s.redirectPort,
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          logging.StdLogger(slog.LevelWarn),
+	}
+}
+
+// runRedirect は ctx がキャンセルされるまでリダイレクト用のサーバーを動かす
+func runRedirect(ctx context.Context, srv *http.Server) {
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	slog.Info("redirecting HTTP to HTTPS", "addr", srv.Addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("HTTP redirect server failed", "err", err)
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"backend/internal/model"
+	"backend/internal/repository"
+	"context"
+	"time"
+)
+
+const (
+	// 配送開始の件数を数える期間
+	claimThroughputWindow = time.Hour
+	// この期間内にアクセスのあったロボットを稼働中とみなす
+	activeRobotWindow = 5 * time.Minute
+	// 概要に含めるスロークエリの件数
+	overviewSlowQueries = 10
+)
+
+// AdminService は運用ダッシュボード向けの集計を行う
+type AdminService struct {
+	store    *repository.Store
+	robotSvc *RobotService
+	// 注文の集計（nil の場合や集計が終わるまでは orders を直接集計する）
+	summaries *OrderSummarizer
+}
+
+func NewAdminService(store *repository.Store, robotSvc *RobotService, summaries *OrderSummarizer) *AdminService {
+	return &AdminService{store: store, robotSvc: robotSvc, summaries: summaries}
+}
+
+func (s *AdminService) Overview(ctx context.Context) (*model.AdminOverview, error) {
+	now := time.Now().UTC()
+	overview := &model.AdminOverview{GeneratedAt: now}
+
+	backlog, err := s.store.OrderRepo.CountByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	overview.Backlog = backlog
+
+	claimed, ok, err := s.store.OrderRepo.CountClaimedSince(ctx, now.Add(-claimThroughputWindow))
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		overview.ClaimThroughput = &model.ClaimThroughput{
+			WindowSeconds: int(claimThroughputWindow.Seconds()),
+			Claimed:       claimed,
+			PerMinute:     float64(claimed) / claimThroughputWindow.Minutes(),
+		}
+	}
+
+	delivered, avgSeconds, err := s.deliveryStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	overview.DeliveredCount = delivered
+	if delivered > 0 {
+		overview.AvgTimeToDeliverySeconds = &avgSeconds
+	}
+
+	overview.ActiveRobots = s.robotSvc.ActiveRobots(now.Add(-activeRobotWindow))
+	overview.SlowQueries = repository.TopSlowQueries(overviewSlowQueries)
+	return overview, nil
+}
+
+// deliveryStats は配送完了した注文数と配送完了までの平均秒数
+// 集計が使える場合は日ごとの集計を合計し、orders は走査しない
+func (s *AdminService) deliveryStats(ctx context.Context) (int, float64, error) {
+	if s.summaries.Ready() {
+		delivered, seconds, err := s.store.SummaryRepo.DeliveryTotals(ctx)
+		if err != nil || delivered == 0 {
+			return 0, 0, err
+		}
+		return delivered, seconds / float64(delivered), nil
+	}
+	avg, delivered, err := s.store.OrderRepo.AverageTimeToDelivery(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	return delivered, avg.Seconds(), nil
+}
+
+// OrderSummaries は period（hour / day）ごとの since 以降の注文の集計
+func (s *AdminService) OrderSummaries(ctx context.Context, period string, since time.Time) ([]model.OrderSummary, error) {
+	return s.summaries.Summaries(ctx, period, since)
+}
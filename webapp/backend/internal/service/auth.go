@@ -9,13 +9,17 @@ import (
 	"time"
 
 	"backend/internal/repository"
-	"backend/internal/service/utils"
+	"backend/internal/telemetry"
 )
 
 var (
 	ErrUserNotFound    = errors.New("user not found")
 	ErrInvalidPassword = errors.New("invalid password")
 	ErrInternalServer  = errors.New("internal server error")
+	// DBクエリがタイムアウトした（ハンドラでは 504 を返す）
+	ErrTimeout = repository.ErrQueryTimeout
+	// DBへの呼び出しが連続して失敗し、一時的に遮断している（ハンドラでは 503 を返す）
+	ErrUnavailable = repository.ErrCircuitOpen
 )
 
 type AuthService struct {
@@ -42,32 +46,43 @@ func verifyPasswordHash(password, storedHash string) bool {
 }
 
 func (s *AuthService) Login(ctx context.Context, userName, password string) (string, time.Time, error) {
-	var sessionID string
-	var expiresAt time.Time
-	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
-		user, err := s.store.UserRepo.FindByUserName(ctx, userName)
-		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				return ErrUserNotFound
-			}
-			return ErrInternalServer
-		}
+	sessionID, expiresAt, err := s.login(ctx, userName, password)
+	switch {
+	case err == nil:
+		telemetry.RecordLogin(ctx, telemetry.LoginSuccess)
+	case errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrInvalidPassword):
+		telemetry.RecordLogin(ctx, telemetry.LoginInvalid)
+	default:
+		telemetry.RecordLogin(ctx, telemetry.LoginError)
+	}
+	return sessionID, expiresAt, err
+}
 
-		// SHA-256による高速なパスワード検証
-		passwordValid := verifyPasswordHash(password, user.PasswordHash)
-		if !passwordValid {
-			return ErrInvalidPassword
+func (s *AuthService) login(ctx context.Context, userName, password string) (string, time.Time, error) {
+	user, err := s.store.UserRepo.FindByUserName(ctx, userName)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", time.Time{}, ErrUserNotFound
 		}
-
-		sessionDuration := 24 * time.Hour
-		sessionID, expiresAt, err = s.store.SessionRepo.Create(ctx, user.UserID, sessionDuration)
-		if err != nil {
-			return ErrInternalServer
+		if errors.Is(err, ErrTimeout) || errors.Is(err, ErrUnavailable) {
+			return "", time.Time{}, err
 		}
-		return nil
-	})
+		return "", time.Time{}, ErrInternalServer
+	}
+
+	// SHA-256による高速なパスワード検証
+	passwordValid := verifyPasswordHash(password, user.PasswordHash)
+	if !passwordValid {
+		return "", time.Time{}, ErrInvalidPassword
+	}
+
+	sessionDuration := 24 * time.Hour
+	sessionID, expiresAt, err := s.store.SessionRepo.Create(ctx, user.UserID, sessionDuration)
 	if err != nil {
-		return "", time.Time{}, err
+		if errors.Is(err, ErrTimeout) || errors.Is(err, ErrUnavailable) {
+			return "", time.Time{}, err
+		}
+		return "", time.Time{}, ErrInternalServer
 	}
 	return sessionID, expiresAt, nil
 }
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"backend/internal/config"
+	"backend/internal/events"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/telemetry"
+)
+
+var (
+	ErrInvalidQuantity = errors.New("invalid quantity")
+	ErrCartItemMissing = errors.New("cart item not found")
+	ErrCartEmpty       = errors.New("cart is empty")
+	// カートに通貨の異なる商品が含まれ、合計を求められない
+	ErrCurrencyMismatch = model.ErrCurrencyMismatch
+)
+
+type CartService struct {
+	store *repository.Store
+	stock *stockAlerter
+}
+
+func NewCartService(store *repository.Store, bus *events.Bus, cfg *config.Config) *CartService {
+	return &CartService{store: store, stock: newStockAlerter(bus, cfg.Stock)}
+}
+
+// カートの中身を取得
+func (s *CartService) GetCart(ctx context.Context, userID int) (*model.Cart, error) {
+	items, err := s.store.CartRepo.ListItems(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	cart := &model.Cart{Items: items}
+	total := model.NewMoney(0, "")
+	if len(items) > 0 {
+		total = model.NewMoney(0, items[0].Currency)
+	}
+	for _, item := range items {
+		cart.TotalQuantity += item.Quantity
+		subtotal, err := model.NewMoney(int64(item.Value), item.Currency).Mul(int64(item.Quantity))
+		if err != nil {
+			return nil, fmt.Errorf("cart item %d: %w", item.ProductID, err)
+		}
+		if total, err = total.Add(subtotal); err != nil {
+			return nil, fmt.Errorf("cart total: %w", err)
+		}
+	}
+	if cart.TotalValue, err = total.Int(); err != nil {
+		return nil, fmt.Errorf("cart total: %w", err)
+	}
+	cart.Currency = total.Currency
+	return cart, nil
+}
+
+// カートに商品を追加
+func (s *CartService) AddItem(ctx context.Context, userID int, item model.RequestItem) error {
+	if item.Quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+	if _, err := s.store.ProductRepo.GetProduct(ctx, item.ProductID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrProductNotFound
+		}
+		return err
+	}
+	return s.store.CartRepo.AddItem(ctx, userID, item.ProductID, item.Quantity)
+}
+
+// カート内の商品の数量を更新（0 の場合は削除）
+func (s *CartService) UpdateQuantity(ctx context.Context, userID, productID, quantity int) error {
+	if quantity < 0 {
+		return ErrInvalidQuantity
+	}
+	if quantity == 0 {
+		return s.store.CartRepo.RemoveItem(ctx, userID, productID)
+	}
+	if err := s.store.CartRepo.SetQuantity(ctx, userID, productID, quantity); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrCartItemMissing
+		}
+		return err
+	}
+	return nil
+}
+
+// カートから商品を削除
+func (s *CartService) RemoveItem(ctx context.Context, userID, productID int) error {
+	return s.store.CartRepo.RemoveItem(ctx, userID, productID)
+}
+
+// カートの中身を注文に変換し、カートを空にする
+// カートの読み取り・注文作成・カートの削除を1つのトランザクションで行う
+// 作成した注文（注文IDと公開IDのみ）を返す
+func (s *CartService) Checkout(ctx context.Context, userID int) ([]model.Order, error) {
+	var inserted []model.Order
+	var lowStock []model.StockLevel
+	err := s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		items, err := txStore.CartRepo.ListItemsForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return ErrCartEmpty
+		}
+		inserted, lowStock, err = createOrders(ctx, txStore, s.stock, userID, items)
+		if err != nil {
+			return err
+		}
+		return txStore.CartRepo.Clear(ctx, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.stock.publish(ctx, lowStock)
+	telemetry.RecordOrdersCreated(ctx, "checkout", len(inserted))
+	return inserted, nil
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"expvar"
+	"sync"
+
+	"backend/internal/config"
+)
+
+// 他の呼び出しの結果を共有した回数（操作ごと）。/debug/vars の coalesced_calls で参照できる
+var coalescedCalls = expvar.NewMap("coalesced_calls")
+
+// coalescer は同じキーの同時呼び出しをまとめ、最初の呼び出しの結果を全員に返す
+// RequestCoalescing が false の場合は何もせず毎回 fn を呼ぶ
+//
+// 結果は呼び出し側で共有されるため、スライスなどを書き換える場合はコピーしてから使うこと
+type coalescer[T any] struct {
+	op      string
+	enabled bool
+
+	mu    sync.Mutex
+	calls map[string]*coalescedCall[T]
+}
+
+// coalescedCall は実行中の1回の呼び出し
+type coalescedCall[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+	// 結果を待っている呼び出し元の数。0 になれば fn の ctx をキャンセルする
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func newCoalescer[T any](op string, cfg config.Cache) *coalescer[T] {
+	return &coalescer[T]{op: op, enabled: cfg.RequestCoalescing, calls: make(map[string]*coalescedCall[T])}
+}
+
+// do は key が同じ実行中の呼び出しがあればその結果を待ち、無ければ fn を実行する
+// fn の ctx は最初の呼び出し元のキャンセルでは終了せず（他の待ち手の結果まで失敗させないため）、
+// 待っている呼び出し元が全員キャンセルした時点でキャンセルされる
+// 各呼び出し元は自分の ctx がキャンセルされれば結果を待たずに戻る
+func (c *coalescer[T]) do(ctx context.Context, key string, fn func(ctx context.Context) (T, error)) (T, error) {
+	if !c.enabled {
+		return fn(ctx)
+	}
+	c.mu.Lock()
+	call, shared := c.calls[key]
+	if shared {
+		call.waiters++
+	} else {
+		fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &coalescedCall[T]{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		c.calls[key] = call
+		go c.run(fctx, key, call, fn)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		if shared {
+			coalescedCalls.Add(c.op, 1)
+		}
+		return call.val, call.err
+	case <-ctx.Done():
+		c.leave(key, call)
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+func (c *coalescer[T]) run(ctx context.Context, key string, call *coalescedCall[T], fn func(ctx context.Context) (T, error)) {
+	defer call.cancel()
+	call.val, call.err = fn(ctx)
+	c.mu.Lock()
+	c.forgetLocked(key, call)
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// leave は待つのをやめた呼び出し元を数え、誰も待っていなければ fn をキャンセルする
+// キャンセルした呼び出しは、後から来た呼び出し元と共有しない
+func (c *coalescer[T]) leave(key string, call *coalescedCall[T]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	call.waiters--
+	if call.waiters > 0 {
+		return
+	}
+	call.cancel()
+	c.forgetLocked(key, call)
+}
+
+func (c *coalescer[T]) forgetLocked(key string, call *coalescedCall[T]) {
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"backend/internal/config"
+)
+
+// 待っている呼び出し元が全員キャンセルした場合だけ、共有している fn をキャンセルする
+func TestCoalescerCancelsWhenAllWaitersLeave(t *testing.T) {
+	c := newCoalescer[int]("test", config.Cache{RequestCoalescing: true})
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	fn := func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+		return 0, ctx.Err()
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() {
+		_, err := c.do(ctx1, "key", fn)
+		errs <- err
+	}()
+	<-started
+	go func() {
+		_, err := c.do(ctx2, "key", fn)
+		errs <- err
+	}()
+	// 2人目が待ち始めるまで待つ
+	for {
+		c.mu.Lock()
+		waiters := c.calls["key"].waiters
+		c.mu.Unlock()
+		if waiters == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel1()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("first caller err = %v, want context.Canceled", err)
+	}
+	select {
+	case <-canceled:
+		t.Fatal("fn was canceled while another caller was waiting")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	cancel2()
+	<-errs
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("fn was not canceled after every caller left")
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/migrations"
+	"backend/internal/repository"
+)
+
+const (
+	HealthOK          = "ok"
+	HealthUnavailable = "unavailable"
+	HealthDegraded    = "degraded"
+)
+
+// ReadinessReport は /readyz のレスポンス
+type ReadinessReport struct {
+	Status     string          `json:"status"`
+	Database   string          `json:"database"`
+	Migrations string          `json:"migrations"`
+	Replicas   []ReplicaHealth `json:"replicas,omitempty"`
+	// 起動直後のキャッシュの読み込み中のみ "warming"
+	Cache string `json:"cache,omitempty"`
+}
+
+type ReplicaHealth struct {
+	Status     string   `json:"status"`
+	LagSeconds *float64 `json:"lag_seconds,omitempty"`
+}
+
+type HealthService struct {
+	store         *repository.Store
+	maxReplicaLag time.Duration
+	// nil の場合はキャッシュの読み込みを待たない
+	warmer *CacheWarmer
+}
+
+// 許容するレプリカ遅延は ReadyMaxReplicaLag で設定する
+func NewHealthService(store *repository.Store, cfg *config.Config, warmer *CacheWarmer) *HealthService {
+	return &HealthService{store: store, maxReplicaLag: cfg.DB.ReadyMaxReplicaLag, warmer: warmer}
+}
+
+// Readiness はプライマリへの接続、マイグレーションの適用状況、レプリカの遅延を確認する
+// プライマリに接続できないか未適用のマイグレーションがある場合のみ unavailable とし、
+// レプリカの異常は読み取りがプライマリにフォールバックするため degraded として報告する
+// 起動直後のキャッシュの読み込み（CacheWarmer）が終わるまでも unavailable とし、冷えた状態でトラフィックを受けないようにする
+func (s *HealthService) Readiness(ctx context.Context) ReadinessReport {
+	report := ReadinessReport{Status: HealthOK, Database: HealthOK, Migrations: HealthOK}
+	if !s.warmer.Done() {
+		report.Status = HealthUnavailable
+		report.Cache = "warming"
+	}
+
+	if err := s.store.Ping(ctx); err != nil {
+		report.Status = HealthUnavailable
+		report.Database = HealthUnavailable
+		report.Migrations = HealthUnavailable
+		return report
+	}
+
+	applied, tracked, err := s.store.AppliedMigrationVersion(ctx)
+	switch {
+	case err != nil:
+		report.Status = HealthUnavailable
+		report.Migrations = HealthUnavailable
+	case tracked:
+		if latest, err := latestMigrationVersion(); err != nil || applied < latest {
+			report.Status = HealthUnavailable
+			report.Migrations = "pending"
+		}
+	}
+
+	for _, rs := range s.store.ReplicaStatuses(ctx) {
+		rh := ReplicaHealth{Status: HealthOK}
+		if rs.Lag != nil {
+			sec := rs.Lag.Seconds()
+			rh.LagSeconds = &sec
+		}
+		if !rs.Healthy || (rs.Lag != nil && *rs.Lag > s.maxReplicaLag) {
+			rh.Status = HealthDegraded
+			if report.Status == HealthOK {
+				report.Status = HealthDegraded
+			}
+		}
+		report.Replicas = append(report.Replicas, rh)
+	}
+	return report
+}
+
+func latestMigrationVersion() (int, error) {
+	ms, err := migrations.Load()
+	if err != nil || len(ms) == 0 {
+		return -1, err
+	}
+	return ms[len(ms)-1].Version, nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"slices"
+	"strings"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+var (
+	ErrInvalidNotificationChannel = errors.New("invalid notification channel")
+	// email チャネルを選んだがメールアドレスが無い、または不正
+	ErrInvalidNotificationEmail = errors.New("invalid notification email")
+)
+
+type NotificationService struct {
+	store *repository.Store
+}
+
+func NewNotificationService(store *repository.Store) *NotificationService {
+	return &NotificationService{store: store}
+}
+
+// GetPreferences はユーザーの通知設定を返す。未設定の場合は何も通知しない設定を返す
+func (s *NotificationService) GetPreferences(ctx context.Context, userID int) (model.NotificationPreferences, error) {
+	prefs, ok, err := s.store.NotifyRepo.Get(ctx, userID)
+	if err != nil {
+		return model.NotificationPreferences{}, err
+	}
+	if !ok {
+		return model.NotificationPreferences{Channels: []string{}}, nil
+	}
+	return prefs, nil
+}
+
+// UpdatePreferences はユーザーの通知設定を検証して保存する
+// チャネルは小文字にそろえて重複を除き、メールアドレスは表示名などを除いたアドレスだけを保存する
+func (s *NotificationService) UpdatePreferences(ctx context.Context, userID int, prefs model.NotificationPreferences) (model.NotificationPreferences, error) {
+	channels := []string{}
+	for _, ch := range prefs.Channels {
+		ch = strings.ToLower(strings.TrimSpace(ch))
+		switch ch {
+		case model.NotifyChannelLog, model.NotifyChannelWebhook, model.NotifyChannelEmail:
+		default:
+			return model.NotificationPreferences{}, ErrInvalidNotificationChannel
+		}
+		if !slices.Contains(channels, ch) {
+			channels = append(channels, ch)
+		}
+	}
+	prefs.Channels = channels
+
+	prefs.Email = strings.TrimSpace(prefs.Email)
+	if prefs.Email != "" {
+		// メールのヘッダーにそのまま使うため、改行などを含む値は受け付けない
+		addr, err := mail.ParseAddress(prefs.Email)
+		if err != nil || addr.Name != "" {
+			return model.NotificationPreferences{}, ErrInvalidNotificationEmail
+		}
+		prefs.Email = addr.Address
+	} else if slices.Contains(channels, model.NotifyChannelEmail) {
+		return model.NotificationPreferences{}, ErrInvalidNotificationEmail
+	}
+
+	if err := s.store.NotifyRepo.Put(ctx, userID, prefs); err != nil {
+		return model.NotificationPreferences{}, err
+	}
+	return prefs, nil
+}
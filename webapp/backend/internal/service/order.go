@@ -1,47 +1,123 @@
 package service
 
 import (
+	"backend/internal/cache"
+	"backend/internal/config"
+	"backend/internal/events"
 	"backend/internal/model"
 	"backend/internal/repository"
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"golang.org/x/sync/errgroup"
 )
 
 type OrderService struct {
 	store *repository.Store
+	// 注文の取り消しをダッシュボードへ通知する（nil の場合は通知しない）
+	bus *events.Bus
+	// 同じユーザー・同じ条件の一覧取得の同時実行をまとめる
+	listFlight *coalescer[orderPage]
+	// 注文履歴の総件数のキャッシュ（nil の場合は無効）
+	countCache *cache.Cache[string, int]
+}
+
+type orderPage struct {
+	orders []model.Order
+	total  model.Total
+}
+
+func NewOrderService(store *repository.Store, bus *events.Bus, cfg *config.Config) *OrderService {
+	s := &OrderService{
+		store:      store,
+		bus:        bus,
+		listFlight: newCoalescer[orderPage]("FetchOrders", cfg.Cache),
+		countCache: newCountCache("order_count", defaultOrderCountCacheSize, cfg.Cache),
+	}
+	// 状態の変更では件数は変わらないため、作成のみで再計算させる
+	store.Changes().Subscribe(cache.EntityOrder, func(context.Context, cache.Change) { s.countCache.Expire() })
+	return s
+}
+
+// GetOrder は注文を1件取得する
+// orderID は middleware.OrderOwnerMiddleware で所有者を確認済みのものを渡す
+func (s *OrderService) GetOrder(ctx context.Context, orderID int64) (*model.Order, error) {
+	order, err := s.store.OrderRepo.FindByID(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+	return order, nil
+}
+
+// CancelOrder は配送前の注文を取り消す。配送中・配送済みの注文は ErrInvalidStatusTransition を返す
+// orderID は middleware.OrderOwnerMiddleware で所有者を確認済みのものを渡す
+func (s *OrderService) CancelOrder(ctx context.Context, orderID int64) error {
+	return transitionOrderStatus(ctx, s.store, s.bus, orderID, model.StatusCancelled)
 }
 
-func NewOrderService(store *repository.Store) *OrderService {
-	return &OrderService{store: store}
+// ListingVersion はユーザーから見た注文一覧のバージョン文字列と最終更新日時を返す
+// 注文の作成・配送状況の変更（updated_at）が無い限り同じ値になる
+func (s *OrderService) ListingVersion(ctx context.Context, userID int) (string, time.Time, error) {
+	updatedAt, count, err := s.store.OrderRepo.LastModified(ctx, userID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return fmt.Sprintf("%d-%d-%d", updatedAt.UnixNano(), count, userID), updatedAt, nil
 }
 
 // ユーザーの注文履歴を取得
-func (s *OrderService) FetchOrders(ctx context.Context, userID int, req model.ListRequest) ([]model.Order, int, error) {
-	orders, err := s.store.OrderRepo.ListOrders(ctx, userID, req)
+func (s *OrderService) FetchOrders(ctx context.Context, userID int, req model.ListRequest) ([]model.Order, model.Total, error) {
+	key := strconv.Itoa(userID) + "|" + listRequestKey(req)
+	page, err := s.listFlight.do(ctx, key, func(ctx context.Context) (orderPage, error) {
+		orders, total, err := s.fetchOrders(ctx, userID, req)
+		return orderPage{orders: orders, total: total}, err
+	})
 	if err != nil {
-		return nil, 0, err
+		return nil, model.Total{}, err
 	}
+	return append([]model.Order(nil), page.orders...), page.total, nil
+}
+
+// StreamOrders はユーザーの注文をページングせずに fn へ渡す（CSV / NDJSON のエクスポート用）
+func (s *OrderService) StreamOrders(ctx context.Context, userID int, req model.ListRequest, fn func(*model.Order) error) error {
+	return s.store.OrderRepo.StreamOrders(ctx, userID, req, fn)
+}
 
-	// 総件数は非同期で取得（初回レスポンスを高速化）
-	// バックグラウンドでgoroutineを使ってCOUNTを取得し、注文データの取得と並行実行
-	totalChan := make(chan int, 1)
-	errChan := make(chan error, 1)
-	go func() {
-		total, err := s.store.OrderRepo.CountOrders(context.Background(), userID, req)
+func (s *OrderService) fetchOrders(ctx context.Context, userID int, req model.ListRequest) ([]model.Order, model.Total, error) {
+	// 一覧と総件数は並行して取得し、どちらかが失敗するかリクエストが切断された時点で両方を打ち切る
+	// 注文の多いユーザーでは COUNT が重いため、キャッシュの古い件数があればそれを待たずに返し、裏で再計算する
+	g, gctx := errgroup.WithContext(ctx)
+	var orders []model.Order
+	g.Go(func() error {
+		var err error
+		orders, err = s.store.OrderRepo.ListOrders(gctx, userID, req)
+		return err
+	})
+	var total model.Total
+	g.Go(func() error {
+		count, stale, err := s.countCache.GetOrLoadStale(gctx, orderCountKey(userID, req), func(ctx context.Context) (int, error) {
+			return s.store.OrderRepo.CountOrders(ctx, userID, req)
+		})
 		if err != nil {
-			errChan <- err
-			return
+			return fmt.Errorf("count orders: %w", err)
 		}
-		totalChan <- total
-	}()
-
-	// 非同期で取得した総件数を待機（注文データは既に取得済みなので、レスポンスは高速）
-	select {
-	case total := <-totalChan:
-		return orders, total, nil
-	case <-errChan:
-		return orders, 0, nil
-	case <-ctx.Done():
-		// コンテキストがキャンセルされた場合は、0を返す
-		return orders, 0, nil
+		total = model.Total{Count: count, Approximate: stale}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return nil, model.Total{}, err
 	}
+	return orders, total, nil
+}
+
+// CountOrders の条件（ユーザーと検索語）だけをキーにする
+func orderCountKey(userID int, req model.ListRequest) string {
+	return fmt.Sprintf("%d|%q|%s", userID, req.Search, req.Type)
 }
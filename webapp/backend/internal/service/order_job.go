@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/model"
+	"backend/internal/workerpool"
+)
+
+var (
+	ErrOrderJobNotFound = errors.New("order job not found")
+	// 処理待ちのジョブが上限に達している
+	ErrOrderJobQueueFull = errors.New("order job queue is full")
+)
+
+// OrderJobQueue は注文の作成をジョブとして受け付け、ワーカーが ChunkSize 件ずつ別のトランザクションで作成する
+// 大量の注文でも HTTP の接続や DB のトランザクションを長く保持しないようにする
+// ジョブの状態はこのサーバーのメモリに保持するため、ほかのサーバーやこのサーバーの再起動後には参照できない
+type OrderJobQueue struct {
+	products  *ProductService
+	workers   *workerpool.Pool
+	chunkSize int
+	retention time.Duration
+	// 終了処理に入った後は、処理中のジョブも次のチャンクへ進まない
+	stopping atomic.Bool
+
+	mu   sync.Mutex
+	jobs map[string]*orderJob
+}
+
+type orderJob struct {
+	userID int
+	chunks [][]model.RequestItem
+	// mu で保護する
+	state model.OrderJob
+}
+
+func NewOrderJobQueue(products *ProductService, cfg *config.Config) *OrderJobQueue {
+	return &OrderJobQueue{
+		products: products,
+		workers: workerpool.New("order_jobs", workerpool.Options{
+			Workers:      cfg.OrderJobs.Workers,
+			QueueSize:    cfg.OrderJobs.QueueSize,
+			DrainTimeout: cfg.Server.ShutdownGracePeriod,
+		}),
+		chunkSize: cfg.OrderJobs.ChunkSize,
+		retention: cfg.OrderJobs.Retention,
+		jobs:      make(map[string]*orderJob),
+	}
+}
+
+// Run は ctx がキャンセルされるまで終了したジョブを定期的に破棄する
+// キャンセル後は処理中のチャンクの完了を待ち、残りのチャンクと処理待ちのジョブは失敗として終える
+func (q *OrderJobQueue) Run(ctx context.Context) {
+	ticker := time.NewTicker(q.retention / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			q.stopping.Store(true)
+			q.workers.Run(ctx)
+			return
+		case <-ticker.C:
+			q.prune(time.Now().Add(-q.retention))
+		}
+	}
+}
+
+// Enqueue は注文の作成をジョブとして受け付ける
+// 同じ商品が複数ある場合の扱いは CreateOrders と同じ（後の数量を使う）
+func (q *OrderJobQueue) Enqueue(ctx context.Context, userID int, items []model.RequestItem) (model.OrderJob, error) {
+	id, err := newOrderJobID()
+	if err != nil {
+		return model.OrderJob{}, err
+	}
+	chunks := chunkOrderItems(items, q.chunkSize)
+	total := 0
+	for _, chunk := range chunks {
+		for _, item := range chunk {
+			total += item.Quantity
+		}
+	}
+	job := &orderJob{
+		userID: userID,
+		chunks: chunks,
+		state: model.OrderJob{
+			JobID:          id,
+			Status:         model.OrderJobQueued,
+			Total:          total,
+			OrderIDs:       []string{},
+			PublicOrderIDs: []string{},
+			CreatedAt:      time.Now().UTC(),
+		},
+	}
+
+	// ワーカーはジョブを登録し終えるまで q.mu で待つ
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	err = q.workers.Submit(ctx, func(ctx context.Context) { q.process(ctx, job) })
+	if errors.Is(err, workerpool.ErrSaturated) || errors.Is(err, workerpool.ErrClosed) {
+		return model.OrderJob{}, ErrOrderJobQueueFull
+	}
+	if err != nil {
+		return model.OrderJob{}, err
+	}
+	q.jobs[id] = job
+	return job.snapshot(), nil
+}
+
+// Get はジョブの状態を返す。ほかのユーザーのジョブは見つからないものとして扱う
+func (q *OrderJobQueue) Get(userID int, jobID string) (model.OrderJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[jobID]
+	if !ok || job.userID != userID {
+		return model.OrderJob{}, ErrOrderJobNotFound
+	}
+	return job.snapshot(), nil
+}
+
+func (q *OrderJobQueue) process(ctx context.Context, job *orderJob) {
+	q.update(job, func(s *model.OrderJob) { s.Status = model.OrderJobRunning })
+	for _, chunk := range job.chunks {
+		if q.stopping.Load() {
+			q.finish(job, model.OrderJobFailed, "server is shutting down")
+			return
+		}
+		orders, err := q.products.CreateOrders(ctx, job.userID, chunk)
+		if err != nil {
+			slog.WarnContext(ctx, "order job failed", "job_id", job.state.JobID, "err", err)
+			if errors.Is(err, ErrInsufficientStock) {
+				q.finish(job, model.OrderJobFailed, "insufficient stock")
+				return
+			}
+			q.finish(job, model.OrderJobFailed, "failed to create orders")
+			return
+		}
+		q.update(job, func(s *model.OrderJob) {
+			for i := range orders {
+				s.OrderIDs = append(s.OrderIDs, strconv.FormatInt(orders[i].OrderID, 10))
+				s.PublicOrderIDs = append(s.PublicOrderIDs, orders[i].ExternalID())
+			}
+			s.Created = len(s.OrderIDs)
+		})
+	}
+	q.finish(job, model.OrderJobSucceeded, "")
+}
+
+func (q *OrderJobQueue) update(job *orderJob, fn func(s *model.OrderJob)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	fn(&job.state)
+}
+
+func (q *OrderJobQueue) finish(job *orderJob, status, message string) {
+	now := time.Now().UTC()
+	q.update(job, func(s *model.OrderJob) {
+		s.Status = status
+		s.Error = message
+		s.FinishedAt = &now
+	})
+}
+
+// prune は before より前に終了したジョブを破棄する
+func (q *OrderJobQueue) prune(before time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for id, job := range q.jobs {
+		if job.state.FinishedAt != nil && job.state.FinishedAt.Before(before) {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// snapshot は呼び出し側が q.mu を保持していること
+func (j *orderJob) snapshot() model.OrderJob {
+	s := j.state
+	s.OrderIDs = append([]string{}, j.state.OrderIDs...)
+	s.PublicOrderIDs = append([]string{}, j.state.PublicOrderIDs...)
+	return s
+}
+
+// chunkOrderItems は注文の数量の合計が size 以下になるよう items を分割する（数量が size を超える商品は複数に分ける）
+func chunkOrderItems(items []model.RequestItem, size int) [][]model.RequestItem {
+	quantities := make(map[int]int)
+	var order []int
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			continue
+		}
+		if _, ok := quantities[item.ProductID]; !ok {
+			order = append(order, item.ProductID)
+		}
+		quantities[item.ProductID] = item.Quantity
+	}
+
+	var chunks [][]model.RequestItem
+	var chunk []model.RequestItem
+	room := size
+	for _, productID := range order {
+		for remaining := quantities[productID]; remaining > 0; {
+			n := min(remaining, room)
+			chunk = append(chunk, model.RequestItem{ProductID: productID, Quantity: n})
+			remaining -= n
+			room -= n
+			if room == 0 {
+				chunks = append(chunks, chunk)
+				chunk, room = nil, size
+			}
+		}
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func newOrderJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"backend/internal/events"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+// transitionOrderStatus は注文の状態を newStatus へ変更し、変更を通知する（bus が nil の場合は通知しない）
+// 存在しない注文は ErrOrderNotFound、現在の状態から遷移できない場合は ErrInvalidStatusTransition を返す（同じ状態への変更は何もしない）
+func transitionOrderStatus(ctx context.Context, store *repository.Store, bus *events.Bus, orderID int64, newStatus model.ShippedStatus) error {
+	current, err := store.OrderRepo.GetStatus(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrOrderNotFound
+		}
+		return err
+	}
+	if current == newStatus {
+		return nil
+	}
+	if !model.CanTransition(current, newStatus) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidStatusTransition, current, newStatus)
+	}
+
+	publicIDs, err := store.OrderRepo.PublicIDs(ctx, []int64{orderID})
+	if err != nil {
+		return err
+	}
+	changed := events.NewOrderStatusChanged([]int64{orderID}, publicIDs, current, newStatus)
+	// 読み込んだ状態のままの場合だけ更新し、その間に他の更新があれば遷移をやり直させない
+	update := func(s *repository.Store) error {
+		affected, err := s.OrderRepo.UpdateStatusesConditional(ctx, []int64{orderID}, newStatus, current)
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return fmt.Errorf("%w: order %d is no longer %s", ErrInvalidStatusTransition, orderID, current)
+		}
+		return nil
+	}
+	if !store.OutboxEnabled() {
+		err = update(store)
+	} else {
+		err = store.ExecTx(ctx, func(txStore *repository.Store) error {
+			if err := update(txStore); err != nil {
+				return err
+			}
+			return txStore.OutboxRepo.Add(ctx, events.OrderStatusEventType(newStatus), orderID, changed)
+		})
+	}
+	if err != nil {
+		return err
+	}
+	if bus != nil {
+		bus.Publish(ctx, events.OrderStatusEventType(newStatus), changed)
+	}
+	return nil
+}
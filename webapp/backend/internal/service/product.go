@@ -2,82 +2,565 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"backend/internal/cache"
+	"backend/internal/config"
+	"backend/internal/events"
+	"backend/internal/featureflag"
 	"backend/internal/model"
 	"backend/internal/repository"
+	"backend/internal/search"
+	"backend/internal/telemetry"
+
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrInvalidProduct  = errors.New("invalid product")
+	// 一覧取得のソート指定がホワイトリストに含まれない
+	ErrInvalidSort = repository.ErrInvalidSort
+	// 一覧取得のカーソルが不正
+	ErrInvalidCursor = repository.ErrInvalidCursor
+	// 注文数が在庫数を上回る
+	ErrInsufficientStock = repository.ErrInsufficientStock
 )
 
 type ProductService struct {
 	store *repository.Store
+	// 検索エンジン（nil の場合はリポジトリの LIKE 検索を使用）
+	searchIndex search.SearchIndex
+	// 商品一覧のキャッシュ（nil の場合は無効）
+	listCache *cache.Cache[string, productPage]
+	// 0件だった検索語のキャッシュ（nil の場合は無効）
+	negativeCache *cache.Cache[string, struct{}]
+	// 商品の総件数のキャッシュ（nil の場合は無効）
+	countCache *cache.Cache[string, int]
+	// カテゴリ一覧のキャッシュ（nil の場合は無効）
+	categoryCache *cache.Cache[struct{}, []model.Category]
+	// 一覧の ETag のバージョンのキャッシュ（nil の場合は無効）
+	productVersion  *cache.Cache[struct{}, listingVersion]
+	favoriteVersion *cache.Cache[int, listingVersion]
+	stock           *stockAlerter
+	flags           *featureflag.Set
+	// 同じ条件の一覧取得の同時実行をまとめる
+	listFlight *coalescer[productPage]
+
+	// 商品が変更された際に呼び出すフック（検索エンジンの同期など）
+	hooksMu       sync.RWMutex
+	onChangeHooks []func(ctx context.Context, productID int)
 }
 
-func NewProductService(store *repository.Store) *ProductService {
-	return &ProductService{store: store}
+func NewProductService(store *repository.Store, searchIndex search.SearchIndex, bus *events.Bus, cfg *config.Config, flags *featureflag.Set) *ProductService {
+	s := &ProductService{
+		store:         store,
+		searchIndex:   searchIndex,
+		listCache:     newProductListCache(cfg.Cache),
+		negativeCache: newNegativeSearchCache(cfg.Cache),
+		countCache:    newCountCache("product_count", defaultCountCacheSize, cfg.Cache),
+		categoryCache: newCategoryCache(cfg.Cache),
+		stock:         newStockAlerter(bus, cfg.Stock),
+		flags:         flags,
+		listFlight:    newCoalescer[productPage]("FetchProducts", cfg.Cache),
+	}
+	s.productVersion, s.favoriteVersion = newListingVersionCaches(cfg.Cache)
+	changes := store.Changes()
+	changes.Subscribe(cache.EntityProduct, func(context.Context, cache.Change) { s.purgeCaches() })
+	changes.Subscribe(cache.EntityProductStock, func(_ context.Context, c cache.Change) { s.evictProductPages(c.ID) })
+	changes.Subscribe(cache.EntityFavorite, func(_ context.Context, c cache.Change) { s.evictFavoriteVersion(c.ID) })
+	return s
 }
 
-func (s *ProductService) CreateOrders(ctx context.Context, userID int, items []model.RequestItem) ([]string, error) {
-	var insertedOrderIDs []string
+// OnProductChanged は商品の作成・更新・削除後に呼び出されるフックを登録する
+// 一括インポートなど、変更された商品を特定できない場合は productID に 0 を渡す
+func (s *ProductService) OnProductChanged(fn func(ctx context.Context, productID int)) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	s.onChangeHooks = append(s.onChangeHooks, fn)
+}
+
+func (s *ProductService) notifyProductChanged(ctx context.Context, productID int) {
+	s.hooksMu.RLock()
+	defer s.hooksMu.RUnlock()
+	for _, fn := range s.onChangeHooks {
+		fn(ctx, productID)
+	}
+}
+
+// CreateOrders は注文を作成し、作成した注文（注文IDと公開IDのみ）を返す
+func (s *ProductService) CreateOrders(ctx context.Context, userID int, items []model.RequestItem) ([]model.Order, error) {
+	var inserted []model.Order
+	var lowStock []model.StockLevel
 
 	err := s.store.ExecTx(ctx, func(txStore *repository.Store) error {
-		itemsToProcess := make(map[int]int)
-		for _, item := range items {
-			if item.Quantity > 0 {
-				itemsToProcess[item.ProductID] = item.Quantity
+		var err error
+		inserted, lowStock, err = createOrders(ctx, txStore, s.stock, userID, items)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	s.stock.publish(ctx, lowStock)
+	telemetry.RecordOrdersCreated(ctx, "order", len(inserted))
+	return inserted, nil
+}
+
+// 商品ごとの数量分だけ注文を作成し、在庫を減らす（トランザクション内で使用）
+// 今回の注文で在庫が閾値を下回った商品を返すので、コミット後に stockAlerter.publish で通知すること
+// 在庫の減算は商品の行ロックを取るため、注文の作成をすべて終えてからトランザクションの最後に行い、人気商品の注文同士が待ち合う時間を短くする
+// 複数の商品を含む注文同士がデッドロックしないよう、商品IDの昇順に処理する。在庫が足りない商品があれば ErrInsufficientStock を返す
+func createOrders(ctx context.Context, txStore *repository.Store, stock *stockAlerter, userID int, items []model.RequestItem) ([]model.Order, []model.StockLevel, error) {
+	var inserted []model.Order
+	var lowStock []model.StockLevel
+
+	itemsToProcess := make(map[int]int)
+	for _, item := range items {
+		if item.Quantity > 0 {
+			itemsToProcess[item.ProductID] = item.Quantity
+		}
+	}
+	if len(itemsToProcess) == 0 {
+		return nil, nil, nil
+	}
+	productIDs := make([]int, 0, len(itemsToProcess))
+	for pID := range itemsToProcess {
+		productIDs = append(productIDs, pID)
+	}
+	sort.Ints(productIDs)
+
+	for _, pID := range productIDs {
+		quantity := itemsToProcess[pID]
+		// イベントは外部へ配信されるため、注文IDには公開IDを使う
+		productOrderIDs := make([]string, 0, quantity)
+		var firstOrderID int64
+		for i := 0; i < quantity; i++ {
+			order := model.Order{
+				UserID:    userID,
+				ProductID: pID,
+			}
+			if err := txStore.OrderRepo.Create(ctx, &order); err != nil {
+				return nil, nil, err
 			}
+			if i == 0 {
+				firstOrderID = order.OrderID
+			}
+			inserted = append(inserted, order)
+			productOrderIDs = append(productOrderIDs, order.ExternalID())
 		}
-		if len(itemsToProcess) == 0 {
-			return nil
+
+		// 集約IDには商品ごとの先頭の注文IDを使う
+		created := events.OrderCreated{UserID: userID, ProductID: pID, OrderIDs: productOrderIDs}
+		if err := txStore.OutboxRepo.Add(ctx, events.TypeOrderCreated, firstOrderID, created); err != nil {
+			return nil, nil, err
 		}
+	}
 
-		for pID, quantity := range itemsToProcess {
-			for i := 0; i < quantity; i++ {
-				order := &model.Order{
-					UserID:    userID,
-					ProductID: pID,
-				}
-				orderID, err := txStore.OrderRepo.Create(ctx, order)
-				if err != nil {
-					return err
-				}
-				insertedOrderIDs = append(insertedOrderIDs, orderID)
-			}
+	for _, pID := range productIDs {
+		quantity := itemsToProcess[pID]
+		remaining, err := txStore.ProductRepo.DecrementStock(ctx, pID, quantity)
+		if err != nil {
+			return nil, nil, err
+		}
+		if remaining != nil && stock.crossed(*remaining+quantity, *remaining) {
+			lowStock = append(lowStock, model.StockLevel{ProductID: pID, Stock: *remaining})
+		}
+	}
+	return inserted, lowStock, nil
+}
+
+// 在庫が閾値未満の商品一覧を取得（管理者用）
+func (s *ProductService) FetchLowStock(ctx context.Context) ([]model.StockLevel, error) {
+	return s.store.ProductRepo.ListLowStock(ctx, s.stock.threshold)
+}
+
+func (s *ProductService) FetchProducts(ctx context.Context, userID int, req model.ListRequest) ([]model.Product, model.Total, error) {
+	var negativeKey string
+	if req.Search != "" && s.negativeCache != nil {
+		negativeKey = negativeSearchKey(req)
+		if _, ok := s.negativeCache.Get(ctx, negativeKey); ok {
+			return []model.Product{}, model.Total{}, nil
+		}
+	}
+
+	// 一覧はユーザーに依存しないため、同じ条件のリクエストはユーザーをまたいでまとめられる
+	// キャッシュを使うかどうかはユーザーごとに決まるため、キーを分けてまとめる
+	useCache := s.flags.Enabled(featureflag.ProductListCache, userID)
+	key := listRequestKey(req)
+	if !useCache {
+		key += "|nocache"
+	}
+	page, err := s.listFlight.do(ctx, key, func(ctx context.Context) (productPage, error) {
+		products, total, err := s.fetchProductsCached(ctx, userID, req, useCache)
+		return productPage{products: products, total: total}, err
+	})
+	if err != nil {
+		return nil, model.Total{}, err
+	}
+	// お気に入りの付与で書き換えるため、共有された結果をコピーする
+	products, total := append([]model.Product(nil), page.products...), page.total
+	// 先頭ページが0件の場合のみ、検索語自体に一致する商品が無いと判断できる
+	if negativeKey != "" && len(products) == 0 && req.Offset == 0 && req.Cursor == "" {
+		s.negativeCache.Set(negativeKey, struct{}{})
+	}
+	if err := s.markFavorites(ctx, userID, products); err != nil {
+		return nil, model.Total{}, err
+	}
+	return products, total, nil
+}
+
+// StreamProducts は検索条件に一致する商品をページングせずに fn へ渡す（CSV / NDJSON のエクスポート用）
+// 一覧のキャッシュ・検索エンジン・お気に入りの付与は使わない
+// 直前の書き込みを読み返す必要が無いため、レプリカがあればレプリカから読む
+func (s *ProductService) StreamProducts(ctx context.Context, req model.ListRequest, fn func(*model.Product) error) error {
+	return s.store.ProductRepo.StreamProducts(repository.AllowReplica(ctx), req, fn)
+}
+
+type productPage struct {
+	products []model.Product
+	total    model.Total
+}
+
+// 検索条件に一致する商品のファセットごとの件数を取得（レプリカがあればレプリカから読む）
+func (s *ProductService) FetchFacets(ctx context.Context, req model.ListRequest) (*model.Facets, error) {
+	return s.store.ProductRepo.CountFacets(repository.AllowReplica(ctx), req)
+}
+
+// NextProductCursor は一覧の次ページを取得するためのカーソルを返す
+// 最終ページ、または検索エンジン経由・関連度順など、キーセットページングに対応しない場合は空文字を返す
+// cursor_pagination フラグが無効なユーザーにも空文字を返す（受け取ったカーソルは引き続き受け付ける）
+func (s *ProductService) NextProductCursor(userID int, req model.ListRequest, products []model.Product) string {
+	if !s.flags.Enabled(featureflag.CursorPagination, userID) {
+		return ""
+	}
+	if len(products) == 0 || len(products) < req.PageSize {
+		return ""
+	}
+	if req.SortField == "relevance" || (req.Search != "" && s.searchIndex != nil) {
+		return ""
+	}
+	cursor, err := s.store.ProductRepo.EncodeCursor(req, products[len(products)-1])
+	if err != nil {
+		return ""
+	}
+	return cursor
+}
+
+// ListingVersion はユーザーから見た商品一覧のバージョン文字列と最終更新日時を返す
+// 商品の最終更新日時・件数と、ユーザーのお気に入りの状態が変わらない限り同じ値になる
+// どちらも変更の通知があるまではキャッシュした値を使い、一覧のリクエストごとには DB を引かない
+func (s *ProductService) ListingVersion(ctx context.Context, userID int) (string, time.Time, error) {
+	products, err := s.productVersion.GetOrLoad(ctx, struct{}{}, func(ctx context.Context) (listingVersion, error) {
+		updatedAt, count, err := s.store.ProductRepo.LastModified(ctx)
+		return listingVersion{at: updatedAt, count: count}, err
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	favorites, err := s.favoriteVersion.GetOrLoad(ctx, userID, func(ctx context.Context) (listingVersion, error) {
+		favAt, favCount, err := s.store.FavoriteRepo.Version(ctx, userID)
+		return listingVersion{at: favAt, count: favCount}, err
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	lastModified := products.at
+	if favorites.at.After(lastModified) {
+		lastModified = favorites.at
+	}
+	return fmt.Sprintf("%d-%d-%d-%d-%d", products.at.UnixNano(), products.count, userID, favorites.at.UnixNano(), favorites.count), lastModified, nil
+}
+
+// 一覧の商品にお気に入り登録状態を付与する
+func (s *ProductService) markFavorites(ctx context.Context, userID int, products []model.Product) error {
+	ids := make([]int, len(products))
+	for i, p := range products {
+		ids[i] = p.ProductID
+	}
+	favorited, err := s.store.FavoriteRepo.FavoritedProductIDs(ctx, userID, ids)
+	if err != nil {
+		return err
+	}
+	for i := range products {
+		products[i].Favorited = favorited[products[i].ProductID]
+	}
+	return nil
+}
+
+// FetchProductsByIDs は ids の順序を保ったまま商品を取得し、お気に入り登録状態を付与する（存在しない商品は含まない）
+func (s *ProductService) FetchProductsByIDs(ctx context.Context, userID int, ids []int) ([]model.Product, error) {
+	products, err := s.store.ProductRepo.GetProductsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.markFavorites(ctx, userID, products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// 商品一覧はユーザーに依存しないため、お気に入り情報を付与する前の結果をキャッシュする
+func (s *ProductService) fetchProductsCached(ctx context.Context, userID int, req model.ListRequest, useCache bool) ([]model.Product, model.Total, error) {
+	if s.listCache == nil || !useCache {
+		return s.fetchProducts(ctx, userID, req)
+	}
+	page, err := s.listCache.GetOrLoad(ctx, listRequestKey(req), func(ctx context.Context) (productPage, error) {
+		products, total, err := s.fetchProducts(ctx, userID, req)
+		return productPage{products: products, total: total}, err
+	})
+	if err != nil {
+		return nil, model.Total{}, err
+	}
+	return page.products, page.total, nil
+}
+
+func (s *ProductService) fetchProducts(ctx context.Context, userID int, req model.ListRequest) ([]model.Product, model.Total, error) {
+	if req.Search != "" && s.searchIndex != nil {
+		products, total, err := s.searchProducts(ctx, req)
+		return products, model.Total{Count: total}, err
+	}
+
+	// 一覧と総件数は並行して取得し、どちらかが失敗するかリクエストが切断された時点で両方を打ち切る
+	// キャッシュの古い件数があればそれを待たずに返し、裏で再計算する
+	// 一覧はキャッシュで数十秒古い結果も返しているため、レプリカがあればレプリカから読む
+	g, gctx := errgroup.WithContext(repository.AllowReplica(ctx))
+	var products []model.Product
+	g.Go(func() error {
+		var err error
+		products, err = s.store.ProductRepo.ListProducts(gctx, userID, req)
+		return err
+	})
+	var total model.Total
+	g.Go(func() error {
+		count, stale, err := s.countCache.GetOrLoadStale(gctx, countKey(req), func(ctx context.Context) (int, error) {
+			return s.store.ProductRepo.CountProducts(ctx, userID, req)
+		})
+		if err != nil {
+			return fmt.Errorf("count products: %w", err)
 		}
+		total = model.Total{Count: count, Approximate: stale}
 		return nil
 	})
+	if err := g.Wait(); err != nil {
+		return nil, model.Total{}, err
+	}
+	return products, total, nil
+}
+
+const (
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 20
+)
+
+// 入力補完用に商品名の候補を取得
+func (s *ProductService) SuggestProducts(ctx context.Context, query string, limit int) ([]string, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return []string{}, nil
+	}
+	if limit <= 0 {
+		limit = defaultSuggestLimit
+	}
+	if limit > maxSuggestLimit {
+		limit = maxSuggestLimit
+	}
+	return s.store.ProductRepo.SuggestProductNames(repository.AllowReplica(ctx), query, limit)
+}
+
+// 商品に紐づく画像パスを取得
+func (s *ProductService) GetProductImage(ctx context.Context, productID int) (string, error) {
+	image, err := s.store.ProductRepo.GetProductImage(ctx, productID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrProductNotFound
+		}
+		return "", err
+	}
+	if image == "" {
+		return "", ErrProductNotFound
+	}
+	return image, nil
+}
 
+// お気に入りに追加
+func (s *ProductService) AddFavorite(ctx context.Context, userID, productID int) error {
+	if _, err := s.store.ProductRepo.GetProduct(ctx, productID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrProductNotFound
+		}
+		return err
+	}
+	return s.store.FavoriteRepo.Add(ctx, userID, productID)
+}
+
+// お気に入りから削除
+func (s *ProductService) RemoveFavorite(ctx context.Context, userID, productID int) error {
+	return s.store.FavoriteRepo.Remove(ctx, userID, productID)
+}
+
+// お気に入り一覧を取得
+func (s *ProductService) FetchFavorites(ctx context.Context, userID, page, pageSize int) ([]model.Product, int, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+	total, err := s.store.FavoriteRepo.CountByUser(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	products, err := s.store.FavoriteRepo.ListByUser(ctx, userID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	return products, total, nil
+}
+
+// カテゴリ一覧を取得
+func (s *ProductService) FetchCategories(ctx context.Context) ([]model.Category, error) {
+	categories, err := s.categoryCache.GetOrLoad(ctx, struct{}{}, s.store.CategoryRepo.ListCategories)
 	if err != nil {
 		return nil, err
 	}
-	return insertedOrderIDs, nil
+	return append([]model.Category(nil), categories...), nil
 }
 
-func (s *ProductService) FetchProducts(ctx context.Context, userID int, req model.ListRequest) ([]model.Product, int, error) {
-	products, err := s.store.ProductRepo.ListProducts(ctx, userID, req)
+// 検索エンジンで商品IDを検索し、商品本体はDBから主キーで取得する
+func (s *ProductService) searchProducts(ctx context.Context, req model.ListRequest) ([]model.Product, int, error) {
+	hits, total, err := s.searchIndex.Search(ctx, req)
 	if err != nil {
 		return nil, 0, err
 	}
+	ids := make([]int, len(hits))
+	scores := make(map[int]float64, len(hits))
+	for i, h := range hits {
+		ids[i] = h.ProductID
+		scores[h.ProductID] = h.Score
+	}
+	products, err := s.store.ProductRepo.GetProductsByIDs(ctx, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range products {
+		score := scores[products[i].ProductID]
+		products[i].Relevance = &score
+	}
+	return products, total, nil
+}
+
+// 商品入力の検証
+func validateProductInput(in model.ProductInput) error {
+	if strings.TrimSpace(in.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidProduct)
+	}
+	if in.Value < 0 {
+		return fmt.Errorf("%w: value must be non-negative", ErrInvalidProduct)
+	}
+	if in.Weight < 0 {
+		return fmt.Errorf("%w: weight must be non-negative", ErrInvalidProduct)
+	}
+	if in.Currency != "" && !model.ValidCurrency(in.Currency) {
+		return fmt.Errorf("%w: currency must be an ISO 4217 code", ErrInvalidProduct)
+	}
+	return nil
+}
+
+func productFromInput(productID int, in model.ProductInput) *model.Product {
+	return &model.Product{
+		ProductID:   productID,
+		Name:        strings.TrimSpace(in.Name),
+		Value:       in.Value,
+		Currency:    model.NewMoney(int64(in.Value), in.Currency).Currency,
+		Weight:      in.Weight,
+		Image:       in.Image,
+		Description: in.Description,
+		CategoryID:  in.CategoryID,
+		Stock:       in.Stock.Value,
+	}
+}
+
+// 商品を作成（管理者用）
+func (s *ProductService) CreateProduct(ctx context.Context, in model.ProductInput) (*model.Product, error) {
+	if err := validateProductInput(in); err != nil {
+		return nil, err
+	}
+	product := productFromInput(0, in)
+	id, err := s.store.ProductRepo.CreateProduct(ctx, product)
+	if err != nil {
+		return nil, err
+	}
+	product.ProductID = id
+	s.notifyProductChanged(ctx, id)
+	return product, nil
+}
 
-	// 総件数は非同期で取得（初回レスポンスを高速化）
-	// バックグラウンドでgoroutineを使ってCOUNTを取得し、商品データの取得と並行実行
-	totalChan := make(chan int, 1)
-	errChan := make(chan error, 1)
-	go func() {
-		total, err := s.store.ProductRepo.CountProducts(context.Background(), userID, req)
+// 商品を更新（管理者用）
+// 価値（value）が変わる場合は、同じトランザクション内で価格履歴を記録する
+func (s *ProductService) UpdateProduct(ctx context.Context, userID, productID int, in model.ProductInput) (*model.Product, error) {
+	if err := validateProductInput(in); err != nil {
+		return nil, err
+	}
+	product := productFromInput(productID, in)
+	err := s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		if err := txStore.ProductRepo.LockProduct(ctx, productID); err != nil {
+			return err
+		}
+		current, err := txStore.ProductRepo.GetProduct(ctx, productID)
 		if err != nil {
-			errChan <- err
-			return
-		}
-		totalChan <- total
-	}()
-
-	// 非同期で取得した総件数を待機（商品データは既に取得済みなので、レスポンスは高速）
-	select {
-	case total := <-totalChan:
-		return products, total, nil
-	case <-errChan:
-		return products, 0, nil
-	case <-ctx.Done():
-		// コンテキストがキャンセルされた場合は、0を返す
-		return products, 0, nil
+			return err
+		}
+		// 在庫数を省略した更新では、現在の在庫数を残す
+		if !in.Stock.Set {
+			product.Stock = current.Stock
+		}
+		if err := txStore.ProductRepo.UpdateProduct(ctx, product); err != nil {
+			return err
+		}
+		if current.Value == product.Value {
+			return nil
+		}
+		return txStore.PriceHistRepo.Create(ctx, &model.PriceHistory{
+			ProductID: productID,
+			OldValue:  current.Value,
+			NewValue:  product.Value,
+			ChangedBy: &userID,
+			Reason:    strings.TrimSpace(in.ChangeReason),
+			ChangedAt: time.Now().UTC(),
+		})
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+	s.notifyProductChanged(ctx, productID)
+	return product, nil
+}
+
+const priceHistoryLimit = 100
+
+// 商品の価値の変更履歴を取得
+func (s *ProductService) FetchPriceHistory(ctx context.Context, productID int) ([]model.PriceHistory, error) {
+	return s.store.PriceHistRepo.ListByProduct(ctx, productID, priceHistoryLimit)
+}
+
+// 商品を削除（管理者用）
+func (s *ProductService) DeleteProduct(ctx context.Context, productID int) error {
+	if err := s.store.ProductRepo.DeleteProduct(ctx, productID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrProductNotFound
+		}
+		return err
 	}
+	s.notifyProductChanged(ctx, productID)
+	return nil
 }
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"backend/internal/cache"
+	"backend/internal/config"
+	"backend/internal/model"
+)
+
+const (
+	defaultNegativeSearchSize = 4096
+	defaultCountCacheSize     = 1024
+	// 注文履歴の総件数はユーザーごとに持つ
+	defaultOrderCountCacheSize = 10000
+	// お気に入りのバージョンはユーザーごとに持つ
+	defaultFavoriteVersionCacheSize = 10000
+)
+
+// 商品一覧のページ単位のキャッシュ（LRU + TTL）
+// 負荷試験中は未検索の先頭数ページが繰り返し要求されるため、短いTTLでもDBアクセスを大きく減らせる
+// ProductListTTL（0 で無効）と ProductListSize で設定する
+func newProductListCache(cfg config.Cache) *cache.Cache[string, productPage] {
+	if cfg.ProductListTTL <= 0 {
+		return nil
+	}
+	return cache.New[string, productPage]("product_list", cache.Options[productPage]{
+		MaxSize: cfg.ProductListSize,
+		// 総件数が古い値（再計算中）のページは、再計算後の件数を返せるよう保持しない
+		TTLFunc: func(page productPage) time.Duration {
+			if page.total.Approximate {
+				return 0
+			}
+			return cfg.ProductListTTL
+		},
+	})
+}
+
+// purgeCaches は商品一覧・0件の検索語のキャッシュを破棄し、総件数を再計算させる（商品の作成・更新・削除時）
+func (s *ProductService) purgeCaches() {
+	s.listCache.Purge()
+	s.productVersion.Purge()
+	s.negativeCache.Purge()
+	s.countCache.Expire()
+}
+
+// evictProductPages は productID を含む商品一覧のページだけを破棄する（在庫数の変更時）
+// 在庫数は並びや件数に影響しないため、ほかのページと総件数はそのまま使える
+func (s *ProductService) evictProductPages(productID string) {
+	// 在庫数の変更でも updated_at は変わるため、一覧のバージョンは破棄する
+	s.productVersion.Purge()
+	id, err := strconv.Atoi(productID)
+	if err != nil {
+		s.listCache.Purge()
+		return
+	}
+	s.listCache.DeleteFunc(func(_ string, page productPage) bool {
+		for _, p := range page.products {
+			if p.ProductID == id {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// listingVersion は一覧の ETag の元になる最終更新日時と件数
+type listingVersion struct {
+	at    time.Time
+	count int
+}
+
+// 商品一覧の ETag のバージョンのキャッシュ（商品全体で1件、お気に入りはユーザーごと）
+// 一覧のリクエストのたびに MAX(updated_at) と COUNT(*) を実行しないよう、変更の通知（cache.Bus）があるまで保持する
+// ListingVersionTTL（0 で無効）で設定する
+func newListingVersionCaches(cfg config.Cache) (*cache.Cache[struct{}, listingVersion], *cache.Cache[int, listingVersion]) {
+	if cfg.ListingVersionTTL <= 0 {
+		return nil, nil
+	}
+	products := cache.New[struct{}, listingVersion]("product_version", cache.Options[listingVersion]{TTL: cfg.ListingVersionTTL})
+	favorites := cache.New[int, listingVersion]("favorite_version", cache.Options[listingVersion]{
+		TTL:     cfg.ListingVersionTTL,
+		MaxSize: defaultFavoriteVersionCacheSize,
+	})
+	return products, favorites
+}
+
+// evictFavoriteVersion はユーザーのお気に入りのバージョンを破棄する（お気に入りの追加・削除時）
+func (s *ProductService) evictFavoriteVersion(userID string) {
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		s.favoriteVersion.Purge()
+		return
+	}
+	s.favoriteVersion.Delete(id)
+}
+
+// 検索条件・ソート・ページからキャッシュキーを生成（注文一覧の同時実行をまとめるキーにも使う）
+func listRequestKey(req model.ListRequest) string {
+	return fmt.Sprintf("%q|%s|%d|%s|%s|%d|%d|%s", req.Search, req.Type, req.Category, req.SortField, req.SortOrder, req.Page, req.PageSize, req.Cursor)
+}
+
+// 結果が0件だった検索語のキャッシュ
+// 存在しない語での検索が繰り返されると、そのたびに検索クエリとCOUNTが走るため、短時間だけ「0件」を覚えておく
+// NegativeSearchTTL（0 で無効）で設定する
+func newNegativeSearchCache(cfg config.Cache) *cache.Cache[string, struct{}] {
+	if cfg.NegativeSearchTTL <= 0 {
+		return nil
+	}
+	return cache.New[string, struct{}]("negative_search", cache.Options[struct{}]{
+		TTL:     cfg.NegativeSearchTTL,
+		MaxSize: defaultNegativeSearchSize,
+	})
+}
+
+// ページやソートに関係なく結果が0件になるため、検索語と絞り込み条件だけをキーにする
+// 検索語は listparams.Normalize で正規化したもの（クエリにも同じ値を使う）
+func negativeSearchKey(req model.ListRequest) string {
+	return fmt.Sprintf("%q|%s|%d", req.Search, req.Type, req.Category)
+}
+
+// 総件数のキャッシュ（商品一覧・注文履歴）
+// 総件数はページやソートに関係しないため、同じ検索条件のページ送りでは COUNT を一度だけ実行する
+// 期限切れや変更の通知の後も CountStaleTTL の間は古い件数を返し、裏で再計算する（レスポンスには approximate を付ける）
+// CountTTL（0 で無効）と CountStaleTTL で設定する
+func newCountCache(name string, size int, cfg config.Cache) *cache.Cache[string, int] {
+	if cfg.CountTTL <= 0 {
+		return nil
+	}
+	return cache.New[string, int](name, cache.Options[int]{
+		TTL:      cfg.CountTTL,
+		StaleTTL: cfg.CountStaleTTL,
+		MaxSize:  size,
+	})
+}
+
+// カテゴリ一覧のキャッシュ
+// カテゴリはマイグレーションでしか変わらないため、変更の通知は受け取らず TTL のみで更新する
+// CategoryTTL（0 で無効）で設定する
+func newCategoryCache(cfg config.Cache) *cache.Cache[struct{}, []model.Category] {
+	if cfg.CategoryTTL <= 0 {
+		return nil
+	}
+	return cache.New[struct{}, []model.Category]("category", cache.Options[[]model.Category]{TTL: cfg.CategoryTTL})
+}
+
+// CountProducts の条件（検索語と分類）だけをキーにする
+func countKey(req model.ListRequest) string {
+	return fmt.Sprintf("%q|%d", req.Search, req.Category)
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+const (
+	// 1回の複数行INSERTで登録する行数
+	importChunkSize = 500
+	// レポートに含めるエラー行の上限
+	maxImportErrors = 100
+	// CSVの一括登録で価値が変わった場合に価格履歴へ記録する理由
+	importChangeReason = "csv import"
+)
+
+var ErrInvalidCSVHeader = errors.New("invalid csv header")
+
+// 必須カラム（product_id, image, description, category_id は任意）
+var requiredImportColumns = []string{"name", "value", "weight"}
+
+// CSVから商品を一括登録する
+// 1行ずつ読み込んで検証し、importChunkSize 件ごとにまとめて書き込むため、大きなファイルでもメモリを使い切らない
+// 書き込みは全体で1つのトランザクションとし、エラーを返した場合はどの行も登録されていない
+// ヘッダ行は必須で、product_id を指定した行は既存商品の更新として扱う
+// 更新で価値（value）が変わる商品は、UpdateProduct と同様に同じトランザクション内で価格履歴を記録する（変更者は userID）
+func (s *ProductService) ImportProducts(ctx context.Context, userID int, r io.Reader) (*model.ImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCSVHeader, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range requiredImportColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: missing column %q", ErrInvalidCSVHeader, name)
+		}
+	}
+
+	report := &model.ImportReport{Errors: []model.ImportError{}}
+	reject := func(line int, reason string) {
+		report.Rejected++
+		if len(report.Errors) < maxImportErrors {
+			report.Errors = append(report.Errors, model.ImportError{Line: line, Reason: reason})
+		}
+	}
+
+	// 全ての chunk を1つのトランザクションで書き込み、途中で失敗した場合は何も反映しない
+	err = s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		chunk := make([]model.Product, 0, importChunkSize)
+		flush := func() error {
+			if len(chunk) == 0 {
+				return nil
+			}
+			history, err := importPriceHistory(ctx, txStore, userID, chunk)
+			if err != nil {
+				return err
+			}
+			inserted, updated, err := txStore.ProductRepo.BulkUpsertProducts(ctx, chunk)
+			if err != nil {
+				return err
+			}
+			if err := txStore.PriceHistRepo.CreateBatch(ctx, history); err != nil {
+				return err
+			}
+			report.Inserted += inserted
+			report.Updated += updated
+			chunk = chunk[:0]
+			return nil
+		}
+
+		line := 1
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			line++
+			if err != nil {
+				var parseErr *csv.ParseError
+				if errors.As(err, &parseErr) {
+					reject(line, parseErr.Err.Error())
+					continue
+				}
+				return err
+			}
+
+			product, err := parseImportRecord(record, columns)
+			if err != nil {
+				reject(line, err.Error())
+				continue
+			}
+			chunk = append(chunk, product)
+			if len(chunk) >= importChunkSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		return flush()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if report.Inserted > 0 || report.Updated > 0 {
+		s.notifyProductChanged(ctx, 0)
+	}
+	return report, nil
+}
+
+// CSVの1行を商品に変換し、ProductInput と同じルールで検証する
+func parseImportRecord(record []string, columns map[string]int) (model.Product, error) {
+	field := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	intField := func(name string) (int, error) {
+		v, err := strconv.Atoi(field(name))
+		if err != nil {
+			return 0, fmt.Errorf("%s must be an integer", name)
+		}
+		return v, nil
+	}
+
+	in := model.ProductInput{
+		Name:        field("name"),
+		Currency:    field("currency"),
+		Image:       field("image"),
+		Description: field("description"),
+	}
+	var err error
+	if in.Value, err = intField("value"); err != nil {
+		return model.Product{}, err
+	}
+	if in.Weight, err = intField("weight"); err != nil {
+		return model.Product{}, err
+	}
+	if field("category_id") != "" {
+		categoryID, err := intField("category_id")
+		if err != nil {
+			return model.Product{}, err
+		}
+		in.CategoryID = &categoryID
+	}
+	if err := validateProductInput(in); err != nil {
+		return model.Product{}, err
+	}
+
+	productID := 0
+	if field("product_id") != "" {
+		if productID, err = intField("product_id"); err != nil || productID <= 0 {
+			return model.Product{}, errors.New("product_id must be a positive integer")
+		}
+	}
+	return *productFromInput(productID, in), nil
+}
+
+// importPriceHistory は chunk のうち既存商品の価値を変える行の価格履歴を返す（トランザクション内で使用）
+// 対象の商品行は BulkUpsertProducts までロックしておく。同じ商品が複数行ある場合は行の順に変更を記録する
+func importPriceHistory(ctx context.Context, txStore *repository.Store, userID int, chunk []model.Product) ([]model.PriceHistory, error) {
+	var ids []int
+	for _, p := range chunk {
+		if p.ProductID > 0 {
+			ids = append(ids, p.ProductID)
+		}
+	}
+	current, err := txStore.ProductRepo.LockValues(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	var history []model.PriceHistory
+	changedAt := time.Now().UTC()
+	for _, p := range chunk {
+		old, ok := current[p.ProductID]
+		if !ok || old == p.Value {
+			continue
+		}
+		history = append(history, model.PriceHistory{
+			ProductID: p.ProductID,
+			OldValue:  old,
+			NewValue:  p.Value,
+			ChangedBy: &userID,
+			Reason:    importChangeReason,
+			ChangedAt: changedAt,
+		})
+		current[p.ProductID] = p.Value
+	}
+	return history, nil
+}
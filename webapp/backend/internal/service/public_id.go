@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"backend/internal/repository"
+)
+
+const (
+	publicIDBackfillBatchSize = 500
+	// 1 バッチごとに空ける間隔。起動直後の負荷を抑える
+	publicIDBackfillPause = 100 * time.Millisecond
+)
+
+// BackfillOrderPublicIDs は公開ID（orders.public_id）が未設定の注文へ、作成日時を時刻部分とする乱数の ULID を割り当てる
+// 全件に割り当てるか ctx がキャンセルされると戻る。条件付きで更新するため、複数台のサーバーで同時に動かしてもよい
+// orders.public_id が無いスキーマでは何もしない
+func BackfillOrderPublicIDs(store *repository.Store) func(ctx context.Context) {
+	return func(ctx context.Context) {
+		if !store.Schema().OrderPublicID {
+			slog.Warn("orders.public_id is missing; order ids are omitted outside the v1 API until the migration is applied")
+			return
+		}
+		total := 0
+		for {
+			n, err := store.OrderRepo.AssignPublicIDs(ctx, publicIDBackfillBatchSize)
+			total += n
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.WarnContext(ctx, "failed to backfill order public ids", "err", err, "assigned", total)
+				}
+				return
+			}
+			// 残っている間は続けて割り当てる
+			if n < publicIDBackfillBatchSize {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(publicIDBackfillPause):
+			}
+		}
+		if total > 0 {
+			slog.InfoContext(ctx, "backfilled order public ids", "count", total)
+		}
+	}
+}
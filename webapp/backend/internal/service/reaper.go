@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/events"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/telemetry"
+)
+
+// OrderReaper は配送中のまま期限を過ぎた注文を配送待ちへ戻す
+// ロボットが注文を確保した後に停止すると、その注文は配送計画の対象から外れたままになるため、定期的に探して戻す
+// 戻す際は行をロックして状態を条件付きで更新するため、複数台のサーバーで同時に動かしてもよい
+type OrderReaper struct {
+	store *repository.Store
+	// 注文の状態変更を通知する（nil の場合は通知しない）
+	bus       *events.Bus
+	lease     time.Duration
+	interval  time.Duration
+	batchSize int
+}
+
+// cfg.DeliveringLease が 0 の場合は nil を返す
+func NewOrderReaper(store *repository.Store, bus *events.Bus, cfg config.Planner) *OrderReaper {
+	if cfg.DeliveringLease <= 0 {
+		return nil
+	}
+	return &OrderReaper{store: store, bus: bus, lease: cfg.DeliveringLease, interval: cfg.ReapInterval, batchSize: cfg.ReapBatchSize}
+}
+
+// Run は ctx がキャンセルされるまで interval ごとに注文を探して戻す
+// orders.updated_at が無いスキーマでは何もせずに戻る
+func (r *OrderReaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for {
+			n, ok, err := r.reapBatch(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.WarnContext(ctx, "failed to reap stuck delivering orders", "err", err)
+				}
+				break
+			}
+			if !ok {
+				slog.Warn("orders.updated_at is missing; stuck delivering orders will not be reaped")
+				return
+			}
+			// 残っている間は待たずに続けて戻す
+			if n < r.batchSize {
+				break
+			}
+		}
+	}
+}
+
+// reapBatch は最大 batchSize 件を戻し、戻した件数を返す
+func (r *OrderReaper) reapBatch(ctx context.Context) (int, bool, error) {
+	cutoff := time.Now().Add(-r.lease)
+	var reset events.OrderStatusChanged
+	var ok bool
+	err := r.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		ids, found, err := txStore.OrderRepo.FindStuckDelivering(ctx, cutoff, r.batchSize)
+		if err != nil || !found || len(ids) == 0 {
+			ok = found
+			return err
+		}
+		ok = true
+		if _, err := txStore.OrderRepo.UpdateStatusesConditional(ctx, ids, model.StatusShipping, model.StatusDelivering); err != nil {
+			return err
+		}
+		publicIDs, err := txStore.OrderRepo.PublicIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		reset = events.NewOrderStatusChanged(ids, publicIDs, model.StatusDelivering, model.StatusShipping)
+		return txStore.OutboxRepo.Add(ctx, events.TypeOrderStatusChanged, ids[0], reset)
+	})
+	if err != nil || len(reset.OrderIDs) == 0 {
+		return 0, ok, err
+	}
+
+	n := len(reset.OrderIDs)
+	slog.WarnContext(ctx, "reset stuck delivering orders to shipping", "count", n, "lease", r.lease.String())
+	telemetry.RecordOrdersReaped(ctx, int64(n))
+	if r.bus != nil {
+		r.bus.Publish(ctx, events.TypeOrderStatusChanged, reset)
+	}
+	return n, true, nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+const defaultRelatedLimit = 10
+
+type RecommendationService struct {
+	store           *repository.Store
+	refreshInterval time.Duration
+}
+
+func NewRecommendationService(store *repository.Store, cfg *config.Config) *RecommendationService {
+	return &RecommendationService{store: store, refreshInterval: cfg.Cache.RelatedRefreshInterval}
+}
+
+// 一緒に注文されることの多い商品を取得
+func (s *RecommendationService) FetchRelated(ctx context.Context, productID int) ([]model.Product, error) {
+	return s.store.RecommendRepo.ListRelatedProducts(ctx, productID, defaultRelatedLimit)
+}
+
+// 共起テーブルを再計算する
+// MySQL では作業用テーブルに作り直して入れ替え、それ以外では1つのトランザクションで作り直す
+func (s *RecommendationService) Refresh(ctx context.Context) error {
+	start := time.Now()
+	var err error
+	if s.store.RecommendRepo.RebuildsBySwap() {
+		err = s.store.RecommendRepo.RebuildCooccurrence(ctx)
+	} else {
+		err = s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+			return txStore.RecommendRepo.RefreshCooccurrence(ctx)
+		})
+	}
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "refreshed product co-occurrence", "duration", time.Since(start).String())
+	return nil
+}
+
+// RunRefresher は起動時と、ctx がキャンセルされるまで定期的に共起テーブルを再計算する
+// 間隔は RelatedRefreshInterval で設定し、0 の場合はすぐに戻る
+func (s *RecommendationService) RunRefresher(ctx context.Context) {
+	interval := s.refreshInterval
+	if interval <= 0 {
+		return
+	}
+
+	// 前回の停止から後の注文を反映するため、最初の間隔を待たずに再計算する
+	if err := s.Refresh(ctx); err != nil && ctx.Err() == nil {
+		slog.ErrorContext(ctx, "failed to refresh product co-occurrence", "err", err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				slog.ErrorContext(ctx, "failed to refresh product co-occurrence", "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/storage"
+)
+
+// 日次レポートを書き出す場所（blob のキーの接頭辞）
+const dailyReportPrefix = "daily/"
+
+var (
+	ErrReportsUnavailable = errors.New("reports are not enabled")
+	ErrReportNotFound     = errors.New("report not found")
+)
+
+// 日次レポートのファイル名（YYYY-MM-DD.json / YYYY-MM-DD.csv）
+var reportNamePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})\.(json|csv)$`)
+
+// ReportGenerator は毎日 hour 時（UTC）に前日分の運用レポートを作成し、JSON と CSV で blobs に書き出す
+// 起動時に前日分が無ければすぐに作成する
+// 配送計画の積載率はこのサーバーが計算した計画のみを数えるため、複数台で動かす場合は最後に書き出したサーバーの値になる
+type ReportGenerator struct {
+	store       *repository.Store
+	robots      *RobotService
+	blobs       storage.WritableBlobStore
+	hour        int
+	topProducts int
+}
+
+func NewReportGenerator(store *repository.Store, robots *RobotService, blobs storage.WritableBlobStore, cfg config.Reports) *ReportGenerator {
+	return &ReportGenerator{store: store, robots: robots, blobs: blobs, hour: cfg.Hour, topProducts: cfg.TopProducts}
+}
+
+// Run は ctx がキャンセルされるまで、毎日前日分のレポートを作成する
+func (g *ReportGenerator) Run(ctx context.Context) {
+	now := time.Now().UTC()
+	if due := g.scheduledAt(now); !now.Before(due) {
+		g.generateMissing(ctx, due.AddDate(0, 0, -1))
+	}
+	for {
+		next := g.scheduledAt(time.Now().UTC())
+		if !next.After(time.Now()) {
+			next = next.AddDate(0, 0, 1)
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		day := next.Truncate(24*time.Hour).AddDate(0, 0, -1)
+		if err := g.Generate(ctx, day); err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "failed to generate daily report", "date", day.Format(time.DateOnly), "err", err)
+		}
+	}
+}
+
+// scheduledAt は now の日の作成時刻
+func (g *ReportGenerator) scheduledAt(now time.Time) time.Time {
+	return now.Truncate(24 * time.Hour).Add(time.Duration(g.hour) * time.Hour)
+}
+
+func (g *ReportGenerator) generateMissing(ctx context.Context, day time.Time) {
+	blob, err := g.blobs.Get(ctx, reportKey(day, "json"))
+	if err == nil {
+		blob.Content.Close()
+		return
+	}
+	if !errors.Is(err, storage.ErrBlobNotFound) {
+		slog.WarnContext(ctx, "failed to check daily report", "date", day.Format(time.DateOnly), "err", err)
+		return
+	}
+	if err := g.Generate(ctx, day); err != nil && ctx.Err() == nil {
+		slog.WarnContext(ctx, "failed to generate daily report", "date", day.Format(time.DateOnly), "err", err)
+	}
+}
+
+// Generate は day（UTC の日付）のレポートを作成して書き出す。既にある場合は置き換える
+func (g *ReportGenerator) Generate(ctx context.Context, day time.Time) error {
+	report, err := g.build(ctx, day.UTC().Truncate(24*time.Hour))
+	if err != nil {
+		return err
+	}
+
+	body, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := g.blobs.Put(ctx, reportKey(day, "json"), bytes.NewReader(body)); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := writeReportCSV(&buf, report); err != nil {
+		return err
+	}
+	if err := g.blobs.Put(ctx, reportKey(day, "csv"), &buf); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "generated daily report", "date", report.Date, "orders_created", report.OrdersCreated, "orders_delivered", report.OrdersDelivered)
+	return nil
+}
+
+func (g *ReportGenerator) build(ctx context.Context, day time.Time) (*model.DailyReport, error) {
+	next := day.AddDate(0, 0, 1)
+	report := &model.DailyReport{
+		Date:        day.Format(time.DateOnly),
+		Planner:     g.robots.PlannerUtilization(day),
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	// 集計のジョブと同じ時間ごとの集計を合計する（order_summaries が無いスキーマでも使える）
+	hours, err := g.store.SummaryRepo.AggregateHours(ctx, day, next)
+	if err != nil {
+		return nil, err
+	}
+	var deliverySeconds float64
+	valueCreated := model.NewMoney(0, model.DefaultCurrency)
+	for _, h := range hours {
+		report.OrdersCreated += h.OrdersCreated
+		if valueCreated, err = valueCreated.Add(model.NewMoney(h.ValueCreated, model.DefaultCurrency)); err != nil {
+			return nil, fmt.Errorf("sum value created: %w", err)
+		}
+		report.OrdersDelivered += h.OrdersDelivered
+		deliverySeconds += h.DeliverySeconds
+	}
+	report.ValueCreated, report.ValueCurrency = valueCreated.Amount, valueCreated.Currency
+	if report.OrdersDelivered > 0 {
+		avg := deliverySeconds / float64(report.OrdersDelivered)
+		report.AvgTimeToDeliverySeconds = &avg
+	}
+
+	report.TopProducts, err = g.store.OrderRepo.TopProducts(ctx, day, next, g.topProducts)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// List は書き出し済みのレポートを新しい順に返す
+func (g *ReportGenerator) List(ctx context.Context) ([]model.ReportFile, error) {
+	if g == nil {
+		return nil, ErrReportsUnavailable
+	}
+	blobs, err := g.blobs.List(ctx, dailyReportPrefix)
+	if err != nil {
+		return nil, err
+	}
+	files := []model.ReportFile{}
+	for _, b := range blobs {
+		name := strings.TrimPrefix(b.Key, dailyReportPrefix)
+		m := reportNamePattern.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		files = append(files, model.ReportFile{Name: name, Date: m[1], Format: m[2], Size: b.Size, ModifiedAt: b.ModTime.UTC()})
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Date > files[j].Date })
+	return files, nil
+}
+
+// Open は name（List の Name）のレポートを返す。呼び出し側で Content を閉じること
+func (g *ReportGenerator) Open(ctx context.Context, name string) (*storage.Blob, error) {
+	if g == nil {
+		return nil, ErrReportsUnavailable
+	}
+	if !reportNamePattern.MatchString(name) {
+		return nil, ErrReportNotFound
+	}
+	blob, err := g.blobs.Get(ctx, dailyReportPrefix+name)
+	if errors.Is(err, storage.ErrBlobNotFound) {
+		return nil, ErrReportNotFound
+	}
+	return blob, err
+}
+
+func reportKey(day time.Time, format string) string {
+	return path.Join(dailyReportPrefix, day.UTC().Format(time.DateOnly)+"."+format)
+}
+
+// writeReportCSV はレポートを1行1項目の縦持ちの CSV で書き出す（売れ筋の商品は商品ごとに注文数と金額の2行）
+func writeReportCSV(buf *bytes.Buffer, report *model.DailyReport) error {
+	w := csv.NewWriter(buf)
+	rows := [][]string{
+		{"date", "metric", "product_id", "product_name", "value"},
+		{report.Date, "orders_created", "", "", strconv.Itoa(report.OrdersCreated)},
+		{report.Date, "value_created", "", "", strconv.FormatInt(report.ValueCreated, 10)},
+		{report.Date, "orders_delivered", "", "", strconv.Itoa(report.OrdersDelivered)},
+	}
+	if avg := report.AvgTimeToDeliverySeconds; avg != nil {
+		rows = append(rows, []string{report.Date, "avg_time_to_delivery_seconds", "", "", strconv.FormatFloat(*avg, 'f', 3, 64)})
+	}
+	p := report.Planner
+	rows = append(rows,
+		[]string{report.Date, "planner_plans", "", "", strconv.Itoa(p.Plans)},
+		[]string{report.Date, "planner_orders_planned", "", "", strconv.Itoa(p.OrdersPlanned)},
+		[]string{report.Date, "planner_total_weight", "", "", strconv.FormatInt(p.TotalWeight, 10)},
+		[]string{report.Date, "planner_total_capacity", "", "", strconv.FormatInt(p.TotalCapacity, 10)},
+	)
+	if p.Utilization != nil {
+		rows = append(rows, []string{report.Date, "planner_utilization", "", "", strconv.FormatFloat(*p.Utilization, 'f', 4, 64)})
+	}
+	for _, prod := range report.TopProducts {
+		id := strconv.Itoa(prod.ProductID)
+		rows = append(rows,
+			[]string{report.Date, "top_product_orders", id, prod.Name, strconv.Itoa(prod.Orders)},
+			[]string{report.Date, "top_product_value", id, prod.Name, strconv.FormatInt(prod.Value, 10)},
+		)
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return err
+	}
+	return w.Error()
+}
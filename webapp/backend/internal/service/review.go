@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+var ErrInvalidReview = errors.New("invalid review")
+
+const maxReviewCommentLength = 2000
+
+type ReviewService struct {
+	store *repository.Store
+}
+
+func NewReviewService(store *repository.Store) *ReviewService {
+	return &ReviewService{store: store}
+}
+
+// レビューを投稿し、商品の平均評価を同じトランザクション内で更新する
+func (s *ReviewService) CreateReview(ctx context.Context, userID, productID int, req model.CreateReviewRequest) (*model.Review, error) {
+	if req.Rating < 1 || req.Rating > 5 {
+		return nil, ErrInvalidReview
+	}
+	comment := strings.TrimSpace(req.Comment)
+	if len([]rune(comment)) > maxReviewCommentLength {
+		return nil, ErrInvalidReview
+	}
+
+	review := &model.Review{
+		ProductID: productID,
+		UserID:    userID,
+		Rating:    req.Rating,
+		Comment:   comment,
+		CreatedAt: time.Now().UTC(),
+	}
+	err := s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		// 商品の存在確認を兼ねて行ロックを取得してから集計する
+		if err := txStore.ProductRepo.LockProduct(ctx, productID); err != nil {
+			return err
+		}
+		id, err := txStore.ReviewRepo.Create(ctx, review)
+		if err != nil {
+			return err
+		}
+		review.ReviewID = id
+		return txStore.ReviewRepo.RefreshProductRating(ctx, productID)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+	return review, nil
+}
+
+// 商品のレビュー一覧を取得
+func (s *ReviewService) FetchReviews(ctx context.Context, productID, page, pageSize int) ([]model.Review, int, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+	total, err := s.store.ReviewRepo.CountByProduct(ctx, productID)
+	if err != nil {
+		return nil, 0, err
+	}
+	reviews, err := s.store.ReviewRepo.ListByProduct(ctx, productID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	return reviews, total, nil
+}
@@ -1,80 +1,378 @@
 package service
 
 import (
+	"backend/internal/cache"
+	"backend/internal/config"
+	"backend/internal/coord"
+	"backend/internal/events"
 	"backend/internal/model"
 	"backend/internal/repository"
-	"backend/internal/service/utils"
+	"backend/internal/telemetry"
+	"backend/internal/workerpool"
 	"context"
-	"log"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
 
-	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// 計画計算の同時実行数が上限に達し、待ち行列でも枠が空かなかった場合に返す
+	ErrPlannerSaturated = errors.New("delivery planner is saturated")
+	ErrOrderNotFound    = errors.New("order not found")
+	// 注文の現在の状態から指定された状態へ変更できない
+	ErrInvalidStatusTransition = errors.New("invalid order status transition")
 )
 
 type RobotService struct {
 	store *repository.Store
+	// 注文の状態変更とロボットの位置をダッシュボードへ通知する（nil の場合は通知しない）
+	bus *events.Bus
+
+	// 配送計画を計算するワーカー。計算の同時実行数と、空きを待てるリクエスト数・時間を制限する
+	planner          *workerpool.Pool
+	planQueueTimeout time.Duration
+	// 全サーバーで共有する計算の枠（nil の場合はこのサーバー内の planner のみ）
+	clusterSlots *coord.Semaphore
+	// 配送計画の候補の読み取りの同時実行をまとめる
+	shippingFlight *coalescer[[]model.Order]
+	// 配送計画の候補のスナップショット（nil の場合は毎回読み取る）
+	shippingCache *cache.Cache[struct{}, []model.Order]
+
+	// ロボットごとの最後のアクセス日時（管理ダッシュボードの稼働中のロボット）
+	seenMu   sync.Mutex
+	lastSeen map[string]time.Time
+
+	// UTC の日付ごとの配送計画の積載（日次レポート）。このインスタンスが計算した計画のみ
+	planStatsMu sync.Mutex
+	planStats   map[string]*model.PlannerUtilization
+}
+
+// 日ごとの配送計画の積載を保持する日数
+const planStatsRetentionDays = 7
+
+func NewRobotService(store *repository.Store, bus *events.Bus, cfg *config.Config) *RobotService {
+	s := &RobotService{
+		store: store,
+		bus:   bus,
+		planner: workerpool.New("planner", workerpool.Options{
+			Workers:      cfg.Planner.Concurrency,
+			QueueSize:    cfg.Planner.QueueSize,
+			QueueTimeout: cfg.Planner.QueueTimeout,
+			DrainTimeout: cfg.Server.ShutdownGracePeriod,
+		}),
+		planQueueTimeout: cfg.Planner.QueueTimeout,
+		shippingFlight:   newCoalescer[[]model.Order]("GetShippingOrders", cfg.Cache),
+		lastSeen:         make(map[string]time.Time),
+		planStats:        make(map[string]*model.PlannerUtilization),
+	}
+	if cfg.Cache.ShippingOrdersTTL > 0 {
+		s.shippingCache = cache.New[struct{}, []model.Order]("shipping_orders", cache.Options[[]model.Order]{TTL: cfg.Cache.ShippingOrdersTTL})
+		// 候補の全件を対象にする必要があるため、注文が増えた場合も確保された場合もすぐに破棄する
+		purge := func(context.Context, cache.Change) { s.shippingCache.Purge() }
+		store.Changes().Subscribe(cache.EntityOrder, purge)
+		store.Changes().Subscribe(cache.EntityOrderStatus, purge)
+	}
+	return s
+}
+
+// UseClusterSlots は配送計画の計算枠を全サーバーで共有する（ROBOT_PLAN_CLUSTER_CONCURRENCY、0 の場合は ROBOT_PLAN_CONCURRENCY）
+// 枠は lease の期限付きで確保するため、lease は計算にかかる時間（配送計画のタイムアウト）より長くすること
+// 起動時、リクエストの受け付け前に呼ぶこと
+func (s *RobotService) UseClusterSlots(client *coord.Client, cfg config.Planner, lease time.Duration) {
+	size := cfg.ClusterConcurrency
+	if size == 0 {
+		size = cfg.Concurrency
+	}
+	s.clusterSlots = coord.NewSemaphore(client, "planner", size, lease)
+}
+
+// RetryAfter は飽和時にクライアントへ提示する再試行までの目安
+func (s *RobotService) RetryAfter() time.Duration {
+	return s.planQueueTimeout
 }
 
-func NewRobotService(store *repository.Store) *RobotService {
-	return &RobotService{store: store}
+// acquireClusterSlot は全サーバーで共有する計画計算の枠を、queuedAt から planQueueTimeout までの残りの時間で確保する
+// 共有する枠が無い場合は何もしない
+func (s *RobotService) acquireClusterSlot(ctx context.Context, queuedAt time.Time) (func(), error) {
+	if s.clusterSlots == nil {
+		return func() {}, nil
+	}
+	release, err := s.clusterSlots.Acquire(ctx, max(s.planQueueTimeout-time.Since(queuedAt), 0))
+	switch {
+	case err == nil:
+		return release, nil
+	case errors.Is(err, coord.ErrSemaphoreTimeout):
+		return nil, ErrPlannerSaturated
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	default:
+		// Redis の障害時は、このサーバーの枠だけで計算を続ける
+		slog.WarnContext(ctx, "failed to acquire cluster planner slot; continuing with local slot only", "err", err)
+		return func() {}, nil
+	}
+}
+
+// RunPlanner は ctx がキャンセルされるまで配送計画の計算のワーカーを動かす
+func (s *RobotService) RunPlanner(ctx context.Context) {
+	s.planner.Run(ctx)
 }
 
 // 注意：このメソッドは、現在、ordersテーブルのshipped_statusが"shipping"になっている注文"全件"を対象に配送計画を立てます。
 // 注文の取得件数を制限した場合、ペナルティの対象になります。
+// 計算はワーカーで行い、空きが無く待ち行列も満杯の場合や planQueueTimeout までに始められない場合は ErrPlannerSaturated を返す
+// 全サーバーで共有する枠がある場合は、ワーカーで始めてから残りの待ち時間でそちらも確保する
 func (s *RobotService) GenerateDeliveryPlan(ctx context.Context, robotID string, capacity int) (*model.DeliveryPlan, error) {
-	var plan model.DeliveryPlan
-	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
-		// 1) Read candidates outside transaction to avoid long-running transaction holding locks
-		orders, err := s.store.OrderRepo.GetShippingOrders(ctx)
+	s.touch(robotID)
+	queuedAt := time.Now()
+	var plan *model.DeliveryPlan
+	err := s.planner.Do(ctx, func(ctx context.Context) error {
+		release, err := s.acquireClusterSlot(ctx, queuedAt)
 		if err != nil {
 			return err
 		}
+		defer release()
+		plan, err = s.generateDeliveryPlan(ctx, robotID, capacity)
+		return err
+	})
+	if errors.Is(err, workerpool.ErrSaturated) || errors.Is(err, workerpool.ErrQueueTimeout) || errors.Is(err, workerpool.ErrClosed) {
+		return nil, ErrPlannerSaturated
+	}
+	return plan, err
+}
 
-		// trace DP calculation to see if it's the bottleneck
-		tracer := otel.Tracer("backend/service.RobotService")
-		dpCtx, dpSpan := tracer.Start(ctx, "selectOrdersForDelivery")
-		dpSpan.SetAttributes(attribute.Int("orders.candidate_count", len(orders)), attribute.String("robot_id", robotID))
-		plan, err = selectOrdersForDelivery(dpCtx, orders, robotID, capacity)
-		if err != nil {
-			dpSpan.RecordError(err)
-			dpSpan.SetStatus(codes.Error, err.Error())
-			dpSpan.End()
-			return err
-		}
-		dpSpan.SetAttributes(attribute.Int("plan.orders", len(plan.Orders)), attribute.Int("plan.total_weight", plan.TotalWeight))
-		dpSpan.End()
-
-		// 2) Short transaction: claim orders that are still 'shipping'
-		if len(plan.Orders) > 0 {
-			orderIDs := make([]int64, len(plan.Orders))
-			for i, order := range plan.Orders {
-				orderIDs[i] = order.OrderID
-			}
+func (s *RobotService) generateDeliveryPlan(ctx context.Context, robotID string, capacity int) (*model.DeliveryPlan, error) {
+	// 1) Read candidates outside transaction to avoid long-running transaction holding locks
+	//    同時に計画するリクエストは同じ読み取り結果を使う（それぞれが同時に読み取った場合と同じく、確保は下の条件付き更新で行う）
+	shared, err := s.shippingOrders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := planCandidates(shared)
+	if err != nil {
+		return nil, err
+	}
 
-			return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
-				affected, err := txStore.OrderRepo.UpdateStatusesConditional(ctx, orderIDs, "delivering", "shipping")
-				if err != nil {
-					return err
-				}
-				log.Printf("Claimed %d/%d orders for delivering", affected, len(orderIDs))
-				return nil
-			})
+	// trace DP calculation to see if it's the bottleneck
+	// 計算時間はスパンの中で記録し、遅い計算の exemplar からこのスパンを開けるようにする
+	plan, err := telemetry.WithSpanResult(ctx, "selectOrdersForDelivery", func(ctx context.Context) (model.DeliveryPlan, error) {
+		span := trace.SpanFromContext(ctx)
+		if span.IsRecording() {
+			span.SetAttributes(
+				attribute.Int("orders.candidate_count", len(shared)),
+				attribute.Int("orders.currency_count", len(groups)),
+				attribute.Int("capacity", capacity),
+				attribute.String("robot_id", robotID),
+			)
+		}
+		plan, err := selectPlanAcrossCurrencies(ctx, groups, robotID, capacity)
+		if err == nil && span.IsRecording() {
+			span.SetAttributes(attribute.Int("plan.orders", len(plan.Orders)), attribute.Int("plan.total_weight", plan.TotalWeight))
 		}
-		return nil
+		return plan, err
 	})
 	if err != nil {
 		return nil, err
 	}
+	s.recordPlan(time.Now().UTC(), plan, capacity)
+
+	// 2) Short transaction: claim orders that are still 'shipping'
+	if len(plan.Orders) > 0 {
+		orderIDs := make([]int64, len(plan.Orders))
+		publicIDs := make(map[int64]string, len(plan.Orders))
+		for i, order := range plan.Orders {
+			orderIDs[i] = order.OrderID
+			publicIDs[order.OrderID] = order.PublicID
+		}
+
+		claimed := events.NewOrderStatusChanged(orderIDs, publicIDs, model.StatusShipping, model.StatusDelivering)
+		var affected int64
+		err := s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+			var err error
+			affected, err = txStore.OrderRepo.UpdateStatusesConditional(ctx, orderIDs, model.StatusDelivering, model.StatusShipping)
+			if err != nil {
+				return err
+			}
+			slog.InfoContext(ctx, "claimed orders for delivering", "claimed", affected, "requested", len(orderIDs))
+			// 他のロボットが先に確保した注文も含まれうるが、配信は at-least-once のため受け手側で重複を許容する
+			return txStore.OutboxRepo.Add(ctx, events.TypeOrderClaimed, orderIDs[0], claimed)
+		})
+		if err != nil {
+			return nil, err
+		}
+		telemetry.RecordOrdersClaimed(ctx, affected)
+		s.publish(ctx, events.TypeOrderClaimed, claimed)
+	}
 	return &plan, nil
 }
 
-func (s *RobotService) UpdateOrderStatus(ctx context.Context, orderID int64, newStatus string) error {
-	return utils.WithTimeout(ctx, func(ctx context.Context) error {
-		return s.store.OrderRepo.UpdateStatuses(ctx, []int64{orderID}, newStatus)
+// shippingOrders は配送計画の候補を返す。スナップショットがあればそれを使う
+// 返すスライスは共有されるため、書き換える場合はコピーすること
+func (s *RobotService) shippingOrders(ctx context.Context) ([]model.Order, error) {
+	if s.shippingCache == nil {
+		return s.shippingFlight.do(ctx, string(model.StatusShipping), s.store.OrderRepo.GetShippingOrders)
+	}
+	return s.shippingCache.GetOrLoad(ctx, struct{}{}, s.store.OrderRepo.GetShippingOrders)
+}
+
+// 状態の変更とアウトボックスへの記録を同じトランザクションで行う
+// 現在の状態から newStatus へ遷移できない場合は ErrInvalidStatusTransition を返す（同じ状態への変更は何もしない）
+// 注文は公開ID、または互換のため order_id で指定できる
+func (s *RobotService) UpdateOrderStatus(ctx context.Context, ref model.OrderRef, newStatus model.ShippedStatus) error {
+	orderID := ref.ID
+	if ref.PublicID != "" {
+		id, err := s.store.OrderRepo.FindIDByPublicID(ctx, ref.PublicID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrOrderNotFound
+			}
+			return err
+		}
+		orderID = id
+	}
+	return transitionOrderStatus(ctx, s.store, s.bus, orderID, newStatus)
+}
+
+// ReportPosition はロボットの現在位置を通知する。位置は保存しない
+func (s *RobotService) ReportPosition(ctx context.Context, robotID string, req model.RobotPositionRequest) {
+	s.touch(robotID)
+	s.publish(ctx, events.TypeRobotPosition, events.RobotPosition{
+		RobotID:    robotID,
+		X:          req.X,
+		Y:          req.Y,
+		ReportedAt: time.Now().UTC(),
 	})
 }
 
+func (s *RobotService) touch(robotID string) {
+	s.seenMu.Lock()
+	s.lastSeen[robotID] = time.Now().UTC()
+	s.seenMu.Unlock()
+}
+
+// ActiveRobots は since 以降にアクセスのあったロボットを返す（このインスタンスが受けたアクセスのみ）
+func (s *RobotService) ActiveRobots(since time.Time) []model.ActiveRobot {
+	s.seenMu.Lock()
+	defer s.seenMu.Unlock()
+	robots := []model.ActiveRobot{}
+	for id, at := range s.lastSeen {
+		if at.Before(since) {
+			continue
+		}
+		robots = append(robots, model.ActiveRobot{RobotID: id, LastSeenAt: at})
+	}
+	sort.Slice(robots, func(i, j int) bool { return robots[i].RobotID < robots[j].RobotID })
+	return robots
+}
+
+func (s *RobotService) recordPlan(now time.Time, plan model.DeliveryPlan, capacity int) {
+	s.planStatsMu.Lock()
+	defer s.planStatsMu.Unlock()
+	day := now.Format(time.DateOnly)
+	stats, ok := s.planStats[day]
+	if !ok {
+		stats = &model.PlannerUtilization{}
+		s.planStats[day] = stats
+		oldest := now.AddDate(0, 0, -planStatsRetentionDays).Format(time.DateOnly)
+		for d := range s.planStats {
+			if d < oldest {
+				delete(s.planStats, d)
+			}
+		}
+	}
+	stats.Plans++
+	stats.OrdersPlanned += len(plan.Orders)
+	stats.TotalWeight += int64(plan.TotalWeight)
+	stats.TotalCapacity += int64(capacity)
+}
+
+// PlannerUtilization は day（UTC の日付）に計算した配送計画の積載を返す（このインスタンスが計算した計画のみ）
+func (s *RobotService) PlannerUtilization(day time.Time) model.PlannerUtilization {
+	s.planStatsMu.Lock()
+	defer s.planStatsMu.Unlock()
+	stats, ok := s.planStats[day.UTC().Format(time.DateOnly)]
+	if !ok {
+		return model.PlannerUtilization{}
+	}
+	u := *stats
+	if u.TotalCapacity > 0 {
+		ratio := float64(u.TotalWeight) / float64(u.TotalCapacity)
+		u.Utilization = &ratio
+	}
+	return u
+}
+
+// publish はコミット後にプロセス内のバスへ通知する（外部への配信はアウトボックスが行う）
+func (s *RobotService) publish(ctx context.Context, eventType string, payload interface{}) {
+	if s.bus != nil {
+		s.bus.Publish(ctx, eventType, payload)
+	}
+}
+
+// candidateGroup は1つの通貨の候補
+type candidateGroup struct {
+	currency string
+	orders   []model.Order
+}
+
+// planCandidates は候補を通貨ごとに分けてコピーして返す（通貨は候補に現れた順）
+// 通貨の異なる価値は比較できないため、1つの計画には1つの通貨の注文だけを載せる
+// 通貨ごとに価値の合計が int に収まることを確かめ、計画の価値の計算で桁あふれしないようにする
+func planCandidates(shared []model.Order) ([]candidateGroup, error) {
+	if len(shared) == 0 {
+		return []candidateGroup{{currency: model.DefaultCurrency, orders: []model.Order{}}}, nil
+	}
+	var groups []candidateGroup
+	totals := make(map[string]model.Money)
+	index := make(map[string]int)
+	for _, o := range shared {
+		price := model.NewMoney(int64(o.Value), o.Currency)
+		i, ok := index[price.Currency]
+		if !ok {
+			i = len(groups)
+			index[price.Currency] = i
+			groups = append(groups, candidateGroup{currency: price.Currency})
+			totals[price.Currency] = model.NewMoney(0, price.Currency)
+		}
+		total, err := totals[price.Currency].Add(price)
+		if err != nil {
+			return nil, fmt.Errorf("sum candidate values: %w", err)
+		}
+		if _, err := total.Int(); err != nil {
+			return nil, fmt.Errorf("sum candidate values: %w", err)
+		}
+		totals[price.Currency] = total
+		groups[i].orders = append(groups[i].orders, o)
+	}
+	return groups, nil
+}
+
+// selectPlanAcrossCurrencies は通貨ごとに計画を立て、運ぶ重量が最も多い計画を返す
+// 重量が同じ場合は先に現れた通貨（価値の密度が高い注文を含む通貨）の計画を選ぶ
+// 選ばれなかった通貨の注文は次の計画の候補に残る
+func selectPlanAcrossCurrencies(ctx context.Context, groups []candidateGroup, robotID string, capacity int) (model.DeliveryPlan, error) {
+	var best model.DeliveryPlan
+	for i, g := range groups {
+		solveStart := time.Now()
+		plan, err := selectOrdersForDelivery(ctx, g.orders, robotID, capacity)
+		telemetry.RecordPlanSolve(ctx, time.Since(solveStart), len(g.orders), capacity)
+		if err != nil {
+			return model.DeliveryPlan{}, err
+		}
+		plan.Currency = g.currency
+		if i == 0 || plan.TotalWeight > best.TotalWeight {
+			best = plan
+		}
+	}
+	return best, nil
+}
+
 // selectOrdersForDelivery は動的計画法（DP）を使用して0/1ナップザック問題を解きます
 // 時間計算量: O(n * capacity) - DFSのO(2^n)から大幅に改善
 // 空間計算量: O(n * capacity) - DPテーブル
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"backend/internal/model"
+)
+
+// 通貨ごとに計画を立て、先頭の通貨以外の注文も計画に載せられる
+func TestSelectPlanAcrossCurrencies(t *testing.T) {
+	orders := []model.Order{
+		{OrderID: 1, Weight: 1, Value: 100, Currency: "JPY"},
+		{OrderID: 2, Weight: 4, Value: 5, Currency: "USD"},
+		{OrderID: 3, Weight: 3, Value: 4, Currency: "USD"},
+	}
+	groups, err := planCandidates(orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || groups[0].currency != "JPY" || len(groups[1].orders) != 2 {
+		t.Fatalf("groups = %+v, want JPY then two USD orders", groups)
+	}
+
+	plan, err := selectPlanAcrossCurrencies(context.Background(), groups, "robot", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Currency != "USD" || plan.TotalWeight != 7 || len(plan.Orders) != 2 {
+		t.Fatalf("plan = %s %d kg with %d orders, want USD 7 kg with 2 orders", plan.Currency, plan.TotalWeight, len(plan.Orders))
+	}
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+
+	"backend/internal/config"
+	"backend/internal/events"
+	"backend/internal/model"
+)
+
+// 在庫が閾値を下回った際に events.TypeLowStock を発行する
+// 閾値は LowStockThreshold で設定する
+type stockAlerter struct {
+	bus       *events.Bus
+	threshold int
+}
+
+func newStockAlerter(bus *events.Bus, cfg config.Stock) *stockAlerter {
+	return &stockAlerter{bus: bus, threshold: cfg.LowStockThreshold}
+}
+
+// 減算後の在庫数が閾値を下回った（今回の注文で閾値をまたいだ）かどうか
+func (a *stockAlerter) crossed(before, after int) bool {
+	return before >= a.threshold && after < a.threshold
+}
+
+// トランザクションのコミット後に呼び出し、在庫不足イベントを発行する
+func (a *stockAlerter) publish(ctx context.Context, levels []model.StockLevel) {
+	if a == nil || a.bus == nil {
+		return
+	}
+	for _, level := range levels {
+		a.bus.Publish(ctx, events.TypeLowStock, level)
+	}
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+// 集計の一覧で返す期間の上限
+const (
+	maxHourSummaries = 7 * 24 * time.Hour
+	maxDaySummaries  = 366 * 24 * time.Hour
+)
+
+var (
+	ErrSummariesUnavailable = errors.New("order summaries are not available")
+	ErrInvalidSummaryPeriod = errors.New("invalid summary period")
+)
+
+// OrderSummarizer は orders を時間・日ごとに集計して order_summaries へ書き込む
+// ダッシュボードは orders を走査せずに集計を読むため、注文数が増えても日数に比例した時間で応答できる
+// 直近 lookback の時間は毎回集計し直し、それより前の時間は確定したものとして扱う
+// 同じ範囲を同じ内容で置き換えるだけなので、複数台のサーバーで同時に動かしてもよい
+type OrderSummarizer struct {
+	store    *repository.Store
+	interval time.Duration
+	lookback time.Duration
+	// 最初の集計（未集計の期間の埋め戻しを含む）が終わったか
+	ready atomic.Bool
+	// 最後に集計し終えた時刻（Run からのみ参照する）。停止していた間の時間を次の集計で埋める
+	until time.Time
+}
+
+// cfg.SummaryInterval が 0 の場合や order_summaries が無いスキーマでは nil を返す
+// DetectSchema の後に呼ぶこと
+func NewOrderSummarizer(store *repository.Store, cfg config.Stats) *OrderSummarizer {
+	if cfg.SummaryInterval <= 0 || !store.SummaryRepo.Enabled() {
+		return nil
+	}
+	return &OrderSummarizer{store: store, interval: cfg.SummaryInterval, lookback: cfg.SummaryLookback}
+}
+
+// Run は起動直後と interval ごとに集計を更新する
+func (s *OrderSummarizer) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		now := time.Now().UTC()
+		if err := s.summarize(ctx, now); err != nil {
+			if ctx.Err() == nil {
+				slog.WarnContext(ctx, "failed to update order summaries", "err", err)
+			}
+		} else {
+			s.until = now
+			s.ready.Store(true)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Ready は集計を読めるか（最初の集計が終わるまでは、埋め戻し前の不完全な値になるため false）
+func (s *OrderSummarizer) Ready() bool {
+	return s != nil && s.ready.Load()
+}
+
+// summarize は直近 lookback と前回の集計以降の時間を、1日ずつ集計し直す
+// 起動後の最初の集計では、集計済みの最新の時間（無ければ最も古い注文）から埋め戻す
+func (s *OrderSummarizer) summarize(ctx context.Context, now time.Time) error {
+	start := now.Add(-s.lookback)
+	last := s.until
+	if last.IsZero() {
+		repo := s.store.SummaryRepo
+		latest, ok, err := repo.LatestBucket(ctx, model.SummaryPeriodHour)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			latest, ok, err = repo.EarliestOrder(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		if ok {
+			last = latest
+		}
+	}
+	if !last.IsZero() && last.Before(start) {
+		start = last
+	}
+	start = start.Truncate(time.Hour)
+	end := now.Truncate(time.Hour).Add(time.Hour)
+
+	for from := start; from.Before(end); {
+		day := from.Truncate(24 * time.Hour)
+		to := day.Add(24 * time.Hour)
+		if to.After(end) {
+			to = end
+		}
+		if err := s.summarizeDay(ctx, day, from, to); err != nil {
+			return err
+		}
+		from = to
+	}
+	return nil
+}
+
+// summarizeDay は [from, to) の時間ごとの集計を置き換え、day の日ごとの集計をその日の時間ごとの集計から求め直す
+func (s *OrderSummarizer) summarizeDay(ctx context.Context, day, from, to time.Time) error {
+	hours, err := s.store.SummaryRepo.AggregateHours(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	nextDay := day.Add(24 * time.Hour)
+	return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		if err := txStore.SummaryRepo.Replace(ctx, model.SummaryPeriodHour, from, to, hours); err != nil {
+			return err
+		}
+		dayHours, err := txStore.SummaryRepo.List(ctx, model.SummaryPeriodHour, day, nextDay)
+		if err != nil {
+			return err
+		}
+		var days []model.OrderSummary
+		if len(dayHours) > 0 {
+			total := model.OrderSummary{Period: model.SummaryPeriodDay, BucketStart: day}
+			value := model.NewMoney(0, model.DefaultCurrency)
+			for _, h := range dayHours {
+				total.OrdersCreated += h.OrdersCreated
+				if value, err = value.Add(model.NewMoney(h.ValueCreated, model.DefaultCurrency)); err != nil {
+					return fmt.Errorf("sum value created: %w", err)
+				}
+				total.OrdersDelivered += h.OrdersDelivered
+				total.DeliverySeconds += h.DeliverySeconds
+			}
+			total.ValueCreated = value.Amount
+			days = append(days, total)
+		}
+		return txStore.SummaryRepo.Replace(ctx, model.SummaryPeriodDay, day, nextDay, days)
+	})
+}
+
+// Summaries は period の since 以降の集計を返す（期間の上限を超える since は上限に切り詰める）
+func (s *OrderSummarizer) Summaries(ctx context.Context, period string, since time.Time) ([]model.OrderSummary, error) {
+	var limit, unit time.Duration
+	switch period {
+	case model.SummaryPeriodHour:
+		limit, unit = maxHourSummaries, time.Hour
+	case model.SummaryPeriodDay:
+		limit, unit = maxDaySummaries, 24*time.Hour
+	default:
+		return nil, ErrInvalidSummaryPeriod
+	}
+	if !s.Ready() {
+		return nil, ErrSummariesUnavailable
+	}
+	now := time.Now().UTC()
+	if earliest := now.Add(-limit); since.Before(earliest) {
+		since = earliest
+	}
+	summaries, err := s.store.SummaryRepo.List(ctx, period, since.Truncate(unit), now.Add(unit))
+	if err != nil {
+		return nil, err
+	}
+	for i := range summaries {
+		if summaries[i].OrdersDelivered > 0 {
+			avg := summaries[i].DeliverySeconds / float64(summaries[i].OrdersDelivered)
+			summaries[i].AvgTimeToDeliverySeconds = &avg
+		}
+	}
+	return summaries, nil
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+// IANA のタイムゾーン名として解釈できない
+var ErrInvalidTimezone = model.ErrInvalidTimezone
+
+type UserService struct {
+	store *repository.Store
+}
+
+func NewUserService(store *repository.Store) *UserService {
+	return &UserService{store: store}
+}
+
+// GetPreferences はユーザーの表示設定を返す
+func (s *UserService) GetPreferences(ctx context.Context, userID int) (model.UserPreferences, error) {
+	tz, err := s.store.UserRepo.FindTimezoneByID(ctx, userID)
+	if err != nil {
+		return model.UserPreferences{}, err
+	}
+	return model.UserPreferences{Timezone: tz}, nil
+}
+
+// UpdatePreferences はタイムゾーンを検証して保存する
+func (s *UserService) UpdatePreferences(ctx context.Context, userID int, prefs model.UserPreferences) (model.UserPreferences, error) {
+	loc, err := model.LoadTimezone(prefs.Timezone)
+	if err != nil {
+		return model.UserPreferences{}, err
+	}
+	prefs.Timezone = loc.String()
+	if err := s.store.UserRepo.UpdateTimezone(ctx, userID, prefs.Timezone); err != nil {
+		return model.UserPreferences{}, err
+	}
+	return prefs, nil
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/model"
+)
+
+// CacheWarmer は起動直後に、最初のリクエストが集中するキャッシュを読み込んでおく
+// デプロイ直後のリクエストがキャッシュの無い状態で DB を叩き、遅くならないようにする
+type CacheWarmer struct {
+	products *ProductService
+	robots   *RobotService
+	timeout  time.Duration
+	done     atomic.Bool
+}
+
+// cfg.Warmup が無効な場合は nil を返す
+func NewCacheWarmer(products *ProductService, robots *RobotService, cfg config.Cache) *CacheWarmer {
+	if !cfg.Warmup {
+		return nil
+	}
+	return &CacheWarmer{products: products, robots: robots, timeout: cfg.WarmupTimeout}
+}
+
+// Run は商品一覧の1ページ目・カテゴリ一覧・配送計画の候補を読み込む
+// 失敗してもログに残すだけで、timeout を過ぎるか ctx がキャンセルされた時点で打ち切る
+func (w *CacheWarmer) Run(ctx context.Context) {
+	defer w.done.Store(true)
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	start := time.Now()
+	steps := []struct {
+		name string
+		fn   func(ctx context.Context) error
+	}{
+		{"product_list", func(ctx context.Context) error {
+			// ハンドラが未指定の項目に入れる既定値と同じ条件（キャッシュのキーを一致させる）
+			req := model.ListRequest{Page: 1, PageSize: 20, SortField: "product_id", SortOrder: "asc"}
+			_, _, err := w.products.FetchProducts(ctx, 0, req)
+			return err
+		}},
+		{"categories", func(ctx context.Context) error {
+			_, err := w.products.FetchCategories(ctx)
+			return err
+		}},
+		{"shipping_orders", func(ctx context.Context) error {
+			_, err := w.robots.shippingOrders(ctx)
+			return err
+		}},
+	}
+	for _, step := range steps {
+		if err := step.fn(ctx); err != nil {
+			slog.WarnContext(ctx, "cache warmup step failed", "step", step.name, "err", err)
+		}
+	}
+	slog.InfoContext(ctx, "cache warmup finished", "elapsed", time.Since(start).String())
+}
+
+// Done は Run が終わったか（失敗・打ち切りを含む）。nil の場合は常に true
+func (w *CacheWarmer) Done() bool {
+	return w == nil || w.done.Load()
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBlobNotFound = errors.New("blob not found")
+	ErrInvalidKey   = errors.New("invalid blob key")
+)
+
+// Blob はBlobStoreから取得したオブジェクト
+// Content は http.ServeContent に渡せるよう Seek 可能であること
+type Blob struct {
+	Content io.ReadSeekCloser
+	Size    int64
+	ModTime time.Time
+}
+
+// BlobStore は商品画像などのバイナリを保存するストレージの抽象
+// ファイルシステム以外（S3など）を使う場合はこのインターフェースを実装する
+type BlobStore interface {
+	Get(ctx context.Context, key string) (*Blob, error)
+}
+
+// BlobInfo は List が返すオブジェクトの情報
+type BlobInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// WritableBlobStore は書き込みと一覧もできる BlobStore（日次レポートの書き出しなど）
+type WritableBlobStore interface {
+	BlobStore
+	// Put は key の内容を r で置き換える。書き込みの途中の内容は Get から見えない
+	Put(ctx context.Context, key string, r io.Reader) error
+	// List は prefix で始まるオブジェクトをキーの順に返す
+	List(ctx context.Context, prefix string) ([]BlobInfo, error)
+}
+
+// FSBlobStore はローカルディレクトリをバックエンドとするBlobStore
+type FSBlobStore struct {
+	baseDir string
+}
+
+func NewFSBlobStore(baseDir string) *FSBlobStore {
+	return &FSBlobStore{baseDir: baseDir}
+}
+
+func (s *FSBlobStore) Get(ctx context.Context, key string) (*Blob, error) {
+	key, ok := cleanKey(key)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	f, err := os.Open(filepath.Join(s.baseDir, key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, ErrBlobNotFound
+	}
+	return &Blob{Content: f, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Put は同じディレクトリの一時ファイルに書き込んでから置き換える
+func (s *FSBlobStore) Put(ctx context.Context, key string, r io.Reader) error {
+	key, ok := cleanKey(key)
+	if !ok {
+		return ErrInvalidKey
+	}
+	path := filepath.Join(s.baseDir, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// List は prefix のディレクトリ以下のファイルを返す（書き込み中の一時ファイルは含まない）
+func (s *FSBlobStore) List(ctx context.Context, prefix string) ([]BlobInfo, error) {
+	infos := []BlobInfo{}
+	root := s.baseDir
+	if dir := filepath.Dir(filepath.Clean(prefix)); dir != "." {
+		if _, ok := cleanKey(dir); !ok {
+			return nil, ErrInvalidKey
+		}
+		root = filepath.Join(s.baseDir, dir)
+	}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.baseDir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		infos = append(infos, BlobInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// cleanKey は baseDir の外や baseDir そのものを指すキーを拒否する
+func cleanKey(key string) (string, bool) {
+	key = filepath.Clean(key)
+	if key == "" || key == "." || filepath.IsAbs(key) || strings.Contains(key, "..") {
+		return "", false
+	}
+	return key, true
+}
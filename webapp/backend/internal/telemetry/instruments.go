@@ -0,0 +1,191 @@
+package telemetry
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// 計測器は起動時（Init の前）に作る。otel の既定の MeterProvider は後から登録されたものへ委譲する
+// メトリクスのエクスポーターが無い場合、Record* は属性を組み立てずに戻る
+var (
+	meter = otel.Meter("backend")
+
+	loginCount, _ = meter.Int64Counter("backend.logins",
+		metric.WithDescription("ログインの試行回数（result: success / invalid / error）"))
+	ordersCreated, _ = meter.Int64Counter("backend.orders.created",
+		metric.WithDescription("作成した注文の件数（source: order / checkout）"))
+	ordersClaimed, _ = meter.Int64Counter("backend.orders.claimed",
+		metric.WithDescription("配送計画で配送中にした注文の件数"))
+	ordersReaped, _ = meter.Int64Counter("backend.orders.reaped",
+		metric.WithDescription("配送中のまま期限を過ぎ、配送待ちへ戻した注文の件数"))
+	notifications, _ = meter.Int64Counter("backend.notifications",
+		metric.WithDescription("ユーザーへの通知の件数（channel: log / webhook / email、result: sent / failed / dropped）"))
+	poolTasks, _ = meter.Int64Counter("backend.workerpool.tasks",
+		metric.WithDescription("ワーカープールのタスクの件数（pool、result: done / rejected / expired / canceled）"))
+	poolQueueWait, _ = meter.Float64Histogram("backend.workerpool.queue_wait",
+		metric.WithDescription("タスクが待ち行列で実行を待った時間（pool）"),
+		metric.WithUnit("ms"),
+		metric.WithExplicitBucketBoundaries(1, 5, 10, 50, 100, 500, 1000, 2500, 5000, 10000))
+	poolBusy, _ = meter.Int64ObservableGauge("backend.workerpool.busy",
+		metric.WithDescription("実行中のタスクの数（pool）"))
+	poolQueued, _ = meter.Int64ObservableGauge("backend.workerpool.queued",
+		metric.WithDescription("待ち行列のタスクの数（pool）。capacity に張り付いている場合は飽和している"))
+	poolCapacity, _ = meter.Int64ObservableGauge("backend.workerpool.capacity",
+		metric.WithDescription("ワーカーの数と待ち行列の上限（pool、kind: workers / queue）"))
+	planSolveTime, _ = meter.Float64Histogram("backend.delivery_plan.solve_time",
+		metric.WithDescription("配送計画の計算（注文の選択）にかかった時間"),
+		metric.WithUnit("ms"),
+		metric.WithExplicitBucketBoundaries(1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000))
+	cacheLookups, _ = meter.Int64Counter("backend.cache.lookups",
+		metric.WithDescription("キャッシュの参照回数（cache: キャッシュ名、hit: true / false）"))
+	cacheEvictions, _ = meter.Int64Counter("backend.cache.evictions",
+		metric.WithDescription("キャッシュから追い出した件数（cache: キャッシュ名、reason: expired / capacity）"))
+)
+
+// ログインの結果
+const (
+	LoginSuccess = "success"
+	LoginInvalid = "invalid"
+	LoginError   = "error"
+)
+
+func RecordLogin(ctx context.Context, result string) {
+	if !metricsEnabled.Load() {
+		return
+	}
+	loginCount.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+}
+
+// RecordOrdersCreated は source（order / checkout）経由で作成した注文の件数を記録する
+func RecordOrdersCreated(ctx context.Context, source string, n int) {
+	if n > 0 && metricsEnabled.Load() {
+		ordersCreated.Add(ctx, int64(n), metric.WithAttributes(attribute.String("source", source)))
+	}
+}
+
+func RecordOrdersClaimed(ctx context.Context, n int64) {
+	if n > 0 && metricsEnabled.Load() {
+		ordersClaimed.Add(ctx, n)
+	}
+}
+
+// RecordOrdersReaped は配送中のまま期限を過ぎ、配送待ちへ戻した注文の件数を記録する
+func RecordOrdersReaped(ctx context.Context, n int64) {
+	if n > 0 && metricsEnabled.Load() {
+		ordersReaped.Add(ctx, n)
+	}
+}
+
+// 通知の結果
+const (
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+	NotificationDropped = "dropped"
+)
+
+// RecordNotification は channel での通知の結果を記録する（送信待ちの上限で破棄した場合は channel が空）
+func RecordNotification(ctx context.Context, channel, result string) {
+	if !metricsEnabled.Load() {
+		return
+	}
+	notifications.Add(ctx, 1, metric.WithAttributes(attribute.String("channel", channel), attribute.String("result", result)))
+}
+
+// ワーカープールのタスクの結果
+const (
+	PoolTaskDone     = "done"
+	PoolTaskRejected = "rejected"
+	PoolTaskExpired  = "expired"
+	PoolTaskCanceled = "canceled"
+)
+
+// PoolStats はワーカープールの状態
+type PoolStats struct {
+	Workers   int
+	QueueSize int
+	Busy      int
+	Queued    int
+}
+
+var (
+	poolsMu       sync.Mutex
+	pools         = map[string]func() PoolStats{}
+	poolsCallback sync.Once
+)
+
+// RegisterWorkerPool は name のワーカープールの状態を計測器として公開する（同じ名前は後から登録したものに置き換える）
+func RegisterWorkerPool(name string, stats func() PoolStats) {
+	poolsMu.Lock()
+	pools[name] = stats
+	poolsMu.Unlock()
+	poolsCallback.Do(func() {
+		_, _ = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+			poolsMu.Lock()
+			defer poolsMu.Unlock()
+			for name, stats := range pools {
+				st := stats()
+				pool := attribute.String("pool", name)
+				o.ObserveInt64(poolBusy, int64(st.Busy), metric.WithAttributes(pool))
+				o.ObserveInt64(poolQueued, int64(st.Queued), metric.WithAttributes(pool))
+				o.ObserveInt64(poolCapacity, int64(st.Workers), metric.WithAttributes(pool, attribute.String("kind", "workers")))
+				o.ObserveInt64(poolCapacity, int64(st.QueueSize), metric.WithAttributes(pool, attribute.String("kind", "queue")))
+			}
+			return nil
+		}, poolBusy, poolQueued, poolCapacity)
+	})
+}
+
+// RecordPoolTask は pool のタスクの結果を記録する
+func RecordPoolTask(ctx context.Context, pool, result string) {
+	if !metricsEnabled.Load() {
+		return
+	}
+	poolTasks.Add(ctx, 1, metric.WithAttributes(attribute.String("pool", pool), attribute.String("result", result)))
+}
+
+// RecordPoolQueueWait はタスクが実行を待った時間を記録する
+func RecordPoolQueueWait(ctx context.Context, pool string, d time.Duration) {
+	if !metricsEnabled.Load() {
+		return
+	}
+	poolQueueWait.Record(ctx, float64(d)/float64(time.Millisecond), metric.WithAttributes(attribute.String("pool", pool)))
+}
+
+// RecordPlanSolve は配送計画の計算時間を記録する。ctx には計算のスパンを渡すこと
+// サンプリングされたトレースの中で記録した値は exemplar（trace_id / span_id）としてヒストグラムのバケットに付き、
+// 候補の注文数と積載量は系列を増やさないよう exemplar にのみ残す（planSolveView を参照）
+func RecordPlanSolve(ctx context.Context, d time.Duration, candidates, capacity int) {
+	if !metricsEnabled.Load() {
+		return
+	}
+	planSolveTime.Record(ctx, float64(d)/float64(time.Millisecond), metric.WithAttributes(
+		attribute.Int("orders.candidate_count", candidates),
+		attribute.Int("capacity", capacity),
+	))
+}
+
+// planSolveView は配送計画の計算時間の属性をすべて系列から外す（外した属性は exemplar に残る）
+var planSolveView = sdkmetric.NewView(
+	sdkmetric.Instrument{Name: "backend.delivery_plan.solve_time"},
+	sdkmetric.Stream{AttributeFilter: func(attribute.KeyValue) bool { return false }},
+)
+
+func RecordCacheLookup(ctx context.Context, cache string, hit bool) {
+	if !metricsEnabled.Load() {
+		return
+	}
+	cacheLookups.Add(ctx, 1, metric.WithAttributes(attribute.String("cache", cache), attribute.Bool("hit", hit)))
+}
+
+func RecordCacheEviction(ctx context.Context, cache, reason string) {
+	if !metricsEnabled.Load() {
+		return
+	}
+	cacheEvictions.Add(ctx, 1, metric.WithAttributes(attribute.String("cache", cache), attribute.String("reason", reason)))
+}
@@ -0,0 +1,93 @@
+package telemetry
+
+import (
+	"backend/internal/config"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// Prometheus エクスポーターを使う場合の /metrics のハンドラ（それ以外は nil）
+var prometheusHandler http.Handler
+
+// PrometheusHandler は MetricsExporter が prometheus の場合に /metrics として公開するハンドラを返す（それ以外は nil）
+func PrometheusHandler() http.Handler {
+	return prometheusHandler
+}
+
+// newMetricReader は cfg.MetricsExporter のリーダーを作る（none の場合は nil）
+// OTLP の送信先とプロトコルはトレースと同じ設定を使う
+func newMetricReader(ctx context.Context, cfg config.Telemetry) (sdkmetric.Reader, error) {
+	switch name := strings.ToLower(cfg.MetricsExporter); name {
+	case "", "none":
+		return nil, nil
+	case "prometheus":
+		registry := prometheus.NewRegistry()
+		exp, err := otelprom.New(otelprom.WithRegisterer(registry))
+		if err != nil {
+			return nil, err
+		}
+		// exemplar（遅い計算のトレースへのリンク）は OpenMetrics 形式（Accept: application/openmetrics-text）でのみ出力される
+		prometheusHandler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+		return exp, nil
+	case "otlp":
+		ep := cfg.OTLPEndpoint
+		isURL := strings.Contains(ep, "://")
+		var exp sdkmetric.Exporter
+		var err error
+		if strings.EqualFold(cfg.OTLPProtocol, "grpc") {
+			var opts []otlpmetricgrpc.Option
+			switch {
+			case isURL:
+				opts = append(opts, otlpmetricgrpc.WithEndpointURL(ep))
+			case ep != "":
+				opts = append(opts, otlpmetricgrpc.WithEndpoint(ep), otlpmetricgrpc.WithInsecure())
+			}
+			exp, err = otlpmetricgrpc.New(ctx, opts...)
+		} else {
+			var opts []otlpmetrichttp.Option
+			switch {
+			case isURL:
+				opts = append(opts, otlpmetrichttp.WithEndpointURL(ep))
+			case ep != "":
+				opts = append(opts, otlpmetrichttp.WithEndpoint(ep), otlpmetrichttp.WithInsecure())
+			}
+			exp, err = otlpmetrichttp.New(ctx, opts...)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return sdkmetric.NewPeriodicReader(exp, sdkmetric.WithInterval(cfg.MetricsInterval)), nil
+	default:
+		return nil, fmt.Errorf("unknown metrics exporter %q", name)
+	}
+}
+
+// initMetrics は MeterProvider を登録し、終了時に呼ぶ関数を返す
+// エクスポーターが無い場合は登録せず、計測は何もしない（otel の既定の MeterProvider のまま）
+func initMetrics(ctx context.Context, cfg config.Telemetry) (func(context.Context) error, error) {
+	reader, err := newMetricReader(ctx, cfg)
+	if err != nil || reader == nil {
+		return func(context.Context) error { return nil }, err
+	}
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithResource(newResource(cfg)),
+		sdkmetric.WithView(planSolveView),
+	)
+	otel.SetMeterProvider(mp)
+	metricsEnabled.Store(true)
+	if err := registerRuntimeMetrics(mp.Meter("backend/runtime")); err != nil {
+		return mp.Shutdown, err
+	}
+	return mp.Shutdown, nil
+}
@@ -0,0 +1,43 @@
+package telemetry
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// エクスポーターが無い場合（既定）はスパンや計測の値を作らずに戻り、リクエストごとの割り当てを増やさない
+var (
+	tracingEnabled atomic.Bool
+	metricsEnabled atomic.Bool
+
+	noopTracer = noop.NewTracerProvider().Tracer("")
+	// 名前ごとのトレーサー（Init で TracerProvider を差し替えたら作り直す）
+	tracers sync.Map
+)
+
+// Enabled はトレースを送るかどうか。false の場合、スパンを作る処理や属性の組み立ては省いてよい
+func Enabled() bool {
+	return tracingEnabled.Load()
+}
+
+// Tracer は name のトレーサーを返す。トレースが無効の場合は共有の no-op トレーサーを返す
+func Tracer(name string) trace.Tracer {
+	if !tracingEnabled.Load() {
+		return noopTracer
+	}
+	if t, ok := tracers.Load(name); ok {
+		return t.(trace.Tracer)
+	}
+	t, _ := tracers.LoadOrStore(name, otel.Tracer(name))
+	return t.(trace.Tracer)
+}
+
+func setTracerProvider(tp trace.TracerProvider, enabled bool) {
+	otel.SetTracerProvider(tp)
+	tracers.Clear()
+	tracingEnabled.Store(enabled)
+}
@@ -0,0 +1,44 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+)
+
+// トレースと計測が無効の場合（既定）、スパンと計測の呼び出しは割り当てをしない
+func TestDisabledTelemetryDoesNotAllocate(t *testing.T) {
+	ctx := context.Background()
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = WithSpan(ctx, "noop", func(ctx context.Context) error { return nil })
+		_, _ = WithSpanResult(ctx, "noop", func(ctx context.Context) (int, error) { return 1, nil })
+		RecordLogin(ctx, LoginSuccess)
+		RecordCacheLookup(ctx, "products", true)
+	})
+	if allocs != 0 {
+		t.Fatalf("allocs per run = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkWithSpanDisabled(b *testing.B) {
+	ctx := context.Background()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = WithSpan(ctx, "noop", func(ctx context.Context) error { return nil })
+	}
+}
+
+func BenchmarkWithSpanResultDisabled(b *testing.B) {
+	ctx := context.Background()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = WithSpanResult(ctx, "noop", func(ctx context.Context) (int, error) { return 1, nil })
+	}
+}
+
+func BenchmarkRecordLoginDisabled(b *testing.B) {
+	ctx := context.Background()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		RecordLogin(ctx, LoginSuccess)
+	}
+}
@@ -0,0 +1,112 @@
+package telemetry
+
+import (
+	"context"
+	"database/sql"
+	"runtime"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// registerRuntimeMetrics は goroutine 数・GC・ヒープの統計を計測器として登録する
+// 値はメトリクスの収集時（OTLP は MetricsInterval ごと、Prometheus はスクレイプ時）に読む
+func registerRuntimeMetrics(m metric.Meter) error {
+	goroutines, err := m.Int64ObservableGauge("process.runtime.go.goroutines",
+		metric.WithDescription("goroutine の数"))
+	if err != nil {
+		return err
+	}
+	gcCount, err := m.Int64ObservableCounter("process.runtime.go.gc.count",
+		metric.WithDescription("完了した GC の回数"))
+	if err != nil {
+		return err
+	}
+	gcPause, err := m.Float64ObservableCounter("process.runtime.go.gc.pause_total",
+		metric.WithDescription("GC による停止時間の合計"), metric.WithUnit("s"))
+	if err != nil {
+		return err
+	}
+	heapAlloc, err := m.Int64ObservableGauge("process.runtime.go.mem.heap_alloc",
+		metric.WithDescription("割り当て中のヒープ"), metric.WithUnit("By"))
+	if err != nil {
+		return err
+	}
+	heapInuse, err := m.Int64ObservableGauge("process.runtime.go.mem.heap_inuse",
+		metric.WithDescription("使用中のヒープのスパン"), metric.WithUnit("By"))
+	if err != nil {
+		return err
+	}
+	heapSys, err := m.Int64ObservableGauge("process.runtime.go.mem.heap_sys",
+		metric.WithDescription("OS から確保したヒープ"), metric.WithUnit("By"))
+	if err != nil {
+		return err
+	}
+	heapObjects, err := m.Int64ObservableGauge("process.runtime.go.mem.heap_objects",
+		metric.WithDescription("ヒープ上のオブジェクト数"))
+	if err != nil {
+		return err
+	}
+
+	_, err = m.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		o.ObserveInt64(goroutines, int64(runtime.NumGoroutine()))
+		o.ObserveInt64(gcCount, int64(ms.NumGC))
+		o.ObserveFloat64(gcPause, float64(ms.PauseTotalNs)/float64(time.Second))
+		o.ObserveInt64(heapAlloc, int64(ms.HeapAlloc))
+		o.ObserveInt64(heapInuse, int64(ms.HeapInuse))
+		o.ObserveInt64(heapSys, int64(ms.HeapSys))
+		o.ObserveInt64(heapObjects, int64(ms.HeapObjects))
+		return nil
+	}, goroutines, gcCount, gcPause, heapAlloc, heapInuse, heapSys, heapObjects)
+	return err
+}
+
+// RegisterDBPoolMetrics は DB のコネクションプールの統計（sql.DBStats）を pool ごとに計測器として登録する
+// 使用中の接続が上限（DB_MAX_OPEN_CONNS）に張り付いて待ちが増えていないかを確認するためのもの
+func RegisterDBPoolMetrics(pools map[string]*sql.DB) error {
+	maxOpen, err := meter.Int64ObservableGauge("db.client.connections.max",
+		metric.WithDescription("接続数の上限"))
+	if err != nil {
+		return err
+	}
+	usage, err := meter.Int64ObservableGauge("db.client.connections.usage",
+		metric.WithDescription("接続数（state: used / idle）"))
+	if err != nil {
+		return err
+	}
+	waitCount, err := meter.Int64ObservableCounter("db.client.connections.wait_count",
+		metric.WithDescription("空き接続を待った回数"))
+	if err != nil {
+		return err
+	}
+	waitTime, err := meter.Float64ObservableCounter("db.client.connections.wait_time",
+		metric.WithDescription("空き接続を待った時間の合計"), metric.WithUnit("s"))
+	if err != nil {
+		return err
+	}
+	closed, err := meter.Int64ObservableCounter("db.client.connections.closed",
+		metric.WithDescription("閉じた接続数（reason: max_idle / max_idle_time / max_lifetime）"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for name, db := range pools {
+			s := db.Stats()
+			pool := attribute.String("pool.name", name)
+			o.ObserveInt64(maxOpen, int64(s.MaxOpenConnections), metric.WithAttributes(pool))
+			o.ObserveInt64(usage, int64(s.InUse), metric.WithAttributes(pool, attribute.String("state", "used")))
+			o.ObserveInt64(usage, int64(s.Idle), metric.WithAttributes(pool, attribute.String("state", "idle")))
+			o.ObserveInt64(waitCount, s.WaitCount, metric.WithAttributes(pool))
+			o.ObserveFloat64(waitTime, s.WaitDuration.Seconds(), metric.WithAttributes(pool))
+			o.ObserveInt64(closed, s.MaxIdleClosed, metric.WithAttributes(pool, attribute.String("reason", "max_idle")))
+			o.ObserveInt64(closed, s.MaxIdleTimeClosed, metric.WithAttributes(pool, attribute.String("reason", "max_idle_time")))
+			o.ObserveInt64(closed, s.MaxLifetimeClosed, metric.WithAttributes(pool, attribute.String("reason", "max_lifetime")))
+		}
+		return nil
+	}, maxOpen, usage, waitCount, waitTime, closed)
+	return err
+}
@@ -0,0 +1,108 @@
+package telemetry
+
+import (
+	"backend/internal/config"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// 値を隠した属性の値（redact）
+const redactedValue = "[REDACTED]"
+
+// scrubProcessor は終了したスパンの指定された属性（イベントの属性を含む）の値を、
+// エクスポートする前にハッシュ値か [REDACTED] に置き換えて next に渡す
+// 属性は OnEnd の時点で確定しているため、スパンの途中で付けられた属性も対象になる
+type scrubProcessor struct {
+	next sdktrace.SpanProcessor
+	keys map[attribute.Key]bool
+	// nil の場合は redact
+	hashKey []byte
+}
+
+// newScrubProcessor は cfg.ScrubAttributes が空の場合は next をそのまま返す
+func newScrubProcessor(next sdktrace.SpanProcessor, cfg config.Telemetry) sdktrace.SpanProcessor {
+	if len(cfg.ScrubAttributes) == 0 {
+		return next
+	}
+	p := &scrubProcessor{next: next, keys: make(map[attribute.Key]bool, len(cfg.ScrubAttributes))}
+	for _, k := range cfg.ScrubAttributes {
+		p.keys[attribute.Key(strings.ToLower(k))] = true
+	}
+	if !strings.EqualFold(cfg.ScrubMode, "redact") {
+		p.hashKey = []byte(cfg.ScrubKey)
+		if len(p.hashKey) == 0 {
+			p.hashKey = make([]byte, 32)
+			_, _ = rand.Read(p.hashKey)
+		}
+	}
+	return p
+}
+
+func (p *scrubProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(parent, s)
+}
+
+func (p *scrubProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.next.OnEnd(scrubbedSpan{ReadOnlySpan: s, p: p})
+}
+
+func (p *scrubProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p *scrubProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// scrub は対象の属性が無ければ attrs をそのまま返す
+func (p *scrubProcessor) scrub(attrs []attribute.KeyValue) []attribute.KeyValue {
+	var out []attribute.KeyValue
+	for i, kv := range attrs {
+		if !p.keys[attribute.Key(strings.ToLower(string(kv.Key)))] {
+			continue
+		}
+		if out == nil {
+			out = append([]attribute.KeyValue(nil), attrs...)
+		}
+		out[i] = attribute.String(string(kv.Key), p.mask(kv.Value))
+	}
+	if out == nil {
+		return attrs
+	}
+	return out
+}
+
+func (p *scrubProcessor) mask(v attribute.Value) string {
+	if p.hashKey == nil {
+		return redactedValue
+	}
+	mac := hmac.New(sha256.New, p.hashKey)
+	mac.Write([]byte(v.Emit()))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+type scrubbedSpan struct {
+	sdktrace.ReadOnlySpan
+	p *scrubProcessor
+}
+
+func (s scrubbedSpan) Attributes() []attribute.KeyValue {
+	return s.p.scrub(s.ReadOnlySpan.Attributes())
+}
+
+func (s scrubbedSpan) Events() []sdktrace.Event {
+	events := s.ReadOnlySpan.Events()
+	out := make([]sdktrace.Event, len(events))
+	for i, ev := range events {
+		ev.Attributes = s.p.scrub(ev.Attributes)
+		out[i] = ev
+	}
+	return out
+}
@@ -0,0 +1,42 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithSpan は name の子スパンの中で fn を実行する
+// fn が返したエラー（パニックも）はスパンに記録してステータスを Error にし、スパンは必ず終了する
+// 属性は fn の中で trace.SpanFromContext(ctx).SetAttributes で付ける（組み立てに手間がかかる場合は span.IsRecording() を確認する）
+// トレースが無効の場合はスパンを作らずに fn を呼ぶ
+func WithSpan(ctx context.Context, name string, fn func(ctx context.Context) error, opts ...trace.SpanStartOption) error {
+	_, err := WithSpanResult(ctx, name, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	}, opts...)
+	return err
+}
+
+// WithSpanResult は値を返す fn 用の WithSpan
+func WithSpanResult[T any](ctx context.Context, name string, fn func(ctx context.Context) (T, error), opts ...trace.SpanStartOption) (result T, err error) {
+	if !tracingEnabled.Load() {
+		return fn(ctx)
+	}
+	ctx, span := Tracer("backend").Start(ctx, name, opts...)
+	defer func() {
+		if p := recover(); p != nil {
+			span.RecordError(fmt.Errorf("panic: %v", p))
+			span.SetStatus(codes.Error, "panic")
+			span.End()
+			panic(p)
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+	return fn(ctx)
+}
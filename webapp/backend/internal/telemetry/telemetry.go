@@ -2,8 +2,11 @@
 package telemetry
 
 import (
+	"backend/internal/config"
 	"context"
-	"os"
+	"errors"
+	"fmt"
+	"log/slog"
 	"strconv"
 	"strings"
 	"time"
@@ -11,99 +14,161 @@ import (
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
-	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
-func enabled() bool {
-	if strings.EqualFold(os.Getenv("TRACE_ENABLED"), "false") {
-		return false
+// 比率サンプリングで比率が指定されていない場合の値
+const defaultSampleRatio = 0.01
+
+// exporterName は使用するエクスポーター。Exporter が空の場合は設定されているエンドポイントから選ぶ
+func exporterName(cfg config.Telemetry) string {
+	if cfg.Exporter != "" {
+		return strings.ToLower(cfg.Exporter)
 	}
-	if strings.EqualFold(os.Getenv("TRACE_ENABLED"), "true") {
-		return true
+	switch {
+	case cfg.JaegerEndpoint != "":
+		return "jaeger"
+	case cfg.OTLPEndpoint != "":
+		return "otlp"
+	case strings.EqualFold(cfg.TraceEnabled, "true"):
+		// エンドポイントの指定が無ければ OTLP の既定の送信先（localhost）へ送る
+		return "otlp"
 	}
-	if os.Getenv("JAEGER_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
-		return true
+	return "none"
+}
+
+func enabled(cfg config.Telemetry) bool {
+	if strings.EqualFold(cfg.TraceEnabled, "false") {
+		return false
 	}
-	return false
+	return exporterName(cfg) != "none"
 }
 
-func samplerFromEnv() sdktrace.Sampler {
-	if r := os.Getenv("TRACE_SAMPLE_RATIO"); r != "" {
-		if v, err := strconv.ParseFloat(r, 64); err == nil {
+func newSampler(cfg config.Telemetry) sdktrace.Sampler {
+	if cfg.SampleRatio != "" {
+		if v, err := strconv.ParseFloat(cfg.SampleRatio, 64); err == nil {
 			return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(v))
 		}
 	}
-	switch strings.ToLower(os.Getenv("OTEL_TRACES_SAMPLER")) {
+	ratio := defaultSampleRatio
+	if cfg.SamplerArg != "" {
+		if v, err := strconv.ParseFloat(cfg.SamplerArg, 64); err == nil {
+			ratio = v
+		}
+	}
+	switch strings.ToLower(cfg.Sampler) {
 	case "always_off":
 		return sdktrace.NeverSample()
 	case "always_on":
 		return sdktrace.AlwaysSample()
+	case "traceidratio":
+		return sdktrace.TraceIDRatioBased(ratio)
+	case "parentbased_always_on":
+		return sdktrace.ParentBased(sdktrace.AlwaysSample())
+	case "parentbased_always_off":
+		return sdktrace.ParentBased(sdktrace.NeverSample())
 	}
-	return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(0.01))
+	return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
 }
 
-func resourceFromEnv() *resource.Resource {
-	svc := os.Getenv("SERVICE_NAME")
-	if svc == "" {
-		svc = "backend"
-	}
-	env := os.Getenv("ENV")
-	if env == "" {
-		env = os.Getenv("GO_ENV")
-		if env == "" {
-			env = "local"
-		}
-	}
+func newResource(cfg config.Telemetry) *resource.Resource {
 	r, _ := resource.Merge(
 		resource.Default(),
 		resource.NewWithAttributes(
 			semconv.SchemaURL,
-			semconv.ServiceName(svc),
-			attribute.String("deployment.environment", env),
+			semconv.ServiceName(cfg.ServiceName),
+			attribute.String("deployment.environment", cfg.Environment),
 		),
 	)
 	return r
 }
 
-func Init(ctx context.Context) (func(context.Context) error, error) {
-	if !enabled() {
-		otel.SetTracerProvider(trace.NewNoopTracerProvider())
-		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
-			propagation.TraceContext{}, propagation.Baggage{},
-		))
-		return func(context.Context) error { return nil }, nil
+// newExporter は name のエクスポーターを作る
+// OTLP のエンドポイントは host:port（平文で接続）か URL（https の場合は TLS）で指定する
+func newExporter(ctx context.Context, name string, cfg config.Telemetry) (sdktrace.SpanExporter, error) {
+	switch name {
+	case "jaeger":
+		var opts []jaeger.CollectorEndpointOption
+		if ep := cfg.JaegerEndpoint; ep != "" {
+			opts = append(opts, jaeger.WithEndpoint(ep))
+		}
+		return jaeger.New(jaeger.WithCollectorEndpoint(opts...))
+	case "stdout":
+		return stdouttrace.New()
+	case "otlp":
+		ep := cfg.OTLPEndpoint
+		isURL := strings.Contains(ep, "://")
+		if strings.EqualFold(cfg.OTLPProtocol, "grpc") {
+			var opts []otlptracegrpc.Option
+			switch {
+			case isURL:
+				opts = append(opts, otlptracegrpc.WithEndpointURL(ep))
+			case ep != "":
+				opts = append(opts, otlptracegrpc.WithEndpoint(ep), otlptracegrpc.WithInsecure())
+			}
+			return otlptracegrpc.New(ctx, opts...)
+		}
+		var opts []otlptracehttp.Option
+		switch {
+		case isURL:
+			opts = append(opts, otlptracehttp.WithEndpointURL(ep))
+		case ep != "":
+			opts = append(opts, otlptracehttp.WithEndpoint(ep), otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
 	}
+	return nil, fmt.Errorf("unknown trace exporter %q", name)
+}
 
-	var (
-		exp sdktrace.SpanExporter
-		err error
-	)
-	if ep := os.Getenv("JAEGER_ENDPOINT"); ep != "" {
-		exp, err = jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(ep)))
-	} else if ep := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); ep != "" {
-		exp, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpoint(ep), otlptracehttp.WithInsecure())
+// Init はトレースとメトリクスのエクスポーターを設定から選んで登録し、終了時に呼ぶ関数を返す
+// エクスポーターを作れない場合はそれぞれを無効にして起動を続ける（traceparent の伝播は常に行う）
+func Init(ctx context.Context, cfg config.Telemetry) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+	shutdownMetrics, err := initMetrics(ctx, cfg)
+	if err != nil {
+		slog.Warn("telemetry: failed to create metrics exporter, metrics disabled", "exporter", cfg.MetricsExporter, "err", err)
 	}
-	if err != nil || exp == nil {
-		otel.SetTracerProvider(trace.NewNoopTracerProvider())
-		return func(context.Context) error { return nil }, nil
+	shutdownTracing := initTracing(ctx, cfg)
+	return func(ctx context.Context) error {
+		return errors.Join(shutdownTracing(ctx), shutdownMetrics(ctx))
+	}, nil
+}
+
+func initTracing(ctx context.Context, cfg config.Telemetry) func(context.Context) error {
+	nop := func(context.Context) error { return nil }
+	if !enabled(cfg) {
+		setTracerProvider(noop.NewTracerProvider(), false)
+		return nop
 	}
 
+	name := exporterName(cfg)
+	exp, err := newExporter(ctx, name, cfg)
+	if err != nil {
+		slog.Warn("telemetry: failed to create trace exporter, tracing disabled", "exporter", name, "err", err)
+		setTracerProvider(noop.NewTracerProvider(), false)
+		return nop
+	}
+
+	sampler := newSampler(cfg)
+	batcher := sdktrace.NewBatchSpanProcessor(exp,
+		sdktrace.WithMaxQueueSize(4096),
+		sdktrace.WithExportTimeout(5*time.Second),
+	)
 	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(samplerFromEnv()),
-		sdktrace.WithBatcher(exp,
-			sdktrace.WithMaxQueueSize(4096),
-			sdktrace.WithExportTimeout(5*time.Second),
-		),
-		sdktrace.WithResource(resourceFromEnv()),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithSpanProcessor(newScrubProcessor(batcher, cfg)),
+		sdktrace.WithResource(newResource(cfg)),
 	)
-	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{}, propagation.Baggage{},
-	))
-	return tp.Shutdown, nil
+	setTracerProvider(tp, true)
+	slog.Info("telemetry: tracing enabled", "exporter", name, "sampler", sampler.Description(), "service", cfg.ServiceName)
+	return tp.Shutdown
 }
@@ -0,0 +1,91 @@
+// Package ulid は外部に公開する ID として ULID（https://github.com/ulid/spec）を生成する
+//
+// ULID は先頭 48 ビットがミリ秒単位の時刻、残り 80 ビットが乱数の 128 ビットの値を
+// Crockford の Base32 で 26 文字にしたもの。文字列の順序が生成時刻の順序になるが、
+// 連番と異なり件数や作成のペースは推測できない
+package ulid
+
+import (
+	"crypto/rand"
+	"time"
+)
+
+// Len は ULID の文字列の長さ
+const Len = 26
+
+// Crockford の Base32（I, L, O, U を除く）
+const alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Make は t の時刻の ULID を返す
+func Make(t time.Time) string {
+	b := timestamp(t)
+	// crypto/rand.Read はエラーを返さない（失敗した場合はプロセスを終了する）
+	_, _ = rand.Read(b[6:])
+	return encode(b)
+}
+
+// timestamp は先頭 48 ビットに t のミリ秒を入れた 128 ビットを返す
+func timestamp(t time.Time) [16]byte {
+	var b [16]byte
+	ms := uint64(t.UnixMilli())
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+	return b
+}
+
+// New は現在時刻の ULID を返す
+func New() string {
+	return Make(time.Now())
+}
+
+// Valid は s が ULID の形式か（大文字・小文字は区別しない）を返す
+func Valid(s string) bool {
+	if len(s) != Len {
+		return false
+	}
+	// 先頭の文字は 128 ビットに収まる範囲（0〜7）のみ
+	if s[0] < '0' || s[0] > '7' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if decodeChar(s[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// encode は 128 ビットを 5 ビットずつ先頭から 26 文字にする（先頭の文字は上位 3 ビットのみ）
+func encode(b [16]byte) string {
+	out := make([]byte, Len)
+	// 下位から 5 ビットずつ取り出す
+	var acc uint32
+	bits := 0
+	pos := Len - 1
+	for i := len(b) - 1; i >= 0; i-- {
+		acc |= uint32(b[i]) << bits
+		bits += 8
+		for bits >= 5 {
+			out[pos] = alphabet[acc&0x1f]
+			pos--
+			acc >>= 5
+			bits -= 5
+		}
+	}
+	out[pos] = alphabet[acc&0x1f]
+	return string(out)
+}
+
+func decodeChar(c byte) int {
+	if c >= 'a' && c <= 'z' {
+		c -= 'a' - 'A'
+	}
+	for i := 0; i < len(alphabet); i++ {
+		if alphabet[i] == c {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,274 @@
+// Package validation は struct タグ `validate:"..."` に従ってリクエストの値を検証する
+//
+// 使えるルール（カンマ区切り）:
+//   - required: ゼロ値（空文字・0・空のスライス・nil）を許可しない
+//   - min=N / max=N: 数値は値、文字列は文字数、スライスは要素数の範囲
+//   - oneof=a b c: 文字列がいずれかに一致する
+//
+// required 以外のルールは、文字列・スライスが空の場合は検証しない（任意項目として扱う）
+//   - maxbytes=N: 文字列のバイト数の上限
+//
+// RegisterRule で独自のルールを追加でき、Validator を実装した型は項目をまたぐ検証も行える
+// ネストした struct やスライスの要素も検証し、フィールド名は JSON 名で items[0].quantity のように返す
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldError は1つのフィールドの検証エラー
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// Errors は検証エラーの一覧。Struct は不正な値があればこれを error として返す
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// Rule は値 v がルールを満たさない場合にエラーメッセージを返す（満たす場合は空文字）
+type Rule func(v reflect.Value, param string) string
+
+// Validator を実装した型は、タグによる検証の後に Validate も呼ばれる
+type Validator interface {
+	Validate() Errors
+}
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]Rule{
+		"required": ruleRequired,
+		"min":      ruleMin,
+		"max":      ruleMax,
+		"oneof":    ruleOneOf,
+		"maxbytes": ruleMaxBytes,
+	}
+)
+
+// RegisterRule は独自のルールを追加する（init で呼ぶこと）
+func RegisterRule(name string, rule Rule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[name] = rule
+}
+
+// Struct は v（struct またはそのポインタ）を検証し、不正な値があれば Errors を返す
+func Struct(v interface{}) error {
+	var errs Errors
+	validateValue(reflect.ValueOf(v), "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func validateValue(v reflect.Value, path string, errs *Errors) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		validateStruct(v, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+func validateStruct(v reflect.Value, path string, errs *Errors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := fieldName(f)
+		if name == "-" {
+			continue
+		}
+		if path != "" {
+			name = path + "." + name
+		}
+		fv := v.Field(i)
+		if tag := f.Tag.Get("validate"); tag != "" {
+			if msg := applyRules(fv, tag); msg != "" {
+				*errs = append(*errs, FieldError{Field: name, Message: msg})
+				continue
+			}
+		}
+		validateValue(fv, name, errs)
+	}
+	if v.CanAddr() {
+		if vv, ok := v.Addr().Interface().(Validator); ok {
+			*errs = append(*errs, prefixed(vv.Validate(), path)...)
+			return
+		}
+	}
+	if vv, ok := v.Interface().(Validator); ok {
+		*errs = append(*errs, prefixed(vv.Validate(), path)...)
+	}
+}
+
+func prefixed(errs Errors, path string) Errors {
+	if path == "" {
+		return errs
+	}
+	for i := range errs {
+		errs[i].Field = path + "." + errs[i].Field
+	}
+	return errs
+}
+
+// JSON のフィールド名（タグが無い場合は Go のフィールド名）
+func fieldName(f reflect.StructField) string {
+	if tag := f.Tag.Get("json"); tag != "" {
+		name, _, _ := strings.Cut(tag, ",")
+		if name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// 最初に満たさなかったルールのメッセージを返す
+func applyRules(v reflect.Value, tag string) string {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	for _, r := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(r), "=")
+		rule, ok := rules[name]
+		if !ok {
+			panic(fmt.Sprintf("validation: unknown rule %q", name))
+		}
+		// required 以外は未指定（空文字・nil）の場合は検証しない。数値は 0 も検証する
+		if name != "required" && isUnset(v) {
+			continue
+		}
+		if msg := rule(v, param); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+func isUnset(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Pointer, reflect.Interface:
+		return isZero(v)
+	}
+	return false
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.IsNil()
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
+func ruleRequired(v reflect.Value, _ string) string {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		if v.Len() == 0 {
+			return "is required"
+		}
+		return ""
+	}
+	if isZero(v) {
+		return "is required"
+	}
+	return ""
+}
+
+// 数値はそのまま、文字列は文字数、スライスは要素数
+func measure(v reflect.Value) (float64, string, bool) {
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), " items", true
+	}
+	return 0, "", false
+}
+
+func ruleMin(v reflect.Value, param string) string {
+	limit := mustFloat("min", param)
+	n, unit, ok := measure(v)
+	if ok && n < limit {
+		if unit != "" {
+			return "must have at least " + param + unit
+		}
+		return "must be at least " + param
+	}
+	return ""
+}
+
+func ruleMax(v reflect.Value, param string) string {
+	limit := mustFloat("max", param)
+	n, unit, ok := measure(v)
+	if ok && n > limit {
+		if unit != "" {
+			return "must have at most " + param + unit
+		}
+		return "must be at most " + param
+	}
+	return ""
+}
+
+func ruleOneOf(v reflect.Value, param string) string {
+	if v.Kind() != reflect.String {
+		return ""
+	}
+	options := strings.Fields(param)
+	for _, o := range options {
+		if v.String() == o {
+			return ""
+		}
+	}
+	return "must be one of " + strings.Join(options, ", ")
+}
+
+func ruleMaxBytes(v reflect.Value, param string) string {
+	limit := mustFloat("maxbytes", param)
+	if v.Kind() == reflect.String && float64(len(v.String())) > limit {
+		return "must not exceed " + param + " bytes"
+	}
+	return ""
+}
+
+// タグの書き間違いはプログラムの誤りなので panic にする
+func mustFloat(rule, param string) float64 {
+	f, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validation: invalid parameter %q for %s", param, rule))
+	}
+	return f
+}
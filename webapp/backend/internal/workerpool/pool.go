@@ -0,0 +1,260 @@
+// Package workerpool は決まった数のワーカーと上限のある待ち行列でタスクを実行する
+//
+// 待ち行列が満杯の場合は待たずに ErrSaturated を返すため、呼び出し側で 503 を返す・破棄するなどの背圧をかけられる
+// 終了時（Run の ctx のキャンセルまたは Close）は新しいタスクを受け付けず、受け付け済みのタスクの完了を待つ
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"backend/internal/telemetry"
+)
+
+var (
+	// 待ち行列が満杯
+	ErrSaturated = errors.New("worker pool is saturated")
+	// Close の後に投入された
+	ErrClosed = errors.New("worker pool is closed")
+	// 待ち行列で QueueTimeout を過ぎても実行が始まらなかった
+	ErrQueueTimeout = errors.New("worker pool queue wait timed out")
+)
+
+type Options struct {
+	Workers int
+	// 実行を待てるタスクの数。0 の場合は空いているワーカーがいなければ受け付けない
+	QueueSize int
+	// 待ち行列で実行を待てる時間（0 は無制限）。過ぎたタスクは実行しない
+	QueueTimeout time.Duration
+	// 1つのタスクの実行時間の上限（0 は無制限）。タスクの ctx の期限になる
+	TaskTimeout time.Duration
+	// Run の終了時に受け付け済みのタスクの完了を待つ時間。過ぎた場合は実行中のタスクの ctx をキャンセルする
+	DrainTimeout time.Duration
+}
+
+type Task func(ctx context.Context)
+
+type task struct {
+	ctx      context.Context
+	fn       Task
+	queuedAt time.Time
+	// Do で投入したタスクのみ。実行の開始と終了を待つ呼び出し側へ知らせる
+	started chan struct{}
+	done    chan struct{}
+	// ワーカーが実行を始めたか、Do の呼び出し側が待つのをやめたか（先に立てた方が決める）
+	claimed  atomic.Bool
+	panicked interface{}
+	// 実行せずに終えた理由（Do の呼び出し側へ返す）
+	skipped error
+}
+
+type Pool struct {
+	name  string
+	opts  Options
+	queue chan *task
+	// 終了の待ち時間を過ぎた場合にキャンセルし、実行中・待ち行列のタスクを止める
+	baseCtx    context.Context
+	cancelBase context.CancelFunc
+
+	mu      sync.RWMutex
+	closed  bool
+	workers sync.WaitGroup
+	busy    atomic.Int64
+}
+
+// New はワーカーを起動する。name はメトリクスとログでプールを区別する名前
+func New(name string, opts Options) *Pool {
+	baseCtx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		name:       name,
+		opts:       opts,
+		queue:      make(chan *task, opts.QueueSize),
+		baseCtx:    baseCtx,
+		cancelBase: cancel,
+	}
+	for i := 0; i < opts.Workers; i++ {
+		p.workers.Add(1)
+		go p.work()
+	}
+	telemetry.RegisterWorkerPool(name, p.Stats)
+	return p
+}
+
+// Submit は fn を待ち行列に入れ、実行を待たずに戻る
+// fn の ctx は ctx の値（トレースやリクエストID）を引き継ぐが、ctx のキャンセルは引き継がない
+func (p *Pool) Submit(ctx context.Context, fn Task) error {
+	return p.enqueue(&task{ctx: context.WithoutCancel(ctx), fn: fn, queuedAt: time.Now()})
+}
+
+// Do は fn をワーカーで実行し、終了を待ってその結果を返す
+// 実行が始まる前に ctx がキャンセルされた場合や QueueTimeout を過ぎた場合は実行せずに戻る
+// fn がパニックした場合は呼び出し側で同じ値でパニックする
+func (p *Pool) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	var err error
+	t := &task{
+		ctx:      ctx,
+		fn:       func(ctx context.Context) { err = fn(ctx) },
+		queuedAt: time.Now(),
+		started:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err := p.enqueue(t); err != nil {
+		return err
+	}
+
+	var expired <-chan time.Time
+	if p.opts.QueueTimeout > 0 {
+		timer := time.NewTimer(p.opts.QueueTimeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-t.started:
+	case <-expired:
+		if t.claimed.CompareAndSwap(false, true) {
+			telemetry.RecordPoolTask(ctx, p.name, telemetry.PoolTaskExpired)
+			return ErrQueueTimeout
+		}
+	case <-ctx.Done():
+		if t.claimed.CompareAndSwap(false, true) {
+			telemetry.RecordPoolTask(ctx, p.name, telemetry.PoolTaskCanceled)
+			return ctx.Err()
+		}
+	}
+	<-t.done
+	if t.panicked != nil {
+		panic(t.panicked)
+	}
+	if t.skipped != nil {
+		return t.skipped
+	}
+	return err
+}
+
+func (p *Pool) enqueue(t *task) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrClosed
+	}
+	select {
+	case p.queue <- t:
+		return nil
+	default:
+		telemetry.RecordPoolTask(t.ctx, p.name, telemetry.PoolTaskRejected)
+		return ErrSaturated
+	}
+}
+
+func (p *Pool) work() {
+	defer p.workers.Done()
+	for t := range p.queue {
+		p.run(t)
+	}
+}
+
+func (p *Pool) run(t *task) {
+	if !t.claimed.CompareAndSwap(false, true) {
+		// Do の呼び出し側が待つのをやめた
+		return
+	}
+	wait := time.Since(t.queuedAt)
+	switch {
+	case p.baseCtx.Err() != nil:
+		p.skip(t, telemetry.PoolTaskCanceled)
+		return
+	case t.started == nil && p.opts.QueueTimeout > 0 && wait > p.opts.QueueTimeout:
+		slog.WarnContext(t.ctx, "workerpool: task expired in queue", "pool", p.name, "wait", wait)
+		p.skip(t, telemetry.PoolTaskExpired)
+		return
+	}
+	telemetry.RecordPoolQueueWait(t.ctx, p.name, wait)
+
+	ctx, cancel := context.WithCancel(t.ctx)
+	defer cancel()
+	stop := context.AfterFunc(p.baseCtx, cancel)
+	defer stop()
+	if p.opts.TaskTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, p.opts.TaskTimeout)
+		defer cancelTimeout()
+	}
+
+	p.busy.Add(1)
+	defer p.busy.Add(-1)
+	if t.started != nil {
+		close(t.started)
+		defer close(t.done)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if t.started != nil {
+				t.panicked = r
+				return
+			}
+			slog.ErrorContext(ctx, "workerpool: task panicked", "pool", p.name, "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+	t.fn(ctx)
+	telemetry.RecordPoolTask(ctx, p.name, telemetry.PoolTaskDone)
+}
+
+// skip は実行しなかったタスクを記録し、Do の呼び出し側には ErrClosed を返す
+func (p *Pool) skip(t *task, result string) {
+	telemetry.RecordPoolTask(t.ctx, p.name, result)
+	if t.started != nil {
+		t.skipped = ErrClosed
+		close(t.started)
+		close(t.done)
+	}
+}
+
+// Run は ctx がキャンセルされるまで待ち、DrainTimeout まで受け付け済みのタスクの完了を待つ
+func (p *Pool) Run(ctx context.Context) {
+	<-ctx.Done()
+	drainCtx, cancel := context.WithTimeout(context.Background(), p.opts.DrainTimeout)
+	defer cancel()
+	if err := p.Close(drainCtx); err != nil {
+		slog.Warn("workerpool: tasks did not finish in time", "pool", p.name, "err", err)
+	}
+}
+
+// Close は新しいタスクの受け付けをやめ、受け付け済みのタスクの完了を ctx の期限まで待つ
+// 期限を過ぎた場合は実行中のタスクの ctx をキャンセルし、残りのタスクは実行せずに ctx.Err() を返す
+func (p *Pool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		p.cancelBase()
+		return nil
+	case <-ctx.Done():
+		p.cancelBase()
+		return ctx.Err()
+	}
+}
+
+// Stats はワーカーの数と実行中・待ち行列のタスクの数を返す
+func (p *Pool) Stats() telemetry.PoolStats {
+	return telemetry.PoolStats{
+		Workers:   p.opts.Workers,
+		QueueSize: cap(p.queue),
+		Busy:      int(p.busy.Load()),
+		Queued:    len(p.queue),
+	}
+}
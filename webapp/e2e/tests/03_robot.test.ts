@@ -2,7 +2,8 @@ import { test, expect } from "@playwright/test";
 import knapsackResults from "./sampleData/expectedRobotDeliveryPlan.json";
 
 type Orders = {
-  order_id: number;
+  // 公開ID（ULID）
+  order_id: string;
   user_id: number;
   product_id: number;
   product_name: string;
@@ -17,6 +18,7 @@ type Orders = {
 };
 
 const ROBOT_API_KEY = "test-robot-key";
+const ULID_PATTERN = /^[0-7][0-9A-HJKMNP-TV-Z]{25}$/;
 
 test.describe("ロボット配送最適化機能", () => {
   test("ロボットが容量制限内で最適な配送プランを生成する", async ({
@@ -51,27 +53,21 @@ test.describe("ロボット配送最適化機能", () => {
     expect(responseData.total_value).toBe(expectedResult.total_value);
     expect(responseData.orders.length).toBe(expectedResult.orders.length);
 
-    // 注文データをorder_idでソート（API結果と期待結果の両方）
-    const actualOrders = responseData.orders.sort(
-      (a: Orders, b: Orders) => a.order_id - b.order_id
-    );
-    const expectedOrders = expectedResult.orders.sort(
-      (a, b) => a.order_id - b.order_id
+    // 公開IDは乱数のため、期待結果の order_id（内部の連番）とは突き合わせられない
+    // 公開IDの形式と重複が無いことを確認し、注文は重量と価値の組で突き合わせる
+    const actualOrders: Orders[] = responseData.orders;
+    for (const order of actualOrders) {
+      expect(order.order_id).toMatch(ULID_PATTERN);
+    }
+    expect(new Set(actualOrders.map((order) => order.order_id)).size).toBe(
+      actualOrders.length
     );
 
-    // 各注文データが一致することを確認
-    for (let i = 0; i < expectedOrders.length; i++) {
-      const actual = actualOrders[i];
-      const expected = expectedOrders[i];
-
-      expect(actual.order_id).toBe(expected.order_id);
-      expect(actual.weight).toBe(expected.weight);
-      expect(actual.value).toBe(expected.value);
-
-      // 期待されるデータでは他のフィールドが空やデフォルト値なので、
-      // 実際のAPIではより詳細なデータが返される可能性があることを考慮
-      // 重要なフィールド（order_id, weight, value）の検証に集中
-    }
+    const weightValue = (order: { weight: number; value: number }) =>
+      `${order.weight}:${order.value}`;
+    expect(actualOrders.map(weightValue).sort()).toEqual(
+      expectedResult.orders.map(weightValue).sort()
+    );
 
     // 合計重量がキャパシティ以下であることを確認
     expect(responseData.total_weight).toBeLessThanOrEqual(capacity);
@@ -105,14 +101,15 @@ test.describe("ロボット配送最適化機能", () => {
 
     expect(loginResponse.status()).toBe(200);
 
-    // 注文を作成
-    const orderResponse = await request.post("/api/v1/product/post", {
+    // 注文を作成（v2 は作成した注文の公開IDを返す）
+    const orderResponse = await request.post("/api/v2/product/post", {
       data: {
         items: [{ product_id: 101942, quantity: 1 }],
       },
     });
     expect(orderResponse.ok()).toBeTruthy();
-    await orderResponse.json();
+    const { order_ids: createdOrderIDs } = await orderResponse.json();
+    expect(createdOrderIDs).toHaveLength(1);
 
     // 少し待機してからdelivery planを取得
     await new Promise((resolve) => setTimeout(resolve, 1000));
@@ -146,7 +143,7 @@ test.describe("ロボット配送最適化機能", () => {
     // 注文データが1件のみなのでそのまま比較
     const actual = responseData.orders[0];
     const expected = expectedResult.orders[0];
-    expect(actual.order_id).toBe(expected.order_id);
+    expect(actual.order_id).toBe(createdOrderIDs[0]);
     expect(actual.weight).toBe(expected.weight);
     expect(actual.value).toBe(expected.value);
   });
@@ -145,7 +145,8 @@ export default function ProductListPage() {
 
   const getImageUrl = (imagePath: string) => {
     if (!imagePath) return "/default-product.png";
-    return `/api/v1/image?path=${encodeURIComponent(imagePath)}`;
+    // 一覧は 60px で表示するため縮小版を取得する
+    return `/api/v1/image?path=${encodeURIComponent(imagePath)}&size=thumb`;
   };
 
   const columns: GridColDef[] = [
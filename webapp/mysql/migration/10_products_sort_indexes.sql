@@ -0,0 +1,5 @@
+-- 商品一覧のソート用インデックス
+-- InnoDBのセカンダリインデックスは主キー(product_id)を含むため、
+-- 「ソートキー + product_id」だけを読むID取得クエリがインデックスのみで完結する
+CREATE INDEX idx_products_value ON products(value);
+CREATE INDEX idx_products_weight ON products(weight);
@@ -0,0 +1,11 @@
+-- ユーザーごとの通知設定
+-- 行の無いユーザーには通知しない（PUT /api/users/me/notifications で設定したユーザーのみ）
+-- channels は通知に使うチャネル（log / webhook / email）のカンマ区切り
+CREATE TABLE notification_preferences (
+    user_id INT UNSIGNED NOT NULL PRIMARY KEY,
+    delivery_completed BOOLEAN NOT NULL DEFAULT TRUE,
+    channels VARCHAR(64) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    updated_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+-- ユーザーごとのタイムゾーン（IANA のタイムゾーン名）
+-- DB の日時は引き続き UTC で保持し、注文一覧などのレスポンスでこのタイムゾーンに変換する
+ALTER TABLE users ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
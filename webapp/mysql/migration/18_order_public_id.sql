@@ -0,0 +1,6 @@
+-- 注文の公開ID（ULID）
+-- API で返す注文IDを連番の order_id から推測できない値にし、注文数や作成のペースが分からないようにする
+-- order_id は引き続き内部のキーとして使う。既存の注文はサーバーが起動後に順次割り当てる（割り当てまでは NULL）
+ALTER TABLE orders
+    ADD COLUMN public_id CHAR(26) NULL,
+    ADD UNIQUE INDEX idx_orders_public_id (public_id);
@@ -0,0 +1,14 @@
+-- 管理者ロールと商品カタログ管理のためのスキーマ変更
+
+-- usersテーブルにロールを追加
+-- 'admin' のユーザーのみが /api/admin 配下の商品管理APIを利用できる
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user';
+
+-- productsテーブルに検索用テキストを追加
+-- name と description を連結した値を保持し、商品の作成・更新時にアプリケーション側で再計算する
+ALTER TABLE products ADD COLUMN search_text TEXT;
+UPDATE products SET search_text = CONCAT(name, ' ', COALESCE(description, ''));
+
+-- search_text に対するngramの全文検索インデックス
+-- ngram_token_size は my.cnf で設定済み
+CREATE FULLTEXT INDEX idx_products_search_text ON products(search_text) WITH PARSER ngram;
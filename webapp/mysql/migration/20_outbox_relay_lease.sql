@@ -0,0 +1,9 @@
+-- アウトボックスのリレーの実行権（リース）
+-- 複数台のサーバーでリレーを起動しても、リースを持つ1台だけが配信するため、二重配信や配信順の入れ替わりが起きない
+-- 持ち主が止まった場合は expires_at を過ぎた時点で他のサーバーが引き継ぐ
+CREATE TABLE outbox_relay_lease (
+    lease_id TINYINT UNSIGNED PRIMARY KEY,
+    owner VARCHAR(128) NOT NULL DEFAULT '',
+    expires_at DATETIME(6) NOT NULL
+);
+INSERT INTO outbox_relay_lease (lease_id, owner, expires_at) VALUES (1, '', '1970-01-01 00:00:00');
@@ -0,0 +1,11 @@
+-- サーバー側で永続化するショッピングカート
+-- ユーザーごとに1つのカートを持ち、(user_id, product_id) の複合主キーで数量を管理する
+CREATE TABLE cart_items (
+    user_id INT UNSIGNED NOT NULL,
+    product_id INT UNSIGNED NOT NULL,
+    quantity INT UNSIGNED NOT NULL,
+    updated_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, product_id),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
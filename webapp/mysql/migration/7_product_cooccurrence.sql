@@ -0,0 +1,13 @@
+-- 「この商品を注文した人はこんな商品も注文しています」用の共起テーブル
+-- ordersから定期的にバッチで再計算する（リアルタイムの重いJOINを避けるため）
+CREATE TABLE product_cooccurrence (
+    product_id INT UNSIGNED NOT NULL,
+    related_product_id INT UNSIGNED NOT NULL,
+    score INT UNSIGNED NOT NULL,
+    PRIMARY KEY (product_id, related_product_id),
+    -- 商品ごとに共起回数の多い順で取得するためのインデックス
+    INDEX idx_cooccurrence_product_score (product_id, score)
+);
+
+-- 共起集計で (user_id, created_at) 単位に同時注文をまとめるためのインデックス
+CREATE INDEX idx_orders_user_created ON orders(user_id, created_at);
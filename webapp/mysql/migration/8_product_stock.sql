@@ -0,0 +1,5 @@
+-- 商品の在庫数
+-- NULL の商品は在庫を管理しない（注文時に減算しない）
+ALTER TABLE products
+    ADD COLUMN stock INT NULL,
+    ADD INDEX idx_products_stock (stock);
@@ -0,0 +1,14 @@
+-- 商品価値（value）の変更履歴
+-- 配送計画の最適化は value に依存するため、いつ・誰が・なぜ変更したかを記録する
+CREATE TABLE product_price_history (
+    history_id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    product_id INT UNSIGNED NOT NULL,
+    old_value INT UNSIGNED NOT NULL,
+    new_value INT UNSIGNED NOT NULL,
+    changed_by INT UNSIGNED NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    changed_at DATETIME NOT NULL,
+    INDEX idx_price_history_product_changed (product_id, changed_at),
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+    FOREIGN KEY (changed_by) REFERENCES users(user_id) ON DELETE SET NULL
+);